@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/yourusername/go-red/internal/agent"
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/registry"
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// runAgent implements "go-red agent", which runs the flows a central
+// instance deploys to this one, and returns the exit code
+func runAgent(args []string) int {
+	fs := flag.NewFlagSet("agent", flag.ExitOnError)
+	hub := fs.String("hub", "", "WebSocket URL of the hub, e.g. wss://central/api/agents")
+	token := fs.String("token", os.Getenv("GO_RED_AGENT_TOKEN"), "Agent token (default $GO_RED_AGENT_TOKEN)")
+	id := fs.String("id", "", "Agent ID (default hostname)")
+	labels := fs.String("labels", "", "Comma-separated key=value labels")
+	flowDir := fs.String("flows", "./flows", "Directory to store flows")
+	httpPort := fs.Int("port", 0, "HTTP port for http-in nodes (0 disables)")
+	fs.Parse(args)
+
+	if *hub == "" {
+		fmt.Fprintln(os.Stderr, "usage: go-red agent -hub URL -token TOKEN [-id ID] [-labels k=v,...]")
+		return 2
+	}
+	if *id == "" {
+		*id, _ = os.Hostname()
+	}
+	labelMap, err := parseLabels(*labels)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	store, err := storage.NewFileStorage(*flowDir)
+	if err != nil {
+		log.Printf("Failed to initialize storage: %v", err)
+		return 1
+	}
+	reg := registry.New()
+	if err := reg.LoadBuiltinNodes(); err != nil {
+		log.Printf("Failed to load builtin nodes: %v", err)
+		return 1
+	}
+
+	// Flows deployed earlier run even if the hub is unreachable
+	eng := engine.New(reg, store)
+	if err := eng.Initialize(); err != nil {
+		log.Printf("Failed to initialize engine: %v", err)
+		return 1
+	}
+	if err := eng.Start(); err != nil {
+		log.Printf("Failed to start engine: %v", err)
+		return 1
+	}
+	defer eng.Stop()
+
+	if *httpPort != 0 {
+		go func() {
+			if err := http.ListenAndServe(fmt.Sprintf(":%d", *httpPort), eng.HTTPRoutes()); err != nil {
+				log.Printf("HTTP server error: %v", err)
+			}
+		}()
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	fmt.Printf("go-red agent %s started\n", *id)
+	agent.New(eng, store, agent.Options{
+		Hub:    *hub,
+		Token:  *token,
+		ID:     *id,
+		Labels: labelMap,
+	}).Run(ctx)
+
+	fmt.Println("Shutting down...")
+	return 0
+}
+
+// parseLabels parses "key=value,key=value"
+func parseLabels(value string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid label: %s", pair)
+		}
+		labels[key] = val
+	}
+	return labels, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the "pgx" database/sql driver
+
+	"github.com/yourusername/go-red/internal/cluster"
+	"github.com/yourusername/go-red/internal/config"
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/server"
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// flowSyncInterval is how often followers poll for deploys by the leader
+const flowSyncInterval = 2 * time.Second
+
+// openStorage creates the storage selected by storage.driver: "file"
+// (default) or "postgres" with storage.dsn, which also returns the database
+func openStorage(cfg *config.Config) (storage.Storage, *sql.DB, error) {
+	switch driver := cfg.GetString("storage.driver"); driver {
+	case "", "file":
+		store, err := storage.NewFileStorage(cfg.GetString("storage.dir"))
+		return store, nil, err
+	case "postgres":
+		db, err := sql.Open("pgx", cfg.GetString("storage.dsn"))
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := db.Ping(); err != nil {
+			return nil, nil, fmt.Errorf("failed to connect to database: %w", err)
+		}
+		store, err := storage.NewSQLStorage(db)
+		return store, db, err
+	default:
+		return nil, nil, fmt.Errorf("unknown storage driver: %s", driver)
+	}
+}
+
+// startCluster joins the cluster configured under "cluster": the engine only
+// runs flows while this instance holds the lease, and followers keep their
+// flows in sync with storage. The returned function leaves the cluster.
+func startCluster(cfg *config.Config, eng *engine.Engine, store storage.Storage, db *sql.DB, srv *server.Server) (func(), error) {
+	sqlStore, ok := store.(*storage.SQLStorage)
+	if !ok {
+		return nil, fmt.Errorf("clustering requires the postgres storage driver")
+	}
+
+	leaseName := cfg.GetString("cluster.name")
+	if leaseName == "" {
+		leaseName = "go-red"
+	}
+	locker, err := cluster.NewSQLLocker(db, leaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	host, _ := os.Hostname()
+	port := cfg.GetInt("http.port")
+	id := cfg.GetString("cluster.id")
+	if id == "" {
+		id = fmt.Sprintf("%s:%d", host, port)
+	}
+	address := cfg.GetString("cluster.advertise")
+	if address == "" {
+		address = fmt.Sprintf("http://%s:%d%s", host, port, srv.BasePath())
+	}
+	ttl, ok := cfg.LookupDuration("cluster.leaseTTL")
+	if value, exists := cfg.Get("cluster.leaseTTL"); exists && !ok {
+		return nil, fmt.Errorf("invalid cluster.leaseTTL: %v", value)
+	}
+
+	elector := cluster.NewElector(locker, cluster.ElectorOptions{
+		ID:      id,
+		Address: address,
+		TTL:     ttl,
+		OnElected: func(lease cluster.Lease) {
+			// Run the latest flows, including deploys not synced yet
+			if err := eng.Reload(); err != nil {
+				log.Printf("Warning: failed to reload flows: %v", err)
+			}
+			if err := eng.Start(); err != nil {
+				log.Printf("Warning: failed to start engine: %v", err)
+			}
+		},
+		OnDeposed: func() {
+			eng.Stop()
+		},
+	})
+
+	// Writes are only accepted under the lease this instance holds
+	sqlStore.SetFence(cluster.LeaseTable, locker.Name(), func() (storage.FenceToken, bool) {
+		lease, ok := elector.Lease()
+		return storage.FenceToken{Holder: lease.Holder, Version: lease.Version}, ok
+	})
+	srv.SetCluster(elector)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		elector.Run(ctx)
+		close(done)
+	}()
+
+	// Followers apply deploys made by the leader
+	go func() {
+		for change := range sqlStore.WatchFlows(ctx, flowSyncInterval) {
+			if elector.IsLeader() {
+				continue
+			}
+			if err := eng.SyncFlow(change.ID); err != nil {
+				log.Printf("Warning: failed to sync flow %s: %v", change.ID, err)
+			}
+		}
+	}()
+
+	log.Printf("Cluster: joined as %s (%s)", id, address)
+	return func() {
+		cancel()
+		<-done
+	}, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/yourusername/go-red/internal/config"
+	"github.com/yourusername/go-red/internal/examples"
+	"github.com/yourusername/go-red/internal/registry"
+)
+
+// runInit implements "go-red init", which prepares the flow storage of a new
+// installation and offers to install the basic example, and returns the
+// exit code
+func runInit(args []string) int {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	configFile := fs.String("config", "", "Path to config file")
+	flowDir := fs.String("flows", "./flows", "Directory to store flows")
+	example := fs.String("example", "basic", "Example to offer; empty installs none")
+	yes := fs.Bool("yes", false, "Install the example without asking")
+	fs.Parse(args)
+
+	cfg := config.New()
+	if *configFile != "" {
+		if err := cfg.LoadFromFile(*configFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+			return 1
+		}
+	}
+	cfg.SetDefault("storage.dir", *flowDir)
+	warnings, err := cfg.Validate()
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	store, db, err := openStorage(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize storage: %v\n", err)
+		return 1
+	}
+	if db != nil {
+		defer db.Close()
+	}
+	fmt.Println("Flow storage is ready")
+
+	if *example == "" {
+		return 0
+	}
+	chosen, err := examples.Get(*example)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if !*yes && !confirm(fmt.Sprintf("Install the %q example flow (%s)?", chosen.Name, chosen.Title)) {
+		return 0
+	}
+
+	log.SetOutput(ioutil.Discard)
+	reg := registry.New()
+	if err := reg.LoadBuiltinNodes(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load builtin nodes: %v\n", err)
+		return 1
+	}
+	id, def, err := chosen.Instantiate(reg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot install the example: %v\n", err)
+		return 1
+	}
+	if err := store.SaveFlow(id, def); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to save example flow: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Installed flow %s; it is disabled until you start it\n", id)
+	return 0
+}
+
+// confirm asks a yes/no question on the terminal, defaulting to yes
+func confirm(question string) bool {
+	fmt.Printf("%s [Y/n] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "" || answer == "y" || answer == "yes"
+}
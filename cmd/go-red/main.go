@@ -1,23 +1,49 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"log/slog"
+	"net"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/yourusername/go-red/internal/agent"
 	"github.com/yourusername/go-red/internal/config"
-	"github.com/yourusername/go-red/internal/engine"
 	"github.com/yourusername/go-red/internal/registry"
-	"github.com/yourusername/go-red/internal/server"
-	"github.com/yourusername/go-red/internal/storage"
+	"github.com/yourusername/go-red/pkg/flowtest"
+	"github.com/yourusername/go-red/pkg/gored"
 )
 
+// configReloadDelay is how long the config file must be left alone after
+// a change before it is reloaded, so that a save reloads it once
+const configReloadDelay = 200 * time.Millisecond
+
+// restartSettings are the prefixes of the settings that are not reloaded
+var restartSettings = []string{
+	"http.", "https.", "log.format", "metrics.", "audit.", "storage.", "cluster.", "agents.", "plugins", "messages.maxHops", "messages.trace", "credentialSecret",
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "test" {
+		os.Exit(runTests(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "agent" {
+		os.Exit(runAgent(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		os.Exit(runInit(os.Args[2:]))
+	}
+
 	// Parse command line flags
-	configFile := flag.String("config", "", "Path to config file")
+	configFile := flag.String("config", "", "Path to config file (JSON, YAML or TOML)")
 	httpPort := flag.Int("port", 1880, "HTTP port to listen on")
 	flowDir := flag.String("flows", "./flows", "Directory to store flows")
 	flag.Parse()
@@ -29,48 +55,144 @@ func main() {
 			log.Fatalf("Failed to load configuration: %v", err)
 		}
 	}
+	// Settings that are read once at startup need a restart to change
+	for _, prefix := range restartSettings {
+		cfg.OnChange(prefix, func(keys []string) {
+			log.Printf("Warning: changes to %s take effect after a restart", strings.Join(keys, ", "))
+		})
+	}
 	cfg.SetDefault("http.port", *httpPort)
 	cfg.SetDefault("storage.dir", *flowDir)
+	cfg.SetDefault("log.format", "text")
+
+	// Check every setting before anything starts
+	warnings, err := cfg.Validate()
+	for _, warning := range warnings {
+		log.Printf("Warning: %s", warning)
+	}
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
 
 	// Create storage
-	store, err := storage.NewFileStorage(cfg.GetString("storage.dir"))
+	store, db, err := openStorage(cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize storage: %v", err)
 	}
 
-	// Initialize node registry
-	reg := registry.New()
-	if err := reg.LoadBuiltinNodes(); err != nil {
-		log.Fatalf("Failed to load builtin nodes: %v", err)
+	// Create the runtime with the engine, the builtin nodes and the HTTP server
+	rt, err := gored.NewRuntime(
+		gored.WithConfig(cfg),
+		gored.WithStorage(store),
+		gored.WithHTTPServer(net.JoinHostPort(cfg.GetString("http.host"), strconv.Itoa(cfg.GetInt("http.port")))),
+	)
+	if err != nil {
+		log.Fatalf("Failed to create runtime: %v", err)
 	}
+	defer rt.Stop()
+	// The rest of the log output goes through the engine's logger too, in
+	// the same format and filtered by log.level
+	slog.SetDefault(rt.Engine().Logger())
 
-	// Create and initialize engine
-	eng := engine.New(reg, store)
-	if err := eng.Initialize(); err != nil {
-		log.Fatalf("Failed to initialize engine: %v", err)
-	}
+	// Flows with a target run on the edge agents that connect to this hub
+	hub := agent.NewHub(rt.Engine().Events(), cfg.GetString("agents.token"))
+	rt.Engine().SetDispatcher(hub)
+	rt.Server().SetAgentHub(hub)
 
-	// Start the engine
-	if err := eng.Start(); err != nil {
-		log.Fatalf("Failed to start engine: %v", err)
+	// Start the engine, or in cluster mode leave that to the leader election
+	if cfg.GetBool("cluster.enabled") {
+		if err := rt.Load(); err != nil {
+			log.Fatalf("Failed to initialize engine: %v", err)
+		}
+		leave, err := startCluster(cfg, rt.Engine(), store, db, rt.Server())
+		if err != nil {
+			log.Fatalf("Failed to join cluster: %v", err)
+		}
+		defer leave()
+		err = rt.Serve()
+	} else {
+		err = rt.Start()
+	}
+	if err != nil {
+		log.Fatalf("Failed to start: %v", err)
 	}
-	defer eng.Stop()
 
-	// Create and start HTTP server
-	srv := server.New(cfg, eng, store)
-	go func() {
-		if err := srv.Start(); err != nil {
-			log.Fatalf("Server error: %v", err)
+	// Reload the config file when it changes
+	if *configFile != "" {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		if err := cfg.Watch(ctx, *configFile, configReloadDelay); err != nil {
+			log.Printf("Warning: %v; config changes need a restart", err)
 		}
-	}()
+	}
 
-	fmt.Printf("go-red started on port %d\n", cfg.GetInt("http.port"))
+	if cfg.GetBool("https.enabled") && cfg.GetInt("https.port") != 0 {
+		fmt.Printf("go-red started on port %d (HTTPS), redirecting HTTP from port %d\n", cfg.GetInt("https.port"), cfg.GetInt("http.port"))
+	} else {
+		fmt.Printf("go-red started on port %d\n", cfg.GetInt("http.port"))
+	}
 	fmt.Println("Press Ctrl+C to exit")
 
-	// Wait for interrupt signal
+	// Wait for interrupt signal, reloading the TLS certificate on SIGHUP
 	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
-	<-sig
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for s := range sig {
+		if s != syscall.SIGHUP {
+			break
+		}
+		if !cfg.GetBool("https.enabled") {
+			continue
+		}
+		if err := rt.Server().ReloadCertificate(); err != nil {
+			log.Printf("Warning: %v", err)
+		} else {
+			log.Printf("Reloaded TLS certificate")
+		}
+	}
 
 	fmt.Println("Shutting down...")
 }
+
+// runTests implements "go-red test [-v] suite..." and returns the exit code
+func runTests(args []string) int {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	verbose := fs.Bool("v", false, "Show runtime logs")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: go-red test [-v] suite.json|suite.yaml...")
+		return 2
+	}
+	if !*verbose {
+		log.SetOutput(ioutil.Discard)
+	}
+
+	reg := registry.New()
+	if err := reg.LoadBuiltinNodes(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load builtin nodes: %v\n", err)
+		return 2
+	}
+	runner, err := flowtest.NewRunner(reg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create test runner: %v\n", err)
+		return 2
+	}
+
+	passed := true
+	for _, path := range fs.Args() {
+		results, err := runner.RunFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "FAIL  %v\n", err)
+			passed = false
+			continue
+		}
+		if !flowtest.Report(os.Stdout, results) {
+			passed = false
+		}
+	}
+
+	if !passed {
+		return 1
+	}
+	return 0
+}
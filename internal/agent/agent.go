@@ -0,0 +1,229 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+const (
+	defaultStatusInterval = 30 * time.Second
+	maxReconnectDelay     = 30 * time.Second
+)
+
+// Options configures an Agent
+type Options struct {
+	// Hub is the WebSocket URL of the hub, e.g. wss://central/api/agents
+	Hub string
+	// Token authenticates the agent with the hub
+	Token string
+	// ID identifies the agent; Labels select the flows it runs
+	ID     string
+	Labels map[string]string
+	// StatusInterval is how often status is reported besides on changes
+	StatusInterval time.Duration
+}
+
+// Agent runs the flows a hub deploys to it. Flows are kept in the engine's
+// storage, so the agent keeps running them while the hub is unreachable
+// and after restarts.
+type Agent struct {
+	engine  *engine.Engine
+	storage storage.Storage
+	options Options
+	started time.Time
+
+	// errors holds why stored flows failed to deploy
+	errors map[string]string
+	mu     sync.Mutex
+}
+
+// New creates an agent for an engine and the storage it loads flows from
+func New(eng *engine.Engine, store storage.Storage, options Options) *Agent {
+	if options.StatusInterval <= 0 {
+		options.StatusInterval = defaultStatusInterval
+	}
+	return &Agent{
+		engine:  eng,
+		storage: store,
+		options: options,
+		started: time.Now(),
+		errors:  make(map[string]string),
+	}
+}
+
+// Run connects to the hub and reconnects with backoff until ctx is done
+func (a *Agent) Run(ctx context.Context) {
+	delay := time.Second
+	for {
+		connected := time.Now()
+		err := a.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(connected) > maxReconnectDelay {
+			delay = time.Second
+		}
+		log.Printf("Warning: hub connection lost: %v; reconnecting in %v", err, delay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// session serves one connection to the hub until it fails
+func (a *Agent) session(ctx context.Context) error {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+a.options.Token)
+	ws, resp, err := websocket.DefaultDialer.DialContext(ctx, a.options.Hub, header)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("%w (HTTP %d)", err, resp.StatusCode)
+		}
+		return err
+	}
+	c := newConn(ws)
+	go c.writePump()
+	defer c.close()
+
+	stop := context.AfterFunc(ctx, c.close)
+	defer stop()
+
+	if err := c.write(TypeRegister, Registration{
+		ID:     a.options.ID,
+		Labels: a.options.Labels,
+		Flows:  a.storedFlows(),
+	}); err != nil {
+		return err
+	}
+	log.Printf("Connected to hub %s as %s", a.options.Hub, a.options.ID)
+
+	// Forward debug output and node status; events are dropped while the
+	// connection is backed up
+	unsubscribe := a.engine.Events().Subscribe(func(event engine.Event) {
+		c.tryWrite(TypeEvent, event)
+	})
+	defer unsubscribe()
+
+	go func() {
+		ticker := time.NewTicker(a.options.StatusInterval)
+		defer ticker.Stop()
+		for {
+			c.tryWrite(TypeStatus, a.status())
+			select {
+			case <-c.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	for {
+		msg, err := c.read(pongWait)
+		if err != nil {
+			return err
+		}
+
+		var deployment Deployment
+		if err := json.Unmarshal(msg.Payload, &deployment); err != nil || deployment.FlowID == "" {
+			log.Printf("Warning: invalid %s message from hub", msg.Type)
+			continue
+		}
+		switch msg.Type {
+		case TypeDeploy:
+			a.deploy(deployment)
+		case TypeDelete:
+			a.delete(deployment.FlowID)
+		default:
+			continue
+		}
+		c.tryWrite(TypeStatus, a.status())
+	}
+}
+
+// deploy runs a flow sent by the hub
+func (a *Agent) deploy(deployment Deployment) {
+	err := a.engine.DeployFlow(deployment.FlowID, deployment.Flow)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err != nil {
+		log.Printf("Warning: failed to deploy flow %s: %v", deployment.FlowID, err)
+		a.errors[deployment.FlowID] = err.Error()
+		return
+	}
+	delete(a.errors, deployment.FlowID)
+	log.Printf("Deployed flow %s", deployment.FlowID)
+}
+
+// delete removes a flow the hub no longer targets at this agent
+func (a *Agent) delete(id string) {
+	if err := a.engine.DeleteFlow(id); err != nil {
+		log.Printf("Warning: failed to delete flow %s: %v", id, err)
+	} else {
+		log.Printf("Removed flow %s", id)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.errors, id)
+}
+
+// storedFlows returns the hashes of the stored flows by ID
+func (a *Agent) storedFlows() map[string]string {
+	ids, err := a.storage.ListFlows()
+	if err != nil {
+		log.Printf("Warning: failed to list flows: %v", err)
+		return nil
+	}
+
+	flows := make(map[string]string, len(ids))
+	for _, id := range ids {
+		if flowDef, err := a.engine.LoadFlow(id); err == nil {
+			flows[id] = Hash(flowDef)
+		}
+	}
+	return flows
+}
+
+// status reports the stored flows and resource usage
+func (a *Agent) status() Status {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	status := Status{
+		Flows: make(map[string]FlowStatus),
+		Metrics: Metrics{
+			Uptime:     time.Since(a.started).Seconds(),
+			Goroutines: runtime.NumGoroutine(),
+			HeapBytes:  mem.HeapAlloc,
+		},
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for id, hash := range a.storedFlows() {
+		flowStatus := FlowStatus{Hash: hash, Error: a.errors[id]}
+		if flow, exists := a.engine.GetFlow(id); exists {
+			flowStatus.Status = string(flow.GetStatus())
+		} else {
+			flowStatus.Status = string(engine.FlowStatusError)
+		}
+		status.Flows[id] = flowStatus
+	}
+	return status
+}
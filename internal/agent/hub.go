@@ -0,0 +1,276 @@
+package agent
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// registerWait bounds how long a new connection may take to register
+const registerWait = 10 * time.Second
+
+// Info describes an agent known to the hub
+type Info struct {
+	ID          string                `json:"id"`
+	Labels      map[string]string     `json:"labels"`
+	Address     string                `json:"address"`
+	Connected   bool                  `json:"connected"`
+	ConnectedAt time.Time             `json:"connectedAt"`
+	LastSeen    time.Time             `json:"lastSeen"`
+	Flows       map[string]FlowStatus `json:"flows"`
+	Metrics     *Metrics              `json:"metrics,omitempty"`
+}
+
+// Hub accepts agent connections and deploys flows with a target selector to
+// the agents whose labels match. It implements engine.FlowDispatcher.
+type Hub struct {
+	events *engine.EventBus
+	token  string
+
+	// flows holds the definitions of targeted flows by ID
+	flows  map[string]targetedFlow
+	agents map[string]*remoteAgent
+	mu     sync.Mutex
+}
+
+type targetedFlow struct {
+	definition []byte
+	target     map[string]string
+}
+
+// remoteAgent is the hub's state for one agent
+type remoteAgent struct {
+	info Info
+	conn *conn
+	// assigned are the flows sent to the agent and not deleted since
+	assigned map[string]bool
+}
+
+// NewHub creates a hub. Events reported by agents are published on events.
+// Agents authenticate with token; without one, agent connections are
+// refused.
+func NewHub(events *engine.EventBus, token string) *Hub {
+	return &Hub{
+		events: events,
+		token:  token,
+		flows:  make(map[string]targetedFlow),
+		agents: make(map[string]*remoteAgent),
+	}
+}
+
+// Agents returns all agents seen since the hub started, ordered by ID
+func (h *Hub) Agents() []Info {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	agents := make([]Info, 0, len(h.agents))
+	for _, agent := range h.agents {
+		info := agent.info
+		info.Flows = make(map[string]FlowStatus, len(agent.info.Flows))
+		for id, status := range agent.info.Flows {
+			info.Flows[id] = status
+		}
+		agents = append(agents, info)
+	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].ID < agents[j].ID })
+	return agents
+}
+
+// DispatchFlow implements engine.FlowDispatcher
+func (h *Hub) DispatchFlow(id string, flowDef []byte, target map[string]string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(target) == 0 {
+		delete(h.flows, id)
+	} else {
+		h.flows[id] = targetedFlow{definition: flowDef, target: target}
+	}
+
+	for _, agent := range h.agents {
+		if agent.conn == nil {
+			continue
+		}
+		if Matches(agent.info.Labels, target) {
+			h.deploy(agent, id, flowDef)
+		} else if agent.assigned[id] {
+			h.remove(agent, id)
+		}
+	}
+}
+
+// RemoveFlow implements engine.FlowDispatcher
+func (h *Hub) RemoveFlow(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.flows, id)
+	for _, agent := range h.agents {
+		if agent.conn != nil && agent.assigned[id] {
+			h.remove(agent, id)
+		}
+	}
+}
+
+// deploy sends a flow to an agent; h.mu must be held
+func (h *Hub) deploy(agent *remoteAgent, id string, flowDef []byte) {
+	if err := agent.conn.write(TypeDeploy, Deployment{FlowID: id, Flow: flowDef}); err != nil {
+		log.Printf("Warning: failed to deploy flow %s to agent %s: %v", id, agent.info.ID, err)
+		return
+	}
+	agent.assigned[id] = true
+}
+
+// remove tells an agent to delete a flow; h.mu must be held
+func (h *Hub) remove(agent *remoteAgent, id string) {
+	if err := agent.conn.write(TypeDelete, Deployment{FlowID: id}); err != nil {
+		log.Printf("Warning: failed to remove flow %s from agent %s: %v", id, agent.info.ID, err)
+		return
+	}
+	delete(agent.assigned, id)
+}
+
+// HandleConnection accepts an agent's WebSocket connection and serves it
+// until it closes
+func (h *Hub) HandleConnection(w http.ResponseWriter, r *http.Request) {
+	if h.token == "" {
+		http.Error(w, "agent connections are disabled", http.StatusForbidden)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		http.Error(w, "invalid agent token", http.StatusUnauthorized)
+		return
+	}
+
+	upgrader := websocket.Upgrader{ReadBufferSize: 4096, WriteBufferSize: 4096}
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade agent connection: %v", err)
+		return
+	}
+	c := newConn(ws)
+	go c.writePump()
+	defer c.close()
+
+	msg, err := c.read(registerWait)
+	var reg Registration
+	if err == nil && msg.Type != TypeRegister {
+		err = errors.New("expected registration")
+	}
+	if err == nil {
+		err = json.Unmarshal(msg.Payload, &reg)
+	}
+	if err == nil && reg.ID == "" {
+		err = errors.New("agent ID is missing")
+	}
+	if err != nil {
+		log.Printf("Warning: rejected agent connection from %s: %v", r.RemoteAddr, err)
+		return
+	}
+
+	agent := h.register(reg, c, r.RemoteAddr)
+	log.Printf("Agent %s connected from %s", reg.ID, r.RemoteAddr)
+
+	for {
+		msg, err := c.read(pongWait)
+		if err != nil {
+			break
+		}
+		h.handle(agent, msg)
+	}
+
+	h.unregister(agent, c)
+	log.Printf("Agent %s disconnected", reg.ID)
+}
+
+// register records a connected agent and brings its flows up to date
+func (h *Hub) register(reg Registration, c *conn, address string) *remoteAgent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	agent, exists := h.agents[reg.ID]
+	if !exists {
+		agent = &remoteAgent{}
+		h.agents[reg.ID] = agent
+	} else if agent.conn != nil {
+		// The agent reconnected before the old connection timed out
+		agent.conn.close()
+	}
+
+	now := time.Now()
+	agent.conn = c
+	agent.info.ID = reg.ID
+	agent.info.Labels = reg.Labels
+	agent.info.Address = address
+	agent.info.Connected = true
+	agent.info.ConnectedAt = now
+	agent.info.LastSeen = now
+	agent.assigned = make(map[string]bool, len(reg.Flows))
+	for id := range reg.Flows {
+		agent.assigned[id] = true
+	}
+
+	// Deploy what changed while the agent was away and remove what is no
+	// longer targeted at it
+	for id, flow := range h.flows {
+		if Matches(reg.Labels, flow.target) && reg.Flows[id] != Hash(flow.definition) {
+			h.deploy(agent, id, flow.definition)
+		}
+	}
+	for id := range reg.Flows {
+		if flow, exists := h.flows[id]; !exists || !Matches(reg.Labels, flow.target) {
+			h.remove(agent, id)
+		}
+	}
+
+	return agent
+}
+
+// unregister marks an agent disconnected unless it already reconnected
+func (h *Hub) unregister(agent *remoteAgent, c *conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if agent.conn == c {
+		agent.conn = nil
+		agent.info.Connected = false
+	}
+}
+
+// handle processes a message from an agent
+func (h *Hub) handle(agent *remoteAgent, msg Message) {
+	switch msg.Type {
+	case TypeStatus:
+		var status Status
+		if err := json.Unmarshal(msg.Payload, &status); err != nil {
+			log.Printf("Warning: invalid status from agent %s: %v", agent.info.ID, err)
+			return
+		}
+		h.mu.Lock()
+		agent.info.Flows = status.Flows
+		agent.info.Metrics = &status.Metrics
+		agent.info.LastSeen = time.Now()
+		h.mu.Unlock()
+
+	case TypeEvent:
+		var event engine.Event
+		if err := json.Unmarshal(msg.Payload, &event); err != nil {
+			return
+		}
+		h.mu.Lock()
+		agent.info.LastSeen = time.Now()
+		event.Agent = agent.info.ID
+		h.mu.Unlock()
+		h.events.Publish(event)
+	}
+}
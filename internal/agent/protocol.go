@@ -0,0 +1,198 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Message types exchanged between agents and the hub
+const (
+	TypeRegister = "register" // agent to hub, first message
+	TypeDeploy   = "deploy"   // hub to agent
+	TypeDelete   = "delete"   // hub to agent
+	TypeStatus   = "status"   // agent to hub
+	TypeEvent    = "event"    // agent to hub
+)
+
+const (
+	// maxMessageSize bounds a message, which may carry a flow definition
+	maxMessageSize = 16 << 20
+	pongWait       = 60 * time.Second
+	pingInterval   = 25 * time.Second
+	writeWait      = 10 * time.Second
+)
+
+var errConnClosed = errors.New("agent connection closed")
+
+// Message is a frame on an agent connection
+type Message struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Registration is the first message an agent sends
+type Registration struct {
+	ID     string            `json:"id"`
+	Labels map[string]string `json:"labels,omitempty"`
+	// Flows maps the flows the agent has stored to their hashes
+	Flows map[string]string `json:"flows,omitempty"`
+}
+
+// Deployment carries a flow to an agent, or names one to delete
+type Deployment struct {
+	FlowID string          `json:"flowId"`
+	Flow   json.RawMessage `json:"flow,omitempty"`
+}
+
+// Status is an agent's report on its flows and resources
+type Status struct {
+	Flows   map[string]FlowStatus `json:"flows"`
+	Metrics Metrics               `json:"metrics"`
+}
+
+// FlowStatus is the state of a flow on an agent
+type FlowStatus struct {
+	Status string `json:"status"`
+	Hash   string `json:"hash"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Metrics are resource figures of an agent
+type Metrics struct {
+	Uptime     float64 `json:"uptime"` // seconds
+	Goroutines int     `json:"goroutines"`
+	HeapBytes  uint64  `json:"heapBytes"`
+}
+
+// Hash identifies a flow definition
+func Hash(flowDef []byte) string {
+	sum := sha256.Sum256(flowDef)
+	return hex.EncodeToString(sum[:])
+}
+
+// Matches reports whether an agent with labels is selected by target. An
+// empty target selects no agent.
+func Matches(labels, target map[string]string) bool {
+	if len(target) == 0 {
+		return false
+	}
+	for key, value := range target {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// conn is either end of an agent connection. Writes go through a buffered
+// channel drained by writePump.
+type conn struct {
+	ws   *websocket.Conn
+	send chan []byte
+	done chan struct{}
+	once sync.Once
+}
+
+func newConn(ws *websocket.Conn) *conn {
+	ws.SetReadLimit(maxMessageSize)
+	return &conn{
+		ws:   ws,
+		send: make(chan []byte, 256),
+		done: make(chan struct{}),
+	}
+}
+
+// write queues a message. If the peer cannot keep up the connection is
+// closed, so that both sides resynchronize when the agent reconnects.
+func (c *conn) write(typ string, payload interface{}) error {
+	data, err := encode(typ, payload)
+	if err != nil {
+		return err
+	}
+	select {
+	case c.send <- data:
+		return nil
+	case <-c.done:
+		return errConnClosed
+	default:
+		c.close()
+		return errors.New("agent connection send buffer full")
+	}
+}
+
+// tryWrite queues a message unless the buffer is full, in which case the
+// message is dropped
+func (c *conn) tryWrite(typ string, payload interface{}) {
+	data, err := encode(typ, payload)
+	if err != nil {
+		return
+	}
+	select {
+	case c.send <- data:
+	default:
+	}
+}
+
+// writePump writes queued messages and pings until the connection closes
+func (c *conn) writePump() {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	defer c.close()
+
+	for {
+		select {
+		case data := <-c.send:
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-c.done:
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			c.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		}
+	}
+}
+
+// read returns the next message, waiting at most wait for it or a pong
+func (c *conn) read(wait time.Duration) (Message, error) {
+	c.ws.SetReadDeadline(time.Now().Add(wait))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	var msg Message
+	_, data, err := c.ws.ReadMessage()
+	if err != nil {
+		return msg, err
+	}
+	return msg, json.Unmarshal(data, &msg)
+}
+
+// close closes the connection; it is safe to call more than once
+func (c *conn) close() {
+	c.once.Do(func() {
+		close(c.done)
+		// Give writePump a moment to send the close frame
+		time.AfterFunc(time.Second, func() { c.ws.Close() })
+	})
+}
+
+func encode(typ string, payload interface{}) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Message{Type: typ, Payload: data})
+}
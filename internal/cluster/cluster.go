@@ -0,0 +1,183 @@
+package cluster
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// defaultLeaseTTL is how long a lease lasts without renewal
+const defaultLeaseTTL = 15 * time.Second
+
+// Lease is the leadership of a cluster. Version increases every time the
+// lease changes hands and serves as fencing token.
+type Lease struct {
+	Holder  string    `json:"holder"`
+	Address string    `json:"address"`
+	Version int64     `json:"version"`
+	Expires time.Time `json:"expires"`
+}
+
+// Locker stores the cluster lease, e.g. in a database table shared by all
+// instances
+type Locker interface {
+	// Acquire takes the lease for holder if it is free or expired, or
+	// renews it if holder already has it. If another holder has an
+	// unexpired lease, ok is false and lease is that holder's lease.
+	Acquire(ctx context.Context, holder, address string, ttl time.Duration) (lease Lease, ok bool, err error)
+
+	// Release gives up the lease if it is still held with this version
+	Release(ctx context.Context, lease Lease) error
+}
+
+// ElectorOptions configures an Elector
+type ElectorOptions struct {
+	// ID identifies this instance
+	ID string
+	// Address is the base URL other instances use to reach this one
+	Address string
+	// TTL is the lease duration; the lease is renewed every TTL/3
+	TTL time.Duration
+	// OnElected is called when this instance became leader
+	OnElected func(lease Lease)
+	// OnDeposed is called when this instance stopped being leader
+	OnDeposed func()
+}
+
+// Elector campaigns for the cluster lease and tracks whether this instance
+// is the leader.
+//
+// A leader that cannot renew its lease steps down once two thirds of the TTL
+// passed since the last renewal, before any other instance can acquire the
+// expired lease. Another instance takes over at most TTL + TTL/3 after the
+// leader stopped renewing.
+type Elector struct {
+	locker  Locker
+	options ElectorOptions
+
+	lease     Lease
+	leader    bool
+	current   Lease
+	renewedAt time.Time
+	mu        sync.RWMutex
+}
+
+// NewElector creates an elector
+func NewElector(locker Locker, options ElectorOptions) *Elector {
+	if options.TTL <= 0 {
+		options.TTL = defaultLeaseTTL
+	}
+	if options.OnElected == nil {
+		options.OnElected = func(Lease) {}
+	}
+	if options.OnDeposed == nil {
+		options.OnDeposed = func() {}
+	}
+	return &Elector{locker: locker, options: options}
+}
+
+// Run campaigns until ctx is done, then releases the lease if held
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.options.TTL / 3)
+	defer ticker.Stop()
+
+	for {
+		e.campaign(ctx)
+
+		select {
+		case <-ctx.Done():
+			e.resign()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// campaign tries to acquire or renew the lease once
+func (e *Elector) campaign(ctx context.Context) {
+	attemptCtx, cancel := context.WithTimeout(ctx, e.options.TTL/3)
+	lease, ok, err := e.locker.Acquire(attemptCtx, e.options.ID, e.options.Address, e.options.TTL)
+	cancel()
+
+	e.mu.Lock()
+	wasLeader := e.leader
+	switch {
+	case err != nil:
+		log.Printf("Warning: cluster lease renewal failed: %v", err)
+		if e.leader && time.Since(e.renewedAt) >= e.options.TTL*2/3 {
+			e.leader = false
+		}
+	case ok:
+		if e.leader && lease.Version != e.lease.Version {
+			// The lease expired and was taken over in between
+			wasLeader = true
+			e.leader = false
+		}
+		e.lease, e.current = lease, lease
+		e.renewedAt = time.Now()
+	default:
+		e.current = lease
+		e.leader = false
+	}
+	isLeader := e.leader
+	e.mu.Unlock()
+
+	if wasLeader && !isLeader {
+		log.Printf("Cluster: %s lost leadership", e.options.ID)
+		e.options.OnDeposed()
+	}
+	if ok && err == nil && !isLeader {
+		e.mu.Lock()
+		e.leader = true
+		e.mu.Unlock()
+		log.Printf("Cluster: %s is leader (lease version %d)", e.options.ID, lease.Version)
+		e.options.OnElected(lease)
+	}
+}
+
+// resign steps down and releases the lease
+func (e *Elector) resign() {
+	e.mu.Lock()
+	wasLeader, lease := e.leader, e.lease
+	e.leader = false
+	e.mu.Unlock()
+
+	if !wasLeader {
+		return
+	}
+	e.options.OnDeposed()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := e.locker.Release(ctx, lease); err != nil {
+		log.Printf("Warning: failed to release cluster lease: %v", err)
+	}
+}
+
+// ID returns the ID of this instance
+func (e *Elector) ID() string {
+	return e.options.ID
+}
+
+// IsLeader reports whether this instance currently holds the lease
+func (e *Elector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leader
+}
+
+// Lease returns the lease while this instance is leader
+func (e *Elector) Lease() (Lease, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.lease, e.leader
+}
+
+// Leader returns the last lease seen, which may belong to another instance,
+// and whether it is still unexpired
+func (e *Elector) Leader() (Lease, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.current, e.current.Holder != "" && time.Now().Before(e.current.Expires)
+}
@@ -0,0 +1,76 @@
+package cluster
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// LeaseTable is the table SQLLocker keeps leases in
+const LeaseTable = "gored_lease"
+
+const leaseSchema = `CREATE TABLE IF NOT EXISTS ` + LeaseTable + ` (
+	name       TEXT PRIMARY KEY,
+	holder     TEXT NOT NULL,
+	address    TEXT NOT NULL,
+	version    BIGINT NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL
+)`
+
+// SQLLocker keeps the lease in a PostgreSQL table. Expiry is checked against
+// the database clock, so instance clocks do not need to agree.
+type SQLLocker struct {
+	db   *sql.DB
+	name string
+}
+
+// NewSQLLocker creates the lease table if needed and returns a locker for
+// the named lease
+func NewSQLLocker(db *sql.DB, name string) (*SQLLocker, error) {
+	if _, err := db.Exec(leaseSchema); err != nil {
+		return nil, fmt.Errorf("failed to create lease table: %w", err)
+	}
+	return &SQLLocker{db: db, name: name}, nil
+}
+
+// Name returns the name of the lease
+func (l *SQLLocker) Name() string {
+	return l.name
+}
+
+// Acquire takes or renews the lease in a single statement; the version only
+// increases when the lease changes hands
+func (l *SQLLocker) Acquire(ctx context.Context, holder, address string, ttl time.Duration) (Lease, bool, error) {
+	lease := Lease{Holder: holder, Address: address}
+	err := l.db.QueryRowContext(ctx, `INSERT INTO `+LeaseTable+` (name, holder, address, version, expires_at)
+		VALUES ($1, $2, $3, 1, now() + $4 * interval '1 millisecond')
+		ON CONFLICT (name) DO UPDATE SET holder = EXCLUDED.holder, address = EXCLUDED.address,
+			expires_at = EXCLUDED.expires_at,
+			version = CASE WHEN `+LeaseTable+`.holder = EXCLUDED.holder THEN `+LeaseTable+`.version ELSE `+LeaseTable+`.version + 1 END
+		WHERE `+LeaseTable+`.holder = EXCLUDED.holder OR `+LeaseTable+`.expires_at < now()
+		RETURNING version, expires_at`,
+		l.name, holder, address, ttl.Milliseconds()).Scan(&lease.Version, &lease.Expires)
+	if err == nil {
+		return lease, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return Lease{}, false, err
+	}
+
+	// Held by someone else
+	current := Lease{}
+	err = l.db.QueryRowContext(ctx, `SELECT holder, address, version, expires_at FROM `+LeaseTable+` WHERE name = $1`, l.name).
+		Scan(&current.Holder, &current.Address, &current.Version, &current.Expires)
+	if err != nil {
+		return Lease{}, false, err
+	}
+	return current, false, nil
+}
+
+// Release expires the lease if it is still held with the given version
+func (l *SQLLocker) Release(ctx context.Context, lease Lease) error {
+	_, err := l.db.ExecContext(ctx, `UPDATE `+LeaseTable+` SET expires_at = now()
+		WHERE name = $1 AND holder = $2 AND version = $3`, l.name, lease.Holder, lease.Version)
+	return err
+}
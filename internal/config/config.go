@@ -1,19 +1,76 @@
 package config
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the format of a config file
+type Format string
+
+// Config file formats
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+	FormatTOML Format = "toml"
 )
 
+// FormatOf returns the format of a config file by its extension, or an
+// empty format if the extension is unknown
+func FormatOf(filePath string) Format {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	}
+	return ""
+}
+
 // Config represents the application configuration
 type Config struct {
 	values map[string]interface{}
-	mu     sync.RWMutex
+	// file holds the values last loaded from the config file, so that a
+	// reload only replaces those
+	file map[string]interface{}
+	// defaults holds the values set with SetDefault, which a reload
+	// restores when their keys are removed from the file
+	defaults map[string]interface{}
+	// format is the format of the config file, which SaveToFile keeps
+	format Format
+	// templates holds the values of the config file before environment
+	// variables were expanded, for the keys that had any
+	templates map[string]interface{}
+	// envPrefix is the prefix of the environment variables LoadFromEnv
+	// applied, which a reload applies again
+	envPrefix *string
+	mu        sync.RWMutex
+
+	// listeners are called with the keys a reload changed
+	listeners  []listener
+	listenerMu sync.Mutex
+}
+
+// listener is a callback registered with OnChange
+type listener struct {
+	prefix string
+	fn     func(keys []string)
 }
 
 // New creates a new Config instance
@@ -23,36 +80,326 @@ func New() *Config {
 	}
 }
 
-// LoadFromFile loads configuration from a JSON file
+// LoadFromFile loads configuration from a JSON, YAML or TOML file, by its
+// extension; files with other extensions are read as JSON. Values may use
+// environment variables as ${VAR} or ${VAR:-default}, and the file fails
+// to load if any is unset without a default.
 func (c *Config) LoadFromFile(filePath string) error {
+	return c.LoadFromFileAs(filePath, "")
+}
+
+// LoadFromFileAs loads configuration from a file of the given format, or
+// of the format of its extension if format is empty
+func (c *Config) LoadFromFileAs(filePath string, format Format) error {
+	if format == "" {
+		format = FormatOf(filePath)
+	}
+	if format == "" {
+		format = FormatJSON
+	}
+	values, err := readConfigFile(filePath, format)
+	if err != nil {
+		return err
+	}
+	values, templates, err := expandFile(values)
+	if err != nil {
+		return err
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	// Flatten nested config
+	c.values = flattenMap(values, "")
+	c.file = flattenMap(values, "")
+	c.format = format
+	c.templates = templates
+
+	return nil
+}
+
+// readConfigFile reads a config file into nested maps holding the same
+// types as decoded JSON, whatever its format
+func readConfigFile(filePath string, format Format) (map[string]interface{}, error) {
 	data, err := ioutil.ReadFile(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to read config file: %w", err)
+		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
 	var values map[string]interface{}
-	if err := json.Unmarshal(data, &values); err != nil {
-		return fmt.Errorf("failed to parse config file: %w", err)
+	switch format {
+	case FormatJSON:
+		err = json.Unmarshal(data, &values)
+	case FormatYAML:
+		err = yaml.Unmarshal(data, &values)
+	case FormatTOML:
+		values, err = parseTOML(data)
+	default:
+		return nil, fmt.Errorf("unknown config file format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if values == nil {
+		values = make(map[string]interface{})
 	}
 
-	// Flatten nested config
-	c.values = flattenMap(values, "")
+	normalized, err := normalizeValue(values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	return normalized.(map[string]interface{}), nil
+}
+
+// normalizeValue converts the values decoded from YAML and TOML to those
+// of decoded JSON: numbers become float64, times strings, and maps have
+// string keys
+func normalizeValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			normalized, err := normalizeValue(child)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = normalized
+		}
+		return result, nil
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			normalized, err := normalizeValue(child)
+			if err != nil {
+				return nil, err
+			}
+			result[fmt.Sprint(key)] = normalized
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, child := range v {
+			normalized, err := normalizeValue(child)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = normalized
+		}
+		return result, nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case float32:
+		return float64(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case nil, string, bool, float64:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("unsupported value %v of type %T", v, v)
+	}
+}
+
+// Reload loads the config file again and calls the OnChange callbacks of
+// the keys whose values changed. Only values that came from the file are
+// replaced or removed, and removed keys fall back to their defaults.
+// Environment variables applied with LoadFromEnv keep overriding the file.
+// A file that fails to load or to validate against Schema leaves the
+// configuration as it was.
+func (c *Config) Reload(filePath string) error {
+	c.mu.RLock()
+	format := c.format
+	c.mu.RUnlock()
+	if format == "" {
+		format = FormatOf(filePath)
+	}
+	if format == "" {
+		format = FormatJSON
+	}
+	values, err := readConfigFile(filePath, format)
+	if err != nil {
+		return err
+	}
+	values, templates, err := expandFile(values)
+	if err != nil {
+		return err
+	}
+	file := flattenMap(values, "")
 
+	c.mu.Lock()
+	var env map[string]interface{}
+	if c.envPrefix != nil {
+		env = envValues(*c.envPrefix)
+	}
+	next := make(map[string]interface{}, len(c.values))
+	for key, value := range c.values {
+		next[key] = value
+	}
+	var changed []string
+	for key, old := range c.file {
+		if _, overridden := env[key]; overridden {
+			continue
+		}
+		if _, exists := file[key]; !exists && reflect.DeepEqual(next[key], old) {
+			if value, exists := c.defaults[key]; exists {
+				next[key] = value
+			} else {
+				delete(next, key)
+			}
+			if value, exists := c.defaults[key]; !exists || !reflect.DeepEqual(value, old) {
+				changed = append(changed, key)
+			}
+		}
+	}
+	for key, value := range file {
+		if _, overridden := env[key]; overridden {
+			continue
+		}
+		if current, exists := next[key]; !exists || !reflect.DeepEqual(current, value) {
+			next[key] = value
+			changed = append(changed, key)
+		}
+	}
+	for key, value := range env {
+		if current, exists := next[key]; !exists || !reflect.DeepEqual(current, value) {
+			next[key] = value
+			changed = append(changed, key)
+		}
+	}
+	warnings, err := validate(next, Schema)
+	if err != nil {
+		c.mu.Unlock()
+		return err
+	}
+	c.values = next
+	c.file = file
+	c.templates = templates
+	c.mu.Unlock()
+
+	for _, warning := range warnings {
+		log.Printf("Warning: %s", warning)
+	}
+
+	sort.Strings(changed)
+	c.notify(changed)
 	return nil
 }
 
-// SaveToFile saves configuration to a JSON file
+// OnChange registers fn to be called after a reload with the changed keys
+// that start with prefix, if any. Callbacks run one after another, without
+// locks held, so they may read the configuration.
+func (c *Config) OnChange(prefix string, fn func(keys []string)) {
+	c.listenerMu.Lock()
+	defer c.listenerMu.Unlock()
+	c.listeners = append(c.listeners, listener{prefix: prefix, fn: fn})
+}
+
+// notify calls the listeners of changed keys
+func (c *Config) notify(changed []string) {
+	if len(changed) == 0 {
+		return
+	}
+	c.listenerMu.Lock()
+	defer c.listenerMu.Unlock()
+	for _, l := range c.listeners {
+		var keys []string
+		for _, key := range changed {
+			if strings.HasPrefix(key, l.prefix) {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) > 0 {
+			l.fn(keys)
+		}
+	}
+}
+
+// Watch reloads the config file whenever it is written or replaced, once
+// its events have settled for delay, until ctx is done. Failed reloads are
+// logged and leave the configuration as it was.
+func (c *Config) Watch(ctx context.Context, filePath string, delay time.Duration) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch config file: %w", err)
+	}
+	// The directory is watched, as editors and deployment tools often
+	// replace the file rather than write to it
+	if err := watcher.Add(filepath.Dir(filePath)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch config file: %w", err)
+	}
+	name := filepath.Clean(filePath)
+
+	go func() {
+		defer watcher.Close()
+		// settled fires once the events of a save are over
+		settled := time.NewTimer(delay)
+		settled.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				settled.Stop()
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == name && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					settled.Reset(delay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("Warning: watching config file: %v", err)
+			case <-settled.C:
+				if err := c.Reload(filePath); err != nil {
+					log.Printf("Warning: failed to reload config: %v", err)
+					continue
+				}
+				log.Printf("Reloaded config from %s", filePath)
+			}
+		}
+	}()
+	return nil
+}
+
+// SaveToFile saves configuration to a file in the format of its extension,
+// or else in the format it was loaded from, or else as JSON
 func (c *Config) SaveToFile(filePath string) error {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	// Values expanded from the environment are saved as they were written,
+	// unless they have been changed since
+	values := make(map[string]interface{}, len(c.values))
+	for key, value := range c.values {
+		if template, exists := c.templates[key]; exists && reflect.DeepEqual(value, c.file[key]) {
+			value = template
+		}
+		values[key] = value
+	}
+
 	// Unflatten the config for saving
-	nestedValues := unflattenMap(c.values)
+	nestedValues := unflattenMap(values)
 
-	data, err := json.MarshalIndent(nestedValues, "", "  ")
+	format := FormatOf(filePath)
+	if format == "" {
+		format = c.format
+	}
+	var data []byte
+	var err error
+	switch format {
+	case FormatYAML:
+		data, err = yaml.Marshal(nestedValues)
+	case FormatTOML:
+		data, err = encodeTOML(nestedValues)
+	default:
+		data, err = json.MarshalIndent(nestedValues, "", "  ")
+	}
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
@@ -81,100 +428,258 @@ func (c *Config) Get(key string) (interface{}, bool) {
 
 // GetString gets a string configuration value
 func (c *Config) GetString(key string) string {
+	value, _ := c.LookupString(key)
+	return value
+}
+
+// LookupString gets a string configuration value, converting other values
+// to strings, and reports whether the key is set
+func (c *Config) LookupString(key string) (string, bool) {
 	value, exists := c.Get(key)
 	if !exists {
-		return ""
+		return "", false
 	}
 
 	strValue, ok := value.(string)
 	if !ok {
 		// Try to convert to string
-		return fmt.Sprintf("%v", value)
+		return fmt.Sprintf("%v", value), true
 	}
 
-	return strValue
+	return strValue, true
 }
 
 // GetInt gets an integer configuration value
 func (c *Config) GetInt(key string) int {
+	value, _ := c.LookupInt(key)
+	return value
+}
+
+// GetIntWithDefault gets an integer configuration value, or def if the key
+// is not set or not an integer. Unlike GetInt, an explicit 0 is kept.
+func (c *Config) GetIntWithDefault(key string, def int) int {
+	if value, ok := c.LookupInt(key); ok {
+		return value
+	}
+	return def
+}
+
+// LookupInt gets an integer configuration value and reports whether the
+// key is set to an integer or something that converts to one
+func (c *Config) LookupInt(key string) (int, bool) {
 	value, exists := c.Get(key)
 	if !exists {
-		return 0
+		return 0, false
 	}
 
 	switch v := value.(type) {
 	case int:
-		return v
+		return v, true
 	case float64:
-		return int(v)
+		return int(v), true
 	case string:
 		intValue, err := strconv.Atoi(v)
 		if err != nil {
-			return 0
+			return 0, false
 		}
-		return intValue
+		return intValue, true
 	default:
-		return 0
+		return 0, false
 	}
 }
 
 // GetBool gets a boolean configuration value
 func (c *Config) GetBool(key string) bool {
+	value, _ := c.LookupBool(key)
+	return value
+}
+
+// LookupBool gets a boolean configuration value and reports whether the
+// key is set to a boolean or something that converts to one
+func (c *Config) LookupBool(key string) (bool, bool) {
 	value, exists := c.Get(key)
 	if !exists {
-		return false
+		return false, false
 	}
 
 	switch v := value.(type) {
 	case bool:
-		return v
+		return v, true
 	case string:
 		boolValue, err := strconv.ParseBool(v)
 		if err != nil {
-			return false
+			return false, false
 		}
-		return boolValue
+		return boolValue, true
 	case int:
-		return v != 0
+		return v != 0, true
 	case float64:
-		return v != 0
+		return v != 0, true
 	default:
-		return false
+		return false, false
 	}
 }
 
 // GetFloat gets a float configuration value
 func (c *Config) GetFloat(key string) float64 {
+	value, _ := c.LookupFloat(key)
+	return value
+}
+
+// LookupFloat gets a float configuration value and reports whether the key
+// is set to a number or something that converts to one
+func (c *Config) LookupFloat(key string) (float64, bool) {
 	value, exists := c.Get(key)
 	if !exists {
-		return 0
+		return 0, false
 	}
 
 	switch v := value.(type) {
 	case float64:
-		return v
+		return v, true
 	case int:
-		return float64(v)
+		return float64(v), true
 	case string:
 		floatValue, err := strconv.ParseFloat(v, 64)
 		if err != nil {
-			return 0
+			return 0, false
 		}
-		return floatValue
+		return floatValue, true
 	default:
-		return 0
+		return 0, false
 	}
 }
 
+// GetDuration gets a duration configuration value, such as "15s" or "2m"
+func (c *Config) GetDuration(key string) time.Duration {
+	value, _ := c.LookupDuration(key)
+	return value
+}
+
+// LookupDuration gets a duration configuration value and reports whether
+// the key is set to a duration. Strings are parsed as Go durations, and
+// numbers are taken as seconds.
+func (c *Config) LookupDuration(key string) (time.Duration, bool) {
+	value, exists := c.Get(key)
+	if !exists {
+		return 0, false
+	}
+
+	switch v := value.(type) {
+	case string:
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			return 0, false
+		}
+		return d, true
+	case time.Duration:
+		return v, true
+	case int:
+		return time.Duration(v) * time.Second, true
+	case float64:
+		return time.Duration(v * float64(time.Second)), true
+	default:
+		return 0, false
+	}
+}
+
+// GetStringSlice gets a list configuration value: an array, or a string of
+// comma-separated entries. Entries are trimmed and empty ones left out.
+func (c *Config) GetStringSlice(key string) []string {
+	value, _ := c.LookupStringSlice(key)
+	return value
+}
+
+// LookupStringSlice gets a list configuration value and reports whether
+// the key is set
+func (c *Config) LookupStringSlice(key string) ([]string, bool) {
+	value, exists := c.Get(key)
+	if !exists {
+		return nil, false
+	}
+
+	var entries []string
+	switch v := value.(type) {
+	case []interface{}:
+		for _, entry := range v {
+			entries = append(entries, fmt.Sprintf("%v", entry))
+		}
+	case []string:
+		entries = v
+	default:
+		entries = strings.Split(fmt.Sprintf("%v", v), ",")
+	}
+
+	list := []string{}
+	for _, entry := range entries {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list, true
+}
+
+// GetOrDefault gets a configuration value of the type of def, converting it
+// like the getter of that type, or returns def if the key is not set or
+// does not convert:
+//
+//	timeout := config.GetOrDefault(cfg, "http.readTimeout", 15*time.Second)
+func GetOrDefault[T any](c *Config, key string, def T) T {
+	var value interface{}
+	var ok bool
+	switch any(def).(type) {
+	case string:
+		value, ok = c.LookupString(key)
+	case int:
+		value, ok = c.LookupInt(key)
+	case bool:
+		value, ok = c.LookupBool(key)
+	case float64:
+		value, ok = c.LookupFloat(key)
+	case time.Duration:
+		value, ok = c.LookupDuration(key)
+	case []string:
+		value, ok = c.LookupStringSlice(key)
+	default:
+		value, ok = c.Get(key)
+	}
+	if !ok {
+		return def
+	}
+	if typed, ok := value.(T); ok {
+		return typed
+	}
+	return def
+}
+
 // SetDefault sets a default value if the key doesn't exist
 func (c *Config) SetDefault(key string, value interface{}) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	if c.defaults == nil {
+		c.defaults = make(map[string]interface{})
+	}
+	c.defaults[key] = value
 	if _, exists := c.values[key]; !exists {
 		c.values[key] = value
 	}
 }
 
+// Keys returns the sorted keys that start with prefix
+func (c *Config) Keys(prefix string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var keys []string
+	for key := range c.values {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // Delete removes a configuration value
 func (c *Config) Delete(key string) {
 	c.mu.Lock()
@@ -182,11 +687,22 @@ func (c *Config) Delete(key string) {
 	delete(c.values, key)
 }
 
-// LoadFromEnv loads configuration from environment variables
+// LoadFromEnv loads configuration from environment variables, which
+// override the config file, also when it is reloaded
 func (c *Config) LoadFromEnv(prefix string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	for key, value := range envValues(prefix) {
+		c.values[key] = value
+	}
+	c.envPrefix = &prefix
+}
+
+// envValues returns the values of the environment variables starting with
+// prefix, by config key: PREFIX_HTTP_PORT sets http.port
+func envValues(prefix string) map[string]interface{} {
+	values := make(map[string]interface{})
 	for _, env := range os.Environ() {
 		parts := strings.SplitN(env, "=", 2)
 		if len(parts) != 2 {
@@ -205,11 +721,15 @@ func (c *Config) LoadFromEnv(prefix string) {
 		// Replace underscores with dots for nested keys
 		configKey = strings.ReplaceAll(configKey, "_", ".")
 
-		c.values[configKey] = value
+		values[configKey] = value
 	}
+	return values
 }
 
-// flattenMap converts a nested map to a flat map with dot-separated keys
+// flattenMap converts a nested map to a flat map with dot-separated keys.
+// Arrays of plain values, such as lists of names, are kept as values, and
+// arrays holding maps or arrays are flattened by index, so that the
+// servers of "servers": [{"host": ...}] are at servers.0.host.
 func flattenMap(nested map[string]interface{}, prefix string) map[string]interface{} {
 	result := make(map[string]interface{})
 
@@ -218,19 +738,41 @@ func flattenMap(nested map[string]interface{}, prefix string) map[string]interfa
 		if prefix != "" {
 			key = prefix + "." + k
 		}
+		flattenValue(result, key, v)
+	}
 
-		switch child := v.(type) {
-		case map[string]interface{}:
-			childMap := flattenMap(child, key)
-			for ck, cv := range childMap {
-				result[ck] = cv
-			}
-		default:
-			result[key] = v
+	return result
+}
+
+// flattenValue adds a value to a flat map under key
+func flattenValue(result map[string]interface{}, key string, value interface{}) {
+	switch child := value.(type) {
+	case map[string]interface{}:
+		for ck, cv := range flattenMap(child, key) {
+			result[ck] = cv
+		}
+	case []interface{}:
+		if !hasContainers(child) {
+			result[key] = value
+			return
+		}
+		for i, element := range child {
+			flattenValue(result, key+"."+strconv.Itoa(i), element)
 		}
+	default:
+		result[key] = value
 	}
+}
 
-	return result
+// hasContainers reports whether an array holds maps or arrays
+func hasContainers(array []interface{}) bool {
+	for _, element := range array {
+		switch element.(type) {
+		case map[string]interface{}, []interface{}:
+			return true
+		}
+	}
+	return false
 }
 
 // unflattenMap converts a flat map with dot-separated keys to a nested map
@@ -257,5 +799,32 @@ func unflattenMap(flat map[string]interface{}) map[string]interface{} {
 		}
 	}
 
+	for k, v := range result {
+		result[k] = restoreArrays(v)
+	}
 	return result
 }
+
+// restoreArrays turns the maps of flattened arrays, whose keys are the
+// indexes 0 to n-1, back into arrays
+func restoreArrays(value interface{}) interface{} {
+	nested, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	for key, child := range nested {
+		nested[key] = restoreArrays(child)
+	}
+	if len(nested) == 0 {
+		return nested
+	}
+	array := make([]interface{}, len(nested))
+	for i := range array {
+		element, exists := nested[strconv.Itoa(i)]
+		if !exists {
+			return nested
+		}
+		array[i] = element
+	}
+	return array
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a config file holding a value of http.port
+func writeConfig(t *testing.T, path string, port int) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(fmt.Sprintf(`{"http": {"port": %d}}`, port)), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConcurrentGetDuringReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, 1000)
+	c := New()
+	if err := c.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				// Every read sees one of the written values, never none
+				port := c.GetInt("http.port")
+				if port < 1000 || port >= 1100 {
+					t.Errorf("read http.port %d during reload", port)
+					return
+				}
+			}
+		}()
+	}
+	for port := 1001; port < 1100; port++ {
+		writeConfig(t, path, port)
+		if err := c.Reload(path); err != nil {
+			t.Error(err)
+			break
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if port := c.GetInt("http.port"); port != 1099 {
+		t.Errorf("http.port is %d after the last reload, want 1099", port)
+	}
+}
+
+func TestReloadKeepsEnvOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, 1880)
+	t.Setenv("GORED_TEST_HTTP_PORT", "9000")
+	c := New()
+	if err := c.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	c.LoadFromEnv("GORED_TEST_")
+
+	var changed []string
+	c.OnChange("http.", func(keys []string) { changed = append(changed, keys...) })
+	writeConfig(t, path, 1881)
+	if err := c.Reload(path); err != nil {
+		t.Fatal(err)
+	}
+	if port := c.GetInt("http.port"); port != 9000 {
+		t.Errorf("http.port is %d after reload, want the environment's 9000", port)
+	}
+	if len(changed) > 0 {
+		t.Errorf("reload reported %v changed", changed)
+	}
+}
+
+func TestWatchReloadsOnWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, 1880)
+	c := New()
+	if err := c.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := c.Watch(ctx, path, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replacing the file is seen as well as writing to it
+	writeConfig(t, path+".tmp", 1881)
+	if err := os.Rename(path+".tmp", path); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for c.GetInt("http.port") != 1881 {
+		if time.Now().After(deadline) {
+			t.Fatal("config was not reloaded after the file was replaced")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
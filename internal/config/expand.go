@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// expandEnv replaces ${VAR} and ${VAR:-default} in s by the value of the
+// environment variable, or by default when it is unset or empty; $${
+// stands for a literal ${. Variables that are unset and have no default
+// are added to missing.
+func expandEnv(s string, missing map[string]bool) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+	var sb strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			sb.WriteString(s)
+			return sb.String()
+		}
+		if i > 0 && s[i-1] == '$' {
+			sb.WriteString(s[:i])
+			sb.WriteString("{")
+			s = s[i+2:]
+			continue
+		}
+		end := strings.Index(s[i:], "}")
+		if end < 0 {
+			sb.WriteString(s)
+			return sb.String()
+		}
+		sb.WriteString(s[:i])
+		name, def, hasDefault := strings.Cut(s[i+2:i+end], ":-")
+		if value := os.Getenv(name); value != "" {
+			sb.WriteString(value)
+		} else if hasDefault {
+			sb.WriteString(def)
+		} else if _, set := os.LookupEnv(name); !set {
+			missing[name] = true
+		}
+		s = s[i+end+1:]
+	}
+}
+
+// expandValue expands the environment variables in the strings of a
+// decoded config value
+func expandValue(value interface{}, missing map[string]bool) interface{} {
+	switch v := value.(type) {
+	case string:
+		return expandEnv(v, missing)
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			result[key] = expandValue(child, missing)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, child := range v {
+			result[i] = expandValue(child, missing)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// expandFile expands the environment variables in the values of a config
+// file. It returns the expanded values and the flat templates of those
+// that changed, or an error naming every unresolved variable.
+func expandFile(values map[string]interface{}) (map[string]interface{}, map[string]interface{}, error) {
+	missing := make(map[string]bool)
+	expanded := expandValue(values, missing).(map[string]interface{})
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, nil, fmt.Errorf("unresolved environment variables in config file: %s", strings.Join(names, ", "))
+	}
+
+	templates := make(map[string]interface{})
+	flat := flattenMap(expanded, "")
+	for key, template := range flattenMap(values, "") {
+		if !reflect.DeepEqual(flat[key], template) {
+			templates[key] = template
+		}
+	}
+	return expanded, templates, nil
+}
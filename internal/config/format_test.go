@@ -0,0 +1,88 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fixtureValues are the flattened values of the config files in testdata,
+// which hold the same config in each format
+var fixtureValues = map[string]interface{}{
+	"name":                     "go-red",
+	"ratio":                    0.75,
+	"started":                  "2026-03-29T02:30:00Z",
+	"matrix.0":                 []interface{}{float64(1), float64(2)},
+	"matrix.1":                 []interface{}{float64(3), float64(4)},
+	"http.port":                float64(1880),
+	"http.timeout":             "30s",
+	"http.compression.enabled": true,
+	"http.compression.types":   []interface{}{"text/*", "application/json"},
+	"servers.0.host":           "alpha",
+	"servers.0.port":           float64(8001),
+	"servers.0.tags":           []interface{}{"primary"},
+	"servers.1.host":           "beta",
+	"servers.1.port":           float64(8002),
+	"servers.1.tags":           []interface{}{},
+}
+
+func TestLoadFormats(t *testing.T) {
+	for _, name := range []string{"config.json", "config.yaml", "config.toml"} {
+		t.Run(name, func(t *testing.T) {
+			c := New()
+			if err := c.LoadFromFile(filepath.Join("testdata", name)); err != nil {
+				t.Fatal(err)
+			}
+			checkValues(t, c, fixtureValues)
+
+			// Saving keeps the format, and loading the saved file gives
+			// the same values
+			saved := filepath.Join(t.TempDir(), "saved")
+			if err := c.SaveToFile(saved); err != nil {
+				t.Fatal(err)
+			}
+			reloaded := New()
+			if err := reloaded.LoadFromFileAs(saved, c.format); err != nil {
+				data, _ := os.ReadFile(saved)
+				t.Fatalf("%v, saved as:\n%s", err, data)
+			}
+			checkValues(t, reloaded, fixtureValues)
+		})
+	}
+}
+
+func TestLoadFormatByArgument(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "go-red.conf")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := New().LoadFromFile(path); err == nil {
+		t.Error("YAML without a known extension was read as JSON")
+	}
+	c := New()
+	if err := c.LoadFromFileAs(path, FormatYAML); err != nil {
+		t.Fatal(err)
+	}
+	checkValues(t, c, fixtureValues)
+}
+
+// checkValues checks the flattened values of a config
+func checkValues(t *testing.T, c *Config, want map[string]interface{}) {
+	t.Helper()
+	for key, value := range want {
+		if got, _ := c.Get(key); !reflect.DeepEqual(got, value) {
+			t.Errorf("%s = %#v, want %#v", key, got, value)
+		}
+	}
+	for _, key := range c.Keys("") {
+		if _, expected := want[key]; !expected {
+			t.Errorf("unexpected key %s", key)
+		}
+	}
+}
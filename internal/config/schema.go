@@ -0,0 +1,366 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type is the type of a setting
+type Type string
+
+// Setting types
+const (
+	TypeAny      Type = "any"
+	TypeString   Type = "string"
+	TypeInt      Type = "integer"
+	TypeFloat    Type = "number"
+	TypeBool     Type = "boolean"
+	TypeDuration Type = "duration"
+	TypeList     Type = "list"
+)
+
+// Range bounds a numeric setting, inclusively
+type Range struct {
+	Min, Max float64
+}
+
+// Rule describes a setting. Keys may contain "*" for any one segment, as in
+// "workspaces.*.users".
+type Rule struct {
+	Key      string
+	Type     Type
+	Required bool
+	// RequiredIf makes the setting required when another has a value,
+	// given as "key=value"
+	RequiredIf string
+	Range      *Range
+	// Enum lists the allowed values, if limited
+	Enum []string
+}
+
+// Schema describes the settings of go-red
+var Schema = []Rule{
+	{Key: "http.port", Type: TypeInt, Range: &Range{1, 65535}},
+	{Key: "http.readTimeout", Type: TypeDuration},
+	{Key: "http.writeTimeout", Type: TypeDuration},
+	{Key: "http.readHeaderTimeout", Type: TypeDuration},
+	{Key: "http.idleTimeout", Type: TypeDuration},
+	{Key: "http.host", Type: TypeString},
+	{Key: "http.basePath", Type: TypeString},
+	{Key: "http.compression.enabled", Type: TypeBool},
+	{Key: "http.compression.minSize", Type: TypeInt, Range: &Range{0, math.MaxInt32}},
+	{Key: "http.compression.types", Type: TypeList},
+	{Key: "https.enabled", Type: TypeBool},
+	{Key: "https.certFile", Type: TypeString, RequiredIf: "https.enabled=true"},
+	{Key: "https.keyFile", Type: TypeString, RequiredIf: "https.enabled=true"},
+	{Key: "https.port", Type: TypeInt, Range: &Range{1, 65535}},
+	{Key: "storage.driver", Type: TypeString, Enum: []string{"file", "postgres"}},
+	{Key: "storage.dir", Type: TypeString},
+	{Key: "storage.dsn", Type: TypeString, RequiredIf: "storage.driver=postgres"},
+	{Key: "cluster.enabled", Type: TypeBool},
+	{Key: "cluster.name", Type: TypeString},
+	{Key: "cluster.id", Type: TypeString},
+	{Key: "cluster.advertise", Type: TypeString},
+	{Key: "cluster.leaseTTL", Type: TypeDuration},
+	{Key: "agents.token", Type: TypeString},
+	{Key: "api.auth.enabled", Type: TypeBool},
+	{Key: "api.auth.secret", Type: TypeString},
+	{Key: "api.auth.tokenTTL", Type: TypeDuration},
+	{Key: "api.auth.tokens.*", Type: TypeString},
+	{Key: "api.auth.users.*", Type: TypeString},
+	{Key: "api.auth.exempt", Type: TypeList},
+	{Key: "api.auth.roles.*", Type: TypeString, Enum: []string{"read", "write", "admin"}},
+	{Key: "api.auth.defaultRole", Type: TypeString, Enum: []string{"read", "write", "admin"}},
+	{Key: "api.cors.origins", Type: TypeList},
+	{Key: "api.cors.methods", Type: TypeList},
+	{Key: "api.cors.headers", Type: TypeList},
+	{Key: "api.cors.credentials", Type: TypeBool},
+	{Key: "api.cors.maxAge", Type: TypeDuration},
+	{Key: "api.maxBodySize", Type: TypeInt, Range: &Range{0, math.MaxInt32}},
+	{Key: "api.maxRestoreSize", Type: TypeInt, Range: &Range{0, math.MaxInt64}},
+	{Key: "api.rateLimit.rps", Type: TypeFloat, Range: &Range{0, math.MaxFloat64}},
+	{Key: "api.rateLimit.burst", Type: TypeInt, Range: &Range{0, math.MaxInt32}},
+	{Key: "metrics.enabled", Type: TypeBool},
+	{Key: "log.level", Type: TypeString, Enum: logLevels},
+	{Key: "log.format", Type: TypeString, Enum: []string{"text", "json"}},
+	{Key: "log.flows.*", Type: TypeString, Enum: logLevels},
+	{Key: "audit.enabled", Type: TypeBool},
+	{Key: "audit.syslog.enabled", Type: TypeBool},
+	{Key: "audit.syslog.network", Type: TypeString, Enum: []string{"udp", "tcp", "unix", "unixgram"}},
+	{Key: "audit.syslog.address", Type: TypeString},
+	{Key: "audit.syslog.tag", Type: TypeString},
+	{Key: "credentialSecret", Type: TypeString},
+	{Key: "plugins", Type: TypeList},
+	{Key: "editor.admins", Type: TypeList},
+	{Key: "editor.lockTTL", Type: TypeDuration},
+	{Key: "workspaces.*.users", Type: TypeList},
+	{Key: "workspaces.*.admins", Type: TypeList},
+	{Key: "websocket.pingInterval", Type: TypeDuration},
+	{Key: "websocket.pongTimeout", Type: TypeDuration},
+	{Key: "flows.allowLoops", Type: TypeBool},
+	{Key: "flows.allowUnknownTypes", Type: TypeBool},
+	{Key: "flows.loopTTL", Type: TypeInt, Range: &Range{0, math.MaxInt32}},
+	{Key: "flows.panicThreshold", Type: TypeInt, Range: &Range{0, math.MaxInt32}},
+	{Key: "flows.versions", Type: TypeInt, Range: &Range{0, math.MaxInt32}},
+	{Key: "flows.readyTimeout", Type: TypeDuration},
+	{Key: "flows.limits.maxMessagesPerSecond", Type: TypeFloat, Range: &Range{0, math.MaxFloat64}},
+	{Key: "flows.limits.maxQueued", Type: TypeInt, Range: &Range{0, math.MaxInt32}},
+	{Key: "flows.limits.maxConcurrent", Type: TypeInt, Range: &Range{0, math.MaxInt32}},
+	{Key: "messages.copyOnWrite", Type: TypeBool},
+	{Key: "messages.maxHops", Type: TypeInt, Range: &Range{0, math.MaxInt32}},
+	{Key: "messages.trace", Type: TypeBool},
+	{Key: "correlations.maxPending", Type: TypeInt, Range: &Range{0, math.MaxInt32}},
+	{Key: "context.persistentPrefixes", Type: TypeList},
+	{Key: "network.proxy.*", Type: TypeString},
+	{Key: "network.tls.caFile", Type: TypeString},
+	{Key: "network.tls.insecureSkipVerify", Type: TypeBool},
+	{Key: "network.profiles.*.proxy.*", Type: TypeString},
+	{Key: "network.profiles.*.tls.caFile", Type: TypeString},
+	{Key: "network.profiles.*.tls.insecureSkipVerify", Type: TypeBool},
+}
+
+// logLevels are the names of log levels
+var logLevels = []string{"debug", "info", "warn", "warning", "error"}
+
+// Problem is a setting that breaks a rule
+type Problem struct {
+	Key     string
+	Value   interface{}
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Value == nil {
+		return fmt.Sprintf("%s: %s", p.Key, p.Message)
+	}
+	return fmt.Sprintf("%s = %v: %s", p.Key, p.Value, p.Message)
+}
+
+// ValidationError lists all the problems of a configuration
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		lines[i] = "  " + problem.String()
+	}
+	return "invalid configuration:\n" + strings.Join(lines, "\n")
+}
+
+// Validate checks the configuration against Schema. It returns a
+// *ValidationError with every problem found, and warnings for top-level
+// keys that no rule knows, which are likely typos.
+func (c *Config) Validate() ([]string, error) {
+	return c.ValidateRules(Schema)
+}
+
+// ValidateRules checks the configuration against rules, like Validate
+func (c *Config) ValidateRules(rules []Rule) ([]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return validate(c.values, rules)
+}
+
+// validate checks flat values against rules
+func validate(values map[string]interface{}, rules []Rule) ([]string, error) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var problems []Problem
+	for _, rule := range rules {
+		matched := false
+		for _, key := range keys {
+			if !matchKey(rule.Key, key) {
+				continue
+			}
+			matched = true
+			if message := rule.check(values[key]); message != "" {
+				problems = append(problems, Problem{Key: key, Value: values[key], Message: message})
+			}
+		}
+		if matched || strings.Contains(rule.Key, "*") {
+			continue
+		}
+		if rule.Required {
+			problems = append(problems, Problem{Key: rule.Key, Message: "is required"})
+		} else if other, want, ok := strings.Cut(rule.RequiredIf, "="); ok && fmt.Sprint(values[other]) == want {
+			problems = append(problems, Problem{Key: rule.Key, Message: fmt.Sprintf("is required when %s is %s", other, want)})
+		}
+	}
+
+	known := make(map[string]bool)
+	for _, rule := range rules {
+		top, _, _ := strings.Cut(rule.Key, ".")
+		known[top] = true
+	}
+	var warnings []string
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		top, _, _ := strings.Cut(key, ".")
+		if known[top] || seen[top] {
+			continue
+		}
+		seen[top] = true
+		warning := fmt.Sprintf("unknown setting %s", top)
+		if suggestion := closestKey(top, known); suggestion != "" {
+			warning += fmt.Sprintf(", did you mean %s?", suggestion)
+		}
+		warnings = append(warnings, warning)
+	}
+
+	if len(problems) > 0 {
+		return warnings, &ValidationError{Problems: problems}
+	}
+	return warnings, nil
+}
+
+// matchKey reports whether a key matches a rule's key, where "*" matches
+// any one segment
+func matchKey(pattern, key string) bool {
+	patternParts := strings.Split(pattern, ".")
+	keyParts := strings.Split(key, ".")
+	if len(patternParts) != len(keyParts) {
+		return false
+	}
+	for i, part := range patternParts {
+		if part != "*" && part != keyParts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// check returns what is wrong with a value of the rule's setting, if
+// anything
+func (r Rule) check(value interface{}) string {
+	var number float64
+	switch r.Type {
+	case TypeString:
+		switch value.(type) {
+		case string, float64, int, bool:
+		default:
+			return "must be a string"
+		}
+	case TypeInt:
+		n, ok := number64(value)
+		if !ok || n != math.Trunc(n) {
+			return "must be an integer"
+		}
+		number = n
+	case TypeFloat:
+		n, ok := number64(value)
+		if !ok {
+			return "must be a number"
+		}
+		number = n
+	case TypeBool:
+		switch v := value.(type) {
+		case bool:
+		case string:
+			if _, err := strconv.ParseBool(v); err != nil {
+				return "must be true or false"
+			}
+		default:
+			return "must be true or false"
+		}
+	case TypeDuration:
+		var d time.Duration
+		switch v := value.(type) {
+		case string:
+			parsed, err := time.ParseDuration(strings.TrimSpace(v))
+			if err != nil {
+				return `must be a duration such as "15s" or a number of seconds`
+			}
+			d = parsed
+		case float64:
+			d = time.Duration(v * float64(time.Second))
+		case int:
+			d = time.Duration(v) * time.Second
+		default:
+			return `must be a duration such as "15s" or a number of seconds`
+		}
+		if d < 0 {
+			return "must not be negative"
+		}
+	case TypeList:
+		switch value.(type) {
+		case string, []interface{}, []string:
+		default:
+			return "must be a list or a comma-separated string"
+		}
+	}
+
+	if r.Range != nil && (number < r.Range.Min || number > r.Range.Max) {
+		switch {
+		case r.Range.Max >= math.MaxInt32:
+			return fmt.Sprintf("must be at least %g", r.Range.Min)
+		default:
+			return fmt.Sprintf("must be between %g and %g", r.Range.Min, r.Range.Max)
+		}
+	}
+	if len(r.Enum) > 0 {
+		text := fmt.Sprint(value)
+		for _, allowed := range r.Enum {
+			if text == allowed {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of %s", strings.Join(r.Enum, ", "))
+	}
+	return ""
+}
+
+// number64 converts a numeric value, or a string holding one
+func number64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// closestKey returns the known key within two edits of key, if any
+func closestKey(key string, known map[string]bool) string {
+	best, bestDistance := "", 3
+	for candidate := range known {
+		if d := editDistance(strings.ToLower(key), strings.ToLower(candidate)); d < bestDistance || d == bestDistance && candidate < best {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance of two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
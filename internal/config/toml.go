@@ -0,0 +1,645 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The TOML support covers what configuration files use: tables, arrays of
+// tables, dotted keys, strings, numbers, booleans, arrays and inline
+// tables. Dates and times are kept as strings.
+
+// tomlDateTime matches the dates, times and date-times of TOML
+var tomlDateTime = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}([Tt ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?([Zz]|[+-]\d{2}:\d{2})?)?|\d{2}:\d{2}:\d{2}(\.\d+)?)`)
+
+// tomlParser parses a TOML document
+type tomlParser struct {
+	data []byte
+	pos  int
+	line int
+}
+
+// parseTOML parses a TOML document into nested maps
+func parseTOML(data []byte) (map[string]interface{}, error) {
+	p := &tomlParser{data: data, line: 1}
+	root := make(map[string]interface{})
+	if err := p.parse(root); err != nil {
+		return nil, fmt.Errorf("line %d: %w", p.line, err)
+	}
+	return root, nil
+}
+
+func (p *tomlParser) parse(root map[string]interface{}) error {
+	current := root
+	for {
+		p.skipSpace(true)
+		if p.pos >= len(p.data) {
+			return nil
+		}
+
+		if p.data[p.pos] == '[' {
+			array := p.pos+1 < len(p.data) && p.data[p.pos+1] == '['
+			if array {
+				p.pos += 2
+			} else {
+				p.pos++
+			}
+			keys, err := p.parseKey()
+			if err != nil {
+				return err
+			}
+			p.skipSpace(false)
+			if array && !p.consume("]]") || !array && !p.consume("]") {
+				return fmt.Errorf("unterminated table header")
+			}
+			if array {
+				current, err = appendTable(root, keys)
+			} else {
+				current, err = table(root, keys)
+			}
+			if err != nil {
+				return err
+			}
+		} else {
+			keys, err := p.parseKey()
+			if err != nil {
+				return err
+			}
+			p.skipSpace(false)
+			if !p.consume("=") {
+				return fmt.Errorf("expected '=' after key %s", strings.Join(keys, "."))
+			}
+			p.skipSpace(false)
+			value, err := p.parseValue()
+			if err != nil {
+				return err
+			}
+			if err := setKey(current, keys, value); err != nil {
+				return err
+			}
+		}
+
+		p.skipSpace(false)
+		if p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+			return fmt.Errorf("unexpected %q after value", p.data[p.pos])
+		}
+	}
+}
+
+// table returns the table at keys below root, creating it if needed
+func table(root map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	current := root
+	for _, key := range keys {
+		switch child := current[key].(type) {
+		case nil:
+			next := make(map[string]interface{})
+			current[key] = next
+			current = next
+		case map[string]interface{}:
+			current = child
+		case []interface{}:
+			// A table below an array of tables belongs to its last table
+			last, ok := child[len(child)-1].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("key %s is not a table", key)
+			}
+			current = last
+		default:
+			return nil, fmt.Errorf("key %s is already defined", key)
+		}
+	}
+	return current, nil
+}
+
+// appendTable appends a table to the array of tables at keys
+func appendTable(root map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	parent, err := table(root, keys[:len(keys)-1])
+	if err != nil {
+		return nil, err
+	}
+	key := keys[len(keys)-1]
+	next := make(map[string]interface{})
+	switch child := parent[key].(type) {
+	case nil:
+		parent[key] = []interface{}{next}
+	case []interface{}:
+		parent[key] = append(child, next)
+	default:
+		return nil, fmt.Errorf("key %s is not an array of tables", key)
+	}
+	return next, nil
+}
+
+// setKey sets a dotted key of a table
+func setKey(current map[string]interface{}, keys []string, value interface{}) error {
+	parent, err := table(current, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	key := keys[len(keys)-1]
+	if _, exists := parent[key]; exists {
+		return fmt.Errorf("key %s is already defined", strings.Join(keys, "."))
+	}
+	parent[key] = value
+	return nil
+}
+
+// skipSpace skips whitespace and comments, and newlines if asked to
+func (p *tomlParser) skipSpace(newlines bool) {
+	for p.pos < len(p.data) {
+		switch c := p.data[p.pos]; {
+		case c == ' ' || c == '\t':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' {
+				p.pos++
+			}
+		case newlines && (c == '\n' || c == '\r'):
+			if c == '\n' {
+				p.line++
+			}
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+// consume skips s if the input continues with it
+func (p *tomlParser) consume(s string) bool {
+	if bytes.HasPrefix(p.data[p.pos:], []byte(s)) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+// parseKey parses a bare, quoted or dotted key
+func (p *tomlParser) parseKey() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpace(false)
+		if p.pos >= len(p.data) {
+			return nil, fmt.Errorf("expected a key")
+		}
+		var key string
+		switch p.data[p.pos] {
+		case '"', '\'':
+			s, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		default:
+			start := p.pos
+			for p.pos < len(p.data) && isBareKeyChar(p.data[p.pos]) {
+				p.pos++
+			}
+			if p.pos == start {
+				return nil, fmt.Errorf("expected a key, found %q", p.data[p.pos])
+			}
+			key = string(p.data[start:p.pos])
+		}
+		keys = append(keys, key)
+		p.skipSpace(false)
+		if !p.consume(".") {
+			return keys, nil
+		}
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// parseValue parses the value of a key or an array element
+func (p *tomlParser) parseValue() (interface{}, error) {
+	if p.pos >= len(p.data) {
+		return nil, fmt.Errorf("expected a value")
+	}
+	switch c := p.data[p.pos]; {
+	case c == '"' || c == '\'':
+		return p.parseString()
+	case c == '[':
+		return p.parseArray()
+	case c == '{':
+		return p.parseInlineTable()
+	case p.consume("true"):
+		return true, nil
+	case p.consume("false"):
+		return false, nil
+	}
+	if match := tomlDateTime.Find(p.data[p.pos:]); match != nil {
+		p.pos += len(match)
+		return string(match), nil
+	}
+	return p.parseNumber()
+}
+
+// parseNumber parses an integer or a float
+func (p *tomlParser) parseNumber() (interface{}, error) {
+	start := p.pos
+	for p.pos < len(p.data) && strings.IndexByte("+-0123456789abcdefABCDEFxXoO._ni", p.data[p.pos]) >= 0 {
+		p.pos++
+	}
+	text := string(p.data[start:p.pos])
+	if text == "" {
+		return nil, fmt.Errorf("unexpected %q", p.data[p.pos])
+	}
+	clean := strings.ReplaceAll(text, "_", "")
+	switch strings.TrimLeft(clean, "+-") {
+	case "inf":
+		if strings.HasPrefix(clean, "-") {
+			return math.Inf(-1), nil
+		}
+		return math.Inf(1), nil
+	case "nan":
+		return math.NaN(), nil
+	}
+	var value interface{}
+	var err error
+	switch {
+	case strings.HasPrefix(clean, "0x") || strings.HasPrefix(clean, "0o") || strings.HasPrefix(clean, "0b"):
+		value, err = strconv.ParseInt(clean, 0, 64)
+	case strings.ContainsAny(clean, ".eE"):
+		value, err = strconv.ParseFloat(clean, 64)
+	default:
+		value, err = strconv.ParseInt(clean, 10, 64)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %s", text)
+	}
+	return value, nil
+}
+
+// parseString parses a basic or literal string, either of which may span
+// lines when tripled
+func (p *tomlParser) parseString() (string, error) {
+	quote := p.data[p.pos]
+	delim := string(quote)
+	if p.consume(strings.Repeat(delim, 3)) {
+		delim = strings.Repeat(delim, 3)
+		// A newline right after the opening quotes is trimmed
+		if !p.consume("\r\n") {
+			p.consume("\n")
+		}
+	} else {
+		p.pos++
+	}
+
+	var sb strings.Builder
+	for {
+		if p.pos >= len(p.data) {
+			return "", fmt.Errorf("unterminated string")
+		}
+		if p.consume(delim) {
+			return sb.String(), nil
+		}
+		c := p.data[p.pos]
+		switch {
+		case c == '\n' && len(delim) == 1:
+			return "", fmt.Errorf("unterminated string")
+		case c == '\\' && quote == '"':
+			if err := p.parseEscape(&sb, len(delim) == 3); err != nil {
+				return "", err
+			}
+		default:
+			if c == '\n' {
+				p.line++
+			}
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// parseEscape parses an escape sequence of a basic string
+func (p *tomlParser) parseEscape(sb *strings.Builder, multiline bool) error {
+	p.pos++
+	if p.pos >= len(p.data) {
+		return fmt.Errorf("unterminated string")
+	}
+	c := p.data[p.pos]
+	p.pos++
+	switch c {
+	case 'b':
+		sb.WriteByte('\b')
+	case 't':
+		sb.WriteByte('\t')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'r':
+		sb.WriteByte('\r')
+	case '"':
+		sb.WriteByte('"')
+	case '\\':
+		sb.WriteByte('\\')
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		if p.pos+size > len(p.data) {
+			return fmt.Errorf("invalid unicode escape")
+		}
+		code, err := strconv.ParseUint(string(p.data[p.pos:p.pos+size]), 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return fmt.Errorf("invalid unicode escape")
+		}
+		sb.WriteRune(rune(code))
+		p.pos += size
+	default:
+		// A backslash at the end of a line of a multi-line string joins
+		// it with the next non-blank line
+		if !multiline || c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			return fmt.Errorf("invalid escape \\%c", c)
+		}
+		p.pos--
+		for p.pos < len(p.data) && strings.IndexByte(" \t\r\n", p.data[p.pos]) >= 0 {
+			if p.data[p.pos] == '\n' {
+				p.line++
+			}
+			p.pos++
+		}
+	}
+	return nil
+}
+
+// parseArray parses an array, which may span lines
+func (p *tomlParser) parseArray() ([]interface{}, error) {
+	p.pos++
+	array := []interface{}{}
+	for {
+		p.skipSpace(true)
+		if p.consume("]") {
+			return array, nil
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, value)
+		p.skipSpace(true)
+		if p.consume("]") {
+			return array, nil
+		}
+		if !p.consume(",") {
+			return nil, fmt.Errorf("expected ',' or ']' in array")
+		}
+	}
+}
+
+// parseInlineTable parses a table on one line
+func (p *tomlParser) parseInlineTable() (map[string]interface{}, error) {
+	p.pos++
+	result := make(map[string]interface{})
+	p.skipSpace(false)
+	if p.consume("}") {
+		return result, nil
+	}
+	for {
+		keys, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace(false)
+		if !p.consume("=") {
+			return nil, fmt.Errorf("expected '=' after key %s", strings.Join(keys, "."))
+		}
+		p.skipSpace(false)
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if err := setKey(result, keys, value); err != nil {
+			return nil, err
+		}
+		p.skipSpace(false)
+		if p.consume("}") {
+			return result, nil
+		}
+		if !p.consume(",") {
+			return nil, fmt.Errorf("expected ',' or '}' in inline table")
+		}
+		p.skipSpace(false)
+	}
+}
+
+// encodeTOML writes nested maps as a TOML document. Values come from
+// decoded JSON, so numbers are float64 and those without a fraction are
+// written as integers. Null values have no TOML form and are left out.
+func encodeTOML(values map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeTOMLTable(&buf, values, nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeTOMLTable writes the keys of a table: its values first, then its
+// tables and arrays of tables under their headers
+func encodeTOMLTable(buf *bytes.Buffer, values map[string]interface{}, path []string) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var tables, arrays []string
+	for _, key := range keys {
+		switch value := values[key].(type) {
+		case nil:
+		case map[string]interface{}:
+			tables = append(tables, key)
+		case []interface{}:
+			if isTableArray(value) {
+				arrays = append(arrays, key)
+				continue
+			}
+			fmt.Fprintf(buf, "%s = ", tomlKey(key))
+			if err := encodeTOMLValue(buf, value); err != nil {
+				return err
+			}
+			buf.WriteByte('\n')
+		default:
+			fmt.Fprintf(buf, "%s = ", tomlKey(key))
+			if err := encodeTOMLValue(buf, value); err != nil {
+				return err
+			}
+			buf.WriteByte('\n')
+		}
+	}
+
+	for _, key := range tables {
+		child := values[key].(map[string]interface{})
+		// Tables holding only tables need no header of their own
+		if len(child) == 0 || hasTOMLValues(child) {
+			if buf.Len() > 0 {
+				buf.WriteByte('\n')
+			}
+			fmt.Fprintf(buf, "[%s]\n", tomlPath(append(path[:len(path):len(path)], key)))
+		}
+		if err := encodeTOMLTable(buf, child, append(path[:len(path):len(path)], key)); err != nil {
+			return err
+		}
+	}
+	for _, key := range arrays {
+		header := tomlPath(append(path[:len(path):len(path)], key))
+		for _, element := range values[key].([]interface{}) {
+			if buf.Len() > 0 {
+				buf.WriteByte('\n')
+			}
+			fmt.Fprintf(buf, "[[%s]]\n", header)
+			if err := encodeTOMLTable(buf, element.(map[string]interface{}), append(path[:len(path):len(path)], key)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hasTOMLValues reports whether a table has keys that are written as
+// key = value
+func hasTOMLValues(values map[string]interface{}) bool {
+	for _, value := range values {
+		switch v := value.(type) {
+		case nil, map[string]interface{}:
+		case []interface{}:
+			if !isTableArray(v) {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// isTableArray reports whether an array is a non-empty array of tables
+func isTableArray(array []interface{}) bool {
+	for _, element := range array {
+		if _, ok := element.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return len(array) > 0
+}
+
+// encodeTOMLValue writes a value inline
+func encodeTOMLValue(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		buf.WriteString(tomlString(v))
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case float64:
+		switch {
+		case math.IsInf(v, 1):
+			buf.WriteString("inf")
+		case math.IsInf(v, -1):
+			buf.WriteString("-inf")
+		case math.IsNaN(v):
+			buf.WriteString("nan")
+		case v == math.Trunc(v) && math.Abs(v) < 1<<53:
+			buf.WriteString(strconv.FormatInt(int64(v), 10))
+		default:
+			buf.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+		}
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, element := range v {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			if err := encodeTOMLValue(buf, element); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			if v[key] != nil {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			fmt.Fprintf(buf, " %s = ", tomlKey(key))
+			if err := encodeTOMLValue(buf, v[key]); err != nil {
+				return err
+			}
+		}
+		if len(keys) > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteByte('}')
+	case nil:
+		return fmt.Errorf("null values in arrays cannot be written as TOML")
+	default:
+		return fmt.Errorf("cannot write %T as TOML", value)
+	}
+	return nil
+}
+
+// tomlPath returns the dotted key of a table header
+func tomlPath(keys []string) string {
+	quoted := make([]string, len(keys))
+	for i, key := range keys {
+		quoted[i] = tomlKey(key)
+	}
+	return strings.Join(quoted, ".")
+}
+
+// tomlKey returns a key bare if it can be, else quoted
+func tomlKey(key string) string {
+	for i := 0; i < len(key); i++ {
+		if !isBareKeyChar(key[i]) {
+			return tomlString(key)
+		}
+	}
+	if key == "" {
+		return `""`
+	}
+	return key
+}
+
+// tomlString quotes a basic string
+func tomlString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&sb, `\u%04X`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
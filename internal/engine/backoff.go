@@ -0,0 +1,64 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+)
+
+// ReconnectConfig holds the reconnect delays of a node's configuration;
+// node configs embed it
+type ReconnectConfig struct {
+	// ReconnectDelay is the first delay before reconnecting; it doubles up
+	// to MaxReconnectDelay while connecting fails
+	ReconnectDelay    string `json:"reconnectDelay" validate:"duration"`
+	MaxReconnectDelay string `json:"maxReconnectDelay" validate:"duration"`
+}
+
+// Backoff parses the reconnect delays
+func (c ReconnectConfig) Backoff() (*Backoff, error) {
+	min, _ := time.ParseDuration(c.ReconnectDelay)
+	max, _ := time.ParseDuration(c.MaxReconnectDelay)
+	if min <= 0 || max < min {
+		return nil, fmt.Errorf("reconnectDelay must be positive and at most maxReconnectDelay")
+	}
+	return NewBackoff(min, max), nil
+}
+
+// Backoff is the delay before retrying something that failed, such as a
+// connection, which doubles from a minimum up to a maximum while it keeps
+// failing. An attempt that lasted longer than the maximum, such as a
+// connection that was up for a while, starts over with the minimum. It is
+// not safe for concurrent use.
+type Backoff struct {
+	min, max time.Duration
+	delay    time.Duration
+	started  time.Time
+}
+
+// NewBackoff creates a backoff from min up to max
+func NewBackoff(min, max time.Duration) *Backoff {
+	return &Backoff{min: min, max: max, delay: min}
+}
+
+// Start marks the start of an attempt
+func (b *Backoff) Start() {
+	b.started = time.Now()
+}
+
+// Next returns the delay before the next attempt and doubles the one after
+func (b *Backoff) Next() time.Duration {
+	if !b.started.IsZero() && time.Since(b.started) > b.max {
+		b.delay = b.min
+	}
+	delay := b.delay
+	if b.delay *= 2; b.delay > b.max {
+		b.delay = b.max
+	}
+	return delay
+}
+
+// Reset starts over with the first delay
+func (b *Backoff) Reset() {
+	b.delay = b.min
+	b.started = time.Time{}
+}
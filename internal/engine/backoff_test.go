@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDoublesUpToMax(t *testing.T) {
+	b := NewBackoff(time.Second, 5*time.Second)
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		b.Start()
+		if got := b.Next(); got != w {
+			t.Errorf("delay %d = %v, want %v", i, got, w)
+		}
+	}
+	b.Reset()
+	if got := b.Next(); got != time.Second {
+		t.Errorf("delay after Reset = %v, want %v", got, time.Second)
+	}
+}
+
+func TestBackoffLongAttemptStartsOver(t *testing.T) {
+	b := NewBackoff(time.Second, 5*time.Second)
+	b.Next()
+	b.Next()
+	// An attempt that lasted longer than the maximum delay
+	b.started = time.Now().Add(-time.Minute)
+	if got := b.Next(); got != time.Second {
+		t.Errorf("delay after a long attempt = %v, want %v", got, time.Second)
+	}
+}
+
+func TestReconnectConfigBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ReconnectConfig
+		wantErr bool
+	}{
+		{"valid", ReconnectConfig{ReconnectDelay: "1s", MaxReconnectDelay: "30s"}, false},
+		{"equal", ReconnectConfig{ReconnectDelay: "1s", MaxReconnectDelay: "1s"}, false},
+		{"zero delay", ReconnectConfig{ReconnectDelay: "0s", MaxReconnectDelay: "30s"}, true},
+		{"max below delay", ReconnectConfig{ReconnectDelay: "1m", MaxReconnectDelay: "30s"}, true},
+		{"missing", ReconnectConfig{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.config.Backoff()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Backoff() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,543 @@
+package engine
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// BackupVersion is the version of the backup archive format
+const BackupVersion = 1
+
+// maxBackupEntry bounds the size of a file in a backup archive
+const maxBackupEntry = 64 << 20
+
+// Backup is the state of a runtime: its stored flows, their credentials,
+// the persistent context and the settings. Credentials are kept as saved,
+// so they are encrypted if a credentialSecret is set, and a backup restores
+// only where the same secret is.
+type Backup struct {
+	Created    time.Time
+	Workspaces []string
+	// Flows are the definitions without credentials, by flow key
+	Flows map[string]json.RawMessage
+	// Credentials are the saved credentials, by flow key
+	Credentials map[string]json.RawMessage
+	// Context holds the persistent context values by scope and key
+	Context map[string]map[string]json.RawMessage
+	// Settings are the flattened settings, if any
+	Settings map[string]interface{}
+}
+
+// backupManifest describes a backup archive
+type backupManifest struct {
+	Version    int       `json:"version"`
+	Created    time.Time `json:"created"`
+	Workspaces []string  `json:"workspaces"`
+	Flows      []string  `json:"flows"`
+}
+
+// RestoreReport tells what restoring a backup did
+type RestoreReport struct {
+	Restored []string `json:"restored"`
+	Deleted  []string `json:"deleted"`
+	// Failed are the flows that were restored in storage but failed to
+	// start, by flow key
+	Failed map[string]string `json:"failed"`
+}
+
+// Backup collects the state of the engine from its storage
+func (e *Engine) Backup() (*Backup, error) {
+	b := &Backup{
+		Created:     e.clock.Now().UTC(),
+		Workspaces:  []string{},
+		Flows:       make(map[string]json.RawMessage),
+		Credentials: make(map[string]json.RawMessage),
+		Context:     make(map[string]map[string]json.RawMessage),
+	}
+
+	workspaces, err := e.workspaces.ListWorkspaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspaces: %w", err)
+	}
+	for _, workspace := range workspaces {
+		b.Workspaces = append(b.Workspaces, workspace.Name)
+	}
+	sort.Strings(b.Workspaces)
+
+	ids, err := e.storage.ListFlows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list flows: %w", err)
+	}
+	var scopes []string
+	for _, id := range ids {
+		flowDef, err := e.storage.LoadFlow(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load flow %s: %w", id, err)
+		}
+		b.Flows[id] = flowDef
+		creds, exists, err := e.credentials.store.LoadCredentials(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load credentials of flow %s: %w", id, err)
+		}
+		if exists {
+			b.Credentials[id] = creds
+		}
+		scopes = append(scopes, flowScope(id))
+	}
+	for _, workspace := range b.globalWorkspaces() {
+		scopes = append(scopes, globalScope(workspace))
+	}
+
+	if store := e.contexts.contextStore(); store != nil {
+		for _, scope := range scopes {
+			values, err := store.LoadContext(scope)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load context %s: %w", scope, err)
+			}
+			if len(values) == 0 {
+				continue
+			}
+			b.Context[scope] = make(map[string]json.RawMessage, len(values))
+			for key, value := range values {
+				b.Context[scope][key] = value
+			}
+		}
+	}
+	return b, nil
+}
+
+// globalWorkspaces returns the workspaces whose global context is part of
+// the backup: the default one, those listed and those of its flows
+func (b *Backup) globalWorkspaces() []string {
+	workspaces := map[string]json.RawMessage{DefaultWorkspace: nil}
+	for _, workspace := range b.Workspaces {
+		workspaces[workspace] = nil
+	}
+	for id := range b.Flows {
+		workspace, _ := SplitFlowKey(id)
+		workspaces[workspace] = nil
+	}
+	return sortedKeys(workspaces)
+}
+
+// WriteTo writes the backup as a gzipped tar archive: manifest.json,
+// settings.json, and a file per flow, credentials and context value under
+// flows/, credentials/ and context/
+func (b *Backup) WriteTo(w io.Writer) (int64, error) {
+	counter := &countingWriter{w: w}
+	gz := gzip.NewWriter(counter)
+	tw := tar.NewWriter(gz)
+
+	write := func(name string, data []byte) error {
+		header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: b.Created}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	writeJSON := func(name string, value interface{}) error {
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return err
+		}
+		return write(name, data)
+	}
+
+	err := writeJSON("manifest.json", backupManifest{
+		Version:    BackupVersion,
+		Created:    b.Created,
+		Workspaces: b.Workspaces,
+		Flows:      sortedKeys(b.Flows),
+	})
+	if err == nil && b.Settings != nil {
+		err = writeJSON("settings.json", b.Settings)
+	}
+	for _, id := range sortedKeys(b.Flows) {
+		if err == nil {
+			err = write("flows/"+url.PathEscape(id)+".json", b.Flows[id])
+		}
+	}
+	for _, id := range sortedKeys(b.Credentials) {
+		if err == nil {
+			err = write("credentials/"+url.PathEscape(id)+".json", b.Credentials[id])
+		}
+	}
+	for scope, values := range b.Context {
+		for _, key := range sortedKeys(values) {
+			if err == nil {
+				err = write("context/"+url.PathEscape(scope)+"/"+url.PathEscape(key)+".json", values[key])
+			}
+		}
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	return counter.n, err
+}
+
+// ReadBackup reads a backup archive written by Backup.WriteTo and checks
+// that it is complete and that every file in it is valid JSON, so that a
+// corrupt archive is rejected before anything is restored
+func ReadBackup(r io.Reader) (*Backup, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup archive: %w", err)
+	}
+	tr := tar.NewReader(gz)
+
+	var manifest *backupManifest
+	flows := make(map[string]json.RawMessage)
+	credentials := make(map[string]json.RawMessage)
+	context := make(map[string]map[string]json.RawMessage)
+	var settings map[string]interface{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid backup archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if header.Size > maxBackupEntry {
+			return nil, fmt.Errorf("invalid backup archive: %s is too large", header.Name)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid backup archive: %w", err)
+		}
+		if !json.Valid(data) {
+			return nil, fmt.Errorf("invalid backup archive: %s is not valid JSON", header.Name)
+		}
+
+		name := path.Clean(header.Name)
+		dir, file := path.Split(name)
+		id, err := url.PathUnescape(strings.TrimSuffix(file, ".json"))
+		if err != nil || !strings.HasSuffix(file, ".json") {
+			return nil, fmt.Errorf("invalid backup archive: unexpected file %s", header.Name)
+		}
+		switch {
+		case name == "manifest.json":
+			manifest = &backupManifest{}
+			if err := json.Unmarshal(data, manifest); err != nil {
+				return nil, fmt.Errorf("invalid backup manifest: %w", err)
+			}
+		case name == "settings.json":
+			if err := DecodeJSON(data, &settings); err != nil {
+				return nil, fmt.Errorf("invalid backup settings: %w", err)
+			}
+		case dir == "flows/":
+			var def FlowDefinition
+			if err := json.Unmarshal(data, &def); err != nil {
+				return nil, fmt.Errorf("invalid flow %s in backup: %w", id, err)
+			}
+			flows[id] = data
+		case dir == "credentials/":
+			credentials[id] = data
+		case strings.HasPrefix(dir, "context/") && strings.Count(dir, "/") == 2:
+			scope, err := url.PathUnescape(strings.TrimSuffix(strings.TrimPrefix(dir, "context/"), "/"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid backup archive: unexpected file %s", header.Name)
+			}
+			if context[scope] == nil {
+				context[scope] = make(map[string]json.RawMessage)
+			}
+			context[scope][id] = data
+		default:
+			return nil, fmt.Errorf("invalid backup archive: unexpected file %s", header.Name)
+		}
+	}
+
+	switch {
+	case manifest == nil:
+		return nil, errors.New("invalid backup archive: manifest.json is missing")
+	case manifest.Version != BackupVersion:
+		return nil, fmt.Errorf("unsupported backup version %d", manifest.Version)
+	case len(manifest.Flows) != len(flows):
+		return nil, fmt.Errorf("invalid backup archive: %d flows listed, %d found", len(manifest.Flows), len(flows))
+	}
+	for _, id := range manifest.Flows {
+		if _, exists := flows[id]; !exists {
+			return nil, fmt.Errorf("invalid backup archive: flow %s is missing", id)
+		}
+	}
+	for id := range credentials {
+		if _, exists := flows[id]; !exists {
+			return nil, fmt.Errorf("invalid backup archive: credentials of unknown flow %s", id)
+		}
+	}
+	return &Backup{
+		Created:     manifest.Created,
+		Workspaces:  manifest.Workspaces,
+		Flows:       flows,
+		Credentials: credentials,
+		Context:     context,
+		Settings:    settings,
+	}, nil
+}
+
+// Restore replaces the stored state of the engine by a backup: flows that
+// are not in the backup are deleted, and those that are are saved with
+// their credentials and context and deployed again. The backup is checked
+// before anything changes: its credentials must decrypt with the current
+// credentialSecret and every flow must build. If saving fails part way,
+// the previous state is put back. Settings are left to the caller.
+func (e *Engine) Restore(b *Backup) (*RestoreReport, error) {
+	for id, data := range b.Credentials {
+		if _, err := e.credentials.decode(id, data); err != nil {
+			return nil, fmt.Errorf("credentials of flow %s: %w", id, err)
+		}
+	}
+	for _, workspace := range b.Workspaces {
+		if err := ValidateWorkspaceName(workspace); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.checkBackup(b); err != nil {
+		return nil, err
+	}
+
+	previous, err := e.Backup()
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up the current state: %w", err)
+	}
+	report, err := e.applyBackup(b)
+	if err == nil {
+		return report, nil
+	}
+
+	// Put the previous state back rather than leave part of the backup
+	rollback, rollbackErr := e.applyBackup(previous)
+	existed := make(map[string]bool, len(previous.Workspaces))
+	for _, workspace := range previous.Workspaces {
+		existed[workspace] = true
+	}
+	for _, workspace := range b.Workspaces {
+		if !existed[workspace] {
+			if err := e.workspaces.DeleteWorkspace(workspace); err != nil && !errors.Is(err, storage.ErrWorkspaceNotFound) {
+				rollbackErr = errors.Join(rollbackErr, fmt.Errorf("failed to delete workspace %s: %w", workspace, err))
+			}
+			e.contexts.deleteWorkspace(workspace)
+		}
+	}
+	if rollbackErr != nil {
+		return report, errors.Join(err, fmt.Errorf("failed to put the previous state back: %w", rollbackErr))
+	}
+	return &RestoreReport{Restored: []string{}, Deleted: []string{}, Failed: rollback.Failed}, fmt.Errorf("%w; the previous state was put back", err)
+}
+
+// checkBackup builds every flow of a backup with its credentials, without
+// initializing or starting anything, so that a backup with flows that
+// would fail to deploy, such as nodes of unknown types or invalid configs,
+// is rejected before anything changes. Flows may use the subflows of the
+// other flows of the backup, which are not registered yet, so each is
+// checked with all of them.
+func (e *Engine) checkBackup(b *Backup) error {
+	ids := sortedKeys(b.Flows)
+	defs := make(map[string]map[string]interface{}, len(ids))
+	var subflows []map[string]interface{}
+	defined := make(map[string]bool)
+	for _, id := range ids {
+		var def map[string]interface{}
+		if err := DecodeJSON(b.Flows[id], &def); err != nil {
+			return fmt.Errorf("invalid flow %s in backup: %w", id, err)
+		}
+		defs[id] = def
+		for _, subflow := range subflowsOf(def) {
+			if subflowID, _ := subflow["id"].(string); !defined[subflowID] {
+				defined[subflowID] = true
+				subflows = append(subflows, subflow)
+			}
+		}
+	}
+
+	for _, id := range ids {
+		def := defs[id]
+		own := make(map[string]bool)
+		for _, subflow := range subflowsOf(def) {
+			subflowID, _ := subflow["id"].(string)
+			own[subflowID] = true
+		}
+		list, _ := def["subflows"].([]interface{})
+		for _, subflow := range subflows {
+			if subflowID, _ := subflow["id"].(string); !own[subflowID] {
+				list = append(list, subflow)
+			}
+		}
+		def["subflows"] = list
+		flowDef, err := json.Marshal(def)
+		if err != nil {
+			return fmt.Errorf("invalid flow %s in backup: %w", id, err)
+		}
+
+		if data, exists := b.Credentials[id]; exists {
+			creds, err := e.credentials.decode(id, data)
+			if err == nil {
+				flowDef, err = e.injectCredentials(flowDef, creds)
+			}
+			if err != nil {
+				return fmt.Errorf("credentials of flow %s: %w", id, err)
+			}
+		}
+		if _, err := newFlow(id, flowDef, e, true); err != nil {
+			return fmt.Errorf("flow %s in backup: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// subflowsOf returns the subflow definitions of a decoded flow definition
+func subflowsOf(def map[string]interface{}) []map[string]interface{} {
+	list, _ := def["subflows"].([]interface{})
+	subflows := make([]map[string]interface{}, 0, len(list))
+	for _, subflow := range list {
+		if fields, ok := subflow.(map[string]interface{}); ok {
+			subflows = append(subflows, fields)
+		}
+	}
+	return subflows
+}
+
+// applyBackup saves and deploys the state of a backup that was checked
+func (e *Engine) applyBackup(b *Backup) (*RestoreReport, error) {
+	report := &RestoreReport{Restored: []string{}, Deleted: []string{}, Failed: make(map[string]string)}
+	current, err := e.storage.ListFlows()
+	if err != nil {
+		return report, fmt.Errorf("failed to list flows: %w", err)
+	}
+	for _, id := range current {
+		if _, exists := b.Flows[id]; exists {
+			continue
+		}
+		if err := e.DeleteFlow(id); err != nil {
+			return report, fmt.Errorf("failed to delete flow %s: %w", id, err)
+		}
+		report.Deleted = append(report.Deleted, id)
+	}
+	for _, workspace := range b.Workspaces {
+		if _, err := e.workspaces.CreateWorkspace(workspace); err != nil && !errors.Is(err, storage.ErrWorkspaceExists) {
+			return report, fmt.Errorf("failed to create workspace %s: %w", workspace, err)
+		}
+	}
+
+	ids := sortedKeys(b.Flows)
+	for _, id := range ids {
+		if flow, exists := e.GetFlow(id); exists {
+			flow.Stop()
+		}
+	}
+	for _, workspace := range b.globalWorkspaces() {
+		if err := e.contexts.restore(globalScope(workspace), b.Context[globalScope(workspace)]); err != nil {
+			return report, err
+		}
+	}
+	for _, id := range ids {
+		if err := e.restoreFlow(id, b); err != nil {
+			return report, err
+		}
+	}
+
+	// Flows may use the subflows of flows restored after them, so those
+	// that fail are tried once more
+	var failed []string
+	for _, id := range ids {
+		if err := e.SyncFlow(id); err != nil {
+			failed = append(failed, id)
+		}
+	}
+	for _, id := range failed {
+		if err := e.SyncFlow(id); err != nil {
+			report.Failed[id] = err.Error()
+		}
+	}
+	for _, id := range ids {
+		if _, failed := report.Failed[id]; !failed {
+			report.Restored = append(report.Restored, id)
+		}
+	}
+	return report, nil
+}
+
+// restoreFlow saves a flow of a backup with its credentials and context,
+// and keeps it as its newest version
+func (e *Engine) restoreFlow(id string, b *Backup) error {
+	if creds, exists := b.Credentials[id]; exists {
+		if err := e.credentials.store.SaveCredentials(id, creds); err != nil {
+			return fmt.Errorf("failed to save credentials of flow %s: %w", id, err)
+		}
+	} else if err := e.credentials.store.DeleteCredentials(id); err != nil {
+		return fmt.Errorf("failed to delete credentials of flow %s: %w", id, err)
+	}
+	if err := e.contexts.restore(flowScope(id), b.Context[flowScope(id)]); err != nil {
+		return err
+	}
+	if err := e.storage.SaveFlow(id, b.Flows[id]); err != nil {
+		return fmt.Errorf("failed to save flow %s: %w", id, err)
+	}
+	e.saveVersion(id, b.Flows[id])
+	return nil
+}
+
+// restore replaces the persistent values of a context scope, in the store
+// and in the context if it is in use
+func (c *contexts) restore(scope string, values map[string]json.RawMessage) error {
+	store := c.contextStore()
+	if store != nil {
+		if err := store.DeleteContext(scope); err != nil {
+			return fmt.Errorf("failed to delete context %s: %w", scope, err)
+		}
+		for key, value := range values {
+			if err := store.SaveContextValue(scope, key, value); err != nil {
+				return fmt.Errorf("failed to save context value %s of %s: %w", key, scope, err)
+			}
+		}
+	}
+
+	ctx := c.loaded(scope)
+	if ctx == nil {
+		return nil
+	}
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	ctx.values = make(map[string]interface{}, len(values))
+	ctx.persistent = make(map[string]bool, len(values))
+	for key, data := range values {
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			c.logger.Warn("invalid context value", "scope", scope, "key", key, "error", err)
+			continue
+		}
+		ctx.values[key] = value
+		ctx.persistent[key] = true
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
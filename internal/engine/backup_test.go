@@ -0,0 +1,124 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// failingStorage fails to save the flow with the ID in fail
+type failingStorage struct {
+	*storage.MemoryStorage
+	fail string
+}
+
+func (s *failingStorage) SaveFlow(id string, flow []byte) error {
+	if id == s.fail {
+		return errors.New("disk full")
+	}
+	return s.MemoryStorage.SaveFlow(id, flow)
+}
+
+// initFlow returns the definition of a flow with one init node
+func initFlow(id, config string) json.RawMessage {
+	return json.RawMessage(`{"id": "` + id + `", "nodes": [{"id": "n", "type": "init", "config": ` + config + `}]}`)
+}
+
+// startRestoreEngine starts an engine on store running the flows a and c,
+// with "old" as the value of k in the global context
+func startRestoreEngine(t *testing.T, store storage.Storage) *engine.Engine {
+	t.Helper()
+	eng := startWorkspaceEngine(t, store)
+	for _, id := range []string{"a", "c"} {
+		if err := eng.DeployFlow(id, initFlow(id, `{}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	eng.GlobalContext(engine.DefaultWorkspace).SetPersistent("k", "old")
+	return eng
+}
+
+// checkUnchanged checks that the flows a and c still run and that the
+// global context still has k
+func checkUnchanged(t *testing.T, eng *engine.Engine, store storage.Storage) {
+	t.Helper()
+	stored, err := store.ListFlows()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(stored)
+	if strings.Join(stored, ",") != "a,c" {
+		t.Errorf("stored flows are %v, want [a c]", stored)
+	}
+	for _, id := range []string{"a", "c"} {
+		flow, exists := eng.GetFlow(id)
+		if !exists {
+			t.Errorf("flow %s is gone", id)
+			continue
+		}
+		if status := flow.GetStatus(); status != engine.FlowStatusRunning {
+			t.Errorf("flow %s is %s, want running", id, status)
+		}
+	}
+	if got, _ := eng.GlobalContext(engine.DefaultWorkspace).Get("k"); got != "old" {
+		t.Errorf("global context has k = %v, want old", got)
+	}
+}
+
+func TestRestoreRejectsBrokenFlows(t *testing.T) {
+	tests := []struct {
+		name string
+		flow json.RawMessage
+	}{
+		{"unknown node type", json.RawMessage(`{"id": "b", "nodes": [{"id": "n", "type": "missing", "config": {}}]}`)},
+		{"failing init", initFlow("b", `{"fail": true}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewMemoryStorage()
+			eng := startRestoreEngine(t, store)
+			defer eng.Stop()
+
+			backup := &engine.Backup{
+				Flows:   map[string]json.RawMessage{"a": initFlow("a", `{}`), "b": tt.flow},
+				Context: map[string]map[string]json.RawMessage{"global": {"k": json.RawMessage(`"new"`)}},
+			}
+			if report, err := eng.Restore(backup); err == nil || report != nil {
+				t.Fatalf("Restore() = %v, %v, want it rejected", report, err)
+			}
+			checkUnchanged(t, eng, store)
+		})
+	}
+}
+
+func TestRestoreRollsBack(t *testing.T) {
+	store := &failingStorage{MemoryStorage: storage.NewMemoryStorage()}
+	eng := startRestoreEngine(t, store)
+	defer eng.Stop()
+
+	// a is restored and c deleted before saving b fails
+	store.fail = "b"
+	backup := &engine.Backup{
+		Flows:   map[string]json.RawMessage{"a": initFlow("a", `{"value": 1}`), "b": initFlow("b", `{}`)},
+		Context: map[string]map[string]json.RawMessage{"global": {"k": json.RawMessage(`"new"`)}},
+	}
+	_, err := eng.Restore(backup)
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("Restore() error = %v, want the failure to save b", err)
+	}
+	store.fail = ""
+
+	checkUnchanged(t, eng, store)
+	saved, err := store.LoadFlow("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(saved), `"value"`) {
+		t.Errorf("flow a kept the version of the backup: %s", saved)
+	}
+}
@@ -0,0 +1,54 @@
+package engine
+
+import "time"
+
+// Clock abstracts time so that timer-driven nodes can be tested
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+
+	// NewTimer creates a timer that fires once after d
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single-shot timer created by a Clock
+type Timer interface {
+	// C returns the channel the timer fires on
+	C() <-chan time.Time
+
+	// Stop prevents the timer from firing
+	Stop() bool
+
+	// Reset changes the timer to fire after d
+	Reset(d time.Duration) bool
+}
+
+// SystemClock is the Clock backed by the time package
+type SystemClock struct{}
+
+// Now returns the current time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer creates a timer that fires once after d
+func (SystemClock) NewTimer(d time.Duration) Timer {
+	return &systemTimer{timer: time.NewTimer(d)}
+}
+
+// systemTimer wraps time.Timer
+type systemTimer struct {
+	timer *time.Timer
+}
+
+func (t *systemTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t *systemTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+func (t *systemTimer) Reset(d time.Duration) bool {
+	return t.timer.Reset(d)
+}
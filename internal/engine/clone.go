@@ -0,0 +1,215 @@
+package engine
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// CloneValue returns a deep copy of a payload or metadata value that keeps
+// its concrete types: structs stay structs and []byte stays []byte. Common
+// JSON-like values are copied without reflection. Unexported struct fields
+// are copied as they are, and kinds that cannot be copied, such as
+// channels and functions, go through JSON, or are shared if that fails.
+// Streams are shared, as they can only be read once.
+func CloneValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, string, bool, float64, float32, int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64, json.Number, time.Time, time.Duration, *Stream:
+		return v
+	case []byte:
+		if v == nil {
+			return v
+		}
+		return append([]byte{}, v...)
+	case map[string]interface{}:
+		if v == nil {
+			return v
+		}
+		clone := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			clone[key] = CloneValue(item)
+		}
+		return clone
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+		clone := make([]interface{}, len(v))
+		for i, item := range v {
+			clone[i] = CloneValue(item)
+		}
+		return clone
+	case map[string]string:
+		if v == nil {
+			return v
+		}
+		clone := make(map[string]string, len(v))
+		for key, item := range v {
+			clone[key] = item
+		}
+		return clone
+	case []string:
+		if v == nil {
+			return v
+		}
+		return append([]string{}, v...)
+	case []float64:
+		if v == nil {
+			return v
+		}
+		return append([]float64{}, v...)
+	}
+
+	c := &cloner{seen: make(map[pointer]reflect.Value)}
+	return c.clone(reflect.ValueOf(value)).Interface()
+}
+
+// cloner deep-copies values by reflection; seen maps the pointers copied
+// so far to their copies, so that shared and cyclic pointers stay so
+type cloner struct {
+	seen map[pointer]reflect.Value
+}
+
+// pointer identifies a pointer by address and type, as a struct and its
+// first field share their address
+type pointer struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// clone returns a deep copy of v
+func (c *cloner) clone(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return v
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		clone := reflect.New(v.Type()).Elem()
+		clone.Set(c.clone(v.Elem()))
+		return clone
+
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		if _, ok := v.Interface().(*Stream); ok {
+			return v
+		}
+		key := pointer{v.Pointer(), v.Type()}
+		if clone, ok := c.seen[key]; ok {
+			return clone
+		}
+		clone := reflect.New(v.Type().Elem())
+		c.seen[key] = clone
+		clone.Elem().Set(c.clone(v.Elem()))
+		return clone
+
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		clone := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			clone.SetMapIndex(c.clone(iter.Key()), c.clone(iter.Value()))
+		}
+		return clone
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		clone := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		if isFlat(v.Type().Elem()) {
+			reflect.Copy(clone, v)
+			return clone
+		}
+		for i := 0; i < v.Len(); i++ {
+			clone.Index(i).Set(c.clone(v.Index(i)))
+		}
+		return clone
+
+	case reflect.Array:
+		clone := reflect.New(v.Type()).Elem()
+		reflect.Copy(clone, v)
+		if !isFlat(v.Type().Elem()) {
+			for i := 0; i < v.Len(); i++ {
+				clone.Index(i).Set(c.clone(v.Index(i)))
+			}
+		}
+		return clone
+
+	case reflect.Struct:
+		// Copy the struct as a whole, which copies unexported fields as
+		// they are, then replace the exported fields by deep copies
+		clone := reflect.New(v.Type()).Elem()
+		clone.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if field := clone.Field(i); field.CanSet() {
+				field.Set(c.clone(v.Field(i)))
+			}
+		}
+		return clone
+	}
+
+	return jsonClone(v)
+}
+
+// isFlat reports whether values of a type hold no references, so that
+// copying them copies everything
+func isFlat(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	case reflect.Array:
+		return isFlat(t.Elem())
+	}
+	return false
+}
+
+// jsonClone copies a value of a kind the cloner does not know through
+// JSON, keeping its type, and shares it if that fails
+func jsonClone(v reflect.Value) reflect.Value {
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return v
+	}
+	clone := reflect.New(v.Type())
+	if err := json.Unmarshal(data, clone.Interface()); err != nil {
+		return v
+	}
+	return clone.Elem()
+}
+
+// SetCopyOnWrite sets whether a message sent on an output with a single
+// target is handed over instead of copied, which saves copying large
+// payloads. Nodes must then not change a message after sending it.
+func (e *Engine) SetCopyOnWrite(enabled bool) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+	atomic.StoreInt32(&e.copyOnWrite, value)
+}
+
+// CopyOnWrite reports whether messages to single targets are handed over
+func (e *Engine) CopyOnWrite() bool {
+	return atomic.LoadInt32(&e.copyOnWrite) == 1
+}
+
+// handOver reports whether a message sent to the targets of an output is
+// delivered without a copy
+func (n *Node) handOver(targets []NodeInstance) bool {
+	return len(targets) == 1 && n.flow != nil && n.flow.engine != nil && n.flow.engine.CopyOnWrite()
+}
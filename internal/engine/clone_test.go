@@ -0,0 +1,215 @@
+package engine_test
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/registry"
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// largePayload returns a nested payload of about 1MB as JSON
+func largePayload() map[string]interface{} {
+	records := make([]interface{}, 2000)
+	for i := range records {
+		values := make([]interface{}, 20)
+		for j := range values {
+			values[j] = float64(i * j)
+		}
+		records[i] = map[string]interface{}{
+			"id":     float64(i),
+			"name":   strings.Repeat("x", 300),
+			"active": i%2 == 0,
+			"values": values,
+			"tags":   map[string]interface{}{"zone": "a", "rack": float64(i % 40)},
+		}
+	}
+	return map[string]interface{}{"records": records, "blob": make([]byte, 64*1024)}
+}
+
+// jsonClone is how messages were copied before CloneValue
+func jsonClone(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		panic(err)
+	}
+	var clone interface{}
+	if err := json.Unmarshal(data, &clone); err != nil {
+		panic(err)
+	}
+	return clone
+}
+
+func BenchmarkCloneValue(b *testing.B) {
+	payload := largePayload()
+	data, _ := json.Marshal(payload)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		engine.CloneValue(payload)
+	}
+}
+
+func BenchmarkCloneJSON(b *testing.B) {
+	payload := largePayload()
+	data, _ := json.Marshal(payload)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		jsonClone(payload)
+	}
+}
+
+func BenchmarkMessageClone(b *testing.B) {
+	msg := engine.NewMessage(largePayload(), "bench")
+	msg.Metadata["trace"] = map[string]interface{}{"hops": []interface{}{"a", "b"}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		msg.Clone()
+	}
+}
+
+type reading struct {
+	Sensor string
+	Values []float64
+	Labels map[string]string
+	Next   *reading
+}
+
+func TestCloneValueIsolation(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  func() interface{}
+		mutate func(interface{})
+	}{
+		{"nested map", func() interface{} {
+			return map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{1.0, 2.0}}}
+		}, func(v interface{}) {
+			inner := v.(map[string]interface{})["a"].(map[string]interface{})
+			inner["b"].([]interface{})[0] = 9.0
+			inner["c"] = "added"
+		}},
+		{"bytes", func() interface{} { return []byte("abc") }, func(v interface{}) {
+			v.([]byte)[0] = 'z'
+		}},
+		{"struct pointer", func() interface{} {
+			return &reading{Sensor: "t1", Values: []float64{1, 2}, Labels: map[string]string{"unit": "C"}}
+		}, func(v interface{}) {
+			r := v.(*reading)
+			r.Sensor = "t2"
+			r.Values[0] = 9
+			r.Labels["unit"] = "F"
+		}},
+		{"slice of structs", func() interface{} {
+			return []reading{{Sensor: "t1", Values: []float64{1}}}
+		}, func(v interface{}) {
+			v.([]reading)[0].Values[0] = 9
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original, want := tt.value(), tt.value()
+			clone := engine.CloneValue(original)
+			if reflect.TypeOf(clone) != reflect.TypeOf(original) {
+				t.Fatalf("clone is a %T, want %T", clone, original)
+			}
+			tt.mutate(clone)
+			if !reflect.DeepEqual(original, want) {
+				t.Errorf("changing the clone changed the original to %v", original)
+			}
+		})
+	}
+}
+
+func TestCloneValueKeepsCycles(t *testing.T) {
+	r := &reading{Sensor: "loop"}
+	r.Next = r
+	clone := engine.CloneValue(r).(*reading)
+	if clone == r {
+		t.Fatal("pointer was not copied")
+	}
+	if clone.Next != clone {
+		t.Error("cycle was not kept in the clone")
+	}
+}
+
+// mutateNode records the payload it receives, then changes it
+type mutateNode struct {
+	engine.BaseNode
+	seen []string
+}
+
+func (n *mutateNode) Init(json.RawMessage) error      { return nil }
+func (n *mutateNode) Start(ctx context.Context) error { return nil }
+func (n *mutateNode) Stop()                           {}
+
+func (n *mutateNode) OnMessage(msg *engine.Message, port int) error {
+	payload := msg.Payload.(map[string]interface{})
+	n.seen = append(n.seen, payload["owner"].(string))
+	payload["owner"] = n.GetNode().ID
+	payload["list"].([]interface{})[0] = n.GetNode().ID
+	return nil
+}
+
+// TestFanOutIsolation checks that targets of one output each get their own
+// copy, with copy-on-write on or off
+func TestFanOutIsolation(t *testing.T) {
+	for _, copyOnWrite := range []bool{false, true} {
+		reg := registry.New()
+		var created []*mutateNode
+		if err := reg.RegisterNodeType(&engine.NodeType{
+			Name: "mutate",
+			Factory: func() engine.NodeInstance {
+				instance := &mutateNode{}
+				created = append(created, instance)
+				return instance
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		eng := engine.New(reg, storage.NewMemoryStorage())
+		eng.SetCopyOnWrite(copyOnWrite)
+		if err := eng.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		flowDef := []byte(`{
+			"id": "fan",
+			"nodes": [{"id": "src", "type": "mutate"}, {"id": "t1", "type": "mutate"}, {"id": "t2", "type": "mutate"}],
+			"wires": [{"source": "src", "target": "t1", "port": 0}, {"source": "src", "target": "t2", "port": 0}]
+		}`)
+		if err := eng.DeployFlow("fan", flowDef); err != nil {
+			t.Fatal(err)
+		}
+		flow, _ := eng.GetFlow("fan")
+		targets := make(map[string]*mutateNode)
+		for _, instance := range created {
+			if node, ok := flow.GetNode(instance.GetNode().ID); ok && node == instance.GetNode() && node.ID != "src" {
+				targets[node.ID] = instance
+			}
+		}
+		if len(targets) != 2 {
+			t.Fatalf("found %d running targets, want 2", len(targets))
+		}
+
+		src, _ := flow.GetNode("src")
+		payload := map[string]interface{}{"owner": "sender", "list": []interface{}{"sender"}}
+		if err := src.Send(engine.NewMessage(payload, ""), 0); err != nil {
+			t.Fatal(err)
+		}
+		eng.Stop()
+
+		for id, node := range targets {
+			if len(node.seen) != 1 || node.seen[0] != "sender" {
+				t.Errorf("copyOnWrite %v: %s saw %v, want [sender]", copyOnWrite, id, node.seen)
+			}
+		}
+		if payload["owner"] != "sender" || payload["list"].([]interface{})[0] != "sender" {
+			t.Errorf("copyOnWrite %v: targets changed the sender's payload to %v", copyOnWrite, payload)
+		}
+	}
+}
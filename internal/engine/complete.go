@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// completions holds the callbacks of the complete nodes of a flow by the ID
+// of the node they watch
+type completions struct {
+	mu        sync.RWMutex
+	callbacks map[string][]*completeCallback
+}
+
+// completeCallback is a callback registered with OnComplete
+type completeCallback struct {
+	fn func(*Message)
+}
+
+// Done reports that the node finished handling a message, e.g. wrote or
+// published it. Nodes without outputs call it, so that complete nodes
+// watching them learn when the message was handled.
+func (n *Node) Done(msg *Message) {
+	if n.flow == nil {
+		return
+	}
+	c := &n.flow.completions
+	c.mu.RLock()
+	callbacks := c.callbacks[n.ID]
+	c.mu.RUnlock()
+
+	for _, callback := range callbacks {
+		done := msg.Clone()
+		done.SetMetadata("completedBy", n.ID)
+		callback.fn(done)
+	}
+}
+
+// OnComplete calls fn with a copy of every message that one of the nodes
+// ids reports done, with the node's ID in the "completedBy" metadata. The
+// message keeps its ID, so it can be matched with the original. The
+// returned function removes the callback.
+func (f *Flow) OnComplete(ids []string, fn func(*Message)) func() {
+	callback := &completeCallback{fn: fn}
+	c := &f.completions
+	c.mu.Lock()
+	if c.callbacks == nil {
+		c.callbacks = make(map[string][]*completeCallback)
+	}
+	for _, id := range ids {
+		c.callbacks[id] = append(c.callbacks[id], callback)
+	}
+	c.mu.Unlock()
+
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for _, id := range ids {
+			callbacks := c.callbacks[id]
+			for i, registered := range callbacks {
+				if registered == callback {
+					callbacks = append(callbacks[:i:i], callbacks[i+1:]...)
+					break
+				}
+			}
+			if len(callbacks) == 0 {
+				delete(c.callbacks, id)
+			} else {
+				c.callbacks[id] = callbacks
+			}
+		}
+	}
+}
+
+// CompleteConfig represents the configuration of a complete node
+type CompleteConfig struct {
+	// Scope lists the IDs of the nodes watched
+	Scope []string `json:"scope"`
+}
+
+// CompleteNode sends the messages that the nodes in its scope report done
+type CompleteNode struct {
+	BaseNode
+	config CompleteConfig
+	cancel func()
+}
+
+// Init initializes the node with its configuration
+func (n *CompleteNode) Init(config json.RawMessage) error {
+	if err := n.LoadConfig(config, &n.config); err != nil {
+		return err
+	}
+	if len(n.config.Scope) == 0 {
+		return fmt.Errorf("scope must list at least one node")
+	}
+	return nil
+}
+
+// Start starts watching the nodes in scope
+func (n *CompleteNode) Start(ctx context.Context) error {
+	node := n.GetNode()
+	n.cancel = node.GetFlow().OnComplete(n.config.Scope, func(msg *Message) {
+		if err := node.Send(msg, 0); err != nil && !errors.Is(err, ErrFlowThrottled) {
+			node.Logger().Warn("failed to send", "error", err)
+		}
+	})
+	return nil
+}
+
+// Stop stops watching
+func (n *CompleteNode) Stop() {
+	if n.cancel != nil {
+		n.cancel()
+		n.cancel = nil
+	}
+}
+
+// OnMessage processes a message
+func (n *CompleteNode) OnMessage(msg *Message, port int) error {
+	return nil // Input node, doesn't process messages
+}
@@ -0,0 +1,346 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldError is a problem with one field of a node configuration
+type FieldError struct {
+	// Path is the JSON path of the field, such as "signature.secret"
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ConfigError lists every problem UnmarshalConfig found in a configuration
+type ConfigError struct {
+	Fields []FieldError
+}
+
+func (e *ConfigError) Error() string {
+	problems := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		problems[i] = field.Path + ": " + field.Message
+	}
+	return "invalid config: " + strings.Join(problems, "; ")
+}
+
+// UnmarshalConfig decodes a node configuration into the struct target
+// points to and checks its `validate` tags, which hold comma-separated
+// rules:
+//
+//	required      the field must not be empty
+//	omitempty     skip the other rules if the field is empty
+//	min=N, max=N  bounds for numbers, or for the length of strings, slices and maps
+//	oneof=a b c   the field must be one of the values
+//	duration      the field must parse with time.ParseDuration
+//
+// Field errors are returned together as a *ConfigError. Fields that target
+// does not have are logged; nodes should use BaseNode.LoadConfig, which
+// also applies the node type's defaults and reports them with the flow.
+func UnmarshalConfig(raw json.RawMessage, target interface{}) error {
+	unknown, err := decodeConfig(nil, raw, target)
+	for _, path := range unknown {
+		slog.Warn("unknown config field", "path", path)
+	}
+	return err
+}
+
+// LoadConfig is UnmarshalConfig for node instances: the defaults
+// registered for the node type are applied first, and unknown fields
+// become warnings of the node
+func (b *BaseNode) LoadConfig(raw json.RawMessage, target interface{}) error {
+	if b.node == nil {
+		return UnmarshalConfig(raw, target)
+	}
+
+	unknown, err := decodeConfig(b.node.Type.Defaults, raw, target)
+	warnings := make([]ValidationIssue, len(unknown))
+	for i, path := range unknown {
+		warnings[i] = ValidationIssue{NodeID: b.node.ID, Message: fmt.Sprintf("unknown config field %q", path)}
+	}
+	b.node.mu.Lock()
+	b.node.warnings = warnings
+	b.node.mu.Unlock()
+	return err
+}
+
+// Warnings returns the problems found in the node's configuration that did
+// not prevent it from being created
+func (n *Node) Warnings() []ValidationIssue {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.warnings
+}
+
+// decodeConfig applies defaults and raw to target and validates the result.
+// It returns the paths of fields in raw that target does not have.
+func decodeConfig(defaults, raw json.RawMessage, target interface{}) ([]string, error) {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config target must be a pointer to a struct, not %T", target)
+	}
+
+	if len(defaults) > 0 {
+		if err := DecodeJSON(defaults, target); err != nil {
+			return nil, fmt.Errorf("invalid config defaults: %w", err)
+		}
+	}
+
+	var unknown []string
+	if len(raw) > 0 {
+		if err := DecodeJSON(raw, target); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				return nil, &ConfigError{Fields: []FieldError{{
+					Path:    typeErr.Field,
+					Message: fmt.Sprintf("must be %s, not %s", kindName(typeErr.Type), typeErr.Value),
+				}}}
+			}
+			return nil, fmt.Errorf("invalid config: %w", err)
+		}
+		unknown = unknownFields("", raw, value.Type())
+	}
+
+	if fields := validateFields("", value.Elem()); len(fields) > 0 {
+		return unknown, &ConfigError{Fields: fields}
+	}
+	return unknown, nil
+}
+
+// kindName describes a Go type in JSON terms
+func kindName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.String:
+		return "a string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
+
+// jsonFields maps the lower case JSON names of a struct's fields, including
+// those of embedded structs, to their types
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for embedded, fieldType := range jsonFields(field.Type) {
+				fields[embedded] = fieldType
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[strings.ToLower(name)] = field.Type
+	}
+	return fields
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// unknownFields returns the paths of the object keys in raw that have no
+// field in t, matching names case-insensitively like encoding/json
+func unknownFields(path string, raw json.RawMessage, t reflect.Type) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(unmarshalerType) {
+		return nil
+	}
+
+	var unknown []string
+	switch t.Kind() {
+	case reflect.Struct:
+		var object map[string]json.RawMessage
+		if json.Unmarshal(raw, &object) != nil {
+			return nil
+		}
+		fields := jsonFields(t)
+		for _, key := range sortedKeys(object) {
+			fieldType, known := fields[strings.ToLower(key)]
+			if !known {
+				unknown = append(unknown, joinPath(path, key))
+				continue
+			}
+			unknown = append(unknown, unknownFields(joinPath(path, key), object[key], fieldType)...)
+		}
+	case reflect.Slice, reflect.Array:
+		var items []json.RawMessage
+		if json.Unmarshal(raw, &items) != nil {
+			return nil
+		}
+		for i, item := range items {
+			unknown = append(unknown, unknownFields(fmt.Sprintf("%s[%d]", path, i), item, t.Elem())...)
+		}
+	case reflect.Map:
+		var object map[string]json.RawMessage
+		if json.Unmarshal(raw, &object) != nil {
+			return nil
+		}
+		for _, key := range sortedKeys(object) {
+			unknown = append(unknown, unknownFields(joinPath(path, key), object[key], t.Elem())...)
+		}
+	}
+	return unknown
+}
+
+// validateFields checks the validate tags of a struct and the structs it
+// contains
+func validateFields(path string, v reflect.Value) []FieldError {
+	var errs []FieldError
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			errs = append(errs, validateFields(path, v.Field(i))...)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fieldPath := joinPath(path, name)
+
+		if message := checkRules(field.Tag.Get("validate"), v.Field(i)); message != "" {
+			errs = append(errs, FieldError{Path: fieldPath, Message: message})
+		}
+		errs = append(errs, validateNested(fieldPath, v.Field(i))...)
+	}
+	return errs
+}
+
+// validateNested validates the structs inside a field
+func validateNested(path string, v reflect.Value) []FieldError {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return validateNested(path, v.Elem())
+		}
+	case reflect.Struct:
+		return validateFields(path, v)
+	case reflect.Slice, reflect.Array:
+		var errs []FieldError
+		for i := 0; i < v.Len(); i++ {
+			errs = append(errs, validateNested(fmt.Sprintf("%s[%d]", path, i), v.Index(i))...)
+		}
+		return errs
+	}
+	return nil
+}
+
+// checkRules applies the rules of a validate tag to a value and returns
+// what is wrong with it, if anything
+func checkRules(tag string, v reflect.Value) string {
+	if tag == "" {
+		return ""
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		rule, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch rule {
+		case "omitempty":
+			if v.IsZero() {
+				return ""
+			}
+		case "required":
+			if v.IsZero() {
+				return "is required"
+			}
+		case "min", "max":
+			bound, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				return fmt.Sprintf("invalid %s rule %q", rule, param)
+			}
+			value, isLength := measure(v)
+			if (rule == "min" && value >= bound) || (rule == "max" && value <= bound) {
+				continue
+			}
+			limit := "at least"
+			if rule == "max" {
+				limit = "at most"
+			}
+			if isLength {
+				return fmt.Sprintf("length must be %s %s", limit, param)
+			}
+			return fmt.Sprintf("must be %s %s", limit, param)
+		case "oneof":
+			options := strings.Fields(param)
+			value := fmt.Sprint(v.Interface())
+			found := false
+			for _, option := range options {
+				found = found || option == value
+			}
+			if !found {
+				return fmt.Sprintf("must be one of %s, not %q", strings.Join(options, ", "), value)
+			}
+		case "duration":
+			if _, err := time.ParseDuration(v.String()); err != nil {
+				return fmt.Sprintf("must be a duration such as \"10s\", not %q", v.String())
+			}
+		default:
+			return fmt.Sprintf("unknown validation rule %q", rule)
+		}
+	}
+	return ""
+}
+
+// measure returns a number's value, or the length of a string, slice or
+// map
+func measure(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true
+	}
+	return 0, false
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func sortedKeys(object map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,307 @@
+package engine
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// Context is a set of named values shared by the nodes of a flow, or by
+// the flows of a workspace for its global context. It is safe for
+// concurrent use.
+//
+// Persistent values are written through to a context store and survive
+// restarts. A value is persistent if it was set with SetPersistent, or if
+// its key starts with one of the engine's persistent prefixes.
+type Context struct {
+	mu     sync.RWMutex
+	values map[string]interface{}
+
+	// scope names the context in the store; persistent holds the keys
+	// written through
+	owner      *contexts
+	scope      string
+	persistent map[string]bool
+}
+
+// NewContext creates an empty context
+func NewContext() *Context {
+	return &Context{values: make(map[string]interface{}), persistent: make(map[string]bool)}
+}
+
+// Get returns the value of a key and whether it is set
+func (c *Context) Get(key string) (interface{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	value, exists := c.values[key]
+	return value, exists
+}
+
+// Set sets the value of a key; a nil value deletes it
+func (c *Context) Set(key string, value interface{}) {
+	c.set(key, value, false)
+}
+
+// SetPersistent sets the value of a key and writes it through to the
+// context store, if the engine has one
+func (c *Context) SetPersistent(key string, value interface{}) {
+	c.set(key, value, true)
+}
+
+// Delete deletes a key
+func (c *Context) Delete(key string) {
+	c.set(key, nil, false)
+}
+
+// set sets or, for a nil value, deletes a key, writing persistent values
+// through
+func (c *Context) set(key string, value interface{}, persistent bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	persistent = persistent || c.persistent[key] || c.owner.persistentKey(key)
+	if value == nil {
+		delete(c.values, key)
+	} else {
+		c.values[key] = value
+	}
+	if !persistent {
+		return
+	}
+	store := c.owner.contextStore()
+	if store == nil {
+		return
+	}
+
+	if value == nil {
+		delete(c.persistent, key)
+		if err := store.DeleteContextValue(c.scope, key); err != nil {
+			c.owner.logger.Warn("failed to delete context value", "scope", c.scope, "key", key, "error", err)
+		}
+		return
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		c.owner.logger.Warn("context value cannot be persisted", "scope", c.scope, "key", key, "error", err)
+		return
+	}
+	c.persistent[key] = true
+	if err := store.SaveContextValue(c.scope, key, data); err != nil {
+		c.owner.logger.Warn("failed to save context value", "scope", c.scope, "key", key, "error", err)
+	}
+}
+
+// Keys returns the set keys, sorted
+func (c *Context) Keys() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	keys := make([]string, 0, len(c.values))
+	for key := range c.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// load adds the values persisted in the store to the context
+func (c *Context) load(store storage.ContextStore) {
+	values, err := store.LoadContext(c.scope)
+	if err != nil {
+		c.owner.logger.Warn("failed to load context", "scope", c.scope, "error", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, data := range values {
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			c.owner.logger.Warn("invalid context value", "scope", c.scope, "key", key, "error", err)
+			continue
+		}
+		c.values[key] = value
+		c.persistent[key] = true
+	}
+}
+
+// globalScope returns the scope of a workspace's global context in the
+// context store; the default workspace keeps the scope "global"
+func globalScope(workspace string) string {
+	if workspace == "" || workspace == DefaultWorkspace {
+		return "global"
+	}
+	return "global:" + workspace
+}
+
+// flowScope returns the scope of a flow's context in the context store, by
+// flow key so that flows of different workspaces keep theirs apart
+func flowScope(key string) string {
+	return "flow:" + key
+}
+
+// contexts holds the global context of every workspace and the context of
+// every flow, which outlives redeploys of the flow until it is deleted
+type contexts struct {
+	mu       sync.Mutex
+	globals  map[string]*Context
+	flows    map[string]*Context
+	store    storage.ContextStore
+	prefixes []string
+	logger   *slog.Logger
+}
+
+// newContexts creates the contexts of an engine, persisting them in store
+// if it is a context store
+func newContexts(store storage.Storage, logger *slog.Logger) *contexts {
+	c := &contexts{globals: make(map[string]*Context), flows: make(map[string]*Context), logger: logger}
+	c.store, _ = store.(storage.ContextStore)
+	return c
+}
+
+// newContext creates a context and loads its persisted values
+func (c *contexts) newContext(scope string) *Context {
+	ctx := NewContext()
+	ctx.owner, ctx.scope = c, scope
+	if c.store != nil {
+		ctx.load(c.store)
+	}
+	return ctx
+}
+
+// global returns the global context of a workspace, creating it on first
+// use
+func (c *contexts) global(workspace string) *Context {
+	if workspace == "" {
+		workspace = DefaultWorkspace
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ctx, exists := c.globals[workspace]
+	if !exists {
+		ctx = c.newContext(globalScope(workspace))
+		c.globals[workspace] = ctx
+	}
+	return ctx
+}
+
+// flow returns the context of a flow by flow key, creating it on first use
+func (c *contexts) flow(key string) *Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ctx, exists := c.flows[key]
+	if !exists {
+		ctx = c.newContext(flowScope(key))
+		c.flows[key] = ctx
+	}
+	return ctx
+}
+
+// deleteFlow forgets the context of a deleted flow, by flow key, and its
+// persisted values
+func (c *contexts) deleteFlow(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.flows, key)
+	if c.store != nil {
+		if err := c.store.DeleteContext(flowScope(key)); err != nil {
+			c.logger.Warn("failed to delete context of flow", "flow_id", key, "error", err)
+		}
+	}
+}
+
+// deleteWorkspace forgets the global context of a deleted workspace and
+// its persisted values
+func (c *contexts) deleteWorkspace(workspace string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.globals, workspace)
+	if c.store != nil {
+		if err := c.store.DeleteContext(globalScope(workspace)); err != nil {
+			c.logger.Warn("failed to delete global context of workspace", "workspace", workspace, "error", err)
+		}
+	}
+}
+
+// loaded returns the context in use for a scope, or nil
+func (c *contexts) loaded(scope string) *Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, ctx := range c.globals {
+		if ctx.scope == scope {
+			return ctx
+		}
+	}
+	for _, ctx := range c.flows {
+		if ctx.scope == scope {
+			return ctx
+		}
+	}
+	return nil
+}
+
+// contextStore returns the store persistent values are written to; c may
+// be nil for contexts that do not belong to an engine
+func (c *contexts) contextStore() storage.ContextStore {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store
+}
+
+// persistentKey reports whether a key starts with a persistent prefix
+func (c *contexts) persistentKey(key string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, prefix := range c.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetContextStore replaces the store that persistent context values are
+// written to, e.g. to keep them apart from the flows; nil keeps them in
+// memory only. It is meant to be called before the engine is initialized.
+func (e *Engine) SetContextStore(store storage.ContextStore) {
+	c := e.contexts
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store = store
+	c.globals = make(map[string]*Context)
+	c.flows = make(map[string]*Context)
+}
+
+// SetPersistentContextPrefixes sets the key prefixes of the context values
+// that are persistent without SetPersistent
+func (e *Engine) SetPersistentContextPrefixes(prefixes []string) {
+	c := e.contexts
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prefixes = append([]string(nil), prefixes...)
+}
+
+// GlobalContext returns the context shared by the flows of a workspace.
+// Workspaces do not see each other's global context.
+func (e *Engine) GlobalContext(workspace string) *Context {
+	return e.contexts.global(workspace)
+}
+
+// Context returns the context shared by the flow's nodes
+func (f *Flow) Context() *Context {
+	return f.engine.contexts.flow(f.Key())
+}
+
+// GlobalContext returns the global context of the flow's workspace
+func (f *Flow) GlobalContext() *Context {
+	return f.engine.contexts.global(f.Workspace())
+}
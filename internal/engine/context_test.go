@@ -0,0 +1,94 @@
+package engine_test
+
+import (
+	"testing"
+
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/registry"
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// startWorkspaceEngine starts an engine on store with the flows stored in it
+func startWorkspaceEngine(t *testing.T, store storage.Storage) *engine.Engine {
+	t.Helper()
+	reg := registry.New()
+	if err := reg.RegisterNodeType(&engine.NodeType{
+		Name:    "init",
+		Factory: func() engine.NodeInstance { return &initNode{} },
+	}); err != nil {
+		t.Fatal(err)
+	}
+	eng := engine.New(reg, store)
+	if err := eng.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return eng
+}
+
+func TestContextsOfWorkspaces(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	eng := startWorkspaceEngine(t, store)
+
+	flowDef := []byte(`{"id": "f", "nodes": [{"id": "n", "type": "init", "config": {}}]}`)
+	flows := make(map[string]*engine.Flow)
+	for _, workspace := range []string{"a", "b"} {
+		if _, err := eng.CreateWorkspace(workspace); err != nil {
+			t.Fatal(err)
+		}
+		key := engine.FlowKey(workspace, "f")
+		if err := eng.DeployFlow(key, flowDef); err != nil {
+			t.Fatal(err)
+		}
+		flows[workspace], _ = eng.GetFlow(key)
+		flows[workspace].Context().SetPersistent("team", workspace)
+	}
+	flows["a"].GlobalContext().SetPersistent("secret", "a")
+
+	for workspace, flow := range flows {
+		if got, _ := flow.Context().Get("team"); got != workspace {
+			t.Errorf("flow context of workspace %s has %v, want %s", workspace, got, workspace)
+		}
+	}
+	if got, exists := flows["b"].GlobalContext().Get("secret"); exists {
+		t.Errorf("workspace b reads the global context of workspace a: %v", got)
+	}
+	if got, exists := eng.GlobalContext(engine.DefaultWorkspace).Get("secret"); exists {
+		t.Errorf("the default workspace reads the global context of workspace a: %v", got)
+	}
+
+	// Deleting a flow only deletes the context of its own workspace
+	if err := eng.DeleteFlow(engine.FlowKey("a", "f")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := flows["b"].Context().Get("team"); got != "b" {
+		t.Errorf("flow context of workspace b has %v after deleting the flow of workspace a, want b", got)
+	}
+	eng.Stop()
+
+	// A new engine on the same store loads each workspace's contexts
+	eng = startWorkspaceEngine(t, store)
+	defer eng.Stop()
+	flow, exists := eng.GetFlow(engine.FlowKey("b", "f"))
+	if !exists {
+		t.Fatal("flow of workspace b was not loaded")
+	}
+	if got, _ := flow.Context().Get("team"); got != "b" {
+		t.Errorf("flow context of workspace b has %v after a restart, want b", got)
+	}
+	if got, _ := eng.GlobalContext("a").Get("secret"); got != "a" {
+		t.Errorf("global context of workspace a has %v after a restart, want a", got)
+	}
+	if got, exists := eng.GlobalContext("b").Get("secret"); exists {
+		t.Errorf("workspace b reads the global context of workspace a after a restart: %v", got)
+	}
+	if err := eng.DeployFlow(engine.FlowKey("a", "f"), flowDef); err != nil {
+		t.Fatal(err)
+	}
+	flow, _ = eng.GetFlow(engine.FlowKey("a", "f"))
+	if got, exists := flow.Context().Get("team"); exists {
+		t.Errorf("redeployed flow of workspace a kept the context of the deleted one: %v", got)
+	}
+}
@@ -0,0 +1,310 @@
+package engine
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MetadataCorrelationID is the metadata key holding the ID a reply is
+// matched to its request by
+const MetadataCorrelationID = "correlationId"
+
+// metadataCorrelationTimeout marks a request that is registered when sent
+const metadataCorrelationTimeout = "correlationTimeout"
+
+// EventCorrelationTimeout is published when a request gets no reply in time
+const EventCorrelationTimeout = "correlation-timeout"
+
+// defaultMaxCorrelations bounds the number of pending requests
+const defaultMaxCorrelations = 10000
+
+var (
+	// ErrCorrelationNotFound is returned for replies to unknown or expired
+	// requests
+	ErrCorrelationNotFound = errors.New("no pending request with this correlation ID")
+	// ErrCorrelationExists is returned when a correlation ID is reused while
+	// its request is pending
+	ErrCorrelationExists = errors.New("correlation ID is already pending")
+	// ErrTooManyCorrelations is returned while the pending requests are at
+	// their limit
+	ErrTooManyCorrelations = errors.New("too many pending correlated requests")
+	// ErrCorrelationTimeout is what a waiting HTTP client is told when its
+	// correlated request expires
+	ErrCorrelationTimeout = errors.New("no reply to correlated request in time")
+)
+
+// SetCorrelation marks the message as a request awaiting a reply with the
+// given ID. The request is registered when a node sends the message and
+// expires after timeout; replies are matched with Engine.Reply.
+func (m *Message) SetCorrelation(id string, timeout time.Duration) {
+	m.SetMetadata(MetadataCorrelationID, id)
+	m.SetMetadata(metadataCorrelationTimeout, timeout.String())
+}
+
+// Correlation is a pending request as shown by the API
+type Correlation struct {
+	ID        string    `json:"id"`
+	Workspace string    `json:"workspace,omitempty"`
+	FlowID    string    `json:"flowId,omitempty"`
+	NodeID    string    `json:"nodeId,omitempty"`
+	Created   time.Time `json:"created"`
+	Deadline  time.Time `json:"deadline"`
+}
+
+// Correlations matches replies to pending requests, which may have left the
+// engine in between, e.g. through a message broker
+type Correlations struct {
+	engine     *Engine
+	maxPending int
+
+	pending  map[string]*pendingCorrelation
+	expiries correlationHeap
+	running  bool
+	wake     chan struct{}
+	mu       sync.Mutex
+}
+
+// pendingCorrelation is a request waiting for its reply
+type pendingCorrelation struct {
+	Correlation
+	// context holds the request's metadata for the reply
+	context map[string]interface{}
+	index   int
+}
+
+func newCorrelations(e *Engine) *Correlations {
+	return &Correlations{
+		engine:     e,
+		maxPending: defaultMaxCorrelations,
+		pending:    make(map[string]*pendingCorrelation),
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// SetMaxPending changes how many requests may be pending at once
+func (c *Correlations) SetMaxPending(max int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxPending = max
+}
+
+// Register adds a pending request, sent by node if not nil, that expires
+// after timeout
+func (c *Correlations) Register(id string, timeout time.Duration, request *Message, node *Node) error {
+	if id == "" {
+		return fmt.Errorf("correlated request has no ID")
+	}
+	if timeout <= 0 {
+		return fmt.Errorf("correlated request %s has no timeout", id)
+	}
+
+	now := c.engine.Clock().Now()
+	pending := &pendingCorrelation{
+		Correlation: Correlation{ID: id, Created: now, Deadline: now.Add(timeout)},
+		context:     make(map[string]interface{}, len(request.Metadata)),
+	}
+	for key, value := range request.Metadata {
+		pending.context[key] = value
+	}
+	if node != nil {
+		pending.NodeID = node.ID
+		if node.flow != nil {
+			pending.Workspace = node.flow.Workspace()
+			pending.FlowID = node.flow.ID
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.pending[id]; exists {
+		return fmt.Errorf("%w: %s", ErrCorrelationExists, id)
+	}
+	if len(c.pending) >= c.maxPending {
+		return ErrTooManyCorrelations
+	}
+	c.pending[id] = pending
+	heap.Push(&c.expiries, pending)
+
+	if !c.running {
+		c.running = true
+		go c.run()
+	} else if c.expiries[0] == pending {
+		// Expires before what the expiry loop waits for
+		select {
+		case c.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Reply completes the pending request with the ID and returns reply with
+// the request's metadata restored, such as the HTTP request an http-response
+// node answers. Metadata the reply carries itself is kept.
+func (c *Correlations) Reply(id string, reply *Message) (*Message, error) {
+	c.mu.Lock()
+	pending, exists := c.pending[id]
+	if exists {
+		delete(c.pending, id)
+		heap.Remove(&c.expiries, pending.index)
+	}
+	c.mu.Unlock()
+
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrCorrelationNotFound, id)
+	}
+	if reply.Metadata == nil {
+		reply.Metadata = make(map[string]interface{})
+	}
+	for key, value := range pending.context {
+		if _, exists := reply.Metadata[key]; !exists && key != metadataCorrelationTimeout {
+			reply.SetMetadata(key, value)
+		}
+	}
+	return reply, nil
+}
+
+// forget drops a pending request whose message was not sent after all
+func (c *Correlations) forget(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if pending, exists := c.pending[id]; exists {
+		delete(c.pending, id)
+		heap.Remove(&c.expiries, pending.index)
+	}
+}
+
+// Pending returns the pending requests, the earliest to expire first
+func (c *Correlations) Pending() []Correlation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pending := make([]Correlation, 0, len(c.pending))
+	for _, p := range c.pending {
+		pending = append(pending, p.Correlation)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Deadline.Before(pending[j].Deadline)
+	})
+	return pending
+}
+
+// MaxPending returns how many requests may be pending at once
+func (c *Correlations) MaxPending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.maxPending
+}
+
+// run expires requests until none are pending
+func (c *Correlations) run() {
+	clock := c.engine.Clock()
+	for {
+		c.mu.Lock()
+		if len(c.expiries) == 0 {
+			c.running = false
+			c.mu.Unlock()
+			return
+		}
+		now := clock.Now()
+		var expired []*pendingCorrelation
+		for len(c.expiries) > 0 && !c.expiries[0].Deadline.After(now) {
+			pending := heap.Pop(&c.expiries).(*pendingCorrelation)
+			delete(c.pending, pending.ID)
+			expired = append(expired, pending)
+		}
+		var wait time.Duration
+		if len(c.expiries) > 0 {
+			wait = c.expiries[0].Deadline.Sub(now)
+		}
+		c.mu.Unlock()
+
+		for _, pending := range expired {
+			c.expire(pending)
+		}
+		if wait <= 0 {
+			continue
+		}
+
+		timer := clock.NewTimer(wait)
+		select {
+		case <-timer.C():
+		case <-c.wake:
+			timer.Stop()
+		}
+	}
+}
+
+// expire reports a request that got no reply. A waiting HTTP client is
+// answered with 504 Gateway Timeout right away.
+func (c *Correlations) expire(pending *pendingCorrelation) {
+	c.engine.Logger().Warn("correlated request got no reply in time",
+		"correlation_id", pending.ID, "flow_id", pending.FlowID, "node_id", pending.NodeID)
+	if requestID, ok := pending.context[MetadataHTTPRequestID].(string); ok {
+		c.engine.HTTPRequests().Respond(requestID, &HTTPResponse{
+			StatusCode: http.StatusGatewayTimeout,
+			Headers:    map[string]string{"Content-Type": "text/plain; charset=utf-8"},
+			Body:       []byte(ErrCorrelationTimeout.Error()),
+		})
+	}
+	c.engine.events.Publish(Event{
+		Type:      EventCorrelationTimeout,
+		Workspace: pending.Workspace,
+		FlowID:    pending.FlowID,
+		NodeID:    pending.NodeID,
+		Data: map[string]interface{}{
+			"id":      pending.ID,
+			"created": pending.Created,
+		},
+	})
+}
+
+// registerCorrelation registers msg if it was marked by SetCorrelation and
+// not yet registered, and returns the ID it registered
+func (n *Node) registerCorrelation(msg *Message) (string, error) {
+	value, marked := msg.Metadata[metadataCorrelationTimeout]
+	if !marked || n.flow == nil || n.flow.engine == nil {
+		return "", nil
+	}
+	delete(msg.Metadata, metadataCorrelationTimeout)
+
+	timeout, err := time.ParseDuration(fmt.Sprint(value))
+	if err != nil {
+		return "", fmt.Errorf("invalid correlation timeout: %v", value)
+	}
+	id, _ := msg.Metadata[MetadataCorrelationID].(string)
+	if err := n.flow.engine.correlations.Register(id, timeout, msg, n); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// correlationHeap orders pending requests by deadline
+type correlationHeap []*pendingCorrelation
+
+func (h correlationHeap) Len() int           { return len(h) }
+func (h correlationHeap) Less(i, j int) bool { return h[i].Deadline.Before(h[j].Deadline) }
+
+func (h correlationHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+
+func (h *correlationHeap) Push(x interface{}) {
+	pending := x.(*pendingCorrelation)
+	pending.index = len(*h)
+	*h = append(*h, pending)
+}
+
+func (h *correlationHeap) Pop() interface{} {
+	old := *h
+	pending := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return pending
+}
@@ -0,0 +1,392 @@
+package engine
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// ErrCredentialSecret is returned when the credentials of a flow were
+// encrypted with another credential secret than the engine's
+var ErrCredentialSecret = errors.New("credentials were encrypted with a different credentialSecret")
+
+// flowCredentials holds the credential values of a flow by node and by the
+// path of their config field. The nodes of subflows are keyed
+// "subflow:<subflow ID>/<node ID>".
+type flowCredentials map[string]map[string]interface{}
+
+// credentialEnvelope is the stored form of the credentials of a flow:
+// encrypted, with the ID of the key, or in plain text without a secret
+type credentialEnvelope struct {
+	KeyID string          `json:"keyId,omitempty"`
+	Data  string          `json:"data,omitempty"`
+	Plain flowCredentials `json:"plain,omitempty"`
+}
+
+// credentials encrypts the credentials of flows and keeps them in a store
+type credentials struct {
+	store storage.CredentialStore
+	mu    sync.RWMutex
+	// key is the AES-256 key derived from the secret; nil without one
+	key   []byte
+	keyID string
+	// unencrypted warns once about credentials stored in plain text
+	unencrypted sync.Once
+	logger      *slog.Logger
+}
+
+// newCredentials returns the credentials of an engine, kept in the storage
+// if it is a credential store and in memory otherwise
+func newCredentials(store storage.Storage, logger *slog.Logger) *credentials {
+	c := &credentials{logger: logger}
+	if credentialStore, ok := store.(storage.CredentialStore); ok {
+		c.store = credentialStore
+	} else {
+		c.store = storage.NewMemoryStorage()
+	}
+	return c
+}
+
+// SetCredentialSecret sets the secret that the credentials of flows are
+// encrypted with. Without one, credentials are still kept apart from the
+// flows, but in plain text.
+func (e *Engine) SetCredentialSecret(secret string) {
+	c := e.credentials
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if secret == "" {
+		c.key, c.keyID = nil, ""
+		return
+	}
+	key := sha256.Sum256([]byte(secret))
+	id := sha256.Sum256(key[:])
+	c.key, c.keyID = key[:], hex.EncodeToString(id[:8])
+}
+
+// load loads and decrypts the credentials of a flow
+func (c *credentials) load(flowID string) (flowCredentials, error) {
+	data, exists, err := c.store.LoadCredentials(flowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load credentials: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+	return c.decode(flowID, data)
+}
+
+// decode decrypts credentials as saved for a flow
+func (c *credentials) decode(flowID string, data []byte) (flowCredentials, error) {
+	var envelope credentialEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("invalid credentials: %w", err)
+	}
+	if envelope.KeyID == "" {
+		return envelope.Plain, nil
+	}
+
+	c.mu.RLock()
+	key, keyID := c.key, c.keyID
+	c.mu.RUnlock()
+	switch {
+	case key == nil:
+		return nil, fmt.Errorf("credentials are encrypted, but no credentialSecret is set")
+	case envelope.KeyID != keyID:
+		return nil, ErrCredentialSecret
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(envelope.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid credentials: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid credentials: too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, []byte(flowID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credentials: %w", err)
+	}
+	var creds flowCredentials
+	if err := json.Unmarshal(plain, &creds); err != nil {
+		return nil, fmt.Errorf("invalid credentials: %w", err)
+	}
+	return creds, nil
+}
+
+// save encrypts and saves the credentials of a flow, deleting them if
+// there are none
+func (c *credentials) save(flowID string, creds flowCredentials) error {
+	if len(creds) == 0 {
+		return c.store.DeleteCredentials(flowID)
+	}
+
+	c.mu.RLock()
+	key, keyID := c.key, c.keyID
+	c.mu.RUnlock()
+
+	envelope := credentialEnvelope{Plain: creds}
+	if key == nil {
+		c.unencrypted.Do(func() {
+			c.logger.Warn("credentialSecret is not set, credentials are stored unencrypted")
+		})
+	} else {
+		plain, err := json.Marshal(creds)
+		if err != nil {
+			return fmt.Errorf("failed to encode credentials: %w", err)
+		}
+		gcm, err := newGCM(key)
+		if err != nil {
+			return err
+		}
+		nonce := make([]byte, gcm.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return fmt.Errorf("failed to encrypt credentials: %w", err)
+		}
+		// The flow ID is authenticated, so credentials cannot be moved to
+		// another flow
+		sealed := gcm.Seal(nonce, nonce, plain, []byte(flowID))
+		envelope = credentialEnvelope{KeyID: keyID, Data: base64.StdEncoding.EncodeToString(sealed)}
+	}
+
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to encode credentials: %w", err)
+	}
+	return c.store.SaveCredentials(flowID, data)
+}
+
+// newGCM returns an AES-GCM cipher with key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// credentialFields returns the credential fields of a node type
+func (e *Engine) credentialFields(typeName string) []string {
+	nodeType, err := e.registry.GetNodeType(typeName)
+	if err != nil {
+		return nil
+	}
+	return nodeType.Credentials
+}
+
+// eachConfig calls fn with the credential key, credential fields and
+// config of every node of a decoded flow definition, including the nodes
+// of its subflows, whose type has credentials
+func (e *Engine) eachConfig(def map[string]interface{}, fn func(key string, fields []string, config map[string]interface{})) {
+	visit := func(prefix string, nodes interface{}) {
+		list, _ := nodes.([]interface{})
+		for _, item := range list {
+			node, _ := item.(map[string]interface{})
+			id, _ := node["id"].(string)
+			typeName, _ := node["type"].(string)
+			fields := e.credentialFields(typeName)
+			if len(fields) == 0 {
+				continue
+			}
+			config, ok := node["config"].(map[string]interface{})
+			if !ok {
+				config = make(map[string]interface{})
+				node["config"] = config
+			}
+			fn(prefix+id, fields, config)
+		}
+	}
+
+	visit("", def["nodes"])
+	subflows, _ := def["subflows"].([]interface{})
+	for _, item := range subflows {
+		subflow, _ := item.(map[string]interface{})
+		id, _ := subflow["id"].(string)
+		visit(SubflowTypePrefix+id+"/", subflow["nodes"])
+	}
+}
+
+// splitCredentials removes the credential values from a flow definition and
+// returns them. Nodes that leave a credential out keep the one in previous,
+// so that a definition read from the API can be deployed again. A
+// definition without credentials is returned as it is.
+func (e *Engine) splitCredentials(flowDef []byte, previous flowCredentials) ([]byte, flowCredentials, error) {
+	var def map[string]interface{}
+	if err := DecodeJSON(flowDef, &def); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal flow definition: %w", err)
+	}
+
+	creds := make(flowCredentials)
+	removed := false
+	e.eachConfig(def, func(key string, fields []string, config map[string]interface{}) {
+		for _, field := range fields {
+			value, exists := removePath(config, field)
+			if exists {
+				removed = true
+			} else if value, exists = previous[key][field]; !exists {
+				continue
+			}
+			if creds[key] == nil {
+				creds[key] = make(map[string]interface{})
+			}
+			creds[key][field] = value
+		}
+	})
+	if !removed {
+		return flowDef, creds, nil
+	}
+
+	stripped, err := json.Marshal(def)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal flow definition: %w", err)
+	}
+	return stripped, creds, nil
+}
+
+// injectCredentials adds credential values to a flow definition
+func (e *Engine) injectCredentials(flowDef []byte, creds flowCredentials) ([]byte, error) {
+	if len(creds) == 0 {
+		return flowDef, nil
+	}
+	var def map[string]interface{}
+	if err := DecodeJSON(flowDef, &def); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal flow definition: %w", err)
+	}
+	e.eachConfig(def, func(key string, fields []string, config map[string]interface{}) {
+		for field, value := range creds[key] {
+			setPath(config, field, value)
+		}
+	})
+	return json.Marshal(def)
+}
+
+// StripCredentials removes the credential values from a flow definition,
+// e.g. before it is returned by the API
+func (e *Engine) StripCredentials(flowDef []byte) ([]byte, error) {
+	stripped, _, err := e.splitCredentials(flowDef, nil)
+	return stripped, err
+}
+
+// PublicJSON returns the flow's definition like ToJSON, without the
+// credential values
+func (f *Flow) PublicJSON() ([]byte, error) {
+	flowDef, err := f.ToJSON()
+	if err != nil {
+		return nil, err
+	}
+	return f.engine.StripCredentials(flowDef)
+}
+
+// PublicConfig returns the node's config without its credentials
+func (n *Node) PublicConfig() json.RawMessage {
+	if len(n.Type.Credentials) == 0 || len(n.Config) == 0 {
+		return n.Config
+	}
+	var config map[string]interface{}
+	if err := DecodeJSON(n.Config, &config); err != nil {
+		return n.Config
+	}
+	for _, field := range n.Type.Credentials {
+		removePath(config, field)
+	}
+	public, err := json.Marshal(config)
+	if err != nil {
+		return n.Config
+	}
+	return public
+}
+
+// saveFlow saves a flow definition without its credentials, which are saved
+// encrypted, and returns the definition with them
+func (e *Engine) saveFlow(id string, flowDef []byte) ([]byte, error) {
+	previous, err := e.credentials.load(id)
+	if err != nil {
+		e.Logger().Warn("replacing unreadable credentials of flow", "flow_id", id, "error", err)
+	}
+	stripped, creds, err := e.splitCredentials(flowDef, previous)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.credentials.save(id, creds); err != nil {
+		return nil, fmt.Errorf("failed to save credentials: %w", err)
+	}
+	if err := e.storage.SaveFlow(id, stripped); err != nil {
+		return nil, fmt.Errorf("failed to save flow: %w", err)
+	}
+	e.saveVersion(id, stripped)
+	return e.injectCredentials(stripped, creds)
+}
+
+// withCredentials returns a flow definition as saveFlow would return it,
+// with the credentials it leaves out taken from those saved, without
+// saving it
+func (e *Engine) withCredentials(id string, flowDef []byte) ([]byte, error) {
+	previous, err := e.credentials.load(id)
+	if err != nil {
+		previous = nil
+	}
+	stripped, creds, err := e.splitCredentials(flowDef, previous)
+	if err != nil {
+		return nil, err
+	}
+	return e.injectCredentials(stripped, creds)
+}
+
+// LoadFlow loads a stored flow definition with its credentials
+func (e *Engine) LoadFlow(id string) ([]byte, error) {
+	flowDef, err := e.storage.LoadFlow(id)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := e.credentials.load(id)
+	if err != nil {
+		return nil, fmt.Errorf("flow %s: %w", id, err)
+	}
+	return e.injectCredentials(flowDef, creds)
+}
+
+// removePath removes the value at a dotted path from a config and returns it
+func removePath(config map[string]interface{}, path string) (interface{}, bool) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := config[key].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		config = next
+	}
+	last := keys[len(keys)-1]
+	value, exists := config[last]
+	delete(config, last)
+	return value, exists
+}
+
+// setPath sets the value at a dotted path of a config, creating objects on
+// the way
+func setPath(config map[string]interface{}, path string, value interface{}) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := config[key].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			config[key] = next
+		}
+		config = next
+	}
+	config[keys[len(keys)-1]] = value
+}
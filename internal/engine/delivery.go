@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Flow delivery modes
+const (
+	// DeliveryAtMostOnce reports a source message as handled as soon as
+	// Send returned; messages held by buffering nodes may be lost
+	DeliveryAtMostOnce = "at-most-once"
+	// DeliveryAtLeastOnce acknowledges a source message only after every
+	// copy of it was handled, so the source can redeliver it otherwise
+	DeliveryAtLeastOnce = "at-least-once"
+)
+
+// defaultAckTimeout is how long an at-least-once delivery may stay open
+const defaultAckTimeout = 5 * time.Minute
+
+// ErrAckTimeout is reported when a delivery was not completed within the
+// flow's ack timeout
+var ErrAckTimeout = errors.New("message was not handled within the ack timeout")
+
+// delivery tracks the outstanding copies of a message sent with SendWithAck.
+// Every copy delivered by Send, and every Retain, holds the delivery open.
+// It completes with nil once all holds were released without error, or
+// with the first error reported.
+type delivery struct {
+	pending  int
+	settled  bool
+	done     func(error)
+	finished chan struct{}
+	mu       sync.Mutex
+}
+
+// hold registers another copy that has to be handled
+func (d *delivery) hold() {
+	d.mu.Lock()
+	d.pending++
+	d.mu.Unlock()
+}
+
+// release reports the outcome of one copy
+func (d *delivery) release(err error) {
+	d.mu.Lock()
+	d.pending--
+	complete := err != nil || d.pending <= 0
+	d.mu.Unlock()
+
+	if complete {
+		d.settle(err)
+	}
+}
+
+// settle completes the delivery; only the first call has an effect
+func (d *delivery) settle(err error) {
+	d.mu.Lock()
+	if d.settled {
+		d.mu.Unlock()
+		return
+	}
+	d.settled = true
+	d.mu.Unlock()
+
+	close(d.finished)
+	d.done(err)
+}
+
+// deliver passes a message to a target, holding the message's delivery open
+// while the target handles it
+func deliver(target NodeInstance, msg *Message) error {
+	d := msg.delivery
+	if d == nil {
+		return onMessage(target, msg, 0)
+	}
+
+	d.hold()
+	err := onMessage(target, msg, 0)
+	d.release(err)
+	return err
+}
+
+// SendWithAck sends a message from a source node and reports the outcome to
+// done exactly once. In flows with at-least-once delivery, done is called
+// when every copy of the message, including copies retained by buffering
+// nodes, has been handled: with nil if all succeeded, otherwise with the
+// first error or ErrAckTimeout. In other flows done receives the result of
+// Send as soon as it returns.
+func (n *Node) SendWithAck(msg *Message, port int, done func(error)) {
+	n.withAck(msg, done, func() error {
+		return n.Send(msg, port)
+	})
+}
+
+// withAck runs fn, which passes msg on, as the first hold of a new delivery
+// reporting to done
+func (n *Node) withAck(msg *Message, done func(error), fn func() error) {
+	if n.flow == nil || !n.flow.AtLeastOnce() {
+		done(fn())
+		return
+	}
+
+	d := &delivery{pending: 1, done: done, finished: make(chan struct{})}
+	msg.delivery = d
+
+	timer := n.Clock().NewTimer(n.flow.ackTimeout)
+	go func() {
+		select {
+		case <-timer.C():
+			d.settle(ErrAckTimeout)
+		case <-d.finished:
+			timer.Stop()
+		}
+	}()
+
+	// The source's own hold ends when fn returned
+	d.release(fn())
+}
+
+// Retain keeps the message's delivery open after OnMessage returns, for
+// nodes that hold on to messages and act on them later, such as
+// aggregations and batches. The returned function must be called once with
+// the outcome; it does nothing for messages without delivery tracking.
+func (m *Message) Retain() func(error) {
+	d := m.delivery
+	if d == nil {
+		return func(error) {}
+	}
+
+	d.hold()
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() { d.release(err) })
+	}
+}
@@ -0,0 +1,286 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// DeploymentType selects what a deploy restarts, like the deployment types
+// of Node-RED
+type DeploymentType string
+
+const (
+	// DeployFull stops the flow and starts its new version
+	DeployFull DeploymentType = "full"
+	// DeployNodes keeps the nodes that did not change running, and only
+	// stops, creates and starts the nodes that changed
+	DeployNodes DeploymentType = "nodes"
+	// DeployFlows leaves the flow as it is if its definition did not
+	// change, and deploys it in full otherwise
+	DeployFlows DeploymentType = "flows"
+)
+
+// ParseDeploymentType parses a deployment type; empty means DeployFull
+func ParseDeploymentType(s string) (DeploymentType, error) {
+	switch deploymentType := DeploymentType(s); deploymentType {
+	case "":
+		return DeployFull, nil
+	case DeployFull, DeployNodes, DeployFlows:
+		return deploymentType, nil
+	}
+	return "", fmt.Errorf("unknown deployment type: %s", s)
+}
+
+// flowSettings are the settings of a flow that apply to all its nodes; a
+// flow whose settings change is deployed in full
+type flowSettings struct {
+	Delivery   string            `json:"delivery"`
+	AckTimeout string            `json:"ackTimeout"`
+	Target     map[string]string `json:"target"`
+	Schedule   *Schedule         `json:"schedule"`
+	Disabled   bool              `json:"disabled"`
+	Limits     *FlowLimits       `json:"limits"`
+}
+
+// deployChanges deploys a new version of an existing flow without
+// restarting what did not change, and reports whether it did. Otherwise
+// the flow is to be deployed in full. e.mu must be held.
+func (e *Engine) deployChanges(id string, existing *Flow, flowDef []byte, deploymentType DeploymentType) (bool, error) {
+	prepared, err := e.withCredentials(id, flowDef)
+	if err != nil {
+		return false, err
+	}
+	existing.mu.RLock()
+	current, running := existing.definition, existing.status == FlowStatusRunning
+	existing.mu.RUnlock()
+
+	switch deploymentType {
+	case DeployFlows:
+		return sameFlowDefinition(current, prepared), nil
+	case DeployNodes:
+		if !running || existing.Remote() || existing.Scheduled() || !sameFlowSettings(current, prepared) {
+			return false, nil
+		}
+	default:
+		return false, nil
+	}
+
+	// Like deployFlow, save the new version only once it is built, so that
+	// a version that fails to build leaves the running one as it is
+	next, err := NewFlow(id, prepared, e)
+	if err != nil {
+		return true, fmt.Errorf("failed to create flow: %w", err)
+	}
+	if _, err := e.saveFlow(id, flowDef); err != nil {
+		return true, err
+	}
+	next.updated = e.clock.Now()
+	return true, existing.update(e.ctx, next)
+}
+
+// update changes a running flow into next, a new version of it. Nodes
+// whose definition did not change keep running, and only take the name
+// and position of their new version; the others are stopped, and their
+// new versions started. Then all nodes are wired anew.
+func (f *Flow) update(ctx context.Context, next *Flow) error {
+	next.inheritRetained(f, f.retainedMessages())
+
+	f.mu.Lock()
+	kept := make(map[string]bool)
+	for id, node := range next.Nodes {
+		if old, exists := f.Nodes[id]; exists && old.sameDefinition(node) {
+			kept[id] = true
+		}
+	}
+	// Nodes also restart with the nodes they depend on, such as the config
+	// nodes they reference
+	for changed := true; changed; {
+		changed = false
+		for id := range kept {
+			for _, dependency := range next.Nodes[id].after {
+				if !kept[dependency] {
+					delete(kept, id)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	// Stop the nodes that changed or were removed, from the sources
+	// downstream like stopNodes
+	for i := len(f.order) - 1; i >= 0; i-- {
+		if node := f.order[i]; !kept[node.ID] {
+			node.Stop()
+			node.ClearRetained()
+		}
+	}
+
+	nodes := make(map[string]*Node, len(next.Nodes))
+	for id, node := range next.Nodes {
+		if !kept[id] {
+			node.flow = f
+			nodes[id] = node
+			continue
+		}
+		old := f.Nodes[id]
+		old.mu.Lock()
+		old.Name, old.Position = node.Name, node.Position
+		old.mu.Unlock()
+		nodes[id] = old
+	}
+	order := make([]*Node, len(next.order))
+	for i, node := range next.order {
+		order[i] = nodes[node.ID]
+	}
+
+	f.Name, f.Description = next.Name, next.Description
+	f.Nodes, f.Wires, f.order = nodes, next.Wires, order
+	f.wires, f.connections, f.virtual = next.wires, next.connections, next.virtual
+	f.groups, f.missing, f.loops = next.groups, next.missing, next.loops
+	f.subflows, f.instances, f.expanded = next.subflows, next.instances, next.expanded
+	f.definition, f.updated = next.definition, next.updated
+	f.rewire()
+	f.markSources()
+
+	for _, node := range f.order {
+		if kept[node.ID] {
+			continue
+		}
+		err := node.Start(ctx)
+		if err == nil {
+			err = node.waitReady(ctx)
+		}
+		if err != nil {
+			// Like Start, do not leave the flow half running
+			for i := len(f.order) - 1; i >= 0; i-- {
+				f.order[i].Stop()
+			}
+			err = f.startError(node, err)
+			event := f.setStatus(FlowStatusError, err.Error(), node.ID)
+			f.mu.Unlock()
+			f.publish(event)
+			return err
+		}
+	}
+	f.mu.Unlock()
+
+	f.replayRetained(next.replay)
+	return nil
+}
+
+// rewire replaces the wires of the flow's nodes by its connections. The
+// wires of each node are replaced at once, so that a running node sends
+// either on its old wires or on its new ones. Wires keep the messages they
+// skipped while disabled.
+func (f *Flow) rewire() {
+	wires := make(map[string][][]NodeInstance)
+	for _, wire := range f.connections {
+		ports := wires[wire.Source]
+		for len(ports) <= wire.Port {
+			ports = append(ports, make([]NodeInstance, 0))
+		}
+		ports[wire.Port] = append(ports[wire.Port], f.Nodes[wire.Target].receiver())
+		wires[wire.Source] = ports
+	}
+
+	for id, node := range f.Nodes {
+		node.mu.Lock()
+		states := make(map[wireEnd]*wireState)
+		for _, wire := range f.connections {
+			if wire.Source != id {
+				continue
+			}
+			end := wireEnd{wire.Port, wire.Target}
+			state := node.wireStates[end]
+			if state == nil && !wire.Disabled {
+				continue
+			}
+			if state == nil {
+				state = &wireState{}
+			}
+			state.disabled = wire.Disabled
+			states[end] = state
+		}
+		node.wires = wires[id]
+		if node.wires == nil {
+			node.wires = make([][]NodeInstance, 0)
+		}
+		node.wireStates = states
+		node.mu.Unlock()
+	}
+}
+
+// definition returns the node's definition
+func (n *Node) definition() NodeDefinition {
+	def := NodeDefinition{
+		ID:         n.ID,
+		Type:       n.Type.Name,
+		Name:       n.Name,
+		Config:     n.Config,
+		Position:   n.Position,
+		Queue:      n.queue,
+		Retain:     n.retain,
+		Priority:   string(n.priority),
+		StartAfter: n.startAfter,
+	}
+	if n.parallel != nil {
+		def.Instances = len(n.parallel.instances)
+		def.Distribute = n.parallel.distribute
+	}
+	return def
+}
+
+// sameDefinition reports whether two versions of a node only differ in
+// their name and position
+func (n *Node) sameDefinition(other *Node) bool {
+	if n.Type != other.Type {
+		return false
+	}
+	a, b := n.definition(), other.definition()
+	a.Name, a.Position, b.Name, b.Position = "", Position{}, "", Position{}
+	return sameJSON(a, b)
+}
+
+// sameFlowDefinition reports whether two flow definitions define the same
+// flow, however they are formatted
+func sameFlowDefinition(a, b []byte) bool {
+	var defA, defB FlowDefinition
+	if json.Unmarshal(a, &defA) != nil || json.Unmarshal(b, &defB) != nil {
+		return false
+	}
+	return sameJSON(defA, defB)
+}
+
+// sameFlowSettings reports whether two flow definitions have the same
+// flowSettings
+func sameFlowSettings(a, b []byte) bool {
+	var settingsA, settingsB flowSettings
+	if json.Unmarshal(a, &settingsA) != nil || json.Unmarshal(b, &settingsB) != nil {
+		return false
+	}
+	if settingsA.Delivery == "" {
+		settingsA.Delivery = DeliveryAtMostOnce
+	}
+	if settingsB.Delivery == "" {
+		settingsB.Delivery = DeliveryAtMostOnce
+	}
+	return sameJSON(settingsA, settingsB)
+}
+
+// sameJSON reports whether two values have equal JSON encodings, ignoring
+// the formatting and key order of raw JSON they hold
+func sameJSON(a, b interface{}) bool {
+	dataA, errA := json.Marshal(a)
+	dataB, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	var valueA, valueB interface{}
+	if DecodeJSON(dataA, &valueA) != nil || DecodeJSON(dataB, &valueB) != nil {
+		return false
+	}
+	return reflect.DeepEqual(valueA, valueB)
+}
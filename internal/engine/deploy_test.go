@@ -0,0 +1,251 @@
+package engine_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/registry"
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// initNode fails to initialize when its config says so
+type initNode struct {
+	engine.BaseNode
+}
+
+func (n *initNode) Init(config json.RawMessage) error {
+	var c struct {
+		Fail bool `json:"fail"`
+	}
+	if err := n.LoadConfig(config, &c); err != nil {
+		return err
+	}
+	if c.Fail {
+		return errors.New("init failed")
+	}
+	return nil
+}
+
+func (n *initNode) Start(ctx context.Context) error               { return nil }
+func (n *initNode) Stop()                                         {}
+func (n *initNode) OnMessage(msg *engine.Message, port int) error { return nil }
+
+func TestFailedDeployKeepsRunningFlow(t *testing.T) {
+	for _, deploymentType := range []engine.DeploymentType{engine.DeployFull, engine.DeployNodes} {
+		t.Run(string(deploymentType), func(t *testing.T) {
+			testFailedDeployKeepsRunningFlow(t, deploymentType)
+		})
+	}
+}
+
+// testFailedDeployKeepsRunningFlow deploys a flow whose node fails to
+// initialize over a running one, as deploymentType
+func testFailedDeployKeepsRunningFlow(t *testing.T, deploymentType engine.DeploymentType) {
+	reg := registry.New()
+	if err := reg.RegisterNodeType(&engine.NodeType{
+		Name:    "init",
+		Factory: func() engine.NodeInstance { return &initNode{} },
+	}); err != nil {
+		t.Fatal(err)
+	}
+	store := storage.NewMemoryStorage()
+	eng := engine.New(reg, store)
+	if err := eng.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer eng.Stop()
+
+	good := []byte(`{"id": "f", "nodes": [{"id": "n", "type": "init", "config": {}}]}`)
+	if err := eng.DeployFlow("f", good); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := eng.GetFlow("f")
+	revision, err := before.Revision()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, err := store.LoadFlow("f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions, err := eng.FlowVersions("f")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bad := []byte(`{"id": "f", "nodes": [{"id": "n", "type": "init", "config": {"fail": true}}]}`)
+	if err := eng.DeployFlowAt("f", bad, deploymentType, revision); err == nil {
+		t.Fatal("deploying a flow that fails to initialize succeeded")
+	}
+
+	after, _ := eng.GetFlow("f")
+	if after != before {
+		t.Error("failed deploy replaced the flow")
+	}
+	if status := after.GetStatus(); status != engine.FlowStatusRunning {
+		t.Errorf("flow is %s after a failed deploy, want running", status)
+	}
+	if current, _ := after.Revision(); current != revision {
+		t.Errorf("revision changed from %s to %s", revision, current)
+	}
+	if stored, _ := store.LoadFlow("f"); string(stored) != string(saved) {
+		t.Error("failed deploy was saved")
+	}
+	if after, _ := eng.FlowVersions("f"); len(after) != len(versions) {
+		t.Errorf("failed deploy added a version: %d versions, had %d", len(after), len(versions))
+	}
+}
+
+// recordNode records the payloads it receives
+type recordNode struct {
+	engine.BaseNode
+	received []interface{}
+}
+
+func (n *recordNode) Init(config json.RawMessage) error { return nil }
+func (n *recordNode) Start(ctx context.Context) error   { return nil }
+func (n *recordNode) Stop()                             {}
+
+func (n *recordNode) OnMessage(msg *engine.Message, port int) error {
+	n.received = append(n.received, msg.Payload)
+	return nil
+}
+
+// deployNode is a node of a flow deployed in the partial deploy tests
+type deployNode struct {
+	id, name, config string
+	x                float64
+}
+
+// partialFlow returns the definition of a flow of record nodes, with wires
+// given as "source>target"
+func partialFlow(nodes []deployNode, wires ...string) []byte {
+	var defs, wireDefs []string
+	for _, node := range nodes {
+		defs = append(defs, fmt.Sprintf(`{"id": %q, "type": "record", "name": %q, "config": {"value": %q}, "position": {"x": %g, "y": 0}}`,
+			node.id, node.name, node.config, node.x))
+	}
+	for _, wire := range wires {
+		source, target, _ := strings.Cut(wire, ">")
+		wireDefs = append(wireDefs, fmt.Sprintf(`{"source": %q, "target": %q, "port": 0}`, source, target))
+	}
+	return []byte(fmt.Sprintf(`{"id": "partial", "nodes": [%s], "wires": [%s]}`, strings.Join(defs, ", "), strings.Join(wireDefs, ", ")))
+}
+
+func TestPartialDeploy(t *testing.T) {
+	base := []deployNode{{"a", "source", "1", 0}, {"b", "first", "1", 100}, {"c", "second", "1", 200}}
+	renamed := []deployNode{{"a", "source", "1", 0}, {"b", "renamed", "1", 150}, {"c", "second", "1", 200}}
+	reconfigured := []deployNode{{"a", "source", "1", 0}, {"b", "first", "2", 100}, {"c", "second", "1", 200}}
+
+	tests := []struct {
+		name           string
+		deploymentType engine.DeploymentType
+		next           []byte
+		// restarted are the nodes given new instances
+		restarted []string
+		// receivers are the nodes a message sent by a reaches
+		receivers []string
+	}{
+		{"rename only", engine.DeployNodes, partialFlow(renamed, "a>b"), nil, []string{"b"}},
+		{"wire only", engine.DeployNodes, partialFlow(base, "a>c"), nil, []string{"c"}},
+		{"wire added", engine.DeployNodes, partialFlow(base, "a>b", "a>c"), nil, []string{"b", "c"}},
+		{"config changed", engine.DeployNodes, partialFlow(reconfigured, "a>b"), []string{"b"}, []string{"b"}},
+		{"node removed", engine.DeployNodes, partialFlow(base[:2], "a>b"), nil, []string{"b"}},
+		{"unchanged flow", engine.DeployFlows, partialFlow(base, "a>b"), nil, []string{"b"}},
+		{"rename of a flow", engine.DeployFlows, partialFlow(renamed, "a>b"), []string{"a", "b", "c"}, []string{"b"}},
+		{"rename in full", engine.DeployFull, partialFlow(renamed, "a>b"), []string{"a", "b", "c"}, []string{"b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := registry.New()
+			var created []*recordNode
+			if err := reg.RegisterNodeType(&engine.NodeType{
+				Name: "record",
+				Factory: func() engine.NodeInstance {
+					instance := &recordNode{}
+					created = append(created, instance)
+					return instance
+				},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			eng := engine.New(reg, storage.NewMemoryStorage())
+			if err := eng.Start(); err != nil {
+				t.Fatal(err)
+			}
+			defer eng.Stop()
+
+			// instances returns the running instance of each node
+			instances := func() map[string]*recordNode {
+				flow, _ := eng.GetFlow("partial")
+				running := make(map[string]*recordNode)
+				for _, instance := range created {
+					if node, ok := flow.GetNode(instance.GetNode().ID); ok && node == instance.GetNode() {
+						running[node.ID] = instance
+					}
+				}
+				return running
+			}
+
+			if err := eng.DeployFlow("partial", partialFlow(base, "a>b")); err != nil {
+				t.Fatal(err)
+			}
+			before := instances()
+			if err := eng.DeployFlowAs("partial", tt.next, tt.deploymentType); err != nil {
+				t.Fatal(err)
+			}
+			after := instances()
+
+			var restarted []string
+			for id, instance := range after {
+				if before[id] != instance {
+					restarted = append(restarted, id)
+				}
+			}
+			sort.Strings(restarted)
+			if strings.Join(restarted, ",") != strings.Join(tt.restarted, ",") {
+				t.Errorf("restarted %v, want %v", restarted, tt.restarted)
+			}
+
+			// Names and positions are those of the new version, and
+			// messages take the new wires
+			flow, _ := eng.GetFlow("partial")
+			var def engine.FlowDefinition
+			if err := json.Unmarshal(tt.next, &def); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range def.Nodes {
+				node, ok := flow.GetNode(want.ID)
+				if !ok {
+					t.Fatalf("node %s missing", want.ID)
+				}
+				if node.Name != want.Name || node.Position != want.Position {
+					t.Errorf("node %s is %q at %v, want %q at %v", want.ID, node.Name, node.Position, want.Name, want.Position)
+				}
+			}
+			if len(after) != len(def.Nodes) {
+				t.Errorf("%d nodes running, want %d", len(after), len(def.Nodes))
+			}
+			a, _ := flow.GetNode("a")
+			if err := a.Send(engine.NewMessage("ping", ""), 0); err != nil {
+				t.Fatal(err)
+			}
+			var receivers []string
+			for id, instance := range after {
+				if len(instance.received) > 0 {
+					receivers = append(receivers, id)
+				}
+			}
+			sort.Strings(receivers)
+			if strings.Join(receivers, ",") != strings.Join(tt.receivers, ",") {
+				t.Errorf("message reached %v, want %v", receivers, tt.receivers)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package engine
+
+import "fmt"
+
+// FlowStatusRemote is the status of a flow that runs on agents
+const FlowStatusRemote FlowStatus = "remote"
+
+// FlowDispatcher runs flows that have a target selector on remote agents.
+// It is called with the engine lock held and must not call back into the
+// engine.
+type FlowDispatcher interface {
+	// DispatchFlow is called for every flow loaded or deployed; flows
+	// without a target are removed from agents still running them
+	DispatchFlow(id string, flowDef []byte, target map[string]string)
+
+	// RemoveFlow removes a deleted flow from the agents running it
+	RemoveFlow(id string)
+}
+
+// SetDispatcher hands flows with a target selector to a dispatcher instead
+// of running them locally, and dispatches the flows already loaded
+func (e *Engine) SetDispatcher(dispatcher FlowDispatcher) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.dispatcher = dispatcher
+	for id, flow := range e.flows {
+		if flow.Remote() {
+			// Loaded locally before the dispatcher was set
+			flow.Stop()
+		}
+		e.dispatch(id, flow)
+	}
+}
+
+// dispatch passes a flow to the dispatcher, if any; e.mu must be held
+func (e *Engine) dispatch(id string, flow *Flow) {
+	if e.dispatcher != nil {
+		e.dispatcher.DispatchFlow(id, flow.definition, flow.target)
+	}
+}
+
+// undispatch removes a flow from agents, if any; e.mu must be held
+func (e *Engine) undispatch(id string) {
+	if e.dispatcher != nil {
+		e.dispatcher.RemoveFlow(id)
+	}
+}
+
+// Target returns the labels an agent must have to run the flow, or nil if
+// the flow runs locally
+func (f *Flow) Target() map[string]string {
+	target := make(map[string]string, len(f.target))
+	for key, value := range f.target {
+		target[key] = value
+	}
+	if len(target) == 0 {
+		return nil
+	}
+	return target
+}
+
+// Remote reports whether the flow runs on agents rather than this engine
+func (f *Flow) Remote() bool {
+	return len(f.target) > 0 && f.engine.dispatcher != nil
+}
+
+// errRemoteFlow is returned when starting a flow that runs on agents
+func errRemoteFlow(id string) error {
+	return fmt.Errorf("flow %s runs on agents", id)
+}
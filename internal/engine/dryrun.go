@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ValidationReport is the result of checking a flow definition without
+// deploying it
+type ValidationReport struct {
+	Valid    bool              `json:"valid"`
+	Errors   []ValidationIssue `json:"errors"`
+	Warnings []ValidationIssue `json:"warnings"`
+}
+
+// DryRunFlow checks a flow definition the way deploying it under the flow
+// key id would, without saving or starting anything: its structure, the
+// types and configs of its nodes, and what creating the flow checks on top.
+// Node instances that implement Validator are validated instead of
+// initialized. Credentials left out of the definition are taken from those
+// saved for the flow.
+func (e *Engine) DryRunFlow(id string, flowDef []byte) *ValidationReport {
+	report := &ValidationReport{Errors: []ValidationIssue{}, Warnings: []ValidationIssue{}}
+	defer func() {
+		report.Valid = len(report.Errors) == 0
+	}()
+
+	flowDef, err := e.withCredentials(id, flowDef)
+	if err != nil {
+		report.Errors = append(report.Errors, ValidationIssue{Message: err.Error()})
+		return report
+	}
+	var def FlowDefinition
+	if err := json.Unmarshal(flowDef, &def); err != nil {
+		report.Errors = append(report.Errors, ValidationIssue{Message: fmt.Sprintf("invalid flow definition: %v", err)})
+		return report
+	}
+
+	warnings, err := ValidateFlow(&def)
+	report.Warnings = append(report.Warnings, warnings...)
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		report.Errors = append(report.Errors, invalid.Issues...)
+	}
+	e.checkNodes(id, &def, report)
+	for i := range report.Errors {
+		report.Errors[i].Path = nodePath(&def, report.Errors[i])
+	}
+	for i := range report.Warnings {
+		report.Warnings[i].Path = nodePath(&def, report.Warnings[i])
+	}
+	if len(report.Errors) > 0 {
+		return report
+	}
+
+	// Creating the flow finds the remaining problems, such as loops or
+	// invalid subflow instances, one at a time
+	if _, err := newFlow(id, flowDef, e, true); err != nil {
+		if errors.As(err, &invalid) {
+			report.Errors = append(report.Errors, invalid.Issues...)
+		} else {
+			report.Errors = append(report.Errors, ValidationIssue{Message: err.Error()})
+		}
+	}
+	return report
+}
+
+// checkNodes checks the types and configs of a flow's nodes, all of them
+// rather than up to the first problem like newFlow. The nodes of subflows
+// are checked when instances are created, with their env applied.
+func (e *Engine) checkNodes(id string, def *FlowDefinition, report *ValidationReport) {
+	_, flowID := SplitFlowKey(id)
+	flow := &Flow{ID: flowID, engine: e, dryRun: true}
+	disabled := disabledNodes(def)
+	for i, nodeDef := range def.Nodes {
+		if disabled[nodeDef.ID] || strings.HasPrefix(nodeDef.Type, SubflowTypePrefix) {
+			continue
+		}
+		path := fmt.Sprintf("nodes[%d]", i)
+		nodeType, err := e.GetRegistry().GetNodeType(nodeDef.Type)
+		if err != nil {
+			issue := ValidationIssue{NodeID: nodeDef.ID, Path: path + ".type", Message: fmt.Sprintf("unknown node type: %s", nodeDef.Type)}
+			if e.AllowUnknownTypes() {
+				report.Warnings = append(report.Warnings, issue)
+			} else {
+				report.Errors = append(report.Errors, issue)
+			}
+			continue
+		}
+		if nodeType.Virtual {
+			continue
+		}
+
+		node, err := NewNode(nodeDef.ID, nodeDef.Name, nodeType, nodeDef.Config, flow)
+		var config *ConfigError
+		switch {
+		case errors.As(err, &config):
+			for _, field := range config.Fields {
+				report.Errors = append(report.Errors, ValidationIssue{
+					NodeID:  nodeDef.ID,
+					Path:    path + ".config." + field.Path,
+					Message: field.Message,
+				})
+			}
+		case err != nil:
+			report.Errors = append(report.Errors, ValidationIssue{NodeID: nodeDef.ID, Path: path + ".config", Message: err.Error()})
+		default:
+			for _, warning := range node.Warnings() {
+				warning.Path = path + ".config"
+				report.Warnings = append(report.Warnings, warning)
+			}
+		}
+	}
+}
+
+// nodePath returns the path of an issue, or that of the node it concerns
+// if its path is not known
+func nodePath(def *FlowDefinition, issue ValidationIssue) string {
+	if issue.Path != "" || issue.NodeID == "" {
+		return issue.Path
+	}
+	for i, nodeDef := range def.Nodes {
+		if nodeDef.ID == issue.NodeID {
+			return fmt.Sprintf("nodes[%d]", i)
+		}
+	}
+	return ""
+}
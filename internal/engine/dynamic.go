@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EventNodeConfig is published when a node's configuration is changed at
+// runtime
+const EventNodeConfig = "node-config"
+
+// ConfigUpdater is implemented by node instances whose type declares
+// dynamic config fields. UpdateConfig receives a JSON object holding only
+// dynamic fields and applies it while the node keeps running.
+type ConfigUpdater interface {
+	UpdateConfig(partial json.RawMessage) error
+}
+
+// DynamicFieldsError is returned when a config patch contains fields that
+// cannot be changed at runtime
+type DynamicFieldsError struct {
+	NodeID string
+	Fields []string
+}
+
+func (e *DynamicFieldsError) Error() string {
+	return fmt.Sprintf("node %s: fields cannot be changed at runtime: %s", e.NodeID, strings.Join(e.Fields, ", "))
+}
+
+// UpdateNodeConfig changes dynamic config fields of a node without
+// redeploying its flow. The patch is a JSON object of fields to replace;
+// fields the node type does not declare dynamic are rejected with a
+// *DynamicFieldsError. The merged configuration is saved to storage.
+func (e *Engine) UpdateNodeConfig(flowID, nodeID string, patch json.RawMessage) error {
+	flow, exists := e.GetFlow(flowID)
+	if !exists {
+		return fmt.Errorf("flow %s not found", flowID)
+	}
+	if flow.Remote() {
+		return errRemoteFlow(flowID)
+	}
+	node, exists := flow.GetNode(nodeID)
+	if !exists {
+		return fmt.Errorf("node %s not found", nodeID)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &fields); err != nil {
+		return fmt.Errorf("config patch must be a JSON object: %w", err)
+	}
+	if err := node.checkDynamic(fields); err != nil {
+		return err
+	}
+
+	if err := node.updateConfig(patch, fields); err != nil {
+		return err
+	}
+
+	if err := e.saveDefinition(flowID, flow); err != nil {
+		return err
+	}
+
+	node.PublishEvent(EventNodeConfig, fields)
+	return nil
+}
+
+// checkDynamic rejects fields the node type does not declare dynamic
+func (n *Node) checkDynamic(fields map[string]json.RawMessage) error {
+	dynamic := make(map[string]bool, len(n.Type.Dynamic))
+	for _, field := range n.Type.Dynamic {
+		dynamic[field] = true
+	}
+	var rejected []string
+	for field := range fields {
+		if !dynamic[field] {
+			rejected = append(rejected, field)
+		}
+	}
+	if len(rejected) > 0 {
+		sort.Strings(rejected)
+		return &DynamicFieldsError{NodeID: n.ID, Fields: rejected}
+	}
+	if _, ok := n.instance.(ConfigUpdater); !ok {
+		return fmt.Errorf("node %s does not support config updates", n.ID)
+	}
+	return nil
+}
+
+// updateConfig applies a patch to the node's instances and merges it into
+// the node's configuration
+func (n *Node) updateConfig(patch json.RawMessage, fields map[string]json.RawMessage) error {
+	instances := []NodeInstance{n.instance}
+	if n.parallel != nil {
+		instances = n.parallel.instances
+	}
+	for _, instance := range instances {
+		if err := instance.(ConfigUpdater).UpdateConfig(patch); err != nil {
+			return err
+		}
+	}
+
+	// Flow.ToJSON reads node configs under the flow lock
+	if n.flow != nil {
+		n.flow.mu.Lock()
+		defer n.flow.mu.Unlock()
+	}
+	config := make(map[string]json.RawMessage)
+	if len(n.Config) > 0 {
+		if err := json.Unmarshal(n.Config, &config); err != nil {
+			return fmt.Errorf("node %s: invalid config: %w", n.ID, err)
+		}
+	}
+	for field, value := range fields {
+		config[field] = value
+	}
+	merged, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	n.Config = merged
+	return nil
+}
@@ -0,0 +1,258 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// typedValue is a value tagged with its Go type, so that encoded messages
+// decode to the same types. Plain JSON turns []byte into a base64 string and
+// every number into float64.
+type typedValue struct {
+	T string          `json:"t"`
+	V json.RawMessage `json:"v,omitempty"`
+}
+
+// binaryMessage is the encoded form of a Message
+type binaryMessage struct {
+	Payload   typedValue            `json:"payload"`
+	Topic     string                `json:"topic"`
+	Headers   map[string]string     `json:"headers"`
+	Metadata  map[string]typedValue `json:"metadata"`
+	SourceID  string                `json:"sourceId"`
+	MsgID     string                `json:"msgId"`
+	Timestamp time.Time             `json:"timestamp"`
+	Priority  Priority              `json:"priority,omitempty"`
+}
+
+// MarshalBinary encodes the message so that payload and metadata types
+// survive a round trip, e.g. for persistent queues
+func (m *Message) MarshalBinary() ([]byte, error) {
+	payload, err := encodeValue(m.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	metadata := make(map[string]typedValue, len(m.Metadata))
+	for key, value := range m.Metadata {
+		if metadata[key], err = encodeValue(value); err != nil {
+			return nil, fmt.Errorf("failed to encode metadata %s: %w", key, err)
+		}
+	}
+
+	return json.Marshal(binaryMessage{
+		Payload:   payload,
+		Topic:     m.Topic,
+		Headers:   m.Headers,
+		Metadata:  metadata,
+		SourceID:  m.SourceID,
+		MsgID:     m.MsgID,
+		Timestamp: m.Timestamp,
+		Priority:  m.Priority,
+	})
+}
+
+// UnmarshalBinary decodes a message encoded by MarshalBinary
+func (m *Message) UnmarshalBinary(data []byte) error {
+	var encoded binaryMessage
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+
+	payload, err := decodeValue(encoded.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to decode payload: %w", err)
+	}
+
+	metadata := make(map[string]interface{}, len(encoded.Metadata))
+	for key, value := range encoded.Metadata {
+		if metadata[key], err = decodeValue(value); err != nil {
+			return fmt.Errorf("failed to decode metadata %s: %w", key, err)
+		}
+	}
+
+	headers := encoded.Headers
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+
+	*m = Message{
+		Payload:   payload,
+		Topic:     encoded.Topic,
+		Headers:   headers,
+		Metadata:  metadata,
+		SourceID:  encoded.SourceID,
+		MsgID:     encoded.MsgID,
+		Timestamp: encoded.Timestamp,
+		Priority:  encoded.Priority,
+	}
+	return nil
+}
+
+// encodeValue tags a value with its type; unknown types are stored as JSON
+// and decode as generic JSON values
+func encodeValue(value interface{}) (typedValue, error) {
+	var t string
+	var v interface{} = value
+
+	switch x := value.(type) {
+	case nil:
+		return typedValue{T: "null"}, nil
+	case bool:
+		t = "bool"
+	case string:
+		t = "string"
+	case []byte:
+		t = "bytes"
+	case int:
+		t = "int"
+	case int32:
+		t = "int32"
+	case int64:
+		t = "int64"
+	case uint:
+		t = "uint"
+	case uint32:
+		t = "uint32"
+	case uint64:
+		t = "uint64"
+	case float32:
+		t = "float32"
+	case float64:
+		t = "float64"
+	case time.Time:
+		t = "time"
+	case json.RawMessage:
+		t = "json"
+	case []string:
+		t = "strings"
+	case map[string]string:
+		t = "stringmap"
+	case *Stream:
+		return typedValue{}, fmt.Errorf("stream payloads cannot be encoded")
+	case []interface{}:
+		items := make([]typedValue, len(x))
+		for i, item := range x {
+			encoded, err := encodeValue(item)
+			if err != nil {
+				return typedValue{}, err
+			}
+			items[i] = encoded
+		}
+		t, v = "list", items
+	case map[string]interface{}:
+		fields := make(map[string]typedValue, len(x))
+		for key, item := range x {
+			encoded, err := encodeValue(item)
+			if err != nil {
+				return typedValue{}, err
+			}
+			fields[key] = encoded
+		}
+		t, v = "map", fields
+	default:
+		t = "json"
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return typedValue{}, err
+	}
+	return typedValue{T: t, V: data}, nil
+}
+
+// decodeValue restores a value encoded by encodeValue
+func decodeValue(value typedValue) (interface{}, error) {
+	var err error
+	switch value.T {
+	case "null":
+		return nil, nil
+	case "bool":
+		var v bool
+		err = json.Unmarshal(value.V, &v)
+		return v, err
+	case "string":
+		var v string
+		err = json.Unmarshal(value.V, &v)
+		return v, err
+	case "bytes":
+		var v []byte
+		err = json.Unmarshal(value.V, &v)
+		return v, err
+	case "int":
+		var v int
+		err = json.Unmarshal(value.V, &v)
+		return v, err
+	case "int32":
+		var v int32
+		err = json.Unmarshal(value.V, &v)
+		return v, err
+	case "int64":
+		var v int64
+		err = json.Unmarshal(value.V, &v)
+		return v, err
+	case "uint":
+		var v uint
+		err = json.Unmarshal(value.V, &v)
+		return v, err
+	case "uint32":
+		var v uint32
+		err = json.Unmarshal(value.V, &v)
+		return v, err
+	case "uint64":
+		var v uint64
+		err = json.Unmarshal(value.V, &v)
+		return v, err
+	case "float32":
+		var v float32
+		err = json.Unmarshal(value.V, &v)
+		return v, err
+	case "float64":
+		var v float64
+		err = json.Unmarshal(value.V, &v)
+		return v, err
+	case "time":
+		var v time.Time
+		err = json.Unmarshal(value.V, &v)
+		return v, err
+	case "strings":
+		var v []string
+		err = json.Unmarshal(value.V, &v)
+		return v, err
+	case "stringmap":
+		var v map[string]string
+		err = json.Unmarshal(value.V, &v)
+		return v, err
+	case "list":
+		var items []typedValue
+		if err := json.Unmarshal(value.V, &items); err != nil {
+			return nil, err
+		}
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			if list[i], err = decodeValue(item); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case "map":
+		var fields map[string]typedValue
+		if err := json.Unmarshal(value.V, &fields); err != nil {
+			return nil, err
+		}
+		m := make(map[string]interface{}, len(fields))
+		for key, item := range fields {
+			if m[key], err = decodeValue(item); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case "json":
+		var v interface{}
+		err = DecodeJSON(value.V, &v)
+		return v, err
+	default:
+		return nil, fmt.Errorf("unknown value type %q", value.T)
+	}
+}
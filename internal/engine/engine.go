@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
 	"sync"
+	"sync/atomic"
 
 	"github.com/yourusername/go-red/internal/registry"
 	"github.com/yourusername/go-red/internal/storage"
@@ -13,13 +15,53 @@ import (
 
 // Engine represents the flow execution engine
 type Engine struct {
-	registry *registry.Registry
-	storage  storage.Storage
-	flows    map[string]*Flow
-	status   Status
-	ctx      context.Context
-	cancel   context.CancelFunc
-	mu       sync.RWMutex
+	registry     *registry.Registry
+	storage      storage.Storage
+	flows        map[string]*Flow
+	quarantined  map[string]*QuarantinedFlow
+	startup      StartupReport
+	status       Status
+	clock        Clock
+	httpRoutes   *HTTPRoutes
+	httpRequests *HTTPRequests
+	correlations *Correlations
+	locks        *FlowLocks
+	workspaces   storage.WorkspaceStore
+	states       storage.StateStore
+	hooks        *Hooks
+	network      *Network
+	contexts     *contexts
+	credentials  *credentials
+	versions     storage.VersionStore
+	library      storage.LibraryStore
+	// panicThreshold is accessed atomically
+	panicThreshold int32
+	// readyTimeout is a time.Duration, accessed atomically
+	readyTimeout int64
+	// allowUnknown is 1 if flows may contain unknown node types, accessed
+	// atomically
+	allowUnknown int32
+	// copyOnWrite is 1 if messages to single targets are not copied,
+	// accessed atomically
+	copyOnWrite int32
+	// allowLoops is 1 if flows may contain loops; loopTTL bounds the sends
+	// of their messages; both are accessed atomically
+	allowLoops int32
+	loopTTL    int32
+	// versionRetention is the number of versions kept per flow, accessed
+	// atomically
+	versionRetention int32
+	// flowLimits holds the FlowLimits of flows without their own
+	flowLimits atomic.Value
+	// observer holds an observerHolder
+	observer atomic.Value
+	// logging holds the handler and levels of the engine's loggers
+	logging    *logging
+	events     *EventBus
+	dispatcher FlowDispatcher
+	ctx        context.Context
+	cancel     context.CancelFunc
+	mu         sync.RWMutex
 }
 
 // Status represents the engine status
@@ -34,14 +76,41 @@ const (
 // New creates a new Engine instance
 func New(reg *registry.Registry, store storage.Storage) *Engine {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &Engine{
-		registry: reg,
-		storage:  store,
-		flows:    make(map[string]*Flow),
-		status:   StatusStopped,
-		ctx:      ctx,
-		cancel:   cancel,
+	logs := &logging{}
+	e := &Engine{
+		registry:         reg,
+		storage:          store,
+		flows:            make(map[string]*Flow),
+		quarantined:      make(map[string]*QuarantinedFlow),
+		status:           StatusStopped,
+		clock:            SystemClock{},
+		httpRoutes:       NewHTTPRoutes(),
+		httpRequests:     NewHTTPRequests(),
+		events:           NewEventBus(),
+		network:          newNetwork(),
+		contexts:         newContexts(store, slog.New(&levelHandler{logging: logs})),
+		hooks:            newHooks(),
+		logging:          logs,
+		panicThreshold:   defaultPanicThreshold,
+		readyTimeout:     int64(defaultReadyTimeout),
+		versionRetention: DefaultVersionRetention,
+		ctx:              ctx,
+		cancel:           cancel,
 	}
+	e.flowLimits.Store(FlowLimits{})
+	e.correlations = newCorrelations(e)
+	e.locks = newFlowLocks(e, store)
+	e.workspaces = newWorkspaceStore(store)
+	e.states = newStateStore(store)
+	e.credentials = newCredentials(store, e.Logger())
+	e.versions = newVersionStore(store)
+	e.library = newLibraryStore(store)
+	for _, hook := range reg.GetHooks() {
+		if err := e.hooks.Register(hook.Stage, hook.Name, hook.Fn); err != nil {
+			e.Logger().Warn("failed to register hook", "hook", hook.Name, "error", err)
+		}
+	}
+	return e
 }
 
 // Initialize prepares the engine for operation
@@ -49,28 +118,119 @@ func (e *Engine) Initialize() error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	return e.loadFlows()
+}
+
+// Reload replaces all flows with their stored definitions. The engine must
+// be stopped; it is used when an instance becomes cluster leader.
+func (e *Engine) Reload() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.status == StatusRunning {
+		return errors.New("cannot reload a running engine")
+	}
+
+	e.flows = make(map[string]*Flow)
+	return e.loadFlows()
+}
+
+// SyncFlow replaces a flow with its stored definition, or removes it if it
+// was deleted, without writing to storage. A running engine restarts the
+// flow. It is used to follow deploys made by another instance.
+func (e *Engine) SyncFlow(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if existing, exists := e.flows[id]; exists {
+		existing.Stop()
+		delete(e.flows, id)
+	}
+
+	flowDef, err := e.LoadFlow(id)
+	if err != nil {
+		if errors.Is(err, storage.ErrFlowNotFound) || errors.Is(err, os.ErrNotExist) {
+			delete(e.quarantined, id)
+			e.undispatch(id)
+			e.registry.SetSubflows(id, nil)
+			return nil // deleted
+		}
+		return fmt.Errorf("failed to load flow: %w", err)
+	}
+
+	if err := e.registerSubflows(id, flowDef); err != nil {
+		e.quarantine(id, flowDef, err)
+		return err
+	}
+	flow, err := NewFlow(id, flowDef, e)
+	if err != nil {
+		e.quarantine(id, flowDef, err)
+		return fmt.Errorf("failed to create flow: %w", err)
+	}
+	flow.updated = e.savedAt(id)
+	delete(e.quarantined, id)
+	e.flows[id] = flow
+	e.dispatch(id, flow)
+
+	if e.status == StatusRunning && !flow.Remote() {
+		if err := e.runFlow(flow); err != nil {
+			return fmt.Errorf("failed to start flow: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadFlows creates the stored flows, quarantining those that fail to
+// load, and publishes a startup report; e.mu must be held
+func (e *Engine) loadFlows() error {
 	// Load all flows from storage
 	flowIDs, err := e.storage.ListFlows()
 	if err != nil {
 		return fmt.Errorf("failed to list flows: %w", err)
 	}
 
+	e.quarantined = make(map[string]*QuarantinedFlow)
+	defs := make(map[string][]byte, len(flowIDs))
 	for _, id := range flowIDs {
-		flowDef, err := e.storage.LoadFlow(id)
+		flowDef, err := e.LoadFlow(id)
 		if err != nil {
-			log.Printf("Warning: Failed to load flow %s: %v", id, err)
+			e.quarantine(id, nil, fmt.Errorf("failed to load flow: %w", err))
+			continue
+		}
+		// Flows may use the subflows of flows loaded after them
+		if err := e.registerSubflows(id, flowDef); err != nil {
+			e.quarantine(id, flowDef, err)
+			continue
+		}
+		defs[id] = flowDef
+	}
+
+	loaded := 0
+	for _, id := range flowIDs {
+		flowDef, ok := defs[id]
+		if !ok {
 			continue
 		}
 
 		flow, err := NewFlow(id, flowDef, e)
 		if err != nil {
-			log.Printf("Warning: Failed to create flow %s: %v", id, err)
+			e.quarantine(id, flowDef, err)
 			continue
 		}
+		flow.updated = e.savedAt(id)
 
 		e.flows[id] = flow
+		e.dispatch(id, flow)
+		loaded++
 	}
 
+	e.startup = StartupReport{Loaded: loaded, Quarantined: len(e.quarantined), Time: e.clock.Now()}
+	e.Logger().Info("loaded flows", "loaded", e.startup.Loaded, "quarantined", e.startup.Quarantined)
+	e.events.Publish(Event{
+		Type:      EventStartupReport,
+		Timestamp: e.startup.Time,
+		Data:      e.startup,
+	})
 	return nil
 }
 
@@ -84,8 +244,11 @@ func (e *Engine) Start() error {
 	}
 
 	for id, flow := range e.flows {
-		if err := flow.Start(e.ctx); err != nil {
-			log.Printf("Warning: Failed to start flow %s: %v", id, err)
+		if flow.Remote() {
+			continue
+		}
+		if err := e.runFlow(flow); err != nil {
+			flow.Logger().Warn("failed to start flow", "error", err)
 		}
 	}
 
@@ -113,32 +276,185 @@ func (e *Engine) Stop() error {
 	return nil
 }
 
-// DeployFlow deploys a new or updated flow
+// runFlow starts a flow, or its schedule, unless it is disabled; e.mu must
+// be held
+func (e *Engine) runFlow(flow *Flow) error {
+	if flow.Disabled() {
+		return nil
+	}
+	if flow.Scheduled() {
+		flow.startSchedule(e.ctx)
+		return nil
+	}
+	return flow.Start(e.ctx)
+}
+
+// StartFlow starts a stopped flow. A scheduled flow runs regardless of its
+// schedule until the next window boundary.
+func (e *Engine) StartFlow(id string) error {
+	e.mu.RLock()
+	flow, exists := e.flows[id]
+	ctx := e.ctx
+	e.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("flow %s not found", id)
+	}
+	flow.setOverride()
+	return flow.Start(ctx)
+}
+
+// StopFlow stops a flow. A scheduled flow stays stopped until the next
+// window boundary.
+func (e *Engine) StopFlow(id string) error {
+	flow, exists := e.GetFlow(id)
+	if !exists {
+		return fmt.Errorf("flow %s not found", id)
+	}
+	flow.setOverride()
+	flow.stopNodes()
+	return nil
+}
+
+// SetFlowDisabled disables or enables a flow and saves it. Disabling stops
+// the flow; enabling starts it, or its schedule, if the engine is running.
+func (e *Engine) SetFlowDisabled(id string, disabled bool) error {
+	flow, exists := e.GetFlow(id)
+	if !exists {
+		return fmt.Errorf("flow %s not found", id)
+	}
+	if flow.Remote() {
+		return errRemoteFlow(id)
+	}
+
+	flow.mu.Lock()
+	changed := flow.disabled != disabled
+	flow.disabled = disabled
+	flow.mu.Unlock()
+	if !changed {
+		return nil
+	}
+	if err := e.saveDefinition(id, flow); err != nil {
+		return err
+	}
+
+	if disabled {
+		flow.Stop()
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.status != StatusRunning {
+		return nil
+	}
+	return e.runFlow(flow)
+}
+
+// saveDefinition saves a flow changed at runtime under its key and keeps
+// the saved definition as the flow's
+func (e *Engine) saveDefinition(id string, flow *Flow) error {
+	flowDef, err := flow.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to marshal flow: %w", err)
+	}
+	if flowDef, err = e.saveFlow(id, flowDef); err != nil {
+		return err
+	}
+	flow.mu.Lock()
+	flow.definition, flow.updated = flowDef, e.clock.Now()
+	flow.mu.Unlock()
+	return nil
+}
+
+// DeployFlow deploys a new or updated flow in full
 func (e *Engine) DeployFlow(id string, flowDef []byte) error {
+	return e.DeployFlowAs(id, flowDef, DeployFull)
+}
+
+// DeployFlowAs deploys a new or updated flow, restarting what the
+// deployment type selects of an updated flow
+func (e *Engine) DeployFlowAs(id string, flowDef []byte, deploymentType DeploymentType) error {
+	return e.DeployFlowAt(id, flowDef, deploymentType, "")
+}
+
+// DeployFlowAt deploys a flow like DeployFlowAs if it is at the expected
+// revision, or exists for AnyRevision, and returns a *RevisionError
+// otherwise. No other deploy comes between the check and the deploy. An
+// empty revision deploys the flow whatever its revision.
+func (e *Engine) DeployFlowAt(id string, flowDef []byte, deploymentType DeploymentType, revision string) error {
+	return e.deployFlow(id, flowDef, deploymentType, func(existing *Flow) error {
+		return checkRevision(existing, revision)
+	})
+}
+
+// CreateFlowAs deploys a flow like DeployFlowAs unless a flow with the ID
+// exists, and returns ErrFlowExists then
+func (e *Engine) CreateFlowAs(id string, flowDef []byte, deploymentType DeploymentType) error {
+	return e.deployFlow(id, flowDef, deploymentType, func(existing *Flow) error {
+		if existing != nil {
+			return ErrFlowExists
+		}
+		return nil
+	})
+}
+
+// deployFlow deploys a flow if check, called with the flow it replaces or
+// nil, allows it
+func (e *Engine) deployFlow(id string, flowDef []byte, deploymentType DeploymentType, check func(existing *Flow) error) error {
+	// Reject broken flows before replacing the running version
+	if err := validateDefinition(flowDef); err != nil {
+		return err
+	}
+
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	// Stop existing flow if it exists
-	if existingFlow, exists := e.flows[id]; exists {
-		existingFlow.Stop()
+	existingFlow, exists := e.flows[id]
+	if err := check(existingFlow); err != nil {
+		return err
+	}
+	if err := e.registerSubflows(id, flowDef); err != nil {
+		return err
 	}
 
-	// Save flow to storage
-	if err := e.storage.SaveFlow(id, flowDef); err != nil {
-		return fmt.Errorf("failed to save flow: %w", err)
+	if exists && deploymentType != DeployFull {
+		if deployed, err := e.deployChanges(id, existingFlow, flowDef, deploymentType); deployed || err != nil {
+			return err
+		}
 	}
 
-	// Create new flow
-	flow, err := NewFlow(id, flowDef, e)
+	// Create the new flow before touching the running one, so that a
+	// flow that fails to build leaves it running, unsaved
+	withCreds, err := e.withCredentials(id, flowDef)
+	if err != nil {
+		return err
+	}
+	flow, err := NewFlow(id, withCreds, e)
 	if err != nil {
 		return fmt.Errorf("failed to create flow: %w", err)
 	}
 
+	// Save flow to storage, with its credentials apart
+	if _, err := e.saveFlow(id, flowDef); err != nil {
+		return err
+	}
+
+	// Stop existing flow if it exists, keeping its retained messages for
+	// the new version
+	if exists {
+		retained := existingFlow.retainedMessages()
+		existingFlow.Stop()
+		flow.inheritRetained(existingFlow, retained)
+	}
+	flow.updated = e.clock.Now()
+
+	delete(e.quarantined, id)
 	e.flows[id] = flow
+	e.dispatch(id, flow)
 
-	// Start the flow if engine is running
-	if e.status == StatusRunning {
-		if err := flow.Start(e.ctx); err != nil {
+	// Start the flow if engine is running, unless agents run it
+	if e.status == StatusRunning && !flow.Remote() {
+		if err := e.runFlow(flow); err != nil {
 			return fmt.Errorf("failed to start flow: %w", err)
 		}
 	}
@@ -173,8 +489,16 @@ func (e *Engine) DeleteFlow(id string) error {
 	// Stop and remove flow if it exists
 	if flow, exists := e.flows[id]; exists {
 		flow.Stop()
+		flow.deleteStates()
 		delete(e.flows, id)
 	}
+	e.contexts.deleteFlow(id)
+	e.registry.SetSubflows(id, nil)
+	delete(e.quarantined, id)
+	e.undispatch(id)
+	if err := e.credentials.save(id, nil); err != nil {
+		e.Logger().Warn("failed to delete credentials of flow", "flow_id", id, "error", err)
+	}
 
 	// Remove from storage
 	return e.storage.DeleteFlow(id)
@@ -185,6 +509,47 @@ func (e *Engine) GetRegistry() *registry.Registry {
 	return e.registry
 }
 
+// Clock returns the clock used by timer-driven nodes
+func (e *Engine) Clock() Clock {
+	return e.clock
+}
+
+// SetClock replaces the clock used by timer-driven nodes; it must be called
+// before flows are started
+func (e *Engine) SetClock(clock Clock) {
+	e.clock = clock
+}
+
+// HTTPRoutes returns the HTTP routes registered by nodes
+func (e *Engine) HTTPRoutes() *HTTPRoutes {
+	return e.httpRoutes
+}
+
+// HTTPRequests returns the pending HTTP requests awaiting a flow response
+func (e *Engine) HTTPRequests() *HTTPRequests {
+	return e.httpRequests
+}
+
+// Correlations returns the requests waiting for replies
+func (e *Engine) Correlations() *Correlations {
+	return e.correlations
+}
+
+// Reply completes the pending request with the ID; see Correlations.Reply
+func (e *Engine) Reply(id string, reply *Message) (*Message, error) {
+	return e.correlations.Reply(id, reply)
+}
+
+// FlowLocks returns the locks editors hold on flows
+func (e *Engine) FlowLocks() *FlowLocks {
+	return e.locks
+}
+
+// Events returns the engine event bus
+func (e *Engine) Events() *EventBus {
+	return e.events
+}
+
 // Status returns the current engine status
 func (e *Engine) Status() Status {
 	e.mu.RLock()
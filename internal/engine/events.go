@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	EventDebug      = "debug"
+	EventNodeStatus = "node-status"
+)
+
+// Event is a runtime event published on the engine event bus
+type Event struct {
+	Type      string      `json:"type"`
+	Workspace string      `json:"workspace,omitempty"`
+	FlowID    string      `json:"flowId,omitempty"`
+	NodeID    string      `json:"nodeId,omitempty"`
+	Agent     string      `json:"agent,omitempty"` // set on events forwarded by agents
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+// EventHandler receives published events. Handlers are called
+// synchronously and must not block.
+type EventHandler func(event Event)
+
+// EventBus distributes runtime events to subscribers
+type EventBus struct {
+	handlers map[int]EventHandler
+	nextID   int
+	mu       sync.RWMutex
+}
+
+// NewEventBus creates a new EventBus
+func NewEventBus() *EventBus {
+	return &EventBus{
+		handlers: make(map[int]EventHandler),
+	}
+}
+
+// Subscribe registers a handler and returns a function that removes it
+func (b *EventBus) Subscribe(handler EventHandler) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.handlers, id)
+	}
+}
+
+// Publish delivers an event to all subscribers
+func (b *EventBus) Publish(event Event) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, handler := range b.handlers {
+		handler(event)
+	}
+}
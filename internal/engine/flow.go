@@ -4,7 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Flow represents a complete flow with nodes and connections
@@ -17,6 +20,78 @@ type Flow struct {
 	engine      *Engine
 	mu          sync.RWMutex
 	status      FlowStatus
+
+	// virtual holds editor-only and disabled nodes, which are kept for
+	// ToJSON only
+	virtual []NodeDefinition
+	groups  []Group
+
+	// delivery is the delivery mode; ackTimeout bounds at-least-once
+	// deliveries
+	delivery   string
+	ackTimeout time.Duration
+
+	// wires are all wires as defined; connections are the wires between
+	// runtime nodes; replay holds retained messages for late-attached
+	// nodes, delivered on the next Start
+	wires       []WireDefinition
+	connections []WireDefinition
+	replay      []retainedReplay
+
+	// target selects the agents that run the flow; definition is the JSON
+	// the flow was created from, which is what agents receive
+	target     map[string]string
+	definition []byte
+	// updated is when the definition was last saved
+	updated    time.Time
+
+	// schedule restricts when the flow runs; override keeps a manual start
+	// or stop until the next window boundary
+	schedule       *schedule
+	scheduleDef    *Schedule
+	scheduleCancel context.CancelFunc
+	scheduleDone   chan struct{}
+	override       bool
+
+	// disabled flows are only started explicitly
+	disabled bool
+
+	// loops is set if the wires form a loop, whose messages carry a TTL
+	loops bool
+
+	// dryRun flows are only created to check their definition
+	dryRun bool
+
+	// order is the order the nodes start in, see startOrder; they stop in
+	// reverse
+	order []*Node
+
+	// limits are the flow's own limits; usage tracks what it uses
+	limits *FlowLimits
+	usage  flowUsage
+
+	// workspace is the workspace the flow belongs to, see FlowKey
+	workspace string
+
+	// missing are the node types of the flow that are not installed
+	missing []string
+
+	// completions are the callbacks of the flow's complete nodes
+	completions completions
+
+	// subflows are the subflows the flow defines; instances are its
+	// subflow instances, replaced by the expanded nodes, which are kept
+	// for ToJSON
+	subflows  []SubflowDefinition
+	instances []NodeDefinition
+	expanded  map[string]bool
+
+	// since, reason and failedNodeID describe the last status transition;
+	// panics counts node panics since the flow started
+	since        time.Time
+	reason       string
+	failedNodeID string
+	panics       int32
 }
 
 // FlowStatus represents the status of a flow
@@ -35,6 +110,23 @@ type FlowDefinition struct {
 	Description string          `json:"description"`
 	Nodes       []NodeDefinition `json:"nodes"`
 	Wires       []WireDefinition `json:"wires"`
+	// Delivery is "at-most-once" (default) or "at-least-once"
+	Delivery    string           `json:"delivery,omitempty"`
+	// AckTimeout bounds how long an at-least-once delivery may stay open
+	AckTimeout  string           `json:"ackTimeout,omitempty"`
+	// Target runs the flow on the agents that have all of these labels
+	Target      map[string]string `json:"target,omitempty"`
+	// Schedule limits the flow to time windows
+	Schedule    *Schedule         `json:"schedule,omitempty"`
+	// Disabled keeps the flow from being started with the engine
+	Disabled    bool              `json:"disabled,omitempty"`
+	// Groups organize nodes in the editor
+	Groups      []Group           `json:"groups,omitempty"`
+	// Limits bound the resources the flow may use
+	Limits      *FlowLimits       `json:"limits,omitempty"`
+	// Subflows package groups of nodes as node types that every flow
+	// can use, see SubflowDefinition
+	Subflows    []SubflowDefinition `json:"subflows,omitempty"`
 }
 
 // NodeDefinition represents the JSON structure of a node
@@ -44,6 +136,23 @@ type NodeDefinition struct {
 	Name     string          `json:"name"`
 	Config   json.RawMessage `json:"config"`
 	Position Position        `json:"position"`
+	// Queue gives the node a persistent inbox
+	Queue    *QueueConfig    `json:"queue,omitempty"`
+	// Retain keeps the last message sent on each output
+	Retain   *RetainConfig   `json:"retain,omitempty"`
+	// Instances runs several instances of a stateless node, which receive
+	// messages round-robin or, with Distribute "topic", by topic
+	Instances  int           `json:"instances,omitempty"`
+	Distribute string        `json:"distribute,omitempty"`
+	// Priority is given to every message the node sends, e.g. to let
+	// commands from one source overtake bulk data from another
+	Priority   string        `json:"priority,omitempty"`
+	// Disabled nodes are kept in the flow but not created; messages are
+	// not sent to or from them
+	Disabled   bool          `json:"disabled,omitempty"`
+	// StartAfter lists nodes that have to be started, and ready, before
+	// this node starts
+	StartAfter []string      `json:"startAfter,omitempty"`
 }
 
 // WireDefinition represents a connection between nodes
@@ -51,6 +160,10 @@ type WireDefinition struct {
 	Source string `json:"source"`
 	Target string `json:"target"`
 	Port   int    `json:"port"`
+	// Label documents what the wire carries
+	Label string `json:"label,omitempty"`
+	// Disabled wires are kept in the flow but carry no messages
+	Disabled bool `json:"disabled,omitempty"`
 }
 
 // Position represents a node's position in the editor
@@ -61,13 +174,26 @@ type Position struct {
 
 // NewFlow creates a new Flow from its JSON definition
 func NewFlow(id string, flowDef []byte, engine *Engine) (*Flow, error) {
+	return newFlow(id, flowDef, engine, false)
+}
+
+// newFlow creates a flow. A dry run only checks the definition: node
+// instances that implement Validator are validated instead of initialized,
+// and warnings are not logged.
+func newFlow(id string, flowDef []byte, engine *Engine, dryRun bool) (*Flow, error) {
 	var def FlowDefinition
 	if err := json.Unmarshal(flowDef, &def); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal flow definition: %w", err)
 	}
 
+	workspace, flowID := SplitFlowKey(id)
 	if def.ID == "" {
-		def.ID = id
+		def.ID = flowID
+	}
+
+	warnings, err := ValidateFlow(&def)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create flow
@@ -78,26 +204,114 @@ func NewFlow(id string, flowDef []byte, engine *Engine) (*Flow, error) {
 		Nodes:       make(map[string]*Node),
 		Wires:       make(map[string][]string),
 		engine:      engine,
+		workspace:   workspace,
 		status:      FlowStatusStopped,
+		delivery:    def.Delivery,
+		ackTimeout:  defaultAckTimeout,
+		target:      def.Target,
+		definition:  flowDef,
+		disabled:    def.Disabled,
+		groups:      def.Groups,
+		limits:      def.Limits,
+		dryRun:      dryRun,
+	}
+	flow.since = flow.clock().Now()
+	if !dryRun {
+		for _, warning := range warnings {
+			flow.logWarning(warning)
+		}
+	}
+
+	switch flow.delivery {
+	case "":
+		flow.delivery = DeliveryAtMostOnce
+	case DeliveryAtMostOnce, DeliveryAtLeastOnce:
+	default:
+		return nil, fmt.Errorf("invalid delivery mode: %s", def.Delivery)
+	}
+	if def.AckTimeout != "" {
+		timeout, err := time.ParseDuration(def.AckTimeout)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid ack timeout: %s", def.AckTimeout)
+		}
+		flow.ackTimeout = timeout
+	}
+	if def.Limits != nil {
+		if err := def.Limits.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if def.Schedule != nil {
+		schedule, err := newSchedule(def.Schedule)
+		if err != nil {
+			return nil, err
+		}
+		flow.schedule, flow.scheduleDef = schedule, def.Schedule
+	}
+
+	// Replace subflow instances by their nodes, keeping the wires as
+	// defined
+	flow.wires = append(flow.wires, def.Wires...)
+	if err := flow.expandSubflows(&def); err != nil {
+		return nil, err
 	}
 
 	// Create nodes
+	disabled := disabledNodes(&def)
 	for _, nodeDef := range def.Nodes {
 		nodeType, err := engine.GetRegistry().GetNodeType(nodeDef.Type)
 		if err != nil {
-			return nil, fmt.Errorf("unknown node type: %s", nodeDef.Type)
+			if !engine.AllowUnknownTypes() {
+				return nil, fmt.Errorf("unknown node type: %s", nodeDef.Type)
+			}
+			nodeType = unknownNodeType(nodeDef.Type)
+			flow.addMissingType(nodeDef.Type)
+		}
+		if nodeType.Virtual || disabled[nodeDef.ID] {
+			flow.virtual = append(flow.virtual, nodeDef)
+			continue
 		}
 
 		node, err := NewNode(nodeDef.ID, nodeDef.Name, nodeType, nodeDef.Config, flow)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create node %s: %w", nodeDef.ID, err)
 		}
+		for _, warning := range node.Warnings() {
+			if !dryRun {
+				flow.logWarning(warning)
+			}
+		}
 
+		node.Position = nodeDef.Position
+		node.startAfter = nodeDef.StartAfter
+		node.queue = nodeDef.Queue
+		if nodeDef.Priority != "" {
+			if node.priority, err = ParsePriority(nodeDef.Priority); err != nil {
+				return nil, fmt.Errorf("node %s: %w", nodeDef.ID, err)
+			}
+		}
+		if nodeDef.Instances > 1 {
+			if err := node.setInstances(nodeDef.Instances, nodeDef.Distribute); err != nil {
+				return nil, fmt.Errorf("node %s: %w", nodeDef.ID, err)
+			}
+		}
+		if nodeDef.Retain != nil {
+			if node.retainer, err = newRetainer(nodeDef.Retain); err != nil {
+				return nil, fmt.Errorf("node %s: %w", nodeDef.ID, err)
+			}
+			node.retain = nodeDef.Retain
+		}
 		flow.Nodes[nodeDef.ID] = node
 	}
 
 	// Connect wires
 	for _, wireDef := range def.Wires {
+		if flow.isVirtual(wireDef.Source) || flow.isVirtual(wireDef.Target) {
+			// Kept for ToJSON but never carries messages
+			flow.Wires[wireDef.Source] = append(flow.Wires[wireDef.Source], wireDef.Target)
+			continue
+		}
+
 		sourceNode, exists := flow.Nodes[wireDef.Source]
 		if !exists {
 			return nil, fmt.Errorf("wire source node not found: %s", wireDef.Source)
@@ -113,6 +327,24 @@ func NewFlow(id string, flowDef []byte, engine *Engine) (*Flow, error) {
 
 		// Connect nodes
 		sourceNode.AddWire(wireDef.Port, targetNode)
+		if wireDef.Disabled {
+			sourceNode.setWireDisabled(wireDef.Port, wireDef.Target, true)
+		}
+		flow.connections = append(flow.connections, wireDef)
+	}
+	flow.markSources()
+	if err := flow.checkLoops(); err != nil {
+		return nil, err
+	}
+
+	// Order the start of the nodes by their dependencies
+	for _, node := range flow.Nodes {
+		if node.after, err = flow.dependencies(node, node.startAfter); err != nil {
+			return nil, fmt.Errorf("node %s: %w", node.ID, err)
+		}
+	}
+	if flow.order, err = flow.startOrder(); err != nil {
+		return nil, err
 	}
 
 	return flow, nil
@@ -121,36 +353,81 @@ func NewFlow(id string, flowDef []byte, engine *Engine) (*Flow, error) {
 // Start starts all nodes in the flow
 func (f *Flow) Start(ctx context.Context) error {
 	f.mu.Lock()
-	defer f.mu.Unlock()
 
 	if f.status == FlowStatusRunning {
+		f.mu.Unlock()
 		return fmt.Errorf("flow %s is already running", f.ID)
 	}
+	if f.Remote() {
+		f.mu.Unlock()
+		return errRemoteFlow(f.ID)
+	}
 
-	for _, node := range f.Nodes {
-		if err := node.Start(ctx); err != nil {
-			return fmt.Errorf("failed to start node %s: %w", node.ID, err)
+	// Nodes start after the nodes they depend on and send to, each once
+	// the previous ones are ready
+	started := make([]*Node, 0, len(f.order))
+	for _, node := range f.order {
+		err := node.Start(ctx)
+		if err == nil {
+			started = append(started, node)
+			err = node.waitReady(ctx)
+		}
+		if err != nil {
+			// Roll back rather than leave the flow half running
+			for i := len(started) - 1; i >= 0; i-- {
+				started[i].Stop()
+			}
+			err = f.startError(node, err)
+			event := f.setStatus(FlowStatusError, err.Error(), node.ID)
+			f.mu.Unlock()
+			f.publish(event)
+			return err
 		}
 	}
 
-	f.status = FlowStatusRunning
+	atomic.StoreInt32(&f.panics, 0)
+	event := f.setStatus(FlowStatusRunning, "", "")
+	replay := f.replay
+	f.replay = nil
+	f.mu.Unlock()
+	f.publish(event)
+
+	// Deliver retained messages once every node is running
+	f.replayRetained(replay)
 	return nil
 }
 
-// Stop stops all nodes in the flow
+// AtLeastOnce reports whether the flow acknowledges source messages only
+// after they were completely handled
+func (f *Flow) AtLeastOnce() bool {
+	return f.delivery == DeliveryAtLeastOnce
+}
+
+// Stop stops all nodes in the flow, and its schedule
 func (f *Flow) Stop() {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+	f.stopSchedule()
+	f.stopNodes()
+}
 
+// stopNodes stops the nodes in the reverse of their start order: from the
+// sources downstream, so that messages in flight and those flushed by
+// stopping nodes still reach running nodes, and nodes before those they
+// depend on
+func (f *Flow) stopNodes() {
+	f.mu.Lock()
 	if f.status != FlowStatusRunning {
+		f.mu.Unlock()
 		return
 	}
 
-	for _, node := range f.Nodes {
-		node.Stop()
+	for i := len(f.order) - 1; i >= 0; i-- {
+		f.order[i].Stop()
+		f.order[i].ClearRetained()
 	}
 
-	f.status = FlowStatusStopped
+	event := f.setStatus(FlowStatusStopped, "", "")
+	f.mu.Unlock()
+	f.publish(event)
 }
 
 // ToJSON converts the flow to its JSON representation
@@ -163,29 +440,38 @@ func (f *Flow) ToJSON() ([]byte, error) {
 		Name:        f.Name,
 		Description: f.Description,
 	}
-
-	// Convert nodes
-	for _, node := range f.Nodes {
-		nodeDef := NodeDefinition{
-			ID:     node.ID,
-			Type:   node.Type.Name,
-			Name:   node.Name,
-			Config: node.Config,
+	if f.delivery == DeliveryAtLeastOnce {
+		def.Delivery = f.delivery
+		def.AckTimeout = f.ackTimeout.String()
+	}
+	def.Target = f.target
+	def.Schedule = f.scheduleDef
+	def.Disabled = f.disabled
+	def.Groups = f.groups
+	def.Limits = f.limits
+	def.Subflows = f.subflows
+
+	// Convert nodes by ID, so that the same flow always has the same JSON;
+	// subflow instances are kept instead of their nodes
+	ids := make([]string, 0, len(f.Nodes))
+	for id := range f.Nodes {
+		if !f.expanded[id] {
+			ids = append(ids, id)
 		}
-		def.Nodes = append(def.Nodes, nodeDef)
 	}
-
-	// Convert wires
-	for sourceID, targets := range f.Wires {
-		for port, targetID := range targets {
-			wireDef := WireDefinition{
-				Source: sourceID,
-				Target: targetID,
-				Port:   port,
-			}
-			def.Wires = append(def.Wires, wireDef)
+	sort.Strings(ids)
+	for _, id := range ids {
+		def.Nodes = append(def.Nodes, f.Nodes[id].definition())
+	}
+	for _, nodeDef := range f.virtual {
+		if !f.expanded[nodeDef.ID] {
+			def.Nodes = append(def.Nodes, nodeDef)
 		}
 	}
+	def.Nodes = append(def.Nodes, f.instances...)
+
+	// Wires keep their ports, labels and disabled flags
+	def.Wires = append(def.Wires, f.wires...)
 
 	return json.Marshal(def)
 }
@@ -194,9 +480,43 @@ func (f *Flow) ToJSON() ([]byte, error) {
 func (f *Flow) GetStatus() FlowStatus {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
+	if f.status != FlowStatusRunning && f.Remote() {
+		return FlowStatusRemote
+	}
+	if f.status != FlowStatusRunning && f.scheduleCancel != nil && !f.override {
+		return FlowStatusInactive
+	}
+	if f.status == FlowStatusRunning {
+		if _, throttled := f.throttleState(); throttled {
+			return FlowStatusThrottled
+		}
+	}
 	return f.status
 }
 
+// Disabled reports whether the flow is kept from starting with the engine
+func (f *Flow) Disabled() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.disabled
+}
+
+// Describe returns the name and description of the flow, which deploys of
+// its nodes change in place
+func (f *Flow) Describe() (name, description string) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.Name, f.Description
+}
+
+// Updated returns when the flow's definition was last saved, or the zero
+// time if that is not known
+func (f *Flow) Updated() time.Time {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.updated
+}
+
 // GetNode returns a node by ID
 func (f *Flow) GetNode(id string) (*Node, bool) {
 	f.mu.RLock()
@@ -204,3 +524,31 @@ func (f *Flow) GetNode(id string) (*Node, bool) {
 	node, exists := f.Nodes[id]
 	return node, exists
 }
+
+// logWarning logs a problem found in the flow's definition
+func (f *Flow) logWarning(warning ValidationIssue) {
+	var attrs []interface{}
+	if warning.NodeID != "" {
+		attrs = append(attrs, "node_id", warning.NodeID)
+	}
+	if warning.Path != "" {
+		attrs = append(attrs, "path", warning.Path)
+	}
+	f.Logger().Warn(warning.Message, attrs...)
+}
+
+// GetEngine returns the engine the flow belongs to
+func (f *Flow) GetEngine() *Engine {
+	return f.engine
+}
+
+// isVirtual reports whether id is an editor-only or disabled node of the
+// flow
+func (f *Flow) isVirtual(id string) bool {
+	for _, nodeDef := range f.virtual {
+		if nodeDef.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package engine
+
+import "fmt"
+
+// Group is a set of nodes that the editor shows together. Disabling a
+// group disables its nodes.
+type Group struct {
+	ID        string   `json:"id"`
+	Label     string   `json:"label,omitempty"`
+	Color     string   `json:"color,omitempty"`
+	Nodes     []string `json:"nodes"`
+	Collapsed bool     `json:"collapsed,omitempty"`
+	Disabled  bool     `json:"disabled,omitempty"`
+}
+
+// validateGroups checks that groups have unique IDs and that their members
+// exist and are in no other group
+func validateGroups(def *FlowDefinition, nodes map[string]bool) []ValidationIssue {
+	var errs []ValidationIssue
+	groups := make(map[string]bool, len(def.Groups))
+	members := make(map[string]string)
+	for i, group := range def.Groups {
+		switch {
+		case group.ID == "":
+			errs = append(errs, ValidationIssue{Message: fmt.Sprintf("group %d (%s) has no ID", i, group.Label)})
+			continue
+		case groups[group.ID]:
+			errs = append(errs, ValidationIssue{Message: fmt.Sprintf("duplicate group ID %q", group.ID)})
+			continue
+		}
+		groups[group.ID] = true
+
+		for _, nodeID := range group.Nodes {
+			switch other, grouped := members[nodeID]; {
+			case !nodes[nodeID]:
+				errs = append(errs, ValidationIssue{
+					NodeID:  nodeID,
+					Message: fmt.Sprintf("member of group %q not found", group.ID),
+				})
+			case grouped:
+				errs = append(errs, ValidationIssue{
+					NodeID:  nodeID,
+					Message: fmt.Sprintf("node is in groups %q and %q", other, group.ID),
+				})
+			default:
+				members[nodeID] = group.ID
+			}
+		}
+	}
+	return errs
+}
+
+// disabledNodes returns the nodes that are disabled themselves or through
+// their group
+func disabledNodes(def *FlowDefinition) map[string]bool {
+	disabled := make(map[string]bool)
+	for _, nodeDef := range def.Nodes {
+		if nodeDef.Disabled {
+			disabled[nodeDef.ID] = true
+		}
+	}
+	for _, group := range def.Groups {
+		if group.Disabled {
+			for _, nodeID := range group.Nodes {
+				disabled[nodeID] = true
+			}
+		}
+	}
+	return disabled
+}
@@ -0,0 +1,302 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HookStage is a point in a message's way through a flow at which hooks run
+type HookStage string
+
+const (
+	// HookPreSend runs once per message a node sends, before it is copied
+	// for the nodes wired to the output port
+	HookPreSend HookStage = "pre-send"
+	// HookPreReceive runs before a node handles a message
+	HookPreReceive HookStage = "pre-receive"
+	// HookPostReceive runs after a node handled a message, with the node's
+	// error if it failed
+	HookPostReceive HookStage = "post-receive"
+	// HookNodeError runs when a node fails to handle a message or panics
+	HookNodeError HookStage = "node-error"
+)
+
+// HookStages lists the stages in the order a message passes them
+var HookStages = []HookStage{HookPreSend, HookPreReceive, HookPostReceive, HookNodeError}
+
+// ErrDropMessage is returned by pre-send and pre-receive hooks to drop a
+// message without failing the delivery
+var ErrDropMessage = errors.New("message dropped by hook")
+
+// HookContext is what a hook is called with. Hooks may change Msg.
+type HookContext struct {
+	Stage HookStage
+	Flow  *Flow
+	// Node is the sending node in the pre-send stage and the receiving node
+	// in the others
+	Node *Node
+	// Port is the output port in the pre-send stage and the input port in
+	// the others
+	Port int
+	Msg  *Message
+	// Err is the node's error in the post-receive and node-error stages.
+	// Node-error hooks may replace it, e.g. to annotate it.
+	Err error
+}
+
+// Hook is called at a stage of message delivery. In the pre-send and
+// pre-receive stages an error vetoes the delivery: ErrDropMessage drops
+// the message quietly, other errors are returned to the sender. Errors of
+// later stages are logged.
+type Hook func(ctx *HookContext) error
+
+// HookStats counts the calls of a hook and the time they took
+type HookStats struct {
+	Name    string        `json:"name"`
+	Stage   HookStage     `json:"stage"`
+	Calls   int64         `json:"calls"`
+	Errors  int64         `json:"errors"`
+	Dropped int64         `json:"dropped"`
+	Total   time.Duration `json:"totalNs"`
+	Max     time.Duration `json:"maxNs"`
+}
+
+// registeredHook is a hook with its counters, which are accessed atomically
+type registeredHook struct {
+	name    string
+	stage   HookStage
+	fn      Hook
+	calls   int64
+	errors  int64
+	dropped int64
+	total   int64
+	max     int64
+}
+
+// Hooks holds the hooks of an engine. The hooks of a stage run in
+// registration order.
+type Hooks struct {
+	// stages maps each stage to its hooks; it is replaced, not changed, so
+	// that running hooks needs no lock
+	stages atomic.Value
+	mu     sync.Mutex
+}
+
+func newHooks() *Hooks {
+	h := &Hooks{}
+	h.stages.Store(map[HookStage][]*registeredHook{})
+	return h
+}
+
+// Register adds a hook to a stage. The name identifies it in the stats.
+func (h *Hooks) Register(stage HookStage, name string, fn Hook) error {
+	if fn == nil {
+		return fmt.Errorf("hook %s has no function", name)
+	}
+	valid := false
+	for _, s := range HookStages {
+		valid = valid || s == stage
+	}
+	if !valid {
+		return fmt.Errorf("unknown hook stage: %s", stage)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	current := h.stages.Load().(map[HookStage][]*registeredHook)
+	for _, hook := range current[stage] {
+		if hook.name == name {
+			return fmt.Errorf("hook %s is already registered for %s", name, stage)
+		}
+	}
+
+	stages := make(map[HookStage][]*registeredHook, len(current)+1)
+	for s, hooks := range current {
+		stages[s] = hooks
+	}
+	hooks := make([]*registeredHook, len(current[stage]), len(current[stage])+1)
+	copy(hooks, current[stage])
+	stages[stage] = append(hooks, &registeredHook{name: name, stage: stage, fn: fn})
+	h.stages.Store(stages)
+	return nil
+}
+
+// Stats returns the stats of every hook, by stage and registration order
+func (h *Hooks) Stats() []HookStats {
+	stages := h.stages.Load().(map[HookStage][]*registeredHook)
+	var stats []HookStats
+	for _, stage := range HookStages {
+		for _, hook := range stages[stage] {
+			stats = append(stats, HookStats{
+				Name:    hook.name,
+				Stage:   hook.stage,
+				Calls:   atomic.LoadInt64(&hook.calls),
+				Errors:  atomic.LoadInt64(&hook.errors),
+				Dropped: atomic.LoadInt64(&hook.dropped),
+				Total:   time.Duration(atomic.LoadInt64(&hook.total)),
+				Max:     time.Duration(atomic.LoadInt64(&hook.max)),
+			})
+		}
+	}
+	return stats
+}
+
+// run calls the hooks of the context's stage in order, stopping at the
+// first error
+func (h *Hooks) run(ctx *HookContext) error {
+	for _, hook := range h.stages.Load().(map[HookStage][]*registeredHook)[ctx.Stage] {
+		if err := hook.call(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// call runs the hook and records its latency and outcome
+func (r *registeredHook) call(ctx *HookContext) error {
+	start := time.Now()
+	err := r.fn(ctx)
+	elapsed := int64(time.Since(start))
+
+	atomic.AddInt64(&r.calls, 1)
+	atomic.AddInt64(&r.total, elapsed)
+	for {
+		max := atomic.LoadInt64(&r.max)
+		if elapsed <= max || atomic.CompareAndSwapInt64(&r.max, max, elapsed) {
+			break
+		}
+	}
+	switch {
+	case errors.Is(err, ErrDropMessage):
+		atomic.AddInt64(&r.dropped, 1)
+	case err != nil:
+		atomic.AddInt64(&r.errors, 1)
+	}
+	if err != nil {
+		return fmt.Errorf("hook %s: %w", r.name, err)
+	}
+	return nil
+}
+
+// RegisterHook adds a hook to the engine; see Hooks.Register
+func (e *Engine) RegisterHook(stage HookStage, name string, fn Hook) error {
+	return e.hooks.Register(stage, name, fn)
+}
+
+// Hooks returns the engine's hooks
+func (e *Engine) Hooks() *Hooks {
+	return e.hooks
+}
+
+// nodeHooks returns the hooks of the engine running a node, or nil if it
+// has none
+func nodeHooks(node *Node) *Hooks {
+	if node == nil || node.flow == nil || node.flow.engine == nil {
+		return nil
+	}
+	hooks := node.flow.engine.hooks
+	if len(hooks.stages.Load().(map[HookStage][]*registeredHook)) == 0 {
+		return nil
+	}
+	return hooks
+}
+
+// preSend counts a message the node sends against its loop TTL and runs the
+// pre-send hooks for it. It reports whether the message is to be sent.
+func (n *Node) preSend(msg *Message, port int) (bool, error) {
+	if !n.countLoopHop(msg) {
+		return false, nil
+	}
+	hooks := nodeHooks(n)
+	if hooks == nil {
+		return true, nil
+	}
+	err := hooks.run(&HookContext{Stage: HookPreSend, Flow: n.flow, Node: n, Port: port, Msg: msg})
+	if errors.Is(err, ErrDropMessage) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// receiveWithHooks has instance handle a message, running the receive and
+// error hooks around it
+func receiveWithHooks(node *Node, msg *Message, port int, handle func() error) error {
+	hooks := nodeHooks(node)
+	if hooks == nil {
+		return handle()
+	}
+
+	ctx := &HookContext{Stage: HookPreReceive, Flow: node.flow, Node: node, Port: port, Msg: msg}
+	if err := hooks.run(ctx); err != nil {
+		if errors.Is(err, ErrDropMessage) {
+			return nil
+		}
+		return err
+	}
+
+	ctx.Err = handle()
+	if ctx.Err != nil {
+		ctx.Stage = HookNodeError
+		if err := hooks.run(ctx); err != nil {
+			node.Logger().Warn("error hook failed", "error", err)
+		}
+	}
+	ctx.Stage = HookPostReceive
+	if err := hooks.run(ctx); err != nil {
+		node.Logger().Warn("post-receive hook failed", "error", err)
+	}
+	return ctx.Err
+}
+
+// HopsMetadata is the metadata key under which HopLimitHook counts the
+// times a message was sent on
+const HopsMetadata = "hops"
+
+// TraceMetadata is the metadata key under which TraceHook lists the IDs of
+// the nodes that handled a message
+const TraceMetadata = "trace"
+
+// HopLimitHook returns a pre-send hook counting the hops of messages. A
+// message sent on more than max times is refused, which stops messages
+// circling in a loop of wires.
+func HopLimitHook(max int) Hook {
+	return func(ctx *HookContext) error {
+		var hops int64
+		if value, ok := ctx.Msg.GetMetadata(HopsMetadata); ok {
+			hops, _ = ToInt(value)
+		}
+		hops++
+		if max > 0 && hops > int64(max) {
+			return fmt.Errorf("message %s exceeded %d hops", ctx.Msg.MsgID, max)
+		}
+		ctx.Msg.SetMetadata(HopsMetadata, hops)
+		return nil
+	}
+}
+
+// TraceHook returns a pre-receive hook adding the ID of every node that
+// handles a message to its trace
+func TraceHook() Hook {
+	return func(ctx *HookContext) error {
+		var trace []interface{}
+		if value, ok := ctx.Msg.GetMetadata(TraceMetadata); ok {
+			switch value := value.(type) {
+			case []interface{}:
+				trace = value
+			case []string:
+				for _, id := range value {
+					trace = append(trace, id)
+				}
+			}
+		}
+		// Copies of a message share the trace, so it is never appended to
+		// in place
+		extended := make([]interface{}, len(trace), len(trace)+1)
+		copy(extended, trace)
+		ctx.Msg.SetMetadata(TraceMetadata, append(extended, ctx.Node.ID))
+		return nil
+	}
+}
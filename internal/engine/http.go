@@ -0,0 +1,238 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrHTTPRequestNotFound is returned when responding to a request that
+	// is unknown or has already timed out
+	ErrHTTPRequestNotFound = errors.New("http request not found or timed out")
+
+	// ErrHTTPResponseSent is returned when responding to a request twice
+	ErrHTTPResponseSent = errors.New("http response already sent")
+)
+
+// HTTPMethodAny registers a route for every HTTP method
+const HTTPMethodAny = "ANY"
+
+// MetadataHTTPRequestID is the metadata key correlating a message with the
+// HTTP request it originated from
+const MetadataHTTPRequestID = "httpRequestId"
+
+// HTTPRoutes is a dynamic set of HTTP handlers registered by nodes while
+// flows are running. Paths may contain mux-style parameters such as
+// /orders/{id}.
+type HTTPRoutes struct {
+	routes []*httpRoute
+	mu     sync.RWMutex
+}
+
+// httpRoute is a registered route
+type httpRoute struct {
+	method   string
+	segments []string
+	handler  http.Handler
+}
+
+// httpRouteParamsKey is the context key of the route parameters
+type httpRouteParamsKey struct{}
+
+// NewHTTPRoutes creates an empty HTTPRoutes
+func NewHTTPRoutes() *HTTPRoutes {
+	return &HTTPRoutes{}
+}
+
+// Handle registers a handler for a method and path
+func (r *HTTPRoutes) Handle(method, path string, handler http.Handler) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	route := &httpRoute{
+		method:   strings.ToUpper(method),
+		segments: splitRoutePath(path),
+		handler:  handler,
+	}
+	for _, existing := range r.routes {
+		if existing.overlaps(route) {
+			return fmt.Errorf("route %s %s is already registered", method, path)
+		}
+	}
+
+	r.routes = append(r.routes, route)
+	return nil
+}
+
+// Remove unregisters the handler for a method and path
+func (r *HTTPRoutes) Remove(method, path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	route := &httpRoute{method: strings.ToUpper(method), segments: splitRoutePath(path)}
+	for i, existing := range r.routes {
+		if existing.overlaps(route) {
+			r.routes = append(r.routes[:i], r.routes[i+1:]...)
+			return
+		}
+	}
+}
+
+// Match returns the handler registered for a request and the values of the
+// route parameters
+func (r *HTTPRoutes) Match(req *http.Request) (http.Handler, map[string]string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	segments := splitRoutePath(req.URL.Path)
+	for _, route := range r.routes {
+		if route.method != HTTPMethodAny && route.method != req.Method {
+			continue
+		}
+		if params, ok := route.match(segments); ok {
+			return route.handler, params, true
+		}
+	}
+	return nil, nil, false
+}
+
+// ServeHTTP implements http.Handler
+func (r *HTTPRoutes) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	handler, params, exists := r.Match(req)
+	if !exists {
+		http.NotFound(w, req)
+		return
+	}
+
+	ctx := context.WithValue(req.Context(), httpRouteParamsKey{}, params)
+	handler.ServeHTTP(w, req.WithContext(ctx))
+}
+
+// HTTPRouteParams returns the route parameters of a request served through
+// HTTPRoutes
+func HTTPRouteParams(req *http.Request) map[string]string {
+	params, _ := req.Context().Value(httpRouteParamsKey{}).(map[string]string)
+	return params
+}
+
+// match matches the route against request path segments
+func (route *httpRoute) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(route.segments) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, segment := range route.segments {
+		if name, ok := routeParamName(segment); ok {
+			params[name] = segments[i]
+		} else if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// overlaps reports whether two routes would serve the same requests
+func (route *httpRoute) overlaps(other *httpRoute) bool {
+	if route.method != other.method && route.method != HTTPMethodAny && other.method != HTTPMethodAny {
+		return false
+	}
+	if len(route.segments) != len(other.segments) {
+		return false
+	}
+
+	for i, segment := range route.segments {
+		_, param := routeParamName(segment)
+		_, otherParam := routeParamName(other.segments[i])
+		if param != otherParam || (!param && segment != other.segments[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitRoutePath splits a path into its segments
+func splitRoutePath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// routeParamName returns the parameter name of a {name} segment
+func routeParamName(segment string) (string, bool) {
+	if len(segment) > 2 && strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
+
+// HTTPResponse is the response a flow sends for a pending HTTP request
+type HTTPResponse struct {
+	StatusCode int
+	Headers    map[string]string
+	Body       []byte
+	// Stream, if set, is copied to the client instead of Body
+	Stream *Stream
+}
+
+// HTTPRequests correlates HTTP requests received by input nodes with the
+// responses sent by response nodes
+type HTTPRequests struct {
+	pending map[string]*pendingHTTPRequest
+	mu      sync.Mutex
+}
+
+// pendingHTTPRequest is a request waiting for its response
+type pendingHTTPRequest struct {
+	response  chan *HTTPResponse
+	responded bool
+}
+
+// NewHTTPRequests creates an empty HTTPRequests
+func NewHTTPRequests() *HTTPRequests {
+	return &HTTPRequests{
+		pending: make(map[string]*pendingHTTPRequest),
+	}
+}
+
+// Register adds a pending request and returns the channel its response is
+// delivered on
+func (r *HTTPRequests) Register(id string) <-chan *HTTPResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pending := &pendingHTTPRequest{response: make(chan *HTTPResponse, 1)}
+	r.pending[id] = pending
+	return pending.response
+}
+
+// Respond delivers the response for a pending request
+func (r *HTTPRequests) Respond(id string, response *HTTPResponse) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pending, exists := r.pending[id]
+	if !exists {
+		return ErrHTTPRequestNotFound
+	}
+	if pending.responded {
+		return ErrHTTPResponseSent
+	}
+
+	pending.responded = true
+	pending.response <- response
+	return nil
+}
+
+// Remove removes a pending request once it has been answered or timed out
+func (r *HTTPRequests) Remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pending, id)
+}
@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// DecodeJSON decodes JSON like json.Unmarshal, except that numbers decoded
+// into interface{} values keep their precision: integers become int64, or
+// uint64 if they only fit that, and other numbers float64. json.Unmarshal
+// turns every number into float64, which rounds integers beyond 2^53 such
+// as Snowflake IDs and Kafka offsets.
+func DecodeJSON(data []byte, v interface{}) error {
+	return ReadJSON(bytes.NewReader(data), v)
+}
+
+// ReadJSON decodes a single JSON value from r like DecodeJSON
+func ReadJSON(r io.Reader, v interface{}) error {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("invalid data after top-level JSON value")
+	}
+	normalizeNumbers(reflect.ValueOf(v))
+	return nil
+}
+
+// normalizeNumbers replaces the json.Number values below v
+func normalizeNumbers(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			normalizeNumbers(v.Elem())
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		if value := convertNumbers(v.Elem().Interface()); v.CanSet() {
+			v.Set(reflect.ValueOf(value))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				normalizeNumbers(v.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if scalarKind(v.Type().Elem().Kind()) {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			normalizeNumbers(v.Index(i))
+		}
+	case reflect.Map:
+		if elem := v.Type().Elem().Kind(); elem != reflect.Interface {
+			if scalarKind(elem) {
+				return
+			}
+			// Other map values are not addressable; maps and slices of
+			// interface{} are still changed in place
+			for _, key := range v.MapKeys() {
+				normalizeNumbers(v.MapIndex(key))
+			}
+			return
+		}
+		for _, key := range v.MapKeys() {
+			if value := v.MapIndex(key); !value.IsNil() {
+				v.SetMapIndex(key, reflect.ValueOf(convertNumbers(value.Elem().Interface())))
+			}
+		}
+	}
+}
+
+// scalarKind reports whether values of a kind cannot hold a json.Number
+// below them
+func scalarKind(kind reflect.Kind) bool {
+	return kind >= reflect.Bool && kind <= reflect.Complex128 || kind == reflect.String
+}
+
+// convertNumbers converts a json.Number, or those in a decoded JSON object or
+// array, which are changed in place
+func convertNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		return jsonNumber(v)
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = convertNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = convertNumbers(item)
+		}
+	}
+	return value
+}
+
+// jsonNumber converts an integer literal to int64, or uint64 if it is too
+// large for int64, and other numbers to float64
+func jsonNumber(n json.Number) interface{} {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		return i
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		return u
+	}
+	f, _ := strconv.ParseFloat(string(n), 64)
+	return f
+}
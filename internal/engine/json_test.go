@@ -0,0 +1,137 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// Integers beyond 2^53, which float64 cannot hold exactly
+const (
+	// deviceID is 2^53+1, which float64 rounds to 2^53
+	deviceID int64 = 9007199254740993
+	// snowflakeID is a Snowflake ID, which uses all 63 bits
+	snowflakeID int64 = 1541815603606036480
+)
+
+func TestDecodeJSONNumbers(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want interface{}
+	}{
+		{"small integer", `42`, int64(42)},
+		{"beyond 2^53", `9007199254740993`, deviceID},
+		{"largest int64", `9223372036854775807`, int64(math.MaxInt64)},
+		{"smallest int64", `-9223372036854775808`, int64(math.MinInt64)},
+		{"beyond int64", `18446744073709551615`, uint64(math.MaxUint64)},
+		{"beyond uint64", `18446744073709551616`, float64(1 << 64)},
+		{"fraction", `0.5`, 0.5},
+		{"exponent", `1e3`, float64(1000)},
+		{"nested", `{"ids": [9007199254740993, {"offset": 1541815603606036480}]}`, map[string]interface{}{
+			"ids": []interface{}{deviceID, map[string]interface{}{"offset": snowflakeID}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got interface{}
+			if err := engine.DecodeJSON([]byte(tt.json), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decoded %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageLargeIntegers(t *testing.T) {
+	msg := engine.NewMessage(map[string]interface{}{
+		"deviceId": deviceID,
+		"offsets":  []interface{}{snowflakeID, int64(math.MaxInt64)},
+		"ratio":    0.25,
+	}, "devices")
+	msg.SetMetadata("kafkaOffset", snowflakeID)
+	msg.SetMetadata("unsigned", uint64(math.MaxUint64))
+
+	data, err := msg.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded engine.Message
+	if err := decoded.FromJSON(data); err != nil {
+		t.Fatal(err)
+	}
+	for name, got := range map[string]*engine.Message{"decoded": &decoded, "cloned": decoded.Clone()} {
+		if !reflect.DeepEqual(got.Payload, msg.Payload) {
+			t.Errorf("%s payload %#v, want %#v", name, got.Payload, msg.Payload)
+		}
+		if !reflect.DeepEqual(got.Metadata, msg.Metadata) {
+			t.Errorf("%s metadata %#v, want %#v", name, got.Metadata, msg.Metadata)
+		}
+		if id, _ := got.GetProperty("payload.deviceId"); id != deviceID {
+			t.Errorf("%s payload.deviceId = %#v, want %d", name, id, deviceID)
+		}
+	}
+}
+
+func TestConfigLargeIntegers(t *testing.T) {
+	var config struct {
+		DeviceID int64                  `json:"deviceId"`
+		Offset   uint64                 `json:"offset"`
+		Extra    interface{}            `json:"extra"`
+		Labels   map[string]interface{} `json:"labels"`
+	}
+	raw := json.RawMessage(`{
+		"deviceId": 9007199254740993,
+		"offset": 18446744073709551615,
+		"extra": [1541815603606036480],
+		"labels": {"serial": 9007199254740993}
+	}`)
+	if err := engine.UnmarshalConfig(raw, &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.DeviceID != deviceID {
+		t.Errorf("deviceId = %d, want %d", config.DeviceID, deviceID)
+	}
+	if config.Offset != math.MaxUint64 {
+		t.Errorf("offset = %d, want %d", config.Offset, uint64(math.MaxUint64))
+	}
+	if want := []interface{}{snowflakeID}; !reflect.DeepEqual(config.Extra, want) {
+		t.Errorf("extra = %#v, want %#v", config.Extra, want)
+	}
+	if serial := config.Labels["serial"]; serial != deviceID {
+		t.Errorf("labels.serial = %#v, want %d", serial, deviceID)
+	}
+}
+
+func TestToInt(t *testing.T) {
+	tests := []struct {
+		value  interface{}
+		want   int64
+		wantOK bool
+	}{
+		{deviceID, deviceID, true},
+		{int32(-7), -7, true},
+		{uint64(math.MaxInt64), math.MaxInt64, true},
+		{uint64(math.MaxUint64), 0, false},
+		{float64(1 << 53), 1 << 53, true},
+		{1.5, 0, false},
+		{float64(1 << 63), 0, false},
+		{json.Number("9007199254740993"), deviceID, true},
+		{" 9007199254740993 ", deviceID, true},
+		{"9223372036854775808", 0, false},
+		{"12abc", 0, false},
+		{true, 0, false},
+		{nil, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := engine.ToInt(tt.value)
+		if ok != tt.wantOK || (ok && got != tt.want) {
+			t.Errorf("ToInt(%#v) = %d, %v, want %d, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// LibraryTypes are the kinds of library entries: flow fragments, function
+// bodies and templates
+var LibraryTypes = []string{"flows", "functions", "templates"}
+
+// ErrInvalidLibraryPath is returned for library entries of unknown types
+// or with invalid paths
+var ErrInvalidLibraryPath = errors.New("invalid library path")
+
+var librarySegmentPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9 ._-]*$`)
+
+// newLibraryStore returns the library: the storage if it is a library
+// store, and memory otherwise
+func newLibraryStore(store storage.Storage) storage.LibraryStore {
+	if library, ok := store.(storage.LibraryStore); ok {
+		return library
+	}
+	return storage.NewMemoryStorage()
+}
+
+// ValidateLibraryPath checks the type and path of a library entry. Paths
+// are names separated by slashes, so that they can be used as file paths.
+func ValidateLibraryPath(libraryType, path string) error {
+	known := false
+	for _, t := range LibraryTypes {
+		known = known || t == libraryType
+	}
+	if !known {
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidLibraryPath, libraryType)
+	}
+	if path == "" {
+		return fmt.Errorf("%w: empty path", ErrInvalidLibraryPath)
+	}
+	for _, segment := range strings.Split(path, "/") {
+		if !librarySegmentPattern.MatchString(segment) || strings.Trim(segment, ".") == "" {
+			return fmt.Errorf("%w %q", ErrInvalidLibraryPath, path)
+		}
+	}
+	return nil
+}
+
+// SaveLibraryEntry saves an entry of the library with its JSON body. An
+// entry that replaces another keeps its created time.
+func (e *Engine) SaveLibraryEntry(entry storage.LibraryEntry, body []byte) (storage.LibraryEntry, error) {
+	if err := ValidateLibraryPath(entry.Type, entry.Path); err != nil {
+		return entry, err
+	}
+	if entry.Name == "" {
+		entry.Name = entry.Path[strings.LastIndex(entry.Path, "/")+1:]
+	}
+	entry.Created = e.clock.Now().UTC()
+	if existing, _, err := e.library.LoadLibraryEntry(entry.Type, entry.Path); err == nil {
+		entry.Created = existing.Created
+	}
+	if err := e.library.SaveLibraryEntry(entry, body); err != nil {
+		return entry, fmt.Errorf("failed to save library entry: %w", err)
+	}
+	return entry, nil
+}
+
+// LibraryEntries lists the entries of a library type whose paths are below
+// dir, or all of them if dir is empty
+func (e *Engine) LibraryEntries(libraryType, dir string) ([]storage.LibraryEntry, error) {
+	prefix := ""
+	if dir != "" {
+		prefix = strings.TrimSuffix(dir, "/") + "/"
+	}
+	if err := ValidateLibraryPath(libraryType, prefix+"x"); err != nil {
+		return nil, err
+	}
+	return e.library.ListLibraryEntries(libraryType, prefix)
+}
+
+// LibraryEntry loads an entry of the library and its body
+func (e *Engine) LibraryEntry(libraryType, path string) (storage.LibraryEntry, []byte, error) {
+	if err := ValidateLibraryPath(libraryType, path); err != nil {
+		return storage.LibraryEntry{}, nil, err
+	}
+	return e.library.LoadLibraryEntry(libraryType, path)
+}
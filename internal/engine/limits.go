@@ -0,0 +1,374 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FlowStatusThrottled is the status of a running flow that refuses messages
+// because it exceeded one of its limits
+const FlowStatusThrottled FlowStatus = "throttled"
+
+// throttleHold is how long a flow stays throttled after it last exceeded a
+// limit
+const throttleHold = time.Second
+
+// ErrFlowThrottled is returned for messages refused because their flow
+// exceeded one of its limits
+var ErrFlowThrottled = errors.New("flow throttled")
+
+// FlowLimits bound the resources a flow may use; zero fields are unlimited.
+// Messages exceeding a limit are refused with ErrFlowThrottled.
+type FlowLimits struct {
+	// MaxMessagesPerSecond limits the messages sent by the flow's source
+	// nodes, those without incoming wires
+	MaxMessagesPerSecond float64 `json:"maxMessagesPerSecond,omitempty"`
+	// MaxQueued limits the messages waiting in the persistent queues and
+	// parallel instances of the flow's nodes
+	MaxQueued int `json:"maxQueued,omitempty"`
+	// MaxConcurrent limits the messages the flow's nodes handle at once. A
+	// message passed on synchronously counts once for every node it is
+	// passing through.
+	MaxConcurrent int `json:"maxConcurrent,omitempty"`
+}
+
+// merge returns the limits with their zero fields taken from defaults
+func (l FlowLimits) merge(defaults FlowLimits) FlowLimits {
+	if l.MaxMessagesPerSecond == 0 {
+		l.MaxMessagesPerSecond = defaults.MaxMessagesPerSecond
+	}
+	if l.MaxQueued == 0 {
+		l.MaxQueued = defaults.MaxQueued
+	}
+	if l.MaxConcurrent == 0 {
+		l.MaxConcurrent = defaults.MaxConcurrent
+	}
+	return l
+}
+
+func (l FlowLimits) validate() error {
+	if l.MaxMessagesPerSecond < 0 || l.MaxQueued < 0 || l.MaxConcurrent < 0 {
+		return fmt.Errorf("invalid limits: values must not be negative")
+	}
+	return nil
+}
+
+// FlowUsage reports the resources a flow uses
+type FlowUsage struct {
+	// Rate is the number of messages the flow's nodes sent in the last
+	// second
+	Rate int64 `json:"rate"`
+	Sent int64 `json:"sent"`
+	// Queued and QueuedBytes are the messages waiting in the flow's queues
+	// and an estimate of their size
+	Queued      int64 `json:"queued"`
+	QueuedBytes int64 `json:"queuedBytes"`
+	Executing   int64 `json:"executing"`
+	// Goroutines counts the goroutines the engine runs for the flow's
+	// persistent queues and parallel instances
+	Goroutines int `json:"goroutines"`
+	// Throttled counts the messages refused because a limit was exceeded
+	Throttled int64      `json:"throttled"`
+	Limits    FlowLimits `json:"limits"`
+}
+
+// flowUsage tracks the resources a flow uses; the counters are accessed
+// atomically
+type flowUsage struct {
+	sent        int64
+	queued      int64
+	queuedBytes int64
+	executing   int64
+	refused     int64
+
+	mu sync.Mutex
+	// tokens and filled are the token bucket limiting the source nodes
+	tokens float64
+	filled time.Time
+	// current counts the messages sent in the Unix second second, previous
+	// those sent in the second before
+	second   int64
+	current  int64
+	previous int64
+	// throttled is set until no limit was exceeded for throttleHold, which
+	// is after until; since and reason describe the throttled status
+	throttled bool
+	until     time.Time
+	since     time.Time
+	reason    string
+}
+
+// SetFlowLimits sets the limits of flows that do not set their own
+func (e *Engine) SetFlowLimits(limits FlowLimits) error {
+	if err := limits.validate(); err != nil {
+		return err
+	}
+	e.flowLimits.Store(limits)
+	return nil
+}
+
+// FlowLimits returns the limits of flows that do not set their own
+func (e *Engine) FlowLimits() FlowLimits {
+	limits, _ := e.flowLimits.Load().(FlowLimits)
+	return limits
+}
+
+// Limits returns the flow's limits, including those it takes from the
+// engine
+func (f *Flow) Limits() FlowLimits {
+	var limits FlowLimits
+	if f.limits != nil {
+		limits = *f.limits
+	}
+	if f.engine != nil {
+		limits = limits.merge(f.engine.FlowLimits())
+	}
+	return limits
+}
+
+// Usage returns the resources the flow uses
+func (f *Flow) Usage() FlowUsage {
+	u := &f.usage
+	usage := FlowUsage{
+		Sent:        atomic.LoadInt64(&u.sent),
+		Queued:      atomic.LoadInt64(&u.queued),
+		QueuedBytes: atomic.LoadInt64(&u.queuedBytes),
+		Executing:   atomic.LoadInt64(&u.executing),
+		Throttled:   atomic.LoadInt64(&u.refused),
+		Limits:      f.Limits(),
+	}
+
+	now := f.clock().Now().Unix()
+	u.mu.Lock()
+	switch now {
+	case u.second:
+		usage.Rate = u.previous
+	case u.second + 1:
+		usage.Rate = u.current
+	}
+	u.mu.Unlock()
+
+	f.mu.RLock()
+	for _, node := range f.Nodes {
+		usage.Goroutines += node.workers()
+	}
+	f.mu.RUnlock()
+	return usage
+}
+
+// markSources marks the nodes without incoming wires, whose messages are
+// rate limited
+func (f *Flow) markSources() {
+	for _, node := range f.Nodes {
+		node.source = true
+	}
+	for _, wire := range f.connections {
+		if node, exists := f.Nodes[wire.Target]; exists {
+			node.source = false
+		}
+	}
+}
+
+// countSent counts a message the node sends. Messages of source nodes are
+// refused while the flow exceeds its rate limit.
+func (n *Node) countSent() error {
+	f := n.flow
+	if f == nil {
+		return nil
+	}
+	u := &f.usage
+	now := f.clock().Now()
+
+	var max float64
+	if n.source {
+		max = f.Limits().MaxMessagesPerSecond
+	}
+
+	u.mu.Lock()
+	if max > 0 {
+		// The bucket holds a second's worth of messages
+		if u.filled.IsZero() {
+			u.tokens = max
+		} else {
+			u.tokens += now.Sub(u.filled).Seconds() * max
+		}
+		if u.tokens > max {
+			u.tokens = max
+		}
+		u.filled = now
+		if u.tokens < 1 {
+			u.mu.Unlock()
+			return f.throttle(fmt.Sprintf("node %s exceeded %g messages per second", n.ID, max))
+		}
+		u.tokens--
+	}
+	if second := now.Unix(); second != u.second {
+		if second == u.second+1 {
+			u.previous = u.current
+		} else {
+			u.previous = 0
+		}
+		u.current = 0
+		u.second = second
+	}
+	u.current++
+	u.mu.Unlock()
+
+	atomic.AddInt64(&u.sent, 1)
+	return nil
+}
+
+// enter counts a message the node starts handling, refusing it while the
+// flow handles as many messages as it may at once; leave ends it
+func (f *Flow) enter(node *Node) error {
+	if f == nil {
+		return nil
+	}
+	executing := atomic.AddInt64(&f.usage.executing, 1)
+	if max := f.Limits().MaxConcurrent; max > 0 && executing > int64(max) {
+		atomic.AddInt64(&f.usage.executing, -1)
+		return f.throttle(fmt.Sprintf("node %s exceeded %d concurrent executions", node.ID, max))
+	}
+	return nil
+}
+
+func (f *Flow) leave() {
+	if f != nil {
+		atomic.AddInt64(&f.usage.executing, -1)
+	}
+}
+
+// admitQueued counts a message of size bytes queued for the node, refusing
+// it while the flow's queues hold as many messages as they may
+func (f *Flow) admitQueued(node *Node, size int64) error {
+	if f == nil {
+		return nil
+	}
+	queued := atomic.AddInt64(&f.usage.queued, 1)
+	if max := f.Limits().MaxQueued; max > 0 && queued > int64(max) {
+		atomic.AddInt64(&f.usage.queued, -1)
+		return f.throttle(fmt.Sprintf("queue of node %s exceeded %d queued messages", node.ID, max))
+	}
+	atomic.AddInt64(&f.usage.queuedBytes, size)
+	return nil
+}
+
+// addQueued adjusts the count and size of the flow's queued messages
+func (f *Flow) addQueued(count, size int64) {
+	if f != nil {
+		atomic.AddInt64(&f.usage.queued, count)
+		atomic.AddInt64(&f.usage.queuedBytes, size)
+	}
+}
+
+// throttle counts a refused message and puts the flow into the throttled
+// status until no limit was exceeded for throttleHold
+func (f *Flow) throttle(reason string) error {
+	u := &f.usage
+	atomic.AddInt64(&u.refused, 1)
+	now := f.clock().Now()
+
+	u.mu.Lock()
+	u.until = now.Add(throttleHold)
+	start := !u.throttled
+	if start {
+		u.throttled = true
+		u.since = now
+		u.reason = reason
+	}
+	u.mu.Unlock()
+
+	if start {
+		f.Logger().Warn("flow throttled", "reason", reason)
+		f.publish(f.statusEvent(FlowState{Status: FlowStatusThrottled, Since: now, Reason: reason}))
+		go f.unthrottle()
+	}
+	return fmt.Errorf("%w: %s", ErrFlowThrottled, reason)
+}
+
+// unthrottle waits until the flow exceeded no limit for throttleHold and
+// announces that it runs normally again
+func (f *Flow) unthrottle() {
+	u := &f.usage
+	clock := f.clock()
+	for {
+		u.mu.Lock()
+		wait := u.until.Sub(clock.Now())
+		if wait <= 0 {
+			u.throttled = false
+			u.mu.Unlock()
+			break
+		}
+		u.mu.Unlock()
+		<-clock.NewTimer(wait).C()
+	}
+
+	if state := f.State(); state.Status == FlowStatusRunning {
+		f.publish(f.statusEvent(state))
+	}
+}
+
+// throttleState returns the throttled status of the flow, if it is
+// throttled
+func (f *Flow) throttleState() (FlowState, bool) {
+	u := &f.usage
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.throttled {
+		return FlowState{}, false
+	}
+	return FlowState{Status: FlowStatusThrottled, Since: u.since, Reason: u.reason}, true
+}
+
+// workers returns the number of goroutines the engine runs for the node's
+// queue and instances
+func (n *Node) workers() int {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	workers := 0
+	if n.inbox != nil {
+		workers++
+	}
+	if n.parallel != nil && n.running {
+		workers += len(n.parallel.instances)
+	}
+	return workers
+}
+
+// messageSize estimates the memory a message takes
+func messageSize(msg *Message) int64 {
+	return int64(len(msg.MsgID)+len(msg.Topic)) + valueSize(msg.Payload)
+}
+
+// valueSize estimates the memory a decoded JSON value takes
+func valueSize(value interface{}) int64 {
+	switch value := value.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(value))
+	case []byte:
+		return int64(len(value))
+	case json.RawMessage:
+		return int64(len(value))
+	case map[string]interface{}:
+		var size int64
+		for key, v := range value {
+			size += int64(len(key)) + valueSize(v)
+		}
+		return size
+	case []interface{}:
+		var size int64
+		for _, v := range value {
+			size += valueSize(v)
+		}
+		return size
+	default:
+		return 8
+	}
+}
@@ -0,0 +1,281 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// EventFlowLock is published when a flow lock is acquired, released or
+// expires
+const EventFlowLock = "flow-lock"
+
+// defaultLockTTL is how long a lock lasts without being renewed
+const defaultLockTTL = 60 * time.Second
+
+// ErrFlowNotLocked is returned when renewing a lock the user does not hold
+var ErrFlowNotLocked = errors.New("flow is not locked by this user")
+
+// FlowLockedError is returned when a flow is locked by another user
+type FlowLockedError struct {
+	Lock storage.FlowLock
+}
+
+func (e *FlowLockedError) Error() string {
+	return fmt.Sprintf("flow %s is locked by %s", e.Lock.FlowID, e.Lock.Holder)
+}
+
+// FlowLocks keeps the advisory locks editors take on the flows they edit.
+// Locks expire unless renewed within their TTL. They live in the storage
+// when it is a storage.LockStore, so that every instance of a cluster sees
+// them, and in memory otherwise.
+type FlowLocks struct {
+	engine *Engine
+	store  storage.LockStore
+	ttl    time.Duration
+	timers map[string]*lockTimer
+	mu     sync.Mutex
+}
+
+// lockTimer expires a lock unless reset or stopped
+type lockTimer struct {
+	timer Timer
+	done  chan struct{}
+}
+
+func newFlowLocks(e *Engine, store storage.Storage) *FlowLocks {
+	lockStore, ok := store.(storage.LockStore)
+	if !ok {
+		lockStore = &memoryLockStore{locks: make(map[string]storage.FlowLock)}
+	}
+	return &FlowLocks{
+		engine: e,
+		store:  lockStore,
+		ttl:    defaultLockTTL,
+		timers: make(map[string]*lockTimer),
+	}
+}
+
+// SetTTL changes how long locks last without being renewed
+func (l *FlowLocks) SetTTL(ttl time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ttl = ttl
+}
+
+// Get returns the lock held on a flow, if any
+func (l *FlowLocks) Get(flowID string) (storage.FlowLock, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.load(flowID)
+}
+
+// Acquire locks a flow for holder, or renews the lock if holder already has
+// it. A *FlowLockedError is returned while another user holds the lock.
+func (l *FlowLocks) Acquire(flowID, holder string) (storage.FlowLock, error) {
+	if holder == "" {
+		return storage.FlowLock{}, fmt.Errorf("lock holder is required")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	current, locked, err := l.load(flowID)
+	if err != nil {
+		return storage.FlowLock{}, err
+	}
+	if locked && current.Holder != holder {
+		return storage.FlowLock{}, &FlowLockedError{Lock: current}
+	}
+
+	now := l.engine.Clock().Now()
+	lock := storage.FlowLock{FlowID: flowID, Holder: holder, Acquired: now, Expires: now.Add(l.ttl)}
+	if locked {
+		lock.Acquired = current.Acquired
+	}
+	if err := l.save(lock); err != nil {
+		return storage.FlowLock{}, err
+	}
+	if !locked {
+		l.publish(lock, "acquired")
+	}
+	return lock, nil
+}
+
+// Renew extends the lock holder has on a flow by the TTL
+func (l *FlowLocks) Renew(flowID, holder string) (storage.FlowLock, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lock, locked, err := l.load(flowID)
+	if err != nil {
+		return storage.FlowLock{}, err
+	}
+	if !locked || lock.Holder != holder {
+		return storage.FlowLock{}, fmt.Errorf("%w: %s", ErrFlowNotLocked, flowID)
+	}
+	lock.Expires = l.engine.Clock().Now().Add(l.ttl)
+	if err := l.save(lock); err != nil {
+		return storage.FlowLock{}, err
+	}
+	return lock, nil
+}
+
+// Release removes the lock holder has on a flow. Unless force is set, a
+// *FlowLockedError is returned if another user holds it. Releasing a flow
+// that is not locked does nothing.
+func (l *FlowLocks) Release(flowID, holder string, force bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lock, locked, err := l.load(flowID)
+	if err != nil || !locked {
+		return err
+	}
+	if lock.Holder != holder && !force {
+		return &FlowLockedError{Lock: lock}
+	}
+	if err := l.remove(flowID); err != nil {
+		return err
+	}
+
+	reason := "released"
+	if lock.Holder != holder {
+		reason = "forced"
+	}
+	l.publish(lock, reason)
+	return nil
+}
+
+// Check returns a *FlowLockedError if a user other than user holds the lock
+// on a flow
+func (l *FlowLocks) Check(flowID, user string) error {
+	lock, locked, err := l.Get(flowID)
+	if err != nil {
+		return err
+	}
+	if locked && lock.Holder != user {
+		return &FlowLockedError{Lock: lock}
+	}
+	return nil
+}
+
+// load returns the unexpired lock of a flow
+func (l *FlowLocks) load(flowID string) (storage.FlowLock, bool, error) {
+	lock, exists, err := l.store.LoadFlowLock(flowID)
+	if err != nil {
+		return storage.FlowLock{}, false, fmt.Errorf("failed to load lock of flow %s: %w", flowID, err)
+	}
+	if !exists || !lock.Expires.After(l.engine.Clock().Now()) {
+		return storage.FlowLock{}, false, nil
+	}
+	return lock, true, nil
+}
+
+// save stores a lock and schedules its expiry
+func (l *FlowLocks) save(lock storage.FlowLock) error {
+	if err := l.store.SaveFlowLock(lock); err != nil {
+		return fmt.Errorf("failed to save lock of flow %s: %w", lock.FlowID, err)
+	}
+
+	wait := lock.Expires.Sub(l.engine.Clock().Now())
+	if t, exists := l.timers[lock.FlowID]; exists {
+		t.timer.Reset(wait)
+		return nil
+	}
+	t := &lockTimer{timer: l.engine.Clock().NewTimer(wait), done: make(chan struct{})}
+	l.timers[lock.FlowID] = t
+	go func() {
+		for {
+			select {
+			case <-t.timer.C():
+				if l.expire(lock.FlowID, t) {
+					return
+				}
+			case <-t.done:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// remove deletes a lock and cancels its expiry
+func (l *FlowLocks) remove(flowID string) error {
+	if err := l.store.DeleteFlowLock(flowID); err != nil {
+		return fmt.Errorf("failed to delete lock of flow %s: %w", flowID, err)
+	}
+	if t, exists := l.timers[flowID]; exists {
+		t.timer.Stop()
+		close(t.done)
+		delete(l.timers, flowID)
+	}
+	return nil
+}
+
+// expire removes a lock that was not renewed in time. It returns false if
+// the lock was renewed and t was reset in the meantime.
+func (l *FlowLocks) expire(flowID string, t *lockTimer) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.timers[flowID] != t {
+		return true
+	}
+	lock, exists, err := l.store.LoadFlowLock(flowID)
+	if err != nil {
+		l.engine.Logger().Warn("failed to expire lock of flow", "flow_id", flowID, "error", err)
+		return false
+	}
+	if exists && lock.Expires.After(l.engine.Clock().Now()) {
+		// Renewed through another instance
+		t.timer.Reset(lock.Expires.Sub(l.engine.Clock().Now()))
+		return false
+	}
+
+	delete(l.timers, flowID)
+	if exists {
+		if err := l.store.DeleteFlowLock(flowID); err != nil {
+			l.engine.Logger().Warn("failed to delete expired lock of flow", "flow_id", flowID, "error", err)
+		}
+		l.publish(lock, "expired")
+	}
+	return true
+}
+
+func (l *FlowLocks) publish(lock storage.FlowLock, reason string) {
+	workspace, flowID := SplitFlowKey(lock.FlowID)
+	l.engine.events.Publish(Event{
+		Type:      EventFlowLock,
+		Workspace: workspace,
+		FlowID:    flowID,
+		Data: map[string]interface{}{
+			"holder": lock.Holder,
+			"reason": reason,
+			"locked": reason == "acquired",
+		},
+	})
+}
+
+// memoryLockStore keeps the locks of a single instance
+type memoryLockStore struct {
+	locks map[string]storage.FlowLock
+}
+
+func (s *memoryLockStore) LoadFlowLock(flowID string) (storage.FlowLock, bool, error) {
+	lock, exists := s.locks[flowID]
+	return lock, exists, nil
+}
+
+func (s *memoryLockStore) SaveFlowLock(lock storage.FlowLock) error {
+	s.locks[lock.FlowID] = lock
+	return nil
+}
+
+func (s *memoryLockStore) DeleteFlowLock(flowID string) error {
+	delete(s.locks, flowID)
+	return nil
+}
@@ -0,0 +1,258 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ParseLogLevel parses a level name: debug, info, warn or error
+func ParseLogLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", name)
+}
+
+// logging holds the handler and the levels of the engine's loggers, which
+// may change while they are in use
+type logging struct {
+	mu         sync.RWMutex
+	handler    slog.Handler
+	level      slog.Level
+	flowLevels map[string]slog.Level
+	listeners  map[int]func(LogRecord)
+	nextID     int
+}
+
+// current returns the handler to write to and the level of a flow's
+// records, or the engine's for flow ""
+func (l *logging) current(flow string) (slog.Handler, slog.Level) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	handler := l.handler
+	if handler == nil {
+		handler = slog.Default().Handler()
+	}
+	if level, ok := l.flowLevels[flow]; ok && flow != "" {
+		return handler, level
+	}
+	return handler, l.level
+}
+
+// LogRecord is a warning or error written by one of the engine's loggers,
+// as passed to log listeners
+type LogRecord struct {
+	Time    time.Time              `json:"time"`
+	Level   string                 `json:"level"`
+	Message string                 `json:"message"`
+	FlowID  string                 `json:"flowId,omitempty"`
+	NodeID  string                 `json:"nodeId,omitempty"`
+	Attrs   map[string]interface{} `json:"attrs,omitempty"`
+}
+
+// OnLog has listener called with every warning and error the engine's
+// loggers write, whatever their level, and returns a function removing it.
+// It is called synchronously from the logging goroutine and must neither
+// block nor log.
+func (e *Engine) OnLog(listener func(LogRecord)) (remove func()) {
+	e.logging.mu.Lock()
+	defer e.logging.mu.Unlock()
+	if e.logging.listeners == nil {
+		e.logging.listeners = make(map[int]func(LogRecord))
+	}
+	id := e.logging.nextID
+	e.logging.nextID++
+	e.logging.listeners[id] = listener
+	return func() {
+		e.logging.mu.Lock()
+		defer e.logging.mu.Unlock()
+		delete(e.logging.listeners, id)
+	}
+}
+
+// notify passes a record to the log listeners
+func (l *logging) notify(record LogRecord) {
+	l.mu.RLock()
+	listeners := make([]func(LogRecord), 0, len(l.listeners))
+	for _, listener := range l.listeners {
+		listeners = append(listeners, listener)
+	}
+	l.mu.RUnlock()
+	for _, listener := range listeners {
+		listener(record)
+	}
+}
+
+// SetLogHandler sets the handler the engine's loggers write to; nil writes
+// to slog's default. The engine filters records by level, so the handler
+// should accept all of them.
+func (e *Engine) SetLogHandler(handler slog.Handler) {
+	e.logging.mu.Lock()
+	defer e.logging.mu.Unlock()
+	e.logging.handler = handler
+}
+
+// SetLogLevel sets the lowest level the engine's loggers write
+func (e *Engine) SetLogLevel(level slog.Level) {
+	e.logging.mu.Lock()
+	defer e.logging.mu.Unlock()
+	e.logging.level = level
+}
+
+// SetFlowLogLevels overrides the log level for the flows of levels, keyed
+// by flow key; other flows log at the engine's level
+func (e *Engine) SetFlowLogLevels(levels map[string]slog.Level) {
+	copied := make(map[string]slog.Level, len(levels))
+	for key, level := range levels {
+		copied[key] = level
+	}
+	e.logging.mu.Lock()
+	defer e.logging.mu.Unlock()
+	e.logging.flowLevels = copied
+}
+
+// Logger returns the logger of the engine
+func (e *Engine) Logger() *slog.Logger {
+	return slog.New(&levelHandler{logging: e.logging})
+}
+
+// Logger returns the logger of the flow, whose records carry its flow_id
+// and which logs at the flow's level
+func (f *Flow) Logger() *slog.Logger {
+	if f.engine == nil {
+		return slog.Default().With("flow_id", f.Key())
+	}
+	logger := slog.New(&levelHandler{logging: f.engine.logging, flow: f.Key()})
+	return logger.With("flow_id", f.Key())
+}
+
+// Logger returns the logger of the node, whose records carry its flow_id,
+// node_id and node_type. Node instances log through it.
+func (n *Node) Logger() *slog.Logger {
+	logger := slog.Default()
+	if n.flow != nil {
+		logger = n.flow.Logger()
+	}
+	nodeType := ""
+	if n.Type != nil {
+		nodeType = n.Type.Name
+	}
+	return logger.With("node_id", n.ID, "node_type", nodeType)
+}
+
+// levelHandler filters records by the level of the engine or a flow and
+// passes the others to the engine's current handler
+type levelHandler struct {
+	logging *logging
+	flow    string
+	// wrap adds the attributes and groups of With and WithGroup to the
+	// current handler
+	wrap []func(slog.Handler) slog.Handler
+	// attrs are the attributes of With, keyed by their group path, for
+	// the log listeners
+	attrs  []slog.Attr
+	prefix string
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if level >= slog.LevelWarn && h.hasListeners() {
+		return true
+	}
+	handler, min := h.logging.current(h.flow)
+	return level >= min && handler.Enabled(ctx, level)
+}
+
+func (h *levelHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level >= slog.LevelWarn && h.hasListeners() {
+		h.logging.notify(h.logRecord(record))
+	}
+	handler, min := h.logging.current(h.flow)
+	if record.Level < min || !handler.Enabled(ctx, record.Level) {
+		return nil
+	}
+	for _, wrap := range h.wrap {
+		handler = wrap(handler)
+	}
+	return handler.Handle(ctx, record)
+}
+
+// hasListeners reports whether any log listener is registered
+func (h *levelHandler) hasListeners() bool {
+	h.logging.mu.RLock()
+	defer h.logging.mu.RUnlock()
+	return len(h.logging.listeners) > 0
+}
+
+// logRecord converts a record for the log listeners
+func (h *levelHandler) logRecord(record slog.Record) LogRecord {
+	result := LogRecord{
+		Time:    record.Time,
+		Level:   strings.ToLower(record.Level.String()),
+		Message: record.Message,
+		Attrs:   make(map[string]interface{}),
+	}
+	add := func(prefix string, attr slog.Attr) {
+		value := attr.Value.Resolve()
+		switch prefix + attr.Key {
+		case "flow_id":
+			result.FlowID = value.String()
+		case "node_id":
+			result.NodeID = value.String()
+		default:
+			if value.Kind() == slog.KindAny {
+				if err, ok := value.Any().(error); ok {
+					result.Attrs[prefix+attr.Key] = err.Error()
+					return
+				}
+			}
+			result.Attrs[prefix+attr.Key] = value.Any()
+		}
+	}
+	for _, attr := range h.attrs {
+		add("", attr)
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		add(h.prefix, attr)
+		return true
+	})
+	if len(result.Attrs) == 0 {
+		result.Attrs = nil
+	}
+	return result
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handler := h.with(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+	for _, attr := range attrs {
+		attr.Key = h.prefix + attr.Key
+		handler.attrs = append(handler.attrs, attr)
+	}
+	return handler
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	handler := h.with(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+	handler.prefix = h.prefix + name + "."
+	return handler
+}
+
+func (h *levelHandler) with(wrap func(slog.Handler) slog.Handler) *levelHandler {
+	return &levelHandler{
+		logging: h.logging,
+		flow:    h.flow,
+		wrap:    append(h.wrap[:len(h.wrap):len(h.wrap)], wrap),
+		attrs:   h.attrs[:len(h.attrs):len(h.attrs)],
+		prefix:  h.prefix,
+	}
+}
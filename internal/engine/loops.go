@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultLoopTTL is the number of times a message may be sent on in a flow
+// with loops, unless SetLoopTTL sets another
+const DefaultLoopTTL = 100
+
+// TTLMetadata is the metadata key under which messages in flows with loops
+// carry the number of times they may still be sent on
+const TTLMetadata = "ttl"
+
+// SetAllowLoops sets whether flows whose wires form a loop deploy. Messages
+// in such flows carry a TTL and are dropped when it runs out, instead of
+// circling until the stack overflows.
+func (e *Engine) SetAllowLoops(allow bool) {
+	var value int32
+	if allow {
+		value = 1
+	}
+	atomic.StoreInt32(&e.allowLoops, value)
+}
+
+// AllowLoops reports whether flows may contain loops
+func (e *Engine) AllowLoops() bool {
+	return atomic.LoadInt32(&e.allowLoops) == 1
+}
+
+// SetLoopTTL sets the number of times a message may be sent on in a flow
+// with loops; 0 restores DefaultLoopTTL
+func (e *Engine) SetLoopTTL(ttl int) {
+	atomic.StoreInt32(&e.loopTTL, int32(ttl))
+}
+
+// LoopTTL returns the number of times a message may be sent on in a flow
+// with loops
+func (e *Engine) LoopTTL() int {
+	if ttl := atomic.LoadInt32(&e.loopTTL); ttl > 0 {
+		return int(ttl)
+	}
+	return DefaultLoopTTL
+}
+
+// checkLoops rejects a flow whose wires form a loop, unless the engine
+// allows loops, in which case its messages are given a TTL. Disabled wires
+// count, as they can be enabled while the flow runs.
+func (f *Flow) checkLoops() error {
+	loop := f.findLoop()
+	if loop == nil {
+		return nil
+	}
+	if f.engine == nil || !f.engine.AllowLoops() {
+		return fmt.Errorf("flow contains a loop: %s; set flows.allowLoops to allow loops", strings.Join(loop, " -> "))
+	}
+	f.loops = true
+	return nil
+}
+
+// findLoop returns the IDs of the nodes of a loop of wires, starting and
+// ending with the same node, or nil if there is none
+func (f *Flow) findLoop() []string {
+	var order []string
+	targets := make(map[string][]string)
+	for _, wire := range f.connections {
+		if _, exists := targets[wire.Source]; !exists {
+			order = append(order, wire.Source)
+		}
+		targets[wire.Source] = append(targets[wire.Source], wire.Target)
+	}
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	var path []string
+	var visit func(id string) []string
+	visit = func(id string) []string {
+		switch state[id] {
+		case done:
+			return nil
+		case visiting:
+			for i, node := range path {
+				if node == id {
+					return append(append([]string{}, path[i:]...), id)
+				}
+			}
+		}
+		state[id] = visiting
+		path = append(path, id)
+		for _, target := range targets[id] {
+			if loop := visit(target); loop != nil {
+				return loop
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = done
+		return nil
+	}
+
+	for _, id := range order {
+		if loop := visit(id); loop != nil {
+			return loop
+		}
+	}
+	return nil
+}
+
+// countLoopHop counts a send of a message in a flow with loops against its
+// TTL and reports whether it may be sent; messages out of TTL are dropped
+func (n *Node) countLoopHop(msg *Message) bool {
+	if n.flow == nil || !n.flow.loops {
+		return true
+	}
+	ttl := int64(n.flow.engine.LoopTTL())
+	if value, ok := msg.GetMetadata(TTLMetadata); ok {
+		ttl, _ = ToInt(value)
+	}
+	if ttl <= 0 {
+		n.Logger().Warn("dropped message whose TTL ran out in a loop", "msg_id", msg.MsgID)
+		return false
+	}
+	msg.SetMetadata(TTLMetadata, ttl-1)
+	return true
+}
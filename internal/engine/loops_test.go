@@ -0,0 +1,107 @@
+package engine_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/registry"
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// loopNode sends every message it receives on. When a message comes back
+// to it, it waits for a writer of its own lock, which must not be blocked
+// by the send the message is still inside of.
+type loopNode struct {
+	engine.BaseNode
+	received int
+	writer   error
+}
+
+func (n *loopNode) Init(json.RawMessage) error      { return nil }
+func (n *loopNode) Start(ctx context.Context) error { return nil }
+func (n *loopNode) Stop()                           {}
+
+func (n *loopNode) OnMessage(msg *engine.Message, port int) error {
+	n.received++
+	if n.received == 2 {
+		done := make(chan struct{})
+		go func() {
+			n.GetNode().SetStatus(engine.NodeStatus{Text: "looping"})
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			n.writer = errors.New("SetStatus blocked while a message looped back")
+		}
+	}
+	return n.GetNode().Send(msg, 0)
+}
+
+func TestTwoNodeLoop(t *testing.T) {
+	reg := registry.New()
+	var created []*loopNode
+	if err := reg.RegisterNodeType(&engine.NodeType{
+		Name: "loop",
+		Factory: func() engine.NodeInstance {
+			instance := &loopNode{}
+			created = append(created, instance)
+			return instance
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	eng := engine.New(reg, storage.NewMemoryStorage())
+	eng.SetAllowLoops(true)
+	eng.SetLoopTTL(10)
+	if err := eng.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	flowDef := []byte(`{
+		"id": "loop",
+		"nodes": [{"id": "a", "type": "loop"}, {"id": "b", "type": "loop"}],
+		"wires": [{"source": "a", "target": "b", "port": 0}, {"source": "b", "target": "a", "port": 0}]
+	}`)
+	if err := eng.DeployFlow("loop", flowDef); err != nil {
+		t.Fatal(err)
+	}
+	flow, _ := eng.GetFlow("loop")
+	instances := make(map[string]*loopNode)
+	for _, instance := range created {
+		if node, ok := flow.GetNode(instance.GetNode().ID); ok && node == instance.GetNode() {
+			instances[node.ID] = instance
+		}
+	}
+	if len(instances) != 2 {
+		t.Fatalf("found %d running nodes, want 2", len(instances))
+	}
+
+	a, _ := flow.GetNode("a")
+	done := make(chan error, 1)
+	go func() { done <- a.Receive(engine.NewMessage("ping", ""), 0) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		// Stopping would block on the deadlocked node too
+		t.Fatal("message loop deadlocked")
+	}
+	eng.Stop()
+
+	for id, instance := range instances {
+		if instance.writer != nil {
+			t.Errorf("node %s: %v", id, instance.writer)
+		}
+	}
+	// Each of the 10 sends the TTL allows delivers the message once more
+	if got := instances["a"].received + instances["b"].received; got != 11 {
+		t.Errorf("nodes received %d messages, want 11", got)
+	}
+}
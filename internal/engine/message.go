@@ -1,7 +1,13 @@
 package engine
 
 import (
+	"crypto/rand"
 	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -14,6 +20,11 @@ type Message struct {
 	SourceID string                 `json:"sourceId"`
 	MsgID    string                 `json:"msgId"`
 	Timestamp time.Time             `json:"timestamp"`
+	// Priority decides the order in which node inboxes deliver messages
+	Priority Priority               `json:"priority,omitempty"`
+
+	// delivery is shared by all copies of a message sent with SendWithAck
+	delivery *delivery
 }
 
 // NewMessage creates a new message with the given payload
@@ -23,7 +34,7 @@ func NewMessage(payload interface{}, topic string) *Message {
 		Topic:     topic,
 		Headers:   make(map[string]string),
 		Metadata:  make(map[string]interface{}),
-		MsgID:     generateUUID(),
+		MsgID:     NewUUID(),
 		Timestamp: time.Now(),
 	}
 }
@@ -35,8 +46,10 @@ func (m *Message) Clone() *Message {
 		SourceID:  m.SourceID,
 		MsgID:     m.MsgID,
 		Timestamp: m.Timestamp,
+		Priority:  m.Priority,
 		Headers:   make(map[string]string),
 		Metadata:  make(map[string]interface{}),
+		delivery:  m.delivery,
 	}
 	
 	// Copy headers
@@ -44,27 +57,12 @@ func (m *Message) Clone() *Message {
 		clone.Headers[k] = v
 	}
 	
-	// Copy metadata
+	// Deep copy metadata and payload, keeping their types; streams can
+	// only be read once, so the clone shares them
 	for k, v := range m.Metadata {
-		clone.Metadata[k] = v
-	}
-	
-	// Deep copy payload
-	if m.Payload != nil {
-		payloadBytes, err := json.Marshal(m.Payload)
-		if err == nil {
-			var clonedPayload interface{}
-			if err = json.Unmarshal(payloadBytes, &clonedPayload); err == nil {
-				clone.Payload = clonedPayload
-			} else {
-				// Fallback to shallow copy if deep copy fails
-				clone.Payload = m.Payload
-			}
-		} else {
-			// Fallback to shallow copy if deep copy fails
-			clone.Payload = m.Payload
-		}
+		clone.Metadata[k] = CloneValue(v)
 	}
+	clone.Payload = CloneValue(m.Payload)
 	
 	return clone
 }
@@ -101,33 +99,248 @@ func (m *Message) GetMetadata(key string) (interface{}, bool) {
 	return value, exists
 }
 
+// GetProperty gets a value by dot-separated property path, e.g. "payload",
+// "payload.temperature", "topic" or "metadata.key"
+func (m *Message) GetProperty(path string) (interface{}, bool) {
+	parts := splitPropertyPath(path)
+	if len(parts) == 0 {
+		return nil, false
+	}
+
+	var current interface{}
+	switch parts[0] {
+	case "payload":
+		current = m.Payload
+	case "topic":
+		current = m.Topic
+	case "headers":
+		if len(parts) != 2 {
+			return nil, false
+		}
+		value, exists := m.Headers[parts[1]]
+		return value, exists
+	case "metadata":
+		current = m.Metadata
+	default:
+		return nil, false
+	}
+
+	for _, part := range parts[1:] {
+		switch c := current.(type) {
+		case map[string]interface{}:
+			value, exists := c[part]
+			if !exists {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= len(c) {
+				return nil, false
+			}
+			current = c[index]
+		default:
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// SetProperty sets a value by dot-separated property path, creating
+// intermediate objects as needed
+func (m *Message) SetProperty(path string, value interface{}) error {
+	parts := splitPropertyPath(path)
+	if len(parts) == 0 {
+		return fmt.Errorf("invalid property path: %q", path)
+	}
+
+	switch parts[0] {
+	case "payload":
+		if len(parts) == 1 {
+			m.Payload = value
+			return nil
+		}
+		root, ok := m.Payload.(map[string]interface{})
+		if !ok {
+			if m.Payload != nil {
+				return fmt.Errorf("cannot set %s: payload is not an object", path)
+			}
+			root = make(map[string]interface{})
+			m.Payload = root
+		}
+		return setPropertyPath(root, parts[1:], value)
+	case "topic":
+		if len(parts) != 1 {
+			return fmt.Errorf("cannot set %s: topic is not an object", path)
+		}
+		m.Topic = fmt.Sprintf("%v", value)
+		return nil
+	case "headers":
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid header path: %q", path)
+		}
+		if m.Headers == nil {
+			m.Headers = make(map[string]string)
+		}
+		m.Headers[parts[1]] = fmt.Sprintf("%v", value)
+		return nil
+	case "metadata":
+		if len(parts) == 1 {
+			return fmt.Errorf("cannot replace metadata")
+		}
+		if m.Metadata == nil {
+			m.Metadata = make(map[string]interface{})
+		}
+		return setPropertyPath(m.Metadata, parts[1:], value)
+	default:
+		return fmt.Errorf("unknown message property: %s", parts[0])
+	}
+}
+
 // ToJSON converts the message to JSON
 func (m *Message) ToJSON() ([]byte, error) {
 	return json.Marshal(m)
 }
 
-// FromJSON populates the message from JSON
+// FromJSON populates the message from JSON, keeping the precision of
+// integers in the payload and metadata, see DecodeJSON
 func (m *Message) FromJSON(data []byte) error {
-	return json.Unmarshal(data, m)
-}
-
-// generateUUID generates a simple UUID
-// In a production environment, use a proper UUID library
-func generateUUID() string {
-	return time.Now().Format("20060102150405.000000000") + "-" + 
-		   randomHex(4) + "-" + 
-		   randomHex(4) + "-" + 
-		   randomHex(4) + "-" + 
-		   randomHex(12)
-}
-
-// randomHex generates a random hex string of the given length
-func randomHex(length int) string {
-	const hexChars = "0123456789abcdef"
-	result := make([]byte, length)
-	for i := 0; i < length; i++ {
-		result[i] = hexChars[time.Now().UnixNano()%16]
-		time.Sleep(1 * time.Nanosecond) // Ensure different values
+	return DecodeJSON(data, m)
+}
+
+// ToFloat converts a numeric value, or a string holding a number, to
+// float64. Integers beyond 2^53 are rounded; use ToInt for them.
+func ToFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// ToInt converts an integer value, a float64 without fraction or a string
+// holding an integer to int64. Values outside the int64 range are rejected
+// rather than wrapped.
+func ToInt(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return ToInt(uint64(v))
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	case float32:
+		return ToInt(float64(v))
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	case json.Number:
+		return ToInt(jsonNumber(v))
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		return i, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// splitPropertyPath splits a property path into its parts, accepting an
+// optional "msg." prefix
+func splitPropertyPath(path string) []string {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "msg.")
+	if path == "" {
+		return nil
 	}
-	return string(result)
+	return strings.Split(path, ".")
+}
+
+// setPropertyPath sets a value in a nested map, creating intermediate maps
+func setPropertyPath(root map[string]interface{}, parts []string, value interface{}) error {
+	current := root
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(map[string]interface{})
+		if !ok {
+			if _, exists := current[part]; exists {
+				return fmt.Errorf("property %s is not an object", part)
+			}
+			next = make(map[string]interface{})
+			current[part] = next
+		}
+		current = next
+	}
+	current[parts[len(parts)-1]] = value
+	return nil
+}
+
+// NewUUID generates a random (version 4) UUID
+func NewUUID() string {
+	id, err := NewUUIDFromReader(rand.Reader)
+	if err != nil {
+		// crypto/rand only fails if the system entropy source is broken
+		panic(fmt.Sprintf("failed to generate UUID: %v", err))
+	}
+	return id
+}
+
+// NewUUIDFromReader generates a version 4 UUID using random bytes read from
+// r, which allows deterministic IDs when r is a seeded generator
+func NewUUIDFromReader(r io.Reader) (string, error) {
+	var b [16]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return "", err
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
 }
@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// NetworkProfile holds the outbound proxy and TLS settings of network
+// nodes. The profile named "" is the default.
+type NetworkProfile struct {
+	// HTTPProxy and HTTPSProxy are the proxy URLs for plain and TLS
+	// requests; NoProxy lists hosts reached directly, like $NO_PROXY. If
+	// none is set, the proxy environment variables apply.
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+	// CAFile is a PEM file of certificate authorities trusted in addition
+	// to the system's
+	CAFile             string
+	InsecureSkipVerify bool
+}
+
+// Network builds the shared transports of the network profiles. Clients it
+// returns keep working when the profiles are reconfigured and use the new
+// settings from their next request on.
+type Network struct {
+	profiles map[string]*networkProfile
+	mu       sync.RWMutex
+}
+
+// networkProfile is a profile's shared transport
+type networkProfile struct {
+	transport *http.Transport
+	tls       *tls.Config
+}
+
+func newNetwork() *Network {
+	// Without settings, building a profile cannot fail
+	defaultProfile, _ := buildNetworkProfile(NetworkProfile{})
+	return &Network{profiles: map[string]*networkProfile{"": defaultProfile}}
+}
+
+// Configure replaces the network profiles. A default profile is added if
+// profiles has none. Nothing changes if a profile is invalid.
+func (n *Network) Configure(profiles map[string]NetworkProfile) error {
+	built := make(map[string]*networkProfile, len(profiles)+1)
+	if _, exists := profiles[""]; !exists {
+		built[""], _ = buildNetworkProfile(NetworkProfile{})
+	}
+	for name, profile := range profiles {
+		p, err := buildNetworkProfile(profile)
+		if err != nil {
+			if name == "" {
+				return fmt.Errorf("default network profile: %w", err)
+			}
+			return fmt.Errorf("network profile %s: %w", name, err)
+		}
+		built[name] = p
+	}
+
+	n.mu.Lock()
+	old := n.profiles
+	n.profiles = built
+	n.mu.Unlock()
+
+	for _, p := range old {
+		p.transport.CloseIdleConnections()
+	}
+	return nil
+}
+
+// HTTPClient returns a client using the named profile
+func (n *Network) HTTPClient(profile string) (*http.Client, error) {
+	if _, err := n.get(profile); err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: &profileTransport{network: n, profile: profile}}, nil
+}
+
+// TLSConfig returns a copy of the named profile's TLS settings, for
+// clients that do not speak HTTP
+func (n *Network) TLSConfig(profile string) (*tls.Config, error) {
+	p, err := n.get(profile)
+	if err != nil {
+		return nil, err
+	}
+	return p.tls.Clone(), nil
+}
+
+func (n *Network) get(profile string) (*networkProfile, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	p, exists := n.profiles[profile]
+	if !exists {
+		return nil, fmt.Errorf("unknown network profile: %s", profile)
+	}
+	return p, nil
+}
+
+// profileTransport sends requests with the current transport of a profile
+type profileTransport struct {
+	network *Network
+	profile string
+}
+
+func (t *profileTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	p, err := t.network.get(t.profile)
+	if err != nil {
+		return nil, err
+	}
+	return p.transport.RoundTrip(req)
+}
+
+func buildNetworkProfile(profile NetworkProfile) (*networkProfile, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: profile.InsecureSkipVerify}
+	if profile.CAFile != "" {
+		pem, err := os.ReadFile(profile.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", profile.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	for _, proxyURL := range []string{profile.HTTPProxy, profile.HTTPSProxy} {
+		if _, err := url.Parse(proxyURL); err != nil {
+			return nil, fmt.Errorf("invalid proxy: %w", err)
+		}
+	}
+	proxy := http.ProxyFromEnvironment
+	if profile.HTTPProxy != "" || profile.HTTPSProxy != "" || profile.NoProxy != "" {
+		proxyFunc := (&httpproxy.Config{
+			HTTPProxy:  profile.HTTPProxy,
+			HTTPSProxy: profile.HTTPSProxy,
+			NoProxy:    profile.NoProxy,
+		}).ProxyFunc()
+		proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	transport.TLSClientConfig = tlsConfig
+	return &networkProfile{transport: transport, tls: tlsConfig}, nil
+}
+
+// Network returns the outbound network profiles
+func (e *Engine) Network() *Network {
+	return e.network
+}
+
+// HTTPClient returns an HTTP client using the named network profile, or the
+// default profile if profile is empty
+func (e *Engine) HTTPClient(profile string) (*http.Client, error) {
+	return e.network.HTTPClient(profile)
+}
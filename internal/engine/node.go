@@ -3,6 +3,7 @@ package engine
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 )
@@ -14,10 +15,30 @@ type Node struct {
 	Type   *NodeType
 	Config json.RawMessage
 	flow   *Flow
+	// Position is the node's position in the editor
+	Position Position
 	
 	instance NodeInstance
+	queue    *QueueConfig
+	inbox    *persistentInbox
+	retain   *RetainConfig
+	retainer *retainer
+	parallel *instancePool
+	priority Priority
+	warnings []ValidationIssue
 	wires    [][]NodeInstance
+	// wireStates holds the wires that are or were disabled
+	wireStates map[wireEnd]*wireState
+	// source nodes have no incoming wires; the flow's rate limit applies
+	// to them
+	source   bool
+	// startAfter lists the nodes the definition starts the node after;
+	// after also holds those its config references
+	startAfter []string
+	after      []string
 	running  bool
+	stopping bool
+	status   NodeStatus
 	mu       sync.RWMutex
 
 	ctx    context.Context
@@ -31,11 +52,34 @@ type NodeType struct {
 	Category    string
 	Defaults    json.RawMessage
 	Factory     NodeFactory
+	// Virtual marks editor-only types, such as comments, that have no
+	// runtime behaviour; flows keep them but create no instance, so they
+	// need no Factory
+	Virtual bool
+	// Parallel marks stateless types whose nodes may run several instances
+	Parallel bool
+	// Dynamic lists the config fields that can be changed while the node
+	// runs; instances must implement ConfigUpdater
+	Dynamic []string
+	// References lists the config fields holding the IDs of other nodes
+	// of the flow, such as config nodes, that a node uses; it starts after
+	// them
+	References []string
+	// Credentials lists the config fields, as dotted paths, holding
+	// passwords and other secrets, which are stored encrypted apart from
+	// the flow and left out of the flows the API returns
+	Credentials []string
 }
 
 // NodeFactory is a function that creates a specific node instance
 type NodeFactory func() NodeInstance
 
+// NodeTypeRegistry is the interface node packages use to register their
+// node types
+type NodeTypeRegistry interface {
+	RegisterNodeType(nodeType *NodeType) error
+}
+
 // NodeInstance is the interface that all node implementations must satisfy
 type NodeInstance interface {
 	// Init initializes the node with its configuration
@@ -57,6 +101,48 @@ type NodeInstance interface {
 	SetNode(node *Node)
 }
 
+// Enabler is implemented by node instances that can be switched on and off
+// at runtime without a redeploy
+type Enabler interface {
+	SetEnabled(enabled bool)
+}
+
+// Validator is implemented by node instances whose Init has side effects,
+// such as opening connections. Validate checks a configuration like Init
+// without them; validating a flow without deploying it calls it instead.
+type Validator interface {
+	Validate(config json.RawMessage) error
+}
+
+// StateReporter is implemented by node instances that expose runtime state,
+// such as current subscriptions, through the API
+type StateReporter interface {
+	State() map[string]interface{}
+}
+
+// NodeStatus is the status a node reports to the editor
+type NodeStatus struct {
+	Fill  string `json:"fill,omitempty"`
+	Shape string `json:"shape,omitempty"`
+	Text  string `json:"text,omitempty"`
+}
+
+// BaseNode implements the GetNode/SetNode plumbing of NodeInstance and can
+// be embedded by node implementations
+type BaseNode struct {
+	node *Node
+}
+
+// GetNode returns the parent Node structure
+func (b *BaseNode) GetNode() *Node {
+	return b.node
+}
+
+// SetNode sets the parent Node structure
+func (b *BaseNode) SetNode(node *Node) {
+	b.node = node
+}
+
 // NewNode creates a new Node instance
 func NewNode(id, name string, nodeType *NodeType, config json.RawMessage, flow *Flow) (*Node, error) {
 	node := &Node{
@@ -73,7 +159,7 @@ func NewNode(id, name string, nodeType *NodeType, config json.RawMessage, flow *
 	instance.SetNode(node)
 	
 	// Initialize with configuration
-	if err := instance.Init(config); err != nil {
+	if err := node.initInstance(instance); err != nil {
 		return nil, fmt.Errorf("failed to initialize node instance: %w", err)
 	}
 	
@@ -81,6 +167,15 @@ func NewNode(id, name string, nodeType *NodeType, config json.RawMessage, flow *
 	return node, nil
 }
 
+// initInstance initializes an instance of the node with its config. Dry
+// runs only validate the config if the instance can.
+func (n *Node) initInstance(instance NodeInstance) error {
+	if validator, ok := instance.(Validator); ok && n.flow != nil && n.flow.dryRun {
+		return validator.Validate(n.Config)
+	}
+	return instance.Init(n.Config)
+}
+
 // Start starts the node
 func (n *Node) Start(ctx context.Context) error {
 	n.mu.Lock()
@@ -91,11 +186,22 @@ func (n *Node) Start(ctx context.Context) error {
 	}
 	
 	n.ctx, n.cancel = context.WithCancel(ctx)
-	if err := n.instance.Start(n.ctx); err != nil {
+	if err := n.startInstances(); err != nil {
 		n.cancel()
 		return err
 	}
 	
+	// Open the inbox after starting, so replayed messages find the node ready
+	if n.queue != nil {
+		inbox, err := openInbox(n, n.queue)
+		if err != nil {
+			n.stopInstances()
+			n.cancel()
+			return fmt.Errorf("node %s: %w", n.ID, err)
+		}
+		n.inbox = inbox
+	}
+	
 	n.running = true
 	return nil
 }
@@ -103,39 +209,66 @@ func (n *Node) Start(ctx context.Context) error {
 // Stop stops the node
 func (n *Node) Stop() {
 	n.mu.Lock()
-	defer n.mu.Unlock()
-	
-	if !n.running {
+	if !n.running || n.stopping {
+		n.mu.Unlock()
 		return
 	}
-	
-	n.instance.Stop()
-	if n.cancel != nil {
-		n.cancel()
+	n.stopping = true
+	inbox := n.inbox
+	n.inbox = nil
+	n.mu.Unlock()
+
+	// Stop delivering queued messages first; undelivered ones stay on disk
+	if inbox != nil {
+		inbox.close()
 	}
-	
+
+	// Stop the instance without holding the lock so that it can wait for
+	// its own goroutines blocked in Send, and flush pending messages
+	n.stopInstances()
+
+	n.mu.Lock()
 	n.running = false
+	n.stopping = false
+	cancel := n.cancel
+	n.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
 }
 
 // Send sends a message to connected nodes
 func (n *Node) Send(msg *Message, port int) error {
-	n.mu.RLock()
-	defer n.mu.RUnlock()
-	
-	if !n.running {
-		return fmt.Errorf("node %s is not running", n.ID)
+	err := n.send(msg, port)
+	n.observeSent(1, err)
+	return err
+}
+
+// send sends a message to connected nodes, see Send
+func (n *Node) send(msg *Message, port int) error {
+	targets, ok, err := n.outgoing(msg, port)
+	if !ok {
+		if stream, isStream := msg.Payload.(*Stream); isStream {
+			stream.Close()
+		}
+		return err
 	}
 	
-	if port >= len(n.wires) {
-		return nil // No wires connected to this port
+	if stream, isStream := msg.Payload.(*Stream); isStream {
+		return n.sendStream(msg, stream, targets)
 	}
 	
-	for _, target := range n.wires[port] {
-		// Clone the message for each target to prevent concurrent modification
-		msgCopy := msg.Clone()
+	for _, target := range targets {
+		// Clone the message for each target to prevent concurrent
+		// modification, unless it can be handed over
+		msgCopy := msg
+		if !n.handOver(targets) {
+			msgCopy = msg.Clone()
+		}
 		
 		// Send the message to the target node
-		if err := target.OnMessage(msgCopy, 0); err != nil {
+		if err := deliver(target, msgCopy); err != nil {
 			return fmt.Errorf("error sending message to node: %w", err)
 		}
 	}
@@ -143,6 +276,212 @@ func (n *Node) Send(msg *Message, port int) error {
 	return nil
 }
 
+// outgoing prepares a message for sending on port and returns a copy of
+// the port's targets, or ok false if the message is not sent. It holds
+// the node's lock only meanwhile: delivering is synchronous, and in a flow
+// with loops a message can come back to the node on the same goroutine,
+// which must not take the lock again while a writer waits for it.
+func (n *Node) outgoing(msg *Message, port int) (targets []NodeInstance, ok bool, err error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	
+	if !n.running {
+		return nil, false, fmt.Errorf("node %s is not running", n.ID)
+	}
+	if err := n.countSent(); err != nil {
+		return nil, false, err
+	}
+	if send, err := n.preSend(msg, port); !send {
+		return nil, false, err
+	}
+	if n.priority != "" {
+		msg.SetPriority(n.priority)
+	}
+	if _, err := n.registerCorrelation(msg); err != nil {
+		return nil, false, err
+	}
+	if _, isStream := msg.Payload.(*Stream); !isStream && n.retainer != nil {
+		n.retainer.store(msg, port, n.Clock().Now())
+	}
+	return append([]NodeInstance(nil), n.targets(port)...), true, nil
+}
+
+// SendAll sends messages on several outputs at once: msgs[port] holds the
+// messages for that port, and nil entries send nothing. It is all or
+// nothing: if the node is not running or any message is refused, by the
+// rate limit, a hook or its correlation, none is delivered, their streams
+// are closed and the errors are returned together. Otherwise every copy is
+// made before the first is delivered, and all are delivered even if some
+// targets fail, whose errors are returned together.
+func (n *Node) SendAll(msgs [][]*Message) error {
+	count := 0
+	for _, portMsgs := range msgs {
+		for _, msg := range portMsgs {
+			if msg != nil {
+				count++
+			}
+		}
+	}
+	err := n.sendAll(msgs)
+	n.observeSent(count, err)
+	return err
+}
+
+// sendAll sends messages on several outputs at once, see SendAll. Like
+// send, it makes the copies under the node's lock and delivers them after
+// releasing it.
+func (n *Node) sendAll(msgs [][]*Message) error {
+	copies, err := n.outgoingAll(msgs)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, c := range copies {
+		var err error
+		if c.stream {
+			err = n.sendStream(c.msg, c.msg.Payload.(*Stream), c.targets)
+		} else if err = deliver(c.targets[0], c.msg); err != nil {
+			err = fmt.Errorf("error sending message to node: %w", err)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// outgoingCopy is a message of SendAll ready for delivery to its target,
+// or for a stream, to the single target of its port
+type outgoingCopy struct {
+	msg     *Message
+	targets []NodeInstance
+	stream  bool
+}
+
+// outgoingAll prepares the messages of SendAll and returns their copies. It
+// first counts, filters and correlates every message; if any of them is
+// refused, it closes the streams of the batch, forgets the correlations it
+// registered and returns the errors, so that nothing is delivered. Only
+// then does it retain the messages and make the copies.
+func (n *Node) outgoingAll(msgs [][]*Message) ([]outgoingCopy, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if !n.running {
+		closeStreams(msgs)
+		return nil, fmt.Errorf("node %s is not running", n.ID)
+	}
+
+	// sent marks, by port, the messages that are sent and not filtered
+	sent := make([][]bool, len(msgs))
+	var correlated []string
+	var errs []error
+	for port, portMsgs := range msgs {
+		sent[port] = make([]bool, len(portMsgs))
+		for i, msg := range portMsgs {
+			if msg == nil {
+				continue
+			}
+			if err := n.countSent(); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			send, err := n.preSend(msg, port)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			if !send {
+				continue
+			}
+			if n.priority != "" {
+				msg.SetPriority(n.priority)
+			}
+			id, err := n.registerCorrelation(msg)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if id != "" {
+				correlated = append(correlated, id)
+			}
+			sent[port][i] = true
+		}
+	}
+	if len(errs) > 0 {
+		closeStreams(msgs)
+		for _, id := range correlated {
+			n.flow.engine.correlations.forget(id)
+		}
+		return nil, errors.Join(errs...)
+	}
+
+	var copies []outgoingCopy
+	for port, portMsgs := range msgs {
+		for i, msg := range portMsgs {
+			if !sent[port][i] {
+				if msg != nil {
+					closeStream(msg)
+				}
+				continue
+			}
+			if _, ok := msg.Payload.(*Stream); ok {
+				targets := append([]NodeInstance(nil), n.targets(port)...)
+				copies = append(copies, outgoingCopy{msg: msg, targets: targets, stream: true})
+				continue
+			}
+			if n.retainer != nil {
+				n.retainer.store(msg, port, n.Clock().Now())
+			}
+			targets := n.targets(port)
+			for _, target := range targets {
+				msgCopy := msg
+				if !n.handOver(targets) {
+					msgCopy = msg.Clone()
+				}
+				copies = append(copies, outgoingCopy{msg: msgCopy, targets: []NodeInstance{target}})
+			}
+		}
+	}
+	return copies, nil
+}
+
+// closeStream closes the payload of a message that is not sent, if it is a
+// stream
+func closeStream(msg *Message) {
+	if stream, ok := msg.Payload.(*Stream); ok {
+		stream.Close()
+	}
+}
+
+// closeStreams closes the stream payloads of a batch of SendAll
+func closeStreams(msgs [][]*Message) {
+	for _, portMsgs := range msgs {
+		for _, msg := range portMsgs {
+			if msg != nil {
+				closeStream(msg)
+			}
+		}
+	}
+}
+
+// Receive delivers a message to the node's input as if it arrived on a wire
+func (n *Node) Receive(msg *Message, port int) error {
+	return onMessage(n.receiver(), msg, port)
+}
+
+// receiver returns what senders deliver to: the instance itself, or its
+// persistent inbox
+func (n *Node) receiver() NodeInstance {
+	if n.queue != nil {
+		return &queuedInstance{NodeInstance: n.instance, node: n}
+	}
+	if n.parallel != nil {
+		return &parallelInstance{NodeInstance: n.instance, node: n}
+	}
+	return n.instance
+}
+
 // AddWire connects this node to another node
 func (n *Node) AddWire(port int, target *Node) {
 	n.mu.Lock()
@@ -153,7 +492,7 @@ func (n *Node) AddWire(port int, target *Node) {
 		n.wires = append(n.wires, make([]NodeInstance, 0))
 	}
 	
-	n.wires[port] = append(n.wires[port], target.instance)
+	n.wires[port] = append(n.wires[port], target.receiver())
 }
 
 // GetWires returns the node's wires
@@ -170,6 +509,89 @@ func (n *Node) IsRunning() bool {
 	return n.running
 }
 
+// SetEnabled switches the node instance on or off at runtime
+func (n *Node) SetEnabled(enabled bool) error {
+	enabler, ok := n.instance.(Enabler)
+	if !ok {
+		return fmt.Errorf("node %s does not support enabling at runtime", n.ID)
+	}
+
+	if n.parallel != nil {
+		for _, instance := range n.parallel.instances {
+			instance.(Enabler).SetEnabled(enabled)
+		}
+		return nil
+	}
+	enabler.SetEnabled(enabled)
+	return nil
+}
+
+// SetStatus sets the node status and publishes it as an event
+func (n *Node) SetStatus(status NodeStatus) {
+	n.mu.Lock()
+	n.status = status
+	n.mu.Unlock()
+
+	n.PublishEvent(EventNodeStatus, status)
+}
+
+// GetStatus returns the node status
+func (n *Node) GetStatus() NodeStatus {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.status
+}
+
+// GetState returns the runtime state of the node instance, if it reports
+// one, and the depth of its persistent inbox by priority
+func (n *Node) GetState() (map[string]interface{}, bool) {
+	var state map[string]interface{}
+	reporter, ok := n.instance.(StateReporter)
+	if n.parallel != nil {
+		state, ok = n.parallel.state(), true
+	} else if ok {
+		state = reporter.State()
+	}
+
+	n.mu.RLock()
+	inbox := n.inbox
+	n.mu.RUnlock()
+	if inbox == nil {
+		return state, ok
+	}
+
+	// Copy rather than add to the map the instance returned
+	merged := make(map[string]interface{}, len(state)+1)
+	for key, value := range state {
+		merged[key] = value
+	}
+	merged["queued"] = inbox.depth()
+	return merged, true
+}
+
+// PublishEvent publishes an event on behalf of the node
+func (n *Node) PublishEvent(eventType string, data interface{}) {
+	if n.flow == nil || n.flow.engine == nil {
+		return
+	}
+
+	n.flow.engine.events.Publish(Event{
+		Type:      eventType,
+		Workspace: n.flow.Workspace(),
+		FlowID:    n.flow.ID,
+		NodeID:    n.ID,
+		Data:      data,
+	})
+}
+
+// Clock returns the clock of the engine running the node
+func (n *Node) Clock() Clock {
+	if n.flow == nil || n.flow.engine == nil || n.flow.engine.clock == nil {
+		return SystemClock{}
+	}
+	return n.flow.engine.clock
+}
+
 // GetFlow returns the node's parent flow
 func (n *Node) GetFlow() *Flow {
 	return n.flow
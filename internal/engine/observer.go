@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"sort"
+	"time"
+)
+
+// MessageObserver is told about every message nodes handle and send, e.g.
+// to keep metrics. It is called concurrently from the nodes' goroutines and
+// must be fast.
+type MessageObserver interface {
+	// MessageHandled is called after a node's OnMessage returned, with
+	// how long it took and its error
+	MessageHandled(node *Node, duration time.Duration, err error)
+	// MessageSent is called after a node's Send or SendAll returned, with
+	// the number of messages it was given and its error
+	MessageSent(node *Node, count int, err error)
+}
+
+// observerHolder stores a MessageObserver in an atomic.Value, which needs
+// the same concrete type on every Store
+type observerHolder struct {
+	observer MessageObserver
+}
+
+// SetObserver has observer told about the messages of every node; nil
+// removes it. Without an observer, messages are not timed.
+func (e *Engine) SetObserver(observer MessageObserver) {
+	e.observer.Store(observerHolder{observer})
+}
+
+// nodeObserver returns the observer of the engine running a node, or nil
+// if it has none
+func nodeObserver(node *Node) MessageObserver {
+	if node == nil || node.flow == nil || node.flow.engine == nil {
+		return nil
+	}
+	holder, _ := node.flow.engine.observer.Load().(observerHolder)
+	return holder.observer
+}
+
+// observeHandled has a node handle a message, telling the engine's observer
+// how it went
+func observeHandled(node *Node, handle func() error) error {
+	observer := nodeObserver(node)
+	if observer == nil {
+		return handle()
+	}
+	start := time.Now()
+	err := handle()
+	observer.MessageHandled(node, time.Since(start), err)
+	return err
+}
+
+// observeSent tells the engine's observer about the messages a node sent
+func (n *Node) observeSent(count int, err error) {
+	if observer := nodeObserver(n); observer != nil && count > 0 {
+		observer.MessageSent(n, count, err)
+	}
+}
+
+// ListNodes returns the runtime nodes of the flow, ordered by ID
+func (f *Flow) ListNodes() []*Node {
+	f.mu.RLock()
+	nodes := make([]*Node, 0, len(f.Nodes))
+	for _, node := range f.Nodes {
+		nodes = append(nodes, node)
+	}
+	f.mu.RUnlock()
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes
+}
+
+// Key returns the key of the flow in the engine, see FlowKey
+func (f *Flow) Key() string {
+	return FlowKey(f.Workspace(), f.ID)
+}
+
+// QueueDepth returns the number of messages waiting in the node's
+// persistent inbox, and false if it has none
+func (n *Node) QueueDepth() (int, bool) {
+	n.mu.RLock()
+	inbox := n.inbox
+	n.mu.RUnlock()
+	if inbox == nil {
+		return 0, false
+	}
+	total := 0
+	for _, count := range inbox.depth() {
+		total += count
+	}
+	return total, true
+}
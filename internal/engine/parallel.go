@@ -0,0 +1,283 @@
+package engine
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+)
+
+// Ways to distribute messages across the instances of a parallel node
+const (
+	DistributeRoundRobin = "round-robin"
+	DistributeTopic      = "topic"
+)
+
+// parallelBuffer is how many messages of each priority may wait for each
+// instance before senders block
+const parallelBuffer = 64
+
+// instancePool runs the instances of a parallel node, each on its own
+// goroutine fed by its own channel per priority
+type instancePool struct {
+	node       *Node
+	instances  []NodeInstance
+	distribute string
+
+	inputs  []poolInput
+	handled []int64
+	failed  []int64
+	next    uint64
+	running bool
+	wg      sync.WaitGroup
+	mu      sync.RWMutex
+}
+
+// poolItem is a message waiting for an instance, with its estimated size
+type poolItem struct {
+	msg     *Message
+	port    int
+	size    int64
+	release func(error)
+}
+
+// poolInput holds the channels of one instance, one per priority
+type poolInput [len(priorities)]chan poolItem
+
+// waiting returns the number of items in each channel
+func (in poolInput) waiting() [len(priorities)]int {
+	var waiting [len(priorities)]int
+	for i, lane := range in {
+		waiting[i] = len(lane)
+	}
+	return waiting
+}
+
+// parallelInstance is what senders deliver to for a parallel node
+type parallelInstance struct {
+	NodeInstance
+	node *Node
+}
+
+// OnMessage hands the message to one of the node's instances
+func (p *parallelInstance) OnMessage(msg *Message, port int) error {
+	return p.node.parallel.dispatch(msg, port)
+}
+
+// ParallelChecker is implemented by node instances of parallel types
+// whose configuration can rule out several instances. CheckParallel
+// returns why, or nil if they may run.
+type ParallelChecker interface {
+	CheckParallel() error
+}
+
+// setInstances gives the node count instances of its type, which share the
+// node and so send identically
+func (n *Node) setInstances(count int, distribute string) error {
+	if !n.Type.Parallel {
+		return fmt.Errorf("node type %s does not support multiple instances", n.Type.Name)
+	}
+	if checker, ok := n.instance.(ParallelChecker); ok {
+		if err := checker.CheckParallel(); err != nil {
+			return fmt.Errorf("node cannot run multiple instances: %w", err)
+		}
+	}
+	switch distribute {
+	case "":
+		distribute = DistributeRoundRobin
+	case DistributeRoundRobin, DistributeTopic:
+	default:
+		return fmt.Errorf("invalid distribution: %s", distribute)
+	}
+
+	pool := &instancePool{
+		node:       n,
+		instances:  []NodeInstance{n.instance},
+		distribute: distribute,
+		handled:    make([]int64, count),
+		failed:     make([]int64, count),
+	}
+	for len(pool.instances) < count {
+		instance := n.Type.Factory()
+		instance.SetNode(n)
+		if err := n.initInstance(instance); err != nil {
+			return fmt.Errorf("failed to initialize node instance: %w", err)
+		}
+		pool.instances = append(pool.instances, instance)
+	}
+	n.parallel = pool
+	return nil
+}
+
+// startInstances starts the node's instances; n.mu must be held
+func (n *Node) startInstances() error {
+	if n.parallel == nil {
+		return n.instance.Start(n.ctx)
+	}
+
+	for i, instance := range n.parallel.instances {
+		if err := instance.Start(n.ctx); err != nil {
+			for _, started := range n.parallel.instances[:i] {
+				started.Stop()
+			}
+			return err
+		}
+	}
+	n.parallel.open()
+	return nil
+}
+
+// stopInstances lets the instances handle the messages waiting for them,
+// then stops them
+func (n *Node) stopInstances() {
+	if n.parallel == nil {
+		n.instance.Stop()
+		return
+	}
+
+	n.parallel.close()
+	for _, instance := range n.parallel.instances {
+		instance.Stop()
+	}
+}
+
+// handle passes a message to the node's instance, or one of its instances
+func (n *Node) handle(msg *Message, port int) error {
+	if n.parallel != nil {
+		return n.parallel.dispatch(msg, port)
+	}
+	return onMessage(n.instance, msg, port)
+}
+
+// open starts a worker per instance
+func (p *instancePool) open() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.inputs = make([]poolInput, len(p.instances))
+	for i, instance := range p.instances {
+		var input poolInput
+		for lane := range input {
+			input[lane] = make(chan poolItem, parallelBuffer)
+		}
+		p.inputs[i] = input
+		p.wg.Add(1)
+		go p.work(i, instance, input)
+	}
+	p.running = true
+}
+
+// close stops accepting messages and waits until the waiting ones were
+// handled
+func (p *instancePool) close() {
+	p.mu.Lock()
+	if !p.running {
+		p.mu.Unlock()
+		return
+	}
+	p.running = false
+	for _, input := range p.inputs {
+		for _, lane := range input {
+			close(lane)
+		}
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+// dispatch queues a message for an instance, blocking while that
+// instance's channel for the message's priority is full. The message's delivery stays open until the
+// instance handled it.
+func (p *instancePool) dispatch(msg *Message, port int) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if !p.running {
+		return fmt.Errorf("node %s is not running", p.node.ID)
+	}
+
+	var i int
+	if p.distribute == DistributeTopic {
+		// Messages with the same topic stay in order
+		hash := fnv.New32a()
+		hash.Write([]byte(msg.Topic))
+		i = int(hash.Sum32() % uint32(len(p.inputs)))
+	} else {
+		i = int((atomic.AddUint64(&p.next, 1) - 1) % uint64(len(p.inputs)))
+	}
+
+	size := messageSize(msg)
+	if err := p.node.flow.admitQueued(p.node, size); err != nil {
+		return err
+	}
+	p.inputs[i][msg.lane()] <- poolItem{msg: msg, port: port, size: size, release: msg.Retain()}
+	return nil
+}
+
+// work handles the messages for one instance until its channels are closed
+// and drained
+func (p *instancePool) work(i int, instance NodeInstance, input poolInput) {
+	defer p.wg.Done()
+
+	var scheduler laneScheduler
+	closed := false
+	for {
+		var item poolItem
+		if lane := scheduler.pick(input.waiting()); lane >= 0 {
+			// Only this worker receives, so the channel is not empty
+			item = <-input[lane]
+		} else if closed {
+			return
+		} else {
+			var ok bool
+			select {
+			case item, ok = <-input[0]:
+			case item, ok = <-input[1]:
+			case item, ok = <-input[2]:
+			}
+			if !ok {
+				// close sends nothing after closing the first channel;
+				// handle what is left and stop
+				closed = true
+				continue
+			}
+		}
+
+		p.node.flow.addQueued(-1, -item.size)
+		err := onMessage(instance, item.msg, item.port)
+		if err != nil {
+			atomic.AddInt64(&p.failed[i], 1)
+			p.node.Logger().Warn("failed to handle message", "instance", i, "error", err)
+		} else {
+			atomic.AddInt64(&p.handled[i], 1)
+		}
+		item.release(err)
+	}
+}
+
+// state reports each instance's counters, queue depth by priority and own
+// state
+func (p *instancePool) state() map[string]interface{} {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	instances := make([]map[string]interface{}, len(p.instances))
+	for i, instance := range p.instances {
+		state := map[string]interface{}{
+			"handled": atomic.LoadInt64(&p.handled[i]),
+			"failed":  atomic.LoadInt64(&p.failed[i]),
+		}
+		if p.running {
+			state["queued"] = laneDepths(p.inputs[i].waiting())
+		}
+		if reporter, ok := instance.(StateReporter); ok {
+			state["state"] = reporter.State()
+		}
+		instances[i] = state
+	}
+	return map[string]interface{}{
+		"distribute": p.distribute,
+		"instances":  instances,
+	}
+}
@@ -0,0 +1,104 @@
+package engine
+
+import "fmt"
+
+// Priority decides which waiting messages a node's inbox delivers first
+type Priority string
+
+// Message priorities; messages without one are normal
+const (
+	PriorityHigh   Priority = "high"
+	PriorityNormal Priority = "normal"
+	PriorityLow    Priority = "low"
+)
+
+// priorities are the inbox lanes, highest first
+var priorities = [...]Priority{PriorityHigh, PriorityNormal, PriorityLow}
+
+// priorityFairness guarantees waiting lower priority messages at least one
+// in this many deliveries, so that a flood of high priority messages does
+// not starve them
+const priorityFairness = 8
+
+// ParsePriority validates a priority name; "" is normal
+func ParsePriority(value string) (Priority, error) {
+	switch Priority(value) {
+	case "":
+		return PriorityNormal, nil
+	case PriorityHigh, PriorityNormal, PriorityLow:
+		return Priority(value), nil
+	}
+	return "", fmt.Errorf("invalid priority: %s", value)
+}
+
+// SetPriority sets the message priority
+func (m *Message) SetPriority(priority Priority) {
+	m.Priority = priority
+}
+
+// GetPriority returns the message priority
+func (m *Message) GetPriority() Priority {
+	if m.Priority == "" {
+		return PriorityNormal
+	}
+	return m.Priority
+}
+
+// lane returns the index of the message's priority in priorities
+func (m *Message) lane() int {
+	switch m.Priority {
+	case PriorityHigh:
+		return 0
+	case PriorityLow:
+		return 2
+	}
+	return 1
+}
+
+// laneScheduler picks the lane to take the next message from: the highest
+// one with messages, unless a lower one was passed over too often
+type laneScheduler struct {
+	skipped [len(priorities)]int
+}
+
+// pick returns the lane to take from given how many messages wait in each,
+// or -1 if none do
+func (s *laneScheduler) pick(waiting [len(priorities)]int) int {
+	// Walk up from the lowest lane, stopping early at a starved one
+	lane := -1
+	for i := len(priorities) - 1; i >= 0; i-- {
+		if waiting[i] == 0 {
+			continue
+		}
+		lane = i
+		if s.skipped[i] >= priorityFairness-1 {
+			break
+		}
+	}
+	if lane < 0 {
+		return -1
+	}
+
+	s.taken(lane, waiting)
+	return lane
+}
+
+// taken records that a message was taken from lane, passing over the
+// lower lanes with messages
+func (s *laneScheduler) taken(lane int, waiting [len(priorities)]int) {
+	s.skipped[lane] = 0
+	for i := lane + 1; i < len(priorities); i++ {
+		if waiting[i] > 0 {
+			s.skipped[i]++
+		}
+	}
+}
+
+// laneDepths maps each priority to the number of waiting messages
+func laneDepths(waiting [len(priorities)]int) map[Priority]int {
+	depths := make(map[Priority]int, len(priorities))
+	for i, priority := range priorities {
+		depths[priority] = waiting[i]
+	}
+	return depths
+}
@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"sort"
+	"time"
+)
+
+// EventStartupReport is published when the engine has loaded the stored
+// flows
+const EventStartupReport = "startup-report"
+
+// QuarantinedFlow is a stored flow that failed to load. It is kept with its
+// definition so that it can be fixed through the API.
+type QuarantinedFlow struct {
+	// ID is the flow's key
+	ID         string    `json:"id"`
+	Error      string    `json:"error"`
+	Since      time.Time `json:"since"`
+	Definition []byte    `json:"-"`
+}
+
+// StartupReport summarizes the last loading of the stored flows
+type StartupReport struct {
+	Loaded      int       `json:"loaded"`
+	Quarantined int       `json:"quarantined"`
+	Time        time.Time `json:"time"`
+}
+
+// quarantine keeps a flow that failed to load; e.mu must be held
+func (e *Engine) quarantine(id string, flowDef []byte, err error) {
+	e.Logger().Warn("quarantined flow", "flow_id", id, "error", err)
+	e.quarantined[id] = &QuarantinedFlow{
+		ID:         id,
+		Error:      err.Error(),
+		Since:      e.clock.Now(),
+		Definition: flowDef,
+	}
+}
+
+// QuarantinedFlows returns the flows that failed to load, sorted by key
+func (e *Engine) QuarantinedFlows() []QuarantinedFlow {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	flows := make([]QuarantinedFlow, 0, len(e.quarantined))
+	for _, flow := range e.quarantined {
+		flows = append(flows, *flow)
+	}
+	sort.Slice(flows, func(i, j int) bool { return flows[i].ID < flows[j].ID })
+	return flows
+}
+
+// QuarantinedFlow returns a flow that failed to load. Deploying a fixed
+// definition under its key releases it.
+func (e *Engine) QuarantinedFlow(id string) (QuarantinedFlow, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	flow, exists := e.quarantined[id]
+	if !exists {
+		return QuarantinedFlow{}, false
+	}
+	return *flow, true
+}
+
+// StartupReport returns the summary of the last loading of the stored flows
+func (e *Engine) StartupReport() StartupReport {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.startup
+}
@@ -0,0 +1,285 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// defaultQueueMaxAttempts is how often a queued message is offered to a node
+// that returns errors before it is dropped
+const defaultQueueMaxAttempts = 3
+
+// QueueConfig enables a persistent inbox for a node. Messages sent to the
+// node are written to disk before delivery and removed once the node handled
+// them without error, so they survive restarts.
+type QueueConfig struct {
+	// MaxSize limits the bytes of queued messages; senders get an error
+	// while the queue is full
+	MaxSize int64 `json:"maxSize,omitempty"`
+	// MaxAge drops messages older than this instead of delivering them
+	MaxAge string `json:"maxAge,omitempty"`
+	// MaxAttempts is how often a message is delivered to a failing node
+	// before it is dropped
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+}
+
+// queuedInstance is wired in place of a node with a persistent inbox and
+// enqueues instead of delivering directly
+type queuedInstance struct {
+	NodeInstance
+	node *Node
+}
+
+// OnMessage enqueues the message
+func (q *queuedInstance) OnMessage(msg *Message, port int) error {
+	q.node.mu.RLock()
+	inbox := q.node.inbox
+	q.node.mu.RUnlock()
+
+	if inbox == nil {
+		return fmt.Errorf("node %s is not running", q.node.ID)
+	}
+	return inbox.enqueue(msg)
+}
+
+// persistentInbox delivers queued messages to a node, higher priorities
+// first and in order within each priority
+type persistentInbox struct {
+	node        *Node
+	queue       *storage.Queue
+	maxAge      time.Duration
+	maxAttempts int
+
+	lanes     [len(priorities)][]inboxItem
+	scheduler laneScheduler
+	mu        sync.Mutex
+	wake      chan struct{}
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// inboxItem is a queued message, its sequence number and encoded size
+type inboxItem struct {
+	seq  uint64
+	msg  *Message
+	size int64
+}
+
+// openInbox opens the node's queue and starts delivering, beginning with
+// the messages left over from a previous run
+func openInbox(n *Node, config *QueueConfig) (*persistentInbox, error) {
+	var maxAge time.Duration
+	if config.MaxAge != "" {
+		var err error
+		if maxAge, err = time.ParseDuration(config.MaxAge); err != nil {
+			return nil, fmt.Errorf("invalid queue maxAge: %s", config.MaxAge)
+		}
+	}
+
+	var store storage.Storage
+	if n.flow != nil && n.flow.engine != nil {
+		store = n.flow.engine.storage
+	}
+	provider, ok := store.(storage.QueueProvider)
+	if !ok {
+		return nil, fmt.Errorf("storage does not support persistent queues")
+	}
+
+	name := strings.ReplaceAll(n.flow.ID, "/", "_") + "/" + strings.ReplaceAll(n.ID, "/", "_")
+	if workspace := n.flow.Workspace(); workspace != DefaultWorkspace {
+		name = "workspaces/" + workspace + "/" + name
+	}
+	queue, records, err := provider.OpenQueue(name, storage.QueueOptions{MaxSize: config.MaxSize})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open queue: %w", err)
+	}
+	if corrupt := queue.Corrupt(); corrupt > 0 {
+		n.Logger().Warn("queue skipped corrupt segments", "segments", corrupt)
+	}
+
+	inbox := &persistentInbox{
+		node:        n,
+		queue:       queue,
+		maxAge:      maxAge,
+		maxAttempts: config.MaxAttempts,
+		wake:        make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+	if inbox.maxAttempts <= 0 {
+		inbox.maxAttempts = defaultQueueMaxAttempts
+	}
+
+	skipped := 0
+	for _, record := range records {
+		msg := &Message{}
+		if err := msg.UnmarshalBinary(record.Data); err != nil {
+			skipped++
+			queue.Ack(record.Seq)
+			continue
+		}
+		inbox.push(inboxItem{seq: record.Seq, msg: msg, size: int64(len(record.Data))})
+		n.flow.addQueued(1, int64(len(record.Data)))
+	}
+	if skipped > 0 {
+		n.Logger().Warn("queue skipped undecodable messages", "messages", skipped)
+	}
+	if replayed := len(records) - skipped; replayed > 0 {
+		n.Logger().Info("replaying queued messages", "messages", replayed)
+	}
+
+	inbox.wg.Add(1)
+	go inbox.run()
+	return inbox, nil
+}
+
+// enqueue persists a message and schedules its delivery
+func (q *persistentInbox) enqueue(msg *Message) error {
+	data, err := msg.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to encode message for queue: %w", err)
+	}
+	size := int64(len(data))
+	if err := q.node.flow.admitQueued(q.node, size); err != nil {
+		return err
+	}
+	seq, err := q.queue.Append(data)
+	if err != nil {
+		q.node.flow.addQueued(-1, -size)
+		return fmt.Errorf("failed to queue message for node %s: %w", q.node.ID, err)
+	}
+	// The sender's delivery is complete once the message is on disk; the
+	// inbox starts a new one when it delivers the message
+	msg.delivery = nil
+
+	q.mu.Lock()
+	q.push(inboxItem{seq: seq, msg: msg, size: size})
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// push adds an item to the lane of its priority; q.mu must be held
+func (q *persistentInbox) push(item inboxItem) {
+	lane := item.msg.lane()
+	q.lanes[lane] = append(q.lanes[lane], item)
+}
+
+// waiting returns the number of items in each lane; q.mu must be held
+func (q *persistentInbox) waiting() [len(priorities)]int {
+	var waiting [len(priorities)]int
+	for i, lane := range q.lanes {
+		waiting[i] = len(lane)
+	}
+	return waiting
+}
+
+// depth returns the number of queued messages by priority
+func (q *persistentInbox) depth() map[Priority]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return laneDepths(q.waiting())
+}
+
+// close stops delivering and closes the queue; undelivered messages stay
+// on disk for the next start
+func (q *persistentInbox) close() {
+	close(q.done)
+	q.wg.Wait()
+	q.queue.Close()
+
+	q.mu.Lock()
+	for _, lane := range q.lanes {
+		for _, item := range lane {
+			q.node.flow.addQueued(-1, -item.size)
+		}
+	}
+	q.mu.Unlock()
+}
+
+// run delivers queued messages one at a time
+func (q *persistentInbox) run() {
+	defer q.wg.Done()
+
+	expired := 0
+	for {
+		q.mu.Lock()
+		var item inboxItem
+		lane := q.scheduler.pick(q.waiting())
+		if lane >= 0 {
+			item = q.lanes[lane][0]
+		}
+		q.mu.Unlock()
+
+		if lane < 0 {
+			if expired > 0 {
+				q.node.Logger().Warn("queue dropped expired messages", "messages", expired)
+				expired = 0
+			}
+			select {
+			case <-q.done:
+				return
+			case <-q.wake:
+			}
+			continue
+		}
+
+		if q.maxAge > 0 && q.node.Clock().Now().Sub(item.msg.Timestamp) > q.maxAge {
+			expired++
+		} else if !q.deliver(item) {
+			return // closing; the message stays queued
+		}
+
+		q.mu.Lock()
+		q.lanes[lane] = q.lanes[lane][1:]
+		q.mu.Unlock()
+		q.node.flow.addQueued(-1, -item.size)
+		if err := q.queue.Ack(item.seq); err != nil {
+			q.node.Logger().Warn("queue failed to acknowledge a message", "error", err)
+		}
+	}
+}
+
+// deliver offers a message to the node, retrying failures with backoff. In
+// flows with at-least-once delivery a message counts as handled once
+// everything downstream completed. It returns false if the inbox closed
+// before the message was handled.
+func (q *persistentInbox) deliver(item inboxItem) bool {
+	backoff := 100 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		msg := item.msg.Clone()
+		result := make(chan error, 1)
+		q.node.withAck(msg, func(err error) { result <- err }, func() error {
+			return q.node.handle(msg, 0)
+		})
+
+		var err error
+		select {
+		case err = <-result:
+		case <-q.done:
+			return false
+		}
+		if err == nil {
+			return true
+		}
+		if attempt >= q.maxAttempts {
+			q.node.Logger().Warn("dropping queued message that failed too often",
+				"msg_id", item.msg.MsgID, "attempts", attempt, "error", err)
+			return true
+		}
+
+		select {
+		case <-q.done:
+			return false
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,187 @@
+package engine
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MetadataRetained marks messages replayed from a node's retained messages
+const MetadataRetained = "retained"
+
+// RetainConfig makes a node keep the last message sent on each of its
+// outputs, so that nodes wired to it later receive it right away
+type RetainConfig struct {
+	// TTL discards retained messages older than this; empty keeps them
+	// until the flow stops
+	TTL string `json:"ttl,omitempty"`
+}
+
+// retainedMessage is the last message sent on an output
+type retainedMessage struct {
+	msg *Message
+	at  time.Time
+}
+
+// RetainedMessage is a retained message as reported by LastMessages
+type RetainedMessage struct {
+	Port       int       `json:"port"`
+	Message    *Message  `json:"message"`
+	RetainedAt time.Time `json:"retainedAt"`
+}
+
+// retainer holds a node's retained messages
+type retainer struct {
+	ttl      time.Duration
+	messages map[int]retainedMessage
+	mu       sync.Mutex
+}
+
+// newRetainer creates a retainer from a node's retain config
+func newRetainer(config *RetainConfig) (*retainer, error) {
+	r := &retainer{messages: make(map[int]retainedMessage)}
+	if config.TTL != "" {
+		ttl, err := time.ParseDuration(config.TTL)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid retain ttl: %s", config.TTL)
+		}
+		r.ttl = ttl
+	}
+	return r, nil
+}
+
+// store keeps a copy of a message sent on port
+func (r *retainer) store(msg *Message, port int, now time.Time) {
+	if _, ok := msg.Payload.(*Stream); ok {
+		return // a stream can only be read once
+	}
+
+	retained := msg.Clone()
+	retained.delivery = nil
+
+	r.mu.Lock()
+	r.messages[port] = retainedMessage{msg: retained, at: now}
+	r.mu.Unlock()
+}
+
+// last returns the unexpired retained messages by port
+func (r *retainer) last(now time.Time) map[int]retainedMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make(map[int]retainedMessage, len(r.messages))
+	for port, retained := range r.messages {
+		if r.ttl > 0 && now.Sub(retained.at) > r.ttl {
+			delete(r.messages, port)
+			continue
+		}
+		result[port] = retained
+	}
+	return result
+}
+
+// clear drops all retained messages
+func (r *retainer) clear() {
+	r.mu.Lock()
+	r.messages = make(map[int]retainedMessage)
+	r.mu.Unlock()
+}
+
+// LastMessages returns the messages retained by the node, ordered by port.
+// It returns nil if the node does not retain messages.
+func (n *Node) LastMessages() []RetainedMessage {
+	if n.retainer == nil {
+		return nil
+	}
+
+	last := n.retainer.last(n.Clock().Now())
+	result := make([]RetainedMessage, 0, len(last))
+	for port := 0; len(result) < len(last); port++ {
+		if retained, exists := last[port]; exists {
+			result = append(result, RetainedMessage{Port: port, Message: retained.msg.Clone(), RetainedAt: retained.at})
+		}
+	}
+	return result
+}
+
+// RetainsMessages reports whether the node keeps its last messages
+func (n *Node) RetainsMessages() bool {
+	return n.retainer != nil
+}
+
+// ClearRetained drops the node's retained messages
+func (n *Node) ClearRetained() {
+	if n.retainer != nil {
+		n.retainer.clear()
+	}
+}
+
+// retainedReplay is a retained message to deliver to a late-attached node
+type retainedReplay struct {
+	msg    *Message
+	target *Node
+}
+
+// inheritRetained takes over the retained messages of nodes that are
+// unchanged since the previous version of the flow, and plans to replay them
+// to the nodes wired to them that are new or changed
+func (f *Flow) inheritRetained(previous *Flow, retained map[string]map[int]retainedMessage) {
+	unchanged := make(map[string]bool)
+	for id, node := range f.Nodes {
+		old, exists := previous.Nodes[id]
+		if exists && old.Type.Name == node.Type.Name && string(old.Config) == string(node.Config) {
+			unchanged[id] = true
+		}
+	}
+
+	for id, messages := range retained {
+		node, exists := f.Nodes[id]
+		if !exists || !unchanged[id] || node.retainer == nil {
+			continue
+		}
+		node.retainer.mu.Lock()
+		for port, message := range messages {
+			node.retainer.messages[port] = message
+		}
+		node.retainer.mu.Unlock()
+	}
+
+	for _, wire := range f.connections {
+		source, target := f.Nodes[wire.Source], f.Nodes[wire.Target]
+		if source == nil || target == nil || source.retainer == nil || unchanged[wire.Target] || wire.Disabled {
+			continue
+		}
+		if message, exists := retained[wire.Source][wire.Port]; exists && unchanged[wire.Source] {
+			f.replay = append(f.replay, retainedReplay{msg: message.msg, target: target})
+		}
+	}
+}
+
+// retainedMessages collects the unexpired retained messages of all nodes
+func (f *Flow) retainedMessages() map[string]map[int]retainedMessage {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	result := make(map[string]map[int]retainedMessage)
+	for id, node := range f.Nodes {
+		if node.retainer == nil {
+			continue
+		}
+		if last := node.retainer.last(node.Clock().Now()); len(last) > 0 {
+			result[id] = last
+		}
+	}
+	return result
+}
+
+// replayRetained delivers the planned retained messages, marked with the
+// "retained" metadata key, to the nodes that were attached late
+func (f *Flow) replayRetained(replay []retainedReplay) {
+	for _, r := range replay {
+		msg := r.msg.Clone()
+		msg.SetMetadata(MetadataRetained, true)
+		if err := r.target.Receive(msg, 0); err != nil {
+			r.target.Logger().Warn("failed to replay retained message", "error", err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// AnyRevision matches any revision of an existing flow in DeployFlowAt
+const AnyRevision = "*"
+
+// ErrFlowExists is returned by CreateFlowAs for the ID of an existing flow
+var ErrFlowExists = errors.New("flow already exists")
+
+// RevisionError is returned when a flow is not at the revision a deploy
+// expected, as another deploy changed it meanwhile
+type RevisionError struct {
+	Expected string
+	// Current is the flow's revision, or "" if it does not exist
+	Current string
+}
+
+func (e *RevisionError) Error() string {
+	if e.Current == "" {
+		return fmt.Sprintf("flow does not exist, expected revision %s", e.Expected)
+	}
+	return fmt.Sprintf("flow is at revision %s, expected %s", e.Current, e.Expected)
+}
+
+// Revision returns the revision of the flow: the start of the SHA-256 of
+// its definition without credentials, so that every change to the flow
+// makes a new one
+func (f *Flow) Revision() (string, error) {
+	flowDef, err := f.PublicJSON()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(flowDef)
+	return hex.EncodeToString(sum[:])[:16], nil
+}
+
+// checkRevision checks that a flow, nil if it does not exist, is at the
+// expected revision, unless that is ""
+func checkRevision(flow *Flow, expected string) error {
+	if expected == "" {
+		return nil
+	}
+	if flow == nil {
+		return &RevisionError{Expected: expected}
+	}
+	current, err := flow.Revision()
+	if err != nil {
+		return err
+	}
+	if expected != AnyRevision && expected != current {
+		return &RevisionError{Expected: expected, Current: current}
+	}
+	return nil
+}
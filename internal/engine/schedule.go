@@ -0,0 +1,292 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EventFlowSchedule is published when a schedule starts or stops a flow
+const EventFlowSchedule = "flow-schedule"
+
+// FlowStatusInactive is the status of a scheduled flow outside its windows
+const FlowStatusInactive FlowStatus = "scheduled (inactive)"
+
+// maxScheduleWait bounds how long the scheduler sleeps, so that it notices
+// wall clock changes
+const maxScheduleWait = time.Minute
+
+// Schedule restricts when a flow runs
+type Schedule struct {
+	// Timezone is an IANA zone such as "Europe/Berlin"; the default is the
+	// zone of the host
+	Timezone string           `json:"timezone,omitempty"`
+	Windows  []ScheduleWindow `json:"windows"`
+}
+
+// ScheduleWindow is a daily time window from Start to End ("15:04"). A
+// window whose End is not after its Start runs past midnight.
+type ScheduleWindow struct {
+	// Days are the days the window starts on, such as "mon", "friday",
+	// "weekdays" or "weekends"; the default is every day
+	Days  []string `json:"days,omitempty"`
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+}
+
+// schedule is a validated Schedule
+type schedule struct {
+	location *time.Location
+	windows  []scheduleWindow
+}
+
+type scheduleWindow struct {
+	days       [7]bool
+	start, end int // minutes after midnight
+}
+
+var dayNames = map[string][]time.Weekday{
+	"weekdays": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekends": {time.Saturday, time.Sunday},
+}
+
+func init() {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := strings.ToLower(day.String())
+		dayNames[name] = []time.Weekday{day}
+		dayNames[name[:3]] = []time.Weekday{day}
+	}
+}
+
+// newSchedule validates a schedule definition
+func newSchedule(def *Schedule) (*schedule, error) {
+	s := &schedule{location: time.Local}
+	if def.Timezone != "" {
+		location, err := time.LoadLocation(def.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule timezone: %s", def.Timezone)
+		}
+		s.location = location
+	}
+	if len(def.Windows) == 0 {
+		return nil, errors.New("schedule has no windows")
+	}
+
+	for _, windowDef := range def.Windows {
+		var window scheduleWindow
+		var err error
+		if window.start, err = parseClock(windowDef.Start); err != nil {
+			return nil, err
+		}
+		if window.end, err = parseClock(windowDef.End); err != nil {
+			return nil, err
+		}
+		if len(windowDef.Days) == 0 {
+			window.days = [7]bool{true, true, true, true, true, true, true}
+		}
+		for _, name := range windowDef.Days {
+			days, ok := dayNames[strings.ToLower(name)]
+			if !ok {
+				return nil, fmt.Errorf("invalid schedule day: %s", name)
+			}
+			for _, day := range days {
+				window.days[day] = true
+			}
+		}
+		s.windows = append(s.windows, window)
+	}
+	return s, nil
+}
+
+// parseClock parses "15:04", allowing "24:00" as the end of the day
+func parseClock(value string) (int, error) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(value, "%d:%d", &hour, &minute); err != nil ||
+		hour < 0 || minute < 0 || minute > 59 || hour*60+minute > 24*60 {
+		return 0, fmt.Errorf("invalid schedule time: %q", value)
+	}
+	return hour*60 + minute, nil
+}
+
+// interval is one occurrence of a window
+type interval struct {
+	start, end time.Time
+}
+
+// intervals returns the occurrences of all windows that start between the
+// day before t and a week after it
+func (s *schedule) intervals(t time.Time) []interval {
+	year, month, day := t.In(s.location).Date()
+	var intervals []interval
+	for offset := -1; offset <= 7; offset++ {
+		date := time.Date(year, month, day+offset, 12, 0, 0, 0, s.location)
+		for _, window := range s.windows {
+			if !window.days[date.Weekday()] {
+				continue
+			}
+			end := window.end
+			if end <= window.start {
+				end += 24 * 60
+			}
+			intervals = append(intervals, interval{
+				start: s.at(date, window.start),
+				end:   s.at(date, end),
+			})
+		}
+	}
+	return intervals
+}
+
+// at returns the instant the wall clock shows minutes after midnight of
+// date. A time skipped when clocks go forward maps to the moment they jump;
+// a time that occurs twice when clocks go back maps to its first
+// occurrence.
+func (s *schedule) at(date time.Time, minutes int) time.Time {
+	year, month, day := date.Date()
+	t := time.Date(year, month, day, 0, minutes, 0, 0, s.location)
+
+	if t.Hour()*60+t.Minute() != minutes%(24*60) {
+		// Skipped: t is past the gap
+		start, _ := t.ZoneBounds()
+		return start
+	}
+
+	start, _ := t.ZoneBounds()
+	_, before := start.Add(-time.Second).Zone()
+	if _, after := t.Zone(); before > after {
+		// The hour after a fall back transition; prefer the earlier
+		// occurrence of the same wall time if there is one
+		earlier := t.Add(-time.Duration(before-after) * time.Second)
+		if earlier.Before(start) {
+			return earlier
+		}
+	}
+	return t
+}
+
+// active reports whether t falls within a window
+func (s *schedule) active(t time.Time) bool {
+	for _, occurrence := range s.intervals(t) {
+		if !t.Before(occurrence.start) && t.Before(occurrence.end) {
+			return true
+		}
+	}
+	return false
+}
+
+// next returns the first window start or end after t
+func (s *schedule) next(t time.Time) time.Time {
+	var next time.Time
+	for _, occurrence := range s.intervals(t) {
+		for _, boundary := range []time.Time{occurrence.start, occurrence.end} {
+			if boundary.After(t) && (next.IsZero() || boundary.Before(next)) {
+				next = boundary
+			}
+		}
+	}
+	return next
+}
+
+// Scheduled reports whether the flow only runs during its schedule
+func (f *Flow) Scheduled() bool {
+	return f.schedule != nil
+}
+
+// startSchedule starts and stops the flow according to its schedule until
+// the flow is stopped
+func (f *Flow) startSchedule(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	f.mu.Lock()
+	f.scheduleCancel, f.scheduleDone = cancel, done
+	f.override = false
+	f.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		f.runSchedule(ctx)
+	}()
+}
+
+// stopSchedule stops the scheduler, if running, and waits for it
+func (f *Flow) stopSchedule() {
+	f.mu.Lock()
+	cancel, done := f.scheduleCancel, f.scheduleDone
+	f.scheduleCancel, f.scheduleDone = nil, nil
+	f.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// runSchedule applies the schedule at every window boundary
+func (f *Flow) runSchedule(ctx context.Context) {
+	clock := f.engine.Clock()
+	for {
+		now := clock.Now()
+		next := f.schedule.next(now)
+		f.applySchedule(ctx, now, next)
+
+		for clock.Now().Before(next) {
+			wait := next.Sub(clock.Now())
+			if wait > maxScheduleWait {
+				wait = maxScheduleWait
+			}
+			timer := clock.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C():
+			}
+		}
+
+		// A manual start or stop lasts until the next boundary
+		f.mu.Lock()
+		f.override = false
+		f.mu.Unlock()
+	}
+}
+
+// applySchedule starts or stops the flow unless it was overridden
+func (f *Flow) applySchedule(ctx context.Context, now, next time.Time) {
+	active := f.schedule.active(now)
+
+	f.mu.RLock()
+	override, running := f.override, f.status == FlowStatusRunning
+	f.mu.RUnlock()
+	if override || active == running {
+		return
+	}
+
+	if active {
+		if err := f.Start(ctx); err != nil {
+			f.Logger().Warn("failed to start scheduled flow", "error", err)
+			return
+		}
+	} else {
+		f.stopNodes()
+	}
+
+	f.engine.events.Publish(Event{
+		Type:      EventFlowSchedule,
+		Workspace: f.Workspace(),
+		FlowID:    f.ID,
+		Data: map[string]interface{}{
+			"active": active,
+			"next":   next,
+		},
+	})
+}
+
+// setOverride keeps a manual start or stop until the next boundary
+func (f *Flow) setOverride() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.override = f.scheduleCancel != nil
+}
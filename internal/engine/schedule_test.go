@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+// In Europe/Berlin clocks go forward from 02:00 to 03:00 on 2026-03-29 and
+// back from 03:00 to 02:00 on 2026-10-25
+const scheduleZone = "Europe/Berlin"
+
+// mustTime parses an RFC 3339 time
+func mustTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func TestScheduleAtDST(t *testing.T) {
+	s, err := newSchedule(&Schedule{Timezone: scheduleZone, Windows: []ScheduleWindow{{Start: "00:00", End: "24:00"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		date  string
+		clock string
+		want  string
+	}{
+		{"ordinary day", "2026-03-28", "02:30", "2026-03-28T02:30:00+01:00"},
+		{"before the gap", "2026-03-29", "01:59", "2026-03-29T01:59:00+01:00"},
+		{"in the gap", "2026-03-29", "02:30", "2026-03-29T03:00:00+02:00"},
+		{"start of the gap", "2026-03-29", "02:00", "2026-03-29T03:00:00+02:00"},
+		{"after the gap", "2026-03-29", "03:00", "2026-03-29T03:00:00+02:00"},
+		{"repeated time", "2026-10-25", "02:30", "2026-10-25T02:30:00+02:00"},
+		{"start of the repeated hour", "2026-10-25", "02:00", "2026-10-25T02:00:00+02:00"},
+		{"after the repeated hour", "2026-10-25", "03:00", "2026-10-25T03:00:00+01:00"},
+		{"end of a short day", "2026-03-29", "24:00", "2026-03-30T00:00:00+02:00"},
+		{"end of a long day", "2026-10-25", "24:00", "2026-10-26T00:00:00+01:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			date, err := time.ParseInLocation("2006-01-02", tt.date, s.location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			minutes, err := parseClock(tt.clock)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.at(date, minutes).Format(time.RFC3339); got != tt.want {
+				t.Errorf("at(%s %s) = %s, want %s", tt.date, tt.clock, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleWindowsDST(t *testing.T) {
+	tests := []struct {
+		name   string
+		window ScheduleWindow
+		// from is where boundaries are looked for; boundaries are the next
+		// window starts and ends, alternating with active
+		from       string
+		boundaries []string
+		// length is the real time the window is open
+		length time.Duration
+	}{
+		{
+			name:       "window ending in the gap",
+			window:     ScheduleWindow{Start: "01:00", End: "02:30"},
+			from:       "2026-03-29T00:00:00+01:00",
+			boundaries: []string{"2026-03-29T01:00:00+01:00", "2026-03-29T03:00:00+02:00"},
+			length:     time.Hour,
+		},
+		{
+			name:       "window starting in the gap",
+			window:     ScheduleWindow{Start: "02:30", End: "04:00"},
+			from:       "2026-03-29T00:00:00+01:00",
+			boundaries: []string{"2026-03-29T03:00:00+02:00", "2026-03-29T04:00:00+02:00"},
+			length:     time.Hour,
+		},
+		{
+			name:       "window across the repeated hour",
+			window:     ScheduleWindow{Start: "01:00", End: "04:00"},
+			from:       "2026-10-25T00:00:00+02:00",
+			boundaries: []string{"2026-10-25T01:00:00+02:00", "2026-10-25T04:00:00+01:00"},
+			length:     4 * time.Hour,
+		},
+		{
+			name:       "window ending in the repeated hour",
+			window:     ScheduleWindow{Start: "01:00", End: "02:30"},
+			from:       "2026-10-25T00:00:00+02:00",
+			boundaries: []string{"2026-10-25T01:00:00+02:00", "2026-10-25T02:30:00+02:00"},
+			length:     90 * time.Minute,
+		},
+		{
+			name:       "overnight window going back",
+			window:     ScheduleWindow{Days: []string{"sat"}, Start: "22:00", End: "06:00"},
+			from:       "2026-10-24T12:00:00+02:00",
+			boundaries: []string{"2026-10-24T22:00:00+02:00", "2026-10-25T06:00:00+01:00"},
+			length:     9 * time.Hour,
+		},
+		{
+			name:       "overnight window going forward",
+			window:     ScheduleWindow{Days: []string{"sat"}, Start: "22:00", End: "06:00"},
+			from:       "2026-03-28T12:00:00+01:00",
+			boundaries: []string{"2026-03-28T22:00:00+01:00", "2026-03-29T06:00:00+02:00"},
+			length:     7 * time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newSchedule(&Schedule{Timezone: scheduleZone, Windows: []ScheduleWindow{tt.window}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			now := mustTime(t, tt.from)
+			if s.active(now) {
+				t.Fatalf("active at %s", tt.from)
+			}
+			for i, want := range tt.boundaries {
+				next := s.next(now)
+				if got := next.Format(time.RFC3339); got != want {
+					t.Fatalf("boundary %d at %s, want %s", i+1, got, want)
+				}
+				// The window is open from its start up to its end
+				if active := s.active(next); active != (i%2 == 0) {
+					t.Errorf("active at %s = %v, want %v", want, active, i%2 == 0)
+				}
+				if before := next.Add(-time.Second); s.active(before) != (i%2 == 1) {
+					t.Errorf("active just before %s = %v, want %v", want, !(i%2 == 1), i%2 == 1)
+				}
+				now = next
+			}
+			start, end := mustTime(t, tt.boundaries[0]), mustTime(t, tt.boundaries[1])
+			if got := end.Sub(start); got != tt.length {
+				t.Errorf("window open for %v, want %v", got, tt.length)
+			}
+		})
+	}
+}
+
+func TestScheduleWindowSkippedByDST(t *testing.T) {
+	// A window within the hour clocks skip does not open that day
+	s, err := newSchedule(&Schedule{Timezone: scheduleZone, Windows: []ScheduleWindow{{Start: "02:10", End: "02:50"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for t0 := mustTime(t, "2026-03-29T00:00:00+01:00"); t0.Before(mustTime(t, "2026-03-29T12:00:00+02:00")); t0 = t0.Add(time.Minute) {
+		if s.active(t0) {
+			t.Fatalf("active at %s", t0.In(s.location).Format(time.RFC3339))
+		}
+	}
+	// Both boundaries fall on the moment clocks jump
+	if next := s.next(mustTime(t, "2026-03-29T00:00:00+01:00")); next.Format(time.RFC3339) != "2026-03-29T03:00:00+02:00" {
+		t.Errorf("next boundary at %s", next.Format(time.RFC3339))
+	}
+}
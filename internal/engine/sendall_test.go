@@ -0,0 +1,153 @@
+package engine_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/registry"
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// Shape of the fan-out flow of the SendAll benchmarks
+const (
+	fanOutPorts   = 4
+	fanOutTargets = 32
+)
+
+// countNode counts the messages it receives
+type countNode struct {
+	engine.BaseNode
+	received *int64
+}
+
+func (n *countNode) Init(json.RawMessage) error      { return nil }
+func (n *countNode) Start(ctx context.Context) error { return nil }
+func (n *countNode) Stop()                           {}
+
+func (n *countNode) OnMessage(msg *engine.Message, port int) error {
+	atomic.AddInt64(n.received, 1)
+	return nil
+}
+
+// fanOutSource deploys a flow whose source node has fanOutPorts outputs,
+// wired to fanOutTargets nodes in all, and returns the engine, the source
+// and the count of messages the targets received
+func fanOutSource(tb testing.TB) (*engine.Engine, *engine.Node, *int64) {
+	tb.Helper()
+	received := new(int64)
+	reg := registry.New()
+	if err := reg.RegisterNodeType(&engine.NodeType{
+		Name:    "count",
+		Factory: func() engine.NodeInstance { return &countNode{received: received} },
+	}); err != nil {
+		tb.Fatal(err)
+	}
+	eng := engine.New(reg, storage.NewMemoryStorage())
+	if err := eng.Start(); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { eng.Stop() })
+
+	nodes := []string{`{"id": "source", "type": "count"}`}
+	var wires []string
+	for i := 0; i < fanOutTargets; i++ {
+		nodes = append(nodes, fmt.Sprintf(`{"id": "t%d", "type": "count"}`, i))
+		wires = append(wires, fmt.Sprintf(`{"source": "source", "target": "t%d", "port": %d}`, i, i%fanOutPorts))
+	}
+	flowDef := fmt.Sprintf(`{"id": "fanout", "nodes": [%s], "wires": [%s]}`, strings.Join(nodes, ", "), strings.Join(wires, ", "))
+	if err := eng.DeployFlow("fanout", []byte(flowDef)); err != nil {
+		tb.Fatal(err)
+	}
+	flow, _ := eng.GetFlow("fanout")
+	source, _ := flow.GetNode("source")
+	return eng, source, received
+}
+
+// fanOutPayload is a payload of a size typical of sensor readings
+func fanOutPayload() map[string]interface{} {
+	return map[string]interface{}{
+		"sensor": "line-1/temperature",
+		"values": []interface{}{21.5, 21.7, 21.6, 21.9},
+		"labels": map[string]interface{}{"site": "plant-a", "unit": "celsius"},
+	}
+}
+
+// checkFanOut fails the benchmark unless every target got every message
+func checkFanOut(b *testing.B, received *int64) {
+	b.Helper()
+	if got, want := atomic.LoadInt64(received), int64(b.N)*fanOutTargets; got != want {
+		b.Fatalf("targets received %d messages, want %d", got, want)
+	}
+}
+
+func BenchmarkSendAll(b *testing.B) {
+	_, source, received := fanOutSource(b)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			msgs := make([][]*engine.Message, fanOutPorts)
+			for port := range msgs {
+				msgs[port] = []*engine.Message{engine.NewMessage(fanOutPayload(), "")}
+			}
+			if err := source.SendAll(msgs); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+	b.StopTimer()
+	checkFanOut(b, received)
+}
+
+func BenchmarkSendPerPort(b *testing.B) {
+	_, source, received := fanOutSource(b)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			for port := 0; port < fanOutPorts; port++ {
+				if err := source.Send(engine.NewMessage(fanOutPayload(), ""), port); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		}
+	})
+	b.StopTimer()
+	checkFanOut(b, received)
+}
+
+func TestSendAllAllOrNothing(t *testing.T) {
+	eng, source, received := fanOutSource(t)
+	refused := errors.New("refused")
+	if err := eng.Hooks().Register(engine.HookPreSend, "refuse", func(ctx *engine.HookContext) error {
+		if ctx.Msg.Payload == "refused" {
+			return refused
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := newTrackedReader("data")
+	msgs := [][]*engine.Message{
+		{engine.NewMessage("sent", ""), engine.NewMessage("refused", "")},
+		{engine.NewMessage(engine.NewStream(reader, 4, "text/plain"), "")},
+		nil,
+		{engine.NewMessage("sent", "")},
+	}
+	if err := source.SendAll(msgs); !errors.Is(err, refused) {
+		t.Fatalf("SendAll error = %v, want %v", err, refused)
+	}
+	if got := atomic.LoadInt64(received); got != 0 {
+		t.Errorf("targets received %d messages, want none", got)
+	}
+	if !reader.isClosed() {
+		t.Error("stream of the refused batch was not closed")
+	}
+}
@@ -0,0 +1,223 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// defaultReadyTimeout is how long a flow waits for a node to become ready
+// before its start fails
+const defaultReadyTimeout = 30 * time.Second
+
+// Readier is implemented by node instances that become ready some time
+// after Start returned, such as nodes connecting in the background. The
+// nodes of a flow that start after such a node do so once it is ready.
+type Readier interface {
+	// WaitReady blocks until the node is ready or ctx is done, and returns
+	// an error if the node failed to become ready
+	WaitReady(ctx context.Context) error
+}
+
+// SetReadyTimeout sets how long flows wait for a node to become ready
+// before their start fails
+func (e *Engine) SetReadyTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&e.readyTimeout, int64(timeout))
+}
+
+// validateStartAfter checks that nodes start after existing nodes other
+// than themselves
+func validateStartAfter(def *FlowDefinition, nodes map[string]bool) []ValidationIssue {
+	var errs []ValidationIssue
+	for _, nodeDef := range def.Nodes {
+		for _, id := range nodeDef.StartAfter {
+			switch {
+			case id == nodeDef.ID:
+				errs = append(errs, ValidationIssue{NodeID: nodeDef.ID, Message: "node starts after itself"})
+			case !nodes[id]:
+				errs = append(errs, ValidationIssue{
+					NodeID:  nodeDef.ID,
+					Message: fmt.Sprintf("node %q to start after not found", id),
+				})
+			}
+		}
+	}
+	return errs
+}
+
+// dependencies returns the nodes the node starts after: those listed in its
+// definition's startAfter and those its config references through the
+// fields its type lists in References. Disabled nodes are left out.
+func (f *Flow) dependencies(node *Node, startAfter []string) ([]string, error) {
+	var ids []string
+	ids = append(ids, startAfter...)
+
+	if len(node.Type.References) > 0 && len(node.Config) > 0 {
+		var config map[string]interface{}
+		if err := json.Unmarshal(node.Config, &config); err != nil {
+			return nil, fmt.Errorf("invalid config: %w", err)
+		}
+		for _, field := range node.Type.References {
+			switch value := config[field].(type) {
+			case string:
+				if value != "" {
+					ids = append(ids, value)
+				}
+			case []interface{}:
+				for _, item := range value {
+					if id, ok := item.(string); ok && id != "" {
+						ids = append(ids, id)
+					}
+				}
+			}
+		}
+	}
+
+	var deps []string
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] || f.isVirtual(id) {
+			continue
+		}
+		if _, exists := f.Nodes[id]; !exists {
+			return nil, fmt.Errorf("referenced node %s not found", id)
+		}
+		seen[id] = true
+		deps = append(deps, id)
+	}
+	return deps, nil
+}
+
+// startOrder returns the nodes in the order they start: every node after
+// the nodes it depends on and, unless that contradicts a dependency, after
+// the nodes it sends to, so that source nodes start last and no messages
+// flow before the nodes downstream run. Wire cycles are broken by node ID;
+// nodes depending on each other are an error.
+func (f *Flow) startOrder() ([]*Node, error) {
+	ids := make([]string, 0, len(f.Nodes))
+	for id := range f.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	// pendingDeps and pendingTargets count the dependencies and wire
+	// targets of each node that have not started yet
+	pendingDeps := make(map[string]int, len(ids))
+	pendingTargets := make(map[string]int, len(ids))
+	dependents := make(map[string][]string)
+	senders := make(map[string][]string)
+	for _, id := range ids {
+		for _, dep := range f.Nodes[id].after {
+			pendingDeps[id]++
+			dependents[dep] = append(dependents[dep], id)
+		}
+	}
+	for _, wire := range f.connections {
+		if wire.Source == wire.Target {
+			continue
+		}
+		pendingTargets[wire.Source]++
+		senders[wire.Target] = append(senders[wire.Target], wire.Source)
+	}
+
+	order := make([]*Node, 0, len(ids))
+	started := make(map[string]bool, len(ids))
+	for len(order) < len(ids) {
+		next := ""
+		for _, id := range ids {
+			if !started[id] && pendingDeps[id] == 0 && pendingTargets[id] == 0 {
+				next = id
+				break
+			}
+		}
+		if next == "" {
+			// A wire cycle, or wires against dependencies
+			for _, id := range ids {
+				if !started[id] && pendingDeps[id] == 0 {
+					next = id
+					break
+				}
+			}
+		}
+		if next == "" {
+			var cycle []string
+			for _, id := range ids {
+				if !started[id] {
+					cycle = append(cycle, id)
+				}
+			}
+			return nil, fmt.Errorf("nodes %s depend on each other", strings.Join(cycle, ", "))
+		}
+
+		started[next] = true
+		order = append(order, f.Nodes[next])
+		for _, id := range dependents[next] {
+			pendingDeps[id]--
+		}
+		for _, id := range senders[next] {
+			pendingTargets[id]--
+		}
+	}
+	return order, nil
+}
+
+// dependents returns the nodes that depend on a node, directly or through
+// other nodes, in start order
+func (f *Flow) dependents(id string) []string {
+	affected := map[string]bool{id: true}
+	var ids []string
+	for _, node := range f.order {
+		for _, dep := range node.after {
+			if affected[dep] && !affected[node.ID] {
+				affected[node.ID] = true
+				ids = append(ids, node.ID)
+			}
+		}
+	}
+	return ids
+}
+
+// startError describes a node that failed to start along with the nodes
+// depending on it
+func (f *Flow) startError(node *Node, err error) error {
+	if dependents := f.dependents(node.ID); len(dependents) > 0 {
+		return fmt.Errorf("failed to start node %s (needed by %s): %w",
+			node.ID, strings.Join(dependents, ", "), err)
+	}
+	return fmt.Errorf("failed to start node %s: %w", node.ID, err)
+}
+
+// waitReady waits until the node's instances are ready, for at most the
+// engine's ready timeout
+func (n *Node) waitReady(ctx context.Context) error {
+	instances := []NodeInstance{n.instance}
+	if n.parallel != nil {
+		instances = n.parallel.instances
+	}
+
+	timeout := defaultReadyTimeout
+	if n.flow != nil && n.flow.engine != nil {
+		timeout = time.Duration(atomic.LoadInt64(&n.flow.engine.readyTimeout))
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for _, instance := range instances {
+		readier, ok := instance.(Readier)
+		if !ok {
+			continue
+		}
+		err := readier.WaitReady(ctx)
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("not ready within %s", timeout)
+		}
+		if err != nil {
+			return fmt.Errorf("not ready: %w", err)
+		}
+	}
+	return nil
+}
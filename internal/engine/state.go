@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// newStateStore returns the storage if it keeps node states, and a store in
+// memory otherwise
+func newStateStore(store storage.Storage) storage.StateStore {
+	if states, ok := store.(storage.StateStore); ok {
+		return states
+	}
+	return storage.NewMemoryStorage()
+}
+
+// stateKey identifies the state of a node
+func (n *Node) stateKey() (string, error) {
+	if n.flow == nil || n.flow.engine == nil {
+		return "", fmt.Errorf("node %s has no state: it does not belong to a flow", n.ID)
+	}
+	return n.flow.ID + "/" + n.ID, nil
+}
+
+// LoadState decodes the state the node saved with SaveState into v and
+// reports whether there was one
+func (n *Node) LoadState(v interface{}) (bool, error) {
+	key, err := n.stateKey()
+	if err != nil {
+		return false, err
+	}
+	data, exists, err := n.flow.engine.states.LoadNodeState(key)
+	if err != nil || !exists {
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("invalid state of node %s: %w", n.ID, err)
+	}
+	return true, nil
+}
+
+// SaveState saves the node's state as JSON in the storage, so that it
+// survives restarts. The state is deleted with the flow.
+func (n *Node) SaveState(v interface{}) error {
+	key, err := n.stateKey()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode state of node %s: %w", n.ID, err)
+	}
+	return n.flow.engine.states.SaveNodeState(key, data)
+}
+
+// deleteStates deletes the states of the flow's nodes
+func (f *Flow) deleteStates() {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, node := range f.Nodes {
+		if err := f.engine.states.DeleteNodeState(f.ID + "/" + node.ID); err != nil {
+			node.Logger().Warn("failed to delete node state", "error", err)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package engine
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// EventFlowStatus is published when a flow's status changes
+const EventFlowStatus = "flow-status"
+
+// defaultPanicThreshold is how many node panics a flow survives before it is
+// stopped with an error
+const defaultPanicThreshold = 10
+
+// FlowState describes a flow's status and its last transition
+type FlowState struct {
+	Status FlowStatus `json:"status"`
+	Since  time.Time  `json:"since"`
+	// Reason and FailedNodeID explain an error status
+	Reason       string `json:"reason,omitempty"`
+	FailedNodeID string `json:"failedNodeId,omitempty"`
+	// MissingTypes are the node types of the flow that are not installed
+	MissingTypes []string `json:"missingTypes,omitempty"`
+}
+
+// PanicError is returned when a node panics while handling a message
+type PanicError struct {
+	NodeID string
+	Value  interface{}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("node %s panicked: %v", e.NodeID, e.Value)
+}
+
+// State returns the flow's status with the time and reason of its last
+// transition
+func (f *Flow) State() FlowState {
+	status := f.GetStatus()
+	if status == FlowStatusThrottled {
+		if state, throttled := f.throttleState(); throttled {
+			state.MissingTypes = f.MissingTypes()
+			return state
+		}
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return FlowState{
+		Status:       status,
+		Since:        f.since,
+		Reason:       f.reason,
+		FailedNodeID: f.failedNodeID,
+		MissingTypes: f.MissingTypes(),
+	}
+}
+
+// setStatus records a status transition and returns the event announcing
+// it, which the caller publishes after releasing f.mu
+func (f *Flow) setStatus(status FlowStatus, reason, nodeID string) Event {
+	f.status = status
+	f.since = f.clock().Now()
+	f.reason = reason
+	f.failedNodeID = nodeID
+	return f.statusEvent(FlowState{
+		Status:       status,
+		Since:        f.since,
+		Reason:       reason,
+		FailedNodeID: nodeID,
+	})
+}
+
+// statusEvent returns the event announcing the flow's state
+func (f *Flow) statusEvent(state FlowState) Event {
+	return Event{
+		Type:      EventFlowStatus,
+		Workspace: f.Workspace(),
+		FlowID:    f.ID,
+		NodeID:    state.FailedNodeID,
+		Data:      state,
+	}
+}
+
+// publish publishes an event on the engine's event bus
+func (f *Flow) publish(event Event) {
+	if f.engine != nil {
+		f.engine.events.Publish(event)
+	}
+}
+
+func (f *Flow) clock() Clock {
+	if f.engine == nil || f.engine.clock == nil {
+		return SystemClock{}
+	}
+	return f.engine.clock
+}
+
+// fail stops the flow and puts it into the error status
+func (f *Flow) fail(nodeID, reason string) {
+	f.Logger().Warn("flow failed", "reason", reason)
+	f.Stop()
+
+	f.mu.Lock()
+	event := f.setStatus(FlowStatusError, reason, nodeID)
+	f.mu.Unlock()
+	f.publish(event)
+}
+
+// onMessage passes a message to a node instance, running the engine's
+// receive hooks around it. Messages beyond the flow's concurrency limit are
+// refused.
+func onMessage(instance NodeInstance, msg *Message, port int) error {
+	switch instance.(type) {
+	case *queuedInstance, *parallelInstance:
+		// The hooks run when one of the node's instances handles the message
+		return handleMessage(instance, msg, port)
+	}
+	node := instance.GetNode()
+	if node != nil {
+		if err := node.flow.enter(node); err != nil {
+			return err
+		}
+		defer node.flow.leave()
+	}
+	return receiveWithHooks(node, msg, port, func() error {
+		return observeHandled(node, func() error {
+			return handleMessage(instance, msg, port)
+		})
+	})
+}
+
+// handleMessage passes a message to a node instance, turning a panic into a
+// *PanicError. Once the flow's nodes panicked more often than the engine's
+// panic threshold, the flow is stopped with an error.
+func handleMessage(instance NodeInstance, msg *Message, port int) (err error) {
+	defer func() {
+		value := recover()
+		if value == nil {
+			return
+		}
+		node := instance.GetNode()
+		if node == nil {
+			panic(value)
+		}
+		err = &PanicError{NodeID: node.ID, Value: value}
+		node.Logger().Error("node panicked", "error", err, "stack", string(debug.Stack()))
+		if node.flow != nil {
+			node.flow.recordPanic(err.(*PanicError))
+		}
+	}()
+	return instance.OnMessage(msg, port)
+}
+
+// recordPanic counts a node panic and fails the flow when the count crosses
+// the threshold
+func (f *Flow) recordPanic(err *PanicError) {
+	threshold := int32(defaultPanicThreshold)
+	if f.engine != nil {
+		threshold = atomic.LoadInt32(&f.engine.panicThreshold)
+	}
+	if threshold <= 0 || atomic.AddInt32(&f.panics, 1) != threshold {
+		return
+	}
+	// The panicking goroutine may be one that stopping the flow waits for
+	go f.fail(err.NodeID, fmt.Sprintf("%v (%d panics)", err, threshold))
+}
+
+// SetPanicThreshold sets how many node panics a flow survives before it is
+// stopped with an error; 0 keeps flows running regardless
+func (e *Engine) SetPanicThreshold(threshold int) {
+	atomic.StoreInt32(&e.panicThreshold, int32(threshold))
+}
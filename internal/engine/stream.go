@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrStreamFanOut is returned by Send when a message carrying a stream is
+// wired to more than one node; a stream can only be read once
+var ErrStreamFanOut = errors.New("stream payloads can only be sent to a single node")
+
+// ErrStreamClosed is returned when reading a closed stream
+var ErrStreamClosed = errors.New("stream is closed")
+
+// openStreams counts streams that have not been closed yet
+var openStreams int64
+
+// Stream is a payload that is passed through the flow as a reader instead
+// of being held in memory, for large files and bodies.
+//
+// A stream has exactly one owner at a time, which must either pass it on or
+// close it:
+//   - the node whose OnMessage received the stream owns it
+//   - Send hands the stream to the single wired node; if it cannot be
+//     delivered (no wires, several wires, or the receiver returned an error)
+//     Send closes it
+//   - reading a stream to the end closes it
+//   - a stream that is garbage collected while open is closed and a
+//     warning logged, so a node dropping a stream does not leak the reader
+type Stream struct {
+	// Length is the number of bytes, or -1 if unknown
+	Length int64
+	// ContentType is the MIME type of the data, if known
+	ContentType string
+
+	reader io.ReadCloser
+	read   int64
+	closed bool
+	mu     sync.Mutex
+}
+
+// NewStream creates a stream payload reading from r
+func NewStream(r io.ReadCloser, length int64, contentType string) *Stream {
+	if length < 0 {
+		length = -1
+	}
+	s := &Stream{Length: length, ContentType: contentType, reader: r}
+	atomic.AddInt64(&openStreams, 1)
+	runtime.SetFinalizer(s, (*Stream).finalize)
+	return s
+}
+
+// OpenStreams returns the number of streams that have not been closed, for
+// detecting leaks
+func OpenStreams() int64 {
+	return atomic.LoadInt64(&openStreams)
+}
+
+// Read reads from the stream, closing it at the end of the data
+func (s *Stream) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return 0, ErrStreamClosed
+	}
+	n, err := s.reader.Read(p)
+	s.read += int64(n)
+	if err == io.EOF {
+		s.close()
+	}
+	return n, err
+}
+
+// Close closes the underlying reader; closing twice is a no-op
+func (s *Stream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.close()
+}
+
+// Bytes reads the rest of the stream into memory and closes it, for nodes
+// that need the whole payload
+func (s *Stream) Bytes() ([]byte, error) {
+	defer s.Close()
+	return ioutil.ReadAll(s)
+}
+
+// Closed reports whether the stream was closed
+func (s *Stream) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// String describes the stream without reading it
+func (s *Stream) String() string {
+	contentType := s.ContentType
+	if contentType == "" {
+		contentType = "unknown type"
+	}
+	if s.Length < 0 {
+		return fmt.Sprintf("[stream of unknown length, %s]", contentType)
+	}
+	return fmt.Sprintf("[stream of %d bytes, %s]", s.Length, contentType)
+}
+
+// MarshalJSON describes the stream without reading it, so that debug
+// output and message dumps do not consume the data
+func (s *Stream) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"stream":      true,
+		"length":      s.Length,
+		"contentType": s.ContentType,
+	})
+}
+
+// close closes the reader; s.mu must be held
+func (s *Stream) close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	atomic.AddInt64(&openStreams, -1)
+	runtime.SetFinalizer(s, nil)
+	return s.reader.Close()
+}
+
+// finalize closes a stream that nobody closed
+func (s *Stream) finalize() {
+	if !s.closed {
+		slog.Warn("stream payload was dropped without being closed", "bytes_read", s.read)
+		s.close()
+	}
+}
+
+// sendStream hands a stream payload to the single target of the port it
+// is sent on and closes it if that is not possible
+func (n *Node) sendStream(msg *Message, stream *Stream, targets []NodeInstance) error {
+
+	switch len(targets) {
+	case 0:
+		stream.Close() // nobody is going to read it
+		return nil
+	case 1:
+		if err := deliver(targets[0], msg.Clone()); err != nil {
+			stream.Close()
+			return fmt.Errorf("error sending message to node: %w", err)
+		}
+		return nil
+	default:
+		stream.Close()
+		return fmt.Errorf("node %s: %w", n.ID, ErrStreamFanOut)
+	}
+}
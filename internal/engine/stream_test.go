@@ -0,0 +1,195 @@
+package engine_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/registry"
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// trackedReader records whether it was closed
+type trackedReader struct {
+	*strings.Reader
+	closed int32
+}
+
+func newTrackedReader(data string) *trackedReader {
+	return &trackedReader{Reader: strings.NewReader(data)}
+}
+
+func (r *trackedReader) Close() error {
+	atomic.StoreInt32(&r.closed, 1)
+	return nil
+}
+
+func (r *trackedReader) isClosed() bool {
+	return atomic.LoadInt32(&r.closed) == 1
+}
+
+// streamSink keeps the streams it receives, or fails to receive them
+type streamSink struct {
+	engine.BaseNode
+	fail     bool
+	received []*engine.Stream
+}
+
+func (n *streamSink) Init(config json.RawMessage) error {
+	var c struct {
+		Fail bool `json:"fail"`
+	}
+	if err := n.LoadConfig(config, &c); err != nil {
+		return err
+	}
+	n.fail = c.Fail
+	return nil
+}
+
+func (n *streamSink) Start(ctx context.Context) error { return nil }
+func (n *streamSink) Stop()                           {}
+
+func (n *streamSink) OnMessage(msg *engine.Message, port int) error {
+	if n.fail {
+		return errors.New("receiver failed")
+	}
+	n.received = append(n.received, msg.Payload.(*engine.Stream))
+	return nil
+}
+
+func TestStreamOwnership(t *testing.T) {
+	tests := []struct {
+		name string
+		// sinks are the configs of the nodes wired to the sender
+		sinks   []string
+		stopped bool
+		// wantErr is part of the error Send returns
+		wantErr string
+		// wantOpen is whether the stream is left to a receiver
+		wantOpen bool
+	}{
+		{name: "no wires"},
+		{name: "single receiver", sinks: []string{`{}`}, wantOpen: true},
+		{name: "receiver fails", sinks: []string{`{"fail": true}`}, wantErr: "receiver failed"},
+		{name: "fan-out", sinks: []string{`{}`, `{}`}, wantErr: engine.ErrStreamFanOut.Error()},
+		{name: "sender stopped", sinks: []string{`{}`}, stopped: true, wantErr: "not running"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := registry.New()
+			var sinks []*streamSink
+			for _, name := range []string{"source", "sink"} {
+				if err := reg.RegisterNodeType(&engine.NodeType{
+					Name: name,
+					Factory: func() engine.NodeInstance {
+						sink := &streamSink{}
+						sinks = append(sinks, sink)
+						return sink
+					},
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			eng := engine.New(reg, storage.NewMemoryStorage())
+			if err := eng.Start(); err != nil {
+				t.Fatal(err)
+			}
+			defer eng.Stop()
+
+			nodes := []string{`{"id": "source", "type": "source"}`}
+			var wires []string
+			for i, config := range tt.sinks {
+				nodes = append(nodes, fmt.Sprintf(`{"id": "sink%d", "type": "sink", "config": %s}`, i, config))
+				wires = append(wires, fmt.Sprintf(`{"source": "source", "target": "sink%d", "port": 0}`, i))
+			}
+			flowDef := fmt.Sprintf(`{"id": "streams", "nodes": [%s], "wires": [%s]}`, strings.Join(nodes, ", "), strings.Join(wires, ", "))
+			if err := eng.DeployFlow("streams", []byte(flowDef)); err != nil {
+				t.Fatal(err)
+			}
+			flow, _ := eng.GetFlow("streams")
+			source, _ := flow.GetNode("source")
+			if tt.stopped {
+				source.Stop()
+			}
+
+			open := engine.OpenStreams()
+			reader := newTrackedReader("data")
+			stream := engine.NewStream(reader, 4, "text/plain")
+			err := source.Send(engine.NewMessage(stream, ""), 0)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("Send: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("Send error = %v, want %q", err, tt.wantErr)
+			}
+
+			if reader.isClosed() == tt.wantOpen {
+				t.Errorf("reader closed = %v, want %v", reader.isClosed(), !tt.wantOpen)
+			}
+			var received []*engine.Stream
+			for _, sink := range sinks {
+				received = append(received, sink.received...)
+			}
+			if tt.wantOpen {
+				if len(received) != 1 || received[0] != stream {
+					t.Fatalf("receivers got %v, want the stream", received)
+				}
+				// The receiver owns it now, and closes it by reading it
+				if data, err := received[0].Bytes(); err != nil || string(data) != "data" {
+					t.Errorf("receiver read %q, %v", data, err)
+				}
+				if !reader.isClosed() {
+					t.Error("reading the stream to the end did not close the reader")
+				}
+			} else if len(received) != 0 {
+				t.Errorf("receivers got %d streams, want none", len(received))
+			}
+			if got := engine.OpenStreams(); got != open {
+				t.Errorf("%d streams open, want %d", got, open)
+			}
+		})
+	}
+}
+
+func TestDroppedStreamClosed(t *testing.T) {
+	open := engine.OpenStreams()
+	reader := newTrackedReader("data")
+	engine.NewStream(reader, -1, "")
+
+	// The finalizer closes the stream once it is collected
+	deadline := time.Now().Add(5 * time.Second)
+	for !reader.isClosed() && time.Now().Before(deadline) {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !reader.isClosed() {
+		t.Fatal("dropped stream was not closed")
+	}
+	if got := engine.OpenStreams(); got != open {
+		t.Errorf("%d streams open, want %d", got, open)
+	}
+}
+
+func TestClosedStream(t *testing.T) {
+	reader := newTrackedReader("data")
+	stream := engine.NewStream(reader, 4, "")
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Close(); err != nil {
+		t.Errorf("closing twice: %v", err)
+	}
+	if _, err := stream.Read(make([]byte, 4)); !errors.Is(err, engine.ErrStreamClosed) {
+		t.Errorf("reading a closed stream: %v, want %v", err, engine.ErrStreamClosed)
+	}
+	if !stream.Closed() || !reader.isClosed() {
+		t.Error("stream or reader not closed")
+	}
+}
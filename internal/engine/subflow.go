@@ -0,0 +1,413 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SubflowTypePrefix is the prefix of the node type of subflow instances,
+// followed by the subflow's ID
+const SubflowTypePrefix = "subflow:"
+
+// maxSubflowDepth bounds how deeply subflow instances may be nested
+const maxSubflowDepth = 8
+
+// SubflowDefinition packages a group of nodes as a node type. Subflows are
+// defined by a flow and can be used by every flow; their instances are
+// replaced by copies of their nodes when a flow is created.
+type SubflowDefinition struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Category    string           `json:"category,omitempty"`
+	Nodes       []NodeDefinition `json:"nodes"`
+	Wires       []WireDefinition `json:"wires"`
+	// In lists the nodes that the messages sent to an instance are
+	// delivered to
+	In []string `json:"in,omitempty"`
+	// Out lists, for each output of an instance, the node outputs whose
+	// messages it sends
+	Out [][]SubflowPort `json:"out,omitempty"`
+	// Env holds the default environment values, which instances override.
+	// Node configs refer to them as ${NAME}.
+	Env map[string]interface{} `json:"env,omitempty"`
+}
+
+// SubflowPort is the output of a node inside a subflow
+type SubflowPort struct {
+	Node string `json:"node"`
+	Port int    `json:"port"`
+}
+
+// TypeName returns the node type of the subflow's instances
+func (s *SubflowDefinition) TypeName() string {
+	return SubflowTypePrefix + s.ID
+}
+
+// SubflowInstanceConfig represents the configuration of a subflow instance
+type SubflowInstanceConfig struct {
+	// Env overrides environment values of the subflow
+	Env map[string]interface{} `json:"env"`
+}
+
+// validateSubflows checks that subflows have unique IDs and valid nodes,
+// wires and ports
+func validateSubflows(def *FlowDefinition) []ValidationIssue {
+	var errs []ValidationIssue
+	ids := make(map[string]bool, len(def.Subflows))
+	for i, subflow := range def.Subflows {
+		switch {
+		case subflow.ID == "":
+			errs = append(errs, ValidationIssue{Message: fmt.Sprintf("subflow %d (%s) has no ID", i, subflow.Name)})
+			continue
+		case ids[subflow.ID]:
+			errs = append(errs, ValidationIssue{Message: fmt.Sprintf("duplicate subflow ID %q", subflow.ID)})
+			continue
+		}
+		ids[subflow.ID] = true
+
+		issue := func(nodeID, problem string) ValidationIssue {
+			return ValidationIssue{NodeID: nodeID, Message: fmt.Sprintf("subflow %q: %s", subflow.ID, problem)}
+		}
+		_, err := ValidateFlow(&FlowDefinition{ID: subflow.ID, Nodes: subflow.Nodes, Wires: subflow.Wires})
+		if verr, ok := err.(*ValidationError); ok {
+			for _, inner := range verr.Issues {
+				errs = append(errs, issue(inner.NodeID, inner.Message))
+			}
+		}
+
+		nodes := make(map[string]bool, len(subflow.Nodes))
+		for _, nodeDef := range subflow.Nodes {
+			nodes[nodeDef.ID] = true
+		}
+		for _, id := range subflow.In {
+			if !nodes[id] {
+				errs = append(errs, issue(id, "input node not found"))
+			}
+		}
+		for output, ports := range subflow.Out {
+			for _, port := range ports {
+				switch {
+				case !nodes[port.Node]:
+					errs = append(errs, issue(port.Node, fmt.Sprintf("node of output %d not found", output)))
+				case port.Port < 0:
+					errs = append(errs, issue(port.Node, fmt.Sprintf("output %d: port is negative", output)))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// subflowExpander replaces the subflow instances of a flow by the nodes of
+// their subflows
+type subflowExpander struct {
+	// lookup returns the subflow of an instance's node type
+	lookup func(typeName string) (*SubflowDefinition, bool)
+	// references returns the config fields of a node type that hold node IDs
+	references func(typeName string) []string
+}
+
+// expandedGraph is a set of nodes and wires whose subflow instances were
+// replaced. The IDs of the nodes of an instance are prefixed with the
+// instance's ID and a slash, so instance "a" of a subflow with node "b"
+// has node "a/b".
+type expandedGraph struct {
+	prefix string
+	nodes  []NodeDefinition
+	wires  []WireDefinition
+	// ins and outs hold, by local instance ID, the nodes its input
+	// delivers to and the node outputs each of its outputs sends
+	ins  map[string][]string
+	outs map[string][][]SubflowPort
+}
+
+// targets returns the nodes that messages wired to the local node id are
+// delivered to
+func (g *expandedGraph) targets(id string) []string {
+	if inputs, instance := g.ins[id]; instance {
+		return inputs
+	}
+	return []string{g.prefix + id}
+}
+
+// sources returns the node outputs that send what the local node id sends
+// on port
+func (g *expandedGraph) sources(id string, port int) ([]SubflowPort, error) {
+	outputs, instance := g.outs[id]
+	if !instance {
+		return []SubflowPort{{Node: g.prefix + id, Port: port}}, nil
+	}
+	if port >= len(outputs) {
+		return nil, fmt.Errorf("subflow instance %s has no output %d", g.prefix+id, port)
+	}
+	return outputs[port], nil
+}
+
+// expand replaces the subflow instances among nodes, recursively, and
+// rewires wires to and from them. Node IDs are prefixed with prefix and
+// env is substituted in node configs; disabled disables every node.
+// stack holds the IDs of the subflows being expanded.
+func (x *subflowExpander) expand(prefix string, nodes []NodeDefinition, wires []WireDefinition, env map[string]interface{}, disabled bool, stack []string) (*expandedGraph, error) {
+	g := &expandedGraph{
+		prefix: prefix,
+		ins:    make(map[string][]string),
+		outs:   make(map[string][][]SubflowPort),
+	}
+	local := make(map[string]bool, len(nodes))
+	for _, nodeDef := range nodes {
+		local[nodeDef.ID] = true
+	}
+
+	for _, nodeDef := range nodes {
+		id := nodeDef.ID
+		config, err := substituteEnv(nodeDef.Config, env)
+		if err != nil {
+			return nil, fmt.Errorf("node %s: %w", prefix+id, err)
+		}
+		if prefix != "" {
+			if config, err = prefixReferences(config, x.references(nodeDef.Type), prefix, local); err != nil {
+				return nil, fmt.Errorf("node %s: %w", prefix+id, err)
+			}
+			startAfter := make([]string, len(nodeDef.StartAfter))
+			for i, after := range nodeDef.StartAfter {
+				startAfter[i] = prefix + after
+			}
+			nodeDef.StartAfter = startAfter
+		}
+		nodeDef.ID = prefix + id
+		nodeDef.Config = config
+		nodeDef.Disabled = nodeDef.Disabled || disabled
+
+		subflow, isInstance := x.lookup(nodeDef.Type)
+		if !isInstance {
+			g.nodes = append(g.nodes, nodeDef)
+			continue
+		}
+
+		for i, outer := range stack {
+			if outer == subflow.ID {
+				cycle := append(append([]string(nil), stack[i:]...), subflow.ID)
+				return nil, fmt.Errorf("subflow recursion: %s", strings.Join(cycle, " -> "))
+			}
+		}
+		if len(stack) >= maxSubflowDepth {
+			return nil, fmt.Errorf("node %s: subflows are nested deeper than %d levels", nodeDef.ID, maxSubflowDepth)
+		}
+
+		var instance SubflowInstanceConfig
+		if len(config) > 0 {
+			if err := json.Unmarshal(config, &instance); err != nil {
+				return nil, fmt.Errorf("node %s: invalid config: %w", nodeDef.ID, err)
+			}
+		}
+		// Instances see the environment of the enclosing subflow, the
+		// subflow's defaults and their own values, in increasing priority
+		instanceEnv := make(map[string]interface{}, len(env)+len(subflow.Env)+len(instance.Env))
+		for _, values := range []map[string]interface{}{env, subflow.Env, instance.Env} {
+			for name, value := range values {
+				instanceEnv[name] = value
+			}
+		}
+
+		inner, err := x.expand(nodeDef.ID+"/", subflow.Nodes, subflow.Wires, instanceEnv, nodeDef.Disabled, append(stack, subflow.ID))
+		if err != nil {
+			return nil, err
+		}
+		g.nodes = append(g.nodes, inner.nodes...)
+		g.wires = append(g.wires, inner.wires...)
+
+		var inputs []string
+		for _, in := range subflow.In {
+			inputs = append(inputs, inner.targets(in)...)
+		}
+		outputs := make([][]SubflowPort, len(subflow.Out))
+		for i, ports := range subflow.Out {
+			for _, port := range ports {
+				sources, err := inner.sources(port.Node, port.Port)
+				if err != nil {
+					return nil, err
+				}
+				outputs[i] = append(outputs[i], sources...)
+			}
+		}
+		g.ins[id], g.outs[id] = inputs, outputs
+	}
+
+	for _, wireDef := range wires {
+		sources, err := g.sources(wireDef.Source, wireDef.Port)
+		if err != nil {
+			return nil, err
+		}
+		for _, source := range sources {
+			for _, target := range g.targets(wireDef.Target) {
+				wire := wireDef
+				wire.Source, wire.Port, wire.Target = source.Node, source.Port, target
+				g.wires = append(g.wires, wire)
+			}
+		}
+	}
+	return g, nil
+}
+
+// envReference matches a reference to an environment value in a config
+var envReference = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// substituteEnv replaces the references to env in the strings of a config.
+// A string that is a single reference takes the value with its type, so
+// that numbers stay numbers; unknown references are kept.
+func substituteEnv(config json.RawMessage, env map[string]interface{}) (json.RawMessage, error) {
+	if len(env) == 0 || len(config) == 0 || !bytes.Contains(config, []byte("${")) {
+		return config, nil
+	}
+	value, err := decodeConfigValue(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return json.Marshal(substituteValue(value, env))
+}
+
+// substituteValue substitutes env in a decoded config value
+func substituteValue(value interface{}, env map[string]interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if match := envReference.FindStringSubmatch(v); match != nil && match[0] == v {
+			if value, exists := env[match[1]]; exists {
+				return value
+			}
+			return v
+		}
+		return envReference.ReplaceAllStringFunc(v, func(reference string) string {
+			if value, exists := env[reference[2:len(reference)-1]]; exists {
+				return fmt.Sprint(value)
+			}
+			return reference
+		})
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = substituteValue(item, env)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = substituteValue(item, env)
+		}
+	}
+	return value
+}
+
+// prefixReferences prefixes the node IDs in the reference fields of a
+// config that name nodes of the same subflow
+func prefixReferences(config json.RawMessage, fields []string, prefix string, local map[string]bool) (json.RawMessage, error) {
+	if len(fields) == 0 || len(config) == 0 {
+		return config, nil
+	}
+	value, err := decodeConfigValue(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return config, nil
+	}
+	for _, field := range fields {
+		switch v := object[field].(type) {
+		case string:
+			if local[v] {
+				object[field] = prefix + v
+			}
+		case []interface{}:
+			for i, item := range v {
+				if id, ok := item.(string); ok && local[id] {
+					v[i] = prefix + id
+				}
+			}
+		}
+	}
+	return json.Marshal(object)
+}
+
+// decodeConfigValue decodes a config keeping numbers as they are written
+func decodeConfigValue(config json.RawMessage) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(config))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// expandSubflows replaces the subflow instances of def by the nodes of
+// their subflows, keeping the instances and subflows for ToJSON. Subflows
+// the flow defines take precedence over those registered by other flows.
+func (f *Flow) expandSubflows(def *FlowDefinition) error {
+	f.subflows = def.Subflows
+	x := &subflowExpander{
+		lookup: func(typeName string) (*SubflowDefinition, bool) {
+			if !strings.HasPrefix(typeName, SubflowTypePrefix) {
+				return nil, false
+			}
+			id := strings.TrimPrefix(typeName, SubflowTypePrefix)
+			for i := range def.Subflows {
+				if def.Subflows[i].ID == id {
+					return &def.Subflows[i], true
+				}
+			}
+			return f.engine.GetRegistry().GetSubflow(typeName)
+		},
+		references: func(typeName string) []string {
+			if nodeType, err := f.engine.GetRegistry().GetNodeType(typeName); err == nil {
+				return nodeType.References
+			}
+			return nil
+		},
+	}
+
+	// Instances in disabled groups disable their nodes
+	disabled := disabledNodes(def)
+	nodes := make([]NodeDefinition, len(def.Nodes))
+	instances := 0
+	for i, nodeDef := range def.Nodes {
+		if _, isInstance := x.lookup(nodeDef.Type); isInstance {
+			f.instances = append(f.instances, nodeDef)
+			nodeDef.Disabled = disabled[nodeDef.ID]
+			instances++
+		}
+		nodes[i] = nodeDef
+	}
+	if instances == 0 {
+		return nil
+	}
+
+	g, err := x.expand("", nodes, def.Wires, nil, false, nil)
+	if err != nil {
+		return err
+	}
+	outer := make(map[string]bool, len(def.Nodes))
+	for _, nodeDef := range def.Nodes {
+		outer[nodeDef.ID] = true
+	}
+	f.expanded = make(map[string]bool)
+	for _, nodeDef := range g.nodes {
+		if !outer[nodeDef.ID] {
+			f.expanded[nodeDef.ID] = true
+		}
+	}
+	def.Nodes, def.Wires = g.nodes, g.wires
+	return nil
+}
+
+// registerSubflows registers the subflows a flow definition defines,
+// replacing those it defined before; e.mu must be held
+func (e *Engine) registerSubflows(id string, flowDef []byte) error {
+	var def struct {
+		Subflows []SubflowDefinition `json:"subflows"`
+	}
+	if err := json.Unmarshal(flowDef, &def); err != nil {
+		return fmt.Errorf("failed to unmarshal flow definition: %w", err)
+	}
+	return e.registry.SetSubflows(id, def.Subflows)
+}
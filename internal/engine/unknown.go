@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// SetAllowUnknownTypes sets whether flows with node types that are not
+// installed deploy, with placeholders for those nodes, instead of failing
+func (e *Engine) SetAllowUnknownTypes(allow bool) {
+	var value int32
+	if allow {
+		value = 1
+	}
+	atomic.StoreInt32(&e.allowUnknown, value)
+}
+
+// AllowUnknownTypes reports whether flows may contain unknown node types
+func (e *Engine) AllowUnknownTypes() bool {
+	return atomic.LoadInt32(&e.allowUnknown) == 1
+}
+
+// unknownNodeType returns the type of the placeholders of an unknown type
+func unknownNodeType(name string) *NodeType {
+	return &NodeType{
+		Name:        name,
+		Description: "Placeholder for a node type that is not installed",
+		Factory: func() NodeInstance {
+			return &unknownNode{typeName: name}
+		},
+	}
+}
+
+// unknownNode stands in for a node whose type is not installed. It keeps
+// the node's config and wires, and drops the messages it receives.
+type unknownNode struct {
+	BaseNode
+	typeName string
+	warned   sync.Once
+}
+
+// Init accepts any configuration
+func (n *unknownNode) Init(config json.RawMessage) error {
+	return nil
+}
+
+// Start reports the missing type as node status
+func (n *unknownNode) Start(ctx context.Context) error {
+	// Set once Start returns, as the node is locked while it runs
+	go n.GetNode().SetStatus(NodeStatus{Fill: "red", Shape: "ring", Text: "unknown type " + n.typeName})
+	return nil
+}
+
+// Stop stops the node
+func (n *unknownNode) Stop() {}
+
+// OnMessage drops the message, warning about the first one
+func (n *unknownNode) OnMessage(msg *Message, port int) error {
+	n.warned.Do(func() {
+		n.GetNode().Logger().Warn("unknown node type, dropping its messages", "type", n.typeName)
+	})
+	return nil
+}
+
+// addMissingType records a node type of the flow that is not installed
+func (f *Flow) addMissingType(name string) {
+	i := sort.SearchStrings(f.missing, name)
+	if i < len(f.missing) && f.missing[i] == name {
+		return
+	}
+	f.missing = append(f.missing, "")
+	copy(f.missing[i+1:], f.missing[i:])
+	f.missing[i] = name
+}
+
+// MissingTypes returns the node types of the flow that are not installed,
+// whose nodes are placeholders
+func (f *Flow) MissingTypes() []string {
+	return append([]string(nil), f.missing...)
+}
@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ValidationIssue is a problem found in a flow definition
+type ValidationIssue struct {
+	// NodeID is the node the problem concerns; for wires, their source
+	NodeID string `json:"nodeId,omitempty"`
+	// Path is the JSON path of the problem in the flow definition, such
+	// as "nodes[2].config.url", if known
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+func (i ValidationIssue) String() string {
+	if i.NodeID == "" {
+		return i.Message
+	}
+	return fmt.Sprintf("node %q: %s", i.NodeID, i.Message)
+}
+
+// ValidationError reports every error found in a flow definition at once
+type ValidationError struct {
+	Issues []ValidationIssue
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		problems[i] = issue.String()
+	}
+	return "invalid flow: " + strings.Join(problems, "; ")
+}
+
+// ValidateFlow checks the structure of a flow definition: node IDs must be
+// present and unique, wires must connect existing nodes on non-negative
+// ports, each node may be in one group at most and nodes may only start
+// after other existing nodes. Subflows are checked the same way, and their
+// inputs and outputs must be existing nodes. Self-wires, duplicate wires
+// and nodes whose outgoing wires are all disabled are legal but usually
+// mistakes and are returned as warnings. Errors are returned together as a
+// *ValidationError.
+func ValidateFlow(def *FlowDefinition) (warnings []ValidationIssue, err error) {
+	var errs []ValidationIssue
+
+	nodes := make(map[string]bool, len(def.Nodes))
+	for i, nodeDef := range def.Nodes {
+		switch {
+		case nodeDef.ID == "":
+			errs = append(errs, ValidationIssue{
+				Path:    fmt.Sprintf("nodes[%d].id", i),
+				Message: fmt.Sprintf("node %d (%s) has no ID", i, nodeDef.Type),
+			})
+		case nodes[nodeDef.ID]:
+			errs = append(errs, ValidationIssue{
+				NodeID:  nodeDef.ID,
+				Path:    fmt.Sprintf("nodes[%d].id", i),
+				Message: "duplicate node ID",
+			})
+		default:
+			nodes[nodeDef.ID] = true
+		}
+	}
+
+	errs = append(errs, validateGroups(def, nodes)...)
+	errs = append(errs, validateStartAfter(def, nodes)...)
+	errs = append(errs, validateSubflows(def)...)
+
+	type wireKey struct {
+		source, target string
+		port           int
+	}
+	wires := make(map[wireKey]bool, len(def.Wires))
+	outgoing := make(map[string]int)
+	disabled := make(map[string]int)
+	var sources []string
+	for i, wireDef := range def.Wires {
+		issue := func(problem string) ValidationIssue {
+			return ValidationIssue{
+				NodeID:  wireDef.Source,
+				Path:    fmt.Sprintf("wires[%d]", i),
+				Message: fmt.Sprintf("wire to %q on port %d: %s", wireDef.Target, wireDef.Port, problem),
+			}
+		}
+
+		if !nodes[wireDef.Source] {
+			errs = append(errs, issue("source node not found"))
+		}
+		if !nodes[wireDef.Target] {
+			errs = append(errs, issue("target node not found"))
+		}
+		if wireDef.Port < 0 {
+			errs = append(errs, issue("port is negative"))
+		}
+
+		if wireDef.Source == wireDef.Target {
+			warnings = append(warnings, issue("node is wired to itself"))
+		}
+		key := wireKey{wireDef.Source, wireDef.Target, wireDef.Port}
+		if wires[key] {
+			warnings = append(warnings, issue("duplicate wire; messages are delivered twice"))
+		}
+		wires[key] = true
+
+		if outgoing[wireDef.Source] == 0 {
+			sources = append(sources, wireDef.Source)
+		}
+		outgoing[wireDef.Source]++
+		if wireDef.Disabled {
+			disabled[wireDef.Source]++
+		}
+	}
+	for _, source := range sources {
+		if disabled[source] == outgoing[source] {
+			warnings = append(warnings, ValidationIssue{
+				NodeID:  source,
+				Message: "every outgoing wire is disabled; the node's messages go nowhere",
+			})
+		}
+	}
+
+	if len(errs) > 0 {
+		return warnings, &ValidationError{Issues: errs}
+	}
+	return warnings, nil
+}
+
+// validateDefinition parses a flow definition and validates its structure,
+// ignoring warnings
+func validateDefinition(flowDef []byte) error {
+	var def FlowDefinition
+	if err := json.Unmarshal(flowDef, &def); err != nil {
+		return fmt.Errorf("failed to unmarshal flow definition: %w", err)
+	}
+	_, err := ValidateFlow(&def)
+	return err
+}
@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// DefaultVersionRetention is the number of versions kept per flow, unless
+// SetVersionRetention sets another
+const DefaultVersionRetention = 20
+
+// newVersionStore returns the store of flow versions: the storage if it is
+// a version store, and memory otherwise
+func newVersionStore(store storage.Storage) storage.VersionStore {
+	if versions, ok := store.(storage.VersionStore); ok {
+		return versions
+	}
+	return storage.NewMemoryStorage()
+}
+
+// SetVersionRetention sets the number of versions kept per flow; 0 keeps
+// none
+func (e *Engine) SetVersionRetention(count int) {
+	atomic.StoreInt32(&e.versionRetention, int32(count))
+}
+
+// VersionRetention returns the number of versions kept per flow
+func (e *Engine) VersionRetention() int {
+	return int(atomic.LoadInt32(&e.versionRetention))
+}
+
+// saveVersion keeps a saved flow definition as the newest version of the
+// flow. Failing to is not fatal to the deploy that saved it.
+func (e *Engine) saveVersion(id string, flowDef []byte) {
+	keep := e.VersionRetention()
+	if keep <= 0 {
+		return
+	}
+	if err := e.versions.SaveFlowVersion(id, flowDef, keep); err != nil {
+		e.Logger().Warn("failed to save version of flow", "flow_id", id, "error", err)
+	}
+}
+
+// savedAt returns when a flow was last saved, from its newest version, or
+// the zero time if it has none
+func (e *Engine) savedAt(id string) time.Time {
+	versions, err := e.versions.ListFlowVersions(id)
+	if err != nil || len(versions) == 0 {
+		return time.Time{}
+	}
+	return versions[0].Timestamp
+}
+
+// FlowVersions lists the saved versions of a flow, newest first. They are
+// kept after the flow is deleted.
+func (e *Engine) FlowVersions(id string) ([]storage.FlowVersion, error) {
+	return e.versions.ListFlowVersions(id)
+}
+
+// RestoreFlowVersion deploys a saved version of a flow, which becomes its
+// newest version. Versions do not hold credentials, so the flow keeps its
+// current ones.
+func (e *Engine) RestoreFlowVersion(id, rev string) error {
+	flowDef, err := e.versions.LoadFlowVersion(id, rev)
+	if err != nil {
+		return err
+	}
+	return e.DeployFlow(id, flowDef)
+}
@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// RegisterCoreNodeTypes registers the editor node types every imported flow
+// may contain: "comment", which is virtual, "junction", a passthrough used
+// to tidy up wiring, and "complete", which sends the messages other nodes
+// report done
+func RegisterCoreNodeTypes(r NodeTypeRegistry) error {
+	if err := r.RegisterNodeType(&NodeType{
+		Name:        "comment",
+		Description: "Adds a note to the flow",
+		Category:    "common",
+		Defaults:    json.RawMessage(`{}`),
+		Virtual:     true,
+	}); err != nil {
+		return err
+	}
+
+	if err := r.RegisterNodeType(&NodeType{
+		Name:        "junction",
+		Description: "Joins wires",
+		Category:    "common",
+		Defaults:    json.RawMessage(`{}`),
+		Factory: func() NodeInstance {
+			return &JunctionNode{}
+		},
+	}); err != nil {
+		return err
+	}
+
+	return r.RegisterNodeType(&NodeType{
+		Name:        "complete",
+		Description: "Sends the messages that other nodes finished handling",
+		Category:    "common",
+		Defaults:    json.RawMessage(`{"scope":[]}`),
+		Factory: func() NodeInstance {
+			return &CompleteNode{}
+		},
+	})
+}
+
+// JunctionNode forwards every message to all of its wires
+type JunctionNode struct {
+	BaseNode
+}
+
+// Init ignores the configuration; junctions have none
+func (n *JunctionNode) Init(config json.RawMessage) error {
+	return nil
+}
+
+// Start starts the node
+func (n *JunctionNode) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop stops the node
+func (n *JunctionNode) Stop() {}
+
+// OnMessage forwards the message
+func (n *JunctionNode) OnMessage(msg *Message, port int) error {
+	return n.GetNode().Send(msg, 0)
+}
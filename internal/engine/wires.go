@@ -0,0 +1,144 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// EventWireUpdate is published when a wire is enabled or disabled at
+// runtime
+const EventWireUpdate = "wire-update"
+
+// ErrWireNotFound is returned when changing a wire a flow does not have
+var ErrWireNotFound = errors.New("wire not found")
+
+// WireState is a wire of a flow with the number of messages it skipped
+// while disabled
+type WireState struct {
+	WireDefinition
+	Skipped int64 `json:"skipped"`
+}
+
+// wireEnd identifies a wire of a source node
+type wireEnd struct {
+	port   int
+	target string
+}
+
+// wireState is the runtime state of a disabled, or once disabled, wire;
+// skipped is accessed atomically
+type wireState struct {
+	disabled bool
+	skipped  int64
+}
+
+// targets returns the instances wired to port, leaving out disabled wires,
+// which count the messages they skip; n.mu must be held
+func (n *Node) targets(port int) []NodeInstance {
+	if port >= len(n.wires) {
+		return nil
+	}
+	if len(n.wireStates) == 0 {
+		return n.wires[port]
+	}
+
+	targets := make([]NodeInstance, 0, len(n.wires[port]))
+	for _, target := range n.wires[port] {
+		if state := n.wireStates[wireEnd{port, target.GetNode().ID}]; state != nil && state.disabled {
+			atomic.AddInt64(&state.skipped, 1)
+			continue
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// setWireDisabled disables or enables the node's wires to target on port
+func (n *Node) setWireDisabled(port int, target string, disabled bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.wireStates == nil {
+		n.wireStates = make(map[wireEnd]*wireState)
+	}
+	end := wireEnd{port, target}
+	if n.wireStates[end] == nil {
+		n.wireStates[end] = &wireState{}
+	}
+	n.wireStates[end].disabled = disabled
+}
+
+// skippedMessages returns how many messages the node's wire to target on
+// port skipped while disabled
+func (n *Node) skippedMessages(port int, target string) int64 {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if state := n.wireStates[wireEnd{port, target}]; state != nil {
+		return atomic.LoadInt64(&state.skipped)
+	}
+	return 0
+}
+
+// WireStates returns the flow's wires with their skipped message counts
+func (f *Flow) WireStates() []WireState {
+	f.mu.RLock()
+	wires := append([]WireDefinition(nil), f.wires...)
+	f.mu.RUnlock()
+
+	states := make([]WireState, len(wires))
+	for i, wire := range wires {
+		states[i].WireDefinition = wire
+		if source, exists := f.GetNode(wire.Source); exists {
+			states[i].Skipped = source.skippedMessages(wire.Port, wire.Target)
+		}
+	}
+	return states
+}
+
+// SetWireDisabled disables or enables the wires from source to target on
+// port without redeploying the flow, and saves the flow. Disabled wires
+// keep their place in the flow but carry no messages.
+func (e *Engine) SetWireDisabled(flowID, source, target string, port int, disabled bool) error {
+	flow, exists := e.GetFlow(flowID)
+	if !exists {
+		return fmt.Errorf("flow %s not found", flowID)
+	}
+	if flow.Remote() {
+		return errRemoteFlow(flowID)
+	}
+
+	flow.mu.Lock()
+	found := false
+	for i, wire := range flow.wires {
+		if wire.Source == source && wire.Target == target && wire.Port == port {
+			flow.wires[i].Disabled = disabled
+			found = true
+		}
+	}
+	flow.mu.Unlock()
+	if !found {
+		return fmt.Errorf("%w: %s port %d to %s", ErrWireNotFound, source, port, target)
+	}
+	if node, exists := flow.GetNode(source); exists {
+		node.setWireDisabled(port, target, disabled)
+	}
+
+	if err := e.saveDefinition(flowID, flow); err != nil {
+		return err
+	}
+
+	flow.publish(Event{
+		Type:      EventWireUpdate,
+		Workspace: flow.Workspace(),
+		FlowID:    flow.ID,
+		NodeID:    source,
+		Data: map[string]interface{}{
+			"target":   target,
+			"port":     port,
+			"disabled": disabled,
+		},
+	})
+	return nil
+}
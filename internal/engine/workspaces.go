@@ -0,0 +1,219 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// DefaultWorkspace holds the flows created without naming a workspace
+const DefaultWorkspace = "main"
+
+// workspaceHTTPPrefix prefixes the HTTP routes of flows outside the default
+// workspace
+const workspaceHTTPPrefix = "/workspaces/"
+
+// ErrWorkspaceNotEmpty is returned when deleting a workspace that has flows
+// without forcing it
+var ErrWorkspaceNotEmpty = errors.New("workspace has flows")
+
+var workspaceNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// ValidateWorkspaceName checks that a workspace name is lowercase letters,
+// digits, '-' and '_', so that it can be used in paths and storage keys
+func ValidateWorkspaceName(name string) error {
+	if !workspaceNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid workspace name %q", name)
+	}
+	return nil
+}
+
+// FlowKey returns the ID the engine and storage know a flow by. Flows of
+// the default workspace keep their ID, others are "workspace/id".
+func FlowKey(workspace, id string) string {
+	if workspace == "" || workspace == DefaultWorkspace {
+		return id
+	}
+	return workspace + "/" + id
+}
+
+// SplitFlowKey returns the workspace and ID of a flow key
+func SplitFlowKey(key string) (workspace, id string) {
+	if workspace, id, ok := strings.Cut(key, "/"); ok {
+		return workspace, id
+	}
+	return DefaultWorkspace, key
+}
+
+// Workspace returns the workspace the flow belongs to
+func (f *Flow) Workspace() string {
+	if f.workspace == "" {
+		return DefaultWorkspace
+	}
+	return f.workspace
+}
+
+// HTTPPath returns the path under which a node of the flow serves path.
+// Routes of flows outside the default workspace are prefixed with
+// /workspaces/{workspace} so that workspaces cannot take each other's paths.
+func (f *Flow) HTTPPath(path string) string {
+	if f.Workspace() == DefaultWorkspace {
+		return path
+	}
+	return workspaceHTTPPrefix + f.workspace + "/" + strings.TrimPrefix(path, "/")
+}
+
+// ListWorkspaces returns the workspaces sorted by name, including the
+// default one and any that only exist through their stored flows
+func (e *Engine) ListWorkspaces() ([]storage.Workspace, error) {
+	stored, err := e.workspaces.ListWorkspaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspaces: %w", err)
+	}
+
+	workspaces := map[string]storage.Workspace{
+		DefaultWorkspace: {Name: DefaultWorkspace},
+	}
+	for _, workspace := range stored {
+		workspaces[workspace.Name] = workspace
+	}
+	for _, key := range e.ListFlows() {
+		if name, _ := SplitFlowKey(key); workspaces[name].Name == "" {
+			workspaces[name] = storage.Workspace{Name: name}
+		}
+	}
+
+	list := make([]storage.Workspace, 0, len(workspaces))
+	for _, workspace := range workspaces {
+		list = append(list, workspace)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// HasWorkspace reports whether a workspace exists
+func (e *Engine) HasWorkspace(name string) (bool, error) {
+	workspaces, err := e.ListWorkspaces()
+	if err != nil {
+		return false, err
+	}
+	for _, workspace := range workspaces {
+		if workspace.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CreateWorkspace adds an empty workspace
+func (e *Engine) CreateWorkspace(name string) (storage.Workspace, error) {
+	if err := ValidateWorkspaceName(name); err != nil {
+		return storage.Workspace{}, err
+	}
+	if exists, err := e.HasWorkspace(name); err != nil || exists {
+		if err == nil {
+			err = storage.ErrWorkspaceExists
+		}
+		return storage.Workspace{}, err
+	}
+	return e.workspaces.CreateWorkspace(name)
+}
+
+// WorkspaceFlows returns the IDs of the flows in a workspace
+func (e *Engine) WorkspaceFlows(name string) []string {
+	var flows []string
+	for _, key := range e.ListFlows() {
+		if workspace, id := SplitFlowKey(key); workspace == name {
+			flows = append(flows, id)
+		}
+	}
+	sort.Strings(flows)
+	return flows
+}
+
+// DeleteWorkspace removes a workspace. A workspace with flows is only
+// removed with force, which deletes its flows too. The default workspace
+// cannot be deleted.
+func (e *Engine) DeleteWorkspace(name string, force bool) error {
+	if name == DefaultWorkspace {
+		return fmt.Errorf("the %s workspace cannot be deleted", DefaultWorkspace)
+	}
+	exists, err := e.HasWorkspace(name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return storage.ErrWorkspaceNotFound
+	}
+
+	flows := e.WorkspaceFlows(name)
+	if len(flows) > 0 && !force {
+		return fmt.Errorf("%w: %s", ErrWorkspaceNotEmpty, strings.Join(flows, ", "))
+	}
+	for _, id := range flows {
+		key := FlowKey(name, id)
+		if err := e.DeleteFlow(key); err != nil {
+			return fmt.Errorf("failed to delete flow %s: %w", id, err)
+		}
+		if err := e.locks.Release(key, "", true); err != nil {
+			return fmt.Errorf("failed to release lock of flow %s: %w", id, err)
+		}
+	}
+
+	if err := e.workspaces.DeleteWorkspace(name); err != nil && !errors.Is(err, storage.ErrWorkspaceNotFound) {
+		return fmt.Errorf("failed to delete workspace: %w", err)
+	}
+	e.contexts.deleteWorkspace(name)
+	return nil
+}
+
+// memoryWorkspaceStore keeps the workspaces when the storage is not a
+// storage.WorkspaceStore
+type memoryWorkspaceStore struct {
+	workspaces map[string]storage.Workspace
+	mu         sync.Mutex
+}
+
+func newWorkspaceStore(store storage.Storage) storage.WorkspaceStore {
+	if workspaceStore, ok := store.(storage.WorkspaceStore); ok {
+		return workspaceStore
+	}
+	return &memoryWorkspaceStore{workspaces: make(map[string]storage.Workspace)}
+}
+
+func (s *memoryWorkspaceStore) ListWorkspaces() ([]storage.Workspace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	workspaces := make([]storage.Workspace, 0, len(s.workspaces))
+	for _, workspace := range s.workspaces {
+		workspaces = append(workspaces, workspace)
+	}
+	return workspaces, nil
+}
+
+func (s *memoryWorkspaceStore) CreateWorkspace(name string) (storage.Workspace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.workspaces[name]; exists {
+		return storage.Workspace{}, storage.ErrWorkspaceExists
+	}
+	workspace := storage.Workspace{Name: name, Created: time.Now()}
+	s.workspaces[name] = workspace
+	return workspace, nil
+}
+
+func (s *memoryWorkspaceStore) DeleteWorkspace(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.workspaces[name]; !exists {
+		return storage.ErrWorkspaceNotFound
+	}
+	delete(s.workspaces, name)
+	return nil
+}
@@ -0,0 +1,156 @@
+// Package examples holds the example flows bundled with go-red
+package examples
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+//go:embed flows/*.json
+var flows embed.FS
+
+// ErrNotFound is returned for unknown examples
+var ErrNotFound = errors.New("example not found")
+
+// NodeTypes looks up registered node types, like registry.Registry
+type NodeTypes interface {
+	GetNodeType(name string) (*engine.NodeType, error)
+}
+
+// MissingTypesError is returned when installing an example that uses node
+// types which are not registered
+type MissingTypesError struct {
+	Example string
+	Types   []string
+}
+
+func (e *MissingTypesError) Error() string {
+	return fmt.Sprintf("example %s needs node types that are not installed: %s", e.Example, strings.Join(e.Types, ", "))
+}
+
+// Example is a bundled example flow
+type Example struct {
+	// Name identifies the example; it is the file name without .json
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// NodeTypes are the node types the example needs
+	NodeTypes []string `json:"nodeTypes"`
+
+	flow engine.FlowDefinition
+}
+
+// List returns the bundled examples sorted by name
+func List() ([]*Example, error) {
+	files, err := flows.ReadDir("flows")
+	if err != nil {
+		return nil, err
+	}
+	examples := make([]*Example, 0, len(files))
+	for _, file := range files {
+		example, err := Get(strings.TrimSuffix(file.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		examples = append(examples, example)
+	}
+	return examples, nil
+}
+
+// Get returns the bundled example with the name
+func Get(name string) (*Example, error) {
+	data, err := flows.ReadFile(path.Join("flows", name+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+
+	example := &Example{Name: name}
+	if err := json.Unmarshal(data, &example.flow); err != nil {
+		return nil, fmt.Errorf("invalid example %s: %w", name, err)
+	}
+	example.Title = example.flow.Name
+	example.Description = example.flow.Description
+
+	seen := make(map[string]bool)
+	for _, node := range example.flow.Nodes {
+		if !seen[node.Type] {
+			seen[node.Type] = true
+			example.NodeTypes = append(example.NodeTypes, node.Type)
+		}
+	}
+	sort.Strings(example.NodeTypes)
+	return example, nil
+}
+
+// MissingTypes returns the node types the example needs that are not
+// registered
+func (e *Example) MissingTypes(types NodeTypes) []string {
+	var missing []string
+	for _, nodeType := range e.NodeTypes {
+		if _, err := types.GetNodeType(nodeType); err != nil {
+			missing = append(missing, nodeType)
+		}
+	}
+	return missing
+}
+
+// Instantiate returns a disabled copy of the example's flow with new flow
+// and node IDs, ready to be deployed, so that an example can be installed
+// several times. A *MissingTypesError is returned if the example needs node
+// types that are not registered.
+func (e *Example) Instantiate(types NodeTypes) (string, []byte, error) {
+	if missing := e.MissingTypes(types); len(missing) > 0 {
+		return "", nil, &MissingTypesError{Example: e.Name, Types: missing}
+	}
+
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 36)
+	flow := e.flow
+	flow.ID = e.Name + "-" + suffix
+	flow.Disabled = true
+
+	ids := make(map[string]string, len(flow.Nodes))
+	flow.Nodes = make([]engine.NodeDefinition, len(e.flow.Nodes))
+	for i, node := range e.flow.Nodes {
+		ids[node.ID] = node.ID + "-" + suffix
+		node.ID = ids[node.ID]
+		flow.Nodes[i] = node
+	}
+	for i, node := range flow.Nodes {
+		if len(node.StartAfter) == 0 {
+			continue
+		}
+		flow.Nodes[i].StartAfter = make([]string, len(node.StartAfter))
+		for j, nodeID := range node.StartAfter {
+			flow.Nodes[i].StartAfter[j] = ids[nodeID]
+		}
+	}
+	flow.Wires = make([]engine.WireDefinition, len(e.flow.Wires))
+	for i, wire := range e.flow.Wires {
+		wire.Source, wire.Target = ids[wire.Source], ids[wire.Target]
+		flow.Wires[i] = wire
+	}
+	flow.Groups = make([]engine.Group, len(e.flow.Groups))
+	for i, group := range e.flow.Groups {
+		group.ID += "-" + suffix
+		group.Nodes = make([]string, len(e.flow.Groups[i].Nodes))
+		for j, nodeID := range e.flow.Groups[i].Nodes {
+			group.Nodes[j] = ids[nodeID]
+		}
+		flow.Groups[i] = group
+	}
+
+	def, err := json.Marshal(flow)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal example %s: %w", e.Name, err)
+	}
+	return flow.ID, def, nil
+}
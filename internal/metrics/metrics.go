@@ -0,0 +1,315 @@
+// Package metrics keeps counters, gauges and histograms and writes them in
+// the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the buckets of
+// latency histograms
+var DefaultBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// ContentType is the content type of the text format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metric is a family of series that can write itself
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+// Registry holds metrics in the order they were created
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// register adds a metric; names must be unique
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteText writes all metrics in the Prometheus text format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP serves the metrics to a scraper
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	r.WriteText(w)
+}
+
+// desc is the name, help and label names of a metric
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+// writeHeader writes the HELP and TYPE lines of a metric
+func (d *desc) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.kind)
+}
+
+// writeSample writes one sample of a series
+func (d *desc) writeSample(w *bufio.Writer, name string, values []string, extra string, value float64) {
+	w.WriteString(name)
+	if len(values) > 0 || extra != "" {
+		w.WriteByte('{')
+		for i, label := range d.labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, label, labelEscaper.Replace(values[i]))
+		}
+		if extra != "" {
+			if len(values) > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(extra)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatValue(value))
+	w.WriteByte('\n')
+}
+
+// labelEscaper escapes label values for the text format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatValue formats a sample value
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// seriesKey joins label values into a map key
+func seriesKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// checkLabels panics unless a metric got as many label values as it has
+// labels
+func (d *desc) checkLabels(values []string) {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+}
+
+// float is a float64 updated atomically
+type float struct {
+	bits atomic.Uint64
+}
+
+func (f *float) add(delta float64) {
+	for {
+		old := f.bits.Load()
+		if f.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func (f *float) load() float64 {
+	return math.Float64frombits(f.bits.Load())
+}
+
+// Counter is a family of counters, one per combination of label values
+type Counter struct {
+	desc
+	mu     sync.RWMutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	values []string
+	value  float
+}
+
+// NewCounter registers a counter with the given label names
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{
+		desc:   desc{name: name, help: help, kind: "counter", labels: labels},
+		series: make(map[string]*counterSeries),
+	}
+	r.register(name, c)
+	return c
+}
+
+// Inc adds one to the counter of the label values
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds delta, which must not be negative, to the counter of the label
+// values
+func (c *Counter) Add(delta float64, values ...string) {
+	c.checkLabels(values)
+	key := seriesKey(values)
+	c.mu.RLock()
+	s := c.series[key]
+	c.mu.RUnlock()
+	if s == nil {
+		c.mu.Lock()
+		if s = c.series[key]; s == nil {
+			s = &counterSeries{values: append([]string(nil), values...)}
+			c.series[key] = s
+		}
+		c.mu.Unlock()
+	}
+	s.value.add(delta)
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.writeHeader(w)
+	c.mu.RLock()
+	keys := make([]string, 0, len(c.series))
+	for key := range c.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := c.series[key]
+		c.writeSample(w, c.name, s.values, "", s.value.load())
+	}
+	c.mu.RUnlock()
+}
+
+// Histogram is a family of histograms, one per combination of label values
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.RWMutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	counts []atomic.Uint64
+	count  atomic.Uint64
+	sum    float
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds, in
+// increasing order, and label names
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		desc:    desc{name: name, help: help, kind: "histogram", labels: labels},
+		buckets: buckets,
+		series:  make(map[string]*histogramSeries),
+	}
+	r.register(name, h)
+	return h
+}
+
+// Observe records a value in the histogram of the label values
+func (h *Histogram) Observe(value float64, values ...string) {
+	h.checkLabels(values)
+	key := seriesKey(values)
+	h.mu.RLock()
+	s := h.series[key]
+	h.mu.RUnlock()
+	if s == nil {
+		h.mu.Lock()
+		if s = h.series[key]; s == nil {
+			s = &histogramSeries{values: append([]string(nil), values...), counts: make([]atomic.Uint64, len(h.buckets))}
+			h.series[key] = s
+		}
+		h.mu.Unlock()
+	}
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		s.counts[i].Add(1)
+	}
+	s.sum.add(value)
+	s.count.Add(1)
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.writeHeader(w)
+	h.mu.RLock()
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := h.series[key]
+		// Buckets are cumulative
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i].Load()
+			h.writeSample(w, h.name+"_bucket", s.values, `le="`+formatValue(bound)+`"`, float64(cumulative))
+		}
+		count := s.count.Load()
+		h.writeSample(w, h.name+"_bucket", s.values, `le="+Inf"`, float64(count))
+		h.writeSample(w, h.name+"_sum", s.values, "", s.sum.load())
+		h.writeSample(w, h.name+"_count", s.values, "", float64(count))
+	}
+	h.mu.RUnlock()
+}
+
+// Gauge is a family of gauges whose values are read from a function when
+// the metrics are written, such as the number of open connections
+type Gauge struct {
+	desc
+	collect func(set func(value float64, values ...string))
+}
+
+// NewGauge registers a gauge with the given label names. collect is called
+// on every write and calls set for each series.
+func (r *Registry) NewGauge(name, help string, collect func(set func(value float64, values ...string)), labels ...string) *Gauge {
+	g := &Gauge{
+		desc:    desc{name: name, help: help, kind: "gauge", labels: labels},
+		collect: collect,
+	}
+	r.register(name, g)
+	return g
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.writeHeader(w)
+	g.collect(func(value float64, values ...string) {
+		g.checkLabels(values)
+		g.writeSample(w, g.name, values, "", value)
+	})
+}
@@ -0,0 +1,561 @@
+// Package nodered translates between the flow export format of Node-RED, a
+// flat array of tabs, nodes, config nodes, groups and subflows that refer
+// to their tab with "z", and go-red flow definitions. Node configs are
+// taken as they are; only the type names of core nodes are mapped.
+package nodered
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// coreTypes maps the Node-RED names of core node types to go-red's
+var coreTypes = map[string]string{
+	"mqtt in":       "mqtt-in",
+	"mqtt out":      "mqtt-out",
+	"http in":       "http-in",
+	"http response": "http-response",
+	"http request":  "http-request",
+	"tcp in":        "tcp-in",
+	"tcp out":       "tcp-out",
+	"udp in":        "udp-in",
+	"udp out":       "udp-out",
+	"websocket in":  "websocket-in",
+	"websocket out": "websocket-out",
+	"link in":       "link-in",
+	"link out":      "link-out",
+	"file in":       "file-in",
+}
+
+// reserved are the properties of Node-RED nodes that are not their config
+var reserved = map[string]bool{
+	"id": true, "type": true, "z": true, "name": true, "x": true, "y": true,
+	"wires": true, "g": true, "d": true,
+}
+
+// object is a decoded object of a Node-RED export
+type object map[string]interface{}
+
+func (o object) str(key string) string {
+	s, _ := o[key].(string)
+	return s
+}
+
+func (o object) float(key string) float64 {
+	f, _ := engine.ToFloat(o[key])
+	return f
+}
+
+func (o object) bool(key string) bool {
+	b, _ := o[key].(bool)
+	return b
+}
+
+func (o object) list(key string) []interface{} {
+	l, _ := o[key].([]interface{})
+	return l
+}
+
+// IsNodeRED reports whether an array of flows is in the Node-RED format:
+// its objects have a type rather than nodes
+func IsNodeRED(data []byte) bool {
+	var objects []object
+	if engine.DecodeJSON(data, &objects) != nil || len(objects) == 0 {
+		return false
+	}
+	for _, o := range objects {
+		if _, nodes := o["nodes"].([]interface{}); nodes && o["type"] == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// ToFlows translates a Node-RED export into a flow per tab. Config nodes
+// outside tabs are copied into the flows whose nodes refer to them. Every
+// subflow is defined by the first flow that uses it, so that it is
+// registered once. It also returns warnings about what was left out.
+func ToFlows(data []byte) ([]engine.FlowDefinition, []string, error) {
+	var objects []object
+	if err := engine.DecodeJSON(data, &objects); err != nil {
+		return nil, nil, fmt.Errorf("invalid Node-RED export: %w", err)
+	}
+
+	var tabs, subflows, global []object
+	members := make(map[string][]object)
+	scopes := make(map[string]bool)
+	for _, o := range objects {
+		switch o.str("type") {
+		case "tab":
+			tabs = append(tabs, o)
+			scopes[o.str("id")] = true
+		case "subflow":
+			subflows = append(subflows, o)
+			scopes[o.str("id")] = true
+		}
+	}
+	var warnings []string
+	for _, o := range objects {
+		switch z := o.str("z"); {
+		case o.str("type") == "tab" || o.str("type") == "subflow":
+		case z == "":
+			global = append(global, o)
+		case scopes[z]:
+			members[z] = append(members[z], o)
+		default:
+			warnings = append(warnings, fmt.Sprintf("node %s is on unknown tab %s and was left out", o.str("id"), z))
+		}
+	}
+
+	defs := make(map[string]engine.SubflowDefinition, len(subflows))
+	for _, s := range subflows {
+		defs[s.str("id")] = toSubflow(s, members[s.str("id")])
+	}
+
+	flows := make([]engine.FlowDefinition, 0, len(tabs))
+	defined := make(map[string]bool)
+	for _, tab := range tabs {
+		id := tab.str("id")
+		flow := engine.FlowDefinition{
+			ID:          id,
+			Name:        tab.str("label"),
+			Description: tab.str("info"),
+			Disabled:    tab.bool("disabled"),
+		}
+		nodes := append(members[id], referencedConfigNodes(members[id], global)...)
+		flow.Nodes, flow.Wires, flow.Groups = toNodes(nodes)
+
+		for _, subflowID := range usedSubflows(flow.Nodes, defs) {
+			if !defined[subflowID] {
+				defined[subflowID] = true
+				flow.Subflows = append(flow.Subflows, defs[subflowID])
+			}
+		}
+		flows = append(flows, flow)
+	}
+	for _, s := range subflows {
+		if id := s.str("id"); !defined[id] {
+			if len(flows) == 0 {
+				warnings = append(warnings, fmt.Sprintf("subflow %s is not used by a tab and was left out", id))
+				continue
+			}
+			flows[0].Subflows = append(flows[0].Subflows, defs[id])
+		}
+	}
+	return flows, warnings, nil
+}
+
+// toNodes translates Node-RED nodes and groups into node definitions, the
+// wires between them and groups
+func toNodes(objects []object) ([]engine.NodeDefinition, []engine.WireDefinition, []engine.Group) {
+	nodes := make([]engine.NodeDefinition, 0, len(objects))
+	wires := make([]engine.WireDefinition, 0)
+	var groups []engine.Group
+	for _, o := range objects {
+		if o.str("type") == "group" {
+			group := engine.Group{ID: o.str("id"), Label: o.str("name"), Nodes: []string{}}
+			for _, member := range o.list("nodes") {
+				if id, ok := member.(string); ok {
+					group.Nodes = append(group.Nodes, id)
+				}
+			}
+			groups = append(groups, group)
+			continue
+		}
+
+		node := engine.NodeDefinition{
+			ID:       o.str("id"),
+			Type:     toType(o.str("type")),
+			Name:     o.str("name"),
+			Position: engine.Position{X: o.float("x"), Y: o.float("y")},
+			Disabled: o.bool("d"),
+			Config:   toConfig(o),
+		}
+		nodes = append(nodes, node)
+		for port, targets := range o.list("wires") {
+			list, _ := targets.([]interface{})
+			for _, target := range list {
+				if id, ok := target.(string); ok {
+					wires = append(wires, engine.WireDefinition{Source: node.ID, Target: id, Port: port})
+				}
+			}
+		}
+	}
+	return nodes, wires, groups
+}
+
+// toType returns the go-red type of a Node-RED node type
+func toType(typeName string) string {
+	if name, exists := coreTypes[typeName]; exists {
+		return name
+	}
+	return typeName
+}
+
+// toConfig returns the config of a Node-RED node: its properties other
+// than the reserved ones. The env list of subflow instances becomes a map.
+func toConfig(o object) json.RawMessage {
+	config := make(map[string]interface{})
+	for key, value := range o {
+		if !reserved[key] {
+			config[key] = value
+		}
+	}
+	if strings.HasPrefix(o.str("type"), engine.SubflowTypePrefix) {
+		config["env"] = toEnv(o.list("env"))
+	}
+	data, _ := json.Marshal(config)
+	return data
+}
+
+// toEnv turns the env list of Node-RED subflows and their instances into
+// values by name
+func toEnv(list []interface{}) map[string]interface{} {
+	env := make(map[string]interface{}, len(list))
+	for _, item := range list {
+		entry, _ := item.(map[string]interface{})
+		o := object(entry)
+		name := o.str("name")
+		if name == "" {
+			continue
+		}
+		value := o["value"]
+		s, isString := value.(string)
+		switch o.str("type") {
+		case "num":
+			if f, ok := engine.ToFloat(value); ok {
+				value = f
+			}
+		case "bool":
+			if isString {
+				value = s == "true"
+			}
+		case "json":
+			var decoded interface{}
+			if isString && engine.DecodeJSON([]byte(s), &decoded) == nil {
+				value = decoded
+			}
+		}
+		env[name] = value
+	}
+	return env
+}
+
+// toSubflow translates a Node-RED subflow and its nodes
+func toSubflow(s object, members []object) engine.SubflowDefinition {
+	def := engine.SubflowDefinition{
+		ID:          s.str("id"),
+		Name:        s.str("name"),
+		Description: s.str("info"),
+		Category:    s.str("category"),
+		Env:         toEnv(s.list("env")),
+	}
+	def.Nodes, def.Wires, _ = toNodes(members)
+	for _, item := range s.list("in") {
+		input, _ := item.(map[string]interface{})
+		for _, wire := range object(input).list("wires") {
+			target, _ := wire.(map[string]interface{})
+			def.In = append(def.In, object(target).str("id"))
+		}
+	}
+	for _, item := range s.list("out") {
+		output, _ := item.(map[string]interface{})
+		ports := []engine.SubflowPort{}
+		for _, wire := range object(output).list("wires") {
+			source, _ := wire.(map[string]interface{})
+			port, _ := engine.ToInt(source["port"])
+			ports = append(ports, engine.SubflowPort{Node: object(source).str("id"), Port: int(port)})
+		}
+		def.Out = append(def.Out, ports)
+	}
+	return def
+}
+
+// referencedConfigNodes returns the global config nodes that nodes refer
+// to, directly or through other config nodes, by ID in a property
+func referencedConfigNodes(nodes []object, global []object) []object {
+	byID := make(map[string]object, len(global))
+	for _, o := range global {
+		byID[o.str("id")] = o
+	}
+	var referenced []object
+	added := make(map[string]bool)
+	queue := append([]object{}, nodes...)
+	for len(queue) > 0 {
+		o := queue[0]
+		queue = queue[1:]
+		for key, value := range o {
+			id, ok := value.(string)
+			if !ok || reserved[key] || added[id] {
+				continue
+			}
+			if config, exists := byID[id]; exists {
+				added[id] = true
+				referenced = append(referenced, config)
+				queue = append(queue, config)
+			}
+		}
+	}
+	sort.Slice(referenced, func(i, j int) bool { return referenced[i].str("id") < referenced[j].str("id") })
+	return referenced
+}
+
+// usedSubflows returns the IDs of the subflows nodes are instances of,
+// directly or inside other subflows
+func usedSubflows(nodes []engine.NodeDefinition, defs map[string]engine.SubflowDefinition) []string {
+	var used []string
+	seen := make(map[string]bool)
+	var visit func(nodes []engine.NodeDefinition)
+	visit = func(nodes []engine.NodeDefinition) {
+		for _, node := range nodes {
+			id := strings.TrimPrefix(node.Type, engine.SubflowTypePrefix)
+			def, exists := defs[id]
+			if !exists || seen[id] || id == node.Type {
+				continue
+			}
+			seen[id] = true
+			used = append(used, id)
+			visit(def.Nodes)
+		}
+	}
+	visit(nodes)
+	return used
+}
+
+// FromFlows translates flows into a Node-RED export: a tab per flow with
+// its nodes, groups and subflows. Config nodes that several flows share
+// are exported once, outside the tabs; other nodes whose IDs are taken by
+// another flow get the flow ID as prefix. Wire labels and disabled wires,
+// and settings Node-RED does not have, such as schedules, are left out.
+func FromFlows(flows []engine.FlowDefinition) ([]byte, error) {
+	var objects []object
+
+	// Nodes without wires and with the same definition in several flows
+	// are shared config nodes
+	type seenNode struct {
+		def    engine.NodeDefinition
+		flows  int
+		shared bool
+	}
+	seen := make(map[string]*seenNode)
+	for _, flow := range flows {
+		wired := make(map[string]bool)
+		for _, wire := range flow.Wires {
+			wired[wire.Source], wired[wire.Target] = true, true
+		}
+		for _, node := range flow.Nodes {
+			s, exists := seen[node.ID]
+			switch {
+			case !exists:
+				seen[node.ID] = &seenNode{def: node, flows: 1, shared: !wired[node.ID]}
+			case s.shared && !wired[node.ID] && sameNode(s.def, node):
+				s.flows++
+			default:
+				s.shared = false
+			}
+		}
+	}
+	global := make(map[string]bool)
+	for id, s := range seen {
+		if s.shared && s.flows > 1 {
+			global[id] = true
+			objects = append(objects, fromNode(s.def, "", nil))
+		}
+	}
+
+	used := make(map[string]bool)
+	for _, flow := range flows {
+		objects = append(objects, object{
+			"id":       flow.ID,
+			"type":     "tab",
+			"label":    flow.Name,
+			"disabled": flow.Disabled,
+			"info":     flow.Description,
+		})
+
+		// Rename nodes whose IDs another flow took
+		rename := make(map[string]string)
+		for _, node := range flow.Nodes {
+			switch {
+			case global[node.ID]:
+			case used[node.ID]:
+				rename[node.ID] = flow.ID + "." + node.ID
+			default:
+				used[node.ID] = true
+			}
+		}
+		renamed := func(id string) string {
+			if name, exists := rename[id]; exists {
+				return name
+			}
+			return id
+		}
+
+		wires := nodeWires(flow.Wires, renamed)
+		groupOf := make(map[string]string)
+		for _, group := range flow.Groups {
+			members := make([]string, len(group.Nodes))
+			for i, id := range group.Nodes {
+				groupOf[id] = group.ID
+				members[i] = renamed(id)
+			}
+			objects = append(objects, object{
+				"id":    group.ID,
+				"type":  "group",
+				"z":     flow.ID,
+				"name":  group.Label,
+				"nodes": members,
+			})
+		}
+		for _, node := range flow.Nodes {
+			if global[node.ID] {
+				continue
+			}
+			o := fromNode(node, flow.ID, wires[node.ID])
+			o["id"] = renamed(node.ID)
+			if group := groupOf[node.ID]; group != "" {
+				o["g"] = group
+			}
+			objects = append(objects, o)
+		}
+
+		for _, subflow := range flow.Subflows {
+			objects = append(objects, fromSubflow(subflow)...)
+		}
+	}
+	return json.Marshal(objects)
+}
+
+// fromNode translates a node definition into a Node-RED node on tab z
+func fromNode(node engine.NodeDefinition, z string, wires [][]string) object {
+	o := object{}
+	var config map[string]interface{}
+	if engine.DecodeJSON(node.Config, &config) == nil {
+		for key, value := range config {
+			if !reserved[key] {
+				o[key] = value
+			}
+		}
+	}
+	if strings.HasPrefix(node.Type, engine.SubflowTypePrefix) {
+		env, _ := config["env"].(map[string]interface{})
+		o["env"] = fromEnv(env)
+	}
+
+	o["id"] = node.ID
+	o["type"] = fromType(node.Type)
+	o["name"] = node.Name
+	if z != "" {
+		o["z"] = z
+		o["x"], o["y"] = node.Position.X, node.Position.Y
+		if wires == nil {
+			wires = [][]string{}
+		}
+		o["wires"] = wires
+	}
+	if node.Disabled {
+		o["d"] = true
+	}
+	return o
+}
+
+// fromType returns the Node-RED type of a go-red node type
+func fromType(typeName string) string {
+	for name, goRed := range coreTypes {
+		if goRed == typeName {
+			return name
+		}
+	}
+	return typeName
+}
+
+// fromEnv turns environment values into a Node-RED env list, sorted by name
+func fromEnv(env map[string]interface{}) []interface{} {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		value := env[name]
+		entry := object{"name": name, "type": "str", "value": value}
+		switch value.(type) {
+		case string:
+		case bool:
+			entry["type"], entry["value"] = "bool", fmt.Sprint(value)
+		case json.Number, float64, int64, int:
+			entry["type"], entry["value"] = "num", fmt.Sprint(value)
+		default:
+			data, _ := json.Marshal(value)
+			entry["type"], entry["value"] = "json", string(data)
+		}
+		list = append(list, entry)
+	}
+	return list
+}
+
+// fromSubflow translates a subflow into a Node-RED subflow and its nodes
+func fromSubflow(subflow engine.SubflowDefinition) []object {
+	in := []interface{}{}
+	if len(subflow.In) > 0 {
+		targets := make([]interface{}, len(subflow.In))
+		for i, id := range subflow.In {
+			targets[i] = object{"id": id}
+		}
+		in = append(in, object{"x": 0, "y": 0, "wires": targets})
+	}
+	out := make([]interface{}, len(subflow.Out))
+	for i, ports := range subflow.Out {
+		sources := make([]interface{}, len(ports))
+		for j, port := range ports {
+			sources[j] = object{"id": port.Node, "port": port.Port}
+		}
+		out[i] = object{"x": 0, "y": 0, "wires": sources}
+	}
+
+	objects := []object{{
+		"id":       subflow.ID,
+		"type":     "subflow",
+		"name":     subflow.Name,
+		"info":     subflow.Description,
+		"category": subflow.Category,
+		"in":       in,
+		"out":      out,
+		"env":      fromEnv(subflow.Env),
+	}}
+	wires := nodeWires(subflow.Wires, func(id string) string { return id })
+	for _, node := range subflow.Nodes {
+		objects = append(objects, fromNode(node, subflow.ID, wires[node.ID]))
+	}
+	return objects
+}
+
+// nodeWires returns the Node-RED wires of the source nodes of wires: the
+// targets, as named by target, by output port
+func nodeWires(wires []engine.WireDefinition, target func(id string) string) map[string][][]string {
+	bySource := make(map[string][][]string)
+	for _, wire := range wires {
+		ports := bySource[wire.Source]
+		for len(ports) <= wire.Port {
+			ports = append(ports, []string{})
+		}
+		ports[wire.Port] = append(ports[wire.Port], target(wire.Target))
+		bySource[wire.Source] = ports
+	}
+	return bySource
+}
+
+// sameNode reports whether two node definitions are the same but for their
+// position
+func sameNode(a, b engine.NodeDefinition) bool {
+	a.Position, b.Position = engine.Position{}, engine.Position{}
+	dataA, errA := json.Marshal(a)
+	dataB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(dataA) == string(dataB)
+}
@@ -1,7 +1,7 @@
 package registry
 
 import (
-	"log"
+	"log/slog"
 
 	"github.com/yourusername/go-red/internal/engine"
 	"github.com/yourusername/go-red/pkg/nodes/input"
@@ -11,17 +11,133 @@ import (
 
 // LoadBuiltinNodes loads all built-in node types
 func (r *Registry) LoadBuiltinNodes() error {
+	// Editor nodes
+	engine.RegisterCoreNodeTypes(r)
+	slog.Debug("registered comment, junction and complete nodes")
+	
 	// Input nodes
 	input.RegisterHTTPInputNode(r)
-	log.Println("Registered HTTP input node")
+	slog.Debug("registered HTTP input node")
+	
+	input.RegisterSQSInputNode(r)
+	slog.Debug("registered SQS input node")
+	
+	input.RegisterCronNode(r)
+	slog.Debug("registered Cron node")
+	
+	input.RegisterInjectNode(r)
+	slog.Debug("registered Inject node")
+	
+	input.RegisterMQTTInputNode(r)
+	slog.Debug("registered MQTT input node")
+	
+	input.RegisterDiscoveryNode(r)
+	slog.Debug("registered Discovery node")
+	
+	input.RegisterTCPInputNode(r)
+	slog.Debug("registered TCP input node")
+	
+	input.RegisterUDPInputNode(r)
+	slog.Debug("registered UDP input node")
+	
+	input.RegisterWebSocketInputNode(r)
+	slog.Debug("registered WebSocket input node")
+	
+	input.RegisterEmailInputNode(r)
+	slog.Debug("registered Email input node")
+	
+	input.RegisterRedisInputNode(r)
+	slog.Debug("registered Redis input node")
+	
+	input.RegisterAMQPInputNode(r)
+	slog.Debug("registered AMQP input node")
 	
 	// Process nodes
 	process.RegisterFunctionNode(r)
-	log.Println("Registered Function node")
+	slog.Debug("registered Function node")
+	
+	process.RegisterCounterNode(r)
+	slog.Debug("registered Counter node")
+	
+	process.RegisterSmoothNode(r)
+	slog.Debug("registered Smooth node")
+	
+	process.RegisterRandomNode(r)
+	slog.Debug("registered Random node")
+	
+	process.RegisterRangeNode(r)
+	slog.Debug("registered Range node")
+	
+	process.RegisterEnrichNode(r)
+	slog.Debug("registered Enrich node")
+	
+	process.RegisterAggregateNode(r)
+	slog.Debug("registered Aggregate node")
+	
+	process.RegisterWatchdogNode(r)
+	slog.Debug("registered Watchdog node")
+	
+	process.RegisterTriggerNode(r)
+	slog.Debug("registered Trigger node")
+	
+	process.RegisterFilterNode(r)
+	slog.Debug("registered Filter node")
+	
+	process.RegisterBatchNode(r)
+	slog.Debug("registered Batch node")
+	
+	process.RegisterSortNode(r)
+	slog.Debug("registered Sort node")
+	
+	process.RegisterExecNode(r)
+	slog.Debug("registered Exec node")
+	
+	process.RegisterCorrelateNode(r)
+	process.RegisterResolveNode(r)
+	slog.Debug("registered Correlate and Resolve nodes")
+	
+	process.RegisterCSVNode(r)
+	slog.Debug("registered CSV node")
+	
+	process.RegisterJSONNode(r)
+	slog.Debug("registered JSON node")
+	
+	process.RegisterYAMLNode(r)
+	slog.Debug("registered YAML node")
+	
+	process.RegisterSQLNode(r)
+	slog.Debug("registered SQL node")
 	
 	// Output nodes
 	output.RegisterDebugNode(r)
-	log.Println("Registered Debug node")
+	slog.Debug("registered Debug node")
+	
+	output.RegisterHTTPResponseNode(r)
+	slog.Debug("registered HTTP response node")
+	
+	output.RegisterLogFileNode(r)
+	slog.Debug("registered Log file node")
+	
+	output.RegisterS3Node(r)
+	slog.Debug("registered S3 node")
+	
+	output.RegisterMetricNode(r)
+	slog.Debug("registered Metric node")
+	
+	output.RegisterTCPOutputNode(r)
+	slog.Debug("registered TCP output node")
+	
+	output.RegisterUDPOutputNode(r)
+	slog.Debug("registered UDP output node")
+	
+	output.RegisterWebSocketOutputNode(r)
+	slog.Debug("registered WebSocket output node")
+	
+	output.RegisterRedisCommandNode(r)
+	slog.Debug("registered Redis node")
+	
+	output.RegisterAMQPOutputNode(r)
+	slog.Debug("registered AMQP output node")
 	
 	return nil
 }
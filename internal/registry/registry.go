@@ -1,8 +1,8 @@
 package registry
 
 import (
-	"errors"
 	"fmt"
+	"plugin"
 	"sync"
 
 	"github.com/yourusername/go-red/internal/engine"
@@ -11,13 +11,31 @@ import (
 // Registry manages all available node types
 type Registry struct {
 	nodeTypes map[string]*engine.NodeType
-	mu        sync.RWMutex
+	hooks     []HookRegistration
+	// subflows holds the subflows defined by flows by their node type
+	subflows map[string]registeredSubflow
+	mu       sync.RWMutex
+}
+
+// registeredSubflow is a subflow with the flow that defines it
+type registeredSubflow struct {
+	flowID string
+	def    *engine.SubflowDefinition
+}
+
+// HookRegistration is a message hook registered with the registry, which engines
+// created with the registry install
+type HookRegistration struct {
+	Stage engine.HookStage
+	Name  string
+	Fn    engine.Hook
 }
 
 // New creates a new Registry
 func New() *Registry {
 	return &Registry{
 		nodeTypes: make(map[string]*engine.NodeType),
+		subflows:  make(map[string]registeredSubflow),
 	}
 }
 
@@ -29,6 +47,9 @@ func (r *Registry) RegisterNodeType(nodeType *engine.NodeType) error {
 	if _, exists := r.nodeTypes[nodeType.Name]; exists {
 		return fmt.Errorf("node type %s is already registered", nodeType.Name)
 	}
+	if nodeType.Factory == nil && !nodeType.Virtual {
+		return fmt.Errorf("node type %s has no factory", nodeType.Name)
+	}
 
 	r.nodeTypes[nodeType.Name] = nodeType
 	return nil
@@ -73,17 +94,86 @@ func (r *Registry) GetAllNodeTypes() []*engine.NodeType {
 	return types
 }
 
-// LoadBuiltinNodes loads all built-in node types
-func (r *Registry) LoadBuiltinNodes() error {
-	// This will be implemented with actual node types
-	// For now, just a placeholder that succeeds
+// SetSubflows replaces the subflows defined by a flow; nil removes them.
+// Subflow IDs must be unique across flows.
+func (r *Registry) SetSubflows(flowID string, subflows []engine.SubflowDefinition) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, subflow := range subflows {
+		if existing, exists := r.subflows[subflow.TypeName()]; exists && existing.flowID != flowID {
+			return fmt.Errorf("subflow %s is already defined by flow %s", subflow.ID, existing.flowID)
+		}
+	}
+	for name, existing := range r.subflows {
+		if existing.flowID == flowID {
+			delete(r.subflows, name)
+		}
+	}
+	for i := range subflows {
+		subflow := subflows[i]
+		r.subflows[subflow.TypeName()] = registeredSubflow{flowID: flowID, def: &subflow}
+	}
 	return nil
 }
 
-// LoadNodePlugin loads a node plugin from a file
+// GetSubflow gets a subflow by the node type of its instances
+func (r *Registry) GetSubflow(name string) (*engine.SubflowDefinition, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	subflow, exists := r.subflows[name]
+	return subflow.def, exists
+}
+
+// GetAllSubflows returns all registered subflows
+func (r *Registry) GetAllSubflows() []*engine.SubflowDefinition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	subflows := make([]*engine.SubflowDefinition, 0, len(r.subflows))
+	for _, subflow := range r.subflows {
+		subflows = append(subflows, subflow.def)
+	}
+
+	return subflows
+}
+
+// RegisterHook registers a message hook for the engines created with the
+// registry afterwards
+func (r *Registry) RegisterHook(stage engine.HookStage, name string, fn engine.Hook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.hooks = append(r.hooks, HookRegistration{Stage: stage, Name: name, Fn: fn})
+}
+
+// GetHooks returns the registered hooks in registration order
+func (r *Registry) GetHooks() []HookRegistration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]HookRegistration(nil), r.hooks...)
+}
+
+// LoadNodePlugin loads a Go plugin, which must export a function
+// "Register(*gored.Registry) error" that registers its node types and
+// hooks. Plugins are only supported on some platforms.
 func (r *Registry) LoadNodePlugin(path string) error {
-	// This requires Go plugin support
-	// It's a bit complex and platform-dependent
-	// For now, we'll return an error
-	return errors.New("plugin loading not implemented yet")
+	p, err := plugin.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open plugin %s: %w", path, err)
+	}
+	symbol, err := p.Lookup("Register")
+	if err != nil {
+		return fmt.Errorf("plugin %s: %w", path, err)
+	}
+	register, ok := symbol.(func(*Registry) error)
+	if !ok {
+		return fmt.Errorf("plugin %s: Register is not a func(*registry.Registry) error", path)
+	}
+	if err := register(r); err != nil {
+		return fmt.Errorf("plugin %s: %w", path, err)
+	}
+	return nil
 }
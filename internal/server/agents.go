@@ -0,0 +1,42 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/yourusername/go-red/internal/agent"
+)
+
+// SetAgentHub accepts edge agents on /api/agents
+func (s *Server) SetAgentHub(hub *agent.Hub) {
+	s.agents = hub
+}
+
+// handleAgentConnection handles agent WebSocket connections to /api/agents
+func (s *Server) handleAgentConnection(w http.ResponseWriter, r *http.Request) {
+	if s.agents == nil {
+		respondError(w, http.StatusNotFound, "Agent hub is not enabled")
+		return
+	}
+	s.agents.HandleConnection(w, r)
+}
+
+// handleListAgents handles GET /api/agents
+func (s *Server) handleListAgents(w http.ResponseWriter, r *http.Request) {
+	agents := []agent.Info{}
+	if s.agents != nil {
+		agents = s.agents.Agents()
+	}
+	respond(w, http.StatusOK, agentList{Agents: agents})
+}
+
+// agentList is the response of GET /api/agents
+type agentList struct {
+	Agents []agent.Info `json:"agents"`
+}
+
+// isWebSocketUpgrade matches WebSocket handshakes
+func isWebSocketUpgrade(r *http.Request, _ *mux.RouteMatch) bool {
+	return websocket.IsWebSocketUpgrade(r)
+}
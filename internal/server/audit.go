@@ -0,0 +1,421 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/go-red/internal/config"
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// Limits of GET /api/audit pages
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// auditActions name the actions of mutating routes, keyed by routeKey.
+// Other routes are named by their method and template.
+var auditActions = map[string]string{
+	"POST /auth/token":                        "auth.token",
+	"POST /flows":                             "flow.create",
+	"PUT /flows/{id}":                         "flow.update",
+	"DELETE /flows/{id}":                      "flow.delete",
+	"POST /flows/{id}/start":                  "flow.start",
+	"POST /flows/{id}/stop":                   "flow.stop",
+	"PUT /flows/{id}/enable":                  "flow.enable",
+	"PUT /flows/{id}/disable":                 "flow.disable",
+	"POST /flows/from-template":               "flow.create",
+	"POST /flows/import":                      "flow.import",
+	"POST /flows/{id}/versions/{rev}/restore": "flow.restore",
+	"PATCH /flows/{id}/wires":                 "flow.wire",
+	"POST /flows/{id}/nodes/{nodeId}/enable":  "node.enable",
+	"PATCH /flows/{id}/nodes/{nodeId}/config": "node.configure",
+	"POST /examples/{name}/install":           "example.install",
+	"PUT /settings":                           "settings.update",
+	"POST /restore":                           "restore",
+	"POST /workspaces":                        "workspace.create",
+	"DELETE /workspaces/{workspace}":          "workspace.delete",
+}
+
+// auditLog records the changes made through the API in the storage, if it
+// keeps an audit log, or else in memory, and optionally sends them to
+// syslog
+type auditLog struct {
+	store  storage.AuditStore
+	logger *slog.Logger
+
+	syslogMu sync.Mutex
+	syslog   io.WriteCloser
+}
+
+// newAuditLog creates the audit log of a server, unless audit.enabled is
+// false. Entries go to syslog as well when audit.syslog.enabled is set.
+func newAuditLog(s *Server) *auditLog {
+	if !config.GetOrDefault(s.config, "audit.enabled", true) {
+		return nil
+	}
+	a := &auditLog{logger: s.engine.Logger().With("component", "audit")}
+	if store, ok := s.storage.(storage.AuditStore); ok {
+		a.store = store
+	} else {
+		a.store = storage.NewMemoryStorage()
+	}
+
+	if s.config.GetBool("audit.syslog.enabled") {
+		tag := config.GetOrDefault(s.config, "audit.syslog.tag", "go-red")
+		writer, err := dialSyslog(s.config.GetString("audit.syslog.network"), s.config.GetString("audit.syslog.address"), tag)
+		if err != nil {
+			a.logger.Warn("failed to connect to syslog, audit entries are only stored", "error", err)
+		} else {
+			a.syslog = writer
+		}
+	}
+	return a
+}
+
+// record stores an entry and sends it to syslog; failures are logged, as
+// the change has been made
+func (a *auditLog) record(entry storage.AuditEntry) {
+	if err := a.store.AppendAudit(entry); err != nil {
+		a.logger.Error("failed to write audit entry", "action", entry.Action, "resource", entry.Resource, "error", err)
+	}
+	if a.syslog == nil {
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	a.syslogMu.Lock()
+	defer a.syslogMu.Unlock()
+	if _, err := a.syslog.Write(data); err != nil {
+		a.logger.Warn("failed to send audit entry to syslog", "error", err)
+	}
+}
+
+// close disconnects from syslog
+func (a *auditLog) close() {
+	a.syslogMu.Lock()
+	defer a.syslogMu.Unlock()
+	if a.syslog != nil {
+		a.syslog.Close()
+		a.syslog = nil
+	}
+}
+
+// auditMiddleware records every request that changes something, with the
+// changes made to the definition of the flow it names
+func (s *Server) auditMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.audit == nil || !isMutating(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		action := auditAction(r.Method, route)
+		resource := auditResource(r)
+
+		var before []byte
+		if mux.Vars(r)["id"] != "" {
+			before = s.publicFlowJSON(flowKey(r))
+		}
+		var settingKeys []string
+		if action == "settings.update" {
+			settingKeys = requestKeys(r)
+		}
+
+		recorder := &auditRecorder{statusRecorder: statusRecorder{ResponseWriter: w, status: http.StatusOK}}
+		next.ServeHTTP(recorder, r)
+
+		entry := storage.AuditEntry{
+			Time:     time.Now(),
+			User:     requestUser(r),
+			Remote:   r.RemoteAddr,
+			Method:   r.Method,
+			Route:    route,
+			Action:   action,
+			Resource: resource,
+			Status:   recorder.status,
+		}
+		if recorder.status < http.StatusBadRequest {
+			switch {
+			case mux.Vars(r)["id"] != "":
+				entry.Summary = summarizeFlowChange(before, s.publicFlowJSON(flowKey(r)))
+			case action == "flow.create":
+				if id := recorder.responseID(); id != "" {
+					key := engine.FlowKey(requestWorkspace(r), id)
+					entry.Resource = key
+					entry.Summary = summarizeFlowChange(nil, s.publicFlowJSON(key))
+				}
+			case len(settingKeys) > 0:
+				entry.Summary = "changed " + strings.Join(settingKeys, ", ")
+			}
+		}
+		s.audit.record(entry)
+	})
+}
+
+// auditAction names the action of a request
+func auditAction(method, route string) string {
+	key := routeKey(method, route)
+	if action, ok := auditActions[key]; ok {
+		return action
+	}
+	return strings.ToLower(method) + strings.TrimPrefix(key, method)
+}
+
+// auditResource returns what a request affects: the flow key, node, library
+// entry, example or workspace in its path
+func auditResource(r *http.Request) string {
+	vars := mux.Vars(r)
+	switch {
+	case vars["id"] != "" && vars["nodeId"] != "":
+		return flowKey(r) + "/" + vars["nodeId"]
+	case vars["id"] != "":
+		return flowKey(r)
+	case vars["path"] != "":
+		return vars["type"] + "/" + vars["path"]
+	case vars["name"] != "":
+		return vars["name"]
+	}
+	return vars["workspace"]
+}
+
+// publicFlowJSON returns the definition of a flow without credentials, or
+// nil if it does not exist
+func (s *Server) publicFlowJSON(key string) []byte {
+	flow, exists := s.engine.GetFlow(key)
+	if !exists {
+		return nil
+	}
+	data, err := flow.PublicJSON()
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// requestKeys returns the sorted keys of a request's JSON object body,
+// leaving the body to be read again. Values are left out, as settings may
+// be secrets.
+func requestKeys(r *http.Request) []string {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+	r.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+	if err != nil {
+		return nil
+	}
+	var object map[string]json.RawMessage
+	if json.Unmarshal(body, &object) != nil {
+		return nil
+	}
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// auditRecorder records the status and the start of the body of a
+// response, for the ID of a created flow
+type auditRecorder struct {
+	statusRecorder
+	body bytes.Buffer
+}
+
+// maxAuditBody is how much of a response auditRecorder keeps
+const maxAuditBody = 64 * 1024
+
+func (r *auditRecorder) Write(data []byte) (int, error) {
+	if room := maxAuditBody - r.body.Len(); room > 0 {
+		if len(data) < room {
+			room = len(data)
+		}
+		r.body.Write(data[:room])
+	}
+	return r.ResponseWriter.Write(data)
+}
+
+// responseID returns the id field of the response, if it is a JSON object
+// with one
+func (r *auditRecorder) responseID() string {
+	var response struct {
+		ID string `json:"id"`
+	}
+	if json.Unmarshal(r.body.Bytes(), &response) != nil {
+		return ""
+	}
+	return response.ID
+}
+
+// summarizeFlowChange describes how a flow definition changed: whether it
+// was created or deleted, renamed, which nodes were added, removed or
+// changed, and how many wires were added or removed
+func summarizeFlowChange(before, after []byte) string {
+	var prev, next engine.FlowDefinition
+	prevErr, nextErr := json.Unmarshal(before, &prev), json.Unmarshal(after, &next)
+	switch {
+	case before == nil && after == nil:
+		return ""
+	case before == nil && nextErr == nil:
+		return fmt.Sprintf("created with %d nodes and %d wires", len(next.Nodes), len(next.Wires))
+	case after == nil && prevErr == nil:
+		return fmt.Sprintf("deleted with %d nodes and %d wires", len(prev.Nodes), len(prev.Wires))
+	case prevErr != nil || nextErr != nil:
+		return ""
+	}
+
+	var parts []string
+	if prev.Name != next.Name {
+		parts = append(parts, fmt.Sprintf("renamed from %q to %q", prev.Name, next.Name))
+	}
+	prevNodes, nextNodes := nodeDefinitions(prev.Nodes), nodeDefinitions(next.Nodes)
+	var added, removed, changed []string
+	for id, node := range nextNodes {
+		if previous, ok := prevNodes[id]; !ok {
+			added = append(added, id)
+		} else if previous != node {
+			changed = append(changed, id)
+		}
+	}
+	for id := range prevNodes {
+		if _, ok := nextNodes[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	parts = appendIDs(parts, "nodes added", added)
+	parts = appendIDs(parts, "nodes removed", removed)
+	parts = appendIDs(parts, "nodes changed", changed)
+
+	prevWires, nextWires := wireSet(prev.Wires), wireSet(next.Wires)
+	wiresAdded, wiresRemoved := 0, 0
+	for wire := range nextWires {
+		if !prevWires[wire] {
+			wiresAdded++
+		}
+	}
+	for wire := range prevWires {
+		if !nextWires[wire] {
+			wiresRemoved++
+		}
+	}
+	if wiresAdded > 0 {
+		parts = append(parts, fmt.Sprintf("%d wires added", wiresAdded))
+	}
+	if wiresRemoved > 0 {
+		parts = append(parts, fmt.Sprintf("%d wires removed", wiresRemoved))
+	}
+
+	// Anything else, such as the schedule or limits
+	prev.Name, prev.Nodes, prev.Wires = "", nil, nil
+	next.Name, next.Nodes, next.Wires = "", nil, nil
+	prevRest, _ := json.Marshal(prev)
+	nextRest, _ := json.Marshal(next)
+	if !bytes.Equal(prevRest, nextRest) {
+		parts = append(parts, "settings changed")
+	}
+	return strings.Join(parts, "; ")
+}
+
+// nodeDefinitions returns the JSON of nodes by ID
+func nodeDefinitions(nodes []engine.NodeDefinition) map[string]string {
+	definitions := make(map[string]string, len(nodes))
+	for _, node := range nodes {
+		data, _ := json.Marshal(node)
+		definitions[node.ID] = string(data)
+	}
+	return definitions
+}
+
+// wireSet returns the wires as a set of source, port and target
+func wireSet(wires []engine.WireDefinition) map[string]bool {
+	set := make(map[string]bool, len(wires))
+	for _, wire := range wires {
+		set[fmt.Sprintf("%s:%d>%s", wire.Source, wire.Port, wire.Target)] = true
+	}
+	return set
+}
+
+// appendIDs appends a list of IDs to the parts of a summary, shortened to
+// the first ten
+func appendIDs(parts []string, label string, ids []string) []string {
+	if len(ids) == 0 {
+		return parts
+	}
+	sort.Strings(ids)
+	if len(ids) > 10 {
+		ids = append(ids[:10:10], fmt.Sprintf("and %d more", len(ids)-10))
+	}
+	return append(parts, label+": "+strings.Join(ids, ", "))
+}
+
+// handleListAudit handles GET /api/audit, which lists the audit log newest
+// first. since and until select a time range as RFC 3339 timestamps, and
+// limit and offset a page of it.
+func (s *Server) handleListAudit(w http.ResponseWriter, r *http.Request) {
+	if len(s.configList("editor.admins")) > 0 && !s.isAdmin(engine.DefaultWorkspace, requestUser(r)) {
+		respondError(w, http.StatusForbidden, "Only admins may read the audit log")
+		return
+	}
+	if s.audit == nil {
+		respondError(w, http.StatusNotFound, "The audit log is disabled")
+		return
+	}
+
+	params := r.URL.Query()
+	limit, offset, err := pageParams(params)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query := storage.AuditQuery{Limit: limit, Offset: offset}
+	if params.Get("limit") == "" {
+		query.Limit = defaultAuditLimit
+	}
+	for name, target := range map[string]*time.Time{"since": &query.Since, "until": &query.Until} {
+		if value := params.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s: %v", name, err))
+				return
+			}
+			*target = t
+		}
+	}
+	if query.Limit == 0 || query.Limit > maxAuditLimit {
+		query.Limit = maxAuditLimit
+	}
+
+	entries, total, err := s.audit.store.ListAudit(query)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list audit entries: %v", err))
+		return
+	}
+	respond(w, http.StatusOK, auditPage{Entries: entries, Total: total, Limit: query.Limit, Offset: query.Offset})
+}
+
+// auditPage is a page of the audit log with the number of entries in the
+// time range
+type auditPage struct {
+	Entries []storage.AuditEntry `json:"entries"`
+	Total   int                  `json:"total"`
+	Limit   int                  `json:"limit"`
+	Offset  int                  `json:"offset"`
+}
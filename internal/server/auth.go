@@ -0,0 +1,361 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/yourusername/go-red/internal/config"
+	"github.com/yourusername/go-red/internal/engine"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// defaultTokenTTL is how long tokens issued by POST /auth/token are valid
+// unless api.auth.tokenTTL says otherwise
+const defaultTokenTTL = 12 * time.Hour
+
+// authProtocol is the WebSocket subprotocol that browsers, which cannot set
+// headers on WebSocket connections, send before their token:
+// new WebSocket(url, ["access_token", token])
+const authProtocol = "access_token"
+
+// contextKey keys the values the server adds to request contexts
+type contextKey int
+
+// identityContextKey holds the Identity of authenticated requests
+const identityContextKey contextKey = iota
+
+// Role is what a user may do through the API
+type Role string
+
+// Roles, each allowing what the ones before it do
+const (
+	// RoleRead may view flows, their status and debug output
+	RoleRead Role = "read"
+	// RoleWrite may also deploy, change and delete flows
+	RoleWrite Role = "write"
+	// RoleAdmin may also change settings, back up and restore
+	RoleAdmin Role = "admin"
+)
+
+// roleRanks orders the roles
+var roleRanks = map[Role]int{RoleRead: 1, RoleWrite: 2, RoleAdmin: 3}
+
+// Allows reports whether the role may do what required needs
+func (r Role) Allows(required Role) bool {
+	return roleRanks[r] >= roleRanks[required]
+}
+
+// Identity is the user of an authenticated request and its role
+type Identity struct {
+	User string `json:"user"`
+	Role Role   `json:"role"`
+}
+
+// RequestIdentity returns the identity of an authenticated request
+func RequestIdentity(r *http.Request) (Identity, bool) {
+	identity, ok := r.Context().Value(identityContextKey).(Identity)
+	return identity, ok
+}
+
+// adminRoutes are the routes that need the admin role
+var adminRoutes = map[string]bool{
+	"/api/settings": true,
+	"/api/backup":   true,
+	"/api/restore":  true,
+	"/api/audit":    true,
+}
+
+// auth checks the bearer tokens of API requests when api.auth.enabled is
+// set. Tokens are either static, from api.auth.tokens.<user>, or issued by
+// POST /auth/token to the users of api.auth.users.<user>, whose values are
+// bcrypt hashes of their passwords. Users have the role set in
+// api.auth.roles.<user>, or else api.auth.defaultRole, or else write.
+type auth struct {
+	config *config.Config
+	// secret signs issued tokens when api.auth.secret is not set
+	secret []byte
+	mu     sync.RWMutex
+	exempt []string
+}
+
+// tokenClaims is the payload of an issued token
+type tokenClaims struct {
+	User    string `json:"sub"`
+	Expires int64  `json:"exp"`
+}
+
+func newAuth(cfg *config.Config) *auth {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return &auth{config: cfg, secret: secret, exempt: []string{"/api/health", "/api/openapi.json", "/api/docs"}}
+}
+
+// Exempt lets requests to paths through without a token. A path ending in
+// "*" exempts the paths starting with it.
+func (s *Server) Exempt(paths ...string) {
+	s.auth.mu.Lock()
+	defer s.auth.mu.Unlock()
+	s.auth.exempt = append(s.auth.exempt, paths...)
+}
+
+// isExempt reports whether a request needs no token: its path is exempt,
+// by Exempt or by api.auth.exempt, or it is an agent connecting with the
+// agents.token, which the agent hub checks itself
+func (a *auth) isExempt(r *http.Request) bool {
+	if r.URL.Path == "/api/agents" && websocket.IsWebSocketUpgrade(r) && a.config.GetString("agents.token") != "" {
+		return true
+	}
+	a.mu.RLock()
+	paths := append(a.config.GetStringSlice("api.auth.exempt"), a.exempt...)
+	a.mu.RUnlock()
+	for _, path := range paths {
+		if path == r.URL.Path || strings.HasSuffix(path, "*") && strings.HasPrefix(r.URL.Path, strings.TrimSuffix(path, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// role returns the role of an authenticated user
+func (a *auth) role(user string) Role {
+	if role := Role(a.config.GetString("api.auth.roles." + user)); roleRanks[role] > 0 {
+		return role
+	}
+	if role := Role(a.config.GetString("api.auth.defaultRole")); roleRanks[role] > 0 {
+		return role
+	}
+	return RoleWrite
+}
+
+// requiredRole returns the role a request needs: admin for the admin
+// routes, write for other requests that change something, else read
+func requiredRole(r *http.Request) Role {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil && adminRoutes[template] {
+			return RoleAdmin
+		}
+	}
+	if isMutating(r.Method) {
+		return RoleWrite
+	}
+	return RoleRead
+}
+
+// signingKey returns the key that signs issued tokens
+func (a *auth) signingKey() []byte {
+	if secret := a.config.GetString("api.auth.secret"); secret != "" {
+		return []byte(secret)
+	}
+	return a.secret
+}
+
+// issue returns a signed token for user, valid for ttl
+func (a *auth) issue(user string, ttl time.Duration) (string, time.Time, error) {
+	expires := time.Now().Add(ttl)
+	payload, err := json.Marshal(tokenClaims{User: user, Expires: expires.Unix()})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + a.sign(encoded), expires, nil
+}
+
+// sign returns the signature of an encoded token payload
+func (a *auth) sign(payload string) string {
+	mac := hmac.New(sha256.New, a.signingKey())
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify returns the user of a token, static or issued
+func (a *auth) verify(token string) (string, error) {
+	for _, key := range a.config.Keys("api.auth.tokens.") {
+		static := a.config.GetString(key)
+		if static != "" && subtle.ConstantTimeCompare([]byte(token), []byte(static)) == 1 {
+			return strings.TrimPrefix(key, "api.auth.tokens."), nil
+		}
+	}
+
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(a.sign(payload))) {
+		return "", errors.New("invalid token")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", errors.New("invalid token")
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(data, &claims); err != nil || claims.User == "" {
+		return "", errors.New("invalid token")
+	}
+	if time.Now().Unix() >= claims.Expires {
+		return "", errors.New("token expired")
+	}
+	return claims.User, nil
+}
+
+// requestToken returns the bearer token of a request. WebSocket upgrades
+// and the pages of the debug console, which browsers open without headers,
+// may also pass it in the access_token query parameter; WebSocket upgrades
+// also as the subprotocol after access_token.
+func requestToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	debugPage := r.URL.Path == "/debug" || strings.HasPrefix(r.URL.Path, "/debug/")
+	if !websocket.IsWebSocketUpgrade(r) && !debugPage {
+		return ""
+	}
+	if token := r.URL.Query().Get("access_token"); token != "" {
+		return token
+	}
+	if !websocket.IsWebSocketUpgrade(r) {
+		return ""
+	}
+	protocols := websocket.Subprotocols(r)
+	for i, protocol := range protocols {
+		if protocol == authProtocol && i+1 < len(protocols) {
+			return protocols[i+1]
+		}
+	}
+	return ""
+}
+
+// authMiddleware rejects requests without a valid token with 401 when
+// api.auth.enabled is set, and those the token's user has no role for
+// with 403. It attaches the Identity of the user to the request.
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.config.GetBool("api.auth.enabled") || s.auth.isExempt(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token := requestToken(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			respondError(w, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+		user, err := s.auth.verify(token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			respondError(w, http.StatusUnauthorized, "Invalid token: "+err.Error())
+			return
+		}
+		identity := Identity{User: user, Role: s.auth.role(user)}
+		if required := requiredRole(r); !identity.Role.Allows(required) {
+			respondError(w, http.StatusForbidden, fmt.Sprintf("The %s role is required", required))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityContextKey, identity)))
+	})
+}
+
+// handleWhoAmI handles GET /api/auth/whoami, which returns the caller and
+// what it may do, so that clients can hide what it may not. Without
+// authentication, everyone may read and write, and admin rights follow
+// editor.admins.
+func (s *Server) handleWhoAmI(w http.ResponseWriter, r *http.Request) {
+	identity, authenticated := RequestIdentity(r)
+	if !authenticated {
+		identity = Identity{User: requestUser(r), Role: RoleWrite}
+		if len(s.configList("editor.admins")) == 0 || s.isAdmin(engine.DefaultWorkspace, identity.User) {
+			identity.Role = RoleAdmin
+		}
+	}
+	respond(w, http.StatusOK, whoAmI{
+		User:          identity.User,
+		Role:          identity.Role,
+		Authenticated: authenticated,
+		Permissions: permissions{
+			Read:  identity.Role.Allows(RoleRead),
+			Write: identity.Role.Allows(RoleWrite),
+			Admin: identity.Role.Allows(RoleAdmin),
+		},
+	})
+}
+
+// whoAmI is the response of GET /api/auth/whoami
+type whoAmI struct {
+	User          string      `json:"user"`
+	Role          Role        `json:"role"`
+	Authenticated bool        `json:"authenticated"`
+	Permissions   permissions `json:"permissions"`
+}
+
+// permissions tells what a caller may do
+type permissions struct {
+	Read  bool `json:"read"`
+	Write bool `json:"write"`
+	Admin bool `json:"admin"`
+}
+
+// handleIssueToken handles POST /auth/token, which exchanges the username
+// and password of a user in api.auth.users for a token
+func (s *Server) handleIssueToken(w http.ResponseWriter, r *http.Request) {
+	var request tokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	hash := s.config.GetString("api.auth.users." + request.Username)
+	if request.Username == "" || hash == "" {
+		// Compare anyway, so that unknown users take as long as known ones
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(request.Password))
+		respondError(w, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(request.Password)); err != nil {
+		respondError(w, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+
+	ttl := config.GetOrDefault(s.config, "api.auth.tokenTTL", defaultTokenTTL)
+	token, expires, err := s.auth.issue(request.Username, ttl)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to issue token")
+		return
+	}
+	respond(w, http.StatusOK, issuedToken{Token: token, ExpiresAt: expires.UTC()})
+}
+
+// tokenRequest is the body of POST /auth/token
+type tokenRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// issuedToken is the response of POST /auth/token
+type issuedToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// dummyHash is compared against the passwords of unknown users
+var dummyHash = []byte("$2a$10$GtrSLi3/Ut4OXIT6M4h0U.gZU5IFF/0fhaLbcsaMesN.esoXBCGae")
+
+// handleHealth handles GET /api/health, which needs no token
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	respond(w, http.StatusOK, healthStatus{Status: "ok"})
+}
+
+// healthStatus is the response of GET /api/health
+type healthStatus struct {
+	Status string `json:"status"`
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// maxRestoreSize bounds the size of backup archives to restore unless
+// api.maxRestoreSize is set
+const maxRestoreSize = 256 << 20
+
+// unsavedSetting reports whether a setting is left out of backups: the
+// credential secret is kept apart from the credentials it encrypts, and
+// API tokens and their signing secret out of archives altogether
+func unsavedSetting(key string) bool {
+	return key == "credentialSecret" || key == "api.auth.secret" || strings.HasPrefix(key, "api.auth.tokens.")
+}
+
+// adminAllowed reports whether a request may manage the whole runtime:
+// if editor.admins is set, only its users and those with the admin role
+// may; otherwise everyone may.
+// Others are answered with 403 Forbidden.
+func (s *Server) adminAllowed(w http.ResponseWriter, r *http.Request) bool {
+	if len(s.configList("editor.admins")) == 0 || s.isAdmin(engine.DefaultWorkspace, requestUser(r)) {
+		return true
+	}
+	respondError(w, http.StatusForbidden, "Only admins may back up and restore")
+	return false
+}
+
+// handleBackup handles GET /api/backup, which returns a tar.gz archive of
+// all flows, their credentials, the persistent context and the settings
+func (s *Server) handleBackup(w http.ResponseWriter, r *http.Request) {
+	if !s.adminAllowed(w, r) {
+		return
+	}
+	backup, err := s.engine.Backup()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to back up: %v", err))
+		return
+	}
+	backup.Settings = make(map[string]interface{})
+	for _, key := range s.config.Keys("") {
+		if !unsavedSetting(key) {
+			backup.Settings[key], _ = s.config.Get(key)
+		}
+	}
+
+	name := fmt.Sprintf("go-red-backup-%s.tar.gz", backup.Created.Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.WriteHeader(http.StatusOK)
+	if _, err := backup.WriteTo(w); err != nil {
+		s.engine.Logger().Warn("failed to send backup", "error", err)
+	}
+}
+
+// handleRestore handles POST /api/restore, which replaces the flows, their
+// credentials, the persistent context and the settings by those of a
+// backup archive. Archives that are corrupt, whose credentials do not
+// decrypt with the current secret, or whose flows fail to build, are
+// rejected with 400 before anything changes.
+func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
+	if !s.adminAllowed(w, r) {
+		return
+	}
+	// limitMiddleware bounds the size of the archive
+	backup, err := engine.ReadBackup(r.Body)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Restoring replaces every flow, those of the backup and the others
+	keys := s.engine.ListFlows()
+	for key := range backup.Flows {
+		keys = append(keys, key)
+	}
+	if !s.checkFlowLocks(w, r, keys) {
+		return
+	}
+
+	report, err := s.engine.Restore(backup)
+	if err != nil && report == nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Failed to restore: %v", err))
+		return
+	}
+	if err != nil {
+		respond(w, http.StatusInternalServerError, restoreErrorResponse{
+			Error:  fmt.Sprintf("Failed to restore: %v", err),
+			Report: report,
+		})
+		return
+	}
+
+	// Settings read at startup take effect after a restart
+	for key, value := range backup.Settings {
+		if !unsavedSetting(key) {
+			s.config.Set(key, value)
+		}
+	}
+	respond(w, http.StatusOK, restoreResult{
+		Success:       len(report.Failed) == 0,
+		RestoreReport: *report,
+		Settings:      len(backup.Settings),
+	})
+}
+
+// restoreResult is the response of POST /api/restore, with the number of
+// settings restored
+type restoreResult struct {
+	Success bool `json:"success"`
+	engine.RestoreReport
+	Settings int `json:"settings"`
+}
+
+// restoreErrorResponse reports a restore that failed part way
+type restoreErrorResponse struct {
+	Error  string                `json:"error"`
+	Report *engine.RestoreReport `json:"report"`
+}
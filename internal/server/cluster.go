@@ -0,0 +1,115 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/yourusername/go-red/internal/cluster"
+)
+
+// forwardedHeader marks requests a follower proxied to the leader
+const forwardedHeader = "X-Go-Red-Forwarded"
+
+// ClusterMember is this instance's view of the cluster
+type ClusterMember interface {
+	// ID identifies this instance
+	ID() string
+	// IsLeader reports whether this instance runs the flows
+	IsLeader() bool
+	// Leader returns the current lease and whether it is unexpired
+	Leader() (cluster.Lease, bool)
+}
+
+// SetCluster enables cluster mode: followers serve reads themselves and
+// proxy mutating API calls to the leader
+func (s *Server) SetCluster(member ClusterMember) {
+	s.cluster = member
+}
+
+// clusterMiddleware proxies mutating API calls to the leader while this
+// instance is a follower
+func (s *Server) clusterMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.cluster == nil || s.cluster.IsLeader() || !isMutating(r.Method) || r.URL.Path == "/api/cluster" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		lease, ok := s.cluster.Leader()
+		if !ok || lease.Address == "" {
+			respondError(w, http.StatusServiceUnavailable, "No cluster leader available")
+			return
+		}
+		if r.Header.Get(forwardedHeader) != "" {
+			// The leader we proxied to thinks it is not leader; do not loop
+			respondError(w, http.StatusServiceUnavailable, "Cluster leadership is changing")
+			return
+		}
+
+		target, err := url.Parse(lease.Address)
+		if err != nil {
+			respondError(w, http.StatusServiceUnavailable, "Invalid leader address")
+			return
+		}
+		proxy := httputil.NewSingleHostReverseProxy(target)
+		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			respondError(w, http.StatusBadGateway, "Leader unreachable: "+err.Error())
+		}
+		// This instance has set the CORS headers already
+		proxy.ModifyResponse = func(res *http.Response) error {
+			for key := range res.Header {
+				if strings.HasPrefix(key, "Access-Control-") {
+					res.Header.Del(key)
+				}
+			}
+			return nil
+		}
+		r.Header.Set(forwardedHeader, s.cluster.ID())
+		proxy.ServeHTTP(w, r)
+	})
+}
+
+// handleGetCluster handles GET /api/cluster
+func (s *Server) handleGetCluster(w http.ResponseWriter, r *http.Request) {
+	if s.cluster == nil {
+		respond(w, http.StatusOK, clusterInfo{Enabled: false})
+		return
+	}
+
+	result := clusterInfo{
+		Enabled: true,
+		ID:      s.cluster.ID(),
+		Leader:  s.cluster.IsLeader(),
+	}
+	if lease, ok := s.cluster.Leader(); ok {
+		result.Lease = &lease
+	}
+
+	// Load balancers can route to the leader by its status code
+	status := http.StatusOK
+	if r.URL.Query().Get("leader") != "" && !s.cluster.IsLeader() {
+		status = http.StatusServiceUnavailable
+	}
+	respond(w, status, result)
+}
+
+// clusterInfo is the response of GET /api/cluster; lease is the current
+// leader's, if any
+type clusterInfo struct {
+	Enabled bool           `json:"enabled"`
+	ID      string         `json:"id,omitempty"`
+	Leader  bool           `json:"leader"`
+	Lease   *cluster.Lease `json:"lease,omitempty"`
+}
+
+// isMutating reports whether a request method changes state
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	default:
+		return true
+	}
+}
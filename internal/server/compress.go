@@ -0,0 +1,230 @@
+package server
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/yourusername/go-red/internal/config"
+)
+
+// Defaults of the compression settings
+var defaultCompressionTypes = []string{
+	"text/*", "application/json", "application/javascript", "application/xml", "image/svg+xml",
+}
+
+// defaultCompressionMinSize is the smallest response compressed unless
+// http.compression.minSize says otherwise; smaller ones gain little
+const defaultCompressionMinSize = 1024
+
+// compression compresses responses of the API and the editor with gzip or
+// deflate, as the client accepts, when http.compression.enabled is set,
+// which it is by default. The routes of http-in nodes are left to their
+// flows, which set their own headers.
+type compression struct {
+	// minSize is the smallest body compressed, from
+	// http.compression.minSize
+	minSize int
+	// types are the media types compressed, from http.compression.types,
+	// where "text/*" stands for all text types
+	types []string
+}
+
+// newCompression reads the compression settings, or returns nil if
+// compression is disabled
+func newCompression(cfg *config.Config) *compression {
+	if !config.GetOrDefault(cfg, "http.compression.enabled", true) {
+		return nil
+	}
+	return &compression{
+		minSize: config.GetOrDefault(cfg, "http.compression.minSize", defaultCompressionMinSize),
+		types:   config.GetOrDefault(cfg, "http.compression.types", defaultCompressionTypes),
+	}
+}
+
+// allows reports whether responses of a content type are compressed
+func (c *compression) allows(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range c.types {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed == mediaType || (strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(allowed, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// middleware compresses the responses of requests that accept gzip or
+// deflate. WebSocket upgrades, HEAD and range requests are passed
+// through.
+func (c *compression) middleware(next http.Handler) http.Handler {
+	if c == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Range") != "" || websocket.IsWebSocketUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		writer := &compressWriter{ResponseWriter: w, compression: c, encoding: encoding, status: http.StatusOK}
+		defer writer.close()
+		next.ServeHTTP(writer, r)
+	})
+}
+
+// acceptedEncoding returns the encoding of an Accept-Encoding header that
+// responses are compressed with, gzip in preference to deflate, or "" for
+// none
+func acceptedEncoding(header string) string {
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if q, err := strconv.ParseFloat(value, 64); err == nil {
+				quality = q
+			}
+		}
+		if name != "" {
+			accepted[name] = quality
+		}
+	}
+	best, bestQuality := "", 0.0
+	for _, encoding := range []string{"gzip", "deflate"} {
+		quality, ok := accepted[encoding]
+		if !ok {
+			quality, ok = accepted["*"]
+		}
+		if ok && quality > bestQuality {
+			best, bestQuality = encoding, quality
+		}
+	}
+	return best
+}
+
+// compressWriter holds back the start of a response until it knows its
+// status, type and whether it reaches the minimum size, then writes it
+// compressed or as it is
+type compressWriter struct {
+	http.ResponseWriter
+	compression *compression
+	encoding    string
+	status      int
+	// buffer holds the body until decided
+	buffer  []byte
+	decided bool
+	// encoder compresses the body, if it is compressed
+	encoder io.WriteCloser
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.decided {
+		return
+	}
+	// Informational responses go out at once
+	if status >= 100 && status < 200 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.buffer = append(w.buffer, data...)
+		if len(w.buffer) < w.compression.minSize {
+			return len(data), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// decide writes the header, compressing the body if it is large enough, of
+// a status with a body, of an allowed type and not encoded already, and
+// then the buffered start of the body
+func (w *compressWriter) decide(large bool) error {
+	w.decided = true
+	header := w.Header()
+	if header.Get("Content-Type") == "" && len(w.buffer) > 0 {
+		header.Set("Content-Type", http.DetectContentType(w.buffer))
+	}
+	eligible := w.status != http.StatusNoContent && w.status != http.StatusNotModified && w.status != http.StatusPartialContent &&
+		header.Get("Content-Encoding") == "" && w.compression.allows(header.Get("Content-Type"))
+	if eligible {
+		header.Add("Vary", "Accept-Encoding")
+	}
+	if eligible && large {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		// The compressed body is not the one the strong ETag names; weak
+		// ETags are accepted by If-Match as well
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+		if w.encoding == "gzip" {
+			w.encoder = gzip.NewWriter(w.ResponseWriter)
+		} else {
+			w.encoder = zlib.NewWriter(w.ResponseWriter)
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buffered := w.buffer
+	w.buffer = nil
+	if len(buffered) == 0 {
+		return nil
+	}
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(buffered)
+	} else {
+		_, err = w.ResponseWriter.Write(buffered)
+	}
+	return err
+}
+
+// Flush sends what was written so far, compressed if the response is of a
+// type that is, as streamed responses do not wait for the minimum size
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(true)
+	}
+	if flusher, ok := w.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap gives http.ResponseController the underlying writer
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// close writes what is held back and ends the compressed body
+func (w *compressWriter) close() {
+	if !w.decided {
+		w.decide(len(w.buffer) >= w.compression.minSize)
+	}
+	if w.encoder != nil {
+		w.encoder.Close()
+	}
+}
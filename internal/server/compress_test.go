@@ -0,0 +1,194 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAcceptedEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"GZIP", "gzip"},
+		{"gzip, deflate, br", "gzip"},
+		{"deflate, gzip;q=0.5", "deflate"},
+		{"gzip;q=0, deflate", "deflate"},
+		{"gzip;q=0", ""},
+		{"*", "gzip"},
+		{"*;q=0.1, deflate", "deflate"},
+		{"identity", ""},
+		{"br", ""},
+	}
+	for _, tt := range tests {
+		if got := acceptedEncoding(tt.header); got != tt.want {
+			t.Errorf("acceptedEncoding(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+// decode returns the body of a response as it was before compression
+func decode(t *testing.T, encoding string, body []byte) []byte {
+	t.Helper()
+	var reader io.Reader
+	var err error
+	switch encoding {
+	case "gzip":
+		reader, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		reader, err = zlib.NewReader(bytes.NewReader(body))
+	default:
+		return body
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	large := `{"data": "` + strings.Repeat("x", 4096) + `"}`
+	tests := []struct {
+		name           string
+		method         string
+		accept         string
+		requestHeaders map[string]string
+		contentType    string
+		headers        map[string]string
+		status         int
+		body           string
+		wantEncoding   string
+		wantVary       bool
+	}{
+		{name: "gzip", accept: "gzip", contentType: "application/json", body: large, wantEncoding: "gzip", wantVary: true},
+		{name: "deflate", accept: "deflate", contentType: "application/json", body: large, wantEncoding: "deflate", wantVary: true},
+		{name: "not accepted", accept: "", contentType: "application/json", body: large},
+		{name: "small", accept: "gzip", contentType: "application/json", body: `{"ok": true}`, wantVary: true},
+		{name: "text wildcard", accept: "gzip", contentType: "text/html; charset=utf-8", body: large, wantEncoding: "gzip", wantVary: true},
+		{name: "image", accept: "gzip", contentType: "image/png", body: large},
+		{name: "encoded already", accept: "gzip", contentType: "application/json", headers: map[string]string{"Content-Encoding": "br"}, body: large, wantEncoding: "br"},
+		{name: "no content", accept: "gzip", status: http.StatusNoContent},
+		{name: "head", method: http.MethodHead, accept: "gzip", contentType: "application/json", body: large},
+		{name: "range", accept: "gzip", requestHeaders: map[string]string{"Range": "bytes=0-9"}, contentType: "application/json", body: large},
+	}
+	c := &compression{minSize: defaultCompressionMinSize, types: defaultCompressionTypes}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := c.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				for key, value := range tt.headers {
+					w.Header().Set(key, value)
+				}
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+				io.WriteString(w, tt.body)
+			}))
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, "/", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept-Encoding", tt.accept)
+			}
+			for key, value := range tt.requestHeaders {
+				req.Header.Set(key, value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+			if got := rec.Header().Get("Vary") == "Accept-Encoding"; got != tt.wantVary {
+				t.Errorf("Vary = %q, want Accept-Encoding %v", rec.Header().Get("Vary"), tt.wantVary)
+			}
+			if tt.wantEncoding == "br" {
+				return
+			}
+			if body := decode(t, tt.wantEncoding, rec.Body.Bytes()); string(body) != tt.body {
+				t.Errorf("body is %d bytes after decoding, want %d", len(body), len(tt.body))
+			}
+		})
+	}
+}
+
+func TestCompressionWeakensETag(t *testing.T) {
+	large := strings.Repeat("x", 4096)
+	tests := []struct {
+		name   string
+		accept string
+		etag   string
+		want   string
+	}{
+		{"strong compressed", "gzip", `"abc"`, `W/"abc"`},
+		{"weak compressed", "gzip", `W/"abc"`, `W/"abc"`},
+		{"strong uncompressed", "", `"abc"`, `"abc"`},
+	}
+	c := &compression{minSize: defaultCompressionMinSize, types: defaultCompressionTypes}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := c.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.Header().Set("ETag", tt.etag)
+				io.WriteString(w, large)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept-Encoding", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if got := rec.Header().Get("ETag"); got != tt.want {
+				t.Errorf("ETag = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompressionRoutes(t *testing.T) {
+	s := newTestServer(t, nil)
+	large := strings.Repeat("x", 4096)
+	if err := s.engine.HTTPRoutes().Handle(http.MethodGet, "/hook", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, large)
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/api/openapi.json", "gzip"},
+		{"/hook", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: status %d", tt.path, rec.Code)
+		}
+		if got := rec.Header().Get("Content-Encoding"); got != tt.want {
+			t.Errorf("GET %s: Content-Encoding = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/go-red/internal/config"
+)
+
+// Defaults of the CORS settings
+var (
+	defaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+	defaultCORSHeaders = []string{"Authorization", "Content-Type", "If-Match", userHeader}
+)
+
+// defaultCORSMaxAge is how long browsers may cache a preflight answer
+// unless api.cors.maxAge says otherwise
+const defaultCORSMaxAge = 10 * time.Minute
+
+// originAllowed reports whether an origin is listed in api.cors.origins,
+// where "*" allows any origin and a "*" within an entry, as in
+// "https://*.example.com", matches any part of it
+func originAllowed(cfg *config.Config, origin string) bool {
+	if origin == "" || cfg == nil {
+		return false
+	}
+	for _, allowed := range cfg.GetStringSlice("api.cors.origins") {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		if prefix, suffix, ok := strings.Cut(strings.ToLower(allowed), "*"); ok {
+			lower := strings.ToLower(origin)
+			if len(lower) > len(prefix)+len(suffix) && strings.HasPrefix(lower, prefix) && strings.HasSuffix(lower, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameOrigin reports whether a request comes from a page of the server
+// itself, or from a client that is not a browser and sends no origin
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// corsMiddleware lets pages of the origins in api.cors.origins call the
+// API, and answers their preflight requests. api.cors.methods and
+// api.cors.headers set what they may send, and api.cors.credentials lets
+// them send cookies.
+func (s *Server) corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if !originAllowed(s.config, origin) {
+			if preflight {
+				respondError(w, http.StatusForbidden, "Origin not allowed")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header := w.Header()
+		header.Add("Vary", "Origin")
+		credentials := s.config.GetBool("api.cors.credentials")
+		// Credentials cannot be sent to "*", so the origin is echoed
+		// instead
+		if !credentials && contains(s.config.GetStringSlice("api.cors.origins"), "*") {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if credentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			// Editors send flow revisions back in If-Match
+			header.Set("Access-Control-Expose-Headers", "ETag")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		methods := s.config.GetStringSlice("api.cors.methods")
+		if len(methods) == 0 {
+			methods = defaultCORSMethods
+		}
+		headers := s.config.GetStringSlice("api.cors.headers")
+		if len(headers) == 0 {
+			headers = defaultCORSHeaders
+		}
+		maxAge := config.GetOrDefault(s.config, "api.cors.maxAge", defaultCORSMaxAge)
+		header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		header.Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(maxAge.Seconds())))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// handleOptions answers OPTIONS requests to the API, which have no routes
+// of their own, so that they reach the CORS middleware
+func (s *Server) handleOptions(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// contains reports whether list holds s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// debugMessageLog is the type of the log record messages of /ws/debug; debug
+// outputs and status changes keep their event type
+const debugMessageLog = "log"
+
+// debugFilter selects the events a /ws/debug client receives
+type debugFilter struct {
+	// flows are the IDs of the flows whose events are sent; all flows if
+	// empty
+	flows map[string]bool
+	// severity is the lowest severity sent. Debug outputs are debug and
+	// status changes info; log records have their level.
+	severity slog.Level
+}
+
+// newDebugFilter creates a filter for the given flow IDs and lowest
+// severity, which defaults to debug
+func newDebugFilter(flows []string, severity string) (*debugFilter, error) {
+	filter := &debugFilter{severity: slog.LevelDebug}
+	if severity != "" {
+		level, err := engine.ParseLogLevel(severity)
+		if err != nil {
+			return nil, err
+		}
+		filter.severity = level
+	}
+	for _, list := range flows {
+		for _, id := range strings.Split(list, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				if filter.flows == nil {
+					filter.flows = make(map[string]bool)
+				}
+				filter.flows[id] = true
+			}
+		}
+	}
+	return filter, nil
+}
+
+// allows reports whether an event of a flow passes the filter. Events of no
+// flow only pass filters of all flows.
+func (f *debugFilter) allows(flowID string, severity slog.Level) bool {
+	if severity < f.severity {
+		return false
+	}
+	return len(f.flows) == 0 || f.flows[flowID]
+}
+
+// subscribeDebug replaces the filter of a /ws/debug client, telling it if
+// the filter is invalid
+func (c *WebSocketClient) subscribeDebug(flowID string, flows []string, severity string) {
+	if flowID != "" {
+		flows = append(flows, flowID)
+	}
+	filter, err := newDebugFilter(flows, severity)
+	if err != nil {
+		payload, _ := json.Marshal(map[string]string{"error": err.Error()})
+		message, _ := json.Marshal(WebSocketMessage{Type: "error", Payload: payload})
+		c.offer(message)
+		return
+	}
+	c.filter.Store(filter)
+}
+
+// offer queues a message for the client without blocking, dropping the
+// oldest queued messages while its buffer is full. The caller holds the
+// manager's lock or is the client's reader, so the buffer is not closed
+// meanwhile.
+func (c *WebSocketClient) offer(message []byte) {
+	for {
+		select {
+		case c.send <- message:
+			return
+		default:
+		}
+		select {
+		case <-c.send:
+		default:
+		}
+	}
+}
+
+// publishDebug sends a message to the debug clients of a workspace whose
+// filters allow it. Events of no workspace go to all workspaces.
+func (m *WebSocketManager) publishDebug(workspace, flowID string, severity slog.Level, message []byte) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for client := range m.clients {
+		if workspace != "" && client.workspace != workspace {
+			continue
+		}
+		filter, _ := client.filter.Load().(*debugFilter)
+		if filter == nil || !filter.allows(flowID, severity) {
+			continue
+		}
+		client.offer(message)
+	}
+}
+
+// forwardEvent sends debug outputs and node status changes to the debug
+// clients
+func (m *WebSocketManager) forwardEvent(event engine.Event) {
+	severity := slog.LevelDebug
+	switch event.Type {
+	case engine.EventDebug:
+	case engine.EventNodeStatus:
+		severity = slog.LevelInfo
+	default:
+		return
+	}
+	message, err := debugMessage(event.Type, event)
+	if err != nil {
+		m.logger.Error("failed to marshal debug event", "error", err)
+		return
+	}
+	m.publishDebug(event.Workspace, event.FlowID, severity, message)
+}
+
+// forwardLog sends a warning or error log record to the debug clients. It
+// must not log, as it is called by the engine's loggers.
+func (m *WebSocketManager) forwardLog(record engine.LogRecord) {
+	workspace, flowID := "", ""
+	if record.FlowID != "" {
+		workspace, flowID = engine.SplitFlowKey(record.FlowID)
+	}
+	level, err := engine.ParseLogLevel(record.Level)
+	if err != nil {
+		level = slog.LevelError
+	}
+	// Like events, log messages carry the workspace and ID of their flow
+	payload := struct {
+		engine.LogRecord
+		Workspace string `json:"workspace,omitempty"`
+	}{record, workspace}
+	payload.FlowID = flowID
+	message, err := debugMessage(debugMessageLog, payload)
+	if err != nil {
+		return
+	}
+	m.publishDebug(workspace, flowID, level, message)
+}
+
+// debugMessage marshals a /ws/debug message
+func debugMessage(messageType string, payload interface{}) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s payload: %w", messageType, err)
+	}
+	return json.Marshal(WebSocketMessage{Type: messageType, Payload: data})
+}
+
+// AddDebugWebSocketHandler adds /ws/debug, which pushes debug outputs, node
+// status changes and logged warnings and errors to clients. Clients choose
+// flows and the lowest severity with ?flow= and ?severity=, or with a
+// subscribe message; slow clients lose their oldest events.
+func (s *Server) AddDebugWebSocketHandler() {
+	debugManager := NewWebSocketManager()
+	debugManager.config = s.config
+	debugManager.logger = s.engine.Logger().With("component", "websocket-debug")
+	debugManager.debug = true
+	go debugManager.Run()
+
+	s.router.Handle("/ws/debug", s.authMiddleware(s.workspaceMiddleware(http.HandlerFunc(debugManager.HandleWebSocket))))
+	s.engine.Events().Subscribe(debugManager.forwardEvent)
+	s.engine.OnLog(debugManager.forwardLog)
+	s.debugManager = debugManager
+}
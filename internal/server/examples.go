@@ -0,0 +1,91 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/examples"
+)
+
+// handleListExamples handles GET /api/examples
+func (s *Server) handleListExamples(w http.ResponseWriter, r *http.Request) {
+	list, err := examples.List()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	result := make([]exampleInfo, len(list))
+	for i, example := range list {
+		missing := example.MissingTypes(s.engine.GetRegistry())
+		result[i] = exampleInfo{
+			Name:         example.Name,
+			Title:        example.Title,
+			Description:  example.Description,
+			NodeTypes:    example.NodeTypes,
+			MissingTypes: missing,
+			Installable:  len(missing) == 0,
+		}
+	}
+	respond(w, http.StatusOK, result)
+}
+
+// exampleInfo describes an example flow and whether its node types are
+// installed
+type exampleInfo struct {
+	Name         string   `json:"name"`
+	Title        string   `json:"title"`
+	Description  string   `json:"description"`
+	NodeTypes    []string `json:"nodeTypes"`
+	MissingTypes []string `json:"missingTypes"`
+	Installable  bool     `json:"installable"`
+}
+
+// missingTypesErrorResponse names the node types a flow needs that are not
+// installed
+type missingTypesErrorResponse struct {
+	Error        string   `json:"error"`
+	MissingTypes []string `json:"missingTypes"`
+}
+
+// createdFlow is the response of creating a flow from an example or a
+// template
+type createdFlow struct {
+	ID string `json:"id"`
+}
+
+// handleInstallExample handles POST /api/examples/{name}/install, which
+// deploys a disabled copy of the example
+func (s *Server) handleInstallExample(w http.ResponseWriter, r *http.Request) {
+	example, err := examples.Get(mux.Vars(r)["name"])
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Example not found")
+		return
+	}
+
+	id, def, err := example.Instantiate(s.engine.GetRegistry())
+	var missing *examples.MissingTypesError
+	if errors.As(err, &missing) {
+		respond(w, http.StatusUnprocessableEntity, missingTypesErrorResponse{
+			Error:        err.Error(),
+			MissingTypes: missing.Types,
+		})
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	key := engine.FlowKey(requestWorkspace(r), id)
+	if !s.checkFlowLock(w, r, key) {
+		return
+	}
+	if err := s.engine.DeployFlow(key, def); err != nil {
+		respondDeployError(w, err)
+		return
+	}
+	respond(w, http.StatusCreated, createdFlow{ID: id})
+}
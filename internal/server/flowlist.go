@@ -0,0 +1,237 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// flowSummary describes a flow in the flow list; GET /api/flows/{id}
+// returns its definition
+type flowSummary struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Status      string           `json:"status"`
+	State       engine.FlowState `json:"state"`
+	Disabled    bool             `json:"disabled"`
+	Updated     *time.Time       `json:"updated,omitempty"`
+}
+
+// flowSummaryFields are the fields of flow summaries that ?fields= selects
+var flowSummaryFields = []string{"id", "name", "description", "status", "state", "disabled", "updated"}
+
+// flowPage is the response of GET /api/flows: a page of the flows that
+// match the filters, and how many match
+type flowPage struct {
+	Flows  []flowSummary `json:"flows"`
+	Total  int           `json:"total"`
+	Limit  int           `json:"limit,omitempty"`
+	Offset int           `json:"offset"`
+}
+
+// flowFieldsPage is a flowPage with only the fields ?fields= selects
+type flowFieldsPage struct {
+	Flows  []map[string]json.RawMessage `json:"flows"`
+	Total  int                          `json:"total"`
+	Limit  int                          `json:"limit,omitempty"`
+	Offset int                          `json:"offset"`
+}
+
+// flowOrders compare flow summaries by the keys ?sort= takes; ties are
+// ordered by ID
+var flowOrders = map[string]func(a, b *flowSummary) int{
+	"id": func(a, b *flowSummary) int { return 0 },
+	"name": func(a, b *flowSummary) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	},
+	"status": func(a, b *flowSummary) int { return strings.Compare(a.Status, b.Status) },
+	"updated": func(a, b *flowSummary) int {
+		var at, bt time.Time
+		if a.Updated != nil {
+			at = *a.Updated
+		}
+		if b.Updated != nil {
+			bt = *b.Updated
+		}
+		return at.Compare(bt)
+	},
+}
+
+// handleListFlows handles GET /api/flows, which lists summaries of the
+// flows of the workspace by ID, or with ?state=quarantined the flows that
+// failed to load. status selects flows by status, a comma-separated list,
+// and q by a substring of their name; sort orders them by id, name, status
+// or updated, descending with a leading "-"; limit and offset select a
+// page and fields the fields of the summaries.
+func (s *Server) handleListFlows(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	switch query.Get("state") {
+	case "":
+	case "quarantined":
+		s.handleListQuarantinedFlows(w, r)
+		return
+	default:
+		respondError(w, http.StatusBadRequest, "Unknown flow state")
+		return
+	}
+
+	fields, err := flowFields(query.Get("fields"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	compare, err := flowOrder(query.Get("sort"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, offset, err := pageParams(query)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	statuses := make(map[string]bool)
+	for _, status := range strings.Split(query.Get("status"), ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			statuses[status] = true
+		}
+	}
+	search := strings.ToLower(query.Get("q"))
+
+	workspace := requestWorkspace(r)
+	flows := make([]flowSummary, 0)
+	for _, id := range s.engine.WorkspaceFlows(workspace) {
+		flow, exists := s.engine.GetFlow(engine.FlowKey(workspace, id))
+		if !exists {
+			continue
+		}
+		summary := newFlowSummary(id, flow)
+		if len(statuses) > 0 && !statuses[summary.Status] {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(summary.Name), search) {
+			continue
+		}
+		flows = append(flows, summary)
+	}
+	sort.SliceStable(flows, func(i, j int) bool {
+		return compare(&flows[i], &flows[j]) < 0
+	})
+
+	total := len(flows)
+	if offset > len(flows) {
+		offset = len(flows)
+	}
+	flows = flows[offset:]
+	if limit > 0 && len(flows) > limit {
+		flows = flows[:limit]
+	}
+	if fields == nil {
+		respond(w, http.StatusOK, flowPage{Flows: flows, Total: total, Limit: limit, Offset: offset})
+		return
+	}
+
+	page := flowFieldsPage{Flows: make([]map[string]json.RawMessage, len(flows)), Total: total, Limit: limit, Offset: offset}
+	for i, summary := range flows {
+		data, err := json.Marshal(summary)
+		var all map[string]json.RawMessage
+		if err == nil {
+			err = json.Unmarshal(data, &all)
+		}
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to marshal flow")
+			return
+		}
+		page.Flows[i] = make(map[string]json.RawMessage, len(fields))
+		for _, field := range fields {
+			if value, ok := all[field]; ok {
+				page.Flows[i][field] = value
+			}
+		}
+	}
+	respond(w, http.StatusOK, page)
+}
+
+// newFlowSummary describes a flow of the flow list
+func newFlowSummary(id string, flow *engine.Flow) flowSummary {
+	summary := flowSummary{
+		ID:       id,
+		Status:   string(flow.GetStatus()),
+		State:    flow.State(),
+		Disabled: flow.Disabled(),
+	}
+	summary.Name, summary.Description = flow.Describe()
+	if updated := flow.Updated(); !updated.IsZero() {
+		summary.Updated = &updated
+	}
+	return summary
+}
+
+// flowFields parses the comma-separated fields of ?fields=, or returns nil
+// for all fields
+func flowFields(list string) ([]string, error) {
+	if list == "" {
+		return nil, nil
+	}
+	var fields []string
+	for _, field := range strings.Split(list, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		known := false
+		for _, name := range flowSummaryFields {
+			known = known || name == field
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown field %q; flows have %s", field, strings.Join(flowSummaryFields, ", "))
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// flowOrder returns the comparison of ?sort=, a key of flowOrders with an
+// optional leading "-" for descending order
+func flowOrder(key string) (func(a, b *flowSummary) int, error) {
+	if key == "" {
+		key = "id"
+	}
+	descending := strings.HasPrefix(key, "-")
+	compare, ok := flowOrders[strings.TrimPrefix(key, "-")]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort key %q", key)
+	}
+	return func(a, b *flowSummary) int {
+		result := compare(a, b)
+		if result == 0 {
+			result = strings.Compare(a.ID, b.ID)
+		}
+		if descending {
+			return -result
+		}
+		return result
+	}, nil
+}
+
+// pageParams parses the limit and offset query parameters of a list; a
+// limit of 0 is no limit
+func pageParams(query url.Values) (limit, offset int, err error) {
+	for name, target := range map[string]*int{"limit": &limit, "offset": &offset} {
+		if value := query.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return 0, 0, fmt.Errorf("invalid %s %q", name, value)
+			}
+			*target = n
+		}
+	}
+	return limit, offset, nil
+}
@@ -2,9 +2,9 @@ package server
 
 import (
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -72,10 +72,14 @@ func (h *WebUIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func (s *Server) AddWebSocketHandler() {
 	// Create WebSocket manager
 	wsManager := NewWebSocketManager()
+	wsManager.locks = s.engine.FlowLocks()
+	wsManager.config = s.config
+	wsManager.logger = s.engine.Logger().With("component", "websocket")
 	go wsManager.Run()
 	
-	// Add WebSocket route
-	s.router.HandleFunc("/ws", wsManager.HandleWebSocket)
+	// Add WebSocket route; clients receive the events of the workspace
+	// they name with ?workspace=
+	s.router.Handle("/ws", s.authMiddleware(s.workspaceMiddleware(http.HandlerFunc(wsManager.HandleWebSocket))))
 	
 	// Store manager for other handlers to use
 	s.wsManager = wsManager
@@ -83,16 +87,28 @@ func (s *Server) AddWebSocketHandler() {
 
 // AddDebugConsoleHandler adds the debug console handler
 func (s *Server) AddDebugConsoleHandler() {
-	// Create a new router for /debug path
+	// Create a new router for /debug path; its pages show flow
+	// definitions, so they need a token like the API
 	debugRouter := s.router.PathPrefix("/debug").Subrouter()
+	debugRouter.Use(s.authMiddleware)
 	
 	// Add handlers for debug console
 	debugRouter.HandleFunc("/", s.handleDebugHome)
 	debugRouter.HandleFunc("/flows", s.handleDebugFlows)
-	debugRouter.HandleFunc("/flows/{id}", s.handleDebugFlow)
+	debugRouter.HandleFunc("/flows/{id:.+}", s.handleDebugFlow)
 	debugRouter.HandleFunc("/console", s.handleDebugConsole)
 }
 
+// debugLink returns the URL of a debug console page, passing on the token
+// of the request if it came in the access_token query parameter
+func (s *Server) debugLink(r *http.Request, path string) string {
+	link := s.basePath + path
+	if token := r.URL.Query().Get("access_token"); token != "" {
+		link += "?access_token=" + url.QueryEscape(token)
+	}
+	return link
+}
+
 // handleDebugHome handles the debug home page
 func (s *Server) handleDebugHome(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html")
@@ -113,8 +129,8 @@ func (s *Server) handleDebugHome(w http.ResponseWriter, r *http.Request) {
 		<body>
 			<h1>go-red Debug Console</h1>
 			<ul>
-				<li><a href="/debug/flows">Flows</a></li>
-				<li><a href="/debug/console">Debug Console</a></li>
+				<li><a href="` + s.debugLink(r, "/debug/flows") + `">Flows</a></li>
+				<li><a href="` + s.debugLink(r, "/debug/console") + `">Debug Console</a></li>
 			</ul>
 		</body>
 		</html>
@@ -154,12 +170,12 @@ func (s *Server) handleDebugFlows(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 		
-		html += `<li><a href="/debug/flows/` + id + `">` + id + `</a> - Status: ` + string(flow.GetStatus()) + `</li>`
+		html += `<li><a href="` + s.debugLink(r, "/debug/flows/"+id) + `">` + id + `</a> - Status: ` + string(flow.GetStatus()) + `</li>`
 	}
 	
 	html += `
 			</ul>
-			<p><a href="/debug/">Back to Debug Home</a></p>
+			<p><a href="` + s.debugLink(r, "/debug/") + `">Back to Debug Home</a></p>
 		</body>
 		</html>
 	`
@@ -179,7 +195,7 @@ func (s *Server) handleDebugFlow(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	// Get flow JSON
-	flowJSON, err := flow.ToJSON()
+	flowJSON, err := flow.PublicJSON()
 	if err != nil {
 		http.Error(w, "Failed to get flow JSON", http.StatusInternalServerError)
 		return
@@ -205,12 +221,12 @@ func (s *Server) handleDebugFlow(w http.ResponseWriter, r *http.Request) {
 			<h1>Flow: ` + id + `</h1>
 			<p>Status: ` + string(flow.GetStatus()) + `</p>
 			<div>
-				<a href="/api/flows/` + id + `/start" class="button">Start Flow</a>
-				<a href="/api/flows/` + id + `/stop" class="button">Stop Flow</a>
+				<a href="` + s.basePath + `/api/flows/` + id + `/start" class="button">Start Flow</a>
+				<a href="` + s.basePath + `/api/flows/` + id + `/stop" class="button">Stop Flow</a>
 			</div>
 			<h2>Flow Definition</h2>
 			<pre>` + string(flowJSON) + `</pre>
-			<p><a href="/debug/flows">Back to Flows</a></p>
+			<p><a href="` + s.debugLink(r, "/debug/flows") + `">Back to Flows</a></p>
 		</body>
 		</html>
 	`
@@ -242,8 +258,13 @@ func (s *Server) handleDebugConsole(w http.ResponseWriter, r *http.Request) {
 				let ws;
 				
 				function connect() {
-					const host = window.location.host;
-					ws = new WebSocket('ws://' + host + '/ws');
+					const scheme = window.location.protocol === 'https:' ? 'wss://' : 'ws://';
+					const token = new URLSearchParams(window.location.search).get('access_token');
+					let url = scheme + window.location.host + '` + s.basePath + `/ws';
+					if (token) {
+						url += '?access_token=' + encodeURIComponent(token);
+					}
+					ws = new WebSocket(url);
 					
 					ws.onopen = function() {
 						addMessage('Connected to server', 'info');
@@ -283,7 +304,7 @@ func (s *Server) handleDebugConsole(w http.ResponseWriter, r *http.Request) {
 		<body>
 			<h1>Debug Console</h1>
 			<div id="console"></div>
-			<p><a href="/debug/">Back to Debug Home</a></p>
+			<p><a href="` + s.debugLink(r, "/debug/") + `">Back to Debug Home</a></p>
 		</body>
 		</html>
 	`
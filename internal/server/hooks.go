@@ -0,0 +1,22 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// handleListHooks handles GET /api/hooks, listing the engine's message
+// hooks with their call counts and latencies
+func (s *Server) handleListHooks(w http.ResponseWriter, r *http.Request) {
+	stats := s.engine.Hooks().Stats()
+	if stats == nil {
+		stats = []engine.HookStats{}
+	}
+	respond(w, http.StatusOK, hookList{Hooks: stats})
+}
+
+// hookList is the response of GET /api/hooks
+type hookList struct {
+	Hooks []engine.HookStats `json:"hooks"`
+}
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/nodered"
+)
+
+// importResult is the outcome of importing one flow
+type importResult struct {
+	ID           string                   `json:"id"`
+	Success      bool                     `json:"success"`
+	Error        string                   `json:"error,omitempty"`
+	Issues       []engine.ValidationIssue `json:"issues,omitempty"`
+	MissingTypes []string                 `json:"missingTypes,omitempty"`
+}
+
+// handleExportFlows handles GET /api/flows/export, which returns all flows
+// of the workspace as a JSON array of flow definitions, or as a Node-RED
+// export with ?format=node-red. Credentials are left out.
+func (s *Server) handleExportFlows(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "go-red" && format != "node-red" {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Unknown format: %s", format))
+		return
+	}
+
+	flows := make([]json.RawMessage, 0)
+	for _, key := range s.engine.WorkspaceFlows(requestWorkspace(r)) {
+		flow, exists := s.engine.GetFlow(key)
+		if !exists {
+			continue
+		}
+		flowDef, err := flow.PublicJSON()
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to marshal flow %s", flow.ID))
+			return
+		}
+		flows = append(flows, flowDef)
+	}
+
+	if format != "node-red" {
+		respond(w, http.StatusOK, flows)
+		return
+	}
+	defs := make([]engine.FlowDefinition, len(flows))
+	for i, flowDef := range flows {
+		if err := json.Unmarshal(flowDef, &defs[i]); err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to unmarshal flow")
+			return
+		}
+	}
+	export, err := nodered.FromFlows(defs)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to export flows: %v", err))
+		return
+	}
+	respond(w, http.StatusOK, json.RawMessage(export))
+}
+
+// handleImportFlows handles POST /api/flows/import, which deploys a JSON
+// array of flow definitions, or a Node-RED export, which is detected or
+// selected with ?format=node-red. With ?mode=replace the workspace flows
+// that are not imported are deleted; the default mode, merge, keeps them.
+// Each flow is checked like a dry run before it is deployed, so that a
+// flow is either deployed as a whole or left as it was, whatever happens
+// to the others.
+func (s *Server) handleImportFlows(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	mode := query.Get("mode")
+	if mode == "" {
+		mode = "merge"
+	}
+	if mode != "merge" && mode != "replace" {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Unknown import mode: %s", mode))
+		return
+	}
+	format := query.Get("format")
+	if format != "" && format != "go-red" && format != "node-red" {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Unknown format: %s", format))
+		return
+	}
+	force, ok := s.forced(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+	if format == "" && nodered.IsNodeRED(data) {
+		format = "node-red"
+	}
+
+	var flows []json.RawMessage
+	var warnings []string
+	if format == "node-red" {
+		defs, translated, err := nodered.ToFlows(data)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		warnings = translated
+		for _, def := range defs {
+			flowDef, err := json.Marshal(def)
+			if err != nil {
+				respondError(w, http.StatusInternalServerError, "Failed to marshal flow definition")
+				return
+			}
+			flows = append(flows, flowDef)
+		}
+	} else if err := engine.DecodeJSON(data, &flows); err != nil {
+		respondError(w, http.StatusBadRequest, "Expected an array of flow definitions")
+		return
+	}
+
+	workspace := requestWorkspace(r)
+	user := requestUser(r)
+	results := make([]importResult, 0, len(flows))
+	imported := make(map[string]bool)
+	success := true
+	for i, flowDef := range flows {
+		result := s.importFlow(workspace, user, force, i, flowDef)
+		if result.Success {
+			imported[result.ID] = true
+		} else {
+			success = false
+		}
+		results = append(results, result)
+	}
+
+	deleted := make([]string, 0)
+	if mode == "replace" {
+		// Flows that failed to import are kept as they were, not deleted
+		for _, result := range results {
+			imported[result.ID] = true
+		}
+		for _, key := range s.engine.WorkspaceFlows(workspace) {
+			_, id := engine.SplitFlowKey(key)
+			if imported[id] {
+				continue
+			}
+			if !force {
+				if err := s.engine.FlowLocks().Check(key, user); err != nil {
+					warnings = append(warnings, fmt.Sprintf("flow %s was not deleted: %v", id, err))
+					success = false
+					continue
+				}
+			}
+			if err := s.engine.DeleteFlow(key); err != nil {
+				warnings = append(warnings, fmt.Sprintf("flow %s was not deleted: %v", id, err))
+				success = false
+				continue
+			}
+			if err := s.engine.FlowLocks().Release(key, user, true); err != nil {
+				s.engine.Logger().Warn("failed to release lock of deleted flow", "flow_id", key, "error", err)
+			}
+			deleted = append(deleted, id)
+		}
+	}
+
+	if warnings == nil {
+		warnings = []string{}
+	}
+	respond(w, http.StatusOK, importReport{
+		Success:  success,
+		Flows:    results,
+		Deleted:  deleted,
+		Warnings: warnings,
+	})
+}
+
+// importReport is the response of POST /api/flows/import
+type importReport struct {
+	Success  bool           `json:"success"`
+	Flows    []importResult `json:"flows"`
+	Deleted  []string       `json:"deleted"`
+	Warnings []string       `json:"warnings"`
+}
+
+// importFlow deploys the i-th flow of an import into the workspace
+func (s *Server) importFlow(workspace, user string, force bool, i int, flowDef json.RawMessage) importResult {
+	var flow map[string]interface{}
+	if err := engine.DecodeJSON(flowDef, &flow); err != nil {
+		return importResult{ID: fmt.Sprintf("flows[%d]", i), Error: "Invalid flow definition"}
+	}
+	id, _ := flow["id"].(string)
+	if id == "" {
+		id = fmt.Sprintf("flow-%d-%d", time.Now().UnixNano(), i)
+		flow["id"] = id
+	}
+	result := importResult{ID: id}
+	// Slashes separate the workspace from the ID in flow keys
+	if strings.Contains(id, "/") {
+		result.Error = "Flow ID must not contain '/'"
+		return result
+	}
+	flowDef, err := json.Marshal(flow)
+	if err != nil {
+		result.Error = "Failed to marshal flow definition"
+		return result
+	}
+
+	key := engine.FlowKey(workspace, id)
+	if !force {
+		if err := s.engine.FlowLocks().Check(key, user); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	}
+	if report := s.engine.DryRunFlow(key, flowDef); !report.Valid {
+		result.Error = "Invalid flow definition"
+		result.Issues = report.Errors
+		return result
+	}
+	if err := s.engine.DeployFlow(key, flowDef); err != nil {
+		result.Error = fmt.Sprintf("Failed to deploy flow: %v", err)
+		return result
+	}
+
+	result.Success = true
+	if flow, exists := s.engine.GetFlow(key); exists {
+		result.MissingTypes = flow.MissingTypes()
+	}
+	return result
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// deviceNode is a node type without behaviour, for flows that are only
+// stored and read back
+type deviceNode struct {
+	engine.BaseNode
+}
+
+func (n *deviceNode) Init(json.RawMessage) error                    { return nil }
+func (n *deviceNode) Start(ctx context.Context) error               { return nil }
+func (n *deviceNode) Stop()                                         {}
+func (n *deviceNode) OnMessage(msg *engine.Message, port int) error { return nil }
+
+func TestFlowLargeIntegers(t *testing.T) {
+	s := newTestServer(t, nil, &engine.NodeType{
+		Name: "device",
+		// Stripping credentials decodes and encodes the config again
+		Credentials: []string{"token"},
+		Factory:     func() engine.NodeInstance { return &deviceNode{} },
+	})
+	flowDef := `{
+		"id": "devices",
+		"nodes": [{"id": "d", "type": "device", "config": {
+			"deviceId": 9007199254740993,
+			"offsets": [9223372036854775807, -9223372036854775808],
+			"serial": 18446744073709551615,
+			"token": "secret"
+		}}]
+	}`
+	want := []string{`9007199254740993`, `[9223372036854775807,-9223372036854775808]`, `18446744073709551615`}
+
+	tests := []struct {
+		method, path, body string
+		wantStatus         int
+	}{
+		{http.MethodPost, "/api/flows", flowDef, http.StatusCreated},
+		{http.MethodGet, "/api/flows/devices", "", http.StatusOK},
+		{http.MethodGet, "/api/flows/export", "", http.StatusOK},
+		{http.MethodPut, "/api/flows/devices?overwrite=true", flowDef, http.StatusOK},
+		{http.MethodGet, "/api/flows/devices", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		if rec.Code != tt.wantStatus {
+			t.Fatalf("%s %s: status %d, want %d: %s", tt.method, tt.path, rec.Code, tt.wantStatus, rec.Body)
+		}
+		if tt.method != http.MethodGet {
+			continue
+		}
+		body := strings.ReplaceAll(rec.Body.String(), " ", "")
+		for _, number := range want {
+			if !strings.Contains(body, number) {
+				t.Errorf("%s %s: %s missing from %s", tt.method, tt.path, number, body)
+			}
+		}
+		if strings.Contains(body, "secret") {
+			t.Errorf("%s %s: credential exposed", tt.method, tt.path)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// handleGetLibrary handles GET /api/library/{type} and
+// /api/library/{type}/{path}. A path naming an entry returns the entry with
+// its body; otherwise the entries below the path are listed with their
+// metadata, without bodies.
+func (s *Server) handleGetLibrary(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	libraryType, path := vars["type"], vars["path"]
+	if path != "" {
+		entry, body, err := s.engine.LibraryEntry(libraryType, path)
+		if err == nil {
+			respond(w, http.StatusOK, libraryEntry{LibraryEntry: entry, Body: body})
+			return
+		}
+		if !errors.Is(err, storage.ErrLibraryEntryNotFound) {
+			respondLibraryError(w, err)
+			return
+		}
+	}
+
+	entries, err := s.engine.LibraryEntries(libraryType, path)
+	if err != nil {
+		respondLibraryError(w, err)
+		return
+	}
+	if path != "" && len(entries) == 0 {
+		respondError(w, http.StatusNotFound, "Library entry not found")
+		return
+	}
+	respond(w, http.StatusOK, libraryList{Entries: entries})
+}
+
+// libraryEntry is a library entry with its body
+type libraryEntry struct {
+	storage.LibraryEntry
+	Body json.RawMessage `json:"body"`
+}
+
+// libraryList lists library entries without their bodies
+type libraryList struct {
+	Entries []storage.LibraryEntry `json:"entries"`
+}
+
+// handleSaveLibrary handles POST /api/library/{type}/{path}, which saves
+// {"name", "description", "body"} as a library entry, replacing any entry
+// at the path. The body is any JSON, such as the source of a function as a
+// string or a flow fragment.
+func (s *Server) handleSaveLibrary(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	var request librarySaveRequest
+	if err := engine.ReadJSON(r.Body, &request); err != nil || len(request.Body) == 0 {
+		respondError(w, http.StatusBadRequest, "Expected a library entry with a body")
+		return
+	}
+
+	entry, err := s.engine.SaveLibraryEntry(storage.LibraryEntry{
+		Type:        vars["type"],
+		Path:        vars["path"],
+		Name:        request.Name,
+		Description: request.Description,
+	}, request.Body)
+	if err != nil {
+		respondLibraryError(w, err)
+		return
+	}
+	respond(w, http.StatusOK, entry)
+}
+
+// librarySaveRequest is the body of POST /api/library/{type}/{path}
+type librarySaveRequest struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Body        json.RawMessage `json:"body"`
+}
+
+// respondLibraryError sends the error of a library request; invalid types
+// and paths are the client's fault
+func respondLibraryError(w http.ResponseWriter, err error) {
+	if errors.Is(err, engine.ErrInvalidLibraryPath) {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondError(w, http.StatusInternalServerError, fmt.Sprintf("Library error: %v", err))
+}
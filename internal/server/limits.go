@@ -0,0 +1,231 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-red/internal/config"
+)
+
+// Reasons of rejected requests, the reason label of
+// gored_http_rejected_total
+const (
+	rejectRateLimit = "rate_limit"
+	rejectBodySize  = "body_size"
+)
+
+// rateLimitSweep is how often buckets that have refilled are dropped
+const rateLimitSweep = time.Minute
+
+// rateLimiter keeps a token bucket per client of the mutating API routes.
+// Clients are authenticated users, or else remote IP addresses.
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+	swept   time.Time
+}
+
+// rateBucket is the token bucket of a client
+type rateBucket struct {
+	tokens float64
+	filled time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[string]*rateBucket), swept: time.Now()}
+}
+
+// allow takes a token from the bucket of a client, which refills at rps
+// tokens a second up to burst. If the bucket is empty it returns how long
+// until it holds a token.
+func (l *rateLimiter) allow(client string, rps float64, burst int) (bool, time.Duration) {
+	max := float64(burst)
+	if max < 1 {
+		max = 1
+	}
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.swept) > rateLimitSweep {
+		l.sweep(now, rps, max)
+	}
+	bucket, ok := l.buckets[client]
+	if !ok {
+		bucket = &rateBucket{tokens: max}
+		l.buckets[client] = bucket
+	} else {
+		bucket.tokens += now.Sub(bucket.filled).Seconds() * rps
+	}
+	if bucket.tokens > max {
+		bucket.tokens = max
+	}
+	bucket.filled = now
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / rps * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// sweep drops the buckets that have refilled, as new buckets start full
+func (l *rateLimiter) sweep(now time.Time, rps, max float64) {
+	for client, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.filled).Seconds()*rps >= max {
+			delete(l.buckets, client)
+		}
+	}
+	l.swept = now
+}
+
+// rateLimitClient names the client of a request for rate limiting: its
+// authenticated user, or else its remote IP address
+func rateLimitClient(r *http.Request) string {
+	if identity, ok := RequestIdentity(r); ok {
+		return "user:" + identity.User
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// limitMiddleware rejects mutating requests of clients exceeding
+// api.rateLimit.rps with 429, and mutating requests with bodies larger
+// than api.maxBodySize bytes with 413. Backups sent to /api/restore are
+// limited by api.maxRestoreSize instead, 256 MB by default. The others are
+// disabled by default; as they are read for every request, changing them
+// applies at once.
+func (s *Server) limitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isMutating(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if rps := config.GetOrDefault(s.config, "api.rateLimit.rps", 0.0); rps > 0 {
+			burst := config.GetOrDefault(s.config, "api.rateLimit.burst", int(math.Ceil(rps)))
+			if ok, wait := s.limiter.allow(rateLimitClient(r), rps, burst); !ok {
+				s.countRejected(rejectRateLimit)
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				respondError(w, http.StatusTooManyRequests, "Too many requests")
+				return
+			}
+		}
+
+		limit := s.bodyLimit(r)
+		if limit <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.ContentLength > limit {
+			s.rejectBody(w, limit)
+			return
+		}
+		// The body is cut off while the handler reads it, which is then
+		// answered with 413 whatever it responds
+		body := &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, limit)}
+		r.Body = body
+		next.ServeHTTP(&limitedWriter{ResponseWriter: w, server: s, body: body, limit: limit}, r)
+	})
+}
+
+// bodyLimit returns the limit of a request's body in bytes, or 0 if it has
+// none
+func (s *Server) bodyLimit(r *http.Request) int64 {
+	if strings.HasSuffix(r.URL.Path, "/api/restore") {
+		return int64(config.GetOrDefault(s.config, "api.maxRestoreSize", maxRestoreSize))
+	}
+	return int64(config.GetOrDefault(s.config, "api.maxBodySize", 0))
+}
+
+// rejectBody answers a request whose body exceeds limit with 413
+func (s *Server) rejectBody(w http.ResponseWriter, limit int64) {
+	s.countRejected(rejectBodySize)
+	respondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body exceeds %d bytes", limit))
+}
+
+// limitedBody is a request body cut off by http.MaxBytesReader, which
+// records whether it was
+type limitedBody struct {
+	io.ReadCloser
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// limitedWriter answers with 413 instead of the handler once the handler
+// read beyond the limit of its body, unless it already responded
+type limitedWriter struct {
+	http.ResponseWriter
+	server   *Server
+	body     *limitedBody
+	limit    int64
+	written  bool
+	rejected bool
+}
+
+func (w *limitedWriter) WriteHeader(status int) {
+	if w.reject() {
+		return
+	}
+	w.written = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.reject() {
+		return len(p), nil
+	}
+	w.written = true
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush lets streamed responses through
+func (w *limitedWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *limitedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// reject reports whether the handler's response is dropped, answering
+// with 413 the first time
+func (w *limitedWriter) reject() bool {
+	if w.rejected {
+		return true
+	}
+	if w.written || !w.body.exceeded {
+		return false
+	}
+	w.rejected = true
+	w.server.rejectBody(w.ResponseWriter, w.limit)
+	return true
+}
+
+// countRejected counts a request rejected by limitMiddleware
+func (s *Server) countRejected(reason string) {
+	if s.metrics != nil {
+		s.metrics.rejected.Inc(reason)
+	}
+}
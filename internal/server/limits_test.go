@@ -0,0 +1,125 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serve sends a request with a body to a server and returns the response
+func serve(s *Server, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "192.0.2.1:1234"
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMaxBodySize(t *testing.T) {
+	s := newTestServer(t, map[string]interface{}{"api.maxBodySize": 64})
+	large := `{"id": "f", "description": "` + strings.Repeat("x", 64) + `"}`
+
+	tests := []struct{ method, path string }{
+		{http.MethodPost, "/api/flows"},
+		{http.MethodPut, "/api/flows/f"},
+		{http.MethodPost, "/api/flows/import"},
+		{http.MethodPost, "/api/flows/validate"},
+		{http.MethodPost, "/api/flows/f/versions/1/restore"},
+		{http.MethodPatch, "/api/flows/f/wires"},
+		{http.MethodPut, "/api/settings"},
+		{http.MethodPost, "/api/library/flows/shared"},
+		{http.MethodPost, "/api/workspaces"},
+		{http.MethodPost, "/api/workspaces/default/flows"},
+		{http.MethodPost, "/auth/token"},
+	}
+	for _, tt := range tests {
+		if rec := serve(s, tt.method, tt.path, large); rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s %s with a large body: status %d, want %d", tt.method, tt.path, rec.Code, http.StatusRequestEntityTooLarge)
+		}
+		if rec := serve(s, tt.method, tt.path, `{}`); rec.Code == http.StatusRequestEntityTooLarge {
+			t.Errorf("%s %s with a small body: status %d", tt.method, tt.path, rec.Code)
+		}
+	}
+}
+
+func TestMaxRestoreSize(t *testing.T) {
+	s := newTestServer(t, map[string]interface{}{"api.maxBodySize": 64})
+	rec := serve(s, http.MethodGet, "/api/backup", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("backup: status %d: %s", rec.Code, rec.Body)
+	}
+	archive := rec.Body.String()
+	if len(archive) <= 64 {
+		t.Fatalf("backup of %d bytes is within the flow limit", len(archive))
+	}
+
+	// Backups are limited apart from flows
+	if rec := serve(s, http.MethodPost, "/api/restore", archive); rec.Code != http.StatusOK {
+		t.Errorf("restore with a small flow limit: status %d: %s", rec.Code, rec.Body)
+	}
+	s.config.Set("api.maxRestoreSize", 64)
+	if rec := serve(s, http.MethodPost, "/api/restore", archive); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("restore beyond api.maxRestoreSize: status %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestMaxBodySizeUnknownLength(t *testing.T) {
+	s := newTestServer(t, map[string]interface{}{"api.maxBodySize": 64})
+	large := `{"id": "f", "description": "` + strings.Repeat("x", 64) + `"}`
+
+	// Without a Content-Length the body is cut off while it is read
+	req := httptest.NewRequest(http.MethodPost, "/api/flows", io.MultiReader(strings.NewReader(large)))
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status %d, want %d: %s", rec.Code, http.StatusRequestEntityTooLarge, rec.Body)
+	}
+	var response struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil || response.Error == "" {
+		t.Errorf("413 without a JSON error: %v", err)
+	}
+}
+
+func TestTokenRateLimit(t *testing.T) {
+	s := newTestServer(t, map[string]interface{}{"api.rateLimit.rps": 0.001, "api.rateLimit.burst": 2})
+	credentials := `{"username": "admin", "password": "guess"}`
+
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		rec := serve(s, http.MethodPost, "/auth/token", credentials)
+		if rec.Code != want {
+			t.Fatalf("request %d: status %d, want %d", i+1, rec.Code, want)
+		}
+		if want == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+			t.Error("no Retry-After header")
+		}
+	}
+
+	// The refused request is audited like the others
+	rec := serve(s, http.MethodGet, "/api/audit", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("audit log: status %d: %s", rec.Code, rec.Body)
+	}
+	var page struct {
+		Entries []struct {
+			Action string `json:"action"`
+			Status int    `json:"status"`
+		} `json:"entries"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Entries) != 3 {
+		t.Fatalf("%d audit entries, want 3", len(page.Entries))
+	}
+	if entry := page.Entries[0]; entry.Action != "auth.token" || entry.Status != http.StatusTooManyRequests {
+		t.Errorf("latest audit entry is %s with status %d, want auth.token with status %d", entry.Action, entry.Status, http.StatusTooManyRequests)
+	}
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// userHeader identifies the user making a request, like the userId query
+// parameter of WebSocket connections
+const userHeader = "X-User-ID"
+
+// requestUser returns the user making a request: the user of its token if
+// authentication is enabled, else the one it names
+func requestUser(r *http.Request) string {
+	if identity, ok := RequestIdentity(r); ok {
+		return identity.User
+	}
+	if user := r.Header.Get(userHeader); user != "" {
+		return user
+	}
+	return r.URL.Query().Get("userId")
+}
+
+// isAdmin reports whether user is listed in the editor.admins setting or in
+// the workspaces.<name>.admins setting of the workspace, comma-separated
+// lists of users, or has the admin role when authentication is enabled
+func (s *Server) isAdmin(workspace, user string) bool {
+	if user == "" {
+		return false
+	}
+	if s.config.GetBool("api.auth.enabled") && s.auth.role(user) == RoleAdmin {
+		return true
+	}
+	admins := append(s.configList("editor.admins"), s.configList("workspaces."+workspace+".admins")...)
+	for _, admin := range admins {
+		if admin == user {
+			return true
+		}
+	}
+	return false
+}
+
+// forced reports whether the request asks to override flow locks. Only
+// admins may; others are answered with 403 Forbidden.
+func (s *Server) forced(w http.ResponseWriter, r *http.Request) (force bool, ok bool) {
+	if r.URL.Query().Get("force") != "true" {
+		return false, true
+	}
+	if !s.isAdmin(requestWorkspace(r), requestUser(r)) {
+		respondError(w, http.StatusForbidden, "Only admins may force flow locks")
+		return false, false
+	}
+	return true, true
+}
+
+// checkFlowLock answers with 423 Locked if another user holds the lock on
+// the flow being changed, unless an admin forces the change
+func (s *Server) checkFlowLock(w http.ResponseWriter, r *http.Request, key string) bool {
+	return s.checkFlowLocks(w, r, []string{key})
+}
+
+// checkFlowLocks is checkFlowLock for requests that change several flows
+func (s *Server) checkFlowLocks(w http.ResponseWriter, r *http.Request, keys []string) bool {
+	force, ok := s.forced(w, r)
+	if !ok || force {
+		return ok
+	}
+	user := requestUser(r)
+	for _, key := range keys {
+		if err := s.engine.FlowLocks().Check(key, user); err != nil {
+			respondLockError(w, err)
+			return false
+		}
+	}
+	return true
+}
+
+// handleAcquireFlowLock handles POST /api/flows/{id}/lock
+func (s *Server) handleAcquireFlowLock(w http.ResponseWriter, r *http.Request) {
+	key := flowKey(r)
+	user := requestUser(r)
+	if user == "" {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("The %s header is required", userHeader))
+		return
+	}
+	if _, exists := s.engine.GetFlow(key); !exists {
+		respondError(w, http.StatusNotFound, "Flow not found")
+		return
+	}
+
+	lock, err := s.engine.FlowLocks().Acquire(key, user)
+	if err != nil {
+		respondLockError(w, err)
+		return
+	}
+	respond(w, http.StatusOK, lockInfo(lock))
+}
+
+// handleGetFlowLock handles GET /api/flows/{id}/lock
+func (s *Server) handleGetFlowLock(w http.ResponseWriter, r *http.Request) {
+	lock, locked, err := s.engine.FlowLocks().Get(flowKey(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !locked {
+		respond(w, http.StatusOK, lockStatus{Locked: false})
+		return
+	}
+	info := lockInfo(lock)
+	respond(w, http.StatusOK, lockStatus{Locked: true, Lock: &info})
+}
+
+// lockStatus is the response of GET /api/flows/{id}/lock
+type lockStatus struct {
+	Locked bool              `json:"locked"`
+	Lock   *storage.FlowLock `json:"lock,omitempty"`
+}
+
+// handleReleaseFlowLock handles DELETE /api/flows/{id}/lock; admins may
+// remove the locks of others with ?force=true
+func (s *Server) handleReleaseFlowLock(w http.ResponseWriter, r *http.Request) {
+	force, ok := s.forced(w, r)
+	if !ok {
+		return
+	}
+	if err := s.engine.FlowLocks().Release(flowKey(r), requestUser(r), force); err != nil {
+		respondLockError(w, err)
+		return
+	}
+	respond(w, http.StatusOK, successResponse{Success: true})
+}
+
+// respondLockError sends the error of a lock operation, naming the holder
+// of a lock that is in the way
+func respondLockError(w http.ResponseWriter, err error) {
+	var locked *engine.FlowLockedError
+	if !errors.As(err, &locked) {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respond(w, http.StatusLocked, lockedErrorResponse{
+		Error: "Flow is locked by another user",
+		Lock:  lockInfo(locked.Lock),
+	})
+}
+
+// lockedErrorResponse names the holder of a lock that is in the way
+type lockedErrorResponse struct {
+	Error string           `json:"error"`
+	Lock  storage.FlowLock `json:"lock"`
+}
+
+// lockInfo returns a lock for API responses, which name flows by their ID
+// within the workspace
+func lockInfo(lock storage.FlowLock) storage.FlowLock {
+	_, lock.FlowID = engine.SplitFlowKey(lock.FlowID)
+	return lock
+}
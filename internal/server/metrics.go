@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// flowMetrics is a flow's status with the resources it uses
+type flowMetrics struct {
+	ID     string            `json:"id"`
+	Status engine.FlowStatus `json:"status"`
+	engine.FlowUsage
+}
+
+// handleGetFlowMetrics handles GET /api/flows/{id}/metrics, reporting the
+// resources a flow uses and its limits
+func (s *Server) handleGetFlowMetrics(w http.ResponseWriter, r *http.Request) {
+	flow, exists := s.engine.GetFlow(flowKey(r))
+	if !exists {
+		respondError(w, http.StatusNotFound, "Flow not found")
+		return
+	}
+	respond(w, http.StatusOK, flowMetrics{
+		ID:        flow.ID,
+		Status:    flow.GetStatus(),
+		FlowUsage: flow.Usage(),
+	})
+}
+
+// handleListFlowMetrics handles GET /api/metrics/flows, reporting the
+// resources every flow of the workspace uses, busiest first
+func (s *Server) handleListFlowMetrics(w http.ResponseWriter, r *http.Request) {
+	workspace := requestWorkspace(r)
+	metrics := []flowMetrics{}
+	for _, id := range s.engine.WorkspaceFlows(workspace) {
+		flow, exists := s.engine.GetFlow(engine.FlowKey(workspace, id))
+		if !exists {
+			continue
+		}
+		metrics = append(metrics, flowMetrics{
+			ID:        id,
+			Status:    flow.GetStatus(),
+			FlowUsage: flow.Usage(),
+		})
+	}
+	sort.SliceStable(metrics, func(i, j int) bool { return metrics[i].Rate > metrics[j].Rate })
+	respond(w, http.StatusOK, flowMetricsList{Flows: metrics})
+}
+
+// flowMetricsList is the response of GET /api/metrics/flows
+type flowMetricsList struct {
+	Flows []flowMetrics `json:"flows"`
+}
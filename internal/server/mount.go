@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// cleanBasePath normalizes http.basePath to a path with a leading and no
+// trailing slash, or "" to mount the routes at the root
+func cleanBasePath(base string) string {
+	base = strings.TrimSpace(base)
+	if base == "" {
+		return ""
+	}
+	base = path.Clean("/" + base)
+	if base == "/" {
+		return ""
+	}
+	return base
+}
+
+// BasePath returns the path every route is mounted under, from
+// http.basePath, or "" for the root
+func (s *Server) BasePath() string {
+	return s.basePath
+}
+
+// mount serves a handler under the base path, stripping it from request
+// paths so that routes are matched as if mounted at the root. Requests
+// outside the base path are not found, and the base path itself is
+// redirected to its slash-terminated form.
+func (s *Server) mount(next http.Handler) http.Handler {
+	handler := exemptLongLived(next)
+	if s.basePath == "" {
+		return handler
+	}
+	stripped := http.StripPrefix(s.basePath, handler)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == s.basePath:
+			target := s.basePath + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+		case strings.HasPrefix(r.URL.Path, s.basePath+"/"):
+			stripped.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// exemptLongLived lifts the write deadline of http.writeTimeout from
+// WebSocket connections and server-sent event streams, which stay open for
+// as long as their clients do
+func exemptLongLived(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) || strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		}
+		next.ServeHTTP(w, r)
+	})
+}
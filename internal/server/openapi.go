@@ -0,0 +1,623 @@
+package server
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/storage"
+	"github.com/yourusername/go-red/internal/templates"
+)
+
+// routeDoc documents a route of the API for the OpenAPI document
+type routeDoc struct {
+	summary string
+	query   []queryParam
+	headers []queryParam
+	// request is a value of the type of the JSON body, if any; requestType
+	// is the media type of other bodies
+	request     interface{}
+	requestType string
+	// responses are values of the types of the JSON responses by status;
+	// responseType is the media type of other successful responses
+	responses    map[int]interface{}
+	responseType string
+}
+
+// queryParam documents a query parameter or a request header
+type queryParam struct {
+	name        string
+	description string
+}
+
+// forceParam is the query parameter overriding flow locks
+var forceParam = queryParam{"force", "true overrides the flow locks of other users; admins only"}
+
+// routeDocs document the routes of the API, keyed like auditActions. Routes
+// without docs are listed with their path parameters only.
+var routeDocs = map[string]routeDoc{
+	"GET /health": {summary: "Check that the runtime is up", responses: map[int]interface{}{200: healthStatus{}}},
+	"GET /cluster": {
+		summary:   "Describe this instance's view of the cluster",
+		query:     []queryParam{{"leader", "answer 503 unless this instance is the leader"}},
+		responses: map[int]interface{}{200: clusterInfo{}},
+	},
+	"GET /auth/whoami": {summary: "Describe the caller and what it may do", responses: map[int]interface{}{200: whoAmI{}}},
+	"POST /auth/token": {
+		summary:   "Exchange a username and password for a token",
+		request:   tokenRequest{},
+		responses: map[int]interface{}{200: issuedToken{}},
+	},
+	"GET /openapi.json": {summary: "Describe the API", responseType: "application/json"},
+	"GET /docs":         {summary: "Browse the API documentation", responseType: "text/html"},
+
+	"GET /workspaces": {summary: "List workspaces", responses: map[int]interface{}{200: workspaceList{}}},
+	"POST /workspaces": {
+		summary:   "Create a workspace",
+		request:   workspaceRequest{},
+		responses: map[int]interface{}{201: workspaceInfo{}},
+	},
+	"GET /workspaces/{workspace}": {summary: "Describe a workspace", responses: map[int]interface{}{200: workspaceInfo{}}},
+	"DELETE /workspaces/{workspace}": {
+		summary:   "Delete a workspace without flows",
+		responses: map[int]interface{}{200: successResponse{}, 409: workspaceFlowsErrorResponse{}},
+	},
+
+	"GET /agents":   {summary: "List connected edge agents; agents connect with a WebSocket upgrade", responses: map[int]interface{}{200: agentList{}}},
+	"GET /nodes":    {summary: "List node types and subflows", responses: map[int]interface{}{200: nodeTypeList{}}},
+	"GET /examples": {summary: "List example flows", responses: map[int]interface{}{200: []exampleInfo{}}},
+	"GET /hooks":    {summary: "List message hooks with their statistics", responses: map[int]interface{}{200: hookList{}}},
+	"GET /info":     {summary: "Describe the runtime and its startup", responses: map[int]interface{}{200: runtimeInfo{}}},
+	"GET /settings": {summary: "Get the settings", responses: map[int]interface{}{200: settingsInfo{}}},
+	"PUT /settings": {
+		summary:   "Change settings",
+		request:   map[string]interface{}{},
+		responses: map[int]interface{}{200: successResponse{}},
+	},
+
+	"GET /library/{type}": {summary: "List library entries", responses: map[int]interface{}{200: libraryList{}}},
+	"GET /library/{type}/{path}": {
+		summary:   "Get a library entry with its body, or list the entries below a path",
+		responses: map[int]interface{}{200: libraryEntry{}},
+	},
+	"POST /library/{type}/{path}": {
+		summary:   "Save a library entry",
+		request:   librarySaveRequest{},
+		responses: map[int]interface{}{200: storage.LibraryEntry{}},
+	},
+
+	"GET /backup": {summary: "Download a backup archive of the runtime", responseType: "application/gzip"},
+	"POST /restore": {
+		summary:     "Restore a backup archive",
+		requestType: "application/gzip",
+		responses:   map[int]interface{}{200: restoreResult{}, 500: restoreErrorResponse{}},
+	},
+	"GET /audit": {
+		summary: "List the audit log, newest first",
+		query: []queryParam{
+			{"since", "RFC 3339 time of the oldest entry"},
+			{"until", "RFC 3339 time the entries are before"},
+			{"limit", "number of entries, at most 1000"},
+			{"offset", "number of entries to skip"},
+		},
+		responses: map[int]interface{}{200: auditPage{}},
+	},
+
+	"GET /flows": {
+		summary: "List summaries of the flows; with state=quarantined, the flows that failed to load",
+		query: []queryParam{
+			{"state", "quarantined lists the flows that failed to load"},
+			{"status", "comma-separated statuses of the flows listed"},
+			{"q", "substring of the names of the flows listed"},
+			{"sort", "id (default), name, status or updated; a leading - sorts descending"},
+			{"limit", "number of flows; all by default"},
+			{"offset", "number of flows to skip"},
+			{"fields", "comma-separated fields of the summaries"},
+		},
+		responses: map[int]interface{}{200: flowPage{}},
+	},
+	"POST /flows": {
+		summary:   "Deploy a new flow",
+		request:   deployRequest{},
+		responses: map[int]interface{}{201: deployResult{}, 400: validationErrorResponse{}, 409: errorResponse{}},
+	},
+	"POST /flows/from-template": {
+		summary:   "Deploy a flow from a template",
+		request:   templateDeployRequest{},
+		responses: map[int]interface{}{201: createdFlow{}, 400: templateErrorResponse{}},
+	},
+	"POST /flows/validate": {
+		summary:   "Validate a flow without deploying it",
+		request:   deployRequest{},
+		responses: map[int]interface{}{200: engine.ValidationReport{}},
+	},
+	"GET /flows/export": {
+		summary:   "Export the flows without credentials",
+		query:     []queryParam{{"format", "go-red (default) or node-red"}},
+		responses: map[int]interface{}{200: []engine.FlowDefinition{}},
+	},
+	"POST /flows/import": {
+		summary: "Import flows",
+		query: []queryParam{
+			{"mode", "merge (default) keeps the other flows, replace deletes them"},
+			{"format", "go-red or node-red; detected if not set"},
+			forceParam,
+		},
+		request:   []engine.FlowDefinition{},
+		responses: map[int]interface{}{200: importReport{}},
+	},
+	"GET /flows/{id}": {summary: "Get a flow with its status and revision, also in the ETag header", responses: map[int]interface{}{200: flowDetails{}}},
+	"PUT /flows/{id}": {
+		summary: "Deploy a flow, replacing it",
+		query: []queryParam{
+			{"dryRun", "true only validates the flow"},
+			{"overwrite", "true replaces the flow without If-Match"},
+			forceParam,
+		},
+		headers:   []queryParam{{"If-Match", "ETag of the flow's revision, required for existing flows"}},
+		request:   deployRequest{},
+		responses: map[int]interface{}{200: deployResult{}, 400: validationErrorResponse{}, 412: revisionErrorResponse{}, 423: lockedErrorResponse{}, 428: errorResponse{}},
+	},
+	"DELETE /flows/{id}": {
+		summary:   "Delete a flow",
+		query:     []queryParam{forceParam},
+		responses: map[int]interface{}{200: successResponse{}, 423: lockedErrorResponse{}},
+	},
+	"POST /flows/{id}/start": {summary: "Start a flow", responses: map[int]interface{}{200: flowStatusResult{}}},
+	"POST /flows/{id}/stop":  {summary: "Stop a flow", responses: map[int]interface{}{200: flowStatusResult{}}},
+	"PUT /flows/{id}/enable": {
+		summary:   "Start a flow and have it start with the runtime",
+		query:     []queryParam{forceParam},
+		responses: map[int]interface{}{200: flowDisabledResult{}, 423: lockedErrorResponse{}},
+	},
+	"PUT /flows/{id}/disable": {
+		summary:   "Stop a flow and keep it stopped",
+		query:     []queryParam{forceParam},
+		responses: map[int]interface{}{200: flowDisabledResult{}, 423: lockedErrorResponse{}},
+	},
+	"GET /flows/{id}/versions": {summary: "List the saved versions of a flow", responses: map[int]interface{}{200: flowVersionList{}}},
+	"POST /flows/{id}/versions/{rev}/restore": {
+		summary:   "Deploy a saved version of a flow",
+		query:     []queryParam{forceParam},
+		responses: map[int]interface{}{200: deployResult{}, 423: lockedErrorResponse{}},
+	},
+	"POST /flows/{id}/lock": {
+		summary:   "Lock a flow for editing",
+		responses: map[int]interface{}{200: storage.FlowLock{}, 423: lockedErrorResponse{}},
+	},
+	"GET /flows/{id}/lock":    {summary: "Get the lock of a flow", responses: map[int]interface{}{200: lockStatus{}}},
+	"DELETE /flows/{id}/lock": {summary: "Release the lock of a flow", responses: map[int]interface{}{200: successResponse{}}},
+	"GET /flows/{id}/wires":   {summary: "List the wires of a flow", responses: map[int]interface{}{200: wireList{}}},
+	"PATCH /flows/{id}/wires": {
+		summary:   "Disable or enable a wire",
+		query:     []queryParam{forceParam},
+		request:   wirePatch{},
+		responses: map[int]interface{}{200: wireList{}, 423: lockedErrorResponse{}},
+	},
+	"GET /flows/{id}/metrics": {summary: "Get the resource usage of a flow", responses: map[int]interface{}{200: flowMetrics{}}},
+	"GET /flows/{id}/template": {
+		summary:   "Export a flow as a template",
+		responses: map[int]interface{}{200: templates.Template{}},
+	},
+	"POST /flows/{id}/template": {
+		summary:   "Export a flow as a template with parameters",
+		request:   templateExportRequest{},
+		responses: map[int]interface{}{200: templates.Template{}},
+	},
+	"GET /flows/{id}/nodes/{nodeId}": {summary: "Describe a running node", responses: map[int]interface{}{200: nodeDetails{}}},
+	"POST /flows/{id}/nodes/{nodeId}/enable": {
+		summary:   "Enable or disable a node",
+		request:   nodeEnableRequest{},
+		responses: map[int]interface{}{200: nodeEnabledResult{}},
+	},
+	"PATCH /flows/{id}/nodes/{nodeId}/config": {
+		summary:   "Change the dynamic config fields of a node",
+		query:     []queryParam{forceParam},
+		request:   map[string]interface{}{},
+		responses: map[int]interface{}{200: nodeConfigResult{}, 400: configErrorResponse{}, 423: lockedErrorResponse{}},
+	},
+	"GET /flows/{id}/nodes/{nodeId}/last":    {summary: "Get the messages a node retains", responses: map[int]interface{}{200: lastMessages{}}},
+	"DELETE /flows/{id}/nodes/{nodeId}/last": {summary: "Clear the messages a node retains", responses: map[int]interface{}{200: successResponse{}}},
+	"GET /metrics/flows":                     {summary: "Get the resource usage of all flows", responses: map[int]interface{}{200: flowMetricsList{}}},
+	"GET /correlations":                      {summary: "List pending correlated requests", responses: map[int]interface{}{200: correlationList{}}},
+	"POST /examples/{name}/install": {
+		summary:   "Deploy an example flow",
+		responses: map[int]interface{}{201: createdFlow{}, 422: missingTypesErrorResponse{}},
+	},
+}
+
+// routeKey returns the key of a route in auditActions and routeDocs: its
+// method and template, without /api, the workspace prefix and the patterns
+// of path parameters
+func routeKey(method, route string) string {
+	path := pathParamPattern.ReplaceAllString(strings.TrimPrefix(route, "/api"), "{$1}")
+	if rest := strings.TrimPrefix(path, "/workspaces/{workspace}"); rest != path && rest != "" {
+		path = rest
+	}
+	return method + " " + path
+}
+
+// apiDocument is an OpenAPI 3 document
+type apiDocument struct {
+	OpenAPI    string                             `json:"openapi"`
+	Info       apiInfo                            `json:"info"`
+	Servers    []apiServer                        `json:"servers,omitempty"`
+	Paths      map[string]map[string]apiOperation `json:"paths"`
+	Components apiComponents                      `json:"components"`
+	Security   []map[string][]string              `json:"security"`
+}
+
+// apiInfo describes the API
+type apiInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// apiServer is the base URL of the paths, when mounted under
+// http.basePath
+type apiServer struct {
+	URL string `json:"url"`
+}
+
+// apiComponents holds the schemas the operations refer to
+type apiComponents struct {
+	Schemas         map[string]*apiSchema        `json:"schemas"`
+	SecuritySchemes map[string]apiSecurityScheme `json:"securitySchemes"`
+}
+
+// apiSecurityScheme describes how requests authenticate
+type apiSecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme"`
+}
+
+// apiOperation describes a method of a path
+type apiOperation struct {
+	Summary     string                 `json:"summary,omitempty"`
+	OperationID string                 `json:"operationId"`
+	Tags        []string               `json:"tags,omitempty"`
+	Parameters  []apiParameter         `json:"parameters,omitempty"`
+	RequestBody *apiBody               `json:"requestBody,omitempty"`
+	Responses   map[string]apiResponse `json:"responses"`
+}
+
+// apiParameter describes a path or query parameter
+type apiParameter struct {
+	Name        string     `json:"name"`
+	In          string     `json:"in"`
+	Description string     `json:"description,omitempty"`
+	Required    bool       `json:"required"`
+	Schema      *apiSchema `json:"schema"`
+}
+
+// apiBody describes a request body
+type apiBody struct {
+	Required bool                    `json:"required"`
+	Content  map[string]apiMediaType `json:"content"`
+}
+
+// apiResponse describes a response
+type apiResponse struct {
+	Description string                  `json:"description"`
+	Content     map[string]apiMediaType `json:"content,omitempty"`
+}
+
+// apiMediaType describes a body of a media type
+type apiMediaType struct {
+	Schema *apiSchema `json:"schema"`
+}
+
+// apiSchema is a JSON schema; the empty schema allows any value
+type apiSchema struct {
+	Ref                  string                `json:"$ref,omitempty"`
+	Type                 string                `json:"type,omitempty"`
+	Format               string                `json:"format,omitempty"`
+	Nullable             bool                  `json:"nullable,omitempty"`
+	Items                *apiSchema            `json:"items,omitempty"`
+	Properties           map[string]*apiSchema `json:"properties,omitempty"`
+	Required             []string              `json:"required,omitempty"`
+	AdditionalProperties *apiSchema            `json:"additionalProperties,omitempty"`
+}
+
+// pathParamPattern matches the variables of route templates, with their
+// patterns if any
+var pathParamPattern = regexp.MustCompile(`\{([^{}:]+)(?::[^{}]*)?\}`)
+
+// openAPIDocument describes the routes of the router under /api, and
+// /auth/token, with the request and response types of routeDocs
+func (s *Server) openAPIDocument() (*apiDocument, error) {
+	doc := &apiDocument{
+		OpenAPI: "3.0.3",
+		Info:    apiInfo{Title: "go-red API", Version: "0.1.0"},
+		Paths:   make(map[string]map[string]apiOperation),
+		Components: apiComponents{
+			Schemas:         make(map[string]*apiSchema),
+			SecuritySchemes: map[string]apiSecurityScheme{"bearer": {Type: "http", Scheme: "bearer"}},
+		},
+		Security: []map[string][]string{{"bearer": {}}},
+	}
+	if s.basePath != "" {
+		doc.Servers = []apiServer{{URL: s.basePath}}
+	}
+	schemas := &schemaBuilder{schemas: doc.Components.Schemas, types: make(map[string]reflect.Type)}
+
+	err := s.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil || !(strings.HasPrefix(template, "/api/") || template == "/auth/token") {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// Subrouters and prefixes have no methods
+			return nil
+		}
+		path := pathParamPattern.ReplaceAllString(template, "{$1}")
+		for _, method := range methods {
+			if method == http.MethodOptions {
+				continue
+			}
+			operations := doc.Paths[path]
+			if operations == nil {
+				operations = make(map[string]apiOperation)
+				doc.Paths[path] = operations
+			}
+			// The first route of a method handles it, as in the router
+			if _, exists := operations[strings.ToLower(method)]; !exists {
+				operations[strings.ToLower(method)] = schemas.operation(method, path)
+			}
+		}
+		return nil
+	})
+	return doc, err
+}
+
+// operation describes a method of a path from its docs
+func (b *schemaBuilder) operation(method, path string) apiOperation {
+	key := routeKey(method, path)
+	docs := routeDocs[key]
+	op := apiOperation{
+		Summary:     docs.summary,
+		OperationID: operationID(method, path),
+		Responses:   make(map[string]apiResponse),
+	}
+	if tag := strings.TrimSuffix(strings.SplitN(strings.TrimPrefix(strings.SplitN(key, " ", 2)[1], "/"), "/", 2)[0], ".json"); tag != "" {
+		op.Tags = []string{tag}
+	}
+	for _, match := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+		op.Parameters = append(op.Parameters, apiParameter{Name: match[1], In: "path", Required: true, Schema: &apiSchema{Type: "string"}})
+	}
+	for _, param := range docs.query {
+		op.Parameters = append(op.Parameters, apiParameter{Name: param.name, In: "query", Description: param.description, Schema: &apiSchema{Type: "string"}})
+	}
+	for _, param := range docs.headers {
+		op.Parameters = append(op.Parameters, apiParameter{Name: param.name, In: "header", Description: param.description, Schema: &apiSchema{Type: "string"}})
+	}
+
+	if method != http.MethodGet {
+		switch {
+		case docs.request != nil:
+			op.RequestBody = &apiBody{Required: true, Content: b.content("application/json", docs.request)}
+		case docs.requestType != "":
+			op.RequestBody = &apiBody{Required: true, Content: b.content(docs.requestType, nil)}
+		}
+	}
+
+	// In order, so that components are named the same every time
+	statuses := make([]int, 0, len(docs.responses))
+	for status := range docs.responses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		op.Responses[strconv.Itoa(status)] = apiResponse{
+			Description: http.StatusText(status),
+			Content:     b.content("application/json", docs.responses[status]),
+		}
+	}
+	if docs.responseType != "" {
+		op.Responses["200"] = apiResponse{Description: "OK", Content: b.content(docs.responseType, nil)}
+	}
+	if len(op.Responses) == 0 {
+		op.Responses["200"] = apiResponse{Description: "OK"}
+	}
+	op.Responses["default"] = apiResponse{Description: "Error", Content: b.content("application/json", errorResponse{})}
+	return op
+}
+
+// content describes a body of a media type, with the schema of value's type
+// if any, or else any JSON, text or binary data
+func (b *schemaBuilder) content(mediaType string, value interface{}) map[string]apiMediaType {
+	schema := &apiSchema{Type: "string", Format: "binary"}
+	switch {
+	case value != nil:
+		schema = b.schema(reflect.TypeOf(value))
+	case mediaType == "application/json":
+		schema = &apiSchema{}
+	case strings.HasPrefix(mediaType, "text/"):
+		schema = &apiSchema{Type: "string"}
+	}
+	return map[string]apiMediaType{mediaType: {Schema: schema}}
+}
+
+// operationID names an operation by its method and path, such as
+// getFlowsIdNodesNodeId
+func operationID(method, path string) string {
+	var id strings.Builder
+	id.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9')
+	}) {
+		if part == "api" && id.Len() == len(method) {
+			continue
+		}
+		id.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return id.String()
+}
+
+// schemaBuilder builds the schemas of Go types as encoding/json marshals
+// them. Structs become components, named by their type.
+type schemaBuilder struct {
+	schemas map[string]*apiSchema
+	// types are the types of the components by name
+	types map[string]reflect.Type
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schema returns the schema of a type
+func (b *schemaBuilder) schema(t reflect.Type) *apiSchema {
+	switch {
+	case t == timeType:
+		return &apiSchema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &apiSchema{}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		// Marshals itself into whatever shape
+		return &apiSchema{}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return &apiSchema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := b.schema(t.Elem())
+		if schema.Ref != "" {
+			return schema
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.Bool:
+		return &apiSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &apiSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &apiSchema{Type: "number"}
+	case reflect.String:
+		return &apiSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &apiSchema{Type: "string", Format: "byte"}
+		}
+		return &apiSchema{Type: "array", Items: b.schema(t.Elem())}
+	case reflect.Map:
+		return &apiSchema{Type: "object", AdditionalProperties: b.schema(t.Elem())}
+	case reflect.Struct:
+		return b.component(t)
+	}
+	return &apiSchema{}
+}
+
+// component returns a reference to the component of a struct type, adding
+// it unless it exists
+func (b *schemaBuilder) component(t reflect.Type) *apiSchema {
+	name := componentName(t)
+	if existing, ok := b.types[name]; ok && existing != t {
+		// Types of different packages with the same name
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	ref := &apiSchema{Ref: "#/components/schemas/" + name}
+	if _, exists := b.types[name]; exists {
+		return ref
+	}
+	// Added before its fields, which may refer to it
+	schema := &apiSchema{Type: "object", Properties: make(map[string]*apiSchema)}
+	b.types[name] = t
+	b.schemas[name] = schema
+	b.addFields(schema, t)
+	sort.Strings(schema.Required)
+	return ref
+}
+
+// addFields adds the fields of a struct type to its schema, with those of
+// embedded structs. Fields without omitempty are required.
+func (b *schemaBuilder) addFields(schema *apiSchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		fieldType := field.Type
+		if field.Anonymous && name == "" {
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				b.addFields(schema, fieldType)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = b.schema(fieldType)
+		if !strings.Contains(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// componentName names the component of a type by its type name, such as
+// FlowDefinition
+func componentName(t reflect.Type) string {
+	name := t.Name()
+	if name == "" {
+		return "Anonymous"
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// handleOpenAPI handles GET /api/openapi.json, which describes the API
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	doc, err := s.openAPIDocument()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to describe the API: %v", err))
+		return
+	}
+	respond(w, http.StatusOK, doc)
+}
+
+// handleAPIDocs handles GET /api/docs, a Swagger UI of /api/openapi.json
+func (s *Server) handleAPIDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(apiDocsPage))
+}
+
+// apiDocsPage loads Swagger UI from a CDN, which the browser needs to reach
+const apiDocsPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>go-red API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>
+window.ui = SwaggerUIBundle({url: "openapi.json", dom_id: "#swagger-ui"});
+</script>
+</body>
+</html>
+`
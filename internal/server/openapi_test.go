@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// TestOpenAPICoversRoutes walks the router and checks that every route of
+// the API is documented, and that every documented route exists
+func TestOpenAPICoversRoutes(t *testing.T) {
+	s := newTestServer(t, map[string]interface{}{"metrics.enabled": true})
+	doc, err := s.openAPIDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registered := make(map[string]bool)
+	err = s.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil || !(strings.HasPrefix(template, "/api/") || template == "/auth/token") {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		path := pathParamPattern.ReplaceAllString(template, "{$1}")
+		for _, method := range methods {
+			if method == http.MethodOptions {
+				continue
+			}
+			key := routeKey(method, template)
+			registered[key] = true
+			if _, ok := doc.Paths[path][strings.ToLower(method)]; !ok {
+				t.Errorf("%s %s is missing from the OpenAPI document", method, path)
+			}
+			if routeDocs[key].summary == "" {
+				t.Errorf("%s has no summary in routeDocs", key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key := range routeDocs {
+		if !registered[key] {
+			t.Errorf("routeDocs documents %s, which is not registered", key)
+		}
+	}
+}
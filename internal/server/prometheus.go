@@ -0,0 +1,167 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/metrics"
+)
+
+// serverMetrics are the metrics served at /metrics when metrics.enabled is
+// set. The engine reports the messages of every node to them.
+type serverMetrics struct {
+	registry       *metrics.Registry
+	handled        *metrics.Counter
+	handleErrors   *metrics.Counter
+	handleDuration *metrics.Histogram
+	sent           *metrics.Counter
+	sendErrors     *metrics.Counter
+	requests       *metrics.Counter
+	requestTime    *metrics.Histogram
+	rejected       *metrics.Counter
+}
+
+// nodeLabels are the labels of the per-node metrics
+var nodeLabels = []string{"flow_id", "node_id", "node_type"}
+
+// newServerMetrics creates the metrics of a server
+func newServerMetrics(s *Server) *serverMetrics {
+	r := metrics.NewRegistry()
+	m := &serverMetrics{
+		registry:       r,
+		handled:        r.NewCounter("gored_node_messages_total", "Messages handled by a node.", nodeLabels...),
+		handleErrors:   r.NewCounter("gored_node_errors_total", "Messages a node failed to handle.", nodeLabels...),
+		handleDuration: r.NewHistogram("gored_node_message_duration_seconds", "Time a node took to handle a message.", metrics.DefaultBuckets, nodeLabels...),
+		sent:           r.NewCounter("gored_node_messages_sent_total", "Messages sent by a node.", nodeLabels...),
+		sendErrors:     r.NewCounter("gored_node_send_errors_total", "Sends of a node that failed.", nodeLabels...),
+		requests:       r.NewCounter("gored_http_requests_total", "HTTP API requests by route and status code.", "method", "route", "code"),
+		requestTime:    r.NewHistogram("gored_http_request_duration_seconds", "Time taken to answer HTTP API requests.", metrics.DefaultBuckets, "method", "route"),
+		rejected:       r.NewCounter("gored_http_rejected_total", "HTTP API requests rejected by the rate limit or the body size limit.", "reason"),
+	}
+
+	r.NewGauge("gored_node_queue_depth", "Messages waiting in the persistent inbox of a node.", func(set func(float64, ...string)) {
+		for _, flow := range s.listFlows() {
+			for _, node := range flow.ListNodes() {
+				if depth, ok := node.QueueDepth(); ok {
+					set(float64(depth), nodeMetricLabels(node)...)
+				}
+			}
+		}
+	}, nodeLabels...)
+	r.NewGauge("gored_flow_status", "The status of a flow, as 1 for the current one.", func(set func(float64, ...string)) {
+		for _, flow := range s.listFlows() {
+			set(1, flow.Key(), string(flow.GetStatus()))
+		}
+	}, "flow_id", "status")
+	r.NewGauge("gored_websocket_clients", "Connected WebSocket clients.", func(set func(float64, ...string)) {
+		if s.wsManager != nil {
+			set(float64(s.wsManager.ClientCount()))
+		}
+	})
+	return m
+}
+
+// listFlows returns the flows of all workspaces, ordered by key
+func (s *Server) listFlows() []*engine.Flow {
+	keys := s.engine.ListFlows()
+	sort.Strings(keys)
+	flows := make([]*engine.Flow, 0, len(keys))
+	for _, key := range keys {
+		if flow, exists := s.engine.GetFlow(key); exists {
+			flows = append(flows, flow)
+		}
+	}
+	return flows
+}
+
+// nodeMetricLabels returns the label values of a node's metrics
+func nodeMetricLabels(node *engine.Node) []string {
+	flowID, nodeType := "", ""
+	if flow := node.GetFlow(); flow != nil {
+		flowID = flow.Key()
+	}
+	if node.Type != nil {
+		nodeType = node.Type.Name
+	}
+	return []string{flowID, node.ID, nodeType}
+}
+
+// MessageHandled implements engine.MessageObserver
+func (m *serverMetrics) MessageHandled(node *engine.Node, duration time.Duration, err error) {
+	labels := nodeMetricLabels(node)
+	m.handled.Inc(labels...)
+	m.handleDuration.Observe(duration.Seconds(), labels...)
+	if err != nil {
+		m.handleErrors.Inc(labels...)
+	}
+}
+
+// MessageSent implements engine.MessageObserver
+func (m *serverMetrics) MessageSent(node *engine.Node, count int, err error) {
+	labels := nodeMetricLabels(node)
+	m.sent.Add(float64(count), labels...)
+	if err != nil {
+		m.sendErrors.Inc(labels...)
+	}
+}
+
+// metricsMiddleware counts and times API requests by their route
+func (s *Server) metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.metrics == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		s.metrics.requests.Inc(r.Method, route, strconv.Itoa(recorder.status))
+		s.metrics.requestTime.Observe(time.Since(start).Seconds(), r.Method, route)
+	})
+}
+
+// statusRecorder records the status code of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush lets streamed responses through
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets WebSocket upgrades through
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not support hijacking")
+	}
+	r.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+// Unwrap gives http.ResponseController the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
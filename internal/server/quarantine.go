@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// handleListQuarantinedFlows handles GET /api/flows?state=quarantined,
+// listing the flows of the workspace that failed to load
+func (s *Server) handleListQuarantinedFlows(w http.ResponseWriter, r *http.Request) {
+	workspace := requestWorkspace(r)
+	flows := make([]quarantinedFlow, 0)
+	for _, flow := range s.engine.QuarantinedFlows() {
+		if flowWorkspace, id := engine.SplitFlowKey(flow.ID); flowWorkspace == workspace {
+			flows = append(flows, quarantinedFlow{
+				ID:     id,
+				Status: "quarantined",
+				Error:  flow.Error,
+				Since:  flow.Since,
+			})
+		}
+	}
+	respond(w, http.StatusOK, quarantinedFlowList{Flows: flows})
+}
+
+// quarantinedFlow describes a flow that failed to load, with its raw
+// definition when requested by ID
+type quarantinedFlow struct {
+	ID         string    `json:"id"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error"`
+	Since      time.Time `json:"since"`
+	Definition *string   `json:"definition,omitempty"`
+}
+
+// quarantinedFlowList is the response of GET /api/flows?state=quarantined
+type quarantinedFlowList struct {
+	Flows []quarantinedFlow `json:"flows"`
+}
+
+// respondQuarantinedFlow sends the raw definition of a quarantined flow,
+// which PUT /api/flows/{id} replaces once fixed. It reports whether the
+// flow is quarantined.
+func (s *Server) respondQuarantinedFlow(w http.ResponseWriter, r *http.Request) bool {
+	flow, exists := s.engine.QuarantinedFlow(flowKey(r))
+	if !exists {
+		return false
+	}
+	_, id := engine.SplitFlowKey(flow.ID)
+	definition := string(flow.Definition)
+	respond(w, http.StatusOK, quarantinedFlow{
+		ID:         id,
+		Status:     "quarantined",
+		Error:      flow.Error,
+		Since:      flow.Since,
+		Definition: &definition,
+	})
+	return true
+}
+
+// handleGetInfo handles GET /api/info, describing the runtime and the
+// outcome of loading the stored flows
+func (s *Server) handleGetInfo(w http.ResponseWriter, r *http.Request) {
+	respond(w, http.StatusOK, runtimeInfo{
+		Version: "0.1.0",
+		Status:  s.engine.Status(),
+		Flows:   len(s.engine.ListFlows()),
+		Startup: s.engine.StartupReport(),
+	})
+}
+
+// runtimeInfo is the response of GET /api/info
+type runtimeInfo struct {
+	Version string               `json:"version"`
+	Status  engine.Status        `json:"status"`
+	Flows   int                  `json:"flows"`
+	Startup engine.StartupReport `json:"startup"`
+}
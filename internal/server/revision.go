@@ -0,0 +1,78 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// flowETag returns the ETag of a flow revision
+func flowETag(revision string) string {
+	return `"` + revision + `"`
+}
+
+// expectedRevision returns the revision of the flow an update expects, from
+// its If-Match header, for engine.DeployFlowAt. Updates of existing flows
+// without one are answered with 428 Precondition Required, unless they ask
+// to overwrite whatever revision with ?overwrite=true.
+func (s *Server) expectedRevision(w http.ResponseWriter, r *http.Request, key string) (string, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		if r.URL.Query().Get("overwrite") == "true" {
+			return "", true
+		}
+		if _, exists := s.engine.GetFlow(key); exists {
+			respondError(w, http.StatusPreconditionRequired, "If-Match with the flow's ETag is required; add ?overwrite=true to overwrite any revision")
+			return "", false
+		}
+		return "", true
+	}
+
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == engine.AnyRevision {
+			return engine.AnyRevision, true
+		}
+		tags = append(tags, strings.Trim(tag, `"`))
+	}
+	// Of several revisions, the current one is expected, if listed
+	if flow, exists := s.engine.GetFlow(key); exists && len(tags) > 1 {
+		if current, err := flow.Revision(); err == nil {
+			for _, tag := range tags {
+				if tag == current {
+					return tag, true
+				}
+			}
+		}
+	}
+	return tags[0], true
+}
+
+// respondRevisionError answers an update of a flow that changed meanwhile
+// with 412 Precondition Failed and the current revision. It reports
+// whether err is such an error.
+func respondRevisionError(w http.ResponseWriter, err error) bool {
+	var moved *engine.RevisionError
+	if !errors.As(err, &moved) {
+		return false
+	}
+	if moved.Current != "" {
+		w.Header().Set("ETag", flowETag(moved.Current))
+	}
+	respond(w, http.StatusPreconditionFailed, revisionErrorResponse{
+		Error:    fmt.Sprintf("Flow was changed meanwhile: %v", err),
+		Revision: moved.Current,
+	})
+	return true
+}
+
+// revisionErrorResponse names the current revision of a flow an update did
+// not expect
+type revisionErrorResponse struct {
+	Error    string `json:"error"`
+	Revision string `json:"revision,omitempty"`
+}
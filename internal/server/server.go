@@ -1,13 +1,20 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
-	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/yourusername/go-red/internal/agent"
 	"github.com/yourusername/go-red/internal/config"
 	"github.com/yourusername/go-red/internal/engine"
 	"github.com/yourusername/go-red/internal/storage"
@@ -15,21 +22,73 @@ import (
 
 // Server represents the HTTP server
 type Server struct {
-	config  *config.Config
-	engine  *engine.Engine
-	storage storage.Storage
-	router  *mux.Router
+	config    *config.Config
+	engine    *engine.Engine
+	storage   storage.Storage
+	router    *mux.Router
+	wsManager *WebSocketManager
+	cluster   ClusterMember
+	agents    *agent.Hub
+	http      *http.Server
+	auth      *auth
+	// cert is the TLS certificate when serving HTTPS
+	cert *certificate
+	// redirect redirects plain HTTP requests to HTTPS, if enabled
+	redirect   *http.Server
+	redirectMu sync.Mutex
+	// metrics are served at /metrics when metrics.enabled is set
+	metrics *serverMetrics
+	// debugManager pushes debug events to the clients of /ws/debug
+	debugManager *WebSocketManager
+	// audit records the changes made through the API, unless disabled
+	audit *auditLog
+	// limiter rate limits the mutating API requests of each client
+	limiter *rateLimiter
+	// basePath is the path every route is mounted under, from
+	// http.basePath, or "" for the root
+	basePath string
+	// compression compresses responses, unless disabled
+	compression *compression
 }
 
+// defaultHTTPTimeout bounds reading requests and writing responses unless
+// http.readTimeout and http.writeTimeout say otherwise
+const defaultHTTPTimeout = 15 * time.Second
+
+// defaultIdleTimeout bounds how long idle keep-alive connections are kept
+// unless http.idleTimeout says otherwise
+const defaultIdleTimeout = 2 * time.Minute
+
 // New creates a new Server instance
 func New(cfg *config.Config, eng *engine.Engine, store storage.Storage) *Server {
 	srv := &Server{
-		config:  cfg,
-		engine:  eng,
-		storage: store,
-		router:  mux.NewRouter(),
+		config:      cfg,
+		engine:      eng,
+		storage:     store,
+		router:      mux.NewRouter(),
+		auth:        newAuth(cfg),
+		limiter:     newRateLimiter(),
+		basePath:    cleanBasePath(cfg.GetString("http.basePath")),
+		compression: newCompression(cfg),
+	}
+	// WebSocket connections and event streams are exempt from the write
+	// timeout; see exemptLongLived
+	readTimeout := config.GetOrDefault(cfg, "http.readTimeout", defaultHTTPTimeout)
+	srv.http = &http.Server{
+		Handler:           srv.Handler(),
+		WriteTimeout:      config.GetOrDefault(cfg, "http.writeTimeout", defaultHTTPTimeout),
+		ReadTimeout:       readTimeout,
+		ReadHeaderTimeout: config.GetOrDefault(cfg, "http.readHeaderTimeout", readTimeout),
+		IdleTimeout:       config.GetOrDefault(cfg, "http.idleTimeout", defaultIdleTimeout),
+	}
+
+	if cfg.GetBool("metrics.enabled") {
+		srv.metrics = newServerMetrics(srv)
+		eng.SetObserver(srv.metrics)
 	}
 
+	srv.audit = newAuditLog(srv)
+
 	// Register routes
 	srv.setupRoutes()
 
@@ -43,77 +102,218 @@ func (s *Server) Start() error {
 		port = 1880 // Default port
 	}
 
-	addr := fmt.Sprintf(":%d", port)
-	server := &http.Server{
-		Handler:      s.router,
-		Addr:         addr,
-		WriteTimeout: 15 * time.Second,
-		ReadTimeout:  15 * time.Second,
+	s.http.Addr = net.JoinHostPort(s.config.GetString("http.host"), strconv.Itoa(port))
+	if s.config.GetBool("https.enabled") {
+		if err := s.EnableTLS(); err != nil {
+			return err
+		}
+		return s.http.ListenAndServeTLS("", "")
 	}
+	return s.http.ListenAndServe()
+}
 
-	return server.ListenAndServe()
+// Serve serves HTTP on a listener until Shutdown is called, or HTTPS once
+// EnableTLS has been called
+func (s *Server) Serve(listener net.Listener) error {
+	if s.cert != nil {
+		return s.http.ServeTLS(listener, "", "")
+	}
+	return s.http.Serve(listener)
+}
+
+// Shutdown stops the HTTP server, waiting for active requests until ctx
+// ends
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.cert != nil {
+		close(s.cert.done)
+	}
+	s.redirectMu.Lock()
+	redirect := s.redirect
+	s.redirectMu.Unlock()
+	if redirect != nil {
+		redirect.Shutdown(ctx)
+	}
+	if s.audit != nil {
+		defer s.audit.close()
+	}
+	return s.http.Shutdown(ctx)
+}
+
+// Handler returns the handler of the API, the editor and the routes of
+// http-in nodes, for serving them from another HTTP server. They are
+// mounted under http.basePath, if set.
+func (s *Server) Handler() http.Handler {
+	return s.mount(s.router)
 }
 
 // setupRoutes registers all HTTP routes
 func (s *Server) setupRoutes() {
 	// API routes
 	api := s.router.PathPrefix("/api").Subrouter()
+	api.Use(s.compression.middleware)
+	api.Use(s.logMiddleware)
+	api.Use(s.metricsMiddleware)
+	api.Use(s.corsMiddleware)
+	api.Use(s.authMiddleware)
+	api.Use(s.clusterMiddleware)
+	api.Use(s.auditMiddleware)
+	api.Use(s.limitMiddleware)
+	api.HandleFunc("/cluster", s.handleGetCluster).Methods("GET")
+	api.PathPrefix("/").HandlerFunc(s.handleOptions).Methods("OPTIONS")
+	api.HandleFunc("/health", s.handleHealth).Methods("GET")
+	api.HandleFunc("/auth/whoami", s.handleWhoAmI).Methods("GET")
+	api.HandleFunc("/openapi.json", s.handleOpenAPI).Methods("GET")
+	api.HandleFunc("/docs", s.handleAPIDocs).Methods("GET")
 	
-	// Flows API
-	api.HandleFunc("/flows", s.handleListFlows).Methods("GET")
-	api.HandleFunc("/flows", s.handleCreateFlow).Methods("POST")
-	api.HandleFunc("/flows/{id}", s.handleGetFlow).Methods("GET")
-	api.HandleFunc("/flows/{id}", s.handleUpdateFlow).Methods("PUT")
-	api.HandleFunc("/flows/{id}", s.handleDeleteFlow).Methods("DELETE")
-	api.HandleFunc("/flows/{id}/start", s.handleStartFlow).Methods("POST")
-	api.HandleFunc("/flows/{id}/stop", s.handleStopFlow).Methods("POST")
+	// Prometheus metrics, when metrics.enabled is set
+	if s.metrics != nil {
+		s.router.Handle("/metrics", s.authMiddleware(s.metrics.registry)).Methods("GET")
+	}
+	
+	// Tokens for the API, when api.auth.enabled is set
+	s.router.Handle("/auth/token", s.corsMiddleware(s.auditMiddleware(s.limitMiddleware(http.HandlerFunc(s.handleIssueToken))))).Methods("POST")
+	s.router.Handle("/auth/token", s.corsMiddleware(http.HandlerFunc(s.handleOptions))).Methods("OPTIONS")
+	
+	// Workspaces, each with its own flows
+	api.HandleFunc("/workspaces", s.handleListWorkspaces).Methods("GET")
+	api.HandleFunc("/workspaces", s.handleCreateWorkspace).Methods("POST")
+	workspace := api.PathPrefix("/workspaces/{workspace}").Subrouter()
+	workspace.Use(s.workspaceMiddleware)
+	workspace.HandleFunc("", s.handleGetWorkspace).Methods("GET")
+	workspace.HandleFunc("", s.handleDeleteWorkspace).Methods("DELETE")
+	s.setupWorkspaceRoutes(workspace)
+	
+	// Edge agents
+	api.HandleFunc("/agents", s.handleAgentConnection).Methods("GET").MatcherFunc(isWebSocketUpgrade)
+	api.HandleFunc("/agents", s.handleListAgents).Methods("GET")
 	
 	// Nodes API
 	api.HandleFunc("/nodes", s.handleListNodeTypes).Methods("GET")
 	
+	// Example flows
+	api.HandleFunc("/examples", s.handleListExamples).Methods("GET")
+	
+	// Message hooks
+	api.HandleFunc("/hooks", s.handleListHooks).Methods("GET")
+	
+	// Runtime info
+	api.HandleFunc("/info", s.handleGetInfo).Methods("GET")
+	
 	// Settings API
 	api.HandleFunc("/settings", s.handleGetSettings).Methods("GET")
 	api.HandleFunc("/settings", s.handleUpdateSettings).Methods("PUT")
 	
+	// Library of reusable flow fragments, functions and templates
+	api.HandleFunc("/library/{type}", s.handleGetLibrary).Methods("GET")
+	api.HandleFunc("/library/{type}/{path:.+}", s.handleGetLibrary).Methods("GET")
+	api.HandleFunc("/library/{type}/{path:.+}", s.handleSaveLibrary).Methods("POST")
+	
+	// Backup and restore of the whole runtime
+	api.HandleFunc("/backup", s.handleBackup).Methods("GET")
+	api.HandleFunc("/restore", s.handleRestore).Methods("POST")
+	
+	// Audit log of the changes made through the API
+	api.HandleFunc("/audit", s.handleListAudit).Methods("GET")
+	
+	// The workspace routes without a workspace address the default one;
+	// they come last as their router matches any path
+	defaultWorkspace := api.NewRoute().Subrouter()
+	defaultWorkspace.Use(s.workspaceMiddleware)
+	s.setupWorkspaceRoutes(defaultWorkspace)
+	
+	// WebSocket and debug console
+	s.AddWebSocketHandler()
+	s.AddDebugWebSocketHandler()
+	s.AddDebugConsoleHandler()
+	s.engine.Events().Subscribe(s.wsManager.BroadcastEvent)
+
+	// Routes registered by http-in nodes
+	routes := s.engine.HTTPRoutes()
+	s.router.MatcherFunc(func(r *http.Request, rm *mux.RouteMatch) bool {
+		_, _, exists := routes.Match(r)
+		return exists
+	}).Handler(routes)
+
 	// Static files (Web UI)
-	s.router.PathPrefix("/").Handler(http.FileServer(http.Dir("web/dist")))
+	s.router.PathPrefix("/").Handler(s.compression.middleware(http.FileServer(http.Dir("web/dist"))))
 }
 
-// handleListFlows handles GET /api/flows
-func (s *Server) handleListFlows(w http.ResponseWriter, r *http.Request) {
-	flowIDs := s.engine.ListFlows()
-	flows := make([]map[string]interface{}, 0, len(flowIDs))
+// setupWorkspaceRoutes registers the routes of the resources that belong to
+// a workspace
+func (s *Server) setupWorkspaceRoutes(router *mux.Router) {
+	// Flows API
+	router.HandleFunc("/flows", s.handleListFlows).Methods("GET")
+	router.HandleFunc("/flows", s.handleCreateFlow).Methods("POST")
+	router.HandleFunc("/flows/from-template", s.handleDeployTemplate).Methods("POST")
+	router.HandleFunc("/flows/validate", s.handleValidateFlow).Methods("POST")
+	router.HandleFunc("/flows/export", s.handleExportFlows).Methods("GET")
+	router.HandleFunc("/flows/import", s.handleImportFlows).Methods("POST")
+	router.HandleFunc("/flows/{id}", s.handleGetFlow).Methods("GET")
+	router.HandleFunc("/flows/{id}", s.handleUpdateFlow).Methods("PUT")
+	router.HandleFunc("/flows/{id}", s.handleDeleteFlow).Methods("DELETE")
+	router.HandleFunc("/flows/{id}/start", s.handleStartFlow).Methods("POST")
+	router.HandleFunc("/flows/{id}/stop", s.handleStopFlow).Methods("POST")
+	router.HandleFunc("/flows/{id}/enable", s.handleEnableFlow).Methods("PUT")
+	router.HandleFunc("/flows/{id}/disable", s.handleDisableFlow).Methods("PUT")
+	router.HandleFunc("/flows/{id}/versions", s.handleListFlowVersions).Methods("GET")
+	router.HandleFunc("/flows/{id}/versions/{rev}/restore", s.handleRestoreFlowVersion).Methods("POST")
+	router.HandleFunc("/flows/{id}/lock", s.handleAcquireFlowLock).Methods("POST")
+	router.HandleFunc("/flows/{id}/lock", s.handleGetFlowLock).Methods("GET")
+	router.HandleFunc("/flows/{id}/lock", s.handleReleaseFlowLock).Methods("DELETE")
+	router.HandleFunc("/flows/{id}/wires", s.handleListWires).Methods("GET")
+	router.HandleFunc("/flows/{id}/wires", s.handleUpdateWire).Methods("PATCH")
+	router.HandleFunc("/flows/{id}/metrics", s.handleGetFlowMetrics).Methods("GET")
+	router.HandleFunc("/flows/{id}/template", s.handleExportTemplate).Methods("GET", "POST")
+	router.HandleFunc("/flows/{id}/nodes/{nodeId}", s.handleGetNode).Methods("GET")
+	router.HandleFunc("/flows/{id}/nodes/{nodeId}/enable", s.handleEnableNode).Methods("POST")
+	router.HandleFunc("/flows/{id}/nodes/{nodeId}/config", s.handleUpdateNodeConfig).Methods("PATCH")
+	router.HandleFunc("/flows/{id}/nodes/{nodeId}/last", s.handleGetLastMessages).Methods("GET")
+	router.HandleFunc("/flows/{id}/nodes/{nodeId}/last", s.handleClearLastMessages).Methods("DELETE")
 	
-	for _, id := range flowIDs {
-		flow, exists := s.engine.GetFlow(id)
-		if !exists {
-			continue
-		}
-		
-		flowJSON, err := flow.ToJSON()
-		if err != nil {
-			continue
-		}
-		
-		var flowMap map[string]interface{}
-		if err := json.Unmarshal(flowJSON, &flowMap); err != nil {
-			continue
-		}
-		
-		// Add status
-		flowMap["status"] = string(flow.GetStatus())
-		flows = append(flows, flowMap)
-	}
+	// Resource usage of the flows
+	router.HandleFunc("/metrics/flows", s.handleListFlowMetrics).Methods("GET")
 	
-	respond(w, http.StatusOK, map[string]interface{}{
-		"flows": flows,
-	})
+	// Pending correlated requests
+	router.HandleFunc("/correlations", s.handleListCorrelations).Methods("GET")
+	
+	// Installing example flows
+	router.HandleFunc("/examples/{name}/install", s.handleInstallExample).Methods("POST")
+}
+
+// flowDetails is a flow's definition, without credentials, with its status
+// and revision
+type flowDetails struct {
+	engine.FlowDefinition
+	Status   string           `json:"status"`
+	State    engine.FlowState `json:"state"`
+	Disabled bool             `json:"disabled"`
+	Revision string           `json:"revision"`
 }
 
-// handleCreateFlow handles POST /api/flows
+// newFlowDetails describes a flow
+func newFlowDetails(flow *engine.Flow) (flowDetails, error) {
+	details := flowDetails{
+		Status:   string(flow.GetStatus()),
+		State:    flow.State(),
+		Disabled: flow.Disabled(),
+	}
+	flowJSON, err := flow.PublicJSON()
+	if err != nil {
+		return details, err
+	}
+	details.Revision, err = flow.Revision()
+	if err != nil {
+		return details, err
+	}
+	err = engine.DecodeJSON(flowJSON, &details.FlowDefinition)
+	return details, err
+}
+
+// handleCreateFlow handles POST /api/flows, which creates a flow; flows
+// that exist are updated with PUT /api/flows/{id}
 func (s *Server) handleCreateFlow(w http.ResponseWriter, r *http.Request) {
 	var flowDef map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&flowDef); err != nil {
+	if err := engine.ReadJSON(r.Body, &flowDef); err != nil {
 		respondError(w, http.StatusBadRequest, "Invalid flow definition")
 		return
 	}
@@ -124,6 +324,17 @@ func (s *Server) handleCreateFlow(w http.ResponseWriter, r *http.Request) {
 		id = fmt.Sprintf("flow-%d", time.Now().UnixNano())
 		flowDef["id"] = id
 	}
+	// Slashes separate the workspace from the ID in flow keys
+	if strings.Contains(id, "/") {
+		respondError(w, http.StatusBadRequest, "Flow ID must not contain '/'")
+		return
+	}
+	
+	deploymentType, err := takeDeploymentType(flowDef)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	
 	// Convert to JSON
 	flowJSON, err := json.Marshal(flowDef)
@@ -133,58 +344,112 @@ func (s *Server) handleCreateFlow(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	// Deploy flow
-	if err := s.engine.DeployFlow(id, flowJSON); err != nil {
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to deploy flow: %v", err))
+	key := engine.FlowKey(requestWorkspace(r), id)
+	if !s.checkFlowLock(w, r, key) {
+		return
+	}
+	err = s.engine.CreateFlowAs(key, flowJSON, deploymentType)
+	if errors.Is(err, engine.ErrFlowExists) {
+		respondError(w, http.StatusConflict, "Flow already exists; update it with PUT /api/flows/"+id)
+		return
+	}
+	if err != nil {
+		respondDeployError(w, err)
 		return
 	}
 	
-	respond(w, http.StatusCreated, map[string]interface{}{
-		"id": id,
-	})
+	result := s.deployResponse(key, id)
+	w.Header().Set("ETag", flowETag(result.Revision))
+	respond(w, http.StatusCreated, result)
+}
+
+// deployRequest is the body of deploying a flow. Handlers read it as a map
+// so that fields of the definition this version does not know are kept.
+type deployRequest struct {
+	engine.FlowDefinition
+	// DeploymentType is "full" (default), "nodes" or "flows"
+	DeploymentType string `json:"deploymentType,omitempty"`
+}
+
+// takeDeploymentType removes the deploymentType of a deploy request from
+// the flow definition and parses it: "full" (default), "nodes" or "flows"
+func takeDeploymentType(flowDef map[string]interface{}) (engine.DeploymentType, error) {
+	value, _ := flowDef["deploymentType"].(string)
+	delete(flowDef, "deploymentType")
+	return engine.ParseDeploymentType(value)
+}
+
+// deployResult is the response to a deploy, with the flow's new revision
+// and the node types of the flow that are not installed, if any
+type deployResult struct {
+	ID           string   `json:"id"`
+	Revision     string   `json:"revision,omitempty"`
+	MissingTypes []string `json:"missingTypes,omitempty"`
+}
+
+// deployResponse returns the response to the deploy of a flow
+func (s *Server) deployResponse(key, id string) deployResult {
+	response := deployResult{ID: id}
+	if flow, exists := s.engine.GetFlow(key); exists {
+		response.MissingTypes = flow.MissingTypes()
+		response.Revision, _ = flow.Revision()
+	}
+	return response
 }
 
 // handleGetFlow handles GET /api/flows/{id}
 func (s *Server) handleGetFlow(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
-	
-	flow, exists := s.engine.GetFlow(id)
+	flow, exists := s.engine.GetFlow(flowKey(r))
 	if !exists {
+		if s.respondQuarantinedFlow(w, r) {
+			return
+		}
 		respondError(w, http.StatusNotFound, "Flow not found")
 		return
 	}
 	
-	flowJSON, err := flow.ToJSON()
+	details, err := newFlowDetails(flow)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to marshal flow")
 		return
 	}
 	
-	var flowMap map[string]interface{}
-	if err := json.Unmarshal(flowJSON, &flowMap); err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to unmarshal flow")
-		return
-	}
-	
-	// Add status
-	flowMap["status"] = string(flow.GetStatus())
-	
-	respond(w, http.StatusOK, flowMap)
+	w.Header().Set("ETag", flowETag(details.Revision))
+	respond(w, http.StatusOK, details)
 }
 
-// handleUpdateFlow handles PUT /api/flows/{id}
+// handleUpdateFlow handles PUT /api/flows/{id}, which expects the flow's
+// revision in If-Match; with ?dryRun=true it only validates the flow like
+// POST /api/flows/validate
 func (s *Server) handleUpdateFlow(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
+	id := mux.Vars(r)["id"]
+	key := flowKey(r)
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+	
+	if !dryRun && !s.checkFlowLock(w, r, key) {
+		return
+	}
+	revision := ""
+	if !dryRun {
+		var ok bool
+		if revision, ok = s.expectedRevision(w, r, key); !ok {
+			return
+		}
+	}
 	
 	var flowDef map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&flowDef); err != nil {
+	if err := engine.ReadJSON(r.Body, &flowDef); err != nil {
 		respondError(w, http.StatusBadRequest, "Invalid flow definition")
 		return
 	}
 	
 	// Ensure ID matches
 	flowDef["id"] = id
+	deploymentType, err := takeDeploymentType(flowDef)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	
 	// Convert to JSON
 	flowJSON, err := json.Marshal(flowDef)
@@ -193,107 +458,462 @@ func (s *Server) handleUpdateFlow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	if dryRun {
+		respond(w, http.StatusOK, s.engine.DryRunFlow(key, flowJSON))
+		return
+	}
+	
 	// Deploy flow
-	if err := s.engine.DeployFlow(id, flowJSON); err != nil {
-		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to deploy flow: %v", err))
+	if err := s.engine.DeployFlowAt(key, flowJSON, deploymentType, revision); err != nil {
+		respondDeployError(w, err)
 		return
 	}
 	
-	respond(w, http.StatusOK, map[string]interface{}{
-		"id": id,
-	})
+	result := s.deployResponse(key, id)
+	w.Header().Set("ETag", flowETag(result.Revision))
+	respond(w, http.StatusOK, result)
+}
+
+// handleValidateFlow handles POST /api/flows/validate, which checks a flow
+// definition like a deploy would, without saving or starting it, and lists
+// the errors and warnings found
+func (s *Server) handleValidateFlow(w http.ResponseWriter, r *http.Request) {
+	var flowDef map[string]interface{}
+	if err := engine.ReadJSON(r.Body, &flowDef); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid flow definition")
+		return
+	}
+	delete(flowDef, "deploymentType")
+	
+	id, _ := flowDef["id"].(string)
+	flowJSON, err := json.Marshal(flowDef)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to marshal flow definition")
+		return
+	}
+	
+	respond(w, http.StatusOK, s.engine.DryRunFlow(engine.FlowKey(requestWorkspace(r), id), flowJSON))
 }
 
 // handleDeleteFlow handles DELETE /api/flows/{id}
 func (s *Server) handleDeleteFlow(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
+	key := flowKey(r)
+	
+	if !s.checkFlowLock(w, r, key) {
+		return
+	}
 	
-	if err := s.engine.DeleteFlow(id); err != nil {
+	if err := s.engine.DeleteFlow(key); err != nil {
 		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to delete flow: %v", err))
 		return
 	}
+	if err := s.engine.FlowLocks().Release(key, requestUser(r), true); err != nil {
+		s.engine.Logger().Warn("failed to release lock of deleted flow", "flow_id", key, "error", err)
+	}
 	
-	respond(w, http.StatusOK, map[string]interface{}{
-		"success": true,
-	})
+	respond(w, http.StatusOK, successResponse{Success: true})
 }
 
 // handleStartFlow handles POST /api/flows/{id}/start
 func (s *Server) handleStartFlow(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
+	key := flowKey(r)
 	
-	flow, exists := s.engine.GetFlow(id)
+	flow, exists := s.engine.GetFlow(key)
 	if !exists {
 		respondError(w, http.StatusNotFound, "Flow not found")
 		return
 	}
 	
-	if err := flow.Start(r.Context()); err != nil {
+	if err := s.engine.StartFlow(key); err != nil {
 		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to start flow: %v", err))
 		return
 	}
 	
-	respond(w, http.StatusOK, map[string]interface{}{
-		"success": true,
-		"status":  string(flow.GetStatus()),
-	})
+	respond(w, http.StatusOK, flowStatusResult{Success: true, Status: string(flow.GetStatus())})
+}
+
+// flowStatusResult is the response of starting and stopping a flow
+type flowStatusResult struct {
+	Success bool   `json:"success"`
+	Status  string `json:"status"`
 }
 
 // handleStopFlow handles POST /api/flows/{id}/stop
 func (s *Server) handleStopFlow(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
+	key := flowKey(r)
 	
-	flow, exists := s.engine.GetFlow(id)
+	flow, exists := s.engine.GetFlow(key)
 	if !exists {
 		respondError(w, http.StatusNotFound, "Flow not found")
 		return
 	}
 	
-	flow.Stop()
+	if err := s.engine.StopFlow(key); err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to stop flow: %v", err))
+		return
+	}
+	
+	respond(w, http.StatusOK, flowStatusResult{Success: true, Status: string(flow.GetStatus())})
+}
+
+// handleEnableFlow handles PUT /api/flows/{id}/enable, which starts the
+// flow and has it start with the engine again
+func (s *Server) handleEnableFlow(w http.ResponseWriter, r *http.Request) {
+	s.setFlowDisabled(w, r, false)
+}
+
+// handleDisableFlow handles PUT /api/flows/{id}/disable, which stops the
+// flow and keeps it stopped across restarts
+func (s *Server) handleDisableFlow(w http.ResponseWriter, r *http.Request) {
+	s.setFlowDisabled(w, r, true)
+}
+
+// setFlowDisabled disables or enables the flow of a request
+func (s *Server) setFlowDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	key := flowKey(r)
+	
+	flow, exists := s.engine.GetFlow(key)
+	if !exists {
+		respondError(w, http.StatusNotFound, "Flow not found")
+		return
+	}
+	if flow.Remote() {
+		respondError(w, http.StatusBadRequest, "Flow runs on agents; redeploy it instead")
+		return
+	}
+	if !s.checkFlowLock(w, r, key) {
+		return
+	}
+	
+	if err := s.engine.SetFlowDisabled(key, disabled); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 	
-	respond(w, http.StatusOK, map[string]interface{}{
-		"success": true,
-		"status":  string(flow.GetStatus()),
+	respond(w, http.StatusOK, flowDisabledResult{
+		Success:  true,
+		Disabled: flow.Disabled(),
+		Status:   string(flow.GetStatus()),
 	})
 }
 
+// flowDisabledResult is the response of enabling and disabling a flow
+type flowDisabledResult struct {
+	Success  bool   `json:"success"`
+	Disabled bool   `json:"disabled"`
+	Status   string `json:"status"`
+}
+
+// handleGetNode handles GET /api/flows/{id}/nodes/{nodeId}
+func (s *Server) handleGetNode(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	
+	flow, exists := s.engine.GetFlow(flowKey(r))
+	if !exists {
+		respondError(w, http.StatusNotFound, "Flow not found")
+		return
+	}
+	
+	node, exists := flow.GetNode(vars["nodeId"])
+	if !exists {
+		respondError(w, http.StatusNotFound, "Node not found")
+		return
+	}
+	
+	details := nodeDetails{
+		ID:      node.ID,
+		Name:    node.Name,
+		Type:    node.Type.Name,
+		Running: node.IsRunning(),
+		Status:  node.GetStatus(),
+	}
+	if state, ok := node.GetState(); ok {
+		details.State = state
+	}
+	
+	respond(w, http.StatusOK, details)
+}
+
+// nodeDetails describes a running node, with its state if it keeps one
+type nodeDetails struct {
+	ID      string                 `json:"id"`
+	Name    string                 `json:"name"`
+	Type    string                 `json:"type"`
+	Running bool                   `json:"running"`
+	Status  engine.NodeStatus      `json:"status"`
+	State   map[string]interface{} `json:"state,omitempty"`
+}
+
+// handleGetLastMessages handles GET /api/flows/{id}/nodes/{nodeId}/last
+func (s *Server) handleGetLastMessages(w http.ResponseWriter, r *http.Request) {
+	node, ok := s.retainingNode(w, r)
+	if !ok {
+		return
+	}
+	
+	respond(w, http.StatusOK, lastMessages{ID: node.ID, Messages: node.LastMessages()})
+}
+
+// lastMessages are the messages a node retains
+type lastMessages struct {
+	ID       string                   `json:"id"`
+	Messages []engine.RetainedMessage `json:"messages"`
+}
+
+// handleClearLastMessages handles DELETE /api/flows/{id}/nodes/{nodeId}/last
+func (s *Server) handleClearLastMessages(w http.ResponseWriter, r *http.Request) {
+	node, ok := s.retainingNode(w, r)
+	if !ok {
+		return
+	}
+	
+	node.ClearRetained()
+	respond(w, http.StatusOK, successResponse{Success: true})
+}
+
+// retainingNode looks up the node of a request and checks that it retains
+// messages; otherwise it writes the error response
+func (s *Server) retainingNode(w http.ResponseWriter, r *http.Request) (*engine.Node, bool) {
+	vars := mux.Vars(r)
+	
+	flow, exists := s.engine.GetFlow(flowKey(r))
+	if !exists {
+		respondError(w, http.StatusNotFound, "Flow not found")
+		return nil, false
+	}
+	
+	node, exists := flow.GetNode(vars["nodeId"])
+	if !exists {
+		respondError(w, http.StatusNotFound, "Node not found")
+		return nil, false
+	}
+	if !node.RetainsMessages() {
+		respondError(w, http.StatusBadRequest, "Node does not retain messages")
+		return nil, false
+	}
+	
+	return node, true
+}
+
+// handleEnableNode handles POST /api/flows/{id}/nodes/{nodeId}/enable
+func (s *Server) handleEnableNode(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	
+	flow, exists := s.engine.GetFlow(flowKey(r))
+	if !exists {
+		respondError(w, http.StatusNotFound, "Flow not found")
+		return
+	}
+	
+	node, exists := flow.GetNode(vars["nodeId"])
+	if !exists {
+		respondError(w, http.StatusNotFound, "Node not found")
+		return
+	}
+	
+	// An empty body enables the node
+	var request nodeEnableRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	enabled := request.Enabled == nil || *request.Enabled
+	
+	if err := node.SetEnabled(enabled); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	
+	respond(w, http.StatusOK, nodeEnabledResult{Success: true, Enabled: enabled})
+}
+
+// nodeEnableRequest is the optional body of enabling a node
+type nodeEnableRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// nodeEnabledResult is the response of enabling and disabling a node
+type nodeEnabledResult struct {
+	Success bool `json:"success"`
+	Enabled bool `json:"enabled"`
+}
+
+// handleUpdateNodeConfig handles PATCH /api/flows/{id}/nodes/{nodeId}/config,
+// which changes dynamic config fields without a redeploy
+func (s *Server) handleUpdateNodeConfig(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	
+	flow, exists := s.engine.GetFlow(flowKey(r))
+	if !exists {
+		respondError(w, http.StatusNotFound, "Flow not found")
+		return
+	}
+	node, exists := flow.GetNode(vars["nodeId"])
+	if !exists {
+		respondError(w, http.StatusNotFound, "Node not found")
+		return
+	}
+	if flow.Remote() {
+		respondError(w, http.StatusBadRequest, "Flow runs on agents; redeploy it instead")
+		return
+	}
+	if !s.checkFlowLock(w, r, flowKey(r)) {
+		return
+	}
+	
+	var patch map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid config patch")
+		return
+	}
+	patchJSON, _ := json.Marshal(patch)
+	
+	err := s.engine.UpdateNodeConfig(flowKey(r), node.ID, patchJSON)
+	var notDynamic *engine.DynamicFieldsError
+	var invalidConfig *engine.ConfigError
+	switch {
+	case errors.As(err, &notDynamic):
+		respond(w, http.StatusBadRequest, dynamicFieldsErrorResponse{
+			Error:  "Fields cannot be changed at runtime",
+			Fields: notDynamic.Fields,
+		})
+		return
+	case errors.As(err, &invalidConfig):
+		respond(w, http.StatusBadRequest, configErrorResponse{
+			Error:  "Invalid config",
+			Fields: invalidConfig.Fields,
+		})
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	
+	respond(w, http.StatusOK, nodeConfigResult{Success: true, Config: node.PublicConfig()})
+}
+
+// dynamicFieldsErrorResponse lists the fields of a config patch that cannot
+// be changed at runtime
+type dynamicFieldsErrorResponse struct {
+	Error  string   `json:"error"`
+	Fields []string `json:"fields"`
+}
+
+// configErrorResponse lists the invalid fields of a node's config
+type configErrorResponse struct {
+	Error  string              `json:"error"`
+	Fields []engine.FieldError `json:"fields"`
+}
+
+// nodeConfigResult is the response of patching a node's config, with the
+// merged config without credentials
+type nodeConfigResult struct {
+	Success bool            `json:"success"`
+	Config  json.RawMessage `json:"config"`
+}
+
 // handleListNodeTypes handles GET /api/nodes
 func (s *Server) handleListNodeTypes(w http.ResponseWriter, r *http.Request) {
 	nodeTypes := s.engine.GetRegistry().GetAllNodeTypes()
-	types := make([]map[string]interface{}, 0, len(nodeTypes))
+	types := make([]nodeTypeInfo, 0, len(nodeTypes))
 	
 	for _, nt := range nodeTypes {
-		types = append(types, map[string]interface{}{
-			"name":        nt.Name,
-			"description": nt.Description,
-			"category":    nt.Category,
-			"defaults":    nt.Defaults,
+		types = append(types, nodeTypeInfo{
+			Name:        nt.Name,
+			Description: nt.Description,
+			Category:    nt.Category,
+			Virtual:     nt.Virtual,
+			Parallel:    nt.Parallel,
+			Defaults:    nt.Defaults,
+			Dynamic:     nt.Dynamic,
+			Credentials: nt.Credentials,
 		})
 	}
 	
-	respond(w, http.StatusOK, map[string]interface{}{
-		"nodes": types,
-	})
+	// Subflows are offered as node types too
+	for _, subflow := range s.engine.GetRegistry().GetAllSubflows() {
+		category := subflow.Category
+		if category == "" {
+			category = "subflows"
+		}
+		inputs := 0
+		if len(subflow.In) > 0 {
+			inputs = 1
+		}
+		outputs := len(subflow.Out)
+		types = append(types, nodeTypeInfo{
+			Name:        subflow.TypeName(),
+			Label:       subflow.Name,
+			Description: subflow.Description,
+			Category:    category,
+			Subflow:     true,
+			Inputs:      &inputs,
+			Outputs:     &outputs,
+			Env:         subflow.Env,
+		})
+	}
+	
+	respond(w, http.StatusOK, nodeTypeList{Nodes: types})
+}
+
+// nodeTypeInfo describes a node type, or a subflow offered as one
+type nodeTypeInfo struct {
+	Name        string          `json:"name"`
+	Label       string          `json:"label,omitempty"`
+	Description string          `json:"description"`
+	Category    string          `json:"category"`
+	Virtual     bool            `json:"virtual"`
+	Parallel    bool            `json:"parallel"`
+	Defaults    json.RawMessage `json:"defaults"`
+	Dynamic     []string        `json:"dynamic"`
+	Credentials []string        `json:"credentials"`
+	// Subflow types have inputs, outputs and default environment values
+	Subflow bool                   `json:"subflow,omitempty"`
+	Inputs  *int                   `json:"inputs,omitempty"`
+	Outputs *int                   `json:"outputs,omitempty"`
+	Env     map[string]interface{} `json:"env,omitempty"`
+}
+
+// nodeTypeList is the response of GET /api/nodes
+type nodeTypeList struct {
+	Nodes []nodeTypeInfo `json:"nodes"`
 }
 
 // handleGetSettings handles GET /api/settings
 func (s *Server) handleGetSettings(w http.ResponseWriter, r *http.Request) {
 	// For now, just return a dummy response
-	respond(w, http.StatusOK, map[string]interface{}{
-		"httpPort": s.config.GetInt("http.port"),
-		"version":  "0.1.0",
-	})
+	respond(w, http.StatusOK, settingsInfo{HTTPPort: s.config.GetInt("http.port"), Version: "0.1.0"})
+}
+
+// settingsInfo is the response of GET /api/settings
+type settingsInfo struct {
+	HTTPPort int    `json:"httpPort"`
+	Version  string `json:"version"`
 }
 
 // handleUpdateSettings handles PUT /api/settings
 func (s *Server) handleUpdateSettings(w http.ResponseWriter, r *http.Request) {
 	// For now, just return a success response
-	respond(w, http.StatusOK, map[string]interface{}{
-		"success": true,
-	})
+	respond(w, http.StatusOK, successResponse{Success: true})
+}
+
+// handleListCorrelations handles GET /api/correlations
+func (s *Server) handleListCorrelations(w http.ResponseWriter, r *http.Request) {
+	correlations := s.engine.Correlations()
+	workspace := requestWorkspace(r)
+	pending := make([]engine.Correlation, 0)
+	for _, correlation := range correlations.Pending() {
+		if correlation.Workspace == workspace || (correlation.Workspace == "" && workspace == engine.DefaultWorkspace) {
+			pending = append(pending, correlation)
+		}
+	}
+	respond(w, http.StatusOK, correlationList{MaxPending: correlations.MaxPending(), Pending: pending})
+}
+
+// correlationList is the response of GET /api/correlations
+type correlationList struct {
+	MaxPending int                  `json:"maxPending"`
+	Pending    []engine.Correlation `json:"pending"`
 }
 
 // respond sends a JSON response
@@ -305,7 +925,44 @@ func respond(w http.ResponseWriter, status int, data interface{}) {
 
 // respondError sends an error response
 func respondError(w http.ResponseWriter, status int, message string) {
-	respond(w, status, map[string]interface{}{
-		"error": message,
+	respond(w, status, errorResponse{Error: message})
+}
+
+// errorResponse is the body of failed requests
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// successResponse is the body of requests that return nothing else
+type successResponse struct {
+	Success bool `json:"success"`
+}
+
+// respondDeployError sends the error of a failed deployment, listing every
+// problem of a structurally invalid flow; invalid flows and node
+// configurations are the client's fault
+func respondDeployError(w http.ResponseWriter, err error) {
+	if respondRevisionError(w, err) {
+		return
+	}
+	var invalid *engine.ValidationError
+	var invalidConfig *engine.ConfigError
+	switch {
+	case errors.As(err, &invalidConfig):
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Failed to deploy flow: %v", err))
+		return
+	case !errors.As(err, &invalid):
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to deploy flow: %v", err))
+		return
+	}
+	respond(w, http.StatusBadRequest, validationErrorResponse{
+		Error:  "Invalid flow definition",
+		Issues: invalid.Issues,
 	})
 }
+
+// validationErrorResponse lists the problems of an invalid flow
+type validationErrorResponse struct {
+	Error  string                   `json:"error"`
+	Issues []engine.ValidationIssue `json:"issues"`
+}
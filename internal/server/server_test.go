@@ -0,0 +1,33 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/yourusername/go-red/internal/config"
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/registry"
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// newTestServer returns a server of a running engine without flows, with
+// the given settings and node types
+func newTestServer(t *testing.T, settings map[string]interface{}, types ...*engine.NodeType) *Server {
+	t.Helper()
+	cfg := config.New()
+	for key, value := range settings {
+		cfg.Set(key, value)
+	}
+	reg := registry.New()
+	for _, nodeType := range types {
+		if err := reg.RegisterNodeType(nodeType); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store := storage.NewMemoryStorage()
+	eng := engine.New(reg, store)
+	if err := eng.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { eng.Stop() })
+	return New(cfg, eng, store)
+}
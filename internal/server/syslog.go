@@ -0,0 +1,14 @@
+//go:build !windows && !plan9
+
+package server
+
+import (
+	"io"
+	"log/syslog"
+)
+
+// dialSyslog connects to the syslog daemon at address over network, or to
+// the local one if both are empty
+func dialSyslog(network, address, tag string) (io.WriteCloser, error) {
+	return syslog.Dial(network, address, syslog.LOG_NOTICE|syslog.LOG_AUTH, tag)
+}
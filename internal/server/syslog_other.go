@@ -0,0 +1,13 @@
+//go:build windows || plan9
+
+package server
+
+import (
+	"errors"
+	"io"
+)
+
+// dialSyslog is not supported on Windows and Plan 9
+func dialSyslog(network, address, tag string) (io.WriteCloser, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
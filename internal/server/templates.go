@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/templates"
+)
+
+// handleExportTemplate handles GET /api/flows/{id}/template, which returns
+// the flow as a template. The optional body marks config values as
+// parameters: {"name", "description", "parameters": [{"name", "node",
+// "field", "type", "default", "description", "secret", "env"}]}.
+// Placeholders already in the flow are declared as well.
+func (s *Server) handleExportTemplate(w http.ResponseWriter, r *http.Request) {
+	flow, exists := s.engine.GetFlow(flowKey(r))
+	if !exists {
+		respondError(w, http.StatusNotFound, "Flow not found")
+		return
+	}
+
+	var request templateExportRequest
+	if err := engine.ReadJSON(r.Body, &request); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, http.StatusBadRequest, "Invalid template request")
+		return
+	}
+
+	flowDef, err := flow.ToJSON()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to marshal flow")
+		return
+	}
+	template, err := templates.Export(flowDef, request.Name, request.Description, request.Parameters)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respond(w, http.StatusOK, template)
+}
+
+// templateExportRequest is the optional body of GET /api/flows/{id}/template
+type templateExportRequest struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Parameters  []templates.Mark `json:"parameters"`
+}
+
+// handleDeployTemplate handles POST /api/flows/from-template, which creates
+// a flow from {"template", "parameters", "id"} and deploys it
+func (s *Server) handleDeployTemplate(w http.ResponseWriter, r *http.Request) {
+	var request templateDeployRequest
+	if err := engine.ReadJSON(r.Body, &request); err != nil || request.Template == nil {
+		respondError(w, http.StatusBadRequest, "Expected template and parameters")
+		return
+	}
+
+	flowDef, err := request.Template.Instantiate(request.Parameters)
+	var invalid *templates.ValidationError
+	if errors.As(err, &invalid) {
+		respond(w, http.StatusBadRequest, templateErrorResponse{
+			Error:    err.Error(),
+			Problems: invalid.Problems,
+		})
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var flow map[string]interface{}
+	if err := engine.DecodeJSON(flowDef, &flow); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	id := request.ID
+	if id == "" {
+		id, _ = flow["id"].(string)
+	}
+	if id == "" {
+		id = fmt.Sprintf("flow-%d", time.Now().UnixNano())
+	}
+	// Slashes separate the workspace from the ID in flow keys
+	if strings.Contains(id, "/") {
+		respondError(w, http.StatusBadRequest, "Flow ID must not contain '/'")
+		return
+	}
+	flow["id"] = id
+	if flowDef, err = json.Marshal(flow); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to marshal flow definition")
+		return
+	}
+
+	key := engine.FlowKey(requestWorkspace(r), id)
+	if !s.checkFlowLock(w, r, key) {
+		return
+	}
+	if err := s.engine.DeployFlow(key, flowDef); err != nil {
+		respondDeployError(w, err)
+		return
+	}
+	respond(w, http.StatusCreated, createdFlow{ID: id})
+}
+
+// templateDeployRequest is the body of POST /api/flows/from-template
+type templateDeployRequest struct {
+	Template   *templates.Template    `json:"template"`
+	Parameters map[string]interface{} `json:"parameters"`
+	// ID replaces the flow ID of the template
+	ID string `json:"id"`
+}
+
+// templateErrorResponse lists the problems of a template or its parameters
+type templateErrorResponse struct {
+	Error    string   `json:"error"`
+	Problems []string `json:"problems"`
+}
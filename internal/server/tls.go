@@ -0,0 +1,156 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// certCheckInterval is how often the certificate files are checked for
+// changes, such as renewals
+const certCheckInterval = 30 * time.Second
+
+// certificate serves the certificate of https.certFile and https.keyFile
+// to TLS handshakes, reloading it when the files change. Connections keep
+// the certificate they were established with, so a reload drops none.
+type certificate struct {
+	certFile, keyFile string
+	mu                sync.RWMutex
+	cert              *tls.Certificate
+	// modified is the newest modification time of the files loaded
+	modified time.Time
+	done     chan struct{}
+}
+
+// load reads the certificate and key, keeping the previous pair if they
+// are invalid
+func (c *certificate) load() error {
+	modified := newestModTime(c.certFile, c.keyFile)
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("invalid TLS certificate or key: %w", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cert = &cert
+	c.modified = modified
+	return nil
+}
+
+// get returns the current certificate to handshakes
+func (c *certificate) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+// watch reloads the certificate whenever its files change, until done is
+// closed
+func (c *certificate) watch() {
+	ticker := time.NewTicker(certCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		c.mu.RLock()
+		changed := newestModTime(c.certFile, c.keyFile).After(c.modified)
+		c.mu.RUnlock()
+		if !changed {
+			continue
+		}
+		if err := c.load(); err != nil {
+			slog.Warn("failed to reload TLS certificate", "error", err)
+			continue
+		}
+		slog.Info("reloaded TLS certificate", "file", c.certFile)
+	}
+}
+
+// newestModTime returns the latest modification time of files
+func newestModTime(files ...string) time.Time {
+	var newest time.Time
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil && info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest
+}
+
+// EnableTLS makes the server serve HTTPS with the certificate and key of
+// https.certFile and https.keyFile, which it reloads when they change. It
+// fails if they do not make a valid pair.
+func (s *Server) EnableTLS() error {
+	cert := &certificate{
+		certFile: s.config.GetString("https.certFile"),
+		keyFile:  s.config.GetString("https.keyFile"),
+		done:     make(chan struct{}),
+	}
+	if cert.certFile == "" || cert.keyFile == "" {
+		return fmt.Errorf("https.certFile and https.keyFile are required for HTTPS")
+	}
+	if err := cert.load(); err != nil {
+		return err
+	}
+
+	s.http.TLSConfig = &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+		// TLS 1.3 suites are not configurable; these are the TLS 1.2 ones
+		// with forward secrecy and authenticated encryption
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+		GetCertificate: cert.get,
+	}
+	s.cert = cert
+	go cert.watch()
+	return nil
+}
+
+// ReloadCertificate loads the TLS certificate and key again, e.g. on
+// SIGHUP after a renewal. Connections keep the certificate they started
+// with, and new ones get the reloaded one.
+func (s *Server) ReloadCertificate() error {
+	if s.cert == nil {
+		return nil
+	}
+	return s.cert.load()
+}
+
+// ServeRedirect answers requests on a plain HTTP listener with a 301
+// redirect to the same URL over HTTPS on httpsPort, until Shutdown is
+// called
+func (s *Server) ServeRedirect(listener net.Listener, httpsPort int) error {
+	redirect := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := r.Host
+			if h, _, err := net.SplitHostPort(r.Host); err == nil {
+				host = h
+			}
+			if httpsPort != 443 {
+				host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+			}
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+		}),
+		ReadHeaderTimeout: s.http.ReadHeaderTimeout,
+	}
+	s.redirectMu.Lock()
+	s.redirect = redirect
+	s.redirectMu.Unlock()
+	return redirect.Serve(listener)
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// handleListFlowVersions handles GET /api/flows/{id}/versions, which lists
+// the saved versions of a flow, newest first, with their revision,
+// timestamp, size and hash. Versions outlive the flow.
+func (s *Server) handleListFlowVersions(w http.ResponseWriter, r *http.Request) {
+	versions, err := s.engine.FlowVersions(flowKey(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list flow versions: %v", err))
+		return
+	}
+	respond(w, http.StatusOK, flowVersionList{Versions: versions})
+}
+
+// flowVersionList is the response of GET /api/flows/{id}/versions
+type flowVersionList struct {
+	Versions []storage.FlowVersion `json:"versions"`
+}
+
+// handleRestoreFlowVersion handles POST /api/flows/{id}/versions/{rev}/restore,
+// which deploys a saved version of a flow, deleted or not
+func (s *Server) handleRestoreFlowVersion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	key := flowKey(r)
+	if !s.checkFlowLock(w, r, key) {
+		return
+	}
+
+	err := s.engine.RestoreFlowVersion(key, vars["rev"])
+	if errors.Is(err, storage.ErrVersionNotFound) {
+		respondError(w, http.StatusNotFound, "Flow version not found")
+		return
+	}
+	if err != nil {
+		respondDeployError(w, err)
+		return
+	}
+
+	respond(w, http.StatusOK, s.deployResponse(key, vars["id"]))
+}
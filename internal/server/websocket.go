@@ -2,12 +2,14 @@ package server
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/yourusername/go-red/internal/config"
 	"github.com/yourusername/go-red/internal/engine"
 )
 
@@ -17,17 +19,41 @@ type WebSocketManager struct {
 	register   chan *WebSocketClient
 	unregister chan *WebSocketClient
 	broadcast  chan []byte
-	mu         sync.RWMutex
+	locks      *engine.FlowLocks
+	// config holds the keepalive settings, read for each connection
+	config *config.Config
+	logger *slog.Logger
+	mu     sync.RWMutex
+	// debug is set on the manager of /ws/debug, whose clients choose the
+	// events they receive with filters
+	debug bool
 }
 
+// Default keepalive of WebSocket connections
+const (
+	defaultPingInterval = 30 * time.Second
+	defaultPongTimeout  = 60 * time.Second
+)
+
 // WebSocketClient represents a WebSocket client
 type WebSocketClient struct {
-	manager  *WebSocketManager
-	conn     *websocket.Conn
-	send     chan []byte
-	flowID   string
-	userID   string
-	lastPing time.Time
+	manager *WebSocketManager
+	conn    *websocket.Conn
+	send    chan []byte
+	flowID  string
+	userID  string
+	// workspace selects the events the client receives
+	workspace string
+	lastPing  time.Time
+	// heldLocks are the flows whose locks the client renews; they are
+	// released when it disconnects
+	heldLocks map[string]bool
+	// pingInterval is how often the client is pinged, and pongTimeout how
+	// long it may stay silent before it is disconnected
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	// filter holds the *debugFilter of a /ws/debug client
+	filter atomic.Value
 }
 
 // WebSocketMessage represents a message sent over WebSocket
@@ -43,6 +69,7 @@ func NewWebSocketManager() *WebSocketManager {
 		register:   make(chan *WebSocketClient),
 		unregister: make(chan *WebSocketClient),
 		broadcast:  make(chan []byte),
+		logger:     slog.Default(),
 	}
 }
 
@@ -76,6 +103,13 @@ func (m *WebSocketManager) Run() {
 	}
 }
 
+// ClientCount returns the number of connected clients
+func (m *WebSocketManager) ClientCount() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.clients)
+}
+
 // BroadcastToAll sends a message to all clients
 func (m *WebSocketManager) BroadcastToAll(message []byte) {
 	m.broadcast <- message
@@ -97,29 +131,289 @@ func (m *WebSocketManager) BroadcastToFlow(flowID string, message []byte) {
 	}
 }
 
+// BroadcastEvent sends an engine event to all clients
+func (m *WebSocketManager) BroadcastEvent(event engine.Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		m.logger.Error("failed to marshal event", "error", err)
+		return
+	}
+
+	message, err := json.Marshal(WebSocketMessage{
+		Type:    event.Type,
+		Payload: payload,
+	})
+	if err != nil {
+		m.logger.Error("failed to marshal WebSocket message", "error", err)
+		return
+	}
+
+	m.broadcastToWorkspace(event.Workspace, message)
+}
+
+// broadcastToWorkspace sends a message to the clients of a workspace, or to
+// all clients if workspace is empty
+func (m *WebSocketManager) broadcastToWorkspace(workspace string, message []byte) {
+	if workspace == "" {
+		m.BroadcastToAll(message)
+		return
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for client := range m.clients {
+		if client.workspace == workspace {
+			select {
+			case client.send <- message:
+			default:
+				// Client send buffer is full, skip
+			}
+		}
+	}
+}
+
+// keepalive returns the ping interval and pong timeout of new connections,
+// from websocket.pingInterval and websocket.pongTimeout. The timeout is at
+// least twice the interval, so that one lost pong is not fatal.
+func (m *WebSocketManager) keepalive() (time.Duration, time.Duration) {
+	ping, pong := defaultPingInterval, defaultPongTimeout
+	if m.config != nil {
+		ping = config.GetOrDefault(m.config, "websocket.pingInterval", ping)
+		pong = config.GetOrDefault(m.config, "websocket.pongTimeout", pong)
+	}
+	if ping <= 0 {
+		ping = defaultPingInterval
+	}
+	if pong < 2*ping {
+		pong = 2 * ping
+	}
+	return ping, pong
+}
+
 // HandleWebSocket handles WebSocket connections
 func (m *WebSocketManager) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	upgrader := websocket.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
+		// Pages of other origins may connect if api.cors.origins lists
+		// them
 		CheckOrigin: func(r *http.Request) bool {
-			return true // Allow all origins for now
+			return sameOrigin(r) || originAllowed(m.config, r.Header.Get("Origin"))
 		},
+		// Browsers pass their token as a subprotocol, and fail the
+		// connection unless one is accepted
+		Subprotocols: []string{authProtocol},
 	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("Failed to upgrade connection: %v", err)
+		m.logger.Warn("failed to upgrade connection", "remote", r.RemoteAddr, "error", err)
 		return
 	}
 
 	client := &WebSocketClient{
-		manager:  m,
-		conn:     conn,
-		send:     make(chan []byte, 256),
-		lastPing: time.Now(),
+		manager:   m,
+		conn:      conn,
+		send:      make(chan []byte, 256),
+		lastPing:  time.Now(),
+		workspace: engine.DefaultWorkspace,
+	}
+	client.pingInterval, client.pongTimeout = m.keepalive()
+	if workspace := r.URL.Query().Get("workspace"); workspace != "" {
+		client.workspace = workspace
 	}
 
 	// Get flowID from query parameters
 	flowID := r.URL.Query().Get("flowId")
 	if flowID != "" {
+		client.flowID = flowID
+	}
+	if m.debug {
+		filter, err := newDebugFilter(r.URL.Query()["flow"], r.URL.Query().Get("severity"))
+		if err != nil {
+			filter = &debugFilter{severity: slog.LevelDebug}
+			m.logger.Warn("invalid debug filter", "remote", r.RemoteAddr, "error", err)
+		}
+		client.filter.Store(filter)
+	}
+	
+	// Get userID from the token, or else from query parameters
+	userID := requestUser(r)
+	if userID != "" {
+		client.userID = userID
+	}
+	
+	// Register client
+	m.register <- client
+	
+	// Start goroutines for reading and writing
+	go client.readPump()
+	go client.writePump()
+	
+	// Send welcome message
+	welcome := WebSocketMessage{
+		Type: "welcome",
+		Payload: json.RawMessage(`{"message": "Connected to go-red server"}`),
+	}
+	
+	welcomeJSON, _ := json.Marshal(welcome)
+	client.send <- welcomeJSON
+}
+
+// readPump pumps messages from the WebSocket connection to the manager
+func (c *WebSocketClient) readPump() {
+	defer func() {
+		c.releaseLocks()
+		c.manager.unregister <- c
+		c.conn.Close()
+	}()
+	
+	c.conn.SetReadLimit(4096) // Maximum message size
+	c.conn.SetReadDeadline(time.Now().Add(c.pongTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		c.lastPing = time.Now()
+		c.conn.SetReadDeadline(time.Now().Add(c.pongTimeout))
+		return nil
+	})
+	
+	for {
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				c.manager.logger.Warn("WebSocket error", "user", c.userID, "error", err)
+			}
+			break
+		}
+		
+		// Handle received message
+		var wsMessage WebSocketMessage
+		if err := json.Unmarshal(message, &wsMessage); err != nil {
+			c.manager.logger.Warn("failed to unmarshal WebSocket message", "user", c.userID, "error", err)
+			continue
+		}
+		
+		// Process message based on type
+		switch wsMessage.Type {
+		case "ping":
+			// Send pong response
+			pong := WebSocketMessage{
+				Type: "pong",
+				Payload: json.RawMessage(`{"time": "` + time.Now().Format(time.RFC3339) + `"}`),
+			}
+			pongJSON, _ := json.Marshal(pong)
+			c.send <- pongJSON
+			
+		case "subscribe":
+			// Subscribe to a flow
+			var payload struct {
+				FlowID string `json:"flowId"`
+				// Flows and Severity filter the events of /ws/debug
+				Flows    []string `json:"flows"`
+				Severity string   `json:"severity"`
+			}
+			if err := json.Unmarshal(wsMessage.Payload, &payload); err != nil {
+				c.manager.logger.Warn("invalid subscribe payload", "user", c.userID, "error", err)
+				continue
+			}
+			
+			c.flowID = payload.FlowID
+			if c.manager.debug {
+				c.subscribeDebug(payload.FlowID, payload.Flows, payload.Severity)
+			}
+			
+		case "unsubscribe":
+			// Unsubscribe from a flow
+			c.flowID = ""
+			if c.manager.debug {
+				c.filter.Store(&debugFilter{severity: slog.LevelDebug})
+			}
+			
+		case "lock-heartbeat":
+			// Renew the lock the user holds on a flow
+			var payload struct {
+				FlowID string `json:"flowId"`
+			}
+			if err := json.Unmarshal(wsMessage.Payload, &payload); err != nil {
+				c.manager.logger.Warn("invalid lock-heartbeat payload", "user", c.userID, "error", err)
+				continue
+			}
+			c.renewLock(payload.FlowID)
+			
+		default:
+			// Unknown message type, ignore
+		}
+	}
+}
+
+// renewLock renews the lock the client's user holds on a flow, telling the
+// client if it was lost
+func (c *WebSocketClient) renewLock(flowID string) {
+	if c.manager.locks == nil {
+		return
+	}
+	key := engine.FlowKey(c.workspace, flowID)
+	if _, err := c.manager.locks.Renew(key, c.userID); err != nil {
+		delete(c.heldLocks, key)
+		payload, _ := json.Marshal(map[string]string{"flowId": flowID, "error": err.Error()})
+		lost, _ := json.Marshal(WebSocketMessage{Type: "lock-lost", Payload: payload})
+		c.send <- lost
+		return
+	}
+	if c.heldLocks == nil {
+		c.heldLocks = make(map[string]bool)
+	}
+	c.heldLocks[key] = true
+}
+
+// releaseLocks releases the locks the client renewed
+func (c *WebSocketClient) releaseLocks() {
+	for key := range c.heldLocks {
+		if err := c.manager.locks.Release(key, c.userID, false); err != nil {
+			c.manager.logger.Warn("failed to release lock", "flow_id", key, "user", c.userID, "error", err)
+		}
+	}
+}
+
+// writePump pumps messages from the client to the WebSocket connection
+func (c *WebSocketClient) writePump() {
+	ticker := time.NewTicker(c.pingInterval)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+	
+	for {
+		select {
+		case message, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if !ok {
+				// Channel closed
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			
+			w, err := c.conn.NextWriter(websocket.TextMessage)
+			if err != nil {
+				return
+			}
+			w.Write(message)
+			
+			// Add queued messages
+			n := len(c.send)
+			for i := 0; i < n; i++ {
+				w.Write([]byte{'\n'})
+				w.Write(<-c.send)
+			}
+			
+			if err := w.Close(); err != nil {
+				return
+			}
+			
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// handleListWires handles GET /api/flows/{id}/wires, listing the flow's
+// wires with the messages disabled wires skipped
+func (s *Server) handleListWires(w http.ResponseWriter, r *http.Request) {
+	flow, exists := s.engine.GetFlow(flowKey(r))
+	if !exists {
+		respondError(w, http.StatusNotFound, "Flow not found")
+		return
+	}
+	respond(w, http.StatusOK, wireList{Wires: flow.WireStates()})
+}
+
+// wireList lists the wires of a flow with their states
+type wireList struct {
+	Success bool               `json:"success,omitempty"`
+	Wires   []engine.WireState `json:"wires"`
+}
+
+// handleUpdateWire handles PATCH /api/flows/{id}/wires, which disables or
+// enables a wire without a redeploy
+func (s *Server) handleUpdateWire(w http.ResponseWriter, r *http.Request) {
+	flow, exists := s.engine.GetFlow(flowKey(r))
+	if !exists {
+		respondError(w, http.StatusNotFound, "Flow not found")
+		return
+	}
+	if flow.Remote() {
+		respondError(w, http.StatusBadRequest, "Flow runs on agents; redeploy it instead")
+		return
+	}
+	if !s.checkFlowLock(w, r, flowKey(r)) {
+		return
+	}
+
+	var patch wirePatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || patch.Disabled == nil {
+		respondError(w, http.StatusBadRequest, "Expected source, target, port and disabled")
+		return
+	}
+
+	err := s.engine.SetWireDisabled(flowKey(r), patch.Source, patch.Target, patch.Port, *patch.Disabled)
+	switch {
+	case errors.Is(err, engine.ErrWireNotFound):
+		respondError(w, http.StatusNotFound, "Wire not found")
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respond(w, http.StatusOK, wireList{Success: true, Wires: flow.WireStates()})
+}
+
+// wirePatch is the body of PATCH /api/flows/{id}/wires
+type wirePatch struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	Port     int    `json:"port"`
+	Disabled *bool  `json:"disabled"`
+}
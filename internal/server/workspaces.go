@@ -0,0 +1,205 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// requestWorkspace returns the workspace named in the request path, or in
+// the workspace query parameter of WebSocket connections, or the default
+// workspace for the paths without one
+func requestWorkspace(r *http.Request) string {
+	if workspace := mux.Vars(r)["workspace"]; workspace != "" {
+		return workspace
+	}
+	if workspace := r.URL.Query().Get("workspace"); workspace != "" && (r.URL.Path == "/ws" || r.URL.Path == "/ws/debug") {
+		return workspace
+	}
+	return engine.DefaultWorkspace
+}
+
+// flowKey returns the engine's key of the flow in the request path
+func flowKey(r *http.Request) string {
+	return engine.FlowKey(requestWorkspace(r), mux.Vars(r)["id"])
+}
+
+// configList returns the entries of a list setting, which may be an array
+// or a comma-separated string
+func (s *Server) configList(key string) []string {
+	return s.config.GetStringSlice(key)
+}
+
+// workspaceAllowed reports whether a user may use a workspace. Workspaces
+// with a workspaces.<name>.users setting are limited to those users and
+// admins; others are open to everyone.
+func (s *Server) workspaceAllowed(workspace, user string) bool {
+	users := s.configList("workspaces." + workspace + ".users")
+	if len(users) == 0 || s.isAdmin(workspace, user) {
+		return true
+	}
+	for _, allowed := range users {
+		if allowed == user {
+			return true
+		}
+	}
+	return false
+}
+
+// workspaceMiddleware answers requests for unknown workspaces with 404 Not
+// Found and those of users the workspace is closed to with 403 Forbidden
+func (s *Server) workspaceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		workspace := requestWorkspace(r)
+		exists, err := s.engine.HasWorkspace(workspace)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !exists {
+			respondError(w, http.StatusNotFound, "Workspace not found")
+			return
+		}
+		if !s.workspaceAllowed(workspace, requestUser(r)) {
+			respondError(w, http.StatusForbidden, "Workspace access denied")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleListWorkspaces handles GET /api/workspaces, listing the workspaces
+// the user may use
+func (s *Server) handleListWorkspaces(w http.ResponseWriter, r *http.Request) {
+	workspaces, err := s.engine.ListWorkspaces()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	user := requestUser(r)
+	result := make([]workspaceInfo, 0, len(workspaces))
+	for _, workspace := range workspaces {
+		if s.workspaceAllowed(workspace.Name, user) {
+			result = append(result, s.workspaceInfo(workspace))
+		}
+	}
+	respond(w, http.StatusOK, workspaceList{Workspaces: result})
+}
+
+// workspaceList is the response of GET /api/workspaces
+type workspaceList struct {
+	Workspaces []workspaceInfo `json:"workspaces"`
+}
+
+// handleCreateWorkspace handles POST /api/workspaces
+func (s *Server) handleCreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	var request workspaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := engine.ValidateWorkspaceName(request.Name); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	workspace, err := s.engine.CreateWorkspace(request.Name)
+	switch {
+	case errors.Is(err, storage.ErrWorkspaceExists):
+		respondError(w, http.StatusConflict, "Workspace already exists")
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respond(w, http.StatusCreated, s.workspaceInfo(workspace))
+}
+
+// workspaceRequest is the body of POST /api/workspaces
+type workspaceRequest struct {
+	Name string `json:"name"`
+}
+
+// handleGetWorkspace handles GET /api/workspaces/{workspace}
+func (s *Server) handleGetWorkspace(w http.ResponseWriter, r *http.Request) {
+	workspaces, err := s.engine.ListWorkspaces()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for _, workspace := range workspaces {
+		if workspace.Name == requestWorkspace(r) {
+			respond(w, http.StatusOK, s.workspaceInfo(workspace))
+			return
+		}
+	}
+	respondError(w, http.StatusNotFound, "Workspace not found")
+}
+
+// handleDeleteWorkspace handles DELETE /api/workspaces/{workspace}. A
+// workspace with flows is only deleted, with its flows, by admins passing
+// ?force=true.
+func (s *Server) handleDeleteWorkspace(w http.ResponseWriter, r *http.Request) {
+	workspace := requestWorkspace(r)
+	if workspace == engine.DefaultWorkspace {
+		respondError(w, http.StatusBadRequest, "The default workspace cannot be deleted")
+		return
+	}
+	force := r.URL.Query().Get("force") == "true"
+	if force && !s.isAdmin(workspace, requestUser(r)) {
+		respondError(w, http.StatusForbidden, "Only admins may delete workspaces with flows")
+		return
+	}
+
+	err := s.engine.DeleteWorkspace(workspace, force)
+	switch {
+	case errors.Is(err, engine.ErrWorkspaceNotEmpty):
+		respond(w, http.StatusConflict, workspaceFlowsErrorResponse{
+			Error: "Workspace has flows",
+			Flows: s.engine.WorkspaceFlows(workspace),
+		})
+		return
+	case errors.Is(err, storage.ErrWorkspaceNotFound):
+		respondError(w, http.StatusNotFound, "Workspace not found")
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respond(w, http.StatusOK, successResponse{Success: true})
+}
+
+// workspaceFlowsErrorResponse lists the flows that keep a workspace from
+// being deleted
+type workspaceFlowsErrorResponse struct {
+	Error string   `json:"error"`
+	Flows []string `json:"flows"`
+}
+
+// workspaceInfo describes a workspace in API responses
+type workspaceInfo struct {
+	Name    string     `json:"name"`
+	Flows   int        `json:"flows"`
+	Created *time.Time `json:"created,omitempty"`
+	Users   []string   `json:"users,omitempty"`
+}
+
+// workspaceInfo describes a workspace
+func (s *Server) workspaceInfo(workspace storage.Workspace) workspaceInfo {
+	info := workspaceInfo{
+		Name:  workspace.Name,
+		Flows: len(s.engine.WorkspaceFlows(workspace.Name)),
+		Users: s.configList("workspaces." + workspace.Name + ".users"),
+	}
+	if !workspace.Created.IsZero() {
+		info.Created = &workspace.Created
+	}
+	return info
+}
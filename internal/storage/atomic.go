@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// backupSuffix is added to the name of a flow file to name the copy of its
+// previous version
+const backupSuffix = ".bak"
+
+// writeFileAtomic writes data to a temporary file in the directory of path,
+// syncs it and renames it over path, so that a crash leaves either the old
+// file or the new one, never a truncated one
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := ioutil.TempFile(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir syncs a directory, so that a rename in it survives a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	// Some platforms cannot sync directories; the rename happened anyway
+	if err := d.Sync(); err != nil && !os.IsPermission(err) {
+		log.Printf("Warning: failed to sync directory %s: %v", dir, err)
+	}
+	return nil
+}
+
+// backUpFlow copies the flow file at path, if it holds a valid flow, to its
+// backup, before it is replaced
+func backUpFlow(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !json.Valid(data) {
+		// Keep the backup of the last good version
+		return nil
+	}
+	return writeFileAtomic(path+backupSuffix, data, 0644)
+}
+
+// readFlowFile reads the flow file at path. If it is unreadable or not
+// valid JSON, such as after a write that did not complete, its backup is
+// read instead.
+func readFlowFile(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err == nil && json.Valid(data) {
+		return data, nil
+	}
+	if os.IsNotExist(err) {
+		return nil, err
+	}
+
+	backup, backupErr := ioutil.ReadFile(path + backupSuffix)
+	if backupErr != nil || !json.Valid(backup) {
+		if err == nil {
+			return data, nil
+		}
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("Warning: failed to read flow file %s, loading its backup: %v", path, err)
+	} else {
+		log.Printf("Warning: flow file %s is corrupt, loading its backup", path)
+	}
+	return backup, nil
+}
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	flowV1 = `{"id": "f", "nodes": [{"id": "a", "type": "inject"}]}`
+	flowV2 = `{"id": "f", "nodes": [{"id": "a", "type": "inject"}, {"id": "b", "type": "debug"}]}`
+)
+
+// savedTwice returns file storage holding two versions of flow "f"
+func savedTwice(t *testing.T) *FileStorage {
+	t.Helper()
+	fs, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, flow := range []string{flowV1, flowV2} {
+		if err := fs.SaveFlow("f", []byte(flow)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return fs
+}
+
+func TestLoadFlowFallsBackToBackup(t *testing.T) {
+	tests := []struct {
+		name string
+		// damage changes the flow file the way an interrupted write would
+		damage func(t *testing.T, path string)
+	}{
+		{"truncated", func(t *testing.T, path string) {
+			if err := os.Truncate(path, int64(len(flowV2)/2)); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"empty", func(t *testing.T, path string) {
+			if err := os.Truncate(path, 0); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"garbage", func(t *testing.T, path string) {
+			if err := os.WriteFile(path, []byte{0, 0, 0, 0}, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"unreadable", func(t *testing.T, path string) {
+			// A directory in the file's place fails to read
+			if err := os.Remove(path); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Mkdir(path, 0755); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := savedTwice(t)
+			tt.damage(t, fs.flowPath("f"))
+
+			data, err := fs.LoadFlow("f")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != flowV1 {
+				t.Errorf("loaded %q, want the backup %q", data, flowV1)
+			}
+		})
+	}
+}
+
+func TestLoadFlowWithoutGoodBackup(t *testing.T) {
+	fs := savedTwice(t)
+	path := fs.flowPath("f")
+	if err := os.WriteFile(path+backupSuffix, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// An intact file is loaded whatever the backup holds
+	data, err := fs.LoadFlow("f")
+	if err != nil || string(data) != flowV2 {
+		t.Fatalf("LoadFlow = %q, %v, want %q", data, err, flowV2)
+	}
+
+	// A corrupt file without a good backup is returned as it is, for the
+	// engine to report
+	if err := os.Truncate(path, 5); err != nil {
+		t.Fatal(err)
+	}
+	data, err = fs.LoadFlow("f")
+	if err != nil || string(data) != flowV2[:5] {
+		t.Errorf("LoadFlow = %q, %v, want the truncated file", data, err)
+	}
+}
+
+func TestSaveFlowKeepsGoodBackup(t *testing.T) {
+	fs := savedTwice(t)
+	path := fs.flowPath("f")
+	if err := os.Truncate(path, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	// Saving over a corrupt file must not back it up over the good backup
+	flowV3 := strings.Replace(flowV2, "debug", "function", 1)
+	if err := fs.SaveFlow("f", []byte(flowV3)); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := os.ReadFile(path + backupSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(backup) != flowV1 {
+		t.Errorf("backup holds %q, want %q", backup, flowV1)
+	}
+	if data, err := fs.LoadFlow("f"); err != nil || string(data) != flowV3 {
+		t.Errorf("LoadFlow = %q, %v, want %q", data, err, flowV3)
+	}
+}
+
+func TestWriteFileAtomicLeavesNoTemporaryFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "flow.json")
+	for _, flow := range []string{flowV1, flowV2} {
+		if err := writeFileAtomic(path, []byte(flow), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "flow.json" {
+		names := make([]string, len(entries))
+		for i, entry := range entries {
+			names[i] = entry.Name()
+		}
+		t.Errorf("directory holds %v, want only flow.json", names)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("file mode is %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestWriteFileAtomicCleansUpOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	// Renaming over a non-empty directory fails after the data was written
+	target := filepath.Join(dir, "busy")
+	if err := os.MkdirAll(filepath.Join(target, "child"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(target, []byte(flowV2), 0644); err == nil {
+		t.Fatal("writing over a directory succeeded")
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	if len(matches) > 0 {
+		t.Errorf("failed write left %v behind", matches)
+	}
+}
@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditEntry records a change made through the API
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+	// User is the authenticated user, or the one the request named
+	User   string `json:"user,omitempty"`
+	Remote string `json:"remote"`
+	Method string `json:"method"`
+	// Route is the route template, such as /api/flows/{id}
+	Route  string `json:"route"`
+	Action string `json:"action"`
+	// Resource is the flow key or name the request affected
+	Resource string `json:"resource,omitempty"`
+	Status   int    `json:"status"`
+	// Summary describes the change, such as the nodes a flow update
+	// added and removed
+	Summary string `json:"summary,omitempty"`
+}
+
+// AuditQuery selects audit entries: those at or after Since and before
+// Until, where set, newest first, skipping Offset and returning at most
+// Limit if it is positive
+type AuditQuery struct {
+	Since  time.Time
+	Until  time.Time
+	Offset int
+	Limit  int
+}
+
+// matches reports whether an entry is in the query's time range
+func (q AuditQuery) matches(entry AuditEntry) bool {
+	return (q.Since.IsZero() || !entry.Time.Before(q.Since)) && (q.Until.IsZero() || entry.Time.Before(q.Until))
+}
+
+// page returns the entries of the query's page of entries, newest first
+func (q AuditQuery) page(entries []AuditEntry) []AuditEntry {
+	if q.Offset >= len(entries) {
+		return []AuditEntry{}
+	}
+	entries = entries[q.Offset:]
+	if q.Limit > 0 && len(entries) > q.Limit {
+		entries = entries[:q.Limit]
+	}
+	return entries
+}
+
+// AuditStore is implemented by storages that keep an append-only audit log
+type AuditStore interface {
+	AppendAudit(entry AuditEntry) error
+	// ListAudit returns the page of entries the query selects and how many
+	// entries it selects in all
+	ListAudit(query AuditQuery) ([]AuditEntry, int, error)
+}
+
+// auditDir holds a file of JSON lines per day, named by its UTC date
+const auditDir = "audit"
+
+// auditMu serializes appends to audit files
+var auditMu sync.Mutex
+
+// AppendAudit appends an entry to the file of its day
+func (fs *FileStorage) AppendAudit(entry AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(fs.baseDir, auditDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	path := filepath.Join(dir, entry.Time.UTC().Format("2006-01-02")+".jsonl")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ListAudit reads the files of the days in the query's time range
+func (fs *FileStorage) ListAudit(query AuditQuery) ([]AuditEntry, int, error) {
+	dir := filepath.Join(fs.baseDir, auditDir)
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []AuditEntry{}, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries := []AuditEntry{}
+	for _, file := range files {
+		day, err := time.Parse("2006-01-02", strings.TrimSuffix(file.Name(), ".jsonl"))
+		if file.IsDir() || err != nil {
+			continue
+		}
+		if (!query.Since.IsZero() && !day.AddDate(0, 0, 1).After(query.Since)) || (!query.Until.IsZero() && !day.Before(query.Until)) {
+			continue
+		}
+		read, err := readAuditFile(filepath.Join(dir, file.Name()), query)
+		if err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, read...)
+	}
+	sortAudit(entries)
+	return query.page(entries), len(entries), nil
+}
+
+// readAuditFile reads the entries of an audit file that the query's time
+// range selects, skipping lines that are not entries, such as one cut short
+// by a crash
+func readAuditFile(path string, query AuditQuery) ([]AuditEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if query.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// sortAudit sorts entries newest first
+func sortAudit(entries []AuditEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.After(entries[j].Time)
+	})
+}
+
+// AppendAudit appends an audit entry
+func (s *MemoryStorage) AppendAudit(entry AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.audit = append(s.audit, entry)
+	return nil
+}
+
+// ListAudit lists audit entries
+func (s *MemoryStorage) ListAudit(query AuditQuery) ([]AuditEntry, int, error) {
+	s.mu.Lock()
+	entries := []AuditEntry{}
+	for _, entry := range s.audit {
+		if query.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	s.mu.Unlock()
+	sortAudit(entries)
+	return query.page(entries), len(entries), nil
+}
+
+const auditSchema = `CREATE TABLE IF NOT EXISTS gored_audit (
+	id         BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ NOT NULL,
+	entry      TEXT NOT NULL
+)`
+
+// AppendAudit inserts an audit entry. Unlike flows, entries are written by
+// whichever instance served the request, leader or not.
+func (s *SQLStorage) AppendAudit(entry AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO gored_audit (created_at, entry) VALUES ($1, $2)`, entry.Time, string(data))
+	return err
+}
+
+// ListAudit lists audit entries
+func (s *SQLStorage) ListAudit(query AuditQuery) ([]AuditEntry, int, error) {
+	const where = `WHERE ($1::timestamptz IS NULL OR created_at >= $1) AND ($2::timestamptz IS NULL OR created_at < $2)`
+	var since, until interface{}
+	if !query.Since.IsZero() {
+		since = query.Since
+	}
+	if !query.Until.IsZero() {
+		until = query.Until
+	}
+
+	var total int
+	if err := s.db.QueryRow(`SELECT count(*) FROM gored_audit `+where, since, until).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	var limit interface{}
+	if query.Limit > 0 {
+		limit = query.Limit
+	}
+	rows, err := s.db.Query(`SELECT entry FROM gored_audit `+where+`
+		ORDER BY created_at DESC, id DESC LIMIT $3 OFFSET $4`, since, until, limit, query.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, 0, err
+		}
+		var entry AuditEntry
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, total, rows.Err()
+}
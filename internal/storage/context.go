@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ContextStore is implemented by storages that keep persistent context
+// values, so that they survive restarts. Values are opaque JSON to the store
+// and keyed by scope, "global", "global:<workspace>" or "flow:<flow key>",
+// and key.
+type ContextStore interface {
+	// LoadContext returns the values saved in a scope by key
+	LoadContext(scope string) (map[string][]byte, error)
+	SaveContextValue(scope, key string, value []byte) error
+	DeleteContextValue(scope, key string) error
+	// DeleteContext deletes every value of a scope
+	DeleteContext(scope string) error
+}
+
+// contextDir holds a directory per context scope, with a file per value
+const contextDir = "context"
+
+// contextPath returns the directory of a context scope
+func (fs *FileStorage) contextPath(scope string) string {
+	return filepath.Join(fs.baseDir, contextDir, url.PathEscape(scope))
+}
+
+// LoadContext loads the values of a scope from their files
+func (fs *FileStorage) LoadContext(scope string) (map[string][]byte, error) {
+	dir := fs.contextPath(scope)
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string][]byte, len(files))
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		key, err := url.PathUnescape(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			continue
+		}
+		value, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// SaveContextValue writes a value to a temporary file and renames it, so
+// that a crash leaves the old value or the new one
+func (fs *FileStorage) SaveContextValue(scope, key string, value []byte) error {
+	dir := fs.contextPath(scope)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, url.PathEscape(key)+".json")
+	if err := ioutil.WriteFile(path+".tmp", value, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// DeleteContextValue removes the file of a value
+func (fs *FileStorage) DeleteContextValue(scope, key string) error {
+	path := filepath.Join(fs.contextPath(scope), url.PathEscape(key)+".json")
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// DeleteContext removes the directory of a scope
+func (fs *FileStorage) DeleteContext(scope string) error {
+	return os.RemoveAll(fs.contextPath(scope))
+}
+
+// LoadContext loads the values of a scope
+func (s *MemoryStorage) LoadContext(scope string) (map[string][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values := make(map[string][]byte, len(s.contexts[scope]))
+	for key, value := range s.contexts[scope] {
+		values[key] = value
+	}
+	return values, nil
+}
+
+// SaveContextValue saves a value
+func (s *MemoryStorage) SaveContextValue(scope, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.contexts == nil {
+		s.contexts = make(map[string]map[string][]byte)
+	}
+	if s.contexts[scope] == nil {
+		s.contexts[scope] = make(map[string][]byte)
+	}
+	s.contexts[scope][key] = append([]byte{}, value...)
+	return nil
+}
+
+// DeleteContextValue deletes a value
+func (s *MemoryStorage) DeleteContextValue(scope, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.contexts[scope], key)
+	return nil
+}
+
+// DeleteContext deletes the values of a scope
+func (s *MemoryStorage) DeleteContext(scope string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.contexts, scope)
+	return nil
+}
+
+const contextSchema = `CREATE TABLE IF NOT EXISTS gored_context (
+	scope      TEXT NOT NULL,
+	key        TEXT NOT NULL,
+	value      BYTEA NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (scope, key)
+)`
+
+// LoadContext loads the values of a scope
+func (s *SQLStorage) LoadContext(scope string) (map[string][]byte, error) {
+	rows, err := s.db.Query(`SELECT key, value FROM gored_context WHERE scope = $1`, scope)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make(map[string][]byte)
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, rows.Err()
+}
+
+// SaveContextValue saves a value; the write is fenced like those of node
+// states
+func (s *SQLStorage) SaveContextValue(scope, key string, value []byte) error {
+	return s.write(`INSERT INTO gored_context (scope, key, value, updated_at) SELECT $1, $2, $3, now() WHERE %s
+		ON CONFLICT (scope, key) DO UPDATE SET value = EXCLUDED.value, updated_at = EXCLUDED.updated_at`, scope, key, value)
+}
+
+// DeleteContextValue removes a value
+func (s *SQLStorage) DeleteContextValue(scope, key string) error {
+	return s.write(`DELETE FROM gored_context WHERE scope = $1 AND key = $2 AND %s`, scope, key)
+}
+
+// DeleteContext removes the values of a scope
+func (s *SQLStorage) DeleteContext(scope string) error {
+	return s.write(`DELETE FROM gored_context WHERE scope = $1 AND %s`, scope)
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// CredentialStore is implemented by storages that keep the credentials of
+// flows apart from their definitions. Credentials are opaque to the store,
+// which receives them encrypted, and keyed by flow.
+type CredentialStore interface {
+	// LoadCredentials returns the credentials saved for a flow, if any
+	LoadCredentials(flowID string) ([]byte, bool, error)
+	SaveCredentials(flowID string, credentials []byte) error
+	DeleteCredentials(flowID string) error
+}
+
+// credentialDir holds a file per flow with credentials
+const credentialDir = "credentials"
+
+// credentialPath returns the credentials file of a flow
+func (fs *FileStorage) credentialPath(flowID string) string {
+	return filepath.Join(fs.baseDir, credentialDir, url.PathEscape(flowID)+".json")
+}
+
+// LoadCredentials loads the credentials of a flow from their file
+func (fs *FileStorage) LoadCredentials(flowID string) ([]byte, bool, error) {
+	credentials, err := ioutil.ReadFile(fs.credentialPath(flowID))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return credentials, true, nil
+}
+
+// SaveCredentials writes the credentials of a flow to a temporary file,
+// readable by the owner only, and renames it
+func (fs *FileStorage) SaveCredentials(flowID string, credentials []byte) error {
+	if err := os.MkdirAll(filepath.Join(fs.baseDir, credentialDir), 0700); err != nil {
+		return err
+	}
+	path := fs.credentialPath(flowID)
+	if err := ioutil.WriteFile(path+".tmp", credentials, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// DeleteCredentials removes the credentials file of a flow
+func (fs *FileStorage) DeleteCredentials(flowID string) error {
+	if err := os.Remove(fs.credentialPath(flowID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// LoadCredentials loads the credentials of a flow
+func (s *MemoryStorage) LoadCredentials(flowID string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	credentials, exists := s.credentials[flowID]
+	return credentials, exists, nil
+}
+
+// SaveCredentials saves the credentials of a flow
+func (s *MemoryStorage) SaveCredentials(flowID string, credentials []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.credentials == nil {
+		s.credentials = make(map[string][]byte)
+	}
+	s.credentials[flowID] = append([]byte{}, credentials...)
+	return nil
+}
+
+// DeleteCredentials deletes the credentials of a flow
+func (s *MemoryStorage) DeleteCredentials(flowID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.credentials, flowID)
+	return nil
+}
+
+const credentialSchema = `CREATE TABLE IF NOT EXISTS gored_credentials (
+	flow_id     TEXT PRIMARY KEY,
+	credentials BYTEA NOT NULL,
+	updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+)`
+
+// LoadCredentials loads the credentials of a flow
+func (s *SQLStorage) LoadCredentials(flowID string) ([]byte, bool, error) {
+	var credentials []byte
+	err := s.db.QueryRow(`SELECT credentials FROM gored_credentials WHERE flow_id = $1`, flowID).Scan(&credentials)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return credentials, true, nil
+}
+
+// SaveCredentials saves the credentials of a flow; the write is fenced like
+// those of flows
+func (s *SQLStorage) SaveCredentials(flowID string, credentials []byte) error {
+	return s.write(`INSERT INTO gored_credentials (flow_id, credentials, updated_at) SELECT $1, $2, now() WHERE %s
+		ON CONFLICT (flow_id) DO UPDATE SET credentials = EXCLUDED.credentials, updated_at = EXCLUDED.updated_at`, flowID, credentials)
+}
+
+// DeleteCredentials removes the credentials of a flow
+func (s *SQLStorage) DeleteCredentials(flowID string) error {
+	return s.write(`DELETE FROM gored_credentials WHERE flow_id = $1 AND %s`, flowID)
+}
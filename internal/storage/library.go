@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrLibraryEntryNotFound is returned when loading a library entry that
+// does not exist
+var ErrLibraryEntryNotFound = errors.New("library entry not found")
+
+// LibraryEntry describes an entry of the library, such as a function body
+// or a flow fragment, saved for reuse
+type LibraryEntry struct {
+	// Type is the kind of entry, such as "flows" or "functions"
+	Type string `json:"type"`
+	// Path is the slash-separated path of the entry within its type
+	Path        string    `json:"path"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Created     time.Time `json:"created"`
+}
+
+// LibraryStore is implemented by storages that keep a library of entries,
+// keyed by type and path. Bodies are opaque JSON to the store.
+type LibraryStore interface {
+	SaveLibraryEntry(entry LibraryEntry, body []byte) error
+	// ListLibraryEntries lists the entries of a type whose paths start
+	// with prefix, sorted by path
+	ListLibraryEntries(libraryType, prefix string) ([]LibraryEntry, error)
+	LoadLibraryEntry(libraryType, path string) (LibraryEntry, []byte, error)
+}
+
+// libraryDir holds a directory per library type, with a file per entry
+const libraryDir = "lib"
+
+// libraryFile is the file of a library entry: its metadata and body
+type libraryFile struct {
+	LibraryEntry
+	Body json.RawMessage `json:"body"`
+}
+
+// libraryPath returns the file of a library entry; paths are checked by
+// the engine, so they stay below the library directory
+func (fs *FileStorage) libraryPath(libraryType, path string) string {
+	return filepath.Join(fs.baseDir, libraryDir, libraryType, filepath.FromSlash(path)+".json")
+}
+
+// SaveLibraryEntry writes a library entry to its file
+func (fs *FileStorage) SaveLibraryEntry(entry LibraryEntry, body []byte) error {
+	data, err := json.Marshal(libraryFile{LibraryEntry: entry, Body: body})
+	if err != nil {
+		return err
+	}
+	path := fs.libraryPath(entry.Type, entry.Path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
+// ListLibraryEntries reads the entry files below the directory of a type
+func (fs *FileStorage) ListLibraryEntries(libraryType, prefix string) ([]LibraryEntry, error) {
+	root := filepath.Join(fs.baseDir, libraryDir, libraryType)
+	entries := []LibraryEntry{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		entryPath := filepath.ToSlash(strings.TrimSuffix(rel, ".json"))
+		if !strings.HasPrefix(entryPath, prefix) {
+			return nil
+		}
+		entry, _, err := fs.LoadLibraryEntry(libraryType, entryPath)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// LoadLibraryEntry reads a library entry from its file
+func (fs *FileStorage) LoadLibraryEntry(libraryType, path string) (LibraryEntry, []byte, error) {
+	data, err := ioutil.ReadFile(fs.libraryPath(libraryType, path))
+	if os.IsNotExist(err) {
+		return LibraryEntry{}, nil, ErrLibraryEntryNotFound
+	}
+	if err != nil {
+		return LibraryEntry{}, nil, err
+	}
+	var file libraryFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return LibraryEntry{}, nil, err
+	}
+	file.Type, file.Path = libraryType, path
+	return file.LibraryEntry, file.Body, nil
+}
+
+// SaveLibraryEntry saves a library entry
+func (s *MemoryStorage) SaveLibraryEntry(entry LibraryEntry, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.library == nil {
+		s.library = make(map[string]libraryFile)
+	}
+	s.library[entry.Type+":"+entry.Path] = libraryFile{LibraryEntry: entry, Body: append([]byte{}, body...)}
+	return nil
+}
+
+// ListLibraryEntries lists library entries
+func (s *MemoryStorage) ListLibraryEntries(libraryType, prefix string) ([]LibraryEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := []LibraryEntry{}
+	for _, file := range s.library {
+		if file.Type == libraryType && strings.HasPrefix(file.Path, prefix) {
+			entries = append(entries, file.LibraryEntry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
+
+// LoadLibraryEntry loads a library entry
+func (s *MemoryStorage) LoadLibraryEntry(libraryType, path string) (LibraryEntry, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, exists := s.library[libraryType+":"+path]
+	if !exists {
+		return LibraryEntry{}, nil, ErrLibraryEntryNotFound
+	}
+	return file.LibraryEntry, file.Body, nil
+}
+
+const librarySchema = `CREATE TABLE IF NOT EXISTS gored_library (
+	type        TEXT NOT NULL,
+	path        TEXT NOT NULL,
+	name        TEXT NOT NULL,
+	description TEXT NOT NULL,
+	body        TEXT NOT NULL,
+	created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (type, path)
+)`
+
+// SaveLibraryEntry saves a library entry
+func (s *SQLStorage) SaveLibraryEntry(entry LibraryEntry, body []byte) error {
+	return s.write(`INSERT INTO gored_library (type, path, name, description, body, created_at)
+		SELECT $1, $2, $3, $4, $5, $6 WHERE %s
+		ON CONFLICT (type, path) DO UPDATE SET name = EXCLUDED.name, description = EXCLUDED.description,
+			body = EXCLUDED.body, created_at = EXCLUDED.created_at`,
+		entry.Type, entry.Path, entry.Name, entry.Description, string(body), entry.Created)
+}
+
+// ListLibraryEntries lists library entries
+func (s *SQLStorage) ListLibraryEntries(libraryType, prefix string) ([]LibraryEntry, error) {
+	rows, err := s.db.Query(`SELECT path, name, description, created_at FROM gored_library
+		WHERE type = $1 AND starts_with(path, $2) ORDER BY path`, libraryType, prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []LibraryEntry{}
+	for rows.Next() {
+		entry := LibraryEntry{Type: libraryType}
+		if err := rows.Scan(&entry.Path, &entry.Name, &entry.Description, &entry.Created); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// LoadLibraryEntry loads a library entry
+func (s *SQLStorage) LoadLibraryEntry(libraryType, path string) (LibraryEntry, []byte, error) {
+	entry := LibraryEntry{Type: libraryType, Path: path}
+	var body string
+	err := s.db.QueryRow(`SELECT name, description, body, created_at FROM gored_library WHERE type = $1 AND path = $2`,
+		libraryType, path).Scan(&entry.Name, &entry.Description, &body, &entry.Created)
+	if err == sql.ErrNoRows {
+		return LibraryEntry{}, nil, ErrLibraryEntryNotFound
+	}
+	if err != nil {
+		return LibraryEntry{}, nil, err
+	}
+	return entry, []byte(body), nil
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// FlowLock is an advisory lock telling other editors that someone is
+// editing a flow
+type FlowLock struct {
+	FlowID   string    `json:"flowId"`
+	Holder   string    `json:"holder"`
+	Acquired time.Time `json:"acquired"`
+	Expires  time.Time `json:"expires"`
+}
+
+// LockStore is implemented by storages shared between instances, which keep
+// flow locks so that every instance sees them and they survive a change of
+// leader
+type LockStore interface {
+	// LoadFlowLock returns the lock of a flow, if any, including expired
+	// ones
+	LoadFlowLock(flowID string) (FlowLock, bool, error)
+	SaveFlowLock(lock FlowLock) error
+	DeleteFlowLock(flowID string) error
+}
+
+const lockSchema = `CREATE TABLE IF NOT EXISTS gored_flow_locks (
+	flow_id     TEXT PRIMARY KEY,
+	holder      TEXT NOT NULL,
+	acquired_at TIMESTAMPTZ NOT NULL,
+	expires_at  TIMESTAMPTZ NOT NULL
+)`
+
+// LoadFlowLock loads the lock of a flow
+func (s *SQLStorage) LoadFlowLock(flowID string) (FlowLock, bool, error) {
+	lock := FlowLock{FlowID: flowID}
+	err := s.db.QueryRow(`SELECT holder, acquired_at, expires_at FROM gored_flow_locks WHERE flow_id = $1`, flowID).
+		Scan(&lock.Holder, &lock.Acquired, &lock.Expires)
+	if err == sql.ErrNoRows {
+		return FlowLock{}, false, nil
+	}
+	if err != nil {
+		return FlowLock{}, false, err
+	}
+	return lock, true, nil
+}
+
+// SaveFlowLock saves the lock of a flow. Locks are advisory and renewed by
+// editors connected to any instance, so their writes are not fenced.
+func (s *SQLStorage) SaveFlowLock(lock FlowLock) error {
+	_, err := s.db.Exec(`INSERT INTO gored_flow_locks (flow_id, holder, acquired_at, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (flow_id) DO UPDATE SET holder = EXCLUDED.holder,
+			acquired_at = EXCLUDED.acquired_at, expires_at = EXCLUDED.expires_at`,
+		lock.FlowID, lock.Holder, lock.Acquired, lock.Expires)
+	return err
+}
+
+// DeleteFlowLock removes the lock of a flow
+func (s *SQLStorage) DeleteFlowLock(flowID string) error {
+	_, err := s.db.Exec(`DELETE FROM gored_flow_locks WHERE flow_id = $1`, flowID)
+	return err
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+)
+
+// MemoryStorage keeps flows in memory, e.g. for tests and embedded runtimes
+// that deploy their flows on every start
+type MemoryStorage struct {
+	flows       map[string][]byte
+	states      map[string][]byte
+	contexts    map[string]map[string][]byte
+	credentials map[string][]byte
+	versions    map[string][]memoryVersion
+	library     map[string]libraryFile
+	audit       []AuditEntry
+	mu          sync.Mutex
+}
+
+// NewMemoryStorage creates an empty MemoryStorage
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{flows: make(map[string][]byte)}
+}
+
+// SaveFlow saves a flow
+func (s *MemoryStorage) SaveFlow(id string, flow []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flows[id] = append([]byte{}, flow...)
+	return nil
+}
+
+// LoadFlow loads a flow
+func (s *MemoryStorage) LoadFlow(id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	flow, exists := s.flows[id]
+	if !exists {
+		return nil, fmt.Errorf("flow %s not found", id)
+	}
+	return flow, nil
+}
+
+// DeleteFlow deletes a flow
+func (s *MemoryStorage) DeleteFlow(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.flows, id)
+	return nil
+}
+
+// ListFlows lists all flow IDs
+func (s *MemoryStorage) ListFlows() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.flows))
+	for id := range s.flows {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,380 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrQueueFull is returned by Append when the queue reached its size limit
+var ErrQueueFull = errors.New("queue is full")
+
+// Record kinds in a segment file
+const (
+	recordMessage byte = 1
+	recordAck     byte = 2
+)
+
+// recordHeaderSize is kind (1) + sequence (8) + length (4) + CRC-32 (4)
+const recordHeaderSize = 17
+
+const (
+	defaultQueueMaxSize     = 64 << 20
+	defaultQueueSegmentSize = 4 << 20
+	// maxQueueRecordSize guards against corrupt length fields
+	maxQueueRecordSize = 256 << 20
+)
+
+// QueueProvider is implemented by storages that can hold persistent queues
+type QueueProvider interface {
+	// OpenQueue opens or creates the named queue and returns the records
+	// that were appended but not acknowledged before, oldest first
+	OpenQueue(name string, opts QueueOptions) (*Queue, []QueueRecord, error)
+}
+
+// QueueOptions configures a persistent queue
+type QueueOptions struct {
+	// MaxSize limits the bytes of unacknowledged records
+	MaxSize int64
+	// SegmentSize is the size at which a new segment file is started
+	SegmentSize int64
+	// NoSync skips the fsync after every append, trading durability for
+	// throughput
+	NoSync bool
+}
+
+// QueueRecord is an unacknowledged record
+type QueueRecord struct {
+	Seq  uint64
+	Data []byte
+}
+
+// Queue is a write-ahead log of records split into segment files. Records
+// are appended before delivery and acknowledged after it; segments are
+// deleted once every record in them, and in all older segments, has been
+// acknowledged.
+type Queue struct {
+	dir      string
+	opts     QueueOptions
+	segments []*queueSegment
+	current  *os.File
+	pending  map[uint64]*queueSegment
+	sizes    map[uint64]int64
+	size     int64
+	nextSeq  uint64
+	corrupt  int
+	mu       sync.Mutex
+}
+
+// queueSegment is a segment file and its number of unacknowledged records
+type queueSegment struct {
+	id   uint64
+	path string
+	size int64
+	live int
+}
+
+// OpenQueue opens a queue below the storage directory
+func (fs *FileStorage) OpenQueue(name string, opts QueueOptions) (*Queue, []QueueRecord, error) {
+	name = strings.ReplaceAll(name, "\\", "_")
+	return OpenQueue(filepath.Join(fs.baseDir, ".queues", name), opts)
+}
+
+// OpenQueue opens or creates a queue in dir and returns its unacknowledged
+// records. Segments that fail to parse are skipped and renamed with a
+// ".corrupt" suffix; their number is reported by Corrupt.
+func OpenQueue(dir string, opts QueueOptions) (*Queue, []QueueRecord, error) {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = defaultQueueMaxSize
+	}
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = defaultQueueSegmentSize
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, nil, err
+	}
+
+	q := &Queue{
+		dir:     dir,
+		opts:    opts,
+		pending: make(map[uint64]*queueSegment),
+		sizes:   make(map[uint64]int64),
+		nextSeq: 1,
+	}
+
+	records, err := q.replay()
+	if err != nil {
+		return nil, nil, err
+	}
+	q.compact()
+
+	if err := q.rotate(); err != nil {
+		return nil, nil, err
+	}
+	return q, records, nil
+}
+
+// Append writes a record and returns its sequence number
+func (q *Queue) Append(data []byte) (uint64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.current == nil {
+		return 0, errors.New("queue is closed")
+	}
+	if q.size+int64(len(data)) > q.opts.MaxSize {
+		return 0, ErrQueueFull
+	}
+
+	seq := q.nextSeq
+	if err := q.write(recordMessage, seq, data); err != nil {
+		return 0, err
+	}
+	q.nextSeq++
+
+	segment := q.segments[len(q.segments)-1]
+	segment.live++
+	q.pending[seq] = segment
+	q.sizes[seq] = int64(len(data))
+	q.size += int64(len(data))
+	return seq, nil
+}
+
+// Ack marks a record as handled
+func (q *Queue) Ack(seq uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.current == nil {
+		return errors.New("queue is closed")
+	}
+	segment, exists := q.pending[seq]
+	if !exists {
+		return nil
+	}
+	if err := q.write(recordAck, seq, nil); err != nil {
+		return err
+	}
+
+	delete(q.pending, seq)
+	q.size -= q.sizes[seq]
+	delete(q.sizes, seq)
+	segment.live--
+	q.compact()
+	return nil
+}
+
+// Len returns the number of unacknowledged records
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// Corrupt returns the number of segments skipped when the queue was opened
+func (q *Queue) Corrupt() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.corrupt
+}
+
+// Close closes the current segment
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.current == nil {
+		return nil
+	}
+	err := q.current.Close()
+	q.current = nil
+	return err
+}
+
+// write appends a record to the current segment, starting a new segment
+// when it is full; q.mu must be held
+func (q *Queue) write(kind byte, seq uint64, data []byte) error {
+	segment := q.segments[len(q.segments)-1]
+	if segment.size >= q.opts.SegmentSize {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+		segment = q.segments[len(q.segments)-1]
+	}
+
+	record := make([]byte, recordHeaderSize+len(data))
+	record[0] = kind
+	binary.BigEndian.PutUint64(record[1:9], seq)
+	binary.BigEndian.PutUint32(record[9:13], uint32(len(data)))
+	binary.BigEndian.PutUint32(record[13:17], crc32.ChecksumIEEE(data))
+	copy(record[recordHeaderSize:], data)
+
+	if _, err := q.current.Write(record); err != nil {
+		return fmt.Errorf("failed to write queue record: %w", err)
+	}
+	if !q.opts.NoSync {
+		if err := q.current.Sync(); err != nil {
+			return fmt.Errorf("failed to sync queue segment: %w", err)
+		}
+	}
+	segment.size += int64(len(record))
+	return nil
+}
+
+// rotate closes the current segment and starts a new one
+func (q *Queue) rotate() error {
+	var id uint64 = 1
+	if len(q.segments) > 0 {
+		id = q.segments[len(q.segments)-1].id + 1
+	}
+
+	path := filepath.Join(q.dir, fmt.Sprintf("%016x.seg", id))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create queue segment: %w", err)
+	}
+
+	if q.current != nil {
+		q.current.Close()
+	}
+	q.current = file
+	q.segments = append(q.segments, &queueSegment{id: id, path: path})
+	return nil
+}
+
+// compact deletes the oldest segments while they hold no unacknowledged
+// records. Deleting in order keeps acknowledgements of older segments
+// alive as long as the records they refer to. q.mu must be held.
+func (q *Queue) compact() {
+	for len(q.segments) > 1 && q.segments[0].live == 0 {
+		os.Remove(q.segments[0].path)
+		q.segments = q.segments[1:]
+	}
+}
+
+// replay reads all segments and returns the unacknowledged records
+func (q *Queue) replay() ([]QueueRecord, error) {
+	files, err := ioutil.ReadDir(q.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uint64
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, ".seg") {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, ".seg"), 16, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	data := make(map[uint64][]byte)
+	for i, id := range ids {
+		segment := &queueSegment{id: id, path: filepath.Join(q.dir, fmt.Sprintf("%016x.seg", id))}
+		messages, acks, size, err := readSegment(segment.path)
+		if err != nil && i == len(ids)-1 && errors.Is(err, io.ErrUnexpectedEOF) {
+			// A crash during an append leaves a partial record at the end
+			// of the newest segment; keep everything before it
+			err = os.Truncate(segment.path, size)
+		}
+		if err != nil {
+			q.corrupt++
+			os.Rename(segment.path, segment.path+".corrupt")
+			continue
+		}
+		segment.size = size
+		q.segments = append(q.segments, segment)
+
+		for _, record := range messages {
+			data[record.Seq] = record.Data
+			q.pending[record.Seq] = segment
+			segment.live++
+			if record.Seq >= q.nextSeq {
+				q.nextSeq = record.Seq + 1
+			}
+		}
+		for _, seq := range acks {
+			if owner, exists := q.pending[seq]; exists {
+				owner.live--
+				delete(q.pending, seq)
+				delete(data, seq)
+			}
+			if seq >= q.nextSeq {
+				q.nextSeq = seq + 1
+			}
+		}
+	}
+
+	records := make([]QueueRecord, 0, len(data))
+	for seq, payload := range data {
+		records = append(records, QueueRecord{Seq: seq, Data: payload})
+		q.sizes[seq] = int64(len(payload))
+		q.size += int64(len(payload))
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Seq < records[j].Seq })
+	return records, nil
+}
+
+// readSegment parses a segment file. On error it also returns the records
+// before the failing one and their size.
+func readSegment(path string) ([]QueueRecord, []uint64, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer file.Close()
+
+	var messages []QueueRecord
+	var acks []uint64
+	var size int64
+	reader := bufio.NewReader(file)
+	header := make([]byte, recordHeaderSize)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if err == io.EOF {
+				return messages, acks, size, nil
+			}
+			return messages, acks, size, fmt.Errorf("truncated record header: %w", err)
+		}
+
+		seq := binary.BigEndian.Uint64(header[1:9])
+		length := binary.BigEndian.Uint32(header[9:13])
+		checksum := binary.BigEndian.Uint32(header[13:17])
+		if length > maxQueueRecordSize {
+			return nil, nil, 0, fmt.Errorf("invalid record length %d", length)
+		}
+
+		data := make([]byte, length)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return messages, acks, size, fmt.Errorf("truncated record: %w", err)
+		}
+		if crc32.ChecksumIEEE(data) != checksum {
+			return nil, nil, 0, fmt.Errorf("checksum mismatch in record %d", seq)
+		}
+
+		switch header[0] {
+		case recordMessage:
+			messages = append(messages, QueueRecord{Seq: seq, Data: data})
+		case recordAck:
+			acks = append(acks, seq)
+		default:
+			return nil, nil, 0, fmt.Errorf("unknown record kind %d", header[0])
+		}
+		size += int64(recordHeaderSize) + int64(length)
+	}
+}
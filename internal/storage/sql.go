@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrFenced is returned by writes from an instance that does not hold the
+// cluster lease it was fenced with
+var ErrFenced = errors.New("write rejected: this instance does not hold the cluster lease")
+
+// ErrFlowNotFound is returned when loading a flow that does not exist
+var ErrFlowNotFound = errors.New("flow not found")
+
+// FenceToken identifies the lease a write is made under
+type FenceToken struct {
+	Holder  string
+	Version int64
+}
+
+// FlowChange reports a flow saved or deleted through the storage
+type FlowChange struct {
+	ID      string
+	Deleted bool
+}
+
+// ChangeNotifier is implemented by storages shared between instances, which
+// report changes made by any of them
+type ChangeNotifier interface {
+	// WatchFlows reports changes made after the call until ctx is done
+	WatchFlows(ctx context.Context, interval time.Duration) <-chan FlowChange
+}
+
+// SQLStorage stores flows in a PostgreSQL table, so that several instances
+// can share them. Deleted flows are kept as tombstones so that other
+// instances notice the deletion.
+type SQLStorage struct {
+	db *sql.DB
+
+	// fence guards writes with a cluster lease, see SetFence
+	leaseTable string
+	leaseName  string
+	fence      func() (FenceToken, bool)
+}
+
+const sqlSchema = `CREATE TABLE IF NOT EXISTS gored_flows (
+	id         TEXT PRIMARY KEY,
+	definition TEXT NOT NULL,
+	version    BIGINT NOT NULL,
+	deleted    BOOLEAN NOT NULL DEFAULT FALSE,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`
+
+// NewSQLStorage creates the flow tables if needed and returns the storage
+func NewSQLStorage(db *sql.DB) (*SQLStorage, error) {
+	if _, err := db.Exec(sqlSchema); err != nil {
+		return nil, fmt.Errorf("failed to create flow table: %w", err)
+	}
+	if _, err := db.Exec(lockSchema); err != nil {
+		return nil, fmt.Errorf("failed to create flow lock table: %w", err)
+	}
+	if _, err := db.Exec(workspaceSchema); err != nil {
+		return nil, fmt.Errorf("failed to create workspace table: %w", err)
+	}
+	if _, err := db.Exec(stateSchema); err != nil {
+		return nil, fmt.Errorf("failed to create node state table: %w", err)
+	}
+	if _, err := db.Exec(contextSchema); err != nil {
+		return nil, fmt.Errorf("failed to create context table: %w", err)
+	}
+	if _, err := db.Exec(credentialSchema); err != nil {
+		return nil, fmt.Errorf("failed to create credentials table: %w", err)
+	}
+	if _, err := db.Exec(versionSchema); err != nil {
+		return nil, fmt.Errorf("failed to create flow version table: %w", err)
+	}
+	if _, err := db.Exec(librarySchema); err != nil {
+		return nil, fmt.Errorf("failed to create library table: %w", err)
+	}
+	if _, err := db.Exec(auditSchema); err != nil {
+		return nil, fmt.Errorf("failed to create audit table: %w", err)
+	}
+	return &SQLStorage{db: db}, nil
+}
+
+// SetFence makes every write conditional on token returning the current
+// holder and version of the named lease in leaseTable. Writes by an
+// instance that lost the lease fail with ErrFenced, even if it has not
+// noticed yet.
+func (s *SQLStorage) SetFence(leaseTable, leaseName string, token func() (FenceToken, bool)) {
+	s.leaseTable = leaseTable
+	s.leaseName = leaseName
+	s.fence = token
+}
+
+// SaveFlow saves a flow
+func (s *SQLStorage) SaveFlow(id string, flow []byte) error {
+	if id == "" {
+		return errors.New("flow ID cannot be empty")
+	}
+
+	return s.write(`INSERT INTO gored_flows (id, definition, version, deleted, updated_at)
+		SELECT $1, $2, COALESCE((SELECT MAX(version) FROM gored_flows), 0) + 1, FALSE, now()
+		WHERE %s
+		ON CONFLICT (id) DO UPDATE SET definition = EXCLUDED.definition, version = EXCLUDED.version,
+			deleted = FALSE, updated_at = EXCLUDED.updated_at`, id, string(flow))
+}
+
+// LoadFlow loads a flow
+func (s *SQLStorage) LoadFlow(id string) ([]byte, error) {
+	var definition string
+	err := s.db.QueryRow(`SELECT definition FROM gored_flows WHERE id = $1 AND NOT deleted`, id).Scan(&definition)
+	if err == sql.ErrNoRows {
+		return nil, ErrFlowNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(definition), nil
+}
+
+// DeleteFlow marks a flow as deleted
+func (s *SQLStorage) DeleteFlow(id string) error {
+	return s.write(`UPDATE gored_flows SET deleted = TRUE, definition = '',
+		version = (SELECT MAX(version) FROM gored_flows) + 1, updated_at = now()
+		WHERE id = $1 AND NOT deleted AND %s`, id)
+}
+
+// ListFlows lists the IDs of all flows
+func (s *SQLStorage) ListFlows() ([]string, error) {
+	rows, err := s.db.Query(`SELECT id FROM gored_flows WHERE NOT deleted ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var flows []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		flows = append(flows, id)
+	}
+	return flows, rows.Err()
+}
+
+// WatchFlows polls for flows changed after the call
+func (s *SQLStorage) WatchFlows(ctx context.Context, interval time.Duration) <-chan FlowChange {
+	changes := make(chan FlowChange)
+
+	var cursor int64
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM gored_flows`).Scan(&cursor); err != nil {
+		log.Printf("Warning: failed to read flow version: %v", err)
+	}
+
+	go func() {
+		defer close(changes)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			found, err := s.changesSince(ctx, cursor)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Warning: failed to poll flow changes: %v", err)
+				}
+				continue
+			}
+			for _, change := range found {
+				select {
+				case changes <- change.FlowChange:
+				case <-ctx.Done():
+					return
+				}
+				cursor = change.version
+			}
+		}
+	}()
+
+	return changes
+}
+
+// versionedChange is a change and the flow version it produced
+type versionedChange struct {
+	FlowChange
+	version int64
+}
+
+// changesSince returns the changes after version, oldest first
+func (s *SQLStorage) changesSince(ctx context.Context, version int64) ([]versionedChange, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, deleted, version FROM gored_flows WHERE version > $1 ORDER BY version`, version)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []versionedChange
+	for rows.Next() {
+		var change versionedChange
+		if err := rows.Scan(&change.ID, &change.Deleted, &change.version); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// write runs a statement whose %s is replaced by the fencing condition
+func (s *SQLStorage) write(query string, args ...interface{}) error {
+	condition := "TRUE"
+	if s.fence != nil {
+		token, ok := s.fence()
+		if !ok {
+			return ErrFenced
+		}
+		n := len(args)
+		condition = fmt.Sprintf(`EXISTS (SELECT 1 FROM %s WHERE name = $%d AND holder = $%d AND version = $%d AND expires_at > now())`,
+			s.leaseTable, n+1, n+2, n+3)
+		args = append(args, s.leaseName, token.Holder, token.Version)
+	}
+
+	result, err := s.db.Exec(fmt.Sprintf(query, condition), args...)
+	if err != nil {
+		return err
+	}
+	if s.fence != nil {
+		if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+			// Either the lease is gone or, for deletes, the flow was; tell
+			// them apart so that deleting a missing flow is not reported
+			// as fencing
+			token, _ := s.fence()
+			var held bool
+			check := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE name = $1 AND holder = $2 AND version = $3 AND expires_at > now())`, s.leaseTable)
+			if err := s.db.QueryRow(check, s.leaseName, token.Holder, token.Version).Scan(&held); err == nil && !held {
+				return ErrFenced
+			}
+		}
+	}
+	return nil
+}
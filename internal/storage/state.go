@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// StateStore is implemented by storages that keep the state of nodes, such
+// as the mails an email-in node has seen, so that it survives restarts.
+// States are opaque to the store and keyed by flow and node.
+type StateStore interface {
+	// LoadNodeState returns the state saved under key, if any
+	LoadNodeState(key string) ([]byte, bool, error)
+	SaveNodeState(key string, state []byte) error
+	DeleteNodeState(key string) error
+}
+
+// stateDir holds a file per node state
+const stateDir = "state"
+
+// statePath returns the file of a node state
+func (fs *FileStorage) statePath(key string) string {
+	return filepath.Join(fs.baseDir, stateDir, url.PathEscape(key)+".json")
+}
+
+// LoadNodeState loads a node state from its file
+func (fs *FileStorage) LoadNodeState(key string) ([]byte, bool, error) {
+	state, err := ioutil.ReadFile(fs.statePath(key))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return state, true, nil
+}
+
+// SaveNodeState writes a node state to a temporary file and renames it, so
+// that a crash leaves the old state or the new one
+func (fs *FileStorage) SaveNodeState(key string, state []byte) error {
+	if err := os.MkdirAll(filepath.Join(fs.baseDir, stateDir), 0755); err != nil {
+		return err
+	}
+	path := fs.statePath(key)
+	if err := ioutil.WriteFile(path+".tmp", state, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// DeleteNodeState removes the file of a node state
+func (fs *FileStorage) DeleteNodeState(key string) error {
+	if err := os.Remove(fs.statePath(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// LoadNodeState loads a node state
+func (s *MemoryStorage) LoadNodeState(key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, exists := s.states[key]
+	return state, exists, nil
+}
+
+// SaveNodeState saves a node state
+func (s *MemoryStorage) SaveNodeState(key string, state []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.states == nil {
+		s.states = make(map[string][]byte)
+	}
+	s.states[key] = append([]byte{}, state...)
+	return nil
+}
+
+// DeleteNodeState deletes a node state
+func (s *MemoryStorage) DeleteNodeState(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, key)
+	return nil
+}
+
+const stateSchema = `CREATE TABLE IF NOT EXISTS gored_node_state (
+	key        TEXT PRIMARY KEY,
+	state      BYTEA NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`
+
+// LoadNodeState loads a node state
+func (s *SQLStorage) LoadNodeState(key string) ([]byte, bool, error) {
+	var state []byte
+	err := s.db.QueryRow(`SELECT state FROM gored_node_state WHERE key = $1`, key).Scan(&state)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return state, true, nil
+}
+
+// SaveNodeState saves a node state. Only the nodes of the leader run, so
+// the write is fenced like those of flows.
+func (s *SQLStorage) SaveNodeState(key string, state []byte) error {
+	return s.write(`INSERT INTO gored_node_state (key, state, updated_at) SELECT $1, $2, now() WHERE %s
+		ON CONFLICT (key) DO UPDATE SET state = EXCLUDED.state, updated_at = EXCLUDED.updated_at`, key, state)
+}
+
+// DeleteNodeState removes a node state
+func (s *SQLStorage) DeleteNodeState(key string) error {
+	return s.write(`DELETE FROM gored_node_state WHERE key = $1 AND %s`, key)
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"io/ioutil"
 	"os"
-	"path/filepath"
 	"strings"
 )
 
@@ -40,32 +39,29 @@ func NewFileStorage(baseDir string) (*FileStorage, error) {
 	}, nil
 }
 
-// SaveFlow saves a flow to a file
+// SaveFlow writes a flow to its file atomically, keeping the previous
+// version as a backup
 func (fs *FileStorage) SaveFlow(id string, flow []byte) error {
 	if id == "" {
 		return errors.New("flow ID cannot be empty")
 	}
 	
-	// Sanitize ID for use as a filename
-	id = strings.ReplaceAll(id, "/", "_")
-	id = strings.ReplaceAll(id, "\\", "_")
-	
-	filePath := filepath.Join(fs.baseDir, id+".json")
-	return ioutil.WriteFile(filePath, flow, 0644)
+	filePath := fs.flowPath(id)
+	if err := backUpFlow(filePath); err != nil {
+		return err
+	}
+	return writeFileAtomic(filePath, flow, 0644)
 }
 
-// LoadFlow loads a flow from a file
+// LoadFlow loads a flow from its file, or from the backup if the file is
+// corrupt
 func (fs *FileStorage) LoadFlow(id string) ([]byte, error) {
 	if id == "" {
 		return nil, errors.New("flow ID cannot be empty")
 	}
 	
-	// Sanitize ID for use as a filename
-	id = strings.ReplaceAll(id, "/", "_")
-	id = strings.ReplaceAll(id, "\\", "_")
-	
-	filePath := filepath.Join(fs.baseDir, id+".json")
-	return ioutil.ReadFile(filePath)
+	filePath := fs.flowPath(id)
+	return readFlowFile(filePath)
 }
 
 // DeleteFlow deletes a flow file
@@ -74,17 +70,16 @@ func (fs *FileStorage) DeleteFlow(id string) error {
 		return errors.New("flow ID cannot be empty")
 	}
 	
-	// Sanitize ID for use as a filename
-	id = strings.ReplaceAll(id, "/", "_")
-	id = strings.ReplaceAll(id, "\\", "_")
-	
-	filePath := filepath.Join(fs.baseDir, id+".json")
+	filePath := fs.flowPath(id)
 	
 	// Check if file exists
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		return errors.New("flow does not exist")
 	}
 	
+	if err := os.Remove(filePath + backupSuffix); err != nil && !os.IsNotExist(err) {
+		return err
+	}
 	return os.Remove(filePath)
 }
 
@@ -104,5 +99,11 @@ func (fs *FileStorage) ListFlows() ([]string, error) {
 		}
 	}
 	
-	return flows, nil
+	// Flows of workspaces other than the default one
+	workspaceFlows, err := fs.listWorkspaceFlows()
+	if err != nil {
+		return nil, err
+	}
+	
+	return append(flows, workspaceFlows...), nil
 }
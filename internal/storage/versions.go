@@ -0,0 +1,286 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrVersionNotFound is returned when loading a flow version that does not
+// exist
+var ErrVersionNotFound = errors.New("flow version not found")
+
+// FlowVersion describes a saved version of a flow
+type FlowVersion struct {
+	// Revision identifies the version among those of its flow
+	Revision  string    `json:"rev"`
+	Timestamp time.Time `json:"timestamp"`
+	Size      int       `json:"size"`
+	// Hash is the start of the SHA-256 of the definition
+	Hash string `json:"hash"`
+}
+
+// VersionStore is implemented by storages that keep earlier versions of
+// flows, so that a flow can be rolled back. Versions are keyed by flow and
+// revision, and listed newest first.
+type VersionStore interface {
+	// SaveFlowVersion saves a flow definition as its newest version, unless
+	// it is the same as the newest version, and removes all but the keep
+	// newest versions
+	SaveFlowVersion(id string, flow []byte, keep int) error
+	ListFlowVersions(id string) ([]FlowVersion, error)
+	LoadFlowVersion(id, rev string) ([]byte, error)
+}
+
+// versionDir holds a directory of versions per flow
+const versionDir = "versions"
+
+// versionHash returns the hash of a flow version
+func versionHash(flow []byte) string {
+	sum := sha256.Sum256(flow)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// newRevision returns the revision of a version saved at t: its time in
+// nanoseconds, or after the newest version saved before if that is later
+func newRevision(t time.Time, versions []FlowVersion) int64 {
+	rev := t.UnixNano()
+	if len(versions) > 0 {
+		if previous, err := strconv.ParseInt(versions[0].Revision, 10, 64); err == nil && previous >= rev {
+			rev = previous + 1
+		}
+	}
+	return rev
+}
+
+// versionPath returns the directory of a flow's versions
+func (fs *FileStorage) versionPath(id string) string {
+	return filepath.Join(fs.baseDir, versionDir, url.PathEscape(id))
+}
+
+// SaveFlowVersion writes a flow version to a file named by its revision
+// and hash
+func (fs *FileStorage) SaveFlowVersion(id string, flow []byte, keep int) error {
+	versions, err := fs.ListFlowVersions(id)
+	if err != nil {
+		return err
+	}
+	hash := versionHash(flow)
+	if len(versions) > 0 && versions[0].Hash == hash {
+		return nil
+	}
+
+	dir := fs.versionPath(id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	rev := strconv.FormatInt(newRevision(time.Now(), versions), 10)
+	path := filepath.Join(dir, rev+"-"+hash+".json")
+	if err := writeFileAtomic(path, flow, 0644); err != nil {
+		return err
+	}
+
+	// The new version is not listed yet, so keep one less of the others
+	for i := keep - 1; i >= 0 && i < len(versions); i++ {
+		version := versions[i]
+		if err := os.Remove(filepath.Join(dir, version.Revision+"-"+version.Hash+".json")); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListFlowVersions lists the version files of a flow
+func (fs *FileStorage) ListFlowVersions(id string) ([]FlowVersion, error) {
+	files, err := ioutil.ReadDir(fs.versionPath(id))
+	if os.IsNotExist(err) {
+		return []FlowVersion{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]FlowVersion, 0, len(files))
+	for _, file := range files {
+		name := strings.TrimSuffix(file.Name(), ".json")
+		rev, hash, ok := strings.Cut(name, "-")
+		nanos, err := strconv.ParseInt(rev, 10, 64)
+		if file.IsDir() || name == file.Name() || !ok || err != nil {
+			continue
+		}
+		versions = append(versions, FlowVersion{
+			Revision:  rev,
+			Timestamp: time.Unix(0, nanos),
+			Size:      int(file.Size()),
+			Hash:      hash,
+		})
+	}
+	sortVersions(versions)
+	return versions, nil
+}
+
+// LoadFlowVersion loads a flow version from its file
+func (fs *FileStorage) LoadFlowVersion(id, rev string) ([]byte, error) {
+	versions, err := fs.ListFlowVersions(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, version := range versions {
+		if version.Revision == rev {
+			return ioutil.ReadFile(filepath.Join(fs.versionPath(id), rev+"-"+version.Hash+".json"))
+		}
+	}
+	return nil, ErrVersionNotFound
+}
+
+// sortVersions sorts versions newest first
+func sortVersions(versions []FlowVersion) {
+	sort.Slice(versions, func(i, j int) bool {
+		a, _ := strconv.ParseInt(versions[i].Revision, 10, 64)
+		b, _ := strconv.ParseInt(versions[j].Revision, 10, 64)
+		return a > b
+	})
+}
+
+// memoryVersion is a flow version kept by MemoryStorage
+type memoryVersion struct {
+	FlowVersion
+	flow []byte
+}
+
+// SaveFlowVersion saves a flow version
+func (s *MemoryStorage) SaveFlowVersion(id string, flow []byte, keep int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.versions == nil {
+		s.versions = make(map[string][]memoryVersion)
+	}
+	versions := s.versions[id]
+	hash := versionHash(flow)
+	if len(versions) > 0 && versions[0].Hash == hash {
+		return nil
+	}
+
+	listed := make([]FlowVersion, len(versions))
+	for i, version := range versions {
+		listed[i] = version.FlowVersion
+	}
+	now := time.Now()
+	version := memoryVersion{
+		FlowVersion: FlowVersion{
+			Revision:  strconv.FormatInt(newRevision(now, listed), 10),
+			Timestamp: now,
+			Size:      len(flow),
+			Hash:      hash,
+		},
+		flow: append([]byte{}, flow...),
+	}
+	versions = append([]memoryVersion{version}, versions...)
+	if keep > 0 && len(versions) > keep {
+		versions = versions[:keep]
+	}
+	s.versions[id] = versions
+	return nil
+}
+
+// ListFlowVersions lists the versions of a flow
+func (s *MemoryStorage) ListFlowVersions(id string) ([]FlowVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	versions := make([]FlowVersion, 0, len(s.versions[id]))
+	for _, version := range s.versions[id] {
+		versions = append(versions, version.FlowVersion)
+	}
+	return versions, nil
+}
+
+// LoadFlowVersion loads a flow version
+func (s *MemoryStorage) LoadFlowVersion(id, rev string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, version := range s.versions[id] {
+		if version.Revision == rev {
+			return version.flow, nil
+		}
+	}
+	return nil, ErrVersionNotFound
+}
+
+const versionSchema = `CREATE TABLE IF NOT EXISTS gored_flow_versions (
+	flow_id    TEXT NOT NULL,
+	rev        BIGINT NOT NULL,
+	definition TEXT NOT NULL,
+	hash       TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (flow_id, rev)
+)`
+
+// SaveFlowVersion saves a flow version. Like flows, versions are only
+// written by the leader.
+func (s *SQLStorage) SaveFlowVersion(id string, flow []byte, keep int) error {
+	versions, err := s.ListFlowVersions(id)
+	if err != nil {
+		return err
+	}
+	hash := versionHash(flow)
+	if len(versions) > 0 && versions[0].Hash == hash {
+		return nil
+	}
+
+	now := time.Now()
+	err = s.write(`INSERT INTO gored_flow_versions (flow_id, rev, definition, hash, created_at)
+		SELECT $1, $2, $3, $4, $5 WHERE %s`, id, newRevision(now, versions), string(flow), hash, now)
+	if err != nil || keep <= 0 || len(versions) < keep {
+		return err
+	}
+	return s.write(`DELETE FROM gored_flow_versions WHERE flow_id = $1 AND rev NOT IN
+		(SELECT rev FROM gored_flow_versions WHERE flow_id = $1 ORDER BY rev DESC LIMIT $2) AND %s`, id, keep)
+}
+
+// ListFlowVersions lists the versions of a flow
+func (s *SQLStorage) ListFlowVersions(id string) ([]FlowVersion, error) {
+	rows, err := s.db.Query(`SELECT rev, created_at, octet_length(definition), hash FROM gored_flow_versions
+		WHERE flow_id = $1 ORDER BY rev DESC`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []FlowVersion{}
+	for rows.Next() {
+		var version FlowVersion
+		var rev int64
+		if err := rows.Scan(&rev, &version.Timestamp, &version.Size, &version.Hash); err != nil {
+			return nil, err
+		}
+		version.Revision = strconv.FormatInt(rev, 10)
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+// LoadFlowVersion loads a flow version
+func (s *SQLStorage) LoadFlowVersion(id, rev string) ([]byte, error) {
+	revision, err := strconv.ParseInt(rev, 10, 64)
+	if err != nil {
+		return nil, ErrVersionNotFound
+	}
+	var definition string
+	err = s.db.QueryRow(`SELECT definition FROM gored_flow_versions WHERE flow_id = $1 AND rev = $2`, id, revision).Scan(&definition)
+	if err == sql.ErrNoRows {
+		return nil, ErrVersionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(definition), nil
+}
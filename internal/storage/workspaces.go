@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrWorkspaceExists is returned when creating a workspace twice
+	ErrWorkspaceExists = errors.New("workspace already exists")
+
+	// ErrWorkspaceNotFound is returned when deleting an unknown workspace
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+)
+
+// Workspace is a namespace of flows. The IDs of flows in a workspace have
+// the form "workspace/flow"; flows of the default workspace keep plain IDs.
+type Workspace struct {
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+}
+
+// WorkspaceStore is implemented by storages that keep the list of
+// workspaces, including ones that have no flows yet
+type WorkspaceStore interface {
+	ListWorkspaces() ([]Workspace, error)
+	CreateWorkspace(name string) (Workspace, error)
+	// DeleteWorkspace removes a workspace; its flows must have been deleted
+	DeleteWorkspace(name string) error
+}
+
+// workspacesDir holds a directory of flows per workspace
+const workspacesDir = "workspaces"
+
+// flowPath returns the file of a flow, which is below the directory of its
+// workspace if the ID has the form "workspace/flow"
+func (fs *FileStorage) flowPath(id string) string {
+	dir := fs.baseDir
+	if workspace, flowID, ok := strings.Cut(id, "/"); ok {
+		dir = filepath.Join(fs.baseDir, workspacesDir, sanitizeFileName(workspace))
+		id = flowID
+	}
+	return filepath.Join(dir, sanitizeFileName(id)+".json")
+}
+
+// sanitizeFileName makes an ID usable as a file name
+func sanitizeFileName(id string) string {
+	id = strings.ReplaceAll(id, "/", "_")
+	return strings.ReplaceAll(id, "\\", "_")
+}
+
+// ListWorkspaces lists the workspace directories
+func (fs *FileStorage) ListWorkspaces() ([]Workspace, error) {
+	files, err := ioutil.ReadDir(filepath.Join(fs.baseDir, workspacesDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	workspaces := make([]Workspace, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() {
+			workspaces = append(workspaces, Workspace{Name: file.Name(), Created: file.ModTime()})
+		}
+	}
+	return workspaces, nil
+}
+
+// CreateWorkspace creates the directory of a workspace
+func (fs *FileStorage) CreateWorkspace(name string) (Workspace, error) {
+	dir := filepath.Join(fs.baseDir, workspacesDir, sanitizeFileName(name))
+	if _, err := os.Stat(dir); err == nil {
+		return Workspace{}, ErrWorkspaceExists
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Workspace{}, err
+	}
+	return Workspace{Name: name, Created: time.Now()}, nil
+}
+
+// DeleteWorkspace removes the directory of a workspace
+func (fs *FileStorage) DeleteWorkspace(name string) error {
+	dir := filepath.Join(fs.baseDir, workspacesDir, sanitizeFileName(name))
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return ErrWorkspaceNotFound
+	}
+	return os.RemoveAll(dir)
+}
+
+// listWorkspaceFlows lists the IDs of the flows in workspace directories
+func (fs *FileStorage) listWorkspaceFlows() ([]string, error) {
+	workspaces, err := fs.ListWorkspaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var flows []string
+	for _, workspace := range workspaces {
+		files, err := ioutil.ReadDir(filepath.Join(fs.baseDir, workspacesDir, workspace.Name))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if !file.IsDir() && strings.HasSuffix(file.Name(), ".json") {
+				flows = append(flows, workspace.Name+"/"+strings.TrimSuffix(file.Name(), ".json"))
+			}
+		}
+	}
+	return flows, nil
+}
+
+const workspaceSchema = `CREATE TABLE IF NOT EXISTS gored_workspaces (
+	name       TEXT PRIMARY KEY,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`
+
+// ListWorkspaces lists the workspaces
+func (s *SQLStorage) ListWorkspaces() ([]Workspace, error) {
+	rows, err := s.db.Query(`SELECT name, created_at FROM gored_workspaces ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var workspaces []Workspace
+	for rows.Next() {
+		var workspace Workspace
+		if err := rows.Scan(&workspace.Name, &workspace.Created); err != nil {
+			return nil, err
+		}
+		workspaces = append(workspaces, workspace)
+	}
+	return workspaces, rows.Err()
+}
+
+// CreateWorkspace adds a workspace
+func (s *SQLStorage) CreateWorkspace(name string) (Workspace, error) {
+	var exists bool
+	if err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM gored_workspaces WHERE name = $1)`, name).Scan(&exists); err != nil {
+		return Workspace{}, err
+	}
+	if exists {
+		return Workspace{}, ErrWorkspaceExists
+	}
+
+	workspace := Workspace{Name: name, Created: time.Now()}
+	err := s.write(`INSERT INTO gored_workspaces (name, created_at) SELECT $1, $2 WHERE %s
+		ON CONFLICT (name) DO NOTHING`, workspace.Name, workspace.Created)
+	return workspace, err
+}
+
+// DeleteWorkspace removes a workspace
+func (s *SQLStorage) DeleteWorkspace(name string) error {
+	err := s.db.QueryRow(`SELECT name FROM gored_workspaces WHERE name = $1`, name).Scan(&name)
+	if err == sql.ErrNoRows {
+		return ErrWorkspaceNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return s.write(`DELETE FROM gored_workspaces WHERE name = $1 AND %s`, name)
+}
@@ -0,0 +1,395 @@
+// Package templates turns flows into reusable templates, whose values are
+// replaced by ${param:name} placeholders, and creates flows from them
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// Parameter types
+const (
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+)
+
+// placeholder matches ${param:name}
+var placeholder = regexp.MustCompile(`\$\{param:([^}]*)\}`)
+
+// validName is what parameter names may consist of
+var validName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Parameter is a value of a template that differs between the flows created
+// from it. Parameters without a default are required.
+type Parameter struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+	Description string      `json:"description,omitempty"`
+	// Secret parameters, such as passwords, never have a default, so that
+	// exported templates contain no secrets
+	Secret bool `json:"secret,omitempty"`
+	// Env names an environment variable supplying the value when none is
+	// given
+	Env string `json:"env,omitempty"`
+}
+
+// Template is a flow definition with ${param:name} placeholders in place of
+// values. A placeholder making up a whole string is replaced by the typed
+// value, so that "${param:port}" becomes a number.
+type Template struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  []Parameter            `json:"parameters"`
+	Flow        map[string]interface{} `json:"flow"`
+}
+
+// Mark selects a config value of a node to become a parameter
+type Mark struct {
+	Parameter
+	Node string `json:"node"`
+	// Field is the path of the value in the node's config, with dots
+	// between object keys and array indexes, e.g. "topics.0.topic"
+	Field string `json:"field"`
+}
+
+// ValidationError lists the problems of a template, or of the parameters
+// a flow is created with
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid template: " + strings.Join(e.Problems, "; ")
+}
+
+// Export turns a flow definition into a template. Marked values are
+// replaced by placeholders of their parameters, which default to the
+// replaced values unless secret. Placeholders already in the definition
+// declare required string parameters unless a mark declares them.
+func Export(flowDef []byte, name, description string, marks []Mark) (*Template, error) {
+	var flow map[string]interface{}
+	if err := engine.DecodeJSON(flowDef, &flow); err != nil {
+		return nil, fmt.Errorf("failed to decode flow definition: %w", err)
+	}
+	if name == "" {
+		name, _ = flow["id"].(string)
+	}
+
+	template := &Template{
+		Name:        name,
+		Description: description,
+		Parameters:  []Parameter{},
+		Flow:        flow,
+	}
+	declared := make(map[string]bool)
+	for _, mark := range marks {
+		param, err := markValue(flow, mark)
+		if err != nil {
+			return nil, err
+		}
+		if !declared[param.Name] {
+			template.Parameters = append(template.Parameters, param)
+			declared[param.Name] = true
+		}
+	}
+
+	var existing []string
+	for name := range placeholders(flow) {
+		if !declared[name] {
+			existing = append(existing, name)
+		}
+	}
+	sort.Strings(existing)
+	for _, name := range existing {
+		template.Parameters = append(template.Parameters, Parameter{Name: name, Type: TypeString})
+	}
+
+	if err := template.Validate(); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// markValue replaces the marked config value by a placeholder and returns
+// the mark's parameter
+func markValue(flow map[string]interface{}, mark Mark) (Parameter, error) {
+	param := mark.Parameter
+	nodes, _ := flow["nodes"].([]interface{})
+	var config interface{}
+	found := false
+	for _, node := range nodes {
+		if node, ok := node.(map[string]interface{}); ok && node["id"] == mark.Node {
+			config, found = node["config"], true
+		}
+	}
+	if !found {
+		return param, fmt.Errorf("parameter %s: node %s not found", param.Name, mark.Node)
+	}
+
+	// Walk to the container of the marked value
+	keys := strings.Split(mark.Field, ".")
+	container := config
+	for _, key := range keys[:len(keys)-1] {
+		var ok bool
+		if container, ok = child(container, key); !ok {
+			return param, fmt.Errorf("parameter %s: node %s has no config field %s", param.Name, mark.Node, mark.Field)
+		}
+	}
+	key := keys[len(keys)-1]
+	value, ok := child(container, key)
+	if !ok {
+		return param, fmt.Errorf("parameter %s: node %s has no config field %s", param.Name, mark.Node, mark.Field)
+	}
+
+	if param.Type == "" {
+		switch value.(type) {
+		case string:
+			param.Type = TypeString
+		case bool:
+			param.Type = TypeBoolean
+		default:
+			if _, isNumber := engine.ToFloat(value); !isNumber {
+				return param, fmt.Errorf("parameter %s: only strings, numbers and booleans can be parameters", param.Name)
+			}
+			param.Type = TypeNumber
+		}
+	}
+	if param.Default == nil && !param.Secret {
+		param.Default = value
+	}
+
+	marker := "${param:" + param.Name + "}"
+	switch container := container.(type) {
+	case map[string]interface{}:
+		container[key] = marker
+	case []interface{}:
+		index, _ := strconv.Atoi(key)
+		container[index] = marker
+	}
+	return param, nil
+}
+
+// child returns the value under key of an object, or at the index key of
+// an array
+func child(container interface{}, key string) (interface{}, bool) {
+	switch container := container.(type) {
+	case map[string]interface{}:
+		value, ok := container[key]
+		return value, ok
+	case []interface{}:
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 || index >= len(container) {
+			return nil, false
+		}
+		return container[index], true
+	}
+	return nil, false
+}
+
+// Validate checks that the parameters have unique, valid names and known
+// types, that secret parameters have no default, and that every parameter
+// is referenced and every placeholder declared
+func (t *Template) Validate() error {
+	var problems []string
+	if t.Flow == nil {
+		problems = append(problems, "template has no flow")
+	}
+
+	declared := make(map[string]bool, len(t.Parameters))
+	for _, param := range t.Parameters {
+		switch {
+		case !validName.MatchString(param.Name):
+			problems = append(problems, fmt.Sprintf("invalid parameter name %q", param.Name))
+			continue
+		case declared[param.Name]:
+			problems = append(problems, fmt.Sprintf("parameter %s is declared twice", param.Name))
+			continue
+		}
+		declared[param.Name] = true
+
+		switch param.Type {
+		case "", TypeString, TypeNumber, TypeBoolean:
+		default:
+			problems = append(problems, fmt.Sprintf("parameter %s has unknown type %q", param.Name, param.Type))
+			continue
+		}
+		if param.Secret && param.Default != nil {
+			problems = append(problems, fmt.Sprintf("secret parameter %s must not have a default", param.Name))
+		} else if param.Default != nil {
+			if _, err := convert(param, param.Default); err != nil {
+				problems = append(problems, fmt.Sprintf("default of parameter %s: %v", param.Name, err))
+			}
+		}
+	}
+
+	referenced := placeholders(t.Flow)
+	for _, param := range t.Parameters {
+		if declared[param.Name] && !referenced[param.Name] {
+			problems = append(problems, fmt.Sprintf("parameter %s is not referenced", param.Name))
+		}
+	}
+	var undeclared []string
+	for name := range referenced {
+		if !declared[name] {
+			undeclared = append(undeclared, name)
+		}
+	}
+	sort.Strings(undeclared)
+	for _, name := range undeclared {
+		problems = append(problems, fmt.Sprintf("placeholder ${param:%s} is not declared", name))
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// Instantiate returns the template's flow definition with the placeholders
+// replaced. A parameter takes its value from values, else from its
+// environment variable, else its default; required parameters without a
+// value are an error.
+func (t *Template) Instantiate(values map[string]interface{}) ([]byte, error) {
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	resolved := make(map[string]interface{}, len(t.Parameters))
+	for _, param := range t.Parameters {
+		value, given := values[param.Name]
+		if !given && param.Env != "" {
+			value, given = os.LookupEnv(param.Env)
+		}
+		if !given && param.Default != nil {
+			value, given = param.Default, true
+		}
+		if !given {
+			problems = append(problems, fmt.Sprintf("parameter %s is required", param.Name))
+			continue
+		}
+		converted, err := convert(param, value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("parameter %s: %v", param.Name, err))
+			continue
+		}
+		resolved[param.Name] = converted
+	}
+	var unknown []string
+	for name := range values {
+		if !t.declares(name) {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		problems = append(problems, fmt.Sprintf("unknown parameter %s", name))
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+
+	return json.Marshal(substitute(t.Flow, resolved))
+}
+
+// declares reports whether the template has a parameter
+func (t *Template) declares(name string) bool {
+	for _, param := range t.Parameters {
+		if param.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// convert checks a parameter value against the parameter's type; strings
+// are parsed for numbers and booleans
+func convert(param Parameter, value interface{}) (interface{}, error) {
+	switch param.Type {
+	case TypeNumber:
+		if i, ok := engine.ToInt(value); ok {
+			return i, nil
+		}
+		if f, ok := engine.ToFloat(value); ok {
+			return f, nil
+		}
+		return nil, fmt.Errorf("%v is not a number", value)
+	case TypeBoolean:
+		switch value := value.(type) {
+		case bool:
+			return value, nil
+		case string:
+			if b, err := strconv.ParseBool(value); err == nil {
+				return b, nil
+			}
+		}
+		return nil, fmt.Errorf("%v is not a boolean", value)
+	default:
+		switch value.(type) {
+		case map[string]interface{}, []interface{}, nil:
+			return nil, fmt.Errorf("%v is not a string", value)
+		}
+		return fmt.Sprint(value), nil
+	}
+}
+
+// placeholders returns the names of the placeholders in the strings below
+// value
+func placeholders(value interface{}) map[string]bool {
+	names := make(map[string]bool)
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch value := value.(type) {
+		case string:
+			for _, match := range placeholder.FindAllStringSubmatch(value, -1) {
+				names[match[1]] = true
+			}
+		case map[string]interface{}:
+			for _, v := range value {
+				walk(v)
+			}
+		case []interface{}:
+			for _, v := range value {
+				walk(v)
+			}
+		}
+	}
+	walk(value)
+	return names
+}
+
+// substitute returns a copy of value with the placeholders replaced
+func substitute(value interface{}, values map[string]interface{}) interface{} {
+	switch value := value.(type) {
+	case string:
+		if match := placeholder.FindStringSubmatch(value); match != nil && match[0] == value {
+			return values[match[1]]
+		}
+		return placeholder.ReplaceAllStringFunc(value, func(marker string) string {
+			return fmt.Sprint(values[placeholder.FindStringSubmatch(marker)[1]])
+		})
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			copied[k] = substitute(v, values)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(value))
+		for i, v := range value {
+			copied[i] = substitute(v, values)
+		}
+		return copied
+	default:
+		return value
+	}
+}
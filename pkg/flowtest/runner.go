@@ -0,0 +1,603 @@
+package flowtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/registry"
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// RecorderType is the node type that replaces mocked nodes and captures
+// messages for expectations
+const RecorderType = "flowtest-recorder"
+
+// captures holds the capture of every running case by flow ID
+var captures sync.Map
+
+// Runner runs suites against node types from a registry
+type Runner struct {
+	registry *registry.Registry
+}
+
+// Result is the outcome of a test case
+type Result struct {
+	Suite    string
+	Case     string
+	Passed   bool
+	Failures []string
+	Duration time.Duration
+}
+
+// T is the part of testing.TB used by Runner.Test
+type T interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// NewRunner creates a runner, registering the recorder node type if needed
+func NewRunner(reg *registry.Registry) (*Runner, error) {
+	if _, err := reg.GetNodeType(RecorderType); err != nil {
+		err := reg.RegisterNodeType(&engine.NodeType{
+			Name:        RecorderType,
+			Description: "Captures messages in flow tests",
+			Category:    "test",
+			Defaults:    json.RawMessage(`{}`),
+			Factory: func() engine.NodeInstance {
+				return &recorderNode{}
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Runner{registry: reg}, nil
+}
+
+// RunFile loads and runs a suite file
+func (r *Runner) RunFile(path string) ([]Result, error) {
+	suite, err := LoadSuite(path)
+	if err != nil {
+		return nil, err
+	}
+	return r.RunSuite(suite), nil
+}
+
+// RunSuite runs every case of a suite, each on a fresh engine
+func (r *Runner) RunSuite(suite *Suite) []Result {
+	results := make([]Result, 0, len(suite.Cases))
+	for i := range suite.Cases {
+		results = append(results, r.runCase(suite, &suite.Cases[i]))
+	}
+	return results
+}
+
+// Test runs suite files from a Go test and reports failed cases on t
+func (r *Runner) Test(t T, paths ...string) {
+	t.Helper()
+
+	for _, path := range paths {
+		results, err := r.RunFile(path)
+		if err != nil {
+			t.Errorf("%v", err)
+			continue
+		}
+		for _, result := range results {
+			for _, failure := range result.Failures {
+				t.Errorf("%s / %s: %s", result.Suite, result.Case, failure)
+			}
+		}
+	}
+}
+
+// Report prints one line per case and the failures of failed cases, and
+// returns whether all cases passed
+func Report(w io.Writer, results []Result) bool {
+	passed := true
+	for _, result := range results {
+		if result.Passed {
+			fmt.Fprintf(w, "PASS  %s / %s (%s)\n", result.Suite, result.Case, result.Duration.Round(time.Millisecond))
+			continue
+		}
+
+		passed = false
+		fmt.Fprintf(w, "FAIL  %s / %s (%s)\n", result.Suite, result.Case, result.Duration.Round(time.Millisecond))
+		for _, failure := range result.Failures {
+			fmt.Fprintf(w, "      %s\n", failure)
+		}
+	}
+	return passed
+}
+
+// runCase runs a single case
+func (r *Runner) runCase(suite *Suite, c *Case) Result {
+	started := time.Now()
+	result := Result{Suite: suite.Name, Case: c.Name}
+	fail := func(format string, args ...interface{}) Result {
+		result.Failures = append(result.Failures, fmt.Sprintf(format, args...))
+		result.Duration = time.Since(started)
+		return result
+	}
+
+	timeout, err := c.timeout()
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	plan, err := newPlan(suite, c)
+	if err != nil {
+		return fail("%v", err)
+	}
+	flowDef, err := json.Marshal(plan.def)
+	if err != nil {
+		return fail("failed to encode flow: %v", err)
+	}
+
+	capture := newCapture()
+	captures.Store(plan.def.ID, capture)
+	defer captures.Delete(plan.def.ID)
+
+	eng := engine.New(r.registry, storage.NewMemoryStorage())
+	if err := eng.Start(); err != nil {
+		return fail("failed to start engine: %v", err)
+	}
+	defer eng.Stop()
+
+	if err := eng.DeployFlow(plan.def.ID, flowDef); err != nil {
+		return fail("failed to deploy flow: %v", err)
+	}
+	flow, _ := eng.GetFlow(plan.def.ID)
+
+	for _, injection := range c.Inject {
+		if err := plan.inject(flow, capture, injection); err != nil {
+			return fail("%v", err)
+		}
+	}
+
+	// Wait until the expectations are met or the timeout expires
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	failures := plan.check(capture, c.Ordered)
+	for len(failures) > 0 {
+		select {
+		case <-ctx.Done():
+			result.Failures = failures
+			result.Duration = time.Since(started)
+			return result
+		case <-capture.changed:
+		}
+		failures = plan.check(capture, c.Ordered)
+	}
+
+	result.Passed = true
+	result.Duration = time.Since(started)
+	return result
+}
+
+// plan is a case's rewritten flow and compiled expectations
+type plan struct {
+	def          engine.FlowDefinition
+	expectations []*expectation
+	// nodeTaps maps node IDs to the recorders capturing their input
+	nodeTaps map[string]string
+}
+
+// newPlan rewrites the suite's flow for a case: mocked nodes become
+// recorders and every expectation gets a recorder to capture into
+func newPlan(suite *Suite, c *Case) (*plan, error) {
+	p := &plan{nodeTaps: make(map[string]string)}
+	if err := json.Unmarshal(suite.flowDef, &p.def); err != nil {
+		return nil, fmt.Errorf("invalid flow: %w", err)
+	}
+	p.def.ID = "flowtest-" + engine.NewUUID()
+
+	// Replace mocked nodes
+	mocked := make(map[string]bool)
+	for _, mock := range suite.Mocks {
+		matched := false
+		for i := range p.def.Nodes {
+			node := &p.def.Nodes[i]
+			if (mock.Type != "" && node.Type == mock.Type) || (mock.Node != "" && (node.ID == mock.Node || node.Name == mock.Node)) {
+				node.Type = RecorderType
+				node.Config = json.RawMessage(`{}`)
+				mocked[node.ID] = true
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("mock matches no node: %+v", mock)
+		}
+	}
+
+	// Attach recorders
+	wireTaps := make(map[string]string)
+	for i, e := range c.Expect {
+		exp, err := compileExpectation(e)
+		if err != nil {
+			return nil, fmt.Errorf("expectation %d: %w", i+1, err)
+		}
+
+		switch {
+		case e.Node != "" && e.Wire != nil:
+			return nil, fmt.Errorf("expectation %d: set either node or wire", i+1)
+		case e.Node != "":
+			id, err := p.resolve(e.Node)
+			if err != nil {
+				return nil, fmt.Errorf("expectation %d: %w", i+1, err)
+			}
+			exp.desc = "node " + e.Node
+			if mocked[id] {
+				exp.recorder = id
+				break
+			}
+			if exp.recorder = p.nodeTaps[id]; exp.recorder == "" {
+				exp.recorder = p.addTap()
+				p.nodeTaps[id] = exp.recorder
+				for _, wire := range p.def.Wires {
+					if wire.Target == id {
+						p.def.Wires = append(p.def.Wires, engine.WireDefinition{Source: wire.Source, Target: exp.recorder, Port: wire.Port})
+					}
+				}
+			}
+		case e.Wire != nil:
+			id, err := p.resolve(e.Wire.Source)
+			if err != nil {
+				return nil, fmt.Errorf("expectation %d: %w", i+1, err)
+			}
+			exp.desc = fmt.Sprintf("wire %s:%d", e.Wire.Source, e.Wire.Port)
+			key := fmt.Sprintf("%s:%d", id, e.Wire.Port)
+			if exp.recorder = wireTaps[key]; exp.recorder == "" {
+				exp.recorder = p.addTap()
+				wireTaps[key] = exp.recorder
+				p.def.Wires = append(p.def.Wires, engine.WireDefinition{Source: id, Target: exp.recorder, Port: e.Wire.Port})
+			}
+		default:
+			return nil, fmt.Errorf("expectation %d: node or wire is required", i+1)
+		}
+
+		p.expectations = append(p.expectations, exp)
+	}
+
+	return p, nil
+}
+
+// resolve returns the ID of the node with the given ID or name
+func (p *plan) resolve(ref string) (string, error) {
+	for _, node := range p.def.Nodes {
+		if node.ID == ref {
+			return node.ID, nil
+		}
+	}
+
+	id := ""
+	for _, node := range p.def.Nodes {
+		if node.Name == ref {
+			if id != "" {
+				return "", fmt.Errorf("node name %q is ambiguous", ref)
+			}
+			id = node.ID
+		}
+	}
+	if id == "" {
+		return "", fmt.Errorf("node %q not found", ref)
+	}
+	return id, nil
+}
+
+// addTap adds a recorder node and returns its ID
+func (p *plan) addTap() string {
+	id := fmt.Sprintf("flowtest-tap-%d", len(p.def.Nodes))
+	p.def.Nodes = append(p.def.Nodes, engine.NodeDefinition{
+		ID:     id,
+		Type:   RecorderType,
+		Config: json.RawMessage(`{}`),
+	})
+	return id
+}
+
+// inject delivers an injected message to its node. Injected messages count
+// as input of the node for expectations.
+func (p *plan) inject(flow *engine.Flow, c *capture, injection Injection) error {
+	if injection.Delay != "" {
+		delay, err := time.ParseDuration(injection.Delay)
+		if err != nil {
+			return fmt.Errorf("invalid delay: %s", injection.Delay)
+		}
+		time.Sleep(delay)
+	}
+
+	id, err := p.resolve(injection.Node)
+	if err != nil {
+		return fmt.Errorf("inject: %w", err)
+	}
+	node, exists := flow.GetNode(id)
+	if !exists {
+		return fmt.Errorf("inject: node %q has no runtime instance", injection.Node)
+	}
+
+	msg := engine.NewMessage(injection.Payload, injection.Topic)
+	msg.SourceID = "flowtest"
+	for key, value := range injection.Headers {
+		msg.SetHeader(key, value)
+	}
+	for key, value := range injection.Metadata {
+		msg.SetMetadata(key, value)
+	}
+
+	if tap, exists := p.nodeTaps[id]; exists {
+		c.add(tap, msg.Clone())
+	}
+	if err := node.Receive(msg, injection.Port); err != nil {
+		return fmt.Errorf("inject into %s: %w", injection.Node, err)
+	}
+	return nil
+}
+
+// check returns the unmet expectations. Absent expectations are checked
+// against the messages captured so far.
+func (p *plan) check(c *capture, ordered bool) []string {
+	records := c.snapshot()
+	used := make([]bool, len(records))
+	last := -1
+
+	var failures []string
+	for _, e := range p.expectations {
+		if e.Absent {
+			continue
+		}
+
+		need := e.Count
+		if need <= 0 {
+			need = 1
+		}
+		found, lastMatch := 0, last
+		for i, rec := range records {
+			if found == need {
+				break
+			}
+			if used[i] || rec.recorder != e.recorder || (ordered && i <= last) {
+				continue
+			}
+			if e.matches(rec.msg) {
+				used[i] = true
+				found++
+				lastMatch = i
+			}
+		}
+		if found < need {
+			failures = append(failures, fmt.Sprintf("%s: expected %d matching message(s), got %d", e.desc, need, found))
+		}
+		if ordered {
+			last = lastMatch
+		}
+	}
+
+	for _, e := range p.expectations {
+		if !e.Absent {
+			continue
+		}
+		for _, rec := range records {
+			if rec.recorder == e.recorder && e.matches(rec.msg) {
+				failures = append(failures, fmt.Sprintf("%s: expected no matching message", e.desc))
+				break
+			}
+		}
+	}
+
+	return failures
+}
+
+// expectation is a compiled Expectation
+type expectation struct {
+	Expectation
+	desc      string
+	recorder  string
+	payload   interface{}
+	contains  interface{}
+	payloadRe *regexp.Regexp
+	topicRe   *regexp.Regexp
+}
+
+// compileExpectation parses the matchers of an expectation
+func compileExpectation(e Expectation) (*expectation, error) {
+	exp := &expectation{Expectation: e}
+
+	if len(e.Payload) > 0 {
+		if err := engine.DecodeJSON(e.Payload, &exp.payload); err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+	}
+	if len(e.PayloadContains) > 0 {
+		if err := engine.DecodeJSON(e.PayloadContains, &exp.contains); err != nil {
+			return nil, fmt.Errorf("invalid payloadContains: %w", err)
+		}
+	}
+
+	var err error
+	if e.PayloadMatches != "" {
+		if exp.payloadRe, err = regexp.Compile(e.PayloadMatches); err != nil {
+			return nil, fmt.Errorf("invalid payloadMatches: %w", err)
+		}
+	}
+	if e.TopicMatches != "" {
+		if exp.topicRe, err = regexp.Compile(e.TopicMatches); err != nil {
+			return nil, fmt.Errorf("invalid topicMatches: %w", err)
+		}
+	}
+
+	return exp, nil
+}
+
+// matches reports whether a captured message satisfies all matchers
+func (e *expectation) matches(msg *engine.Message) bool {
+	if e.Topic != "" && msg.Topic != e.Topic {
+		return false
+	}
+	if e.topicRe != nil && !e.topicRe.MatchString(msg.Topic) {
+		return false
+	}
+
+	if e.payloadRe != nil {
+		var text string
+		switch v := msg.Payload.(type) {
+		case string:
+			text = v
+		case []byte:
+			text = string(v)
+		default:
+			return false
+		}
+		if !e.payloadRe.MatchString(text) {
+			return false
+		}
+	}
+
+	if e.payload == nil && e.contains == nil {
+		return true
+	}
+	actual := normalize(msg.Payload)
+	if e.payload != nil && !subset(e.payload, actual, true) {
+		return false
+	}
+	if e.contains != nil && !subset(e.contains, actual, false) {
+		return false
+	}
+	return true
+}
+
+// normalize converts a payload to its JSON form so that it compares with
+// decoded expectations; byte slices compare as strings
+func normalize(payload interface{}) interface{} {
+	if data, ok := payload.([]byte); ok {
+		return string(data)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return payload
+	}
+	var value interface{}
+	engine.DecodeJSON(data, &value)
+	return value
+}
+
+// subset reports whether expected is contained in actual. With exact set,
+// objects must have the same keys.
+func subset(expected, actual interface{}, exact bool) bool {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok || (exact && len(a) != len(e)) {
+			return false
+		}
+		for key, value := range e {
+			if !subset(value, a[key], exact) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(a) != len(e) {
+			return false
+		}
+		for i := range e {
+			if !subset(e[i], a[i], exact) {
+				return false
+			}
+		}
+		return true
+	default:
+		_, expectedFloat := expected.(float64)
+		_, actualFloat := actual.(float64)
+		if expectedFloat != actualFloat && isNumber(expected) && isNumber(actual) {
+			// 5.0 decodes to float64 and 5 to int64
+			e, _ := engine.ToFloat(expected)
+			a, _ := engine.ToFloat(actual)
+			return e == a
+		}
+		return expected == actual
+	}
+}
+
+// isNumber reports whether a decoded JSON value is a number
+func isNumber(value interface{}) bool {
+	switch value.(type) {
+	case int64, uint64, float64:
+		return true
+	}
+	return false
+}
+
+// capture collects the messages recorded during a case
+type capture struct {
+	mu      sync.Mutex
+	records []record
+	changed chan struct{}
+}
+
+// record is a captured message and the recorder that captured it
+type record struct {
+	recorder string
+	msg      *engine.Message
+}
+
+func newCapture() *capture {
+	return &capture{changed: make(chan struct{}, 1)}
+}
+
+func (c *capture) add(recorder string, msg *engine.Message) {
+	c.mu.Lock()
+	c.records = append(c.records, record{recorder: recorder, msg: msg})
+	c.mu.Unlock()
+
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (c *capture) snapshot() []record {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]record{}, c.records...)
+}
+
+// recorderNode captures every message it receives
+type recorderNode struct {
+	engine.BaseNode
+}
+
+func (n *recorderNode) Init(config json.RawMessage) error {
+	return nil
+}
+
+func (n *recorderNode) Start(ctx context.Context) error {
+	return nil
+}
+
+func (n *recorderNode) Stop() {}
+
+func (n *recorderNode) OnMessage(msg *engine.Message, port int) error {
+	// Record streams by their content so that expectations can match it
+	if stream, ok := msg.Payload.(*engine.Stream); ok {
+		data, err := stream.Bytes()
+		if err != nil {
+			return fmt.Errorf("failed to read stream payload: %w", err)
+		}
+		msg.Payload = data
+	}
+
+	node := n.GetNode()
+	if c, ok := captures.Load(node.GetFlow().ID); ok {
+		c.(*capture).add(node.ID, msg.Clone())
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+// Package flowtest runs declarative regression tests against flows. A suite
+// loads a flow, replaces external nodes with recorders, injects messages into
+// named nodes and checks the messages captured at nodes or on wires.
+package flowtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultCaseTimeout is how long a case waits for its expectations
+const defaultCaseTimeout = time.Second
+
+// Suite is a set of test cases run against one flow
+type Suite struct {
+	Name string `json:"name"`
+	// Flow is a path to a flow definition, relative to the suite file, or
+	// an inline flow definition
+	Flow json.RawMessage `json:"flow"`
+	// Mocks replaces nodes with recorders for every case
+	Mocks []Mock `json:"mocks"`
+	Cases []Case `json:"cases"`
+
+	flowDef []byte
+}
+
+// Mock replaces a node, selected by ID or name, or every node of a type, with
+// a recorder that captures its input instead of talking to the outside world
+type Mock struct {
+	Node string `json:"node"`
+	Type string `json:"type"`
+}
+
+// Case is a single test case
+type Case struct {
+	Name   string        `json:"name"`
+	Inject []Injection   `json:"inject"`
+	Expect []Expectation `json:"expect"`
+	// Ordered requires the expectations to be met in the listed order
+	Ordered bool `json:"ordered"`
+	// Timeout is how long to wait for the expectations; defaults to 1s
+	Timeout string `json:"timeout"`
+}
+
+// Injection is a message delivered to a node's input
+type Injection struct {
+	// Node is the ID or name of the receiving node
+	Node     string                 `json:"node"`
+	Port     int                    `json:"port"`
+	Payload  interface{}            `json:"payload"`
+	Topic    string                 `json:"topic"`
+	Headers  map[string]string      `json:"headers"`
+	Metadata map[string]interface{} `json:"metadata"`
+	// Delay waits before injecting, e.g. to exercise time windows
+	Delay string `json:"delay"`
+}
+
+// Expectation describes messages that must be captured at a node or on a
+// wire. All matchers that are set must match.
+type Expectation struct {
+	// Node is the ID or name of a node whose input is captured
+	Node string `json:"node"`
+	// Wire captures the messages a node sends on one of its ports
+	Wire *WireTap `json:"wire"`
+	// Payload must equal the captured payload (compared as JSON)
+	Payload json.RawMessage `json:"payload"`
+	// PayloadContains must be a subset of the captured payload
+	PayloadContains json.RawMessage `json:"payloadContains"`
+	// PayloadMatches is a regular expression for string payloads
+	PayloadMatches string `json:"payloadMatches"`
+	Topic          string `json:"topic"`
+	// TopicMatches is a regular expression for the topic
+	TopicMatches string `json:"topicMatches"`
+	// Count is the number of matching messages required; defaults to 1.
+	// Set Absent instead to require that no message matches.
+	Count  int  `json:"count"`
+	Absent bool `json:"absent"`
+}
+
+// WireTap is an assertion tap on the wires leaving a node port
+type WireTap struct {
+	Source string `json:"source"`
+	Port   int    `json:"port"`
+}
+
+// LoadSuite reads a suite from a JSON or YAML file
+func LoadSuite(path string) (*Suite, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read suite: %w", err)
+	}
+
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, fmt.Errorf("invalid suite %s: %w", path, err)
+		}
+	}
+
+	var suite Suite
+	if err := json.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("invalid suite %s: %w", path, err)
+	}
+	if suite.Name == "" {
+		suite.Name = filepath.Base(path)
+	}
+
+	flow := bytes.TrimSpace(suite.Flow)
+	switch {
+	case len(flow) == 0:
+		return nil, fmt.Errorf("suite %s has no flow", suite.Name)
+	case flow[0] == '"':
+		var flowPath string
+		json.Unmarshal(flow, &flowPath)
+		if !filepath.IsAbs(flowPath) {
+			flowPath = filepath.Join(filepath.Dir(path), flowPath)
+		}
+		if suite.flowDef, err = ioutil.ReadFile(flowPath); err != nil {
+			return nil, fmt.Errorf("failed to read flow of suite %s: %w", suite.Name, err)
+		}
+	default:
+		suite.flowDef = flow
+	}
+
+	return &suite, nil
+}
+
+// yamlToJSON converts a YAML document to JSON so that one set of struct tags
+// serves both formats
+func yamlToJSON(data []byte) ([]byte, error) {
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// timeout returns the case timeout
+func (c *Case) timeout() (time.Duration, error) {
+	if c.Timeout == "" {
+		return defaultCaseTimeout, nil
+	}
+	timeout, err := time.ParseDuration(c.Timeout)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid timeout: %s", c.Timeout)
+	}
+	return timeout, nil
+}
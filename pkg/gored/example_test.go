@@ -0,0 +1,47 @@
+package gored_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/yourusername/go-red/pkg/gored"
+)
+
+// greetNode prints a greeting for every message
+type greetNode struct {
+	gored.BaseNode
+}
+
+func (n *greetNode) Init(json.RawMessage) error      { return nil }
+func (n *greetNode) Start(ctx context.Context) error { return nil }
+func (n *greetNode) Stop()                           {}
+
+func (n *greetNode) OnMessage(msg *gored.Message, port int) error {
+	fmt.Printf("Hello, %v!\n", msg.Payload)
+	return nil
+}
+
+func Example() {
+	rt, err := gored.NewRuntime(gored.WithRegistry(gored.NewRegistry()))
+	if err != nil {
+		log.Fatal(err)
+	}
+	rt.RegisterNodeType(&gored.NodeType{
+		Name:    "greet",
+		Factory: func() gored.NodeInstance { return &greetNode{} },
+	})
+	if err := rt.Start(); err != nil {
+		log.Fatal(err)
+	}
+	defer rt.Stop()
+
+	if err := rt.DeployFlow("hello", []byte(`{"id": "hello", "nodes": [{"id": "greeter", "type": "greet"}]}`)); err != nil {
+		log.Fatal(err)
+	}
+	flow, _ := rt.Engine().GetFlow("hello")
+	greeter, _ := flow.GetNode("greeter")
+	greeter.Receive(gored.NewMessage("world", ""), 0)
+	// Output: Hello, world!
+}
@@ -0,0 +1,323 @@
+// Package gored embeds the go-red flow engine in other programs. A Runtime
+// bundles the engine with its node registry, storage and configuration, and
+// optionally serves the HTTP API and editor:
+//
+//	rt, err := gored.NewRuntime(gored.WithHTTPServer(":1880"))
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	rt.RegisterNodeType(&gored.NodeType{
+//		Name:    "greet",
+//		Factory: func() gored.NodeInstance { return &greetNode{} },
+//	})
+//	if err := rt.Start(); err != nil {
+//		log.Fatal(err)
+//	}
+//	defer rt.Stop()
+//	rt.DeployFlow("hello", flowJSON)
+package gored
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/server"
+)
+
+// shutdownTimeout is how long Stop waits for active HTTP requests
+const shutdownTimeout = 5 * time.Second
+
+// Runtime is an embedded go-red instance
+type Runtime struct {
+	engine   *engine.Engine
+	registry *Registry
+	storage  Storage
+	config   *Config
+	server   *server.Server
+	addr     string
+	loaded   bool
+	serving  bool
+	mu       sync.Mutex
+}
+
+// Option configures a Runtime
+type Option func(*options)
+
+type options struct {
+	storage  Storage
+	registry *Registry
+	config   *Config
+	logger   *log.Logger
+	handler  slog.Handler
+	addr     string
+}
+
+// WithStorage keeps the flows in store; by default they are kept in memory
+func WithStorage(store Storage) Option {
+	return func(o *options) { o.storage = store }
+}
+
+// WithRegistry takes the node types from reg; by default the runtime has
+// the built-in node types
+func WithRegistry(reg *Registry) Option {
+	return func(o *options) { o.registry = reg }
+}
+
+// WithConfig applies the settings of cfg, such as the network, hook and
+// workspace settings
+func WithConfig(cfg *Config) Option {
+	return func(o *options) { o.config = cfg }
+}
+
+// WithLogger sends go-red's log output, which is written through the
+// standard logger, to logger
+func WithLogger(logger *log.Logger) Option {
+	return func(o *options) { o.logger = logger }
+}
+
+// WithLogHandler has the loggers of the engine, its flows and nodes write
+// to handler, which should pass all levels, since the engine filters them
+// by "log.level" and "log.flows.<flow>". Without it, they write in the
+// format of "log.format" if set, and else to slog's default logger.
+func WithLogHandler(handler slog.Handler) Option {
+	return func(o *options) { o.handler = handler }
+}
+
+// WithHTTPServer serves the HTTP API, the editor and the routes of http-in
+// nodes on addr once the runtime is started. Without it nothing listens;
+// Handler returns the same handler for the program's own server.
+func WithHTTPServer(addr string) Option {
+	return func(o *options) { o.addr = addr }
+}
+
+// NewRuntime creates a runtime. Its flows are loaded and run by Start, so
+// that node types can be registered first.
+func NewRuntime(opts ...Option) (*Runtime, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.logger != nil {
+		log.SetOutput(o.logger.Writer())
+		log.SetFlags(o.logger.Flags())
+		log.SetPrefix(o.logger.Prefix())
+	}
+	if o.config == nil {
+		o.config = NewConfig()
+	}
+	if o.storage == nil {
+		o.storage = NewMemoryStorage()
+	}
+	if o.registry == nil {
+		reg, err := NewBuiltinRegistry()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load builtin nodes: %w", err)
+		}
+		o.registry = reg
+	}
+	if err := registerHooks(o.config, o.registry); err != nil {
+		return nil, fmt.Errorf("failed to register hooks: %w", err)
+	}
+
+	eng := engine.New(o.registry, o.storage)
+	if o.handler == nil {
+		if _, exists := o.config.Get("log.format"); exists {
+			handler, err := newLogHandler(o.config, log.Writer())
+			if err != nil {
+				return nil, err
+			}
+			o.handler = handler
+		}
+	}
+	if o.handler != nil {
+		eng.SetLogHandler(o.handler)
+	}
+	eng.SetCredentialSecret(o.config.GetString("credentialSecret"))
+	if err := configureEngine(o.config, eng); err != nil {
+		return nil, err
+	}
+	// Reloaded settings apply to the running engine; invalid ones are
+	// reported and leave the settings before them applied
+	o.config.OnChange("", func(keys []string) {
+		if err := configureEngine(o.config, eng); err != nil {
+			log.Printf("Warning: failed to apply reloaded config: %v", err)
+		}
+	})
+	return &Runtime{
+		engine:   eng,
+		registry: o.registry,
+		storage:  o.storage,
+		config:   o.config,
+		server:   server.New(o.config, eng, o.storage),
+		addr:     o.addr,
+	}, nil
+}
+
+// RegisterNodeType adds a node type, which must happen before flows using
+// it are loaded
+func (r *Runtime) RegisterNodeType(nodeType *NodeType) error {
+	return r.registry.RegisterNodeType(nodeType)
+}
+
+// RegisterHook adds a message hook
+func (r *Runtime) RegisterHook(stage HookStage, name string, fn Hook) error {
+	return r.engine.RegisterHook(stage, name, fn)
+}
+
+// Load loads the stored flows without running them. Start calls it if it
+// was not called before.
+func (r *Runtime) Load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.loaded {
+		return nil
+	}
+	if err := r.engine.Initialize(); err != nil {
+		return fmt.Errorf("failed to load flows: %w", err)
+	}
+	r.loaded = true
+	return nil
+}
+
+// Start loads and runs the stored flows and starts the HTTP server
+func (r *Runtime) Start() error {
+	if err := r.Load(); err != nil {
+		return err
+	}
+	if err := r.engine.Start(); err != nil {
+		return fmt.Errorf("failed to start engine: %w", err)
+	}
+	return r.Serve()
+}
+
+// Serve starts the HTTP server configured with WithHTTPServer without
+// running the flows, e.g. while another cluster member runs them
+func (r *Runtime) Serve() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.addr == "" || r.serving {
+		return nil
+	}
+
+	if !r.config.GetBool("https.enabled") {
+		listener, err := net.Listen("tcp", r.addr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", r.addr, err)
+		}
+		r.serving = true
+		go r.serve(listener, "HTTP", r.server.Serve)
+		return nil
+	}
+
+	if err := r.server.EnableTLS(); err != nil {
+		return err
+	}
+	// With https.port, HTTPS gets its own port and the HTTP one redirects
+	// to it
+	addr, redirect := r.addr, false
+	if port := r.config.GetInt("https.port"); port != 0 {
+		host, _, err := net.SplitHostPort(r.addr)
+		if err != nil {
+			return fmt.Errorf("invalid address %s: %w", r.addr, err)
+		}
+		addr, redirect = net.JoinHostPort(host, strconv.Itoa(port)), true
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	if redirect {
+		httpListener, err := net.Listen("tcp", r.addr)
+		if err != nil {
+			listener.Close()
+			return fmt.Errorf("failed to listen on %s: %w", r.addr, err)
+		}
+		port := r.config.GetInt("https.port")
+		go r.serve(httpListener, "HTTP redirect", func(l net.Listener) error {
+			return r.server.ServeRedirect(l, port)
+		})
+	}
+	r.serving = true
+	go r.serve(listener, "HTTPS", r.server.Serve)
+	return nil
+}
+
+// serve runs a server on a listener, logging why it stopped unless it was
+// shut down
+func (r *Runtime) serve(listener net.Listener, name string, serve func(net.Listener) error) {
+	if err := serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Warning: %s server stopped: %v", name, err)
+	}
+}
+
+// Stop stops the HTTP server and the flows
+func (r *Runtime) Stop() error {
+	r.mu.Lock()
+	serving := r.serving
+	r.serving = false
+	r.mu.Unlock()
+
+	if serving {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := r.server.Shutdown(ctx); err != nil {
+			log.Printf("Warning: failed to shut down HTTP server: %v", err)
+		}
+	}
+	if r.engine.Status() != engine.StatusRunning {
+		return nil
+	}
+	return r.engine.Stop()
+}
+
+// DeployFlow saves a flow definition and runs it if the runtime is started.
+// A deployed flow replaces the running flow with the same ID.
+func (r *Runtime) DeployFlow(id string, flowDef []byte) error {
+	return r.engine.DeployFlow(id, flowDef)
+}
+
+// DeleteFlow stops and removes a flow
+func (r *Runtime) DeleteFlow(id string) error {
+	return r.engine.DeleteFlow(id)
+}
+
+// Handler returns the handler of the HTTP API, the editor and the routes
+// of http-in nodes
+func (r *Runtime) Handler() http.Handler {
+	return r.server.Handler()
+}
+
+// Engine returns the runtime's engine
+func (r *Runtime) Engine() *Engine {
+	return r.engine
+}
+
+// Registry returns the runtime's node registry
+func (r *Runtime) Registry() *Registry {
+	return r.registry
+}
+
+// Storage returns the runtime's flow storage
+func (r *Runtime) Storage() Storage {
+	return r.storage
+}
+
+// Config returns the runtime's configuration
+func (r *Runtime) Config() *Config {
+	return r.config
+}
+
+// Server returns the runtime's HTTP server
+func (r *Runtime) Server() *Server {
+	return r.server
+}
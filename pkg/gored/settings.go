@@ -0,0 +1,140 @@
+package gored
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/yourusername/go-red/internal/config"
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/registry"
+)
+
+// registerHooks registers the built-in message hooks enabled by
+// "messages.maxHops" and "messages.trace", then loads the plugins listed in
+// "plugins", which may register hooks of their own
+func registerHooks(cfg *config.Config, reg *registry.Registry) error {
+	if max := cfg.GetInt("messages.maxHops"); max > 0 {
+		reg.RegisterHook(engine.HookPreSend, "hop-limit", engine.HopLimitHook(max))
+	}
+	if cfg.GetBool("messages.trace") {
+		reg.RegisterHook(engine.HookPreReceive, "trace", engine.TraceHook())
+	}
+
+	for _, path := range cfg.GetStringSlice("plugins") {
+		if err := reg.LoadNodePlugin(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// configureEngine applies the engine settings of the configuration. It runs
+// again whenever the config file is reloaded, so the settings it applies
+// take effect live.
+func configureEngine(cfg *config.Config, eng *engine.Engine) error {
+	if err := eng.Network().Configure(networkProfiles(cfg)); err != nil {
+		return fmt.Errorf("invalid network settings: %w", err)
+	}
+	if max := cfg.GetInt("correlations.maxPending"); max > 0 {
+		eng.Correlations().SetMaxPending(max)
+	}
+	eng.SetAllowUnknownTypes(cfg.GetBool("flows.allowUnknownTypes"))
+	eng.SetCopyOnWrite(cfg.GetBool("messages.copyOnWrite"))
+	eng.SetAllowLoops(cfg.GetBool("flows.allowLoops"))
+	eng.SetLoopTTL(cfg.GetInt("flows.loopTTL"))
+	eng.SetPersistentContextPrefixes(cfg.GetStringSlice("context.persistentPrefixes"))
+	if _, exists := cfg.Get("flows.panicThreshold"); exists {
+		eng.SetPanicThreshold(cfg.GetInt("flows.panicThreshold"))
+	}
+	if _, exists := cfg.Get("flows.versions"); exists {
+		eng.SetVersionRetention(cfg.GetInt("flows.versions"))
+	}
+	err := eng.SetFlowLimits(engine.FlowLimits{
+		MaxMessagesPerSecond: cfg.GetFloat("flows.limits.maxMessagesPerSecond"),
+		MaxQueued:            cfg.GetInt("flows.limits.maxQueued"),
+		MaxConcurrent:        cfg.GetInt("flows.limits.maxConcurrent"),
+	})
+	if err != nil {
+		return fmt.Errorf("invalid flows.limits: %w", err)
+	}
+	if value, exists := cfg.Get("flows.readyTimeout"); exists {
+		d, ok := cfg.LookupDuration("flows.readyTimeout")
+		if !ok {
+			return fmt.Errorf("invalid flows.readyTimeout: %v", value)
+		}
+		eng.SetReadyTimeout(d)
+	}
+	if err := configureLogLevels(cfg, eng); err != nil {
+		return err
+	}
+	if value, exists := cfg.Get("editor.lockTTL"); exists {
+		d, ok := cfg.LookupDuration("editor.lockTTL")
+		if !ok {
+			return fmt.Errorf("invalid editor.lockTTL: %v", value)
+		}
+		eng.FlowLocks().SetTTL(d)
+	}
+	return nil
+}
+
+// configureLogLevels applies "log.level" and the levels of flows in
+// "log.flows.<flow>"
+func configureLogLevels(cfg *config.Config, eng *engine.Engine) error {
+	level, err := engine.ParseLogLevel(cfg.GetString("log.level"))
+	if err != nil {
+		return fmt.Errorf("invalid log.level: %w", err)
+	}
+	flowLevels := make(map[string]slog.Level)
+	for _, key := range cfg.Keys("log.flows.") {
+		flowLevel, err := engine.ParseLogLevel(cfg.GetString(key))
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		flowLevels[strings.TrimPrefix(key, "log.flows.")] = flowLevel
+	}
+	eng.SetLogLevel(level)
+	eng.SetFlowLogLevels(flowLevels)
+	return nil
+}
+
+// newLogHandler returns a handler writing to w in the format of
+// "log.format", text or json. It passes all levels; the engine filters
+// them.
+func newLogHandler(cfg *config.Config, w io.Writer) (slog.Handler, error) {
+	options := &slog.HandlerOptions{Level: slog.LevelDebug}
+	switch format := cfg.GetString("log.format"); format {
+	case "text", "":
+		return slog.NewTextHandler(w, options), nil
+	case "json":
+		return slog.NewJSONHandler(w, options), nil
+	default:
+		return nil, fmt.Errorf("invalid log.format %q, must be text or json", format)
+	}
+}
+
+// networkProfiles reads the default network profile from "network" and the
+// named ones from "network.profiles.<name>"
+func networkProfiles(cfg *config.Config) map[string]engine.NetworkProfile {
+	profiles := map[string]engine.NetworkProfile{
+		"": networkProfile(cfg, "network."),
+	}
+	for _, key := range cfg.Keys("network.profiles.") {
+		name, _, _ := strings.Cut(strings.TrimPrefix(key, "network.profiles."), ".")
+		if _, exists := profiles[name]; !exists {
+			profiles[name] = networkProfile(cfg, "network.profiles."+name+".")
+		}
+	}
+	return profiles
+}
+
+func networkProfile(cfg *config.Config, prefix string) engine.NetworkProfile {
+	return engine.NetworkProfile{
+		HTTPProxy:          cfg.GetString(prefix + "proxy.http"),
+		HTTPSProxy:         cfg.GetString(prefix + "proxy.https"),
+		NoProxy:            cfg.GetString(prefix + "proxy.no_proxy"),
+		CAFile:             cfg.GetString(prefix + "tls.caFile"),
+		InsecureSkipVerify: cfg.GetBool(prefix + "tls.insecureSkipVerify"),
+	}
+}
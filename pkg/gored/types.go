@@ -0,0 +1,86 @@
+package gored
+
+import (
+	"github.com/yourusername/go-red/internal/config"
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/registry"
+	"github.com/yourusername/go-red/internal/server"
+	"github.com/yourusername/go-red/internal/storage"
+)
+
+// The types programs embedding go-red work with
+type (
+	Engine       = engine.Engine
+	Flow         = engine.Flow
+	Node         = engine.Node
+	NodeType     = engine.NodeType
+	NodeInstance = engine.NodeInstance
+	NodeFactory  = engine.NodeFactory
+	BaseNode     = engine.BaseNode
+	Message      = engine.Message
+	Hook         = engine.Hook
+	HookStage    = engine.HookStage
+	HookContext  = engine.HookContext
+	Registry     = registry.Registry
+	Storage      = storage.Storage
+	Config       = config.Config
+	Server       = server.Server
+)
+
+// The stages at which hooks run
+const (
+	HookPreSend     = engine.HookPreSend
+	HookPreReceive  = engine.HookPreReceive
+	HookPostReceive = engine.HookPostReceive
+	HookNodeError   = engine.HookNodeError
+)
+
+// ErrDropMessage is returned by hooks to drop a message quietly
+var ErrDropMessage = engine.ErrDropMessage
+
+// NewMessage creates a message with a payload and topic
+func NewMessage(payload interface{}, topic string) *Message {
+	return engine.NewMessage(payload, topic)
+}
+
+// NewRegistry creates a registry without node types
+func NewRegistry() *Registry {
+	return registry.New()
+}
+
+// NewBuiltinRegistry creates a registry with the built-in node types
+func NewBuiltinRegistry() (*Registry, error) {
+	reg := registry.New()
+	if err := reg.LoadBuiltinNodes(); err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+// NewConfig creates an empty configuration
+func NewConfig() *Config {
+	return config.New()
+}
+
+// LoadConfig reads a JSON, YAML or TOML configuration file
+func LoadConfig(path string) (*Config, error) {
+	cfg := config.New()
+	if err := cfg.LoadFromFile(path); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// NewFileStorage creates a storage keeping each flow in a JSON file of dir
+func NewFileStorage(dir string) (Storage, error) {
+	store, err := storage.NewFileStorage(dir)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// NewMemoryStorage creates a storage keeping flows in memory
+func NewMemoryStorage() Storage {
+	return storage.NewMemoryStorage()
+}
@@ -0,0 +1,296 @@
+package input
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// Cron control commands, sent in the "control" metadata of an incoming
+// message together with an optional "schedule" name
+const (
+	CronControlPause  = "pause"
+	CronControlResume = "resume"
+)
+
+// cronParser accepts five or six field expressions and descriptors such as
+// @hourly
+var cronParser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// CronSchedule is a single schedule of a cron node
+type CronSchedule struct {
+	Name string `json:"name"`
+	// Expression is a cron expression with an optional seconds field,
+	// e.g. "30 7 * * 1-5"
+	Expression string          `json:"expression"`
+	Payload    json.RawMessage `json:"payload"`
+	Topic      string          `json:"topic"`
+	// Paused schedules do not fire until resumed
+	Paused bool `json:"paused"`
+}
+
+// CronConfig represents the configuration of a cron node
+type CronConfig struct {
+	// Timezone is an IANA zone such as "Europe/Berlin"; defaults to UTC
+	Timezone  string         `json:"timezone"`
+	Schedules []CronSchedule `json:"schedules"`
+	// CatchUp fires a schedule once on start if it missed executions while
+	// the node was stopped; requires StateFile
+	CatchUp bool `json:"catchUp"`
+	// StateFile records the last fire time of each schedule
+	StateFile string `json:"stateFile"`
+}
+
+// CronNode emits messages on cron schedules
+type CronNode struct {
+	engine.BaseNode
+	config    CronConfig
+	location  *time.Location
+	schedules []*cronEntry
+
+	stateMu sync.Mutex
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// cronEntry is a parsed schedule and its runtime state
+type cronEntry struct {
+	config   CronSchedule
+	schedule cron.Schedule
+	mu       sync.Mutex
+	paused   bool
+}
+
+// RegisterCronNode registers the cron node type
+func RegisterCronNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "cron",
+		Description: "Emits messages on cron schedules",
+		Category:    "input",
+		Defaults:    json.RawMessage(`{"timezone":"UTC","schedules":[{"name":"schedule","expression":"0 * * * *"}]}`),
+		Factory: func() engine.NodeInstance {
+			return &CronNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *CronNode) Init(config json.RawMessage) error {
+	n.config = CronConfig{Timezone: "UTC"}
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &n.config); err != nil {
+			return fmt.Errorf("invalid cron config: %w", err)
+		}
+	}
+
+	location, err := time.LoadLocation(n.config.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", n.config.Timezone, err)
+	}
+	n.location = location
+
+	if len(n.config.Schedules) == 0 {
+		return fmt.Errorf("at least one schedule is required")
+	}
+	if n.config.CatchUp && n.config.StateFile == "" {
+		return fmt.Errorf("catchUp requires a stateFile")
+	}
+
+	names := make(map[string]bool)
+	n.schedules = nil
+	for i, config := range n.config.Schedules {
+		if config.Name == "" {
+			config.Name = fmt.Sprintf("schedule-%d", i+1)
+		}
+		if names[config.Name] {
+			return fmt.Errorf("duplicate schedule name: %s", config.Name)
+		}
+		names[config.Name] = true
+
+		schedule, err := cronParser.Parse(config.Expression)
+		if err != nil {
+			return fmt.Errorf("invalid expression for schedule %s: %w", config.Name, err)
+		}
+		if len(config.Payload) > 0 && !json.Valid(config.Payload) {
+			return fmt.Errorf("invalid payload for schedule %s", config.Name)
+		}
+
+		n.schedules = append(n.schedules, &cronEntry{
+			config:   config,
+			schedule: schedule,
+			paused:   config.Paused,
+		})
+	}
+
+	return nil
+}
+
+// Start catches up missed executions and starts the schedules
+func (n *CronNode) Start(ctx context.Context) error {
+	ctx, n.cancel = context.WithCancel(ctx)
+
+	var state map[string]time.Time
+	if n.config.CatchUp {
+		state = n.loadState()
+	}
+
+	now := n.GetNode().Clock().Now().In(n.location)
+	for _, entry := range n.schedules {
+		if last, ok := state[entry.config.Name]; ok {
+			// Fire at most once for all executions missed since the last run
+			if missed := entry.schedule.Next(last.In(n.location)); missed.Before(now) && !entry.isPaused() {
+				n.fire(entry, missed, true)
+			}
+		}
+
+		n.wg.Add(1)
+		go n.run(ctx, entry)
+	}
+
+	return nil
+}
+
+// Stop stops all schedules
+func (n *CronNode) Stop() {
+	if n.cancel != nil {
+		n.cancel()
+		n.cancel = nil
+	}
+	n.wg.Wait()
+}
+
+// OnMessage handles pause and resume control messages
+func (n *CronNode) OnMessage(msg *engine.Message, port int) error {
+	control, _ := msg.GetMetadata("control")
+	name, _ := msg.GetMetadata("schedule")
+
+	var paused bool
+	switch control {
+	case CronControlPause:
+		paused = true
+	case CronControlResume:
+		paused = false
+	default:
+		return fmt.Errorf("cron: unknown control %v", control)
+	}
+
+	matched := false
+	for _, entry := range n.schedules {
+		if name == nil || name == "" || name == entry.config.Name {
+			entry.setPaused(paused)
+			matched = true
+		}
+	}
+	if !matched {
+		return fmt.Errorf("cron: unknown schedule %v", name)
+	}
+	return nil
+}
+
+// run fires a schedule until the context is cancelled
+func (n *CronNode) run(ctx context.Context, entry *cronEntry) {
+	defer n.wg.Done()
+
+	clock := n.GetNode().Clock()
+	for {
+		// Next works in the schedule's time zone, so DST transitions are
+		// resolved there rather than in the server's local time
+		now := clock.Now().In(n.location)
+		next := entry.schedule.Next(now)
+		if next.IsZero() {
+			return // the expression never fires again
+		}
+
+		timer := clock.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		if !entry.isPaused() {
+			n.fire(entry, next, false)
+		}
+	}
+}
+
+// fire emits the message of a schedule
+func (n *CronNode) fire(entry *cronEntry, scheduled time.Time, catchUp bool) {
+	var payload interface{} = scheduled.Unix()
+	if len(entry.config.Payload) > 0 {
+		engine.DecodeJSON(entry.config.Payload, &payload)
+	}
+
+	msg := engine.NewMessage(payload, entry.config.Topic)
+	msg.SourceID = n.GetNode().ID
+	msg.SetMetadata("schedule", entry.config.Name)
+	msg.SetMetadata("scheduledTime", scheduled.Format(time.RFC3339Nano))
+	msg.SetMetadata("firedTime", n.GetNode().Clock().Now().In(n.location).Format(time.RFC3339Nano))
+	if catchUp {
+		msg.SetMetadata("catchUp", true)
+	}
+
+	if n.config.StateFile != "" {
+		n.saveState(entry.config.Name, scheduled)
+	}
+	if err := n.GetNode().Send(msg, 0); err != nil {
+		n.GetNode().Logger().Warn("failed to send message", "schedule", entry.config.Name, "error", err)
+	}
+}
+
+// loadState reads the last fire times from the state file
+func (n *CronNode) loadState() map[string]time.Time {
+	n.stateMu.Lock()
+	defer n.stateMu.Unlock()
+
+	state := make(map[string]time.Time)
+	data, err := ioutil.ReadFile(n.config.StateFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			n.GetNode().Logger().Warn("failed to read state", "error", err)
+		}
+		return state
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		n.GetNode().Logger().Warn("invalid state file", "error", err)
+	}
+	return state
+}
+
+// saveState records the last fire time of a schedule
+func (n *CronNode) saveState(name string, fired time.Time) {
+	state := n.loadState()
+
+	n.stateMu.Lock()
+	defer n.stateMu.Unlock()
+
+	state[name] = fired
+	data, err := json.Marshal(state)
+	if err == nil {
+		err = ioutil.WriteFile(n.config.StateFile, data, 0644)
+	}
+	if err != nil {
+		n.GetNode().Logger().Warn("failed to save state", "error", err)
+	}
+}
+
+func (e *cronEntry) isPaused() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.paused
+}
+
+func (e *cronEntry) setPaused(paused bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.paused = paused
+}
@@ -0,0 +1,119 @@
+package input
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// In Europe/Berlin clocks go forward from 02:00 to 03:00 on 2026-03-29 and
+// back from 03:00 to 02:00 on 2026-10-25
+const dstZone = "Europe/Berlin"
+
+// dstCase is a schedule and the times it fires at after from, in RFC 3339
+// with the zone's offset
+type dstCase struct {
+	name  string
+	expr  string
+	from  string
+	fires []string
+}
+
+var dstCases = []dstCase{
+	{
+		name:  "weekdays keep their wall time",
+		expr:  "30 7 * * 1-5",
+		from:  "2026-03-26T12:00:00+01:00",
+		fires: []string{"2026-03-27T07:30:00+01:00", "2026-03-30T07:30:00+02:00", "2026-03-31T07:30:00+02:00"},
+	},
+	{
+		name:  "time skipped going forward",
+		expr:  "30 2 * * *",
+		from:  "2026-03-27T12:00:00+01:00",
+		fires: []string{"2026-03-28T02:30:00+01:00", "2026-03-30T02:30:00+02:00"},
+	},
+	{
+		name:  "time repeated going back",
+		expr:  "30 2 * * *",
+		from:  "2026-10-24T12:00:00+02:00",
+		fires: []string{"2026-10-25T02:30:00+02:00", "2026-10-25T02:30:00+01:00", "2026-10-26T02:30:00+01:00"},
+	},
+	{
+		name:  "hourly going forward",
+		expr:  "0 * * * *",
+		from:  "2026-03-29T00:30:00+01:00",
+		fires: []string{"2026-03-29T01:00:00+01:00", "2026-03-29T03:00:00+02:00", "2026-03-29T04:00:00+02:00"},
+	},
+	{
+		name:  "hourly going back",
+		expr:  "0 * * * *",
+		from:  "2026-10-25T00:30:00+02:00",
+		fires: []string{"2026-10-25T01:00:00+02:00", "2026-10-25T02:00:00+02:00", "2026-10-25T02:00:00+01:00", "2026-10-25T03:00:00+01:00"},
+	},
+	{
+		name:  "seconds field going back",
+		expr:  "0 0 3 25 10 *",
+		from:  "2026-10-25T01:00:00+02:00",
+		fires: []string{"2026-10-25T03:00:00+01:00", "2027-10-25T03:00:00+02:00"},
+	},
+}
+
+// checkFires checks the times a schedule fires at after a case's start
+func checkFires(t *testing.T, schedule cron.Schedule, location *time.Location, tt dstCase) {
+	t.Helper()
+	from, err := time.Parse(time.RFC3339, tt.from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := from.In(location)
+	for i, want := range tt.fires {
+		next = schedule.Next(next)
+		if got := next.Format(time.RFC3339); got != want {
+			t.Fatalf("fire %d at %s, want %s", i+1, got, want)
+		}
+	}
+}
+
+func TestCronScheduleDST(t *testing.T) {
+	for _, tt := range dstCases {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &CronNode{}
+			config := fmt.Sprintf(`{"timezone": %q, "schedules": [{"name": "s", "expression": %q}]}`, dstZone, tt.expr)
+			if err := n.Init(json.RawMessage(config)); err != nil {
+				t.Fatal(err)
+			}
+			checkFires(t, n.schedules[0].schedule, n.location, tt)
+		})
+	}
+}
+
+func TestInjectCronDST(t *testing.T) {
+	for _, tt := range dstCases {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &InjectNode{}
+			config := fmt.Sprintf(`{"timezone": %q, "cron": %q}`, dstZone, tt.expr)
+			if err := n.Init(json.RawMessage(config)); err != nil {
+				t.Fatal(err)
+			}
+			checkFires(t, n.schedule, n.location, tt)
+		})
+	}
+}
+
+func TestCronCatchUpAcrossDST(t *testing.T) {
+	n := &CronNode{}
+	config := fmt.Sprintf(`{"timezone": %q, "schedules": [{"name": "s", "expression": "30 2 * * *"}]}`, dstZone)
+	if err := n.Init(json.RawMessage(config)); err != nil {
+		t.Fatal(err)
+	}
+	// Stopped after the fire of 2026-03-28 and started on 2026-03-30, the
+	// node missed the fire of 2026-03-30 only, as 2026-03-29 has no 02:30
+	last, _ := time.Parse(time.RFC3339, "2026-03-28T02:30:00+01:00")
+	missed := n.schedules[0].schedule.Next(last.In(n.location))
+	if got, want := missed.Format(time.RFC3339), "2026-03-30T02:30:00+02:00"; got != want {
+		t.Errorf("missed fire at %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,297 @@
+package input
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grandcat/zeroconf"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// Discovery events, set in the "event" metadata of emitted messages
+const (
+	DiscoveryAppeared    = "appeared"
+	DiscoveryDisappeared = "disappeared"
+	DiscoveryInventory   = "inventory"
+	DiscoveryResolved    = "resolved"
+)
+
+// DiscoveryConfig represents the configuration of a discovery node
+type DiscoveryConfig struct {
+	// Service is the DNS-SD service type, e.g. "_http._tcp"
+	Service string `json:"service"`
+	Domain  string `json:"domain"`
+	// Interfaces limits discovery to the named network interfaces
+	Interfaces []string `json:"interfaces"`
+	// IPVersion is "any", "ipv4" or "ipv6"
+	IPVersion string `json:"ipVersion"`
+	// Interval is the time between scans; instances missing from a scan
+	// are reported as disappeared
+	Interval string `json:"interval"`
+	// BrowseTimeout is how long each scan and on-demand lookup listens
+	BrowseTimeout string `json:"browseTimeout"`
+	// Inventory emits the full list of instances after every scan
+	Inventory bool `json:"inventory"`
+}
+
+// DiscoveryNode browses mDNS/DNS-SD and emits a message when a service
+// instance appears or disappears. An incoming message whose payload names an
+// instance resolves that instance on demand.
+type DiscoveryNode struct {
+	engine.BaseNode
+	config        DiscoveryConfig
+	interval      time.Duration
+	browseTimeout time.Duration
+	options       []zeroconf.ClientOption
+
+	known  map[string]map[string]interface{}
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// RegisterDiscoveryNode registers the discovery node type
+func RegisterDiscoveryNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "discovery",
+		Description: "Discovers services on the local network with mDNS/DNS-SD",
+		Category:    "input",
+		Defaults:    json.RawMessage(`{"service":"_http._tcp","domain":"local.","ipVersion":"any","interval":"30s","browseTimeout":"5s","inventory":false}`),
+		Factory: func() engine.NodeInstance {
+			return &DiscoveryNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *DiscoveryNode) Init(config json.RawMessage) error {
+	n.config = DiscoveryConfig{
+		Domain:        "local.",
+		IPVersion:     "any",
+		Interval:      "30s",
+		BrowseTimeout: "5s",
+	}
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &n.config); err != nil {
+			return fmt.Errorf("invalid discovery config: %w", err)
+		}
+	}
+
+	if n.config.Service == "" {
+		return fmt.Errorf("service is required")
+	}
+
+	var err error
+	if n.interval, err = time.ParseDuration(n.config.Interval); err != nil || n.interval <= 0 {
+		return fmt.Errorf("invalid interval: %s", n.config.Interval)
+	}
+	if n.browseTimeout, err = time.ParseDuration(n.config.BrowseTimeout); err != nil || n.browseTimeout <= 0 {
+		return fmt.Errorf("invalid browseTimeout: %s", n.config.BrowseTimeout)
+	}
+	if n.browseTimeout >= n.interval {
+		return fmt.Errorf("browseTimeout must be shorter than interval")
+	}
+
+	n.options = nil
+	switch n.config.IPVersion {
+	case "any", "":
+		n.options = append(n.options, zeroconf.SelectIPTraffic(zeroconf.IPv4AndIPv6))
+	case "ipv4":
+		n.options = append(n.options, zeroconf.SelectIPTraffic(zeroconf.IPv4))
+	case "ipv6":
+		n.options = append(n.options, zeroconf.SelectIPTraffic(zeroconf.IPv6))
+	default:
+		return fmt.Errorf("invalid ipVersion: %s", n.config.IPVersion)
+	}
+
+	if len(n.config.Interfaces) > 0 {
+		ifaces := make([]net.Interface, 0, len(n.config.Interfaces))
+		for _, name := range n.config.Interfaces {
+			iface, err := net.InterfaceByName(name)
+			if err != nil {
+				return fmt.Errorf("invalid interface %s: %w", name, err)
+			}
+			ifaces = append(ifaces, *iface)
+		}
+		n.options = append(n.options, zeroconf.SelectIfaces(ifaces))
+	}
+
+	return nil
+}
+
+// Start starts scanning
+func (n *DiscoveryNode) Start(ctx context.Context) error {
+	n.mu.Lock()
+	n.known = make(map[string]map[string]interface{})
+	n.mu.Unlock()
+
+	n.ctx, n.cancel = context.WithCancel(ctx)
+	n.wg.Add(1)
+	go n.run(n.ctx)
+	return nil
+}
+
+// Stop stops scanning
+func (n *DiscoveryNode) Stop() {
+	if n.cancel != nil {
+		n.cancel()
+	}
+	n.wg.Wait()
+}
+
+// OnMessage resolves the instance named by the payload
+func (n *DiscoveryNode) OnMessage(msg *engine.Message, port int) error {
+	instance, ok := msg.Payload.(string)
+	if !ok || instance == "" {
+		return fmt.Errorf("discovery: payload must be an instance name")
+	}
+
+	resolver, err := zeroconf.NewResolver(n.options...)
+	if err != nil {
+		return fmt.Errorf("discovery: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(n.ctx, n.browseTimeout)
+	defer cancel()
+
+	entries := make(chan *zeroconf.ServiceEntry)
+	if err := resolver.Lookup(ctx, instance, n.config.Service, n.config.Domain, entries); err != nil {
+		return fmt.Errorf("discovery: lookup failed: %w", err)
+	}
+
+	select {
+	case entry, ok := <-entries:
+		if !ok {
+			break
+		}
+		msg.Payload = serviceEntryPayload(entry)
+		msg.SetMetadata("event", DiscoveryResolved)
+		return n.GetNode().Send(msg, 0)
+	case <-ctx.Done():
+	}
+	return fmt.Errorf("discovery: instance %s not found", instance)
+}
+
+// run scans at every interval
+func (n *DiscoveryNode) run(ctx context.Context) {
+	defer n.wg.Done()
+
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+
+	for {
+		n.scan(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scan browses for BrowseTimeout and reports changes against the previous
+// scan
+func (n *DiscoveryNode) scan(ctx context.Context) {
+	resolver, err := zeroconf.NewResolver(n.options...)
+	if err != nil {
+		n.GetNode().Logger().Warn("failed to create resolver", "error", err)
+		return
+	}
+
+	browseCtx, cancel := context.WithTimeout(ctx, n.browseTimeout)
+	defer cancel()
+
+	entries := make(chan *zeroconf.ServiceEntry)
+	if err := resolver.Browse(browseCtx, n.config.Service, n.config.Domain, entries); err != nil {
+		n.GetNode().Logger().Warn("failed to browse", "service", n.config.Service, "error", err)
+		return
+	}
+
+	found := make(map[string]map[string]interface{})
+	for entry := range entries {
+		found[entry.ServiceInstanceName()] = serviceEntryPayload(entry)
+	}
+	if ctx.Err() != nil {
+		return // stopping; the scan is incomplete
+	}
+
+	n.mu.Lock()
+	previous := n.known
+	n.known = found
+	n.mu.Unlock()
+
+	for _, name := range sortedKeys(found) {
+		if _, exists := previous[name]; !exists {
+			n.emit(DiscoveryAppeared, found[name])
+		}
+	}
+	for _, name := range sortedKeys(previous) {
+		if _, exists := found[name]; !exists {
+			n.emit(DiscoveryDisappeared, previous[name])
+		}
+	}
+
+	if n.config.Inventory {
+		instances := make([]interface{}, 0, len(found))
+		for _, name := range sortedKeys(found) {
+			instances = append(instances, found[name])
+		}
+		n.emit(DiscoveryInventory, instances)
+	}
+}
+
+// emit sends a discovery event
+func (n *DiscoveryNode) emit(event string, payload interface{}) {
+	msg := engine.NewMessage(payload, n.config.Service)
+	msg.SourceID = n.GetNode().ID
+	msg.SetMetadata("event", event)
+	if err := n.GetNode().Send(msg, 0); err != nil {
+		n.GetNode().Logger().Warn("failed to send message", "event", event, "error", err)
+	}
+}
+
+// serviceEntryPayload converts a service entry to a message payload
+func serviceEntryPayload(entry *zeroconf.ServiceEntry) map[string]interface{} {
+	txt := make(map[string]interface{}, len(entry.Text))
+	for _, record := range entry.Text {
+		key, value, _ := strings.Cut(record, "=")
+		txt[key] = value
+	}
+
+	addresses := make([]string, 0, len(entry.AddrIPv4)+len(entry.AddrIPv6))
+	for _, ip := range entry.AddrIPv4 {
+		addresses = append(addresses, ip.String())
+	}
+	for _, ip := range entry.AddrIPv6 {
+		addresses = append(addresses, ip.String())
+	}
+
+	return map[string]interface{}{
+		"name":      entry.Instance,
+		"service":   entry.Service,
+		"domain":    entry.Domain,
+		"host":      entry.HostName,
+		"port":      entry.Port,
+		"txt":       txt,
+		"addresses": addresses,
+	}
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys(m map[string]map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,524 @@
+package input
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// Connection security of the email-in node
+const (
+	mailTLS      = "tls"
+	mailStartTLS = "starttls"
+)
+
+// mailTimeout bounds every exchange with a mail server
+const mailTimeout = 30 * time.Second
+
+// dialMail connects to a mail server, with TLS from the start in mode "tls"
+func dialMail(address string, mode string, config *tls.Config) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: mailTimeout}
+	if mode == mailTLS {
+		return tls.DialWithDialer(dialer, "tcp", address, config)
+	}
+	return dialer.Dial("tcp", address)
+}
+
+// EmailInputConfig represents the configuration of an email-in node
+type EmailInputConfig struct {
+	// Protocol is "imap" or "pop3"
+	Protocol string `json:"protocol" validate:"oneof=imap pop3"`
+	Host     string `json:"host" validate:"required"`
+	// Port defaults to the protocol's port for the TLS mode
+	Port int `json:"port" validate:"min=0,max=65535"`
+	// TLS is "tls", "starttls" or "none"
+	TLS                string `json:"tls" validate:"oneof=tls starttls none"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+	Username           string `json:"username" validate:"required"`
+	Password           string `json:"password"`
+	// Folder and Search, an IMAP search such as "UNSEEN FROM alice", only
+	// apply to IMAP
+	Folder   string `json:"folder"`
+	Search   string `json:"search"`
+	Interval string `json:"interval" validate:"duration"`
+	// After is what happens to a mail once sent: "none", "read" (IMAP
+	// only) or "delete"
+	After string `json:"after" validate:"oneof=none read delete"`
+	// MaxAttachmentSize is the largest attachment whose content is included
+	// in the message; larger ones only have their name and size
+	MaxAttachmentSize int `json:"maxAttachmentSize" validate:"min=0"`
+}
+
+// emailState is the persisted record of the mails already sent
+type emailState struct {
+	// UIDValidity and LastUID track IMAP mails; a new UIDVALIDITY means
+	// the server renumbered the folder
+	UIDValidity uint32 `json:"uidValidity,omitempty"`
+	LastUID     uint32 `json:"lastUid,omitempty"`
+	// Seen holds the unique IDs of the POP3 mails still on the server
+	Seen []string `json:"seen,omitempty"`
+}
+
+// EmailInputNode polls a mailbox and sends a message for every new mail,
+// with the subject as topic and the text body as payload
+type EmailInputNode struct {
+	engine.BaseNode
+	config EmailInputConfig
+
+	address   string
+	interval  time.Duration
+	tlsConfig *tls.Config
+	state     emailState
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// RegisterEmailInputNode registers the email-in node type
+func RegisterEmailInputNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "email-in",
+		Description: "Receives mails from an IMAP or POP3 mailbox",
+		Category:    "input",
+		Defaults:    json.RawMessage(`{"protocol":"imap","tls":"tls","folder":"INBOX","search":"UNSEEN","interval":"1m","after":"none","maxAttachmentSize":10485760}`),
+		Credentials: []string{"password"},
+		Factory: func() engine.NodeInstance {
+			return &EmailInputNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *EmailInputNode) Init(config json.RawMessage) error {
+	if err := n.LoadConfig(config, &n.config); err != nil {
+		return err
+	}
+	if n.config.Protocol == "pop3" && n.config.After == "read" {
+		return fmt.Errorf("POP3 cannot mark mails as read")
+	}
+
+	interval, err := time.ParseDuration(n.config.Interval)
+	if err != nil || interval <= 0 {
+		return fmt.Errorf("invalid interval: %s", n.config.Interval)
+	}
+	n.interval = interval
+
+	port := n.config.Port
+	if port == 0 {
+		ports := map[string][2]int{"imap": {993, 143}, "pop3": {995, 110}}[n.config.Protocol]
+		port = ports[1]
+		if n.config.TLS == mailTLS {
+			port = ports[0]
+		}
+	}
+	n.address = net.JoinHostPort(n.config.Host, strconv.Itoa(port))
+	n.tlsConfig = &tls.Config{ServerName: n.config.Host, InsecureSkipVerify: n.config.InsecureSkipVerify}
+	return nil
+}
+
+// Start starts polling, which stops with ctx or Stop
+func (n *EmailInputNode) Start(ctx context.Context) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	ctx, n.cancel = context.WithCancel(ctx)
+	n.wg.Add(1)
+	go n.run(ctx)
+	return nil
+}
+
+// Stop stops polling and waits for a poll in progress
+func (n *EmailInputNode) Stop() {
+	n.mu.Lock()
+	cancel := n.cancel
+	n.cancel = nil
+	n.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	n.wg.Wait()
+}
+
+// OnMessage processes a message
+func (n *EmailInputNode) OnMessage(msg *engine.Message, port int) error {
+	return nil // Input node, doesn't process messages
+}
+
+// run loads the state and polls right away, then on every interval until
+// ctx is cancelled
+func (n *EmailInputNode) run(ctx context.Context) {
+	defer n.wg.Done()
+
+	n.state = emailState{}
+	if _, err := n.GetNode().LoadState(&n.state); err != nil {
+		n.GetNode().Logger().Warn("failed to load state, old mails may be sent again", "error", err)
+	}
+
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+	for {
+		n.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll sends the new mails and reports the outcome as node status
+func (n *EmailInputNode) poll(ctx context.Context) {
+	var count int
+	var err error
+	if n.config.Protocol == "pop3" {
+		count, err = n.pollPOP3(ctx)
+	} else {
+		count, err = n.pollIMAP(ctx)
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		n.GetNode().Logger().Warn("failed to check mailbox", "address", n.address, "error", err)
+		n.GetNode().SetStatus(engine.NodeStatus{Fill: "red", Shape: "ring", Text: "error"})
+		return
+	}
+	n.GetNode().SetStatus(engine.NodeStatus{Fill: "green", Shape: "dot", Text: fmt.Sprintf("%d new", count)})
+}
+
+// closeOnDone closes c when ctx is cancelled, unless the returned function
+// was called first
+func closeOnDone(ctx context.Context, c io.Closer) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// pollIMAP sends the mails above the last UID sent that match the search
+func (n *EmailInputNode) pollIMAP(ctx context.Context) (int, error) {
+	client, err := dialIMAP(n.address, n.config.TLS, n.tlsConfig)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+	defer closeOnDone(ctx, client)()
+
+	if err := client.Login(n.config.Username, n.config.Password); err != nil {
+		return 0, err
+	}
+	validity, err := client.Select(n.config.Folder)
+	if err != nil {
+		return 0, err
+	}
+	if validity != n.state.UIDValidity {
+		n.state = emailState{UIDValidity: validity}
+	}
+	uids, err := client.Search(n.state.LastUID, n.config.Search)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, uid := range uids {
+		if ctx.Err() != nil {
+			break
+		}
+		raw, err := client.Fetch(uid)
+		if err != nil {
+			return count, err
+		}
+		if n.send(raw, uid) {
+			count++
+		}
+		n.state.LastUID = uid
+		n.saveState()
+
+		switch n.config.After {
+		case "read":
+			err = client.AddFlag(uid, `\Seen`)
+		case "delete":
+			err = client.AddFlag(uid, `\Deleted`)
+		}
+		if err != nil {
+			return count, err
+		}
+	}
+	if n.config.After == "delete" && len(uids) > 0 {
+		if err := client.Expunge(); err != nil {
+			return count, err
+		}
+	}
+	client.Logout()
+	return count, nil
+}
+
+// pollPOP3 sends the mails whose unique ID has not been seen
+func (n *EmailInputNode) pollPOP3(ctx context.Context) (int, error) {
+	client, err := dialPOP3(n.address, n.config.TLS, n.tlsConfig)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+	defer closeOnDone(ctx, client)()
+
+	if err := client.Login(n.config.Username, n.config.Password); err != nil {
+		return 0, err
+	}
+	uids, err := client.UIDL()
+	if err != nil {
+		return 0, err
+	}
+	numbers := make([]int, 0, len(uids))
+	for number := range uids {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+
+	seen := make(map[string]bool, len(n.state.Seen))
+	for _, uid := range n.state.Seen {
+		seen[uid] = true
+	}
+	// Forget the mails no longer on the server
+	var kept []string
+	for _, number := range numbers {
+		if seen[uids[number]] {
+			kept = append(kept, uids[number])
+		}
+	}
+	n.state.Seen = kept
+
+	count := 0
+	for _, number := range numbers {
+		uid := uids[number]
+		if seen[uid] {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		raw, err := client.Retrieve(number)
+		if err != nil {
+			return count, err
+		}
+		if n.send(raw, uid) {
+			count++
+		}
+		if n.config.After == "delete" {
+			if err := client.Delete(number); err != nil {
+				return count, err
+			}
+		} else {
+			n.state.Seen = append(n.state.Seen, uid)
+		}
+		n.saveState()
+	}
+	// Deletions only happen when the session ends properly
+	if err := client.Quit(); err != nil && n.config.After == "delete" {
+		return count, err
+	}
+	return count, nil
+}
+
+// saveState persists the mails sent so far
+func (n *EmailInputNode) saveState() {
+	if err := n.GetNode().SaveState(n.state); err != nil {
+		n.GetNode().Logger().Warn("failed to save state", "error", err)
+	}
+}
+
+// send parses a mail and sends it, reporting whether it could be parsed
+func (n *EmailInputNode) send(raw []byte, uid interface{}) bool {
+	msg, err := parseMail(raw, n.config.MaxAttachmentSize)
+	if err != nil {
+		n.GetNode().Logger().Warn("skipped mail", "uid", uid, "error", err)
+		return false
+	}
+	msg.SetMetadata("uid", uid)
+	if n.config.Protocol == "imap" {
+		msg.SetMetadata("folder", n.config.Folder)
+	}
+	if err := n.GetNode().Send(msg, 0); err != nil && !errors.Is(err, engine.ErrFlowThrottled) {
+		n.GetNode().Logger().Warn("failed to send message", "error", err)
+	}
+	return true
+}
+
+// mailDecoder decodes RFC 2047 encoded words in headers
+var mailDecoder = &mime.WordDecoder{}
+
+// decodeHeader decodes a header value, keeping it as is if it is malformed
+func decodeHeader(value string) string {
+	decoded, err := mailDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// mailContent collects the bodies and attachments of a mail
+type mailContent struct {
+	text        string
+	html        string
+	attachments []map[string]interface{}
+	maxSize     int
+}
+
+// mimeHeader is implemented by the headers of mails and of their parts
+type mimeHeader interface {
+	Get(key string) string
+}
+
+// parseMail turns a raw mail into a message with the subject as topic and
+// the text body, or the HTML one without it, as payload
+func parseMail(raw []byte, maxAttachmentSize int) (*engine.Message, error) {
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid mail: %w", err)
+	}
+
+	content := &mailContent{maxSize: maxAttachmentSize}
+	if err := content.walk(parsed.Header, parsed.Body); err != nil {
+		return nil, fmt.Errorf("invalid mail body: %w", err)
+	}
+
+	payload := content.text
+	if payload == "" {
+		payload = content.html
+	}
+	msg := engine.NewMessage(payload, decodeHeader(parsed.Header.Get("Subject")))
+
+	headers := make(map[string]string, len(parsed.Header))
+	for key, values := range parsed.Header {
+		decoded := make([]string, len(values))
+		for i, value := range values {
+			decoded[i] = decodeHeader(value)
+		}
+		headers[key] = strings.Join(decoded, ", ")
+	}
+	msg.SetMetadata("headers", headers)
+	msg.SetMetadata("from", mailAddresses(parsed.Header, "From"))
+	msg.SetMetadata("to", mailAddresses(parsed.Header, "To"))
+	if cc := mailAddresses(parsed.Header, "Cc"); len(cc) > 0 {
+		msg.SetMetadata("cc", cc)
+	}
+	if date, err := parsed.Header.Date(); err == nil {
+		msg.SetMetadata("date", date.Format(time.RFC3339))
+	}
+	if id := parsed.Header.Get("Message-Id"); id != "" {
+		msg.SetMetadata("messageId", strings.Trim(id, "<>"))
+	}
+	if content.html != "" {
+		msg.SetMetadata("html", content.html)
+	}
+	if len(content.attachments) > 0 {
+		msg.SetMetadata("attachments", content.attachments)
+	}
+	return msg, nil
+}
+
+// mailAddresses returns the addresses in a header, or its decoded value
+// if they cannot be parsed
+func mailAddresses(header mail.Header, key string) []string {
+	value := header.Get(key)
+	if value == "" {
+		return nil
+	}
+	list, err := header.AddressList(key)
+	if err != nil {
+		return []string{decodeHeader(value)}
+	}
+	addresses := make([]string, len(list))
+	for i, address := range list {
+		addresses[i] = address.Address
+	}
+	return addresses
+}
+
+// walk collects the first text and HTML bodies and the attachments of a
+// part, descending into multipart ones
+func (c *mailContent) walk(header mimeHeader, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := c.walk(part.Header, part); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := dispositionParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+	if disposition != "attachment" && filename == "" {
+		if mediaType == "text/plain" && c.text == "" {
+			c.text = string(data)
+			return nil
+		}
+		if mediaType == "text/html" && c.html == "" {
+			c.html = string(data)
+			return nil
+		}
+	}
+
+	attachment := map[string]interface{}{
+		"filename":    decodeHeader(filename),
+		"contentType": mediaType,
+		"size":        len(data),
+	}
+	if len(data) <= c.maxSize {
+		attachment["content"] = data
+	}
+	c.attachments = append(c.attachments, attachment)
+	return nil
+}
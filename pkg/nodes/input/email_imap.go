@@ -0,0 +1,213 @@
+package input
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// imapClient speaks the part of IMAP4rev1 the email-in node needs
+type imapClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	tag    int
+}
+
+// imapResponse is an untagged response line with its literals in order
+type imapResponse struct {
+	line     string
+	literals [][]byte
+}
+
+// dialIMAP connects and reads the greeting
+func dialIMAP(address string, mode string, config *tls.Config) (*imapClient, error) {
+	conn, err := dialMail(address, mode, config)
+	if err != nil {
+		return nil, err
+	}
+	c := &imapClient{conn: conn, reader: bufio.NewReader(conn)}
+	greeting, err := c.readResponse()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read greeting: %w", err)
+	}
+	if !strings.HasPrefix(greeting.line, "* OK") && !strings.HasPrefix(greeting.line, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected greeting: %s", greeting.line)
+	}
+
+	if mode == mailStartTLS {
+		if _, err := c.command("STARTTLS"); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("TLS handshake failed: %w", err)
+		}
+		c.conn = tlsConn
+		c.reader = bufio.NewReader(tlsConn)
+	}
+	return c, nil
+}
+
+// Close closes the connection
+func (c *imapClient) Close() error {
+	return c.conn.Close()
+}
+
+// command sends a command and returns the untagged responses, failing
+// unless the server completes it with OK
+func (c *imapClient) command(format string, args ...interface{}) ([]imapResponse, error) {
+	c.tag++
+	tag := fmt.Sprintf("A%d", c.tag)
+	c.conn.SetDeadline(time.Now().Add(mailTimeout))
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, fmt.Sprintf(format, args...)); err != nil {
+		return nil, err
+	}
+
+	var responses []imapResponse
+	for {
+		response, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(response.line, tag+" ") {
+			responses = append(responses, response)
+			continue
+		}
+		status := strings.TrimPrefix(response.line, tag+" ")
+		if !strings.HasPrefix(status, "OK") {
+			name, _, _ := strings.Cut(format, " ")
+			return nil, fmt.Errorf("%s failed: %s", name, status)
+		}
+		return responses, nil
+	}
+}
+
+// readResponse reads a response line, including the literals announced by
+// {size} at the end of its parts
+func (c *imapClient) readResponse() (imapResponse, error) {
+	var response imapResponse
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return response, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		response.line += line
+
+		// A literal follows lines ending in {size}
+		open := strings.LastIndexByte(line, '{')
+		if open < 0 || !strings.HasSuffix(line, "}") {
+			return response, nil
+		}
+		size, err := strconv.Atoi(line[open+1 : len(line)-1])
+		if err != nil {
+			return response, nil
+		}
+		literal := make([]byte, size)
+		if _, err := io.ReadFull(c.reader, literal); err != nil {
+			return response, err
+		}
+		response.literals = append(response.literals, literal)
+	}
+}
+
+// Login authenticates
+func (c *imapClient) Login(username, password string) error {
+	_, err := c.command("LOGIN %s %s", imapQuote(username), imapQuote(password))
+	return err
+}
+
+// Select opens a folder and returns its UIDVALIDITY
+func (c *imapClient) Select(folder string) (uint32, error) {
+	responses, err := c.command("SELECT %s", imapQuote(folder))
+	if err != nil {
+		return 0, err
+	}
+	for _, response := range responses {
+		if i := strings.Index(response.line, "[UIDVALIDITY "); i >= 0 {
+			value := response.line[i+len("[UIDVALIDITY "):]
+			value, _, _ = strings.Cut(value, "]")
+			validity, err := strconv.ParseUint(value, 10, 32)
+			if err == nil {
+				return uint32(validity), nil
+			}
+		}
+	}
+	return 0, nil
+}
+
+// Search returns the UIDs above after matching the search criteria, in
+// ascending order
+func (c *imapClient) Search(after uint32, criteria string) ([]uint32, error) {
+	query := fmt.Sprintf("UID SEARCH UID %d:*", after+1)
+	if criteria != "" {
+		query += " " + criteria
+	}
+	responses, err := c.command("%s", query)
+	if err != nil {
+		return nil, err
+	}
+
+	var uids []uint32
+	for _, response := range responses {
+		if !strings.HasPrefix(response.line, "* SEARCH") {
+			continue
+		}
+		for _, field := range strings.Fields(strings.TrimPrefix(response.line, "* SEARCH")) {
+			uid, err := strconv.ParseUint(field, 10, 32)
+			// n:* matches the last mail even if its UID is below n
+			if err == nil && uint32(uid) > after {
+				uids = append(uids, uint32(uid))
+			}
+		}
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+	return uids, nil
+}
+
+// Fetch returns the raw mail with a UID without marking it read
+func (c *imapClient) Fetch(uid uint32) ([]byte, error) {
+	responses, err := c.command("UID FETCH %d (UID BODY.PEEK[])", uid)
+	if err != nil {
+		return nil, err
+	}
+	for _, response := range responses {
+		if strings.Contains(response.line, "FETCH") && len(response.literals) > 0 {
+			return response.literals[0], nil
+		}
+	}
+	return nil, fmt.Errorf("mail %d not found", uid)
+}
+
+// AddFlag adds a flag such as \Seen to a mail
+func (c *imapClient) AddFlag(uid uint32, flag string) error {
+	_, err := c.command("UID STORE %d +FLAGS.SILENT (%s)", uid, flag)
+	return err
+}
+
+// Expunge removes the mails flagged as deleted
+func (c *imapClient) Expunge() error {
+	_, err := c.command("EXPUNGE")
+	return err
+}
+
+// Logout ends the session
+func (c *imapClient) Logout() error {
+	_, err := c.command("LOGOUT")
+	return err
+}
+
+// imapQuote quotes a string for a command
+func imapQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,146 @@
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// pop3Client speaks the part of POP3 the email-in node needs
+type pop3Client struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// dialPOP3 connects and reads the greeting
+func dialPOP3(address string, mode string, config *tls.Config) (*pop3Client, error) {
+	conn, err := dialMail(address, mode, config)
+	if err != nil {
+		return nil, err
+	}
+	c := &pop3Client{conn: conn, reader: bufio.NewReader(conn)}
+	if _, err := c.status(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected greeting: %w", err)
+	}
+
+	if mode == mailStartTLS {
+		if _, err := c.command("STLS"); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("TLS handshake failed: %w", err)
+		}
+		c.conn = tlsConn
+		c.reader = bufio.NewReader(tlsConn)
+	}
+	return c, nil
+}
+
+// Close closes the connection
+func (c *pop3Client) Close() error {
+	return c.conn.Close()
+}
+
+// status reads a status line, failing unless it is +OK
+func (c *pop3Client) status() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasPrefix(line, "+OK") {
+		return "", fmt.Errorf("%s", line)
+	}
+	return strings.TrimSpace(strings.TrimPrefix(line, "+OK")), nil
+}
+
+// command sends a command and reads its status line
+func (c *pop3Client) command(format string, args ...interface{}) (string, error) {
+	c.conn.SetDeadline(time.Now().Add(mailTimeout))
+	if _, err := fmt.Fprintf(c.conn, format+"\r\n", args...); err != nil {
+		return "", err
+	}
+	status, err := c.status()
+	if err != nil {
+		name, _, _ := strings.Cut(format, " ")
+		return "", fmt.Errorf("%s failed: %w", name, err)
+	}
+	return status, nil
+}
+
+// multiline reads the lines of a multi-line response up to the final "."
+func (c *pop3Client) multiline() ([]byte, error) {
+	var data bytes.Buffer
+	for {
+		line, err := c.reader.ReadBytes('\n')
+		if err != nil {
+			return nil, err
+		}
+		trimmed := bytes.TrimRight(line, "\r\n")
+		if bytes.Equal(trimmed, []byte(".")) {
+			return data.Bytes(), nil
+		}
+		// Lines starting with a dot have it doubled
+		if bytes.HasPrefix(line, []byte("..")) {
+			line = line[1:]
+		}
+		data.Write(line)
+	}
+}
+
+// Login authenticates
+func (c *pop3Client) Login(username, password string) error {
+	if _, err := c.command("USER %s", username); err != nil {
+		return err
+	}
+	_, err := c.command("PASS %s", password)
+	return err
+}
+
+// UIDL returns the unique IDs of the mails by message number
+func (c *pop3Client) UIDL() (map[int]string, error) {
+	if _, err := c.command("UIDL"); err != nil {
+		return nil, err
+	}
+	data, err := c.multiline()
+	if err != nil {
+		return nil, err
+	}
+	uids := make(map[int]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		var number int
+		var uid string
+		if _, err := fmt.Sscanf(strings.TrimSpace(line), "%d %s", &number, &uid); err == nil {
+			uids[number] = uid
+		}
+	}
+	return uids, nil
+}
+
+// Retrieve returns the raw mail with a message number
+func (c *pop3Client) Retrieve(number int) ([]byte, error) {
+	if _, err := c.command("RETR %d", number); err != nil {
+		return nil, err
+	}
+	return c.multiline()
+}
+
+// Delete marks a mail for deletion when the session ends with Quit
+func (c *pop3Client) Delete(number int) error {
+	_, err := c.command("DELE %d", number)
+	return err
+}
+
+// Quit ends the session, deleting the mails marked for deletion
+func (c *pop3Client) Quit() error {
+	_, err := c.command("QUIT")
+	return err
+}
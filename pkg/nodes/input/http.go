@@ -0,0 +1,371 @@
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// MetadataHTTPRequestID is the metadata key correlating a message with the
+// HTTP request it originated from
+const MetadataHTTPRequestID = engine.MetadataHTTPRequestID
+
+// defaultHTTPTimeout stays below the server write timeout
+const defaultHTTPTimeout = 10 * time.Second
+
+// HTTPInputConfig represents the configuration of an HTTP input node
+type HTTPInputConfig struct {
+	// Method is GET, POST, PUT, DELETE, PATCH or "any"
+	Method string `json:"method" validate:"required"`
+	// URL is the route path, which may contain parameters like /orders/{id}
+	URL string `json:"url" validate:"required"`
+	// Timeout is how long to wait for an http-response node before
+	// replying with 504 Gateway Timeout
+	Timeout string `json:"timeout" validate:"omitempty,duration"`
+	// MaxBodySize limits the request body in bytes
+	MaxBodySize int64 `json:"maxBodySize" validate:"min=1"`
+	// ParseBody decodes JSON, form and multipart bodies into the payload;
+	// other bodies are passed as raw []byte
+	ParseBody bool `json:"parseBody"`
+	// RejectInvalid answers 400 to bodies that fail to parse instead of
+	// passing the raw body into the flow
+	RejectInvalid bool `json:"rejectInvalid"`
+	// Signature enables webhook signature verification
+	Signature *SignatureConfig `json:"signature"`
+	// StreamBody passes the body as an engine.Stream instead of reading it
+	// into memory; the stream is readable until the response is sent
+	StreamBody bool `json:"streamBody"`
+}
+
+// HTTPInputNode receives HTTP requests and starts a flow for each of them.
+// The response is sent by an http-response node later in the flow.
+type HTTPInputNode struct {
+	engine.BaseNode
+	config   HTTPInputConfig
+	timeout  time.Duration
+	verifier *signatureVerifier
+	rejected int64
+	routes   *engine.HTTPRoutes
+	// path is the registered route, see Flow.HTTPPath
+	path string
+}
+
+// RegisterHTTPInputNode registers the HTTP input node type
+func RegisterHTTPInputNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "http-in",
+		Description: "Receives HTTP requests",
+		Category:    "input",
+		Defaults:    json.RawMessage(`{"method":"GET","url":"/","timeout":"10s","maxBodySize":1048576,"parseBody":true,"rejectInvalid":true}`),
+		Credentials: []string{"signature.secret"},
+		Factory: func() engine.NodeInstance {
+			return &HTTPInputNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *HTTPInputNode) Init(config json.RawMessage) error {
+	if err := n.LoadConfig(config, &n.config); err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(n.config.URL, "/") {
+		return fmt.Errorf("url must start with /")
+	}
+	n.config.Method = strings.ToUpper(n.config.Method)
+	switch n.config.Method {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch, engine.HTTPMethodAny:
+	default:
+		return fmt.Errorf("unsupported method: %s", n.config.Method)
+	}
+	n.timeout = defaultHTTPTimeout
+	if n.config.Timeout != "" {
+		timeout, err := time.ParseDuration(n.config.Timeout)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid timeout: %s", n.config.Timeout)
+		}
+		n.timeout = timeout
+	}
+
+	if n.config.Signature != nil {
+		if n.config.StreamBody {
+			return fmt.Errorf("signature verification needs the whole body and cannot be combined with streamBody")
+		}
+		verifier, err := newSignatureVerifier(*n.config.Signature)
+		if err != nil {
+			return err
+		}
+		n.verifier = verifier
+	}
+
+	return nil
+}
+
+// Start registers the node's route, below the prefix of its flow's
+// workspace
+func (n *HTTPInputNode) Start(ctx context.Context) error {
+	flow := n.GetNode().GetFlow()
+	n.routes = flow.GetEngine().HTTPRoutes()
+	n.path = flow.HTTPPath(n.config.URL)
+	return n.routes.Handle(n.config.Method, n.path, http.HandlerFunc(n.handleRequest))
+}
+
+// Stop removes the node's route
+func (n *HTTPInputNode) Stop() {
+	if n.routes != nil {
+		n.routes.Remove(n.config.Method, n.path)
+		n.routes = nil
+	}
+}
+
+// OnMessage processes a message
+func (n *HTTPInputNode) OnMessage(msg *engine.Message, port int) error {
+	return nil // Input node, doesn't process messages
+}
+
+// handleRequest turns a request into a message and waits for the response
+func (n *HTTPInputNode) handleRequest(w http.ResponseWriter, r *http.Request) {
+	var payload interface{}
+	if n.config.StreamBody {
+		stream := engine.NewStream(http.MaxBytesReader(w, r.Body, n.config.MaxBodySize), r.ContentLength, r.Header.Get("Content-Type"))
+		// The body cannot be read once the handler returned
+		defer stream.Close()
+		payload = stream
+	} else {
+		var ok bool
+		if payload, ok = n.readBody(w, r); !ok {
+			return
+		}
+	}
+
+	msg := engine.NewMessage(payload, r.URL.Path)
+	msg.SourceID = n.GetNode().ID
+	for key := range r.Header {
+		msg.SetHeader(key, r.Header.Get(key))
+	}
+
+	params := make(map[string]interface{})
+	for key, value := range engine.HTTPRouteParams(r) {
+		params[key] = value
+	}
+	msg.SetMetadata("method", r.Method)
+	msg.SetMetadata("path", r.URL.Path)
+	msg.SetMetadata("remoteAddr", r.RemoteAddr)
+	msg.SetMetadata("params", params)
+	msg.SetMetadata("query", valuesToMap(r.URL.Query()))
+	msg.SetMetadata(MetadataHTTPRequestID, msg.MsgID)
+
+	requests := n.GetNode().GetFlow().GetEngine().HTTPRequests()
+	responses := requests.Register(msg.MsgID)
+	defer func() {
+		requests.Remove(msg.MsgID)
+		// Close a streamed response that arrived after giving up
+		select {
+		case response := <-responses:
+			if response.Stream != nil {
+				response.Stream.Close()
+			}
+		default:
+		}
+	}()
+
+	timer := time.NewTimer(n.timeout)
+	defer timer.Stop()
+
+	if err := n.GetNode().Send(msg, 0); err != nil {
+		// A response sent before the flow failed elsewhere, such as in a
+		// second response node, still answers the request
+		select {
+		case response := <-responses:
+			writeHTTPResponse(w, response)
+		default:
+			http.Error(w, fmt.Sprintf("Flow error: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	select {
+	case response := <-responses:
+		writeHTTPResponse(w, response)
+	case <-timer.C:
+		http.Error(w, "No response from flow", http.StatusGatewayTimeout)
+	case <-r.Context().Done():
+	}
+}
+
+// readBody reads, verifies and parses the request body; on failure it
+// writes the error response and returns false
+func (n *HTTPInputNode) readBody(w http.ResponseWriter, r *http.Request) (interface{}, bool) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, n.config.MaxBodySize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return nil, false
+	}
+
+	// Verify against the raw body before it is parsed
+	if n.verifier != nil {
+		if err := n.verifier.Verify(r.Header, body); err != nil {
+			rejected := atomic.AddInt64(&n.rejected, 1)
+			n.GetNode().Logger().Warn("rejected request", "rejected", rejected, "error", err)
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return nil, false
+		}
+	}
+
+	var payload interface{} = body
+	if n.config.ParseBody && len(body) > 0 {
+		parsed, err := parseHTTPBody(r.Header.Get("Content-Type"), body)
+		if err != nil && n.config.RejectInvalid {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return nil, false
+		}
+		if err == nil {
+			payload = parsed
+		}
+	}
+	return payload, true
+}
+
+// Rejected returns the number of requests rejected by signature verification
+func (n *HTTPInputNode) Rejected() int64 {
+	return atomic.LoadInt64(&n.rejected)
+}
+
+// parseHTTPBody decodes a request body according to its content type:
+// JSON into a value, forms into a map, multipart into fields and files, and
+// anything else into the raw bytes
+func parseHTTPBody(contentType string, body []byte) (interface{}, error) {
+	if contentType == "" {
+		return body, nil
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("invalid content type: %w", err)
+	}
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var value interface{}
+		if err := engine.DecodeJSON(body, &value); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return value, nil
+
+	case mediaType == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, fmt.Errorf("invalid form: %w", err)
+		}
+		return valuesToMap(values), nil
+
+	case mediaType == "multipart/form-data":
+		return parseMultipart(body, params["boundary"])
+
+	default:
+		return body, nil
+	}
+}
+
+// parseMultipart decodes a multipart body into its form fields and files
+func parseMultipart(body []byte, boundary string) (interface{}, error) {
+	if boundary == "" {
+		return nil, fmt.Errorf("multipart body without boundary")
+	}
+
+	fields := make(map[string]interface{})
+	files := make([]interface{}, 0)
+
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid multipart body: %w", err)
+		}
+
+		data, err := ioutil.ReadAll(part)
+		part.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid multipart body: %w", err)
+		}
+
+		if part.FileName() == "" {
+			fields[part.FormName()] = string(data)
+			continue
+		}
+		files = append(files, map[string]interface{}{
+			"field":       part.FormName(),
+			"filename":    part.FileName(),
+			"contentType": part.Header.Get("Content-Type"),
+			"data":        data,
+		})
+	}
+
+	return map[string]interface{}{
+		"fields": fields,
+		"files":  files,
+	}, nil
+}
+
+// valuesToMap converts query or form values to a map holding a string for
+// single values and a list for repeated ones
+func valuesToMap(values url.Values) map[string]interface{} {
+	result := make(map[string]interface{}, len(values))
+	for key, list := range values {
+		if len(list) == 1 {
+			result[key] = list[0]
+			continue
+		}
+		items := make([]interface{}, len(list))
+		for i, value := range list {
+			items[i] = value
+		}
+		result[key] = items
+	}
+	return result
+}
+
+// writeHTTPResponse writes a flow response to the client
+func writeHTTPResponse(w http.ResponseWriter, response *engine.HTTPResponse) {
+	for key, value := range response.Headers {
+		w.Header().Set(key, value)
+	}
+
+	status := response.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if response.Stream == nil {
+		w.WriteHeader(status)
+		w.Write(response.Body)
+		return
+	}
+
+	// The stream may be the request body, which HTTP/1 servers stop reading
+	// once the response started unless full duplex is enabled, and which is
+	// closed outright if its "Expect: 100-continue" was not answered yet.
+	// Reading the first bytes before writing the header answers it.
+	defer response.Stream.Close()
+	http.NewResponseController(w).EnableFullDuplex()
+	body := bufio.NewReader(response.Stream)
+	body.Peek(1)
+	w.WriteHeader(status)
+	io.Copy(w, body)
+}
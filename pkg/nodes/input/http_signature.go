@@ -0,0 +1,209 @@
+package input
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webhook signature providers
+const (
+	SignatureProviderCustom = "custom"
+	SignatureProviderGitHub = "github"
+	SignatureProviderStripe = "stripe"
+	SignatureProviderSlack  = "slack"
+)
+
+// defaultReplayWindow is the default tolerance for timestamped signatures
+const defaultReplayWindow = 5 * time.Minute
+
+// SignatureConfig configures webhook signature verification on the HTTP
+// input node
+type SignatureConfig struct {
+	// Provider selects a preset: github, stripe, slack or custom
+	Provider string `json:"provider"`
+	// Algorithm is sha256 or sha1 (custom provider)
+	Algorithm string `json:"algorithm"`
+	// Secret is the shared signing secret
+	Secret string `json:"secret"`
+	// Header carries the signature (custom provider)
+	Header string `json:"header"`
+	// Encoding is hex or base64 (custom provider)
+	Encoding string `json:"encoding"`
+	// Prefix is stripped from the header value, e.g. "sha256="
+	Prefix string `json:"prefix"`
+	// ReplayWindow bounds the age of timestamped (stripe, slack) requests
+	ReplayWindow string `json:"replayWindow"`
+}
+
+// signatureVerifier verifies the signature of a webhook request
+type signatureVerifier struct {
+	config       SignatureConfig
+	newHash      func() hash.Hash
+	replayWindow time.Duration
+	now          func() time.Time
+}
+
+// newSignatureVerifier validates the configuration and applies the
+// provider presets
+func newSignatureVerifier(config SignatureConfig) (*signatureVerifier, error) {
+	if config.Secret == "" {
+		return nil, fmt.Errorf("signature secret is required")
+	}
+
+	switch config.Provider {
+	case "", SignatureProviderCustom:
+		config.Provider = SignatureProviderCustom
+		if config.Header == "" {
+			return nil, fmt.Errorf("signature header is required")
+		}
+	case SignatureProviderGitHub:
+		config.Algorithm = "sha256"
+		config.Header = "X-Hub-Signature-256"
+		config.Encoding = "hex"
+		config.Prefix = "sha256="
+	case SignatureProviderStripe:
+		config.Algorithm = "sha256"
+		config.Header = "Stripe-Signature"
+		config.Encoding = "hex"
+	case SignatureProviderSlack:
+		config.Algorithm = "sha256"
+		config.Header = "X-Slack-Signature"
+		config.Encoding = "hex"
+		config.Prefix = "v0="
+	default:
+		return nil, fmt.Errorf("unknown signature provider: %s", config.Provider)
+	}
+
+	verifier := &signatureVerifier{
+		config:       config,
+		replayWindow: defaultReplayWindow,
+		now:          time.Now,
+	}
+
+	switch strings.ToLower(config.Algorithm) {
+	case "", "sha256", "hmac-sha256":
+		verifier.newHash = sha256.New
+	case "sha1", "hmac-sha1":
+		verifier.newHash = sha1.New
+	default:
+		return nil, fmt.Errorf("unsupported signature algorithm: %s", config.Algorithm)
+	}
+
+	switch config.Encoding {
+	case "", "hex", "base64":
+	default:
+		return nil, fmt.Errorf("unsupported signature encoding: %s", config.Encoding)
+	}
+
+	if config.ReplayWindow != "" {
+		window, err := time.ParseDuration(config.ReplayWindow)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("invalid replay window: %s", config.ReplayWindow)
+		}
+		verifier.replayWindow = window
+	}
+
+	return verifier, nil
+}
+
+// Verify checks the signature of a request against its raw body
+func (v *signatureVerifier) Verify(header http.Header, body []byte) error {
+	value := header.Get(v.config.Header)
+	if value == "" {
+		return fmt.Errorf("missing %s header", v.config.Header)
+	}
+
+	switch v.config.Provider {
+	case SignatureProviderStripe:
+		return v.verifyStripe(value, body)
+	case SignatureProviderSlack:
+		timestamp := header.Get("X-Slack-Request-Timestamp")
+		if err := v.checkTimestamp(timestamp); err != nil {
+			return err
+		}
+		signed := append([]byte("v0:"+timestamp+":"), body...)
+		return v.compare(strings.TrimPrefix(value, v.config.Prefix), signed)
+	default:
+		if v.config.Prefix != "" && !strings.HasPrefix(value, v.config.Prefix) {
+			return fmt.Errorf("signature does not start with %q", v.config.Prefix)
+		}
+		return v.compare(strings.TrimPrefix(value, v.config.Prefix), body)
+	}
+}
+
+// verifyStripe verifies a "t=<timestamp>,v1=<signature>" header
+func (v *signatureVerifier) verifyStripe(value string, body []byte) error {
+	var timestamp string
+	var signatures []string
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "t":
+			timestamp = parts[1]
+		case "v1":
+			signatures = append(signatures, parts[1])
+		}
+	}
+
+	if err := v.checkTimestamp(timestamp); err != nil {
+		return err
+	}
+
+	signed := append([]byte(timestamp+"."), body...)
+	for _, signature := range signatures {
+		if v.compare(signature, signed) == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("signature mismatch")
+}
+
+// checkTimestamp rejects requests outside the replay window
+func (v *signatureVerifier) checkTimestamp(timestamp string) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid signature timestamp")
+	}
+
+	age := v.now().Sub(time.Unix(seconds, 0))
+	if age < 0 {
+		age = -age
+	}
+	if age > v.replayWindow {
+		return fmt.Errorf("signature timestamp outside the replay window")
+	}
+	return nil
+}
+
+// compare checks an encoded signature against the HMAC of the signed bytes
+func (v *signatureVerifier) compare(encoded string, signed []byte) error {
+	var signature []byte
+	var err error
+	if v.config.Encoding == "base64" {
+		signature, err = base64.StdEncoding.DecodeString(encoded)
+	} else {
+		signature, err = hex.DecodeString(encoded)
+	}
+	if err != nil {
+		return fmt.Errorf("malformed signature")
+	}
+
+	mac := hmac.New(v.newHash, []byte(v.config.Secret))
+	mac.Write(signed)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package input
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// InjectConfig represents the configuration of an inject node
+type InjectConfig struct {
+	// Payload is the JSON payload to send; without it the payload is the
+	// time of sending in Unix milliseconds
+	Payload json.RawMessage `json:"payload"`
+	Topic   string          `json:"topic"`
+	// Interval repeats the message, e.g. "5s"
+	Interval string `json:"interval" validate:"omitempty,duration"`
+	// Cron repeats the message on a cron expression with an optional
+	// seconds field, evaluated in Timezone
+	Cron     string `json:"cron"`
+	Timezone string `json:"timezone"`
+	// Once sends the message when the node starts
+	Once bool `json:"once"`
+}
+
+// InjectNode sends a message at start, on an interval or on a cron
+// schedule, and whenever it receives a message
+type InjectNode struct {
+	engine.BaseNode
+	config   InjectConfig
+	interval time.Duration
+	schedule cron.Schedule
+	location *time.Location
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// RegisterInjectNode registers the inject node type
+func RegisterInjectNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "inject",
+		Description: "Sends a message once, on an interval or on a cron schedule",
+		Category:    "input",
+		Defaults:    json.RawMessage(`{"timezone":"UTC"}`),
+		Factory: func() engine.NodeInstance {
+			return &InjectNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *InjectNode) Init(config json.RawMessage) error {
+	if err := n.LoadConfig(config, &n.config); err != nil {
+		return err
+	}
+
+	if len(n.config.Payload) > 0 && !json.Valid(n.config.Payload) {
+		return fmt.Errorf("invalid payload")
+	}
+	if n.config.Interval != "" && n.config.Cron != "" {
+		return fmt.Errorf("interval and cron cannot be combined")
+	}
+
+	n.interval, n.schedule = 0, nil
+	if n.config.Interval != "" {
+		interval, err := time.ParseDuration(n.config.Interval)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("invalid interval: %s", n.config.Interval)
+		}
+		n.interval = interval
+	}
+	if n.config.Cron != "" {
+		schedule, err := cronParser.Parse(n.config.Cron)
+		if err != nil {
+			return fmt.Errorf("invalid cron expression: %w", err)
+		}
+		n.schedule = schedule
+	}
+
+	timezone := n.config.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", timezone, err)
+	}
+	n.location = location
+	return nil
+}
+
+// Start sends the message if configured to and starts the interval or
+// schedule, which stop with ctx or Stop
+func (n *InjectNode) Start(ctx context.Context) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	ctx, n.cancel = context.WithCancel(ctx)
+	n.wg.Add(1)
+	go n.run(ctx)
+	return nil
+}
+
+// Stop stops the interval or schedule and waits for a send in progress
+func (n *InjectNode) Stop() {
+	n.mu.Lock()
+	cancel := n.cancel
+	n.cancel = nil
+	n.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	n.wg.Wait()
+}
+
+// OnMessage sends the configured message, e.g. when triggered from the
+// editor
+func (n *InjectNode) OnMessage(msg *engine.Message, port int) error {
+	return n.inject()
+}
+
+// run sends the message once if configured to, then on every tick of the
+// interval or schedule until ctx is cancelled
+func (n *InjectNode) run(ctx context.Context) {
+	defer n.wg.Done()
+
+	if n.config.Once {
+		n.send()
+	}
+	if n.interval == 0 && n.schedule == nil {
+		return
+	}
+
+	clock := n.GetNode().Clock()
+	next := clock.Now()
+	for {
+		now := clock.Now()
+		if n.schedule != nil {
+			next = n.schedule.Next(now.In(n.location))
+			if next.IsZero() {
+				return // the expression never fires again
+			}
+		} else {
+			// Ticks are aligned to the start, skipping those missed
+			for !next.After(now) {
+				next = next.Add(n.interval)
+			}
+		}
+
+		timer := clock.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+		n.send()
+	}
+}
+
+// send injects the message, logging failures other than throttling, which
+// the flow reports itself
+func (n *InjectNode) send() {
+	if err := n.inject(); err != nil && !errors.Is(err, engine.ErrFlowThrottled) {
+		n.GetNode().Logger().Warn("failed to send message", "error", err)
+	}
+}
+
+// inject sends a new message with the configured payload and topic
+func (n *InjectNode) inject() error {
+	node := n.GetNode()
+	now := node.Clock().Now()
+
+	var payload interface{} = now.UnixMilli()
+	if len(n.config.Payload) > 0 {
+		if err := engine.DecodeJSON(n.config.Payload, &payload); err != nil {
+			return fmt.Errorf("invalid payload: %w", err)
+		}
+	}
+
+	msg := engine.NewMessage(payload, n.config.Topic)
+	msg.SourceID = node.ID
+	msg.SetMetadata("injectedTime", now.Format(time.RFC3339Nano))
+	return node.Send(msg, 0)
+}
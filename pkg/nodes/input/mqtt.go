@@ -0,0 +1,383 @@
+package input
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// MQTT control actions, sent as the "action" field of a control payload
+const (
+	MQTTActionSubscribe   = "subscribe"
+	MQTTActionUnsubscribe = "unsubscribe"
+	MQTTActionConnect     = "connect"
+	MQTTActionDisconnect  = "disconnect"
+)
+
+// mqttOperationTimeout bounds connect, subscribe and unsubscribe calls
+const mqttOperationTimeout = 10 * time.Second
+
+// newMQTTClient creates the client of a connection; tests replace it
+var newMQTTClient = mqtt.NewClient
+
+// MQTTSubscription is a topic filter and its QoS
+type MQTTSubscription struct {
+	Topic string `json:"topic"`
+	QoS   byte   `json:"qos"`
+}
+
+// MQTTInputConfig represents the configuration of an mqtt-in node
+type MQTTInputConfig struct {
+	// Broker is the broker URL, e.g. "tcp://localhost:1883"
+	Broker   string `json:"broker"`
+	ClientID string `json:"clientId"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// Topics are the configured subscriptions. They are always subscribed
+	// and cannot be removed by control messages.
+	Topics []MQTTSubscription `json:"topics"`
+	// AutoConnect connects on Start; otherwise the node waits for a
+	// connect control message
+	AutoConnect bool `json:"autoConnect"`
+	// ParseJSON decodes JSON payloads; other payloads are passed as strings
+	ParseJSON bool `json:"parseJson"`
+}
+
+// MQTTInputNode subscribes to MQTT topics and emits a message for each
+// publication. Control messages on its input change the subscriptions at
+// runtime:
+//
+//	{"action": "subscribe", "topic": "sensors/+/temp", "qos": 1}
+//	{"action": "unsubscribe", "topic": "sensors/+/temp"}
+//	{"action": "connect", "broker": "tcp://other:1883"}
+//	{"action": "disconnect"}
+//
+// Dynamic subscriptions survive reconnects and broker changes; all
+// subscriptions are renewed whenever the connection is (re)established, so a
+// broker restart does not lose them. Subscribing to a configured topic is a
+// no-op that keeps the configured QoS, and unsubscribing from one is an error.
+type MQTTInputNode struct {
+	engine.BaseNode
+	config MQTTInputConfig
+
+	mu        sync.Mutex
+	client    mqtt.Client
+	broker    string
+	connected bool
+	dynamic   map[string]byte
+}
+
+// RegisterMQTTInputNode registers the mqtt-in node type
+func RegisterMQTTInputNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "mqtt-in",
+		Description: "Subscribes to MQTT topics",
+		Category:    "input",
+		Defaults:    json.RawMessage(`{"broker":"tcp://localhost:1883","topics":[],"autoConnect":true,"parseJson":true}`),
+		Credentials: []string{"password"},
+		Factory: func() engine.NodeInstance {
+			return &MQTTInputNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *MQTTInputNode) Init(config json.RawMessage) error {
+	n.config = MQTTInputConfig{
+		Broker:      "tcp://localhost:1883",
+		AutoConnect: true,
+		ParseJSON:   true,
+	}
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &n.config); err != nil {
+			return fmt.Errorf("invalid mqtt-in config: %w", err)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, sub := range n.config.Topics {
+		if sub.Topic == "" {
+			return fmt.Errorf("topic must not be empty")
+		}
+		if sub.QoS > 2 {
+			return fmt.Errorf("invalid qos %d for topic %s", sub.QoS, sub.Topic)
+		}
+		if seen[sub.Topic] {
+			return fmt.Errorf("duplicate topic: %s", sub.Topic)
+		}
+		seen[sub.Topic] = true
+	}
+
+	return nil
+}
+
+// Start connects to the broker if configured to
+func (n *MQTTInputNode) Start(ctx context.Context) error {
+	n.mu.Lock()
+	n.dynamic = make(map[string]byte)
+	n.broker = n.config.Broker
+	n.mu.Unlock()
+
+	if n.config.AutoConnect {
+		return n.connect("")
+	}
+	n.setStatus()
+	return nil
+}
+
+// Stop disconnects from the broker
+func (n *MQTTInputNode) Stop() {
+	n.disconnect()
+}
+
+// OnMessage handles control messages
+func (n *MQTTInputNode) OnMessage(msg *engine.Message, port int) error {
+	var control struct {
+		Action string `json:"action"`
+		Topic  string `json:"topic"`
+		QoS    byte   `json:"qos"`
+		Broker string `json:"broker"`
+	}
+	data, err := json.Marshal(msg.Payload)
+	if err == nil {
+		err = json.Unmarshal(data, &control)
+	}
+	if err != nil {
+		return fmt.Errorf("mqtt-in: invalid control message: %w", err)
+	}
+
+	switch control.Action {
+	case MQTTActionSubscribe:
+		return n.subscribe(control.Topic, control.QoS)
+	case MQTTActionUnsubscribe:
+		return n.unsubscribe(control.Topic)
+	case MQTTActionConnect:
+		n.disconnect()
+		return n.connect(control.Broker)
+	case MQTTActionDisconnect:
+		n.disconnect()
+		return nil
+	default:
+		return fmt.Errorf("mqtt-in: unknown action %q", control.Action)
+	}
+}
+
+// State reports the connection and the current subscriptions
+func (n *MQTTInputNode) State() map[string]interface{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	subscriptions := make([]map[string]interface{}, 0, len(n.config.Topics)+len(n.dynamic))
+	for _, sub := range n.config.Topics {
+		subscriptions = append(subscriptions, map[string]interface{}{"topic": sub.Topic, "qos": sub.QoS, "dynamic": false})
+	}
+	for _, sub := range n.dynamicLocked() {
+		subscriptions = append(subscriptions, map[string]interface{}{"topic": sub.Topic, "qos": sub.QoS, "dynamic": true})
+	}
+
+	return map[string]interface{}{
+		"broker":        n.broker,
+		"connected":     n.connected,
+		"subscriptions": subscriptions,
+	}
+}
+
+// connect connects to the broker, optionally overriding the configured one
+func (n *MQTTInputNode) connect(broker string) error {
+	n.mu.Lock()
+	if broker != "" {
+		n.broker = broker
+	}
+	options := mqtt.NewClientOptions().
+		AddBroker(n.broker).
+		SetClientID(n.config.ClientID).
+		SetUsername(n.config.Username).
+		SetPassword(n.config.Password).
+		SetCleanSession(true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOnConnectHandler(n.onConnect).
+		SetConnectionLostHandler(n.onConnectionLost)
+	client := newMQTTClient(options)
+	n.client = client
+	n.mu.Unlock()
+
+	// With connect retry enabled the client keeps trying in the background,
+	// so an unreachable broker does not fail the flow
+	token := client.Connect()
+	if token.WaitTimeout(mqttOperationTimeout) && token.Error() != nil {
+		return fmt.Errorf("mqtt-in: failed to connect to %s: %w", n.broker, token.Error())
+	}
+	n.setStatus()
+	return nil
+}
+
+// disconnect closes the connection, keeping the dynamic subscriptions
+func (n *MQTTInputNode) disconnect() {
+	n.mu.Lock()
+	client := n.client
+	n.client = nil
+	n.connected = false
+	n.mu.Unlock()
+
+	if client != nil {
+		client.Disconnect(250)
+	}
+	n.setStatus()
+}
+
+// subscribe adds a dynamic subscription
+func (n *MQTTInputNode) subscribe(topic string, qos byte) error {
+	if topic == "" {
+		return fmt.Errorf("mqtt-in: topic is required")
+	}
+	if qos > 2 {
+		return fmt.Errorf("mqtt-in: invalid qos %d", qos)
+	}
+	if n.isConfigured(topic) {
+		n.GetNode().Logger().Warn("ignoring subscribe to a configured subscription", "topic", topic)
+		return nil
+	}
+
+	n.mu.Lock()
+	n.dynamic[topic] = qos
+	client, connected := n.client, n.connected
+	n.mu.Unlock()
+
+	if connected {
+		if err := waitMQTT(client.Subscribe(topic, qos, n.onMessage)); err != nil {
+			return fmt.Errorf("mqtt-in: failed to subscribe to %s: %w", topic, err)
+		}
+	}
+	n.setStatus()
+	return nil
+}
+
+// unsubscribe removes a dynamic subscription
+func (n *MQTTInputNode) unsubscribe(topic string) error {
+	if n.isConfigured(topic) {
+		return fmt.Errorf("mqtt-in: %s is a configured subscription and cannot be removed", topic)
+	}
+
+	n.mu.Lock()
+	_, exists := n.dynamic[topic]
+	delete(n.dynamic, topic)
+	client, connected := n.client, n.connected
+	n.mu.Unlock()
+
+	if !exists {
+		return fmt.Errorf("mqtt-in: not subscribed to %s", topic)
+	}
+	if connected {
+		if err := waitMQTT(client.Unsubscribe(topic)); err != nil {
+			return fmt.Errorf("mqtt-in: failed to unsubscribe from %s: %w", topic, err)
+		}
+	}
+	n.setStatus()
+	return nil
+}
+
+// onConnect renews all subscriptions whenever the connection is established
+func (n *MQTTInputNode) onConnect(client mqtt.Client) {
+	n.mu.Lock()
+	if client != n.client {
+		n.mu.Unlock()
+		return // a replaced client
+	}
+	n.connected = true
+	subscriptions := append(append([]MQTTSubscription{}, n.config.Topics...), n.dynamicLocked()...)
+	n.mu.Unlock()
+
+	for _, sub := range subscriptions {
+		if err := waitMQTT(client.Subscribe(sub.Topic, sub.QoS, n.onMessage)); err != nil {
+			n.GetNode().Logger().Warn("failed to subscribe", "topic", sub.Topic, "error", err)
+		}
+	}
+	n.setStatus()
+}
+
+// onConnectionLost marks the node as disconnected while the client
+// reconnects
+func (n *MQTTInputNode) onConnectionLost(client mqtt.Client, err error) {
+	n.mu.Lock()
+	if client == n.client {
+		n.connected = false
+	}
+	n.mu.Unlock()
+
+	n.GetNode().Logger().Warn("lost connection", "error", err)
+	n.setStatus()
+}
+
+// onMessage emits a received publication
+func (n *MQTTInputNode) onMessage(client mqtt.Client, m mqtt.Message) {
+	var payload interface{} = string(m.Payload())
+	if n.config.ParseJSON {
+		var parsed interface{}
+		if err := engine.DecodeJSON(m.Payload(), &parsed); err == nil {
+			payload = parsed
+		}
+	}
+
+	msg := engine.NewMessage(payload, m.Topic())
+	msg.SourceID = n.GetNode().ID
+	msg.SetMetadata("qos", int(m.Qos()))
+	msg.SetMetadata("retain", m.Retained())
+	msg.SetMetadata("mqttMessageId", int(m.MessageID()))
+
+	if err := n.GetNode().Send(msg, 0); err != nil {
+		n.GetNode().Logger().Warn("failed to send message", "error", err)
+	}
+}
+
+// setStatus reports the connection and subscription count as node status
+func (n *MQTTInputNode) setStatus() {
+	n.mu.Lock()
+	connected := n.connected
+	count := len(n.config.Topics) + len(n.dynamic)
+	n.mu.Unlock()
+
+	status := engine.NodeStatus{Fill: "red", Shape: "ring", Text: "disconnected"}
+	if connected {
+		status = engine.NodeStatus{Fill: "green", Shape: "dot", Text: fmt.Sprintf("connected, %d subscriptions", count)}
+	}
+	n.GetNode().SetStatus(status)
+}
+
+// isConfigured reports whether topic is a configured subscription
+func (n *MQTTInputNode) isConfigured(topic string) bool {
+	for _, sub := range n.config.Topics {
+		if sub.Topic == topic {
+			return true
+		}
+	}
+	return false
+}
+
+// dynamicLocked returns the dynamic subscriptions sorted by topic; n.mu must
+// be held
+func (n *MQTTInputNode) dynamicLocked() []MQTTSubscription {
+	subscriptions := make([]MQTTSubscription, 0, len(n.dynamic))
+	for topic, qos := range n.dynamic {
+		subscriptions = append(subscriptions, MQTTSubscription{Topic: topic, QoS: qos})
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].Topic < subscriptions[j].Topic
+	})
+	return subscriptions
+}
+
+// waitMQTT waits for an MQTT operation to complete
+func waitMQTT(token mqtt.Token) error {
+	if !token.WaitTimeout(mqttOperationTimeout) {
+		return fmt.Errorf("timed out")
+	}
+	return token.Error()
+}
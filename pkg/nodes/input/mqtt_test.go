@@ -0,0 +1,220 @@
+package input
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// doneToken is a completed MQTT operation
+type doneToken struct{}
+
+func (doneToken) Wait() bool                     { return true }
+func (doneToken) WaitTimeout(time.Duration) bool { return true }
+func (doneToken) Error() error                   { return nil }
+
+func (doneToken) Done() <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+// fakeMQTTClient records the subscriptions a node makes instead of talking
+// to a broker. Connections are established and lost by calling the handlers
+// of its options.
+type fakeMQTTClient struct {
+	options *mqtt.ClientOptions
+	// subscribed are the topics subscribed since the last connect, by QoS
+	subscribed   map[string]byte
+	unsubscribed []string
+	disconnected bool
+}
+
+func (c *fakeMQTTClient) IsConnected() bool      { return !c.disconnected }
+func (c *fakeMQTTClient) IsConnectionOpen() bool { return !c.disconnected }
+func (c *fakeMQTTClient) Connect() mqtt.Token    { return doneToken{} }
+func (c *fakeMQTTClient) Disconnect(uint)        { c.disconnected = true }
+
+func (c *fakeMQTTClient) Publish(string, byte, bool, interface{}) mqtt.Token { return doneToken{} }
+
+func (c *fakeMQTTClient) Subscribe(topic string, qos byte, callback mqtt.MessageHandler) mqtt.Token {
+	c.subscribed[topic] = qos
+	return doneToken{}
+}
+
+func (c *fakeMQTTClient) SubscribeMultiple(filters map[string]byte, callback mqtt.MessageHandler) mqtt.Token {
+	for topic, qos := range filters {
+		c.subscribed[topic] = qos
+	}
+	return doneToken{}
+}
+
+func (c *fakeMQTTClient) Unsubscribe(topics ...string) mqtt.Token {
+	for _, topic := range topics {
+		delete(c.subscribed, topic)
+		c.unsubscribed = append(c.unsubscribed, topic)
+	}
+	return doneToken{}
+}
+
+func (c *fakeMQTTClient) AddRoute(string, mqtt.MessageHandler) {}
+
+func (c *fakeMQTTClient) OptionsReader() mqtt.ClientOptionsReader {
+	return mqtt.NewOptionsReader(c.options)
+}
+
+// connect establishes the connection, as the broker accepting it does
+func (c *fakeMQTTClient) connect() {
+	c.subscribed = make(map[string]byte)
+	c.options.OnConnect(c)
+}
+
+// lose drops the connection, as a broker restart does
+func (c *fakeMQTTClient) lose() {
+	c.options.OnConnectionLost(c, context.Canceled)
+}
+
+// startMQTTNode starts an mqtt-in node with a config, returning it and the
+// clients it creates
+func startMQTTNode(t *testing.T, config string) (*MQTTInputNode, *[]*fakeMQTTClient) {
+	t.Helper()
+	clients := &[]*fakeMQTTClient{}
+	newClient := newMQTTClient
+	newMQTTClient = func(options *mqtt.ClientOptions) mqtt.Client {
+		client := &fakeMQTTClient{options: options, subscribed: make(map[string]byte)}
+		*clients = append(*clients, client)
+		return client
+	}
+	t.Cleanup(func() { newMQTTClient = newClient })
+
+	var instance *MQTTInputNode
+	nodeType := &engine.NodeType{
+		Name: "mqtt-in",
+		Factory: func() engine.NodeInstance {
+			instance = &MQTTInputNode{}
+			return instance
+		},
+	}
+	if _, err := engine.NewNode("mqtt", "", nodeType, json.RawMessage(config), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := instance.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(instance.Stop)
+	return instance, clients
+}
+
+// control sends a control message to a node
+func control(n *MQTTInputNode, payload map[string]interface{}) error {
+	return n.OnMessage(engine.NewMessage(payload, ""), 0)
+}
+
+// subscriptions returns the subscriptions a node reports
+func subscriptions(n *MQTTInputNode) []map[string]interface{} {
+	return n.State()["subscriptions"].([]map[string]interface{})
+}
+
+func TestMQTTSubscriptionConflicts(t *testing.T) {
+	n, clients := startMQTTNode(t, `{"topics": [{"topic": "sensors/+/temp", "qos": 1}]}`)
+	client := (*clients)[0]
+	client.connect()
+
+	tests := []struct {
+		name    string
+		control map[string]interface{}
+		wantErr bool
+	}{
+		{"subscribe to a configured topic", map[string]interface{}{"action": "subscribe", "topic": "sensors/+/temp", "qos": 2}, false},
+		{"unsubscribe from a configured topic", map[string]interface{}{"action": "unsubscribe", "topic": "sensors/+/temp"}, true},
+		{"unsubscribe from an unknown topic", map[string]interface{}{"action": "unsubscribe", "topic": "alarms/#"}, true},
+		{"subscribe without a topic", map[string]interface{}{"action": "subscribe", "qos": 0}, true},
+		{"subscribe with an invalid qos", map[string]interface{}{"action": "subscribe", "topic": "alarms/#", "qos": 3}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := control(n, tt.control)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// The configured subscription keeps its QoS and is never removed
+	if got := client.subscribed; !reflect.DeepEqual(got, map[string]byte{"sensors/+/temp": 1}) {
+		t.Errorf("client subscriptions = %v", got)
+	}
+	if len(client.unsubscribed) != 0 {
+		t.Errorf("client unsubscribed from %v", client.unsubscribed)
+	}
+	want := []map[string]interface{}{{"topic": "sensors/+/temp", "qos": byte(1), "dynamic": false}}
+	if got := subscriptions(n); !reflect.DeepEqual(got, want) {
+		t.Errorf("state subscriptions = %v, want %v", got, want)
+	}
+}
+
+func TestMQTTResubscribeOnReconnect(t *testing.T) {
+	n, clients := startMQTTNode(t, `{"topics": [{"topic": "configured", "qos": 1}]}`)
+	client := (*clients)[0]
+	client.connect()
+
+	if err := control(n, map[string]interface{}{"action": "subscribe", "topic": "kept", "qos": 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := control(n, map[string]interface{}{"action": "subscribe", "topic": "removed", "qos": 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Subscriptions changed while the broker is down apply once it is back
+	client.lose()
+	if connected := n.State()["connected"]; connected != false {
+		t.Fatalf("connected = %v after the connection was lost", connected)
+	}
+	if err := control(n, map[string]interface{}{"action": "subscribe", "topic": "added", "qos": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := control(n, map[string]interface{}{"action": "unsubscribe", "topic": "removed"}); err != nil {
+		t.Fatal(err)
+	}
+	client.connect()
+
+	want := map[string]byte{"configured": 1, "kept": 2, "added": 1}
+	if !reflect.DeepEqual(client.subscribed, want) {
+		t.Errorf("resubscribed to %v, want %v", client.subscribed, want)
+	}
+	wantState := []map[string]interface{}{
+		{"topic": "configured", "qos": byte(1), "dynamic": false},
+		{"topic": "added", "qos": byte(1), "dynamic": true},
+		{"topic": "kept", "qos": byte(2), "dynamic": true},
+	}
+	if got := subscriptions(n); !reflect.DeepEqual(got, wantState) {
+		t.Errorf("state subscriptions = %v, want %v", got, wantState)
+	}
+
+	// A new broker gets every subscription, and the replaced client's
+	// handlers are ignored
+	if err := control(n, map[string]interface{}{"action": "connect", "broker": "tcp://other:1883"}); err != nil {
+		t.Fatal(err)
+	}
+	if !client.disconnected {
+		t.Error("old client was not disconnected")
+	}
+	replacement := (*clients)[1]
+	if brokers := replacement.options.Servers; len(brokers) != 1 || brokers[0].Host != "other:1883" {
+		t.Errorf("connected to %v, want other:1883", brokers)
+	}
+	client.connect()
+	if connected := n.State()["connected"]; connected != false {
+		t.Error("replaced client marked the node connected")
+	}
+	replacement.connect()
+	if !reflect.DeepEqual(replacement.subscribed, want) {
+		t.Errorf("subscribed on the new broker to %v, want %v", replacement.subscribed, want)
+	}
+}
@@ -0,0 +1,655 @@
+package input
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// redisTimeout bounds connecting and authenticating
+const redisTimeout = 10 * time.Second
+
+// RedisConnection holds the settings of a connection to a Redis server.
+// Nodes with the same settings share a connection.
+type RedisConnection struct {
+	Address string `json:"address" validate:"required"`
+	DB      int    `json:"db" validate:"min=0"`
+	// Username is only needed with Redis ACLs
+	Username           string `json:"username"`
+	Password           string `json:"password"`
+	TLS                bool   `json:"tls"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+}
+
+// RedisError is an error reply of the server
+type RedisError string
+
+func (e RedisError) Error() string {
+	return string(e)
+}
+
+// redisConn is a connection speaking RESP
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// dialRedis connects, authenticates and selects the database
+func dialRedis(settings RedisConnection) (*redisConn, error) {
+	dialer := &net.Dialer{Timeout: redisTimeout}
+	var conn net.Conn
+	var err error
+	if settings.TLS {
+		host, _, _ := net.SplitHostPort(settings.Address)
+		conn, err = tls.DialWithDialer(dialer, "tcp", settings.Address,
+			&tls.Config{ServerName: host, InsecureSkipVerify: settings.InsecureSkipVerify})
+	} else {
+		conn, err = dialer.Dial("tcp", settings.Address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &redisConn{conn: conn, reader: bufio.NewReader(conn)}
+	conn.SetDeadline(time.Now().Add(redisTimeout))
+	if settings.Password != "" {
+		args := []interface{}{"AUTH", settings.Password}
+		if settings.Username != "" {
+			args = []interface{}{"AUTH", settings.Username, settings.Password}
+		}
+		if _, err := c.do(args...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+	if settings.DB != 0 {
+		if _, err := c.do("SELECT", settings.DB); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to select database %d: %w", settings.DB, err)
+		}
+	}
+	conn.SetDeadline(time.Time{})
+	return c, nil
+}
+
+// Close closes the connection
+func (c *redisConn) Close() error {
+	return c.conn.Close()
+}
+
+// do sends a command and reads its reply, returning error replies as
+// RedisError
+func (c *redisConn) do(args ...interface{}) (interface{}, error) {
+	if err := c.write(args...); err != nil {
+		return nil, err
+	}
+	reply, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+	if replyErr, ok := reply.(RedisError); ok {
+		return nil, replyErr
+	}
+	return reply, nil
+}
+
+// write sends a command as an array of bulk strings
+func (c *redisConn) write(args ...interface{}) error {
+	buf := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		value := redisArg(arg)
+		buf = append(buf, "$"+strconv.Itoa(len(value))+"\r\n"...)
+		buf = append(buf, value...)
+		buf = append(buf, "\r\n"...)
+	}
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+// redisArg converts a value to the text of a command argument. Objects and
+// arrays become JSON.
+func redisArg(arg interface{}) []byte {
+	switch v := arg.(type) {
+	case string:
+		return []byte(v)
+	case []byte:
+		return v
+	case nil:
+		return nil
+	case json.Number:
+		return []byte(v.String())
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, bool:
+		return []byte(fmt.Sprintf("%v", v))
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return []byte(fmt.Sprintf("%v", v))
+		}
+		return data
+	}
+}
+
+// read reads a reply: a string, an int64, nil, a RedisError or an array of
+// replies
+func (c *redisConn) read() (interface{}, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("malformed reply: %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return RedisError(body), nil
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		size, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("malformed reply: %q", line)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("malformed reply: %q", line)
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("malformed reply: %q", line)
+	}
+}
+
+// redisClients holds the command clients in use
+var redisClients = struct {
+	sync.Mutex
+	clients map[RedisConnection]*RedisClient
+}{clients: make(map[RedisConnection]*RedisClient)}
+
+// RedisClient runs commands over a connection shared by the nodes with the
+// same settings. Commands run one at a time; a connection that fails is
+// replaced on the next command.
+type RedisClient struct {
+	settings RedisConnection
+	refs     int
+
+	mu   sync.Mutex
+	conn *redisConn
+}
+
+// AcquireRedisClient returns the client of the settings. Users release it
+// when done.
+func AcquireRedisClient(settings RedisConnection) *RedisClient {
+	redisClients.Lock()
+	defer redisClients.Unlock()
+
+	c := redisClients.clients[settings]
+	if c == nil {
+		c = &RedisClient{settings: settings}
+		redisClients.clients[settings] = c
+	}
+	c.refs++
+	return c
+}
+
+// Release gives up a use of the client; the last one closes the connection
+func (c *RedisClient) Release() {
+	redisClients.Lock()
+	c.refs--
+	last := c.refs == 0
+	if last {
+		delete(redisClients.clients, c.settings)
+	}
+	redisClients.Unlock()
+	if !last {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// Do runs a command until ctx is done, connecting first if needed. Error
+// replies are returned as RedisError.
+func (c *RedisClient) Do(ctx context.Context, args ...interface{}) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		conn, err := dialRedis(c.settings)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %s: %w", c.settings.Address, err)
+		}
+		c.conn = conn
+	}
+
+	// Without a deadline, the zero time clears the previous one
+	deadline, _ := ctx.Deadline()
+	c.conn.conn.SetDeadline(deadline)
+	stop := closeOnDone(ctx, c.conn)
+	reply, err := c.conn.do(args...)
+	stop()
+
+	var replyErr RedisError
+	if err != nil && !errors.As(err, &replyErr) {
+		// The connection is in an unknown state
+		c.conn.Close()
+		c.conn = nil
+	}
+	return reply, err
+}
+
+// redisMinDelay and redisMaxDelay bound the delay before a subscriber
+// reconnects
+const (
+	redisMinDelay = time.Second
+	redisMaxDelay = time.Minute
+)
+
+// redisSubscribers holds the subscribers in use
+var redisSubscribers = struct {
+	sync.Mutex
+	subscribers map[RedisConnection]*redisSubscriber
+}{subscribers: make(map[RedisConnection]*redisSubscriber)}
+
+// redisReceiver is a node receiving the messages of channels or patterns
+type redisReceiver struct {
+	channels  []string
+	pattern   bool
+	onMessage func(channel, pattern, payload string)
+	onStatus  func(connected bool)
+	logger    *slog.Logger
+}
+
+// redisSubscriber holds a connection in subscribe mode shared by the
+// redis-in nodes with the same settings, and subscribes to the union of
+// their channels and patterns
+type redisSubscriber struct {
+	settings RedisConnection
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	mu        sync.Mutex
+	receivers map[string]*redisReceiver
+	conn      *redisConn
+}
+
+// subscribeRedis registers a receiver with the subscriber of the settings,
+// starting it for the first one
+func subscribeRedis(settings RedisConnection, id string, receiver *redisReceiver) {
+	redisSubscribers.Lock()
+	defer redisSubscribers.Unlock()
+
+	s := redisSubscribers.subscribers[settings]
+	if s == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		s = &redisSubscriber{
+			settings:  settings,
+			cancel:    cancel,
+			done:      make(chan struct{}),
+			receivers: make(map[string]*redisReceiver),
+		}
+		redisSubscribers.subscribers[settings] = s
+		go s.run(ctx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.receivers[id] = receiver
+	if s.conn != nil {
+		if err := s.conn.write(subscribeArgs(receiver.pattern, receiver.channels)...); err != nil {
+			s.conn.Close()
+		}
+		receiver.onStatus(true)
+	}
+}
+
+// unsubscribeRedis removes a receiver, unsubscribing from the channels
+// no other receiver needs and stopping the subscriber after the last one
+func unsubscribeRedis(settings RedisConnection, id string) {
+	redisSubscribers.Lock()
+	s := redisSubscribers.subscribers[settings]
+	if s == nil {
+		redisSubscribers.Unlock()
+		return
+	}
+	s.mu.Lock()
+	receiver := s.receivers[id]
+	delete(s.receivers, id)
+	last := len(s.receivers) == 0
+	if last {
+		delete(redisSubscribers.subscribers, settings)
+	} else if receiver != nil && s.conn != nil {
+		channels, patterns := s.subscriptions()
+		needed := channels
+		if receiver.pattern {
+			needed = patterns
+		}
+		var unused []string
+		for _, channel := range receiver.channels {
+			if !needed[channel] {
+				unused = append(unused, channel)
+			}
+		}
+		if len(unused) > 0 {
+			command := "UNSUBSCRIBE"
+			if receiver.pattern {
+				command = "PUNSUBSCRIBE"
+			}
+			if err := s.conn.write(append([]interface{}{command}, stringArgs(unused)...)...); err != nil {
+				s.conn.Close()
+			}
+		}
+	}
+	s.mu.Unlock()
+	redisSubscribers.Unlock()
+
+	if last {
+		s.cancel()
+		<-s.done
+	}
+}
+
+// subscriptions returns the channels and patterns of all receivers
+func (s *redisSubscriber) subscriptions() (map[string]bool, map[string]bool) {
+	channels := make(map[string]bool)
+	patterns := make(map[string]bool)
+	for _, receiver := range s.receivers {
+		for _, channel := range receiver.channels {
+			if receiver.pattern {
+				patterns[channel] = true
+			} else {
+				channels[channel] = true
+			}
+		}
+	}
+	return channels, patterns
+}
+
+// subscribeArgs returns the command subscribing to channels or patterns
+func subscribeArgs(pattern bool, channels []string) []interface{} {
+	command := "SUBSCRIBE"
+	if pattern {
+		command = "PSUBSCRIBE"
+	}
+	return append([]interface{}{command}, stringArgs(channels)...)
+}
+
+// stringArgs converts strings to command arguments
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		args[i] = value
+	}
+	return args
+}
+
+// run keeps the subscriber connected, reconnecting with backoff, until
+// ctx is cancelled
+func (s *redisSubscriber) run(ctx context.Context) {
+	defer close(s.done)
+
+	backoff := engine.NewBackoff(redisMinDelay, redisMaxDelay)
+	for {
+		backoff.Start()
+		err := s.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		s.setStatus(false)
+		delay := backoff.Next()
+		s.warn("redis subscription lost, reconnecting", "address", s.settings.Address, "error", err, "delay", delay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// session connects, subscribes and dispatches messages until the
+// connection fails or ctx is cancelled
+func (s *redisSubscriber) session(ctx context.Context) error {
+	conn, err := dialRedis(s.settings)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer closeOnDone(ctx, conn)()
+
+	s.mu.Lock()
+	channels, patterns := s.subscriptions()
+	for _, subscription := range []struct {
+		pattern bool
+		names   map[string]bool
+	}{{false, channels}, {true, patterns}} {
+		if len(subscription.names) == 0 {
+			continue
+		}
+		names := make([]string, 0, len(subscription.names))
+		for name := range subscription.names {
+			names = append(names, name)
+		}
+		if err := conn.write(subscribeArgs(subscription.pattern, names)...); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+	}
+	s.conn = conn
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.conn = nil
+		s.mu.Unlock()
+	}()
+	s.setStatus(true)
+
+	for {
+		reply, err := conn.read()
+		if err != nil {
+			return err
+		}
+		if replyErr, ok := reply.(RedisError); ok {
+			return replyErr
+		}
+		items, _ := reply.([]interface{})
+		if len(items) < 3 {
+			continue
+		}
+		kind, _ := items[0].(string)
+		switch {
+		case kind == "message":
+			channel, _ := items[1].(string)
+			payload, _ := items[2].(string)
+			s.dispatch(channel, "", payload)
+		case kind == "pmessage" && len(items) == 4:
+			pattern, _ := items[1].(string)
+			channel, _ := items[2].(string)
+			payload, _ := items[3].(string)
+			s.dispatch(channel, pattern, payload)
+		}
+	}
+}
+
+// dispatch hands a message to the receivers of its channel or pattern
+func (s *redisSubscriber) dispatch(channel, pattern, payload string) {
+	name := channel
+	if pattern != "" {
+		name = pattern
+	}
+
+	s.mu.Lock()
+	var targets []*redisReceiver
+	for _, receiver := range s.receivers {
+		if receiver.pattern != (pattern != "") {
+			continue
+		}
+		for _, c := range receiver.channels {
+			if c == name {
+				targets = append(targets, receiver)
+				break
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	for _, receiver := range targets {
+		receiver.onMessage(channel, pattern, payload)
+	}
+}
+
+// setStatus reports the connection state to every receiver
+func (s *redisSubscriber) setStatus(connected bool) {
+	s.mu.Lock()
+	receivers := make([]*redisReceiver, 0, len(s.receivers))
+	for _, receiver := range s.receivers {
+		receivers = append(receivers, receiver)
+	}
+	s.mu.Unlock()
+
+	for _, receiver := range receivers {
+		receiver.onStatus(connected)
+	}
+}
+
+// warn logs a problem of the shared connection to every receiver's node
+func (s *redisSubscriber) warn(message string, args ...interface{}) {
+	s.mu.Lock()
+	receivers := make([]*redisReceiver, 0, len(s.receivers))
+	for _, receiver := range s.receivers {
+		receivers = append(receivers, receiver)
+	}
+	s.mu.Unlock()
+
+	for _, receiver := range receivers {
+		receiver.logger.Warn(message, args...)
+	}
+}
+
+// RedisInputConfig represents the configuration of a redis-in node
+type RedisInputConfig struct {
+	RedisConnection
+	Channels []string `json:"channels" validate:"required"`
+	// Pattern subscribes to the channels as glob patterns, e.g. "sensors.*"
+	Pattern bool `json:"pattern"`
+	// JSON parses payloads holding JSON; others stay strings
+	JSON bool `json:"json"`
+}
+
+// RedisInputNode sends a message for every message published to its
+// channels, with the channel as topic
+type RedisInputNode struct {
+	engine.BaseNode
+	config RedisInputConfig
+}
+
+// RegisterRedisInputNode registers the redis-in node type
+func RegisterRedisInputNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "redis-in",
+		Description: "Subscribes to Redis pub/sub channels",
+		Category:    "input",
+		Defaults:    json.RawMessage(`{"address":"localhost:6379"}`),
+		Credentials: []string{"password"},
+		Factory: func() engine.NodeInstance {
+			return &RedisInputNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *RedisInputNode) Init(config json.RawMessage) error {
+	if err := n.LoadConfig(config, &n.config); err != nil {
+		return err
+	}
+	if len(n.config.Channels) == 0 {
+		return fmt.Errorf("no channels to subscribe to")
+	}
+	return nil
+}
+
+// Start subscribes to the channels
+func (n *RedisInputNode) Start(ctx context.Context) error {
+	node := n.GetNode()
+	subscribeRedis(n.config.RedisConnection, node.ID, &redisReceiver{
+		channels:  n.config.Channels,
+		pattern:   n.config.Pattern,
+		onMessage: n.receive,
+		logger:    node.Logger(),
+		onStatus: func(connected bool) {
+			status := engine.NodeStatus{Fill: "red", Shape: "ring", Text: "disconnected"}
+			if connected {
+				status = engine.NodeStatus{Fill: "green", Shape: "dot", Text: "connected"}
+			}
+			// Start holds the node, so report asynchronously
+			go node.SetStatus(status)
+		},
+	})
+	return nil
+}
+
+// Stop unsubscribes from the channels
+func (n *RedisInputNode) Stop() {
+	unsubscribeRedis(n.config.RedisConnection, n.GetNode().ID)
+}
+
+// OnMessage processes a message
+func (n *RedisInputNode) OnMessage(msg *engine.Message, port int) error {
+	return nil // Input node, doesn't process messages
+}
+
+// receive sends a published message
+func (n *RedisInputNode) receive(channel, pattern, payload string) {
+	var value interface{} = payload
+	if n.config.JSON {
+		var parsed interface{}
+		if err := engine.DecodeJSON([]byte(payload), &parsed); err == nil {
+			value = parsed
+		}
+	}
+	msg := engine.NewMessage(value, channel)
+	if pattern != "" {
+		msg.SetMetadata("pattern", pattern)
+	}
+	if err := n.GetNode().Send(msg, 0); err != nil && !errors.Is(err, engine.ErrFlowThrottled) {
+		n.GetNode().Logger().Warn("failed to send message", "error", err)
+	}
+}
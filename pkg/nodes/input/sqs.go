@@ -0,0 +1,326 @@
+package input
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// SQS delete modes
+const (
+	// SQSDeleteImmediate deletes messages as soon as they are received
+	SQSDeleteImmediate = "immediate"
+	// SQSDeleteOnComplete deletes messages once the flow handled them
+	// without error
+	SQSDeleteOnComplete = "complete"
+)
+
+// SQSInputConfig represents the configuration of an sqs-in node
+type SQSInputConfig struct {
+	QueueURL string `json:"queueUrl"`
+	Region   string `json:"region"`
+	// Endpoint overrides the service endpoint, e.g. for ElasticMQ or
+	// LocalStack
+	Endpoint string `json:"endpoint"`
+	// AccessKey and SecretKey are optional; without them the standard
+	// provider chain is used
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey"`
+	// BatchSize is the number of messages per receive call (1-10)
+	BatchSize int `json:"batchSize"`
+	// WaitTime is the long polling wait time (at most 20s)
+	WaitTime string `json:"waitTime"`
+	// VisibilityTimeout is requested on receive and extended while a
+	// message is still being handled
+	VisibilityTimeout string `json:"visibilityTimeout"`
+	// DeleteMode is "complete" or "immediate"
+	DeleteMode string `json:"deleteMode"`
+	// MaxReceives leaves a failing message invisible once it has been
+	// received this many times, so the queue's redrive policy can move it
+	// to the dead letter queue; 0 disables the check
+	MaxReceives int `json:"maxReceives"`
+	// ParseJSON decodes JSON bodies into the payload
+	ParseJSON bool `json:"parseJson"`
+	// NetworkProfile selects the proxy and TLS settings; empty for the
+	// default profile
+	NetworkProfile string `json:"networkProfile"`
+}
+
+// SQSInputNode long-polls an SQS compatible queue and emits a message for
+// each received item
+type SQSInputNode struct {
+	engine.BaseNode
+	config            SQSInputConfig
+	waitTime          time.Duration
+	visibilityTimeout time.Duration
+	client            *sqs.Client
+	cancel            context.CancelFunc
+	wg                sync.WaitGroup
+}
+
+// RegisterSQSInputNode registers the sqs-in node type
+func RegisterSQSInputNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "sqs-in",
+		Description: "Receives messages from an SQS compatible queue",
+		Category:    "input",
+		Defaults:    json.RawMessage(`{"batchSize":10,"waitTime":"20s","visibilityTimeout":"30s","deleteMode":"complete","maxReceives":5,"parseJson":true}`),
+		Credentials: []string{"secretKey"},
+		Factory: func() engine.NodeInstance {
+			return &SQSInputNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *SQSInputNode) Init(config json.RawMessage) error {
+	n.config = SQSInputConfig{
+		BatchSize:         10,
+		WaitTime:          "20s",
+		VisibilityTimeout: "30s",
+		DeleteMode:        SQSDeleteOnComplete,
+		ParseJSON:         true,
+	}
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &n.config); err != nil {
+			return fmt.Errorf("invalid sqs-in config: %w", err)
+		}
+	}
+
+	if n.config.QueueURL == "" {
+		return fmt.Errorf("queueUrl is required")
+	}
+	if n.config.BatchSize < 1 || n.config.BatchSize > 10 {
+		return fmt.Errorf("batchSize must be between 1 and 10")
+	}
+	switch n.config.DeleteMode {
+	case SQSDeleteOnComplete, SQSDeleteImmediate:
+	default:
+		return fmt.Errorf("invalid deleteMode: %s", n.config.DeleteMode)
+	}
+
+	waitTime, err := time.ParseDuration(n.config.WaitTime)
+	if err != nil || waitTime < 0 || waitTime > 20*time.Second {
+		return fmt.Errorf("invalid waitTime: %s", n.config.WaitTime)
+	}
+	n.waitTime = waitTime
+
+	visibilityTimeout, err := time.ParseDuration(n.config.VisibilityTimeout)
+	if err != nil || visibilityTimeout < time.Second {
+		return fmt.Errorf("invalid visibilityTimeout: %s", n.config.VisibilityTimeout)
+	}
+	n.visibilityTimeout = visibilityTimeout
+
+	return nil
+}
+
+// Start creates the client and starts polling
+func (n *SQSInputNode) Start(ctx context.Context) error {
+	httpClient, err := n.GetNode().GetFlow().GetEngine().HTTPClient(n.config.NetworkProfile)
+	if err != nil {
+		return err
+	}
+	options := []func(*awsconfig.LoadOptions) error{awsconfig.WithHTTPClient(httpClient)}
+	if n.config.Region != "" {
+		options = append(options, awsconfig.WithRegion(n.config.Region))
+	}
+	if n.config.AccessKey != "" {
+		options = append(options, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(n.config.AccessKey, n.config.SecretKey, "")))
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return fmt.Errorf("failed to load aws config: %w", err)
+	}
+	n.client = sqs.NewFromConfig(cfg, func(o *sqs.Options) {
+		if n.config.Endpoint != "" {
+			o.BaseEndpoint = aws.String(n.config.Endpoint)
+		}
+	})
+
+	ctx, n.cancel = context.WithCancel(ctx)
+	n.wg.Add(1)
+	go n.poll(ctx)
+
+	return nil
+}
+
+// Stop stops polling and waits for in-flight messages
+func (n *SQSInputNode) Stop() {
+	if n.cancel != nil {
+		n.cancel()
+	}
+	n.wg.Wait()
+}
+
+// OnMessage processes a message
+func (n *SQSInputNode) OnMessage(msg *engine.Message, port int) error {
+	return nil // Input node, doesn't process messages
+}
+
+// poll receives batches until the context is cancelled
+func (n *SQSInputNode) poll(ctx context.Context) {
+	defer n.wg.Done()
+
+	for ctx.Err() == nil {
+		output, err := n.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:                    aws.String(n.config.QueueURL),
+			MaxNumberOfMessages:         int32(n.config.BatchSize),
+			WaitTimeSeconds:             int32(n.waitTime / time.Second),
+			VisibilityTimeout:           int32(n.visibilityTimeout / time.Second),
+			MessageAttributeNames:       []string{"All"},
+			MessageSystemAttributeNames: []types.MessageSystemAttributeName{types.MessageSystemAttributeNameAll},
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			n.GetNode().Logger().Warn("failed to receive", "error", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+
+		for _, item := range output.Messages {
+			n.handle(ctx, item)
+		}
+	}
+}
+
+// handle emits a received item and deletes or releases it afterwards
+func (n *SQSInputNode) handle(ctx context.Context, item types.Message) {
+	if n.config.DeleteMode == SQSDeleteImmediate {
+		n.delete(ctx, item)
+		if err := n.GetNode().Send(n.toMessage(item), 0); err != nil {
+			n.GetNode().Logger().Warn("failed to send message", "error", err)
+		}
+		return
+	}
+
+	// Keep the item invisible while the flow is still working on it. In
+	// at-least-once flows that includes messages held by buffering nodes,
+	// so the outcome may arrive after handle returned.
+	done := make(chan struct{})
+	n.wg.Add(1)
+	go n.extendVisibility(ctx, item, done)
+
+	n.GetNode().SendWithAck(n.toMessage(item), 0, func(err error) {
+		close(done)
+		n.complete(ctx, item, err)
+	})
+}
+
+// complete deletes a handled item or releases a failed one
+func (n *SQSInputNode) complete(ctx context.Context, item types.Message, err error) {
+	if ctx.Err() != nil {
+		return // stopped; the item becomes visible again by itself
+	}
+	if err == nil {
+		n.delete(ctx, item)
+		return
+	}
+
+	receives, _ := strconv.Atoi(item.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)])
+	if n.config.MaxReceives > 0 && receives >= n.config.MaxReceives {
+		// Leave the item invisible so the redrive policy picks it up
+		n.GetNode().Logger().Warn("message failed too often, leaving it for redrive",
+			"message_id", aws.ToString(item.MessageId), "receives", receives, "error", err)
+		return
+	}
+
+	n.GetNode().Logger().Warn("message failed, releasing it",
+		"message_id", aws.ToString(item.MessageId), "error", err)
+	n.changeVisibility(ctx, item, 0)
+}
+
+// toMessage converts a queue item to a message
+func (n *SQSInputNode) toMessage(item types.Message) *engine.Message {
+	body := aws.ToString(item.Body)
+	var payload interface{} = body
+	if n.config.ParseJSON {
+		var parsed interface{}
+		if err := engine.DecodeJSON([]byte(body), &parsed); err == nil {
+			payload = parsed
+		}
+	}
+
+	msg := engine.NewMessage(payload, n.config.QueueURL)
+	msg.SourceID = n.GetNode().ID
+
+	attributes := make(map[string]interface{}, len(item.MessageAttributes))
+	for name, value := range item.MessageAttributes {
+		if value.StringValue != nil {
+			attributes[name] = aws.ToString(value.StringValue)
+		} else {
+			attributes[name] = value.BinaryValue
+		}
+	}
+	system := make(map[string]interface{}, len(item.Attributes))
+	for name, value := range item.Attributes {
+		system[name] = value
+	}
+
+	msg.SetMetadata("sqsMessageId", aws.ToString(item.MessageId))
+	msg.SetMetadata("attributes", attributes)
+	msg.SetMetadata("systemAttributes", system)
+	return msg
+}
+
+// extendVisibility extends the visibility timeout at half intervals until
+// done is closed
+func (n *SQSInputNode) extendVisibility(ctx context.Context, item types.Message, done <-chan struct{}) {
+	defer n.wg.Done()
+
+	ticker := time.NewTicker(n.visibilityTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n.changeVisibility(ctx, item, n.visibilityTimeout)
+		}
+	}
+}
+
+// changeVisibility sets the visibility timeout of a received item
+func (n *SQSInputNode) changeVisibility(ctx context.Context, item types.Message, timeout time.Duration) {
+	_, err := n.client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(n.config.QueueURL),
+		ReceiptHandle:     item.ReceiptHandle,
+		VisibilityTimeout: int32(timeout / time.Second),
+	})
+	if err != nil && ctx.Err() == nil {
+		n.GetNode().Logger().Warn("failed to change visibility", "error", err)
+	}
+}
+
+// delete removes a received item from the queue
+func (n *SQSInputNode) delete(ctx context.Context, item types.Message) {
+	_, err := n.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(n.config.QueueURL),
+		ReceiptHandle: item.ReceiptHandle,
+	})
+	if err != nil {
+		n.GetNode().Logger().Warn("failed to delete message",
+			"message_id", aws.ToString(item.MessageId), "error", err)
+	}
+}
@@ -0,0 +1,447 @@
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// TCP modes
+const (
+	TCPModeServer = "server"
+	TCPModeClient = "client"
+)
+
+// TCP framings
+const (
+	TCPFramingStream     = "stream"
+	TCPFramingConnection = "connection"
+	TCPFramingDelimiter  = "delimiter"
+	TCPFramingLength     = "length"
+	TCPFramingFixed      = "fixed"
+)
+
+// MetadataTCPConnection is the metadata key holding the ID of the
+// connection a message arrived on, which tcp-out nodes can reply on
+const MetadataTCPConnection = "tcpConnection"
+
+// tcpWriteTimeout bounds writes to TCP connections
+const tcpWriteTimeout = 10 * time.Second
+
+// TCPFraming describes how a byte stream is split into messages
+type TCPFraming struct {
+	// Framing is "stream" for data as it arrives, "connection" for all
+	// data of a connection, "delimiter" for frames ending in Delimiter,
+	// "length" for frames preceded by their length, or "fixed" for frames
+	// of FrameSize bytes
+	Framing string `json:"framing" validate:"oneof=stream connection delimiter length fixed"`
+	// Delimiter ends frames; it is not part of the messages
+	Delimiter string `json:"delimiter"`
+	// PrefixBytes is the size of the big-endian length prefix: 1, 2 or 4
+	PrefixBytes int `json:"prefixBytes" validate:"oneof=1 2 4"`
+	FrameSize   int `json:"frameSize" validate:"min=0"`
+	// MaxFrameSize bounds frames and the data of a connection
+	MaxFrameSize int `json:"maxFrameSize" validate:"min=1"`
+}
+
+// Check validates the settings the framing needs
+func (f TCPFraming) Check() error {
+	switch {
+	case f.Framing == TCPFramingDelimiter && f.Delimiter == "":
+		return fmt.Errorf("delimiter framing needs a delimiter")
+	case f.Framing == TCPFramingFixed && f.FrameSize == 0:
+		return fmt.Errorf("fixed framing needs a frameSize")
+	}
+	return nil
+}
+
+// Frame returns data framed for writing: followed by the delimiter or
+// preceded by its length. Fixed frames must have the frame size.
+func (f TCPFraming) Frame(data []byte) ([]byte, error) {
+	switch f.Framing {
+	case TCPFramingDelimiter:
+		return append(data[:len(data):len(data)], f.Delimiter...), nil
+	case TCPFramingLength:
+		if max := uint64(1)<<(8*f.PrefixBytes) - 1; uint64(len(data)) > max {
+			return nil, fmt.Errorf("%d bytes do not fit a %d byte length prefix", len(data), f.PrefixBytes)
+		}
+		framed := make([]byte, f.PrefixBytes+len(data))
+		switch f.PrefixBytes {
+		case 1:
+			framed[0] = byte(len(data))
+		case 2:
+			binary.BigEndian.PutUint16(framed, uint16(len(data)))
+		default:
+			binary.BigEndian.PutUint32(framed, uint32(len(data)))
+		}
+		copy(framed[f.PrefixBytes:], data)
+		return framed, nil
+	case TCPFramingFixed:
+		if len(data) != f.FrameSize {
+			return nil, fmt.Errorf("%d bytes do not match the frame size %d", len(data), f.FrameSize)
+		}
+	}
+	return data, nil
+}
+
+// readFrames reads frames from r and passes them to emit until r ends
+func (f TCPFraming) readFrames(r io.Reader, emit func([]byte)) error {
+	switch f.Framing {
+	case TCPFramingConnection:
+		data, err := io.ReadAll(io.LimitReader(r, int64(f.MaxFrameSize)+1))
+		if err != nil {
+			return err
+		}
+		if len(data) > f.MaxFrameSize {
+			return fmt.Errorf("connection sent more than %d bytes", f.MaxFrameSize)
+		}
+		if len(data) > 0 {
+			emit(data)
+		}
+		return nil
+
+	case TCPFramingDelimiter:
+		delimiter := []byte(f.Delimiter)
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 4096), f.MaxFrameSize+len(delimiter))
+		scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+			if i := bytes.Index(data, delimiter); i >= 0 {
+				return i + len(delimiter), data[:i], nil
+			}
+			if atEOF && len(data) > 0 {
+				return len(data), data, nil
+			}
+			return 0, nil, nil
+		})
+		for scanner.Scan() {
+			emit(append([]byte(nil), scanner.Bytes()...))
+		}
+		if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+			return fmt.Errorf("frame exceeds %d bytes", f.MaxFrameSize)
+		}
+		return scanner.Err()
+
+	case TCPFramingLength:
+		prefix := make([]byte, f.PrefixBytes)
+		for {
+			if _, err := io.ReadFull(r, prefix); err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+			var size uint32
+			switch f.PrefixBytes {
+			case 1:
+				size = uint32(prefix[0])
+			case 2:
+				size = uint32(binary.BigEndian.Uint16(prefix))
+			default:
+				size = binary.BigEndian.Uint32(prefix)
+			}
+			if uint64(size) > uint64(f.MaxFrameSize) {
+				return fmt.Errorf("frame of %d bytes exceeds %d bytes", size, f.MaxFrameSize)
+			}
+			frame := make([]byte, size)
+			if _, err := io.ReadFull(r, frame); err != nil {
+				return fmt.Errorf("incomplete frame: %w", err)
+			}
+			emit(frame)
+		}
+
+	case TCPFramingFixed:
+		for {
+			frame := make([]byte, f.FrameSize)
+			if _, err := io.ReadFull(r, frame); err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return fmt.Errorf("incomplete frame: %w", err)
+			}
+			emit(frame)
+		}
+
+	default:
+		buf := make([]byte, 64*1024)
+		for {
+			count, err := r.Read(buf)
+			if count > 0 {
+				emit(append([]byte(nil), buf[:count]...))
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// tcpConnection is an open connection of a tcp-in node
+type tcpConnection struct {
+	conn net.Conn
+	mu   sync.Mutex // serializes writes
+}
+
+// tcpConnections holds the open connections of tcp-in nodes by ID
+var tcpConnections = struct {
+	sync.Mutex
+	conns map[string]*tcpConnection
+}{conns: make(map[string]*tcpConnection)}
+
+// WriteTCP writes data to the connection with the ID a tcp-in node put in
+// the MetadataTCPConnection metadata, and closes it afterwards if closeAfter
+// is set
+func WriteTCP(id string, data []byte, closeAfter bool) error {
+	tcpConnections.Lock()
+	c := tcpConnections.conns[id]
+	tcpConnections.Unlock()
+	if c == nil {
+		return fmt.Errorf("connection %s is closed", id)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(tcpWriteTimeout))
+	if _, err := c.conn.Write(data); err != nil {
+		c.conn.Close()
+		return fmt.Errorf("failed to write to connection %s: %w", id, err)
+	}
+	if closeAfter {
+		return c.conn.Close()
+	}
+	return nil
+}
+
+// TCPInputConfig represents the configuration of a tcp-in node
+type TCPInputConfig struct {
+	// Mode is "server" to accept connections, or "client" to connect to
+	// a remote host
+	Mode string `json:"mode" validate:"oneof=server client"`
+	// Address is the address to listen on, e.g. ":7000", or the
+	// host:port to connect to
+	Address string `json:"address" validate:"required"`
+	TCPFraming
+	// Output is "string" or "bytes"
+	Output string `json:"output" validate:"oneof=string bytes"`
+	Topic  string `json:"topic"`
+	// MaxConnections limits the connections a server accepts at once
+	MaxConnections int `json:"maxConnections" validate:"min=0"`
+	// ReconnectConfig holds the backoff of a client reconnecting
+	engine.ReconnectConfig
+}
+
+// TCPInputNode receives data on TCP connections it accepts or opens, and
+// sends it framed into messages carrying the connection's ID in the
+// MetadataTCPConnection metadata
+type TCPInputNode struct {
+	engine.BaseNode
+	config  TCPInputConfig
+	backoff *engine.Backoff
+
+	listener net.Listener
+	conns    map[string]net.Conn
+	mu       sync.Mutex
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// RegisterTCPInputNode registers the tcp-in node type
+func RegisterTCPInputNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "tcp-in",
+		Description: "Receives data on TCP connections",
+		Category:    "input",
+		Defaults:    json.RawMessage(`{"mode":"server","framing":"delimiter","delimiter":"\n","prefixBytes":4,"maxFrameSize":1048576,"output":"string","reconnectDelay":"1s","maxReconnectDelay":"30s"}`),
+		Factory: func() engine.NodeInstance {
+			return &TCPInputNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *TCPInputNode) Init(config json.RawMessage) error {
+	if err := n.LoadConfig(config, &n.config); err != nil {
+		return err
+	}
+	if err := n.config.Check(); err != nil {
+		return err
+	}
+	backoff, err := n.config.Backoff()
+	if err != nil {
+		return err
+	}
+	n.backoff = backoff
+	return nil
+}
+
+// Start listens or starts connecting
+func (n *TCPInputNode) Start(ctx context.Context) error {
+	ctx, n.cancel = context.WithCancel(ctx)
+	n.conns = make(map[string]net.Conn)
+
+	if n.config.Mode == TCPModeClient {
+		n.wg.Add(1)
+		go n.connect(ctx)
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", n.config.Address)
+	if err != nil {
+		n.cancel()
+		return fmt.Errorf("failed to listen on %s: %w", n.config.Address, err)
+	}
+	n.listener = listener
+	n.wg.Add(1)
+	go n.accept(listener)
+	return nil
+}
+
+// Stop closes the listener and all connections
+func (n *TCPInputNode) Stop() {
+	if n.cancel != nil {
+		n.cancel()
+	}
+	n.mu.Lock()
+	if n.listener != nil {
+		n.listener.Close()
+	}
+	for _, conn := range n.conns {
+		conn.Close()
+	}
+	n.mu.Unlock()
+	n.wg.Wait()
+}
+
+// OnMessage processes a message
+func (n *TCPInputNode) OnMessage(msg *engine.Message, port int) error {
+	return nil // Input node, doesn't process messages
+}
+
+// accept serves the connections of the listener until it is closed
+func (n *TCPInputNode) accept(listener net.Listener) {
+	defer n.wg.Done()
+	n.setStatus()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				n.GetNode().Logger().Error("stopped accepting", "error", err)
+			}
+			return
+		}
+
+		n.mu.Lock()
+		full := n.config.MaxConnections > 0 && len(n.conns) >= n.config.MaxConnections
+		n.mu.Unlock()
+		if full {
+			n.GetNode().Logger().Warn("refused connection, too many open", "remote", conn.RemoteAddr().String(), "connections", n.config.MaxConnections)
+			conn.Close()
+			continue
+		}
+
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			n.serve(conn)
+		}()
+	}
+}
+
+// connect keeps a connection to the remote host open, reconnecting with
+// backoff until the node stops
+func (n *TCPInputNode) connect(ctx context.Context) {
+	defer n.wg.Done()
+
+	var dialer net.Dialer
+	n.backoff.Reset()
+	for {
+		n.backoff.Start()
+		conn, err := dialer.DialContext(ctx, "tcp", n.config.Address)
+		if err == nil {
+			n.serve(conn)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		delay := n.backoff.Next()
+		if err != nil {
+			n.GetNode().Logger().Warn("failed to connect, retrying", "address", n.config.Address, "error", err, "delay", delay)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// serve sends the frames arriving on a connection until it closes
+func (n *TCPInputNode) serve(conn net.Conn) {
+	id := engine.NewUUID()
+	n.mu.Lock()
+	n.conns[id] = conn
+	n.mu.Unlock()
+	tcpConnections.Lock()
+	tcpConnections.conns[id] = &tcpConnection{conn: conn}
+	tcpConnections.Unlock()
+	n.setStatus()
+
+	defer func() {
+		conn.Close()
+		tcpConnections.Lock()
+		delete(tcpConnections.conns, id)
+		tcpConnections.Unlock()
+		n.mu.Lock()
+		delete(n.conns, id)
+		n.mu.Unlock()
+		n.setStatus()
+	}()
+
+	remote := conn.RemoteAddr().String()
+	err := n.config.readFrames(conn, func(frame []byte) {
+		var payload interface{} = frame
+		if n.config.Output == "string" {
+			payload = string(frame)
+		}
+		msg := engine.NewMessage(payload, n.config.Topic)
+		msg.SetMetadata(MetadataTCPConnection, id)
+		msg.SetMetadata("remoteAddr", remote)
+		if err := n.GetNode().Send(msg, 0); err != nil && !errors.Is(err, engine.ErrFlowThrottled) {
+			n.GetNode().Logger().Warn("failed to send message", "error", err)
+		}
+	})
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		n.GetNode().Logger().Warn("closed connection", "remote", remote, "error", err)
+	}
+}
+
+// setStatus reports the open connections as node status
+func (n *TCPInputNode) setStatus() {
+	n.mu.Lock()
+	count := len(n.conns)
+	n.mu.Unlock()
+
+	status := engine.NodeStatus{Fill: "green", Shape: "dot", Text: fmt.Sprintf("%d connections", count)}
+	if n.config.Mode == TCPModeClient {
+		status = engine.NodeStatus{Fill: "red", Shape: "ring", Text: "disconnected"}
+		if count > 0 {
+			status = engine.NodeStatus{Fill: "green", Shape: "dot", Text: "connected"}
+		}
+	}
+	n.GetNode().SetStatus(status)
+}
@@ -0,0 +1,149 @@
+package input
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// UDPInputConfig represents the configuration of a udp-in node
+type UDPInputConfig struct {
+	// Address is the address to listen on, e.g. ":5140"
+	Address string `json:"address" validate:"required"`
+	// Group is a multicast group to join, e.g. "239.0.0.1", on Interface
+	// or the system's default multicast interface
+	Group     string `json:"group"`
+	Interface string `json:"interface"`
+	// Output is "string" or "bytes"
+	Output string `json:"output" validate:"oneof=string bytes"`
+	Topic  string `json:"topic"`
+	// MaxSize is the largest datagram received whole; larger ones are
+	// truncated
+	MaxSize int `json:"maxSize" validate:"min=1,max=65535"`
+}
+
+// UDPInputNode sends a message for every datagram it receives, with the
+// sender's address in the "remoteAddr" metadata
+type UDPInputNode struct {
+	engine.BaseNode
+	config UDPInputConfig
+
+	conn   *net.UDPConn
+	warned bool
+	wg     sync.WaitGroup
+}
+
+// RegisterUDPInputNode registers the udp-in node type
+func RegisterUDPInputNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "udp-in",
+		Description: "Receives UDP datagrams",
+		Category:    "input",
+		Defaults:    json.RawMessage(`{"output":"string","maxSize":65535}`),
+		Factory: func() engine.NodeInstance {
+			return &UDPInputNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *UDPInputNode) Init(config json.RawMessage) error {
+	if err := n.LoadConfig(config, &n.config); err != nil {
+		return err
+	}
+	if n.config.Group != "" {
+		if ip := net.ParseIP(n.config.Group); ip == nil || !ip.IsMulticast() {
+			return fmt.Errorf("invalid multicast group: %s", n.config.Group)
+		}
+	}
+	return nil
+}
+
+// Start binds the address and starts receiving
+func (n *UDPInputNode) Start(ctx context.Context) error {
+	addr, err := net.ResolveUDPAddr("udp", n.config.Address)
+	if err != nil {
+		return fmt.Errorf("invalid address %s: %w", n.config.Address, err)
+	}
+
+	var conn *net.UDPConn
+	if n.config.Group != "" {
+		var iface *net.Interface
+		if n.config.Interface != "" {
+			if iface, err = net.InterfaceByName(n.config.Interface); err != nil {
+				return fmt.Errorf("invalid interface %s: %w", n.config.Interface, err)
+			}
+		}
+		addr.IP = net.ParseIP(n.config.Group)
+		conn, err = net.ListenMulticastUDP("udp", iface, addr)
+	} else {
+		conn, err = net.ListenUDP("udp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", n.config.Address, err)
+	}
+	n.conn = conn
+	n.warned = false
+
+	n.wg.Add(1)
+	go n.receive(conn)
+	return nil
+}
+
+// Stop closes the socket
+func (n *UDPInputNode) Stop() {
+	if n.conn != nil {
+		n.conn.Close()
+	}
+	n.wg.Wait()
+}
+
+// OnMessage processes a message
+func (n *UDPInputNode) OnMessage(msg *engine.Message, port int) error {
+	return nil // Input node, doesn't process messages
+}
+
+// receive sends the datagrams arriving on conn until it is closed
+func (n *UDPInputNode) receive(conn *net.UDPConn) {
+	defer n.wg.Done()
+
+	// One byte more than the maximum reveals larger datagrams
+	buf := make([]byte, n.config.MaxSize+1)
+	for {
+		count, remote, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				n.GetNode().Logger().Error("stopped receiving", "error", err)
+			}
+			return
+		}
+
+		truncated := count > n.config.MaxSize
+		if truncated {
+			count = n.config.MaxSize
+			if !n.warned {
+				n.GetNode().Logger().Warn("truncated a datagram; further truncations are only marked in the metadata",
+					"remote", remote, "bytes", count)
+				n.warned = true
+			}
+		}
+
+		var payload interface{} = append([]byte(nil), buf[:count]...)
+		if n.config.Output == "string" {
+			payload = string(buf[:count])
+		}
+		msg := engine.NewMessage(payload, n.config.Topic)
+		msg.SetMetadata("remoteAddr", remote.String())
+		if truncated {
+			msg.SetMetadata("truncated", true)
+		}
+		if err := n.GetNode().Send(msg, 0); err != nil && !errors.Is(err, engine.ErrFlowThrottled) {
+			n.GetNode().Logger().Warn("failed to send message", "error", err)
+		}
+	}
+}
@@ -0,0 +1,536 @@
+package input
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// WebSocket modes
+const (
+	WebSocketModeServer = "server"
+	WebSocketModeClient = "client"
+)
+
+// MetadataWebSocketConnection is the metadata key holding the ID of the
+// WebSocket connection a message arrived on, which websocket-out nodes can
+// reply on
+const MetadataWebSocketConnection = "websocketConnection"
+
+// websocketTimeout bounds handshakes and writes
+const websocketTimeout = 10 * time.Second
+
+// wsConnection is an open WebSocket connection
+type wsConnection struct {
+	conn *websocket.Conn
+	mu   sync.Mutex // serializes writes
+}
+
+// write writes a frame
+func (c *wsConnection) write(messageType int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(websocketTimeout))
+	return c.conn.WriteMessage(messageType, data)
+}
+
+// close says goodbye and closes the connection
+func (c *wsConnection) close() {
+	c.mu.Lock()
+	c.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(time.Second))
+	c.mu.Unlock()
+	c.conn.Close()
+}
+
+// wsConnections holds the open connections of websocket nodes by ID
+var wsConnections = struct {
+	sync.Mutex
+	conns map[string]*wsConnection
+}{conns: make(map[string]*wsConnection)}
+
+// addWebSocket registers a connection and returns its ID
+func addWebSocket(c *wsConnection) string {
+	id := engine.NewUUID()
+	wsConnections.Lock()
+	wsConnections.conns[id] = c
+	wsConnections.Unlock()
+	return id
+}
+
+func removeWebSocket(id string) {
+	wsConnections.Lock()
+	delete(wsConnections.conns, id)
+	wsConnections.Unlock()
+}
+
+// WriteWebSocket writes a frame to the connection with the ID a websocket
+// node put in the MetadataWebSocketConnection metadata
+func WriteWebSocket(id string, messageType int, data []byte) error {
+	wsConnections.Lock()
+	c := wsConnections.conns[id]
+	wsConnections.Unlock()
+	if c == nil {
+		return fmt.Errorf("connection %s is closed", id)
+	}
+	if err := c.write(messageType, data); err != nil {
+		c.conn.Close()
+		return fmt.Errorf("failed to write to connection %s: %w", id, err)
+	}
+	return nil
+}
+
+// wsReceiver handles a frame received on a connection
+type wsReceiver func(id string, messageType int, data []byte, remote string)
+
+// wsListenerKey identifies a listener by the routes and path it serves
+type wsListenerKey struct {
+	routes *engine.HTTPRoutes
+	path   string
+}
+
+// wsListeners holds the listeners in use
+var wsListeners = struct {
+	sync.Mutex
+	listeners map[wsListenerKey]*WebSocketListener
+}{listeners: make(map[wsListenerKey]*WebSocketListener)}
+
+// WebSocketListener accepts WebSocket connections on a path of the
+// runtime's HTTP server. The websocket nodes serving a path share its
+// listener.
+type WebSocketListener struct {
+	key       wsListenerKey
+	upgrader  websocket.Upgrader
+	refs      int
+	conns     map[string]*wsConnection
+	receivers map[string]wsReceiver
+	mu        sync.Mutex
+}
+
+// AcquireWebSocketListener returns the listener of a path, registering the
+// path with routes for its first user. Users release it when done.
+func AcquireWebSocketListener(routes *engine.HTTPRoutes, path string) (*WebSocketListener, error) {
+	wsListeners.Lock()
+	defer wsListeners.Unlock()
+
+	key := wsListenerKey{routes, path}
+	if l := wsListeners.listeners[key]; l != nil {
+		l.refs++
+		return l, nil
+	}
+
+	l := &WebSocketListener{
+		key: key,
+		upgrader: websocket.Upgrader{
+			HandshakeTimeout: websocketTimeout,
+			CheckOrigin: func(r *http.Request) bool {
+				return true
+			},
+		},
+		refs:      1,
+		conns:     make(map[string]*wsConnection),
+		receivers: make(map[string]wsReceiver),
+	}
+	if err := routes.Handle(http.MethodGet, path, http.HandlerFunc(l.serveHTTP)); err != nil {
+		return nil, err
+	}
+	wsListeners.listeners[key] = l
+	return l, nil
+}
+
+// Release gives up a use of the listener; the last one removes the path
+// and closes its connections
+func (l *WebSocketListener) Release() {
+	wsListeners.Lock()
+	l.refs--
+	last := l.refs == 0
+	if last {
+		delete(wsListeners.listeners, l.key)
+		l.key.routes.Remove(http.MethodGet, l.key.path)
+	}
+	wsListeners.Unlock()
+	if !last {
+		return
+	}
+
+	l.mu.Lock()
+	conns := make([]*wsConnection, 0, len(l.conns))
+	for _, c := range l.conns {
+		conns = append(conns, c)
+	}
+	l.mu.Unlock()
+	for _, c := range conns {
+		c.close()
+	}
+}
+
+// Broadcast writes a frame to every connection of the listener; those
+// failing to take it are closed
+func (l *WebSocketListener) Broadcast(messageType int, data []byte) {
+	l.mu.Lock()
+	conns := make([]*wsConnection, 0, len(l.conns))
+	for _, c := range l.conns {
+		conns = append(conns, c)
+	}
+	l.mu.Unlock()
+
+	for _, c := range conns {
+		if err := c.write(messageType, data); err != nil {
+			c.conn.Close()
+		}
+	}
+}
+
+// Connections returns the number of open connections
+func (l *WebSocketListener) Connections() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.conns)
+}
+
+// setReceiver sets, or with a nil receiver removes, the handler of the
+// frames received by the listener for a node
+func (l *WebSocketListener) setReceiver(nodeID string, receiver wsReceiver) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if receiver == nil {
+		delete(l.receivers, nodeID)
+	} else {
+		l.receivers[nodeID] = receiver
+	}
+}
+
+// serveHTTP upgrades a request and passes the frames arriving on the
+// connection to the receivers until it closes
+func (l *WebSocketListener) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := l.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	// The connection outlives the HTTP server's request timeouts
+	conn.SetReadDeadline(time.Time{})
+
+	c := &wsConnection{conn: conn}
+	id := addWebSocket(c)
+	l.mu.Lock()
+	l.conns[id] = c
+	l.mu.Unlock()
+	defer func() {
+		conn.Close()
+		removeWebSocket(id)
+		l.mu.Lock()
+		delete(l.conns, id)
+		l.mu.Unlock()
+	}()
+
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		l.mu.Lock()
+		receivers := make([]wsReceiver, 0, len(l.receivers))
+		for _, receiver := range l.receivers {
+			receivers = append(receivers, receiver)
+		}
+		l.mu.Unlock()
+		for _, receiver := range receivers {
+			receiver(id, messageType, data, r.RemoteAddr)
+		}
+	}
+}
+
+// WebSocketClient keeps a connection to a WebSocket server open,
+// reconnecting with backoff until stopped
+type WebSocketClient struct {
+	URL    string
+	Header http.Header
+	// Backoff is the delay before reconnecting
+	Backoff *engine.Backoff
+	// Logger logs the failed connection attempts; nil logs to slog's
+	// default logger
+	Logger *slog.Logger
+	// OnMessage is called for every frame received
+	OnMessage func(id string, messageType int, data []byte)
+	// OnStatus is called when the client connects or disconnects
+	OnStatus func(connected bool)
+
+	conn   *wsConnection
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Start starts connecting
+func (c *WebSocketClient) Start(ctx context.Context) {
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.wg.Add(1)
+	go c.connect(ctx)
+}
+
+// logger returns the logger of the client
+func (c *WebSocketClient) logger() *slog.Logger {
+	if c.Logger == nil {
+		return slog.Default()
+	}
+	return c.Logger
+}
+
+// Stop closes the connection and stops reconnecting
+func (c *WebSocketClient) Stop() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.mu.Lock()
+	if c.conn != nil {
+		c.conn.close()
+	}
+	c.mu.Unlock()
+	c.wg.Wait()
+}
+
+// Write writes a frame to the server
+func (c *WebSocketClient) Write(messageType int, data []byte) error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return fmt.Errorf("not connected to %s", c.URL)
+	}
+	if err := conn.write(messageType, data); err != nil {
+		// Closing the connection makes connect reconnect
+		conn.conn.Close()
+		return fmt.Errorf("failed to write to %s: %w", c.URL, err)
+	}
+	return nil
+}
+
+// connect keeps the connection open until ctx is done
+func (c *WebSocketClient) connect(ctx context.Context) {
+	defer c.wg.Done()
+	c.OnStatus(false)
+
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: websocketTimeout,
+	}
+	c.Backoff.Reset()
+	for {
+		c.Backoff.Start()
+		conn, _, err := dialer.DialContext(ctx, c.URL, c.Header)
+		if err == nil {
+			c.serve(conn)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		delay := c.Backoff.Next()
+		if err != nil {
+			c.logger().Warn("failed to connect, retrying", "url", c.URL, "error", err, "delay", delay)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// serve passes the frames arriving on a connection to OnMessage until it
+// closes
+func (c *WebSocketClient) serve(conn *websocket.Conn) {
+	wc := &wsConnection{conn: conn}
+	id := addWebSocket(wc)
+	c.mu.Lock()
+	c.conn = wc
+	c.mu.Unlock()
+	c.OnStatus(true)
+
+	defer func() {
+		conn.Close()
+		removeWebSocket(id)
+		c.mu.Lock()
+		c.conn = nil
+		c.mu.Unlock()
+		c.OnStatus(false)
+	}()
+
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if c.OnMessage != nil {
+			c.OnMessage(id, messageType, data)
+		}
+	}
+}
+
+// WebSocketInputConfig represents the configuration of a websocket-in node
+type WebSocketInputConfig struct {
+	// Mode is "server" to accept connections on Path, or "client" to
+	// connect to URL
+	Mode string `json:"mode" validate:"oneof=server client"`
+	// Path is served below the prefix of the flow's workspace
+	Path string `json:"path"`
+	// URL is a ws:// or wss:// URL
+	URL string `json:"url"`
+	// Headers are sent with the client's handshake, e.g. Authorization
+	Headers map[string]string `json:"headers"`
+	// Output is "auto" for strings from text frames and bytes from binary
+	// frames, "string" or "bytes"
+	Output string `json:"output" validate:"oneof=auto string bytes"`
+	Topic  string `json:"topic"`
+	// ReconnectConfig holds the backoff of the client reconnecting
+	engine.ReconnectConfig
+}
+
+// CheckWebSocketEndpoint validates the path of server mode or the URL of
+// client mode
+func CheckWebSocketEndpoint(mode, path, rawURL string) error {
+	if mode == WebSocketModeServer {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("path must start with /")
+		}
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+		return fmt.Errorf("url must be a ws:// or wss:// URL: %s", rawURL)
+	}
+	return nil
+}
+
+// WebSocketInputNode sends a message for every frame received on the
+// connections of a path it serves, or on its connection to a server, with
+// the connection's ID in the MetadataWebSocketConnection metadata
+type WebSocketInputNode struct {
+	engine.BaseNode
+	config WebSocketInputConfig
+
+	listener *WebSocketListener
+	client   *WebSocketClient
+}
+
+// RegisterWebSocketInputNode registers the websocket-in node type
+func RegisterWebSocketInputNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "websocket-in",
+		Description: "Receives WebSocket frames",
+		Category:    "input",
+		Defaults:    json.RawMessage(`{"mode":"server","output":"auto","reconnectDelay":"1s","maxReconnectDelay":"30s"}`),
+		Factory: func() engine.NodeInstance {
+			return &WebSocketInputNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *WebSocketInputNode) Init(config json.RawMessage) error {
+	if err := n.LoadConfig(config, &n.config); err != nil {
+		return err
+	}
+	if err := CheckWebSocketEndpoint(n.config.Mode, n.config.Path, n.config.URL); err != nil {
+		return err
+	}
+
+	n.client = nil
+	if n.config.Mode == WebSocketModeClient {
+		node := n.GetNode()
+		client, err := NewWebSocketClient(n.config.URL, n.config.Headers, n.config.ReconnectConfig, node)
+		if err != nil {
+			return err
+		}
+		client.OnMessage = func(id string, messageType int, data []byte) {
+			n.send(id, messageType, data, n.config.URL)
+		}
+		n.client = client
+	}
+	return nil
+}
+
+// Start serves the path or starts connecting
+func (n *WebSocketInputNode) Start(ctx context.Context) error {
+	if n.client != nil {
+		n.client.Start(ctx)
+		return nil
+	}
+
+	node := n.GetNode()
+	flow := node.GetFlow()
+	listener, err := AcquireWebSocketListener(flow.GetEngine().HTTPRoutes(), flow.HTTPPath(n.config.Path))
+	if err != nil {
+		return err
+	}
+	listener.setReceiver(node.ID, n.send)
+	n.listener = listener
+	return nil
+}
+
+// Stop stops serving the path or closes the connection
+func (n *WebSocketInputNode) Stop() {
+	if n.listener != nil {
+		n.listener.setReceiver(n.GetNode().ID, nil)
+		n.listener.Release()
+		n.listener = nil
+	}
+	if n.client != nil {
+		n.client.Stop()
+	}
+}
+
+// OnMessage processes a message
+func (n *WebSocketInputNode) OnMessage(msg *engine.Message, port int) error {
+	return nil // Input node, doesn't process messages
+}
+
+// send sends a received frame
+func (n *WebSocketInputNode) send(id string, messageType int, data []byte, remote string) {
+	var payload interface{} = data
+	if n.config.Output == "string" || n.config.Output == "auto" && messageType == websocket.TextMessage {
+		payload = string(data)
+	}
+	msg := engine.NewMessage(payload, n.config.Topic)
+	msg.SetMetadata(MetadataWebSocketConnection, id)
+	msg.SetMetadata("remoteAddr", remote)
+	if err := n.GetNode().Send(msg, 0); err != nil && !errors.Is(err, engine.ErrFlowThrottled) {
+		n.GetNode().Logger().Warn("failed to send message", "error", err)
+	}
+}
+
+// NewWebSocketClient creates a client for a websocket node from its
+// configuration, reporting the connection as the node's status
+func NewWebSocketClient(rawURL string, headers map[string]string, reconnect engine.ReconnectConfig, node *engine.Node) (*WebSocketClient, error) {
+	backoff, err := reconnect.Backoff()
+	if err != nil {
+		return nil, err
+	}
+	header := make(http.Header, len(headers))
+	for key, value := range headers {
+		header.Set(key, value)
+	}
+	return &WebSocketClient{
+		URL:     rawURL,
+		Header:  header,
+		Backoff: backoff,
+		Logger:  node.Logger(),
+		OnStatus: func(connected bool) {
+			status := engine.NodeStatus{Fill: "red", Shape: "ring", Text: "disconnected"}
+			if connected {
+				status = engine.NodeStatus{Fill: "green", Shape: "dot", Text: "connected"}
+			}
+			node.SetStatus(status)
+		},
+	}, nil
+}
@@ -0,0 +1,219 @@
+package output
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// Debug targets
+const (
+	DebugTargetLog     = "log"
+	DebugTargetSidebar = "sidebar"
+	DebugTargetBoth    = "both"
+)
+
+// DebugConfig represents the configuration of a debug node
+type DebugConfig struct {
+	// Complete selects what is output: "payload", "msg" for the whole
+	// message, or a property path such as "payload.temperature"
+	Complete string `json:"complete" validate:"required"`
+	// Target is log, sidebar or both
+	Target string `json:"target" validate:"oneof=log sidebar both"`
+	Active bool   `json:"active"`
+	// MaxLength truncates strings, MaxDepth limits nested objects and
+	// MaxBytes limits the hex dump of []byte values
+	MaxLength int `json:"maxLength" validate:"min=1"`
+	MaxDepth  int `json:"maxDepth" validate:"min=1"`
+	MaxBytes  int `json:"maxBytes" validate:"min=1"`
+	// MaxPayload limits the JSON encoding of the value sent to the
+	// sidebar and /ws/debug, in bytes
+	MaxPayload int `json:"maxPayload" validate:"min=1"`
+}
+
+// DebugNode outputs messages to the runtime log and the editor's debug
+// sidebar
+type DebugNode struct {
+	engine.BaseNode
+	config DebugConfig
+	active int32
+	count  int64
+}
+
+// RegisterDebugNode registers the debug node type
+func RegisterDebugNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "debug",
+		Description: "Outputs messages to the debug sidebar and runtime log",
+		Category:    "output",
+		Defaults:    json.RawMessage(`{"complete":"payload","target":"sidebar","active":true,"maxLength":1000,"maxDepth":5,"maxBytes":64,"maxPayload":16384}`),
+		Dynamic:     []string{"active"},
+		Factory: func() engine.NodeInstance {
+			return &DebugNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *DebugNode) Init(config json.RawMessage) error {
+	if err := n.LoadConfig(config, &n.config); err != nil {
+		return err
+	}
+
+	n.SetEnabled(n.config.Active)
+	return nil
+}
+
+// Start starts the node
+func (n *DebugNode) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop stops the node
+func (n *DebugNode) Stop() {}
+
+// SetEnabled activates or deactivates the node at runtime
+func (n *DebugNode) SetEnabled(enabled bool) {
+	var active int32
+	if enabled {
+		active = 1
+	}
+	atomic.StoreInt32(&n.active, active)
+}
+
+// UpdateConfig switches the node on or off without a redeploy
+func (n *DebugNode) UpdateConfig(partial json.RawMessage) error {
+	var patch struct {
+		Active *bool `json:"active"`
+	}
+	if err := engine.UnmarshalConfig(partial, &patch); err != nil {
+		return err
+	}
+	if patch.Active != nil {
+		n.SetEnabled(*patch.Active)
+	}
+	return nil
+}
+
+// OnMessage outputs a message
+func (n *DebugNode) OnMessage(msg *engine.Message, port int) error {
+	// Nothing reads a stream after the debug node
+	if stream, ok := msg.Payload.(*engine.Stream); ok {
+		defer stream.Close()
+	}
+	defer n.GetNode().Done(msg)
+
+	if atomic.LoadInt32(&n.active) == 0 {
+		return nil
+	}
+
+	var value interface{}
+	switch n.config.Complete {
+	case "msg":
+		value = map[string]interface{}{
+			"payload":  msg.Payload,
+			"topic":    msg.Topic,
+			"headers":  msg.Headers,
+			"metadata": msg.Metadata,
+			"msgId":    msg.MsgID,
+		}
+	default:
+		value, _ = msg.GetProperty(n.config.Complete)
+	}
+	value = n.preview(value, 0)
+
+	node := n.GetNode()
+	count := atomic.AddInt64(&n.count, 1)
+
+	if n.config.Target == DebugTargetLog || n.config.Target == DebugTargetBoth {
+		data, _ := json.Marshal(value)
+		node.Logger().Info("debug", "topic", msg.Topic, "value", json.RawMessage(data))
+	}
+
+	if n.config.Target == DebugTargetSidebar || n.config.Target == DebugTargetBoth {
+		value, truncated := n.truncate(value)
+		node.PublishEvent(engine.EventDebug, map[string]interface{}{
+			"name":      node.Name,
+			"msgId":     msg.MsgID,
+			"topic":     msg.Topic,
+			"property":  n.config.Complete,
+			"value":     value,
+			"truncated": truncated,
+		})
+	}
+
+	node.SetStatus(engine.NodeStatus{Text: fmt.Sprintf("%d messages", count)})
+	return nil
+}
+
+// truncate replaces a value whose JSON encoding is longer than MaxPayload
+// with the start of the encoding, reporting whether it did
+func (n *DebugNode) truncate(value interface{}) (interface{}, bool) {
+	data, err := json.Marshal(value)
+	if err != nil || len(data) <= n.config.MaxPayload {
+		return value, false
+	}
+	cut := n.config.MaxPayload
+	for cut > 0 && !utf8.RuneStart(data[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s... (%d bytes)", data[:cut], len(data)), true
+}
+
+// preview returns a size-limited representation of a value
+func (n *DebugNode) preview(value interface{}, depth int) interface{} {
+	switch v := value.(type) {
+	case *engine.Stream:
+		return v.String()
+
+	case []byte:
+		head := v
+		if len(head) > n.config.MaxBytes {
+			head = head[:n.config.MaxBytes]
+		}
+		result := fmt.Sprintf("[%d bytes] %s", len(v), hex.EncodeToString(head))
+		if len(head) < len(v) {
+			result += "..."
+		}
+		return result
+
+	case string:
+		if utf8.RuneCountInString(v) <= n.config.MaxLength {
+			return v
+		}
+		runes := []rune(v)
+		return fmt.Sprintf("%s... (%d more characters)", string(runes[:n.config.MaxLength]), len(runes)-n.config.MaxLength)
+
+	case map[string]interface{}:
+		if depth >= n.config.MaxDepth {
+			return fmt.Sprintf("[object with %d keys]", len(v))
+		}
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = n.preview(item, depth+1)
+		}
+		return result
+
+	case []interface{}:
+		if depth >= n.config.MaxDepth {
+			return fmt.Sprintf("[array of %d items]", len(v))
+		}
+		items := v
+		if len(items) > n.config.MaxLength {
+			items = items[:n.config.MaxLength]
+		}
+		result := make([]interface{}, len(items))
+		for i, item := range items {
+			result[i] = n.preview(item, depth+1)
+		}
+		return result
+
+	default:
+		return value
+	}
+}
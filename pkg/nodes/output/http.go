@@ -0,0 +1,129 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/pkg/nodes/input"
+)
+
+// HTTPResponseConfig represents the configuration of an HTTP response node
+type HTTPResponseConfig struct {
+	StatusCode int               `json:"statusCode"`
+	Headers    map[string]string `json:"headers"`
+}
+
+// HTTPResponseNode completes the HTTP request a message originated from.
+// The message may override the status code and add headers through the
+// "statusCode" and "responseHeaders" metadata keys.
+type HTTPResponseNode struct {
+	engine.BaseNode
+	config HTTPResponseConfig
+}
+
+// RegisterHTTPResponseNode registers the HTTP response node type
+func RegisterHTTPResponseNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "http-response",
+		Description: "Sends the response to a request received by an http-in node",
+		Category:    "output",
+		Defaults:    json.RawMessage(`{"statusCode":200}`),
+		Parallel:    true,
+		Factory: func() engine.NodeInstance {
+			return &HTTPResponseNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *HTTPResponseNode) Init(config json.RawMessage) error {
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &n.config); err != nil {
+			return fmt.Errorf("invalid http-response config: %w", err)
+		}
+	}
+	return nil
+}
+
+// Start starts the node
+func (n *HTTPResponseNode) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop stops the node
+func (n *HTTPResponseNode) Stop() {}
+
+// OnMessage sends the HTTP response for a message
+func (n *HTTPResponseNode) OnMessage(msg *engine.Message, port int) error {
+	value, exists := msg.GetMetadata(input.MetadataHTTPRequestID)
+	id, ok := value.(string)
+	if !exists || !ok {
+		return fmt.Errorf("http-response: message has no %s", input.MetadataHTTPRequestID)
+	}
+
+	response := &engine.HTTPResponse{
+		StatusCode: n.config.StatusCode,
+		Headers:    make(map[string]string),
+	}
+	for key, value := range n.config.Headers {
+		response.Headers[key] = value
+	}
+
+	if value, exists := msg.GetMetadata("statusCode"); exists {
+		if status, ok := engine.ToFloat(value); ok {
+			response.StatusCode = int(status)
+		}
+	}
+	if value, exists := msg.GetMetadata("responseHeaders"); exists {
+		switch headers := value.(type) {
+		case map[string]string:
+			for key, value := range headers {
+				response.Headers[key] = value
+			}
+		case map[string]interface{}:
+			for key, value := range headers {
+				response.Headers[key] = fmt.Sprintf("%v", value)
+			}
+		}
+	}
+
+	switch payload := msg.Payload.(type) {
+	case nil:
+	case *engine.Stream:
+		response.Stream = payload
+		if _, exists := response.Headers["Content-Type"]; !exists && payload.ContentType != "" {
+			response.Headers["Content-Type"] = payload.ContentType
+		}
+		if payload.Length >= 0 {
+			response.Headers["Content-Length"] = strconv.FormatInt(payload.Length, 10)
+		}
+	case []byte:
+		response.Body = payload
+	case string:
+		response.Body = []byte(payload)
+	default:
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("http-response: failed to encode payload: %w", err)
+		}
+		response.Body = body
+		if _, exists := response.Headers["Content-Type"]; !exists {
+			response.Headers["Content-Type"] = "application/json"
+		}
+	}
+
+	requests := n.GetNode().GetFlow().GetEngine().HTTPRequests()
+	if err := requests.Respond(id, response); err != nil {
+		if response.Stream != nil {
+			response.Stream.Close()
+		}
+		n.GetNode().Logger().Warn("failed to respond", "request_id", id, "error", err)
+		return fmt.Errorf("http-response: %w", err)
+	}
+
+	n.GetNode().Done(msg)
+	return nil
+}
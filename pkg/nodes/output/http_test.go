@@ -0,0 +1,292 @@
+package output_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/registry"
+	"github.com/yourusername/go-red/internal/storage"
+	"github.com/yourusername/go-red/pkg/nodes/input"
+	"github.com/yourusername/go-red/pkg/nodes/output"
+)
+
+// newHTTPEngine returns a running engine with the http-in and http-response
+// node types, and a server of its http-in routes
+func newHTTPEngine(t *testing.T) (*engine.Engine, *httptest.Server) {
+	t.Helper()
+	reg := registry.New()
+	if err := input.RegisterHTTPInputNode(reg); err != nil {
+		t.Fatal(err)
+	}
+	if err := output.RegisterHTTPResponseNode(reg); err != nil {
+		t.Fatal(err)
+	}
+	eng := engine.New(reg, storage.NewMemoryStorage())
+	if err := eng.Start(); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(eng.HTTPRoutes())
+	t.Cleanup(func() {
+		server.Close()
+		eng.Stop()
+	})
+	return eng, server
+}
+
+// request sends a request and returns the status and body of its response
+func request(t *testing.T, method, url string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestHTTPResponseSentOnce(t *testing.T) {
+	eng, server := newHTTPEngine(t)
+	// Both response nodes get the message; the first one answers
+	flowDef := []byte(`{
+		"id": "twice",
+		"nodes": [
+			{"id": "in", "type": "http-in", "config": {"method": "GET", "url": "/twice"}},
+			{"id": "first", "type": "http-response", "config": {"statusCode": 201}},
+			{"id": "second", "type": "http-response", "config": {"statusCode": 202}}
+		],
+		"wires": [{"source": "in", "target": "first", "port": 0}, {"source": "in", "target": "second", "port": 0}]
+	}`)
+	if err := eng.DeployFlow("twice", flowDef); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if status, _ := request(t, http.MethodGet, server.URL+"/twice"); status != http.StatusCreated {
+			t.Errorf("request %d: status %d, want %d from the first response node", i, status, http.StatusCreated)
+		}
+	}
+}
+
+func TestHTTPRequestsRespond(t *testing.T) {
+	requests := engine.NewHTTPRequests()
+	responses := requests.Register("r1")
+
+	if err := requests.Respond("r1", &engine.HTTPResponse{StatusCode: http.StatusOK}); err != nil {
+		t.Fatal(err)
+	}
+	if err := requests.Respond("r1", &engine.HTTPResponse{StatusCode: http.StatusTeapot}); !errors.Is(err, engine.ErrHTTPResponseSent) {
+		t.Errorf("second response: error %v, want %v", err, engine.ErrHTTPResponseSent)
+	}
+	if response := <-responses; response.StatusCode != http.StatusOK {
+		t.Errorf("delivered status %d, want the first response's %d", response.StatusCode, http.StatusOK)
+	}
+	select {
+	case response := <-responses:
+		t.Errorf("second response delivered with status %d", response.StatusCode)
+	default:
+	}
+
+	requests.Remove("r1")
+	if err := requests.Respond("r1", &engine.HTTPResponse{}); !errors.Is(err, engine.ErrHTTPRequestNotFound) {
+		t.Errorf("response after removal: error %v, want %v", err, engine.ErrHTTPRequestNotFound)
+	}
+}
+
+func TestHTTPInputsSharingPath(t *testing.T) {
+	type route struct{ method, path string }
+	tests := []struct {
+		name string
+		// inputs are the routes of http-in nodes, each wired to its own
+		// response node, which answers with status 200 plus its index
+		inputs  []route
+		wantErr bool
+		// requests map requests to the status of the node expected to
+		// answer them
+		requests map[route]int
+	}{
+		{
+			name:     "different methods",
+			inputs:   []route{{"GET", "/orders"}, {"POST", "/orders"}},
+			requests: map[route]int{{"GET", "/orders"}: 200, {"POST", "/orders"}: 201, {"PUT", "/orders"}: http.StatusNotFound},
+		},
+		{
+			name:     "parameter below a fixed path",
+			inputs:   []route{{"GET", "/orders/{id}"}, {"GET", "/orders"}},
+			requests: map[route]int{{"GET", "/orders/7"}: 200, {"GET", "/orders"}: 201},
+		},
+		{name: "same method", inputs: []route{{"GET", "/orders"}, {"GET", "/orders"}}, wantErr: true},
+		{name: "any method", inputs: []route{{"any", "/orders"}, {"POST", "/orders"}}, wantErr: true},
+		{name: "other parameter names", inputs: []route{{"GET", "/orders/{id}"}, {"GET", "/orders/{name}"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eng, server := newHTTPEngine(t)
+			var nodes, wires []string
+			for i, in := range tt.inputs {
+				nodes = append(nodes,
+					fmt.Sprintf(`{"id": "in%d", "type": "http-in", "config": {"method": %q, "url": %q}}`, i, in.method, in.path),
+					fmt.Sprintf(`{"id": "out%d", "type": "http-response", "config": {"statusCode": %d}}`, i, 200+i))
+				wires = append(wires, fmt.Sprintf(`{"source": "in%d", "target": "out%d", "port": 0}`, i, i))
+			}
+			flowDef := fmt.Sprintf(`{"id": "shared", "nodes": [%s], "wires": [%s]}`, strings.Join(nodes, ", "), strings.Join(wires, ", "))
+
+			err := eng.DeployFlow("shared", []byte(flowDef))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DeployFlow error = %v, want error %v", err, tt.wantErr)
+			}
+			for req, want := range tt.requests {
+				if status, _ := request(t, req.method, server.URL+req.path); status != want {
+					t.Errorf("%s %s: status %d, want %d", req.method, req.path, status, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPInputsSharingPathAcrossFlows(t *testing.T) {
+	eng, server := newHTTPEngine(t)
+	flow := func(id string, status int) []byte {
+		return []byte(fmt.Sprintf(`{
+			"id": %q,
+			"nodes": [
+				{"id": "in", "type": "http-in", "config": {"method": "GET", "url": "/shared"}},
+				{"id": "out", "type": "http-response", "config": {"statusCode": %d}}
+			],
+			"wires": [{"source": "in", "target": "out", "port": 0}]
+		}`, id, status))
+	}
+	if err := eng.DeployFlow("first", flow("first", http.StatusOK)); err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.DeployFlow("second", flow("second", http.StatusAccepted)); err == nil {
+		t.Error("a second flow took the route of the first")
+	}
+	if status, _ := request(t, http.MethodGet, server.URL+"/shared"); status != http.StatusOK {
+		t.Errorf("status %d, want %d from the first flow", status, http.StatusOK)
+	}
+}
+
+func TestHTTPStreamLeaks(t *testing.T) {
+	eng, _ := newHTTPEngine(t)
+	flowDef := []byte(`{
+		"id": "streams",
+		"nodes": [
+			{"id": "echo", "type": "http-in", "config": {"method": "POST", "url": "/echo", "streamBody": true}},
+			{"id": "echoed", "type": "http-response", "config": {}},
+			{"id": "drop", "type": "http-in", "config": {"method": "POST", "url": "/drop", "streamBody": true, "timeout": "50ms"}},
+			{"id": "fanout", "type": "http-in", "config": {"method": "POST", "url": "/fanout", "streamBody": true}},
+			{"id": "first", "type": "http-response", "config": {}},
+			{"id": "second", "type": "http-response", "config": {}}
+		],
+		"wires": [
+			{"source": "echo", "target": "echoed", "port": 0},
+			{"source": "fanout", "target": "first", "port": 0},
+			{"source": "fanout", "target": "second", "port": 0}
+		]
+	}`)
+	if err := eng.DeployFlow("streams", flowDef); err != nil {
+		t.Fatal(err)
+	}
+
+	// Track the connections the server has open, by state
+	var mu sync.Mutex
+	states := make(map[net.Conn]http.ConnState)
+	server := httptest.NewUnstartedServer(eng.HTTPRoutes())
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		mu.Lock()
+		defer mu.Unlock()
+		if state == http.StateClosed || state == http.StateHijacked {
+			delete(states, conn)
+		} else {
+			states[conn] = state
+		}
+	}
+	server.Start()
+	defer server.Close()
+	transport := &http.Transport{}
+	client := &http.Client{Transport: transport}
+
+	streams := engine.OpenStreams()
+	goroutines := runtime.NumGoroutine()
+	body := strings.Repeat("x", 1<<20)
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/echo", http.StatusOK, body},
+		{"/drop", http.StatusGatewayTimeout, "No response from flow\n"},
+		{"/fanout", http.StatusInternalServerError, ""},
+	}
+	for i := 0; i < 3; i++ {
+		for _, tt := range tests {
+			resp, err := client.Post(server.URL+tt.path, "text/plain", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("%s: status %d, want %d", tt.path, resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantBody != "" && string(got) != tt.wantBody {
+				t.Errorf("%s: body of %d bytes, want %d", tt.path, len(got), len(tt.wantBody))
+			}
+		}
+	}
+
+	// Every request's stream is closed and its connection idle or closed,
+	// which the server notes just after the response went out
+	active := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		count := 0
+		for _, state := range states {
+			if state != http.StateIdle {
+				count++
+			}
+		}
+		return count
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for active() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := active(); n > 0 {
+		t.Errorf("%d connections left active", n)
+	}
+	if open := engine.OpenStreams(); open != streams {
+		t.Errorf("%d streams open, want %d", open, streams)
+	}
+
+	// Once the client lets go of its connections nothing is left running
+	transport.CloseIdleConnections()
+	deadline = time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > goroutines {
+		t.Errorf("%d goroutines running, %d before the requests", n, goroutines)
+	}
+}
@@ -0,0 +1,382 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// Log file formats
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+)
+
+const (
+	defaultLogMaxFiles      = 5
+	defaultLogFlushInterval = time.Second
+	defaultLogTemplate      = "{{.timestamp}} [{{.topic}}] {{.payload}}"
+)
+
+// LogFileConfig represents the configuration of a log-out node
+type LogFileConfig struct {
+	File string `json:"file"`
+	// Format is json (one JSON object per line) or text
+	Format string `json:"format"`
+	// Template is a text/template used by the text format, with the fields
+	// timestamp, topic, payload and headers
+	Template string `json:"template"`
+	// Headers lists the message headers included in each entry
+	Headers []string `json:"headers"`
+	// MaxSize rotates the file once it reaches this many bytes
+	MaxSize int64 `json:"maxSize"`
+	// MaxAge rotates the file once it is older than this, e.g. "24h"
+	MaxAge string `json:"maxAge"`
+	// MaxFiles is the number of rotated files kept
+	MaxFiles      int    `json:"maxFiles"`
+	Compress      bool   `json:"compress"`
+	FlushInterval string `json:"flushInterval"`
+}
+
+// LogFileNode appends messages to a log file with size and age based
+// rotation
+type LogFileNode struct {
+	engine.BaseNode
+	config        LogFileConfig
+	template      *template.Template
+	maxAge        time.Duration
+	flushInterval time.Duration
+
+	file     *os.File
+	writer   *bufio.Writer
+	size     int64
+	openedAt time.Time
+	mu       sync.Mutex
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// RegisterLogFileNode registers the log-out node type
+func RegisterLogFileNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "log-out",
+		Description: "Appends messages to a rotating log file",
+		Category:    "output",
+		Defaults:    json.RawMessage(`{"format":"json","maxSize":10485760,"maxFiles":5,"flushInterval":"1s"}`),
+		Factory: func() engine.NodeInstance {
+			return &LogFileNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *LogFileNode) Init(config json.RawMessage) error {
+	n.config = LogFileConfig{
+		Format:   LogFormatJSON,
+		MaxFiles: defaultLogMaxFiles,
+	}
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &n.config); err != nil {
+			return fmt.Errorf("invalid log-out config: %w", err)
+		}
+	}
+
+	if n.config.File == "" {
+		return fmt.Errorf("file is required")
+	}
+	if n.config.MaxFiles < 0 {
+		return fmt.Errorf("maxFiles must not be negative")
+	}
+
+	switch n.config.Format {
+	case LogFormatJSON:
+	case LogFormatText:
+		text := n.config.Template
+		if text == "" {
+			text = defaultLogTemplate
+		}
+		tmpl, err := template.New("log").Parse(text)
+		if err != nil {
+			return fmt.Errorf("invalid template: %w", err)
+		}
+		n.template = tmpl
+	default:
+		return fmt.Errorf("invalid log format: %s", n.config.Format)
+	}
+
+	if n.config.MaxAge != "" {
+		maxAge, err := time.ParseDuration(n.config.MaxAge)
+		if err != nil || maxAge <= 0 {
+			return fmt.Errorf("invalid maxAge: %s", n.config.MaxAge)
+		}
+		n.maxAge = maxAge
+	}
+
+	n.flushInterval = defaultLogFlushInterval
+	if n.config.FlushInterval != "" {
+		interval, err := time.ParseDuration(n.config.FlushInterval)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("invalid flushInterval: %s", n.config.FlushInterval)
+		}
+		n.flushInterval = interval
+	}
+
+	return nil
+}
+
+// Start opens the log file and starts the flush loop
+func (n *LogFileNode) Start(ctx context.Context) error {
+	n.mu.Lock()
+	err := n.open()
+	n.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	ctx, n.cancel = context.WithCancel(ctx)
+	n.wg.Add(1)
+	go n.flushLoop(ctx)
+	return nil
+}
+
+// Stop flushes and closes the log file
+func (n *LogFileNode) Stop() {
+	if n.cancel != nil {
+		n.cancel()
+		n.cancel = nil
+	}
+	n.wg.Wait()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err := n.close(); err != nil {
+		n.GetNode().Logger().Warn("failed to close log file", "file", n.config.File, "error", err)
+	}
+}
+
+// OnMessage appends a message to the log file
+func (n *LogFileNode) OnMessage(msg *engine.Message, port int) error {
+	entry, err := n.format(msg)
+	if err != nil {
+		return fmt.Errorf("log-out: %w", err)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.writer == nil {
+		return fmt.Errorf("log-out: file is not open")
+	}
+
+	if n.needsRotation(int64(len(entry))) {
+		if err := n.rotate(); err != nil {
+			return fmt.Errorf("log-out: failed to rotate %s: %w", n.config.File, err)
+		}
+	}
+
+	written, err := n.writer.Write(entry)
+	n.size += int64(written)
+	if err != nil {
+		return fmt.Errorf("log-out: failed to write %s: %w", n.config.File, err)
+	}
+	n.GetNode().Done(msg)
+	return nil
+}
+
+// format renders the log entry of a message
+func (n *LogFileNode) format(msg *engine.Message) ([]byte, error) {
+	headers := make(map[string]string)
+	for _, name := range n.config.Headers {
+		if value, exists := msg.GetHeader(name); exists {
+			headers[name] = value
+		}
+	}
+
+	payload := msg.Payload
+	if data, ok := payload.([]byte); ok {
+		payload = string(data)
+	}
+
+	entry := map[string]interface{}{
+		"timestamp": time.Now().Format(time.RFC3339Nano),
+		"topic":     msg.Topic,
+		"payload":   payload,
+		"headers":   headers,
+	}
+
+	var buf bytes.Buffer
+	if n.template == nil {
+		if len(headers) == 0 {
+			delete(entry, "headers")
+		}
+		// Encode terminates the entry with a newline
+		if err := json.NewEncoder(&buf).Encode(entry); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	if err := n.template.Execute(&buf, entry); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// flushLoop flushes buffered entries on every interval
+func (n *LogFileNode) flushLoop(ctx context.Context) {
+	defer n.wg.Done()
+
+	ticker := time.NewTicker(n.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n.mu.Lock()
+			if n.writer != nil {
+				if err := n.writer.Flush(); err != nil {
+					n.GetNode().Logger().Warn("failed to flush log file", "file", n.config.File, "error", err)
+				}
+			}
+			n.mu.Unlock()
+		}
+	}
+}
+
+// needsRotation reports whether writing size more bytes requires rotation
+func (n *LogFileNode) needsRotation(size int64) bool {
+	if n.config.MaxSize > 0 && n.size > 0 && n.size+size > n.config.MaxSize {
+		return true
+	}
+	return n.maxAge > 0 && time.Since(n.openedAt) > n.maxAge
+}
+
+// open opens the log file for appending
+func (n *LogFileNode) open() error {
+	if err := os.MkdirAll(filepath.Dir(n.config.File), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(n.config.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	n.file = file
+	n.writer = bufio.NewWriter(file)
+	n.size = info.Size()
+	n.openedAt = time.Now()
+	return nil
+}
+
+// close flushes and closes the log file
+func (n *LogFileNode) close() error {
+	if n.file == nil {
+		return nil
+	}
+
+	flushErr := n.writer.Flush()
+	closeErr := n.file.Close()
+	n.file = nil
+	n.writer = nil
+
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
+
+// rotate moves the current file aside, reopens it and prunes old files
+func (n *LogFileNode) rotate() error {
+	if err := n.close(); err != nil {
+		return err
+	}
+
+	rotated := fmt.Sprintf("%s.%s", n.config.File, time.Now().Format("20060102-150405.000000000"))
+	if err := os.Rename(n.config.File, rotated); err != nil {
+		return err
+	}
+
+	if n.config.Compress {
+		if err := compressFile(rotated); err != nil {
+			n.GetNode().Logger().Warn("failed to compress rotated log file", "file", rotated, "error", err)
+		}
+	}
+
+	if err := n.prune(); err != nil {
+		n.GetNode().Logger().Warn("failed to remove old log files", "error", err)
+	}
+
+	return n.open()
+}
+
+// prune removes rotated files beyond the retention count
+func (n *LogFileNode) prune() error {
+	matches, err := filepath.Glob(n.config.File + ".*")
+	if err != nil {
+		return err
+	}
+
+	// Rotated names embed a sortable timestamp, so oldest come first
+	sort.Strings(matches)
+	for len(matches) > n.config.MaxFiles {
+		if err := os.Remove(matches[0]); err != nil {
+			return err
+		}
+		matches = matches[1:]
+	}
+	return nil
+}
+
+// compressFile gzips a file and removes the original
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,326 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// Metric types
+const (
+	MetricCounter   = "counter"
+	MetricGauge     = "gauge"
+	MetricTimer     = "timer"
+	MetricHistogram = "histogram"
+)
+
+// Metric formats
+const (
+	MetricFormatStatsD    = "statsd"
+	MetricFormatDogStatsD = "dogstatsd"
+)
+
+// metricTypeCodes maps metric types to their StatsD type codes
+var metricTypeCodes = map[string]string{
+	MetricCounter:   "c",
+	MetricGauge:     "g",
+	MetricTimer:     "ms",
+	MetricHistogram: "h",
+}
+
+// metricNamePattern matches valid metric names
+var metricNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.\-]*$`)
+
+// MetricConfig represents the configuration of a metric-out node
+type MetricConfig struct {
+	// Network is "udp" or "unixgram"
+	Network string `json:"network"`
+	// Address is host:port for UDP or a socket path for UDS
+	Address string `json:"address"`
+	// Format is "statsd" or "dogstatsd"; only DogStatsD carries tags
+	Format string `json:"format"`
+	// Type is counter, gauge, timer or histogram
+	Type   string `json:"type"`
+	Prefix string `json:"prefix"`
+	// Name is the metric name template, e.g. "orders.{{topic}}"
+	Name string `json:"name"`
+	// NameProperty takes the metric name from a message property instead
+	NameProperty string `json:"nameProperty"`
+	// Value is the property holding the value
+	Value      string  `json:"value"`
+	SampleRate float64 `json:"sampleRate"`
+	// Tags maps tag names to message property paths
+	Tags map[string]string `json:"tags"`
+	// StaticTags are added to every metric, e.g. ["env:prod"]
+	StaticTags []string `json:"staticTags"`
+	// FlushInterval is how long metrics are buffered before sending
+	FlushInterval string `json:"flushInterval"`
+	// MaxPacketSize bounds the buffered payload of a single packet
+	MaxPacketSize int `json:"maxPacketSize"`
+}
+
+// MetricNode sends message values as StatsD or DogStatsD metrics
+type MetricNode struct {
+	engine.BaseNode
+	config        MetricConfig
+	typeCode      string
+	flushInterval time.Duration
+
+	conn   net.Conn
+	buffer bytes.Buffer
+	warned map[string]bool
+	mu     sync.Mutex
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// RegisterMetricNode registers the metric-out node type
+func RegisterMetricNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "metric-out",
+		Description: "Sends metrics to StatsD or DogStatsD",
+		Category:    "output",
+		Defaults:    json.RawMessage(`{"network":"udp","address":"127.0.0.1:8125","format":"statsd","type":"counter","name":"{{topic}}","value":"payload","sampleRate":1,"flushInterval":"1s","maxPacketSize":1432}`),
+		Factory: func() engine.NodeInstance {
+			return &MetricNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *MetricNode) Init(config json.RawMessage) error {
+	n.config = MetricConfig{
+		Network:       "udp",
+		Address:       "127.0.0.1:8125",
+		Format:        MetricFormatStatsD,
+		Type:          MetricCounter,
+		Value:         "payload",
+		SampleRate:    1,
+		FlushInterval: "1s",
+		MaxPacketSize: 1432,
+	}
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &n.config); err != nil {
+			return fmt.Errorf("invalid metric-out config: %w", err)
+		}
+	}
+
+	switch n.config.Network {
+	case "udp", "unixgram":
+	default:
+		return fmt.Errorf("invalid network: %s", n.config.Network)
+	}
+	switch n.config.Format {
+	case MetricFormatStatsD, MetricFormatDogStatsD:
+	default:
+		return fmt.Errorf("invalid format: %s", n.config.Format)
+	}
+	typeCode, ok := metricTypeCodes[n.config.Type]
+	if !ok {
+		return fmt.Errorf("invalid metric type: %s", n.config.Type)
+	}
+	n.typeCode = typeCode
+
+	if n.config.Name == "" && n.config.NameProperty == "" {
+		return fmt.Errorf("name or nameProperty is required")
+	}
+	if n.config.SampleRate <= 0 || n.config.SampleRate > 1 {
+		return fmt.Errorf("sampleRate must be in (0, 1]")
+	}
+	if n.config.MaxPacketSize <= 0 {
+		n.config.MaxPacketSize = 1432
+	}
+
+	flushInterval, err := time.ParseDuration(n.config.FlushInterval)
+	if err != nil || flushInterval < 0 {
+		return fmt.Errorf("invalid flushInterval: %s", n.config.FlushInterval)
+	}
+	n.flushInterval = flushInterval
+
+	return nil
+}
+
+// Start connects to the agent and starts the flush timer
+func (n *MetricNode) Start(ctx context.Context) error {
+	conn, err := net.Dial(n.config.Network, n.config.Address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", n.config.Address, err)
+	}
+
+	n.mu.Lock()
+	n.conn = conn
+	n.warned = make(map[string]bool)
+	n.mu.Unlock()
+
+	if n.flushInterval > 0 {
+		ctx, n.cancel = context.WithCancel(ctx)
+		n.wg.Add(1)
+		go n.run(ctx)
+	}
+	return nil
+}
+
+// Stop flushes buffered metrics and closes the connection
+func (n *MetricNode) Stop() {
+	if n.cancel != nil {
+		n.cancel()
+		n.cancel = nil
+	}
+	n.wg.Wait()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.flushLocked()
+	if n.conn != nil {
+		n.conn.Close()
+		n.conn = nil
+	}
+}
+
+// OnMessage buffers a metric built from the message
+func (n *MetricNode) OnMessage(msg *engine.Message, port int) error {
+	name := n.metricName(msg)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if !metricNamePattern.MatchString(name) {
+		n.warnOnce(name, "invalid metric name")
+		return nil
+	}
+	raw, _ := msg.GetProperty(n.config.Value)
+	value, ok := engine.ToFloat(raw)
+	if !ok {
+		n.warnOnce(name, "metric has a non-numeric value", "type", fmt.Sprintf("%T", raw))
+		return nil
+	}
+
+	line := n.formatLine(name, value, msg)
+	if n.buffer.Len() > 0 && n.buffer.Len()+1+len(line) > n.config.MaxPacketSize {
+		n.flushLocked()
+	}
+	if n.buffer.Len() > 0 {
+		n.buffer.WriteByte('\n')
+	}
+	n.buffer.WriteString(line)
+
+	if n.flushInterval == 0 {
+		n.flushLocked()
+	}
+	return nil
+}
+
+// run flushes the buffer at every interval
+func (n *MetricNode) run(ctx context.Context) {
+	defer n.wg.Done()
+
+	ticker := time.NewTicker(n.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n.mu.Lock()
+			n.flushLocked()
+			n.mu.Unlock()
+		}
+	}
+}
+
+// metricName returns the prefixed metric name for a message
+func (n *MetricNode) metricName(msg *engine.Message) string {
+	var name string
+	if n.config.NameProperty != "" {
+		if value, exists := msg.GetProperty(n.config.NameProperty); exists {
+			name = fmt.Sprintf("%v", value)
+		}
+	} else {
+		name = expandKeyTemplate(n.config.Name, msg)
+	}
+
+	if n.config.Prefix != "" {
+		name = n.config.Prefix + "." + name
+	}
+	return name
+}
+
+// formatLine renders a metric in StatsD or DogStatsD format
+func (n *MetricNode) formatLine(name string, value float64, msg *engine.Message) string {
+	var line strings.Builder
+	line.WriteString(name)
+	line.WriteByte(':')
+	line.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+	line.WriteByte('|')
+	line.WriteString(n.typeCode)
+	if n.config.SampleRate < 1 {
+		line.WriteString("|@")
+		line.WriteString(strconv.FormatFloat(n.config.SampleRate, 'f', -1, 64))
+	}
+
+	if n.config.Format == MetricFormatDogStatsD {
+		if tags := n.tags(msg); len(tags) > 0 {
+			line.WriteString("|#")
+			line.WriteString(strings.Join(tags, ","))
+		}
+	}
+	return line.String()
+}
+
+// tags assembles the static and message derived tags. Metrics of flows
+// outside the default workspace are tagged with their workspace.
+func (n *MetricNode) tags(msg *engine.Message) []string {
+	tags := append([]string{}, n.config.StaticTags...)
+	if flow := n.GetNode().GetFlow(); flow != nil && flow.Workspace() != engine.DefaultWorkspace {
+		tags = append(tags, "workspace:"+flow.Workspace())
+	}
+
+	names := make([]string, 0, len(n.config.Tags))
+	for name := range n.config.Tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value, exists := msg.GetProperty(n.config.Tags[name])
+		if !exists {
+			continue
+		}
+		// Separators would corrupt the tag list
+		tag := strings.NewReplacer(",", "_", "|", "_", "\n", "_").Replace(fmt.Sprintf("%v", value))
+		tags = append(tags, name+":"+tag)
+	}
+	return tags
+}
+
+// flushLocked sends the buffered metrics; n.mu must be held
+func (n *MetricNode) flushLocked() {
+	if n.buffer.Len() == 0 || n.conn == nil {
+		return
+	}
+	if _, err := n.conn.Write(n.buffer.Bytes()); err != nil {
+		n.GetNode().Logger().Warn("failed to send metrics", "error", err)
+	}
+	n.buffer.Reset()
+}
+
+// warnOnce logs a problem once per metric name; n.mu must be held
+func (n *MetricNode) warnOnce(name, message string, args ...interface{}) {
+	if n.warned[name] {
+		return
+	}
+	n.warned[name] = true
+	n.GetNode().Logger().Warn(message, append([]interface{}{"metric", name}, args...)...)
+}
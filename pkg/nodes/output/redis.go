@@ -0,0 +1,153 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/pkg/nodes/input"
+)
+
+// RedisCommandConfig represents the configuration of a redis node
+type RedisCommandConfig struct {
+	input.RedisConnection
+	// Command is a command such as "GET", "SET", "HSET" or "LPUSH". Without
+	// it the payload holds the whole command, e.g. ["INCRBY", "hits", 2].
+	Command string `json:"command"`
+	// Key is a template like the s3-out key, e.g. "sensor:{{payload.id}}";
+	// empty for commands without a key
+	Key string `json:"key"`
+	// Args follow the key. Each is a template; an argument that is a single
+	// property placeholder such as "{{payload}}" passes the value itself,
+	// with objects as JSON.
+	Args    []string `json:"args"`
+	Timeout string   `json:"timeout" validate:"duration"`
+}
+
+// RedisCommandNode runs a command for every message and sends the reply
+// as payload. Failures, including error replies, are sent on the second
+// output with the "error" metadata.
+type RedisCommandNode struct {
+	engine.BaseNode
+	config  RedisCommandConfig
+	timeout time.Duration
+
+	client *input.RedisClient
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// RegisterRedisCommandNode registers the redis node type
+func RegisterRedisCommandNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "redis",
+		Description: "Runs Redis commands",
+		Category:    "output",
+		Defaults:    json.RawMessage(`{"address":"localhost:6379","command":"GET","key":"{{topic}}","timeout":"5s"}`),
+		Credentials: []string{"password"},
+		Parallel:    true,
+		Factory: func() engine.NodeInstance {
+			return &RedisCommandNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *RedisCommandNode) Init(config json.RawMessage) error {
+	if err := n.LoadConfig(config, &n.config); err != nil {
+		return err
+	}
+	timeout, err := time.ParseDuration(n.config.Timeout)
+	if err != nil || timeout <= 0 {
+		return fmt.Errorf("invalid timeout: %s", n.config.Timeout)
+	}
+	n.timeout = timeout
+	return nil
+}
+
+// Start joins the connection of the settings
+func (n *RedisCommandNode) Start(ctx context.Context) error {
+	n.client = input.AcquireRedisClient(n.config.RedisConnection)
+	n.ctx, n.cancel = context.WithCancel(ctx)
+	return nil
+}
+
+// Stop cancels a running command and leaves the connection
+func (n *RedisCommandNode) Stop() {
+	if n.cancel != nil {
+		n.cancel()
+	}
+	if n.client != nil {
+		n.client.Release()
+		n.client = nil
+	}
+}
+
+// OnMessage runs the command and replaces the payload with the reply
+func (n *RedisCommandNode) OnMessage(msg *engine.Message, port int) error {
+	args, err := n.args(msg)
+	if err != nil {
+		msg.SetMetadata("error", err.Error())
+		return n.GetNode().Send(msg, 1)
+	}
+
+	ctx, cancel := context.WithTimeout(n.ctx, n.timeout)
+	defer cancel()
+	reply, err := n.client.Do(ctx, args...)
+	if err != nil {
+		msg.SetMetadata("error", err.Error())
+		return n.GetNode().Send(msg, 1)
+	}
+	msg.Payload = reply
+	return n.GetNode().Send(msg, 0)
+}
+
+// args returns the command to run for a message
+func (n *RedisCommandNode) args(msg *engine.Message) ([]interface{}, error) {
+	if n.config.Command == "" {
+		command, ok := msg.Payload.([]interface{})
+		if !ok || len(command) == 0 {
+			return nil, fmt.Errorf("payload must be an array holding a command")
+		}
+		return command, nil
+	}
+
+	args := []interface{}{n.config.Command}
+	if n.config.Key != "" {
+		key := expandRedisTemplate(n.config.Key, msg)
+		if key == "" {
+			return nil, fmt.Errorf("key template %q expanded to an empty key", n.config.Key)
+		}
+		args = append(args, key)
+	}
+	for _, arg := range n.config.Args {
+		args = append(args, expandRedisArg(arg, msg))
+	}
+	return args, nil
+}
+
+// expandRedisArg expands an argument template, passing the value of a
+// lone property placeholder unchanged
+func expandRedisArg(tmpl string, msg *engine.Message) interface{} {
+	if match := keyTemplatePattern.FindStringSubmatch(tmpl); match != nil && match[0] == strings.TrimSpace(tmpl) {
+		if value, exists := msg.GetProperty(match[1]); exists {
+			return value
+		}
+	}
+	return expandRedisTemplate(tmpl, msg)
+}
+
+// expandRedisTemplate expands a template like expandKeyTemplate, keeping
+// slashes, which are common in Redis keys
+func expandRedisTemplate(tmpl string, msg *engine.Message) string {
+	return keyTemplatePattern.ReplaceAllStringFunc(tmpl, func(match string) string {
+		name := keyTemplatePattern.FindStringSubmatch(match)[1]
+		if value, exists := msg.GetProperty(name); exists {
+			return fmt.Sprintf("%v", value)
+		}
+		return expandKeyTemplate(match, msg)
+	})
+}
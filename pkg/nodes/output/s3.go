@@ -0,0 +1,371 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// S3 node modes
+const (
+	S3ModePut = "put"
+	S3ModeGet = "get"
+)
+
+const (
+	defaultS3Endpoint = "s3.amazonaws.com"
+	defaultS3PartSize = 16 << 20
+	defaultS3Retries  = 3
+)
+
+// keyTemplatePattern matches {{name}} placeholders in key templates
+var keyTemplatePattern = regexp.MustCompile(`\{\{\s*([^{}\s]+)\s*\}\}`)
+
+// S3Config represents the configuration of an s3-out node
+type S3Config struct {
+	// Mode is put (upload the payload) or get (download into the payload)
+	Mode string `json:"mode"`
+	// Endpoint is the S3 or MinIO host, e.g. "localhost:9000"
+	Endpoint string `json:"endpoint"`
+	Region   string `json:"region"`
+	UseSSL   bool   `json:"useSSL"`
+	Bucket   string `json:"bucket"`
+	// Key is a template such as "logs/{{date}}/{{topic}}.json"; supported
+	// placeholders are date, time, timestamp, msgId and message property
+	// paths like payload.id
+	Key         string `json:"key"`
+	ContentType string `json:"contentType"`
+	// AccessKey and SecretKey are optional; without them credentials come
+	// from the environment or the instance metadata service
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey"`
+	// PartSize is the multipart upload part size in bytes
+	PartSize uint64 `json:"partSize"`
+	Retries  int    `json:"retries"`
+	// Stream makes get emit the object as an engine.Stream instead of
+	// reading it into memory
+	Stream bool `json:"stream"`
+	// NetworkProfile selects the proxy and TLS settings; empty for the
+	// default profile
+	NetworkProfile string `json:"networkProfile"`
+}
+
+// S3Node uploads message payloads to, or downloads them from, S3 compatible
+// object storage
+type S3Node struct {
+	engine.BaseNode
+	config S3Config
+	client *minio.Client
+	ctx    context.Context
+}
+
+// RegisterS3Node registers the s3-out node type
+func RegisterS3Node(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "s3-out",
+		Description: "Uploads payloads to or downloads them from S3 compatible storage",
+		Category:    "output",
+		Defaults:    json.RawMessage(`{"mode":"put","endpoint":"s3.amazonaws.com","useSSL":true,"key":"{{date}}/{{msgId}}"}`),
+		Credentials: []string{"secretKey"},
+		Parallel:    true,
+		Factory: func() engine.NodeInstance {
+			return &S3Node{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *S3Node) Init(config json.RawMessage) error {
+	n.config = S3Config{
+		Mode:     S3ModePut,
+		Endpoint: defaultS3Endpoint,
+		UseSSL:   true,
+		PartSize: defaultS3PartSize,
+		Retries:  defaultS3Retries,
+	}
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &n.config); err != nil {
+			return fmt.Errorf("invalid s3-out config: %w", err)
+		}
+	}
+
+	switch n.config.Mode {
+	case S3ModePut, S3ModeGet:
+	default:
+		return fmt.Errorf("invalid s3 mode: %s", n.config.Mode)
+	}
+	if n.config.Bucket == "" {
+		return fmt.Errorf("bucket is required")
+	}
+	if n.config.Key == "" {
+		return fmt.Errorf("key is required")
+	}
+	if n.config.Retries < 0 {
+		n.config.Retries = 0
+	}
+
+	return nil
+}
+
+// Start creates the storage client
+func (n *S3Node) Start(ctx context.Context) error {
+	var creds *credentials.Credentials
+	if n.config.AccessKey != "" {
+		creds = credentials.NewStaticV4(n.config.AccessKey, n.config.SecretKey, "")
+	} else {
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.IAM{Client: &http.Client{Timeout: 5 * time.Second}},
+		})
+	}
+
+	httpClient, err := n.GetNode().GetFlow().GetEngine().HTTPClient(n.config.NetworkProfile)
+	if err != nil {
+		return err
+	}
+
+	client, err := minio.New(n.config.Endpoint, &minio.Options{
+		Creds:     creds,
+		Secure:    n.config.UseSSL,
+		Region:    n.config.Region,
+		Transport: httpClient.Transport,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create s3 client: %w", err)
+	}
+
+	n.client = client
+	n.ctx = ctx
+	return nil
+}
+
+// Stop stops the node
+func (n *S3Node) Stop() {
+	n.client = nil
+}
+
+// OnMessage uploads or downloads an object
+func (n *S3Node) OnMessage(msg *engine.Message, port int) error {
+	key := expandKeyTemplate(n.config.Key, msg)
+	if key == "" {
+		return fmt.Errorf("s3-out: key template %q expanded to an empty key", n.config.Key)
+	}
+
+	if n.config.Mode == S3ModeGet {
+		return n.get(msg, key)
+	}
+	return n.put(msg, key)
+}
+
+// put uploads the payload, retrying transient errors
+func (n *S3Node) put(msg *engine.Message, key string) error {
+	if stream, ok := msg.Payload.(*engine.Stream); ok {
+		return n.putStream(msg, key, stream)
+	}
+
+	data, contentType, err := payloadBytes(msg.Payload)
+	if err != nil {
+		return fmt.Errorf("s3-out: %w", err)
+	}
+	if n.config.ContentType != "" {
+		contentType = n.config.ContentType
+	}
+
+	var info minio.UploadInfo
+	err = n.retry(func() error {
+		var err error
+		info, err = n.client.PutObject(n.ctx, n.config.Bucket, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+			ContentType: contentType,
+			PartSize:    n.config.PartSize,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("s3-out: failed to upload %s/%s: %w", n.config.Bucket, key, err)
+	}
+
+	msg.SetMetadata("s3", map[string]interface{}{
+		"bucket": n.config.Bucket,
+		"key":    key,
+		"etag":   info.ETag,
+		"size":   info.Size,
+	})
+	return n.GetNode().Send(msg, 0)
+}
+
+// putStream uploads a stream payload without buffering it. A stream cannot
+// be rewound, so failed uploads are not retried; the payload of the
+// forwarded message is cleared because the stream has been consumed.
+func (n *S3Node) putStream(msg *engine.Message, key string, stream *engine.Stream) error {
+	defer stream.Close()
+
+	contentType := stream.ContentType
+	if n.config.ContentType != "" {
+		contentType = n.config.ContentType
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	info, err := n.client.PutObject(n.ctx, n.config.Bucket, key, stream, stream.Length, minio.PutObjectOptions{
+		ContentType: contentType,
+		PartSize:    n.config.PartSize,
+	})
+	if err != nil {
+		return fmt.Errorf("s3-out: failed to upload %s/%s: %w", n.config.Bucket, key, err)
+	}
+
+	msg.SetPayload(nil)
+	msg.SetMetadata("s3", map[string]interface{}{
+		"bucket": n.config.Bucket,
+		"key":    key,
+		"etag":   info.ETag,
+		"size":   info.Size,
+	})
+	return n.GetNode().Send(msg, 0)
+}
+
+// get downloads an object into the payload
+func (n *S3Node) get(msg *engine.Message, key string) error {
+	if n.config.Stream {
+		return n.getStream(msg, key)
+	}
+
+	var data []byte
+	var info minio.ObjectInfo
+	err := n.retry(func() error {
+		object, err := n.client.GetObject(n.ctx, n.config.Bucket, key, minio.GetObjectOptions{})
+		if err != nil {
+			return err
+		}
+		defer object.Close()
+
+		if info, err = object.Stat(); err != nil {
+			return err
+		}
+		data, err = ioutil.ReadAll(object)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("s3-out: failed to download %s/%s: %w", n.config.Bucket, key, err)
+	}
+
+	msg.SetPayload(data)
+	msg.SetMetadata("s3", map[string]interface{}{
+		"bucket":      n.config.Bucket,
+		"key":         key,
+		"etag":        info.ETag,
+		"size":        info.Size,
+		"contentType": info.ContentType,
+	})
+	return n.GetNode().Send(msg, 0)
+}
+
+// getStream emits an object as a stream payload; the object is read as the
+// stream is consumed further down the flow
+func (n *S3Node) getStream(msg *engine.Message, key string) error {
+	var object *minio.Object
+	var info minio.ObjectInfo
+	err := n.retry(func() error {
+		var err error
+		if object, err = n.client.GetObject(n.ctx, n.config.Bucket, key, minio.GetObjectOptions{}); err != nil {
+			return err
+		}
+		if info, err = object.Stat(); err != nil {
+			object.Close()
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("s3-out: failed to download %s/%s: %w", n.config.Bucket, key, err)
+	}
+
+	msg.SetPayload(engine.NewStream(object, info.Size, info.ContentType))
+	msg.SetMetadata("s3", map[string]interface{}{
+		"bucket":      n.config.Bucket,
+		"key":         key,
+		"etag":        info.ETag,
+		"size":        info.Size,
+		"contentType": info.ContentType,
+	})
+	return n.GetNode().Send(msg, 0)
+}
+
+// retry runs fn, retrying transient errors with exponential backoff
+func (n *S3Node) retry(fn func() error) error {
+	backoff := 500 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= n.config.Retries || !isTransientS3Error(err) {
+			return err
+		}
+
+		select {
+		case <-n.ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// isTransientS3Error reports whether an error is worth retrying
+func isTransientS3Error(err error) bool {
+	response := minio.ToErrorResponse(err)
+	if response.StatusCode == 0 {
+		return true // network error
+	}
+	return response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests
+}
+
+// payloadBytes converts a payload to bytes and a default content type
+func payloadBytes(payload interface{}) ([]byte, string, error) {
+	switch v := payload.(type) {
+	case []byte:
+		return v, "application/octet-stream", nil
+	case string:
+		return []byte(v), "text/plain", nil
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to encode payload: %w", err)
+		}
+		return data, "application/json", nil
+	}
+}
+
+// expandKeyTemplate replaces {{placeholders}} in an object key template
+func expandKeyTemplate(tmpl string, msg *engine.Message) string {
+	now := time.Now().UTC()
+	return keyTemplatePattern.ReplaceAllStringFunc(tmpl, func(match string) string {
+		name := keyTemplatePattern.FindStringSubmatch(match)[1]
+		switch name {
+		case "date":
+			return now.Format("2006-01-02")
+		case "time":
+			return now.Format("150405")
+		case "timestamp":
+			return fmt.Sprintf("%d", now.Unix())
+		case "msgId":
+			return msg.MsgID
+		}
+
+		value, exists := msg.GetProperty(name)
+		if !exists {
+			return ""
+		}
+		return strings.ReplaceAll(fmt.Sprintf("%v", value), "/", "_")
+	})
+}
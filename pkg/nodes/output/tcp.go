@@ -0,0 +1,220 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/pkg/nodes/input"
+)
+
+// TCP output modes
+const (
+	TCPModeClient = "client"
+	TCPModeReply  = "reply"
+)
+
+// tcpTimeout bounds connecting to the remote host and writing to it
+const tcpTimeout = 10 * time.Second
+
+// TCPOutputConfig represents the configuration of a tcp-out node
+type TCPOutputConfig struct {
+	// Mode is "client" to write to Address, or "reply" to write to the
+	// connection a tcp-in node received the message on
+	Mode    string `json:"mode" validate:"oneof=client reply"`
+	Address string `json:"address"`
+	// Framing is applied to every payload written: "stream" writes it as
+	// is, "connection" closes the connection after it
+	input.TCPFraming
+	// Close closes the connection after every message
+	Close bool `json:"close"`
+	// ReconnectConfig holds the backoff of the client reconnecting
+	engine.ReconnectConfig
+}
+
+// TCPOutputNode writes payloads to a TCP connection. In client mode it
+// keeps a connection to the remote host, reconnecting with backoff, or
+// opens one per message if connections close after every message.
+type TCPOutputNode struct {
+	engine.BaseNode
+	config     TCPOutputConfig
+	closeAfter bool
+	backoff    *engine.Backoff
+
+	conn net.Conn
+	mu   sync.Mutex
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// RegisterTCPOutputNode registers the tcp-out node type
+func RegisterTCPOutputNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "tcp-out",
+		Description: "Writes payloads to TCP connections",
+		Category:    "output",
+		Defaults:    json.RawMessage(`{"mode":"client","framing":"stream","delimiter":"\n","prefixBytes":4,"maxFrameSize":1048576,"reconnectDelay":"1s","maxReconnectDelay":"30s"}`),
+		Factory: func() engine.NodeInstance {
+			return &TCPOutputNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *TCPOutputNode) Init(config json.RawMessage) error {
+	if err := n.LoadConfig(config, &n.config); err != nil {
+		return err
+	}
+	if err := n.config.Check(); err != nil {
+		return err
+	}
+	if n.config.Mode == TCPModeClient && n.config.Address == "" {
+		return fmt.Errorf("client mode needs an address")
+	}
+	n.closeAfter = n.config.Close || n.config.Framing == input.TCPFramingConnection
+
+	backoff, err := n.config.Backoff()
+	if err != nil {
+		return err
+	}
+	n.backoff = backoff
+	return nil
+}
+
+// Start starts connecting in client mode
+func (n *TCPOutputNode) Start(ctx context.Context) error {
+	ctx, n.cancel = context.WithCancel(ctx)
+	if n.config.Mode == TCPModeClient && !n.closeAfter {
+		n.wg.Add(1)
+		go n.connect(ctx)
+	}
+	return nil
+}
+
+// Stop closes the connection
+func (n *TCPOutputNode) Stop() {
+	if n.cancel != nil {
+		n.cancel()
+	}
+	n.mu.Lock()
+	if n.conn != nil {
+		n.conn.Close()
+	}
+	n.mu.Unlock()
+	n.wg.Wait()
+}
+
+// OnMessage writes the framed payload
+func (n *TCPOutputNode) OnMessage(msg *engine.Message, port int) error {
+	var data []byte
+	var err error
+	if stream, ok := msg.Payload.(*engine.Stream); ok {
+		data, err = stream.Bytes()
+	} else {
+		data, _, err = payloadBytes(msg.Payload)
+	}
+	if err != nil {
+		return fmt.Errorf("tcp-out: %w", err)
+	}
+	if data, err = n.config.Frame(data); err != nil {
+		return fmt.Errorf("tcp-out: %w", err)
+	}
+
+	if n.config.Mode == TCPModeReply {
+		value, _ := msg.GetMetadata(input.MetadataTCPConnection)
+		id, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("tcp-out: message has no %s", input.MetadataTCPConnection)
+		}
+		if err := input.WriteTCP(id, data, n.closeAfter); err != nil {
+			return fmt.Errorf("tcp-out: %w", err)
+		}
+		n.GetNode().Done(msg)
+		return nil
+	}
+
+	if n.closeAfter {
+		conn, err := net.DialTimeout("tcp", n.config.Address, tcpTimeout)
+		if err != nil {
+			return fmt.Errorf("tcp-out: failed to connect to %s: %w", n.config.Address, err)
+		}
+		defer conn.Close()
+		conn.SetWriteDeadline(time.Now().Add(tcpTimeout))
+		if _, err := conn.Write(data); err != nil {
+			return fmt.Errorf("tcp-out: failed to write to %s: %w", n.config.Address, err)
+		}
+		n.GetNode().Done(msg)
+		return nil
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.conn == nil {
+		return fmt.Errorf("tcp-out: not connected to %s", n.config.Address)
+	}
+	n.conn.SetWriteDeadline(time.Now().Add(tcpTimeout))
+	if _, err := n.conn.Write(data); err != nil {
+		// Closing the connection makes connect reconnect
+		n.conn.Close()
+		return fmt.Errorf("tcp-out: failed to write to %s: %w", n.config.Address, err)
+	}
+	n.GetNode().Done(msg)
+	return nil
+}
+
+// connect keeps a connection to the remote host open, reconnecting with
+// backoff until the node stops
+func (n *TCPOutputNode) connect(ctx context.Context) {
+	defer n.wg.Done()
+	n.setStatus(false)
+
+	dialer := net.Dialer{Timeout: tcpTimeout}
+	n.backoff.Reset()
+	for {
+		n.backoff.Start()
+		conn, err := dialer.DialContext(ctx, "tcp", n.config.Address)
+		if err == nil {
+			n.mu.Lock()
+			n.conn = conn
+			n.mu.Unlock()
+			n.setStatus(true)
+
+			// Reading notices the remote host closing the connection
+			io.Copy(io.Discard, conn)
+
+			n.mu.Lock()
+			conn.Close()
+			n.conn = nil
+			n.mu.Unlock()
+			n.setStatus(false)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		delay := n.backoff.Next()
+		if err != nil {
+			n.GetNode().Logger().Warn("failed to connect, retrying", "address", n.config.Address, "error", err, "delay", delay)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// setStatus reports the connection as node status
+func (n *TCPOutputNode) setStatus(connected bool) {
+	status := engine.NodeStatus{Fill: "red", Shape: "ring", Text: "disconnected"}
+	if connected {
+		status = engine.NodeStatus{Fill: "green", Shape: "dot", Text: "connected"}
+	}
+	n.GetNode().SetStatus(status)
+}
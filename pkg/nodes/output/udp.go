@@ -0,0 +1,116 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// UDPOutputConfig represents the configuration of a udp-out node
+type UDPOutputConfig struct {
+	// Address is the host:port datagrams are sent to. Without it they go
+	// to the address in the message's "remoteAddr" metadata, which udp-in
+	// nodes set to the sender's address.
+	Address string `json:"address"`
+	// Broadcast sends over IPv4 with broadcasting allowed; an address
+	// without host, e.g. ":5140", broadcasts to 255.255.255.255
+	Broadcast bool `json:"broadcast"`
+}
+
+// UDPOutputNode sends payloads as UDP datagrams
+type UDPOutputNode struct {
+	engine.BaseNode
+	config UDPOutputConfig
+	addr   *net.UDPAddr
+
+	conn *net.UDPConn
+}
+
+// RegisterUDPOutputNode registers the udp-out node type
+func RegisterUDPOutputNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "udp-out",
+		Description: "Sends payloads as UDP datagrams",
+		Category:    "output",
+		Parallel:    true,
+		Factory: func() engine.NodeInstance {
+			return &UDPOutputNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *UDPOutputNode) Init(config json.RawMessage) error {
+	return n.LoadConfig(config, &n.config)
+}
+
+// Start resolves the address and opens the socket
+func (n *UDPOutputNode) Start(ctx context.Context) error {
+	network := "udp"
+	if n.config.Broadcast {
+		network = "udp4"
+	}
+
+	n.addr = nil
+	if n.config.Address != "" {
+		addr, err := net.ResolveUDPAddr(network, n.config.Address)
+		if err != nil {
+			return fmt.Errorf("invalid address %s: %w", n.config.Address, err)
+		}
+		if addr.IP == nil && n.config.Broadcast {
+			addr.IP = net.IPv4bcast
+		}
+		n.addr = addr
+	}
+
+	// Go allows broadcasting on UDP sockets
+	conn, err := net.ListenUDP(network, nil)
+	if err != nil {
+		return fmt.Errorf("failed to open socket: %w", err)
+	}
+	n.conn = conn
+	return nil
+}
+
+// Stop closes the socket
+func (n *UDPOutputNode) Stop() {
+	if n.conn != nil {
+		n.conn.Close()
+	}
+}
+
+// OnMessage sends the payload as a datagram
+func (n *UDPOutputNode) OnMessage(msg *engine.Message, port int) error {
+	addr := n.addr
+	if addr == nil {
+		value, _ := msg.GetMetadata("remoteAddr")
+		remote, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("udp-out: no address configured and message has no remoteAddr")
+		}
+		var err error
+		if addr, err = net.ResolveUDPAddr("udp", remote); err != nil {
+			return fmt.Errorf("udp-out: invalid remoteAddr %s: %w", remote, err)
+		}
+	}
+
+	var data []byte
+	var err error
+	if stream, ok := msg.Payload.(*engine.Stream); ok {
+		data, err = stream.Bytes()
+	} else {
+		data, _, err = payloadBytes(msg.Payload)
+	}
+	if err != nil {
+		return fmt.Errorf("udp-out: %w", err)
+	}
+
+	if _, err := n.conn.WriteToUDP(data, addr); err != nil {
+		return fmt.Errorf("udp-out: failed to send to %s: %w", addr, err)
+	}
+	n.GetNode().Done(msg)
+	return nil
+}
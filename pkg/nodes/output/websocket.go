@@ -0,0 +1,155 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/pkg/nodes/input"
+)
+
+// WebSocket output modes
+const (
+	WebSocketModeClient = input.WebSocketModeClient
+	WebSocketModeServer = input.WebSocketModeServer
+	WebSocketModeReply  = "reply"
+)
+
+// WebSocketOutputConfig represents the configuration of a websocket-out
+// node
+type WebSocketOutputConfig struct {
+	// Mode is "client" to send to the server at URL, "server" to send to
+	// every connection on Path, or "reply" to send to the connection a
+	// websocket-in node received the message on
+	Mode string `json:"mode" validate:"oneof=client server reply"`
+	// Path is served below the prefix of the flow's workspace
+	Path string `json:"path"`
+	// URL is a ws:// or wss:// URL
+	URL string `json:"url"`
+	// Headers are sent with the client's handshake, e.g. Authorization
+	Headers map[string]string `json:"headers"`
+	// Frame is "text", "binary" or "auto", which sends bytes in binary
+	// frames and everything else in text frames
+	Frame string `json:"frame" validate:"oneof=auto text binary"`
+	// ReconnectConfig holds the backoff of the client reconnecting
+	engine.ReconnectConfig
+}
+
+// WebSocketOutputNode sends payloads as WebSocket frames. Objects are sent
+// as JSON.
+type WebSocketOutputNode struct {
+	engine.BaseNode
+	config WebSocketOutputConfig
+
+	listener *input.WebSocketListener
+	client   *input.WebSocketClient
+}
+
+// RegisterWebSocketOutputNode registers the websocket-out node type
+func RegisterWebSocketOutputNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "websocket-out",
+		Description: "Sends payloads as WebSocket frames",
+		Category:    "output",
+		Defaults:    json.RawMessage(`{"mode":"reply","frame":"auto","reconnectDelay":"1s","maxReconnectDelay":"30s"}`),
+		Factory: func() engine.NodeInstance {
+			return &WebSocketOutputNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *WebSocketOutputNode) Init(config json.RawMessage) error {
+	if err := n.LoadConfig(config, &n.config); err != nil {
+		return err
+	}
+	if n.config.Mode != WebSocketModeReply {
+		if err := input.CheckWebSocketEndpoint(n.config.Mode, n.config.Path, n.config.URL); err != nil {
+			return err
+		}
+	}
+
+	n.client = nil
+	if n.config.Mode == WebSocketModeClient {
+		node := n.GetNode()
+		client, err := input.NewWebSocketClient(n.config.URL, n.config.Headers, n.config.ReconnectConfig, node)
+		if err != nil {
+			return err
+		}
+		n.client = client
+	}
+	return nil
+}
+
+// Start serves the path or starts connecting
+func (n *WebSocketOutputNode) Start(ctx context.Context) error {
+	switch n.config.Mode {
+	case WebSocketModeClient:
+		n.client.Start(ctx)
+	case WebSocketModeServer:
+		flow := n.GetNode().GetFlow()
+		listener, err := input.AcquireWebSocketListener(flow.GetEngine().HTTPRoutes(), flow.HTTPPath(n.config.Path))
+		if err != nil {
+			return err
+		}
+		n.listener = listener
+	}
+	return nil
+}
+
+// Stop stops serving the path or closes the connection
+func (n *WebSocketOutputNode) Stop() {
+	if n.listener != nil {
+		n.listener.Release()
+		n.listener = nil
+	}
+	if n.client != nil {
+		n.client.Stop()
+	}
+}
+
+// OnMessage sends the payload as a frame
+func (n *WebSocketOutputNode) OnMessage(msg *engine.Message, port int) error {
+	var data []byte
+	var err error
+	if stream, ok := msg.Payload.(*engine.Stream); ok {
+		data, err = stream.Bytes()
+	} else {
+		data, _, err = payloadBytes(msg.Payload)
+	}
+	if err != nil {
+		return fmt.Errorf("websocket-out: %w", err)
+	}
+
+	messageType := websocket.TextMessage
+	switch n.config.Frame {
+	case "binary":
+		messageType = websocket.BinaryMessage
+	case "auto":
+		switch msg.Payload.(type) {
+		case []byte, *engine.Stream:
+			messageType = websocket.BinaryMessage
+		}
+	}
+
+	switch n.config.Mode {
+	case WebSocketModeClient:
+		err = n.client.Write(messageType, data)
+	case WebSocketModeServer:
+		n.listener.Broadcast(messageType, data)
+	default:
+		value, _ := msg.GetMetadata(input.MetadataWebSocketConnection)
+		id, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("websocket-out: message has no %s", input.MetadataWebSocketConnection)
+		}
+		err = input.WriteWebSocket(id, messageType, data)
+	}
+	if err != nil {
+		return fmt.Errorf("websocket-out: %w", err)
+	}
+	n.GetNode().Done(msg)
+	return nil
+}
@@ -0,0 +1,301 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// AggregateConfig represents the configuration of an aggregate node
+type AggregateConfig struct {
+	// Window is the window size, e.g. "1m"
+	Window string `json:"window"`
+	// Slide is the distance between window starts; empty for tumbling
+	// windows
+	Slide string `json:"slide"`
+	// AllowedLateness is how long a window stays open after its end
+	AllowedLateness string `json:"allowedLateness"`
+	// Property is the path of the numeric value
+	Property string `json:"property"`
+	// FlushOnStop emits open windows on Stop instead of discarding them
+	FlushOnStop bool `json:"flushOnStop"`
+}
+
+// AggregateNode groups numeric values into tumbling or sliding time windows
+// per topic and emits one summary message per closed window. Messages that
+// arrive after all their windows closed are sent to the second output.
+type AggregateNode struct {
+	engine.BaseNode
+	config   AggregateConfig
+	size     time.Duration
+	slide    time.Duration
+	lateness time.Duration
+
+	windows map[aggregateKey]*aggregateWindow
+	mu      sync.Mutex
+	wake    chan struct{}
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// aggregateKey identifies a window of a topic
+type aggregateKey struct {
+	topic string
+	start int64
+}
+
+// aggregateWindow holds the totals of an open window
+type aggregateWindow struct {
+	topic string
+	start time.Time
+	end   time.Time
+	count int64
+	sum   float64
+	min   float64
+	max   float64
+	// releases settle the deliveries of the aggregated messages
+	releases []func(error)
+}
+
+// RegisterAggregateNode registers the aggregate node type
+func RegisterAggregateNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "aggregate",
+		Description: "Aggregates numeric values over time windows",
+		Category:    "process",
+		Defaults:    json.RawMessage(`{"window":"1m","property":"payload"}`),
+		Factory: func() engine.NodeInstance {
+			return &AggregateNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *AggregateNode) Init(config json.RawMessage) error {
+	n.config = AggregateConfig{
+		Window:   "1m",
+		Property: "payload",
+	}
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &n.config); err != nil {
+			return fmt.Errorf("invalid aggregate config: %w", err)
+		}
+	}
+
+	size, err := parseDuration("window", n.config.Window)
+	if err != nil {
+		return err
+	}
+	if size == 0 {
+		return fmt.Errorf("window is required")
+	}
+
+	slide, err := parseDuration("slide", n.config.Slide)
+	if err != nil {
+		return err
+	}
+	if slide == 0 {
+		slide = size
+	}
+	if slide > size {
+		return fmt.Errorf("slide must not be greater than the window size")
+	}
+
+	lateness, err := parseDuration("allowedLateness", n.config.AllowedLateness)
+	if err != nil {
+		return err
+	}
+
+	n.size = size
+	n.slide = slide
+	n.lateness = lateness
+	n.windows = make(map[aggregateKey]*aggregateWindow)
+	n.wake = make(chan struct{}, 1)
+	return nil
+}
+
+// Start starts the window timer
+func (n *AggregateNode) Start(ctx context.Context) error {
+	ctx, n.cancel = context.WithCancel(ctx)
+	n.wg.Add(1)
+	go n.run(ctx)
+	return nil
+}
+
+// Stop stops the window timer and flushes or discards open windows
+func (n *AggregateNode) Stop() {
+	if n.cancel != nil {
+		n.cancel()
+		n.cancel = nil
+	}
+	n.wg.Wait()
+
+	n.mu.Lock()
+	windows := n.windows
+	n.windows = make(map[aggregateKey]*aggregateWindow)
+	n.mu.Unlock()
+
+	if !n.config.FlushOnStop {
+		for _, window := range windows {
+			window.settle(fmt.Errorf("aggregate: window discarded on stop"))
+		}
+		return
+	}
+	for _, window := range windows {
+		window.emit(n.GetNode())
+	}
+}
+
+// OnMessage processes a message
+func (n *AggregateNode) OnMessage(msg *engine.Message, port int) error {
+	raw, _ := msg.GetProperty(n.config.Property)
+	value, ok := engine.ToFloat(raw)
+	if !ok {
+		return fmt.Errorf("aggregate: property %s is not numeric (got %T)", n.config.Property, raw)
+	}
+
+	now := n.GetNode().Clock().Now()
+	at := msg.Timestamp
+	if at.IsZero() {
+		at = now
+	}
+
+	n.mu.Lock()
+	added, created := false, false
+	// Visit every window [start, start+size) containing the event time
+	for start := at.Truncate(n.slide); start.After(at.Add(-n.size)); start = start.Add(-n.slide) {
+		end := start.Add(n.size)
+		if !end.Add(n.lateness).After(now) {
+			continue // already closed
+		}
+
+		key := aggregateKey{topic: msg.Topic, start: start.UnixNano()}
+		window, exists := n.windows[key]
+		if !exists {
+			window = &aggregateWindow{topic: msg.Topic, start: start, end: end, min: math.Inf(1), max: math.Inf(-1)}
+			n.windows[key] = window
+			created = true
+		}
+		window.add(value)
+		window.releases = append(window.releases, msg.Retain())
+		added = true
+	}
+	n.mu.Unlock()
+
+	if !added {
+		msg.SetMetadata("late", true)
+		return n.GetNode().Send(msg, 1)
+	}
+
+	if created {
+		select {
+		case n.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// run emits windows as they close
+func (n *AggregateNode) run(ctx context.Context) {
+	defer n.wg.Done()
+
+	clock := n.GetNode().Clock()
+	for {
+		var timer engine.Timer
+		var fire <-chan time.Time
+		if next, ok := n.nextClose(); ok {
+			timer = clock.NewTimer(next.Sub(clock.Now()))
+			fire = timer.C()
+		}
+
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-n.wake:
+		case <-fire:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+
+		for _, window := range n.closeWindows(clock.Now()) {
+			window.emit(n.GetNode())
+		}
+	}
+}
+
+// nextClose returns the time the earliest open window closes
+func (n *AggregateNode) nextClose() (time.Time, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var next time.Time
+	for _, window := range n.windows {
+		closeAt := window.end.Add(n.lateness)
+		if next.IsZero() || closeAt.Before(next) {
+			next = closeAt
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// closeWindows removes and returns the windows closed at now
+func (n *AggregateNode) closeWindows(now time.Time) []*aggregateWindow {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var closed []*aggregateWindow
+	for key, window := range n.windows {
+		if window.end.Add(n.lateness).After(now) {
+			continue
+		}
+		closed = append(closed, window)
+		delete(n.windows, key)
+	}
+	return closed
+}
+
+// add adds a value to the window totals
+func (w *aggregateWindow) add(value float64) {
+	w.count++
+	w.sum += value
+	w.min = math.Min(w.min, value)
+	w.max = math.Max(w.max, value)
+}
+
+// emit sends the summary of the window and settles the deliveries of the
+// aggregated messages with the result
+func (w *aggregateWindow) emit(node *engine.Node) {
+	w.settle(node.Send(w.message(), 0))
+}
+
+// settle reports the outcome to the deliveries of the aggregated messages
+func (w *aggregateWindow) settle(err error) {
+	for _, release := range w.releases {
+		release(err)
+	}
+	w.releases = nil
+}
+
+// message builds the summary message of the window
+func (w *aggregateWindow) message() *engine.Message {
+	return engine.NewMessage(map[string]interface{}{
+		"count": w.count,
+		"sum":   w.sum,
+		"min":   w.min,
+		"max":   w.max,
+		"mean":  w.sum / float64(w.count),
+		"start": w.start.Format(time.RFC3339Nano),
+		"end":   w.end.Format(time.RFC3339Nano),
+	}, w.topic)
+}
@@ -0,0 +1,346 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// Batch modes
+const (
+	// BatchModeCount groups every Count messages
+	BatchModeCount = "count"
+	// BatchModeInterval groups the messages received every Interval
+	BatchModeInterval = "interval"
+	// BatchModeTopic groups the messages of every topic, sending a group
+	// every Interval or when it holds Count messages
+	BatchModeTopic = "topic"
+)
+
+// Batch overflow policies
+const (
+	// BatchOverflowDropOldest drops the oldest buffered message
+	BatchOverflowDropOldest = "drop-oldest"
+	// BatchOverflowDropNewest rejects the incoming message
+	BatchOverflowDropNewest = "drop-newest"
+	// BatchOverflowFlush sends the buffered groups early
+	BatchOverflowFlush = "flush"
+)
+
+// errBatchDropped settles the deliveries of messages dropped on overflow
+var errBatchDropped = errors.New("batch: buffer full, message dropped")
+
+// BatchConfig represents the configuration of a batch node
+type BatchConfig struct {
+	// Mode is "count", "interval" or "topic"
+	Mode  string `json:"mode" validate:"oneof=count interval topic"`
+	Count int    `json:"count" validate:"min=0"`
+	// Overlap is the number of messages of a count group that start the
+	// next one, e.g. 5 for groups of 10 sliding by 5
+	Overlap  int    `json:"overlap" validate:"min=0"`
+	Interval string `json:"interval" validate:"omitempty,duration"`
+	// AllowEmpty sends an empty group when an interval passes without
+	// messages, in interval mode
+	AllowEmpty bool `json:"allowEmpty"`
+	// Property is the path of the value collected from every message
+	Property string `json:"property" validate:"required"`
+	// MaxBuffer bounds the buffered messages; Overflow is what happens to a
+	// message arriving when it is reached: "drop-oldest", "drop-newest" or
+	// "flush"
+	MaxBuffer int    `json:"maxBuffer" validate:"min=1"`
+	Overflow  string `json:"overflow" validate:"oneof=drop-oldest drop-newest flush"`
+	// FlushOnStop sends the buffered groups on Stop instead of discarding
+	// them
+	FlushOnStop bool `json:"flushOnStop"`
+}
+
+// BatchNode collects messages into groups and sends each group as one
+// message with an array payload and "parts" metadata describing the array.
+// The deliveries of the collected messages settle once their group is sent.
+type BatchNode struct {
+	engine.BaseNode
+	config   BatchConfig
+	interval time.Duration
+
+	groups   map[string][]batchEntry
+	buffered int
+	seq      uint64
+	mu       sync.Mutex
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// batchEntry is a buffered message
+type batchEntry struct {
+	msg     *engine.Message
+	release func(error)
+	seq     uint64
+	// sent is set on the entries an overlapping group passes on
+	sent bool
+}
+
+// batchGroup is a group ready to be sent. Only the entries in settle are
+// settled with the outcome; the others start the next group.
+type batchGroup struct {
+	topic   string
+	entries []batchEntry
+	settle  []batchEntry
+}
+
+// RegisterBatchNode registers the batch node type
+func RegisterBatchNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "batch",
+		Description: "Groups messages by count, interval or topic",
+		Category:    "process",
+		Defaults:    json.RawMessage(`{"mode":"count","count":10,"property":"payload","maxBuffer":1000,"overflow":"drop-oldest"}`),
+		Factory: func() engine.NodeInstance {
+			return &BatchNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *BatchNode) Init(config json.RawMessage) error {
+	if err := n.LoadConfig(config, &n.config); err != nil {
+		return err
+	}
+	interval, err := parseDuration("interval", n.config.Interval)
+	if err != nil {
+		return err
+	}
+	n.interval = interval
+
+	switch n.config.Mode {
+	case BatchModeCount:
+		if n.config.Count < 1 {
+			return fmt.Errorf("count must be at least 1")
+		}
+		if n.config.Overlap >= n.config.Count {
+			return fmt.Errorf("overlap must be less than count")
+		}
+	case BatchModeInterval:
+		if n.interval == 0 {
+			return fmt.Errorf("interval is required")
+		}
+	case BatchModeTopic:
+		if n.interval == 0 && n.config.Count == 0 {
+			return fmt.Errorf("an interval or a count is required")
+		}
+	}
+	if n.config.Count > n.config.MaxBuffer {
+		return fmt.Errorf("count must not be greater than maxBuffer")
+	}
+
+	n.mu.Lock()
+	n.groups = make(map[string][]batchEntry)
+	n.buffered = 0
+	n.mu.Unlock()
+	return nil
+}
+
+// Start starts the interval timer
+func (n *BatchNode) Start(ctx context.Context) error {
+	if n.config.Mode == BatchModeCount || n.interval == 0 {
+		return nil
+	}
+	ctx, n.cancel = context.WithCancel(ctx)
+	n.wg.Add(1)
+	go n.run(ctx)
+	return nil
+}
+
+// Stop stops the timer and flushes or discards the buffered groups
+func (n *BatchNode) Stop() {
+	if n.cancel != nil {
+		n.cancel()
+		n.cancel = nil
+	}
+	n.wg.Wait()
+
+	n.mu.Lock()
+	groups := n.takeAll()
+	n.mu.Unlock()
+
+	for _, group := range groups {
+		if group.sent() {
+			// Only the overlap of a group already sent
+			group.done(nil)
+		} else if n.config.FlushOnStop {
+			n.emit(group)
+		} else {
+			group.done(fmt.Errorf("batch: group discarded on stop"))
+		}
+	}
+}
+
+// OnMessage adds the message to its group
+func (n *BatchNode) OnMessage(msg *engine.Message, port int) error {
+	key := ""
+	if n.config.Mode == BatchModeTopic {
+		key = msg.Topic
+	}
+
+	var ready []batchGroup
+	n.mu.Lock()
+	if n.buffered >= n.config.MaxBuffer {
+		switch n.config.Overflow {
+		case BatchOverflowDropNewest:
+			n.mu.Unlock()
+			return errBatchDropped
+		case BatchOverflowFlush:
+			ready = n.takeAll()
+		default:
+			n.dropOldest()
+		}
+	}
+
+	n.seq++
+	n.groups[key] = append(n.groups[key], batchEntry{msg: msg, release: msg.Retain(), seq: n.seq})
+	n.buffered++
+	if n.config.Count > 0 && len(n.groups[key]) >= n.config.Count {
+		ready = append(ready, n.take(key, n.config.Overlap))
+	}
+	n.mu.Unlock()
+
+	for _, group := range ready {
+		n.emit(group)
+	}
+	return nil
+}
+
+// take removes a group, keeping its last keep entries to start the next
+// one. Called with n.mu held.
+func (n *BatchNode) take(key string, keep int) batchGroup {
+	entries := n.groups[key]
+	group := batchGroup{topic: key, entries: entries, settle: entries[:len(entries)-keep]}
+	if keep > 0 {
+		kept := append([]batchEntry(nil), entries[len(entries)-keep:]...)
+		for i := range kept {
+			kept[i].sent = true
+		}
+		n.groups[key] = kept
+	} else {
+		delete(n.groups, key)
+	}
+	n.buffered -= len(group.settle)
+	return group
+}
+
+// takeAll removes all groups, in the order of their oldest message. Called
+// with n.mu held.
+func (n *BatchNode) takeAll() []batchGroup {
+	var groups []batchGroup
+	for key := range n.groups {
+		groups = append(groups, n.take(key, 0))
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].entries[0].seq < groups[j].entries[0].seq
+	})
+	return groups
+}
+
+// dropOldest drops the oldest buffered message. Called with n.mu held.
+func (n *BatchNode) dropOldest() {
+	oldest := ""
+	found := false
+	for key, entries := range n.groups {
+		if !found || entries[0].seq < n.groups[oldest][0].seq {
+			oldest, found = key, true
+		}
+	}
+	if !found {
+		return
+	}
+
+	entries := n.groups[oldest]
+	entries[0].release(errBatchDropped)
+	if len(entries) == 1 {
+		delete(n.groups, oldest)
+	} else {
+		n.groups[oldest] = entries[1:]
+	}
+	n.buffered--
+}
+
+// run sends the groups every interval
+func (n *BatchNode) run(ctx context.Context) {
+	defer n.wg.Done()
+
+	clock := n.GetNode().Clock()
+	for {
+		timer := clock.NewTimer(n.interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		n.mu.Lock()
+		groups := n.takeAll()
+		n.mu.Unlock()
+
+		if len(groups) == 0 && n.config.Mode == BatchModeInterval && n.config.AllowEmpty {
+			groups = append(groups, batchGroup{})
+		}
+		for _, group := range groups {
+			n.emit(group)
+		}
+	}
+}
+
+// emit sends a group and settles its deliveries with the outcome
+func (n *BatchNode) emit(group batchGroup) {
+	values := make([]interface{}, 0, len(group.entries))
+	topic := group.topic
+	for i, entry := range group.entries {
+		value, _ := entry.msg.GetProperty(n.config.Property)
+		values = append(values, value)
+		// Outside topic mode, the group has a topic if its messages share one
+		if n.config.Mode != BatchModeTopic {
+			if i == 0 {
+				topic = entry.msg.Topic
+			} else if topic != entry.msg.Topic {
+				topic = ""
+			}
+		}
+	}
+
+	msg := engine.NewMessage(values, topic)
+	msg.SourceID = n.GetNode().ID
+	msg.SetMetadata("parts", map[string]interface{}{
+		"id":    msg.MsgID,
+		"type":  "array",
+		"count": len(values),
+	})
+	err := n.GetNode().Send(msg, 0)
+	if err != nil && !errors.Is(err, engine.ErrFlowThrottled) {
+		n.GetNode().Logger().Warn("failed to send a group", "error", err)
+	}
+	group.done(err)
+}
+
+// sent reports whether all entries of the group were sent already
+func (g batchGroup) sent() bool {
+	for _, entry := range g.entries {
+		if !entry.sent {
+			return false
+		}
+	}
+	return true
+}
+
+// done settles the deliveries of the group's settled entries
+func (g batchGroup) done(err error) {
+	for _, entry := range g.settle {
+		entry.release(err)
+	}
+}
@@ -0,0 +1,135 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// CorrelateConfig represents the configuration of a correlate node
+type CorrelateConfig struct {
+	// Property holds the correlation ID; the message ID is used when empty
+	Property string `json:"property"`
+	// Timeout is how long to wait for the reply
+	Timeout string `json:"timeout" validate:"required,duration"`
+}
+
+// CorrelateNode marks messages as requests that a resolve node later
+// matches replies to
+type CorrelateNode struct {
+	engine.BaseNode
+	config  CorrelateConfig
+	timeout time.Duration
+}
+
+// RegisterCorrelateNode registers the correlate node type
+func RegisterCorrelateNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "correlate",
+		Description: "Registers messages as requests awaiting a reply",
+		Category:    "process",
+		Defaults:    json.RawMessage(`{"timeout":"30s"}`),
+		Parallel:    true,
+		Factory: func() engine.NodeInstance {
+			return &CorrelateNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *CorrelateNode) Init(config json.RawMessage) error {
+	if err := n.LoadConfig(config, &n.config); err != nil {
+		return err
+	}
+	n.timeout, _ = time.ParseDuration(n.config.Timeout)
+	if n.timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	return nil
+}
+
+// Start starts the node
+func (n *CorrelateNode) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop stops the node
+func (n *CorrelateNode) Stop() {}
+
+// OnMessage marks the message and passes it on, which registers it
+func (n *CorrelateNode) OnMessage(msg *engine.Message, port int) error {
+	id := msg.MsgID
+	if n.config.Property != "" {
+		value, exists := msg.GetProperty(n.config.Property)
+		if !exists || value == nil {
+			return fmt.Errorf("correlate: message has no %s", n.config.Property)
+		}
+		id = fmt.Sprint(value)
+	}
+
+	msg.SetCorrelation(id, n.timeout)
+	return n.GetNode().Send(msg, 0)
+}
+
+// ResolveConfig represents the configuration of a resolve node
+type ResolveConfig struct {
+	// Property holds the correlation ID of replies
+	Property string `json:"property" validate:"required"`
+}
+
+// ResolveNode matches replies to the requests registered by correlate
+// nodes. Matched replies leave on the first output with the request's
+// metadata restored, so that e.g. an http-response node can answer the
+// original request; unknown and late replies leave on the second.
+type ResolveNode struct {
+	engine.BaseNode
+	config ResolveConfig
+}
+
+// RegisterResolveNode registers the resolve node type
+func RegisterResolveNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "resolve",
+		Description: "Matches replies to pending correlated requests",
+		Category:    "process",
+		Defaults:    json.RawMessage(`{"property":"metadata.correlationId"}`),
+		Parallel:    true,
+		Factory: func() engine.NodeInstance {
+			return &ResolveNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *ResolveNode) Init(config json.RawMessage) error {
+	return n.LoadConfig(config, &n.config)
+}
+
+// Start starts the node
+func (n *ResolveNode) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop stops the node
+func (n *ResolveNode) Stop() {}
+
+// OnMessage completes the request the reply belongs to
+func (n *ResolveNode) OnMessage(msg *engine.Message, port int) error {
+	value, exists := msg.GetProperty(n.config.Property)
+	if !exists || value == nil {
+		return n.GetNode().Send(msg, 1)
+	}
+
+	reply, err := n.GetNode().GetFlow().GetEngine().Reply(fmt.Sprint(value), msg)
+	if errors.Is(err, engine.ErrCorrelationNotFound) {
+		return n.GetNode().Send(msg, 1)
+	}
+	if err != nil {
+		return err
+	}
+	return n.GetNode().Send(reply, 0)
+}
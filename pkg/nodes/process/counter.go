@@ -0,0 +1,353 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// Counter modes
+const (
+	CounterModeCount = "count"
+	CounterModeSum   = "sum"
+	CounterModeMin   = "min"
+	CounterModeMax   = "max"
+	CounterModeMean  = "mean"
+)
+
+// Counter emit modes
+const (
+	CounterEmitMessage  = "message"
+	CounterEmitInterval = "interval"
+)
+
+// CounterConfig represents the configuration of a counter node
+type CounterConfig struct {
+	// GroupBy is empty (no grouping), "topic" or a property path
+	GroupBy string `json:"groupBy"`
+	// Mode is one of count, sum, min, max or mean
+	Mode string `json:"mode"`
+	// Property is the numeric property used by the aggregation modes
+	Property string `json:"property"`
+	// Target is the property the result is written to
+	Target string `json:"target"`
+	// Emit is "message" to emit with every message or "interval" to emit
+	// a periodic summary
+	Emit        string `json:"emit"`
+	Interval    string `json:"interval"`
+	ResetOnEmit bool   `json:"resetOnEmit"`
+}
+
+// CounterNode counts messages and aggregates numeric values, optionally
+// grouped by topic or by a property value. Its totals are kept in the
+// flow context, so they survive restarts if the context is persisted.
+type CounterNode struct {
+	engine.BaseNode
+	config   CounterConfig
+	interval time.Duration
+	groups   map[string]*counterGroup
+	mu       sync.Mutex
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// counterGroup holds the running totals for one group
+type counterGroup struct {
+	count int64
+	sum   float64
+	min   float64
+	max   float64
+}
+
+// RegisterCounterNode registers the counter node type
+func RegisterCounterNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "counter",
+		Description: "Counts messages and aggregates numeric values",
+		Category:    "process",
+		Defaults:    json.RawMessage(`{"mode":"count","property":"payload","target":"payload","emit":"message"}`),
+		Factory: func() engine.NodeInstance {
+			return &CounterNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *CounterNode) Init(config json.RawMessage) error {
+	n.config = CounterConfig{
+		Mode:     CounterModeCount,
+		Property: "payload",
+		Target:   "payload",
+		Emit:     CounterEmitMessage,
+	}
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &n.config); err != nil {
+			return fmt.Errorf("invalid counter config: %w", err)
+		}
+	}
+
+	switch n.config.Mode {
+	case CounterModeCount, CounterModeSum, CounterModeMin, CounterModeMax, CounterModeMean:
+	default:
+		return fmt.Errorf("invalid counter mode: %s", n.config.Mode)
+	}
+
+	interval, err := parseDuration("interval", n.config.Interval)
+	if err != nil {
+		return err
+	}
+
+	switch n.config.Emit {
+	case CounterEmitMessage:
+	case CounterEmitInterval:
+		if interval == 0 {
+			return fmt.Errorf("interval is required when emit is %q", CounterEmitInterval)
+		}
+	default:
+		return fmt.Errorf("invalid counter emit mode: %s", n.config.Emit)
+	}
+
+	n.interval = interval
+	n.groups = make(map[string]*counterGroup)
+	return nil
+}
+
+// Start starts the node
+func (n *CounterNode) Start(ctx context.Context) error {
+	n.mu.Lock()
+	n.load()
+	n.mu.Unlock()
+
+	if n.config.Emit != CounterEmitInterval {
+		return nil
+	}
+
+	ctx, n.cancel = context.WithCancel(ctx)
+	n.wg.Add(1)
+	go n.run(ctx)
+	return nil
+}
+
+// Stop stops the node
+func (n *CounterNode) Stop() {
+	if n.cancel != nil {
+		n.cancel()
+		n.cancel = nil
+	}
+	n.wg.Wait()
+}
+
+// OnMessage processes a message
+func (n *CounterNode) OnMessage(msg *engine.Message, port int) error {
+	if isResetMessage(msg) {
+		n.mu.Lock()
+		n.groups = make(map[string]*counterGroup)
+		n.save()
+		n.mu.Unlock()
+		return nil
+	}
+
+	var value float64
+	if n.config.Mode != CounterModeCount {
+		raw, _ := msg.GetProperty(n.config.Property)
+		v, ok := engine.ToFloat(raw)
+		if !ok {
+			return fmt.Errorf("counter: property %s is not numeric", n.config.Property)
+		}
+		value = v
+	}
+
+	key := n.groupKey(msg)
+
+	n.mu.Lock()
+	group, exists := n.groups[key]
+	if !exists {
+		group = &counterGroup{min: math.Inf(1), max: math.Inf(-1)}
+		n.groups[key] = group
+	}
+	group.add(value)
+	result := group.value(n.config.Mode)
+	count := group.count
+	if n.config.Emit == CounterEmitMessage && n.config.ResetOnEmit {
+		delete(n.groups, key)
+	}
+	n.save()
+	n.mu.Unlock()
+
+	if n.config.Emit != CounterEmitMessage {
+		return nil
+	}
+
+	if err := msg.SetProperty(n.config.Target, result); err != nil {
+		return fmt.Errorf("counter: %w", err)
+	}
+	msg.SetMetadata("count", count)
+	return n.GetNode().Send(msg, 0)
+}
+
+// run emits a summary message on every interval tick
+func (n *CounterNode) run(ctx context.Context) {
+	defer n.wg.Done()
+
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if msg := n.summary(); msg != nil {
+				n.GetNode().Send(msg, 0)
+			}
+		}
+	}
+}
+
+// summary builds the periodic summary message, or nil if nothing was counted
+func (n *CounterNode) summary() *engine.Message {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if len(n.groups) == 0 {
+		return nil
+	}
+
+	var payload interface{}
+	if n.config.GroupBy == "" {
+		payload = n.groups[""].value(n.config.Mode)
+	} else {
+		values := make(map[string]interface{}, len(n.groups))
+		for key, group := range n.groups {
+			values[key] = group.value(n.config.Mode)
+		}
+		payload = values
+	}
+
+	if n.config.ResetOnEmit {
+		n.groups = make(map[string]*counterGroup)
+		n.save()
+	}
+
+	return engine.NewMessage(payload, "")
+}
+
+// flowContext returns the flow context the totals are kept in, or nil if
+// the node does not run in an engine's flow
+func (n *CounterNode) flowContext() *engine.Context {
+	node := n.GetNode()
+	if node == nil || node.GetFlow() == nil || node.GetFlow().GetEngine() == nil {
+		return nil
+	}
+	return node.GetFlow().Context()
+}
+
+// contextKey returns the flow context key of the node's totals
+func (n *CounterNode) contextKey() string {
+	return "counter:" + n.GetNode().ID
+}
+
+// load restores the totals kept in the flow context. The caller must hold
+// n.mu.
+func (n *CounterNode) load() {
+	ctx := n.flowContext()
+	if ctx == nil {
+		return
+	}
+	value, exists := ctx.Get(n.contextKey())
+	if !exists {
+		return
+	}
+	saved, ok := value.(map[string]interface{})
+	if !ok {
+		n.GetNode().Logger().Warn("ignoring invalid saved counts", "type", fmt.Sprintf("%T", value))
+		return
+	}
+
+	n.groups = make(map[string]*counterGroup, len(saved))
+	for key, value := range saved {
+		fields, _ := value.(map[string]interface{})
+		count, ok := engine.ToInt(fields["count"])
+		if !ok || count <= 0 {
+			continue
+		}
+		group := &counterGroup{count: count}
+		group.sum, _ = engine.ToFloat(fields["sum"])
+		group.min, _ = engine.ToFloat(fields["min"])
+		group.max, _ = engine.ToFloat(fields["max"])
+		n.groups[key] = group
+	}
+}
+
+// save writes the totals to the flow context. They are persisted only if
+// the operator made the key persistent with a context.persistentPrefixes
+// entry such as "counter:". The caller must hold n.mu.
+func (n *CounterNode) save() {
+	ctx := n.flowContext()
+	if ctx == nil {
+		return
+	}
+	if len(n.groups) == 0 {
+		ctx.Delete(n.contextKey())
+		return
+	}
+	saved := make(map[string]interface{}, len(n.groups))
+	for key, group := range n.groups {
+		saved[key] = map[string]interface{}{
+			"count": group.count,
+			"sum":   group.sum,
+			"min":   group.min,
+			"max":   group.max,
+		}
+	}
+	ctx.Set(n.contextKey(), saved)
+}
+
+// groupKey returns the group a message belongs to
+func (n *CounterNode) groupKey(msg *engine.Message) string {
+	switch n.config.GroupBy {
+	case "":
+		return ""
+	case "topic":
+		return msg.Topic
+	default:
+		value, exists := msg.GetProperty(n.config.GroupBy)
+		if !exists {
+			return ""
+		}
+		return fmt.Sprintf("%v", value)
+	}
+}
+
+// add adds a value to the group totals
+func (g *counterGroup) add(value float64) {
+	g.count++
+	g.sum += value
+	g.min = math.Min(g.min, value)
+	g.max = math.Max(g.max, value)
+}
+
+// value returns the group result for the given mode
+func (g *counterGroup) value(mode string) float64 {
+	switch mode {
+	case CounterModeSum:
+		return g.sum
+	case CounterModeMin:
+		return g.min
+	case CounterModeMax:
+		return g.max
+	case CounterModeMean:
+		if g.count == 0 {
+			return 0
+		}
+		return g.sum / float64(g.count)
+	default:
+		return float64(g.count)
+	}
+}
@@ -0,0 +1,129 @@
+package process_test
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/registry"
+	"github.com/yourusername/go-red/internal/storage"
+	"github.com/yourusername/go-red/pkg/nodes/process"
+)
+
+// collectNode keeps the messages it receives
+type collectNode struct {
+	engine.BaseNode
+	mu       sync.Mutex
+	received []*engine.Message
+}
+
+func (n *collectNode) Init(json.RawMessage) error      { return nil }
+func (n *collectNode) Start(ctx context.Context) error { return nil }
+func (n *collectNode) Stop()                           {}
+
+func (n *collectNode) OnMessage(msg *engine.Message, port int) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.received = append(n.received, msg)
+	return nil
+}
+
+// last returns the payload of the last message received
+func (n *collectNode) last() interface{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.received) == 0 {
+		return nil
+	}
+	return n.received[len(n.received)-1].Payload
+}
+
+// counterFlow counts messages by topic and passes the counts to a collector
+const counterFlow = `{
+	"id": "counts",
+	"nodes": [
+		{"id": "counter", "type": "counter", "config": {"groupBy": "topic"}},
+		{"id": "out", "type": "collect"}
+	],
+	"wires": [{"source": "counter", "target": "out", "port": 0}]
+}`
+
+// startCounterEngine starts an engine on store with the persistent context
+// prefixes, deploys counterFlow and returns the counter node and the
+// collector
+func startCounterEngine(t *testing.T, store storage.Storage, prefixes ...string) (*engine.Engine, *engine.Node, *collectNode) {
+	t.Helper()
+	collector := &collectNode{}
+	reg := registry.New()
+	if err := process.RegisterCounterNode(reg); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.RegisterNodeType(&engine.NodeType{
+		Name:    "collect",
+		Factory: func() engine.NodeInstance { return collector },
+	}); err != nil {
+		t.Fatal(err)
+	}
+	eng := engine.New(reg, store)
+	eng.SetPersistentContextPrefixes(prefixes)
+	if err := eng.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.DeployFlow("counts", []byte(counterFlow)); err != nil {
+		eng.Stop()
+		t.Fatal(err)
+	}
+	flow, _ := eng.GetFlow("counts")
+	counter, _ := flow.GetNode("counter")
+	return eng, counter, collector
+}
+
+// countAfterRestart counts a, a and b, restarts the engine on the same
+// store and returns the counts of a, b and c it sends next
+func countAfterRestart(t *testing.T, prefixes ...string) []interface{} {
+	t.Helper()
+	store := storage.NewMemoryStorage()
+	eng, counter, _ := startCounterEngine(t, store, prefixes...)
+	for _, topic := range []string{"a", "a", "b"} {
+		if err := counter.Receive(engine.NewMessage("x", topic), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	eng.Stop()
+
+	eng, counter, collector := startCounterEngine(t, store, prefixes...)
+	defer eng.Stop()
+	var counts []interface{}
+	for _, topic := range []string{"a", "b", "c"} {
+		if err := counter.Receive(engine.NewMessage("x", topic), 0); err != nil {
+			t.Fatal(err)
+		}
+		counts = append(counts, collector.last())
+	}
+	return counts
+}
+
+func TestCounterSurvivesRestart(t *testing.T) {
+	// The persistent prefix makes the totals persistent
+	counts := countAfterRestart(t, "counter:")
+	for i, tt := range []struct {
+		topic string
+		want  float64
+	}{{"a", 3}, {"b", 2}, {"c", 1}} {
+		if counts[i] != tt.want {
+			t.Errorf("count of topic %s after restart = %v, want %v", tt.topic, counts[i], tt.want)
+		}
+	}
+}
+
+func TestCounterKeepsTotalsInMemory(t *testing.T) {
+	// Without a persistent prefix the totals start over
+	counts := countAfterRestart(t)
+	for i, topic := range []string{"a", "b", "c"} {
+		if counts[i] != float64(1) {
+			t.Errorf("count of topic %s after restart = %v, want 1", topic, counts[i])
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// CSV output modes for parsed text
+const (
+	CSVOutputArray    = "array"
+	CSVOutputMessages = "messages"
+)
+
+// csvNoQuote disables quote handling
+const csvNoQuote = "none"
+
+// CSVConfig represents the configuration of a csv node
+type CSVConfig struct {
+	// Property holds the CSV text to parse, or the objects to serialize
+	Property string `json:"property" validate:"required"`
+	// Columns names the columns. Without them, parsing takes the names
+	// from the header row if Header is set and names them col1, col2, ...
+	// otherwise, and serializing uses the objects' keys in sorted order.
+	Columns []string `json:"columns"`
+	// Header reads the first row as column names when parsing, and writes
+	// the column names as the first row when serializing
+	Header bool `json:"header"`
+	// Delimiter separates the fields, e.g. ";" or "\t"
+	Delimiter string `json:"delimiter" validate:"required"`
+	// Quote encloses fields containing delimiters, quotes or line breaks;
+	// "none" reads and writes quotes like any other character
+	Quote string `json:"quote" validate:"required"`
+	// QuoteAll quotes every field when serializing
+	QuoteAll bool `json:"quoteAll"`
+	// SkipEmpty skips empty lines; otherwise they become rows of empty
+	// fields
+	SkipEmpty bool `json:"skipEmpty"`
+	// Output sends the parsed rows as a single array, or one message each
+	Output string `json:"output" validate:"oneof=array messages"`
+}
+
+// CSVNode parses CSV text into objects and serializes objects into CSV.
+// Text is parsed, objects and arrays of objects are serialized. Malformed
+// rows are sent on the second output with their line in the "row"
+// metadata.
+type CSVNode struct {
+	engine.BaseNode
+	config    CSVConfig
+	delimiter rune
+	quote     rune
+}
+
+// csvRow is a parsed row with the line it starts on and its text
+type csvRow struct {
+	line   int
+	fields []string
+	text   string
+	err    string
+}
+
+// RegisterCSVNode registers the csv node type
+func RegisterCSVNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "csv",
+		Description: "Converts between CSV text and objects",
+		Category:    "process",
+		Defaults:    json.RawMessage(`{"property":"payload","header":true,"delimiter":",","quote":"\"","skipEmpty":true,"output":"array"}`),
+		Parallel:    true,
+		Factory: func() engine.NodeInstance {
+			return &CSVNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *CSVNode) Init(config json.RawMessage) error {
+	if err := n.LoadConfig(config, &n.config); err != nil {
+		return err
+	}
+
+	delimiter, size := utf8.DecodeRuneInString(n.config.Delimiter)
+	if size != len(n.config.Delimiter) || delimiter == '\r' || delimiter == '\n' {
+		return fmt.Errorf("delimiter must be a single character other than a line break")
+	}
+	n.delimiter = delimiter
+
+	n.quote = 0
+	if n.config.Quote != csvNoQuote {
+		quote, size := utf8.DecodeRuneInString(n.config.Quote)
+		if size != len(n.config.Quote) || quote == delimiter || quote == '\r' || quote == '\n' {
+			return fmt.Errorf("quote must be a single character other than the delimiter, or %q", csvNoQuote)
+		}
+		n.quote = quote
+	}
+	return nil
+}
+
+// Start starts the node
+func (n *CSVNode) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop stops the node
+func (n *CSVNode) Stop() {}
+
+// OnMessage parses or serializes the configured property
+func (n *CSVNode) OnMessage(msg *engine.Message, port int) error {
+	value, _ := msg.GetProperty(n.config.Property)
+	switch value := value.(type) {
+	case string:
+		return n.parse(msg, value)
+	case []byte:
+		return n.parse(msg, string(value))
+	case map[string]interface{}, []interface{}:
+		text, err := n.serialize(value)
+		if err != nil {
+			return fmt.Errorf("csv: %w", err)
+		}
+		if err := msg.SetProperty(n.config.Property, text); err != nil {
+			return fmt.Errorf("csv: %w", err)
+		}
+		return n.GetNode().Send(msg, 0)
+	default:
+		return fmt.Errorf("csv: %s must be CSV text, an object or an array of objects", n.config.Property)
+	}
+}
+
+// parse sends the rows of text as objects, and its malformed rows on the
+// second output
+func (n *CSVNode) parse(msg *engine.Message, text string) error {
+	columns := n.config.Columns
+	header := n.config.Header
+
+	var objects []interface{}
+	var rowLines []int
+	var failed []*engine.Message
+	for _, row := range n.rows(text) {
+		if row.err == "" && len(row.fields) == 1 && row.fields[0] == "" && row.text == "" {
+			// An empty line
+			if n.config.SkipEmpty {
+				continue
+			}
+			row.fields = make([]string, len(columns))
+		}
+		if row.err == "" && header {
+			header = false
+			if columns == nil {
+				columns = row.fields
+			}
+			continue
+		}
+		if row.err == "" && columns != nil && len(row.fields) != len(columns) {
+			row.err = fmt.Sprintf("expected %d fields, got %d", len(columns), len(row.fields))
+		}
+		if row.err != "" {
+			failure := msg.Clone()
+			failure.Payload = row.text
+			failure.SetMetadata("row", row.line)
+			failure.SetMetadata("error", row.err)
+			failed = append(failed, failure)
+			continue
+		}
+
+		object := make(map[string]interface{}, len(row.fields))
+		for i, field := range row.fields {
+			if columns != nil {
+				object[columns[i]] = field
+			} else {
+				object[fmt.Sprintf("col%d", i+1)] = field
+			}
+		}
+		objects = append(objects, object)
+		rowLines = append(rowLines, row.line)
+	}
+
+	var out []*engine.Message
+	if n.config.Output == CSVOutputMessages {
+		for i, object := range objects {
+			rowMsg := msg.Clone()
+			if err := rowMsg.SetProperty(n.config.Property, object); err != nil {
+				return fmt.Errorf("csv: %w", err)
+			}
+			rowMsg.SetMetadata("row", rowLines[i])
+			rowMsg.SetMetadata("index", i)
+			rowMsg.SetMetadata("count", len(objects))
+			out = append(out, rowMsg)
+		}
+	} else {
+		if objects == nil {
+			objects = []interface{}{}
+		}
+		if err := msg.SetProperty(n.config.Property, objects); err != nil {
+			return fmt.Errorf("csv: %w", err)
+		}
+		out = append(out, msg)
+	}
+	return n.GetNode().SendAll([][]*engine.Message{out, failed})
+}
+
+// rows splits text into rows of fields. A malformed row is returned with
+// an error and the rest of its line.
+func (n *CSVNode) rows(text string) []csvRow {
+	var rows []csvRow
+	line := 1
+	for len(text) > 0 {
+		row := csvRow{line: line}
+		var field strings.Builder
+		quoted := false // inside a quoted field
+		i := 0
+	scan:
+		for i < len(text) {
+			r, size := utf8.DecodeRuneInString(text[i:])
+			switch {
+			case quoted && r == n.quote:
+				next, nextSize := utf8.DecodeRuneInString(text[i+size:])
+				switch {
+				case next == n.quote && nextSize > 0:
+					field.WriteRune(n.quote)
+					i += size + nextSize
+				case next == n.delimiter || next == '\n' || next == '\r' || nextSize == 0:
+					quoted = false
+					i += size
+				default:
+					row.err = fmt.Sprintf("unexpected %q after closing quote", next)
+					break scan
+				}
+				continue
+			case quoted:
+				if r == '\n' {
+					line++
+				}
+				field.WriteRune(r)
+			case r == n.quote && n.quote != 0:
+				if field.Len() > 0 {
+					row.err = "quote in unquoted field"
+					break scan
+				}
+				quoted = true
+			case r == n.delimiter:
+				row.fields = append(row.fields, field.String())
+				field.Reset()
+			case r == '\n' || r == '\r':
+				break scan
+			default:
+				field.WriteRune(r)
+			}
+			i += size
+		}
+		if quoted && row.err == "" {
+			row.err = "unterminated quoted field"
+		}
+
+		// A malformed row extends to the end of its line
+		end := i
+		if row.err != "" {
+			if newline := strings.IndexAny(text[i:], "\r\n"); newline >= 0 {
+				end = i + newline
+			} else {
+				end = len(text)
+			}
+		}
+		row.text = text[:end]
+		row.fields = append(row.fields, field.String())
+		rows = append(rows, row)
+
+		text = text[end:]
+		if strings.HasPrefix(text, "\r\n") {
+			text = text[2:]
+		} else if len(text) > 0 {
+			text = text[1:]
+		}
+		line++
+	}
+	return rows
+}
+
+// serialize writes an object, or an array of objects or arrays, as CSV
+func (n *CSVNode) serialize(value interface{}) (string, error) {
+	items, ok := value.([]interface{})
+	if !ok {
+		items = []interface{}{value}
+	}
+
+	columns := n.config.Columns
+	if columns == nil {
+		keys := make(map[string]bool)
+		for _, item := range items {
+			if object, ok := item.(map[string]interface{}); ok {
+				for key := range object {
+					if !keys[key] {
+						keys[key] = true
+						columns = append(columns, key)
+					}
+				}
+			}
+		}
+		sort.Strings(columns)
+	}
+
+	var out strings.Builder
+	if n.config.Header && len(columns) > 0 {
+		n.writeRow(&out, columns)
+	}
+	for i, item := range items {
+		var fields []string
+		switch item := item.(type) {
+		case map[string]interface{}:
+			fields = make([]string, len(columns))
+			for j, column := range columns {
+				fields[j] = csvField(item[column])
+			}
+		case []interface{}:
+			fields = make([]string, len(item))
+			for j, v := range item {
+				fields[j] = csvField(v)
+			}
+		default:
+			return "", fmt.Errorf("item %d is not an object or array", i)
+		}
+		n.writeRow(&out, fields)
+	}
+	return out.String(), nil
+}
+
+// writeRow writes the fields of a row, quoting them as configured
+func (n *CSVNode) writeRow(out *strings.Builder, fields []string) {
+	for i, field := range fields {
+		if i > 0 {
+			out.WriteRune(n.delimiter)
+		}
+		if n.quote == 0 || !n.config.QuoteAll && !strings.ContainsAny(field, string([]rune{n.delimiter, n.quote, '\r', '\n'})) {
+			out.WriteString(field)
+			continue
+		}
+		quote := string(n.quote)
+		out.WriteString(quote)
+		out.WriteString(strings.ReplaceAll(field, quote, quote+quote))
+		out.WriteString(quote)
+	}
+	out.WriteString("\n")
+}
+
+// csvField formats a value as a field; objects and arrays become JSON
+func csvField(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		return string(data)
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,329 @@
+package process
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// Enrich miss behaviours
+const (
+	EnrichMissPass   = "pass"
+	EnrichMissDrop   = "drop"
+	EnrichMissOutput = "output"
+)
+
+const (
+	defaultEnrichMaxRecords     = 100000
+	defaultEnrichReloadInterval = 5 * time.Second
+)
+
+// EnrichConfig represents the configuration of an enrich node
+type EnrichConfig struct {
+	// File is a CSV or JSON lookup table; Table is used when File is empty
+	File   string `json:"file"`
+	Format string `json:"format"`
+	// KeyField is the CSV column or JSON array field holding the record key
+	KeyField string                            `json:"keyField"`
+	Table    map[string]map[string]interface{} `json:"table"`
+	// Key is the message property the lookup key is taken from
+	Key string `json:"key"`
+	// Target is the property the matched record is merged into
+	Target string `json:"target"`
+	// OnMiss is one of pass, drop or output (route to the second port)
+	OnMiss         string `json:"onMiss"`
+	MaxRecords     int    `json:"maxRecords"`
+	ReloadInterval string `json:"reloadInterval"`
+}
+
+// EnrichNode merges records from a lookup table into messages
+type EnrichNode struct {
+	engine.BaseNode
+	config         EnrichConfig
+	reloadInterval time.Duration
+	table          map[string]map[string]interface{}
+	modTime        time.Time
+	mu             sync.RWMutex
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// RegisterEnrichNode registers the enrich node type
+func RegisterEnrichNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "enrich",
+		Description: "Enriches messages with records from a lookup table",
+		Category:    "process",
+		Defaults:    json.RawMessage(`{"key":"payload.id","keyField":"id","target":"payload","onMiss":"pass"}`),
+		Factory: func() engine.NodeInstance {
+			return &EnrichNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *EnrichNode) Init(config json.RawMessage) error {
+	n.config = EnrichConfig{
+		Key:        "payload.id",
+		KeyField:   "id",
+		Target:     "payload",
+		OnMiss:     EnrichMissPass,
+		MaxRecords: defaultEnrichMaxRecords,
+	}
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &n.config); err != nil {
+			return fmt.Errorf("invalid enrich config: %w", err)
+		}
+	}
+
+	switch n.config.OnMiss {
+	case EnrichMissPass, EnrichMissDrop, EnrichMissOutput:
+	default:
+		return fmt.Errorf("invalid onMiss behaviour: %s", n.config.OnMiss)
+	}
+
+	if n.config.File == "" && n.config.Table == nil {
+		return fmt.Errorf("either file or table is required")
+	}
+	if n.config.File != "" && n.config.Format == "" {
+		n.config.Format = strings.TrimPrefix(strings.ToLower(filepath.Ext(n.config.File)), ".")
+	}
+	if n.config.File != "" && n.config.Format != "csv" && n.config.Format != "json" {
+		return fmt.Errorf("unsupported table format: %s", n.config.Format)
+	}
+	if n.config.Table != nil && len(n.config.Table) > n.config.MaxRecords {
+		return fmt.Errorf("table has %d records, exceeding the limit of %d", len(n.config.Table), n.config.MaxRecords)
+	}
+
+	interval, err := parseDuration("reloadInterval", n.config.ReloadInterval)
+	if err != nil {
+		return err
+	}
+	if interval == 0 {
+		interval = defaultEnrichReloadInterval
+	}
+	n.reloadInterval = interval
+
+	return nil
+}
+
+// Start loads the lookup table and starts watching the source file
+func (n *EnrichNode) Start(ctx context.Context) error {
+	if n.config.File == "" {
+		n.mu.Lock()
+		n.table = n.config.Table
+		n.mu.Unlock()
+		return nil
+	}
+
+	if err := n.load(); err != nil {
+		return err
+	}
+
+	ctx, n.cancel = context.WithCancel(ctx)
+	n.wg.Add(1)
+	go n.watch(ctx)
+	return nil
+}
+
+// Stop stops the node
+func (n *EnrichNode) Stop() {
+	if n.cancel != nil {
+		n.cancel()
+		n.cancel = nil
+	}
+	n.wg.Wait()
+}
+
+// OnMessage processes a message
+func (n *EnrichNode) OnMessage(msg *engine.Message, port int) error {
+	var record map[string]interface{}
+	if key, exists := msg.GetProperty(n.config.Key); exists {
+		n.mu.RLock()
+		record = n.table[fmt.Sprintf("%v", key)]
+		n.mu.RUnlock()
+	}
+
+	if record == nil {
+		switch n.config.OnMiss {
+		case EnrichMissDrop:
+			return nil
+		case EnrichMissOutput:
+			return n.GetNode().Send(msg, 1)
+		default:
+			return n.GetNode().Send(msg, 0)
+		}
+	}
+
+	current, _ := msg.GetProperty(n.config.Target)
+	merged, ok := current.(map[string]interface{})
+	if !ok {
+		merged = make(map[string]interface{}, len(record))
+	}
+	for k, v := range record {
+		merged[k] = v
+	}
+
+	if err := msg.SetProperty(n.config.Target, merged); err != nil {
+		return fmt.Errorf("enrich: %w", err)
+	}
+	return n.GetNode().Send(msg, 0)
+}
+
+// watch reloads the table whenever the source file changes
+func (n *EnrichNode) watch(ctx context.Context) {
+	defer n.wg.Done()
+
+	ticker := time.NewTicker(n.reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(n.config.File)
+			if err != nil {
+				n.GetNode().Logger().Warn("cannot stat table file", "file", n.config.File, "error", err)
+				continue
+			}
+
+			n.mu.RLock()
+			changed := !info.ModTime().Equal(n.modTime)
+			n.mu.RUnlock()
+			if !changed {
+				continue
+			}
+
+			// Keep serving the previous table if the new one is invalid
+			if err := n.load(); err != nil {
+				n.GetNode().Logger().Warn("failed to reload table file", "file", n.config.File, "error", err)
+			}
+		}
+	}
+}
+
+// load reads the lookup table from the source file
+func (n *EnrichNode) load() error {
+	file, err := os.Open(n.config.File)
+	if err != nil {
+		return fmt.Errorf("failed to open lookup table: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat lookup table: %w", err)
+	}
+
+	var table map[string]map[string]interface{}
+	if n.config.Format == "csv" {
+		table, err = n.parseCSV(file)
+	} else {
+		table, err = n.parseJSON(file)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse lookup table: %w", err)
+	}
+
+	n.mu.Lock()
+	n.table = table
+	n.modTime = info.ModTime()
+	n.mu.Unlock()
+	return nil
+}
+
+// parseCSV parses a CSV table whose first row holds the column names
+func (n *EnrichNode) parseCSV(r io.Reader) (map[string]map[string]interface{}, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	keyIndex := -1
+	for i, column := range header {
+		if column == n.config.KeyField {
+			keyIndex = i
+		}
+	}
+	if keyIndex < 0 {
+		return nil, fmt.Errorf("key column %q not found", n.config.KeyField)
+	}
+
+	table := make(map[string]map[string]interface{})
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		record := make(map[string]interface{}, len(header))
+		for i, column := range header {
+			if i < len(row) {
+				record[column] = row[i]
+			}
+		}
+		table[row[keyIndex]] = record
+
+		if len(table) > n.config.MaxRecords {
+			return nil, fmt.Errorf("table exceeds the limit of %d records", n.config.MaxRecords)
+		}
+	}
+
+	return table, nil
+}
+
+// parseJSON parses a JSON table given either as an object keyed by record
+// key or as an array of records containing the key field
+func (n *EnrichNode) parseJSON(r io.Reader) (map[string]map[string]interface{}, error) {
+	var data interface{}
+	if err := engine.ReadJSON(r, &data); err != nil {
+		return nil, err
+	}
+
+	table := make(map[string]map[string]interface{})
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			record, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("record %q is not an object", key)
+			}
+			table[key] = record
+		}
+	case []interface{}:
+		for i, value := range v {
+			record, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("record %d is not an object", i)
+			}
+			key, exists := record[n.config.KeyField]
+			if !exists {
+				return nil, fmt.Errorf("record %d has no %q field", i, n.config.KeyField)
+			}
+			table[fmt.Sprintf("%v", key)] = record
+		}
+	default:
+		return nil, fmt.Errorf("table must be a JSON object or array")
+	}
+
+	if len(table) > n.config.MaxRecords {
+		return nil, fmt.Errorf("table has %d records, exceeding the limit of %d", len(table), n.config.MaxRecords)
+	}
+
+	return table, nil
+}
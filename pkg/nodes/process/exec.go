@@ -0,0 +1,431 @@
+package process
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// Exec node modes
+const (
+	// ExecModeExec runs the command once per message and collects its output
+	ExecModeExec = "exec"
+	// ExecModeSpawn keeps a long-running process and streams its output
+	ExecModeSpawn = "spawn"
+)
+
+// Spawn start options
+const (
+	ExecStartOnStart   = "start"
+	ExecStartOnMessage = "message"
+)
+
+// maxExecLineSize limits a single streamed output line
+const maxExecLineSize = 1 << 20
+
+// ExecConfig represents the configuration of an exec node
+type ExecConfig struct {
+	Command string            `json:"command"`
+	Args    []string          `json:"args"`
+	Dir     string            `json:"dir"`
+	Env     map[string]string `json:"env"`
+	// Mode is "exec" or "spawn"
+	Mode string `json:"mode"`
+	// AppendPayload appends the payload as the last argument (exec mode)
+	AppendPayload bool `json:"appendPayload"`
+	// Timeout stops a command running longer than this (exec mode)
+	Timeout string `json:"timeout"`
+	// StartOn is "start" or "message" (spawn mode)
+	StartOn string `json:"startOn"`
+	// PTY runs the process on a pseudo terminal so that tools which buffer
+	// output when not attached to a terminal stream it line by line; stdout
+	// and stderr are merged (spawn mode)
+	PTY bool `json:"pty"`
+	// RestartDelay is the initial delay before restarting an exited
+	// process; it doubles up to MaxRestartDelay (spawn mode)
+	RestartDelay    string `json:"restartDelay"`
+	MaxRestartDelay string `json:"maxRestartDelay"`
+	// StopGrace is how long to wait after SIGTERM before sending SIGKILL
+	StopGrace string `json:"stopGrace"`
+}
+
+// ExecNode runs external commands. In exec mode each message runs the
+// command and stdout, stderr and the exit code are sent to outputs 1, 2 and
+// 3. In spawn mode a long-running process streams each output line as a
+// message, incoming messages are written to its stdin and every exit is
+// reported on output 3.
+type ExecNode struct {
+	engine.BaseNode
+	config          ExecConfig
+	timeout         time.Duration
+	restartDelay    time.Duration
+	maxRestartDelay time.Duration
+	stopGrace       time.Duration
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	started bool
+	ready   chan struct{}
+	stdin   io.Writer
+	writeMu sync.Mutex
+}
+
+// RegisterExecNode registers the exec node type
+func RegisterExecNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "exec",
+		Description: "Runs external commands",
+		Category:    "process",
+		Defaults:    json.RawMessage(`{"mode":"exec","appendPayload":true,"startOn":"start","restartDelay":"1s","maxRestartDelay":"1m","stopGrace":"5s"}`),
+		Factory: func() engine.NodeInstance {
+			return &ExecNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *ExecNode) Init(config json.RawMessage) error {
+	n.config = ExecConfig{
+		Mode:            ExecModeExec,
+		StartOn:         ExecStartOnStart,
+		RestartDelay:    "1s",
+		MaxRestartDelay: "1m",
+		StopGrace:       "5s",
+	}
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &n.config); err != nil {
+			return fmt.Errorf("invalid exec config: %w", err)
+		}
+	}
+
+	if n.config.Command == "" {
+		return fmt.Errorf("command is required")
+	}
+	switch n.config.Mode {
+	case ExecModeExec, ExecModeSpawn:
+	default:
+		return fmt.Errorf("invalid exec mode: %s", n.config.Mode)
+	}
+	switch n.config.StartOn {
+	case ExecStartOnStart, ExecStartOnMessage:
+	default:
+		return fmt.Errorf("invalid startOn: %s", n.config.StartOn)
+	}
+
+	var err error
+	if n.timeout, err = parseDuration("timeout", n.config.Timeout); err != nil {
+		return err
+	}
+	if n.restartDelay, err = parseDuration("restartDelay", n.config.RestartDelay); err != nil {
+		return err
+	}
+	if n.maxRestartDelay, err = parseDuration("maxRestartDelay", n.config.MaxRestartDelay); err != nil {
+		return err
+	}
+	if n.stopGrace, err = parseDuration("stopGrace", n.config.StopGrace); err != nil {
+		return err
+	}
+	if n.restartDelay == 0 {
+		n.restartDelay = time.Second
+	}
+	if n.maxRestartDelay < n.restartDelay {
+		n.maxRestartDelay = n.restartDelay
+	}
+
+	return nil
+}
+
+// Start starts the node, spawning the process if configured to
+func (n *ExecNode) Start(ctx context.Context) error {
+	n.ctx, n.cancel = context.WithCancel(ctx)
+
+	n.mu.Lock()
+	n.started = false
+	n.ready = make(chan struct{})
+	n.mu.Unlock()
+
+	if n.config.Mode == ExecModeSpawn && n.config.StartOn == ExecStartOnStart {
+		n.spawn()
+	}
+	return nil
+}
+
+// Stop terminates running processes and stops the restart loop
+func (n *ExecNode) Stop() {
+	if n.cancel != nil {
+		n.cancel()
+	}
+	n.wg.Wait()
+}
+
+// OnMessage runs the command or writes the payload to the process's stdin
+func (n *ExecNode) OnMessage(msg *engine.Message, port int) error {
+	if n.config.Mode == ExecModeExec {
+		return n.run(msg)
+	}
+
+	n.spawn()
+	n.mu.Lock()
+	ready := n.ready
+	n.mu.Unlock()
+
+	select {
+	case <-ready:
+	case <-n.ctx.Done():
+		return fmt.Errorf("exec: node is stopping")
+	case <-time.After(5 * time.Second):
+		return fmt.Errorf("exec: process did not start")
+	}
+
+	n.mu.Lock()
+	stdin := n.stdin
+	n.mu.Unlock()
+	if stdin == nil {
+		return fmt.Errorf("exec: process is not running")
+	}
+
+	data := execPayload(msg.Payload)
+	if !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+
+	n.writeMu.Lock()
+	defer n.writeMu.Unlock()
+	if _, err := stdin.Write(data); err != nil {
+		return fmt.Errorf("exec: failed to write to stdin: %w", err)
+	}
+	return nil
+}
+
+// run executes the command once and sends its output
+func (n *ExecNode) run(msg *engine.Message) error {
+	ctx := n.ctx
+	if n.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, n.timeout)
+		defer cancel()
+	}
+
+	args := append([]string{}, n.config.Args...)
+	if n.config.AppendPayload {
+		args = append(args, string(execPayload(msg.Payload)))
+	}
+
+	cmd := n.command(args)
+	setProcessGroup(cmd)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("exec: failed to start %s: %w", n.config.Command, err)
+	}
+
+	exited := make(chan struct{})
+	go n.stopOnDone(ctx, cmd, exited)
+	err := cmd.Wait()
+	close(exited)
+
+	out := msg.Clone()
+	out.Payload = stdout.String()
+	var errMsg *engine.Message
+	if stderr.Len() > 0 {
+		errMsg = msg.Clone()
+		errMsg.Payload = stderr.String()
+	}
+	return n.GetNode().SendAll([][]*engine.Message{
+		{out},
+		{errMsg},
+		{n.exitMessage(msg.Clone(), cmd, err, ctx.Err())},
+	})
+}
+
+// spawn starts the supervisor once
+func (n *ExecNode) spawn() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.started {
+		return
+	}
+	n.started = true
+	n.wg.Add(1)
+	go n.supervise(n.ctx, n.ready)
+}
+
+// supervise runs the process and restarts it with backoff until the node
+// stops
+func (n *ExecNode) supervise(ctx context.Context, ready chan struct{}) {
+	defer n.wg.Done()
+
+	var once sync.Once
+	markReady := func() { once.Do(func() { close(ready) }) }
+	defer markReady()
+
+	backoff := engine.NewBackoff(n.restartDelay, n.maxRestartDelay)
+	for {
+		backoff.Start()
+		err := n.runProcess(ctx, markReady)
+		if ctx.Err() != nil {
+			return
+		}
+
+		delay := backoff.Next()
+		n.GetNode().Logger().Warn("process exited, restarting", "error", err, "delay", delay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// runProcess runs the process until it exits, streaming its output
+func (n *ExecNode) runProcess(ctx context.Context, markReady func()) error {
+	cmd := n.command(n.config.Args)
+
+	var stdout, stderr io.Reader
+	var stdin io.WriteCloser
+	if n.config.PTY {
+		tty, err := startPTY(cmd)
+		if err != nil {
+			return fmt.Errorf("failed to start %s on a pty: %w", n.config.Command, err)
+		}
+		defer tty.Close()
+		stdout, stdin = tty, tty
+	} else {
+		setProcessGroup(cmd)
+		var err error
+		if stdin, err = cmd.StdinPipe(); err != nil {
+			return err
+		}
+		if stdout, err = cmd.StdoutPipe(); err != nil {
+			return err
+		}
+		if stderr, err = cmd.StderrPipe(); err != nil {
+			return err
+		}
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("failed to start %s: %w", n.config.Command, err)
+		}
+	}
+
+	n.mu.Lock()
+	n.stdin = stdin
+	n.mu.Unlock()
+	markReady()
+	defer func() {
+		n.mu.Lock()
+		n.stdin = nil
+		n.mu.Unlock()
+	}()
+
+	exited := make(chan struct{})
+	go n.stopOnDone(ctx, cmd, exited)
+
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		n.streamLines(stdout, 0)
+	}()
+	if stderr != nil {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			n.streamLines(stderr, 1)
+		}()
+	}
+	readers.Wait()
+
+	err := cmd.Wait()
+	close(exited)
+
+	msg := engine.NewMessage(nil, n.config.Command)
+	msg.SourceID = n.GetNode().ID
+	n.GetNode().Send(n.exitMessage(msg, cmd, err, ctx.Err()), 2)
+	return err
+}
+
+// streamLines sends every line read from r as a message
+func (n *ExecNode) streamLines(r io.Reader, port int) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxExecLineSize)
+	for scanner.Scan() {
+		line := bytes.TrimSuffix(scanner.Bytes(), []byte("\r"))
+		msg := engine.NewMessage(string(line), n.config.Command)
+		msg.SourceID = n.GetNode().ID
+		if err := n.GetNode().Send(msg, port); err != nil {
+			n.GetNode().Logger().Warn("failed to send output line", "error", err)
+		}
+	}
+}
+
+// stopOnDone terminates the process group when ctx is done before the
+// process exited
+func (n *ExecNode) stopOnDone(ctx context.Context, cmd *exec.Cmd, exited <-chan struct{}) {
+	select {
+	case <-exited:
+	case <-ctx.Done():
+		terminateProcess(cmd, n.stopGrace, exited)
+	}
+}
+
+// command builds the command with the node's directory and environment
+func (n *ExecNode) command(args []string) *exec.Cmd {
+	cmd := exec.Command(n.config.Command, args...)
+	cmd.Dir = n.config.Dir
+	if len(n.config.Env) > 0 {
+		cmd.Env = os.Environ()
+		for key, value := range n.config.Env {
+			cmd.Env = append(cmd.Env, key+"="+value)
+		}
+	}
+	return cmd
+}
+
+// exitMessage sets the exit code payload on msg
+func (n *ExecNode) exitMessage(msg *engine.Message, cmd *exec.Cmd, err, ctxErr error) *engine.Message {
+	code := -1
+	if cmd.ProcessState != nil {
+		code = cmd.ProcessState.ExitCode()
+	}
+	result := map[string]interface{}{"code": code}
+	if err != nil {
+		result["error"] = err.Error()
+	}
+	if ctxErr == context.DeadlineExceeded {
+		result["timeout"] = true
+	}
+	msg.Payload = result
+	return msg
+}
+
+// execPayload converts a payload to the bytes passed to a process
+func execPayload(payload interface{}) []byte {
+	switch v := payload.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return append([]byte{}, v...)
+	case string:
+		return []byte(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return []byte(fmt.Sprintf("%v", v))
+		}
+		return data
+	}
+}
@@ -0,0 +1,40 @@
+//go:build !windows
+
+package process
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/creack/pty"
+)
+
+// setProcessGroup starts the command in its own process group so that
+// children are signalled together
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// startPTY starts the command on a new pseudo terminal. The process becomes
+// a session leader, which also makes it the leader of its process group.
+func startPTY(cmd *exec.Cmd) (*os.File, error) {
+	return pty.Start(cmd)
+}
+
+// terminateProcess sends SIGTERM to the process group and SIGKILL if it has
+// not exited after grace
+func terminateProcess(cmd *exec.Cmd, grace time.Duration, exited <-chan struct{}) {
+	if cmd.Process == nil {
+		return
+	}
+	pgid := -cmd.Process.Pid
+
+	syscall.Kill(pgid, syscall.SIGTERM)
+	select {
+	case <-exited:
+	case <-time.After(grace):
+		syscall.Kill(pgid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build windows
+
+package process
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// setProcessGroup is a no-op on Windows
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// startPTY is not supported on Windows
+func startPTY(cmd *exec.Cmd) (*os.File, error) {
+	return nil, errors.New("pty is not supported on windows")
+}
+
+// terminateProcess kills the process; Windows has no SIGTERM
+func terminateProcess(cmd *exec.Cmd, grace time.Duration, exited <-chan struct{}) {
+	if cmd.Process != nil {
+		cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,150 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// Filter modes
+const (
+	// FilterModeChanged passes values that differ from the previous one
+	FilterModeChanged = "changed"
+	// FilterModeDeadband passes numbers that changed by more than the band
+	FilterModeDeadband = "deadband"
+)
+
+// FilterConfig represents the configuration of a filter node
+type FilterConfig struct {
+	// Mode is "changed" or "deadband"
+	Mode string `json:"mode" validate:"oneof=changed deadband"`
+	// Property is the path of the compared value
+	Property string `json:"property" validate:"required"`
+	// Band is the change a number must exceed in deadband mode, in units
+	// of the value or, with BandType "percent", in percent of the value it
+	// is compared to
+	Band     float64 `json:"band" validate:"min=0"`
+	BandType string  `json:"bandType" validate:"oneof=absolute percent"`
+	// CompareTo is "sent" to compare with the last value passed, which
+	// keeps slow drifts from going unnoticed, or "received" to compare
+	// with the last value seen
+	CompareTo string `json:"compareTo" validate:"oneof=sent received"`
+	// IgnoreInitial blocks the first value of every topic, only recording
+	// it
+	IgnoreInitial bool `json:"ignoreInitial"`
+	// PerTopic tracks every topic separately
+	PerTopic bool `json:"perTopic"`
+}
+
+// FilterNode passes messages only when their value changed, reporting by
+// exception. A message with the "reset" metadata forgets the value of its
+// topic, or of all topics if it has none, and is not passed.
+type FilterNode struct {
+	engine.BaseNode
+	config FilterConfig
+	last   map[string]interface{}
+	mu     sync.Mutex
+}
+
+// RegisterFilterNode registers the filter node type
+func RegisterFilterNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "filter",
+		Description: "Passes messages only when their value changes",
+		Category:    "process",
+		Defaults:    json.RawMessage(`{"mode":"changed","property":"payload","bandType":"absolute","compareTo":"sent","perTopic":true}`),
+		Factory: func() engine.NodeInstance {
+			return &FilterNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *FilterNode) Init(config json.RawMessage) error {
+	if err := n.LoadConfig(config, &n.config); err != nil {
+		return err
+	}
+	n.mu.Lock()
+	n.last = make(map[string]interface{})
+	n.mu.Unlock()
+	return nil
+}
+
+// Start starts the node
+func (n *FilterNode) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop stops the node
+func (n *FilterNode) Stop() {}
+
+// OnMessage passes the message if its value changed
+func (n *FilterNode) OnMessage(msg *engine.Message, port int) error {
+	key := ""
+	if n.config.PerTopic {
+		key = msg.Topic
+	}
+
+	if isResetMessage(msg) {
+		n.mu.Lock()
+		if key == "" {
+			n.last = make(map[string]interface{})
+		} else {
+			delete(n.last, key)
+		}
+		n.mu.Unlock()
+		return nil
+	}
+
+	raw, exists := msg.GetProperty(n.config.Property)
+	if !exists {
+		return fmt.Errorf("filter: message has no %s", n.config.Property)
+	}
+	value, err := jsonValue(raw)
+	if err != nil {
+		return fmt.Errorf("filter: cannot compare %s: %w", n.config.Property, err)
+	}
+	if n.config.Mode == FilterModeDeadband {
+		number, ok := engine.ToFloat(value)
+		if !ok {
+			return fmt.Errorf("filter: %s is not a number", n.config.Property)
+		}
+		value = number
+	}
+
+	n.mu.Lock()
+	last, seen := n.last[key]
+	pass := false
+	switch {
+	case !seen:
+		pass = !n.config.IgnoreInitial
+	case n.config.Mode == FilterModeDeadband:
+		pass = n.exceedsBand(value.(float64), last.(float64))
+	default:
+		pass = !reflect.DeepEqual(value, last)
+	}
+	if pass || !seen || n.config.CompareTo == "received" {
+		n.last[key] = value
+	}
+	n.mu.Unlock()
+
+	if !pass {
+		return nil
+	}
+	return n.GetNode().Send(msg, 0)
+}
+
+// exceedsBand reports whether value differs from last by more than the
+// band
+func (n *FilterNode) exceedsBand(value, last float64) bool {
+	band := n.config.Band
+	if n.config.BandType == "percent" {
+		band = math.Abs(last) * n.config.Band / 100
+	}
+	return math.Abs(value-last) > band
+}
@@ -0,0 +1,470 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/metrics"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// functionWrapper turns a script into a function; the script starts on the
+// first line, so that error positions match its lines
+const functionWrapper = "(function (msg, node, flow, global) {%s\n})"
+
+// functionAllocs is the runtime metric of the bytes the whole process has
+// allocated on the heap
+const functionAllocs = "/gc/heap/allocs:bytes"
+
+// errFunctionAllocs interrupts scripts during which the process allocates
+// more than MaxMemory
+var errFunctionAllocs = errors.New("allocation limit exceeded")
+
+// FunctionConfig represents the configuration of a function node
+type FunctionConfig struct {
+	// Func is the body of a JavaScript function of msg, which returns the
+	// message to send, null to send nothing, or an array with the
+	// messages of every output, each a message, null or an array of
+	// messages
+	Func string `json:"func" validate:"required"`
+	// Outputs is the number of outputs
+	Outputs int `json:"outputs" validate:"min=1"`
+	// Timeout interrupts a script running longer
+	Timeout string `json:"timeout" validate:"required,duration"`
+	// MaxMemory guards the process against runaway scripts: a script is
+	// interrupted when the process allocates more bytes while it runs.
+	// It is not a limit on the memory of the script, as the allocations of
+	// every other goroutine count too, so it is off by default and suits
+	// runtimes whose other flows are quiet; 0 for no guard.
+	MaxMemory int64 `json:"maxMemory" validate:"min=0"`
+}
+
+// FunctionNode runs a JavaScript function for every message. Besides msg,
+// the script has node.send(msg, port), node.done() to report the message
+// handled, node.log, node.warn and node.error, and flow.get/set and
+// global.get/set for the flow and global contexts.
+type FunctionNode struct {
+	engine.BaseNode
+	config  FunctionConfig
+	timeout time.Duration
+
+	vm *goja.Runtime
+	fn goja.Callable
+	mu sync.Mutex
+}
+
+// RegisterFunctionNode registers the function node type
+func RegisterFunctionNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "function",
+		Description: "Processes messages with JavaScript",
+		Category:    "process",
+		Defaults:    json.RawMessage(`{"func":"return msg;","outputs":1,"timeout":"1s","maxMemory":0}`),
+		Factory: func() engine.NodeInstance {
+			return &FunctionNode{}
+		},
+	})
+}
+
+// Init compiles the script
+func (n *FunctionNode) Init(config json.RawMessage) error {
+	if err := n.LoadConfig(config, &n.config); err != nil {
+		return err
+	}
+	timeout, err := time.ParseDuration(n.config.Timeout)
+	if err != nil || timeout <= 0 {
+		return fmt.Errorf("invalid timeout: %s", n.config.Timeout)
+	}
+	n.timeout = timeout
+
+	program, err := goja.Compile("function", fmt.Sprintf(functionWrapper, n.config.Func), true)
+	if err != nil {
+		return fmt.Errorf("invalid func: %w", err)
+	}
+	vm := goja.New()
+	value, err := vm.RunProgram(program)
+	if err != nil {
+		return fmt.Errorf("invalid func: %w", err)
+	}
+	fn, ok := goja.AssertFunction(value)
+	if !ok {
+		return fmt.Errorf("invalid func: not a function")
+	}
+
+	n.mu.Lock()
+	n.vm, n.fn = vm, fn
+	n.mu.Unlock()
+	return nil
+}
+
+// Start starts the node
+func (n *FunctionNode) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop stops the node
+func (n *FunctionNode) Stop() {}
+
+// OnMessage runs the script and sends what it passed to node.send, then
+// what it returned. Sending waits for the script to end, so that flows
+// looping back to the node do not deadlock.
+func (n *FunctionNode) OnMessage(msg *engine.Message, port int) error {
+	sends, err := n.call(msg)
+	if err != nil {
+		return fmt.Errorf("function: %w", err)
+	}
+	for _, out := range sends {
+		if err := n.GetNode().SendAll(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// call runs the script for a message and returns the messages to send
+func (n *FunctionNode) call(msg *engine.Message) ([][][]*engine.Message, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var sends [][][]*engine.Message
+	flow := n.GetNode().GetFlow()
+	result, err := n.run(
+		n.toJS(msg),
+		n.nodeObject(msg, &sends),
+		n.contextObject(flow.Context()),
+		n.contextObject(flow.GlobalContext()),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := n.messages(msg, result, -1)
+	if err != nil {
+		return nil, err
+	}
+	if out != nil {
+		sends = append(sends, out)
+	}
+	return sends, nil
+}
+
+// run calls the script, interrupting it on timeout or when the process
+// allocates too much
+func (n *FunctionNode) run(args ...goja.Value) (goja.Value, error) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		n.watch(done)
+	}()
+	defer func() {
+		close(done)
+		wg.Wait()
+		n.vm.ClearInterrupt()
+	}()
+
+	result, err := n.fn(goja.Undefined(), args...)
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) {
+		if cause, ok := interrupted.Value().(error); ok {
+			return nil, cause
+		}
+	}
+	return result, err
+}
+
+// watch interrupts the running script on timeout or when the process
+// allocates more than MaxMemory, until done is closed
+func (n *FunctionNode) watch(done <-chan struct{}) {
+	timer := time.NewTimer(n.timeout)
+	defer timer.Stop()
+	var tick <-chan time.Time
+	var start uint64
+	sample := []metrics.Sample{{Name: functionAllocs}}
+	if n.config.MaxMemory > 0 {
+		metrics.Read(sample)
+		start = sample[0].Value.Uint64()
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-timer.C:
+			n.vm.Interrupt(fmt.Errorf("timed out after %s", n.timeout))
+			return
+		case <-tick:
+			metrics.Read(sample)
+			if sample[0].Value.Uint64()-start > uint64(n.config.MaxMemory) {
+				n.vm.Interrupt(errFunctionAllocs)
+				return
+			}
+		}
+	}
+}
+
+// messages converts what the script returns or passes to node.send to the
+// messages of every output; port is the output of a single message or
+// array, or -1 for the return value layout
+func (n *FunctionNode) messages(original *engine.Message, value goja.Value, port int) ([][]*engine.Message, error) {
+	if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+		return nil, nil
+	}
+	out := make([][]*engine.Message, n.config.Outputs)
+
+	if port >= 0 {
+		if port >= n.config.Outputs {
+			return nil, fmt.Errorf("output %d does not exist", port)
+		}
+		msgs, err := n.portMessages(original, value)
+		if err != nil {
+			return nil, err
+		}
+		out[port] = msgs
+		return out, nil
+	}
+
+	if _, isArray := value.Export().([]interface{}); !isArray {
+		msgs, err := n.portMessages(original, value)
+		if err != nil {
+			return nil, err
+		}
+		out[0] = msgs
+		return out, nil
+	}
+	ports := value.ToObject(n.vm)
+	length := int(ports.Get("length").ToInteger())
+	if length > n.config.Outputs {
+		return nil, fmt.Errorf("%d outputs returned, the node has %d", length, n.config.Outputs)
+	}
+	for i := 0; i < length; i++ {
+		msgs, err := n.portMessages(original, ports.Get(fmt.Sprint(i)))
+		if err != nil {
+			return nil, err
+		}
+		out[i] = msgs
+	}
+	return out, nil
+}
+
+// portMessages converts a message, null or an array of messages
+func (n *FunctionNode) portMessages(original *engine.Message, value goja.Value) ([]*engine.Message, error) {
+	if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+		return nil, nil
+	}
+	if list, isArray := fromJS(value.Export()).([]interface{}); isArray {
+		var msgs []*engine.Message
+		for _, item := range list {
+			if item == nil {
+				continue
+			}
+			msg, err := toMessage(original, item)
+			if err != nil {
+				return nil, err
+			}
+			msgs = append(msgs, msg)
+		}
+		return msgs, nil
+	}
+	msg, err := toMessage(original, fromJS(value.Export()))
+	if err != nil {
+		return nil, err
+	}
+	return []*engine.Message{msg}, nil
+}
+
+// nodeObject builds the node object of the script, whose send adds to
+// sends
+func (n *FunctionNode) nodeObject(original *engine.Message, sends *[][][]*engine.Message) *goja.Object {
+	node := n.GetNode()
+	object := n.vm.NewObject()
+	object.Set("id", node.ID)
+	object.Set("name", node.Name)
+	object.Set("outputs", n.config.Outputs)
+	object.Set("send", func(call goja.FunctionCall) goja.Value {
+		port := -1
+		if len(call.Arguments) > 1 && !goja.IsUndefined(call.Argument(1)) {
+			port = int(call.Argument(1).ToInteger())
+		}
+		out, err := n.messages(original, call.Argument(0), port)
+		if err != nil {
+			panic(n.vm.NewGoError(err))
+		}
+		if out != nil {
+			*sends = append(*sends, out)
+		}
+		return goja.Undefined()
+	})
+	object.Set("log", func(call goja.FunctionCall) goja.Value {
+		node.Logger().Info(joinArguments(call))
+		return goja.Undefined()
+	})
+	object.Set("done", func() {
+		node.Done(original)
+	})
+	object.Set("warn", func(call goja.FunctionCall) goja.Value {
+		node.Logger().Warn(joinArguments(call))
+		return goja.Undefined()
+	})
+	object.Set("error", func(call goja.FunctionCall) goja.Value {
+		node.Logger().Error(joinArguments(call))
+		return goja.Undefined()
+	})
+	return object
+}
+
+// contextObject builds the get/set object of a context for the script
+func (n *FunctionNode) contextObject(ctx *engine.Context) *goja.Object {
+	object := n.vm.NewObject()
+	object.Set("get", func(key string) goja.Value {
+		value, exists := ctx.Get(key)
+		if !exists {
+			return goja.Undefined()
+		}
+		return n.toJSValue(value)
+	})
+	// set(key, value, {persistent: true}) writes the value through to the
+	// context store
+	object.Set("set", func(call goja.FunctionCall) goja.Value {
+		key, value := call.Argument(0).String(), call.Argument(1)
+		persistent := false
+		if options, ok := call.Argument(2).(*goja.Object); ok {
+			if flag := options.Get("persistent"); flag != nil {
+				persistent = flag.ToBoolean()
+			}
+		}
+		switch {
+		case goja.IsUndefined(value) || goja.IsNull(value):
+			ctx.Delete(key)
+		case persistent:
+			ctx.SetPersistent(key, fromJS(value.Export()))
+		default:
+			ctx.Set(key, fromJS(value.Export()))
+		}
+		return goja.Undefined()
+	})
+	object.Set("keys", func() []string {
+		return ctx.Keys()
+	})
+	return object
+}
+
+// toJS converts a message to the msg object of the script
+func (n *FunctionNode) toJS(msg *engine.Message) goja.Value {
+	headers := make(map[string]interface{}, len(msg.Headers))
+	for key, value := range msg.Headers {
+		headers[key] = value
+	}
+	return n.toJSValue(map[string]interface{}{
+		"_msgid":   msg.MsgID,
+		"topic":    msg.Topic,
+		"payload":  msg.Payload,
+		"headers":  headers,
+		"metadata": msg.Metadata,
+	})
+}
+
+// toJSValue converts a message value to a native JavaScript value, so that
+// scripts may change objects and arrays freely; bytes become an
+// ArrayBuffer
+func (n *FunctionNode) toJSValue(value interface{}) goja.Value {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		object := n.vm.NewObject()
+		for key, item := range v {
+			object.Set(key, n.toJSValue(item))
+		}
+		return object
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = n.toJSValue(item)
+		}
+		return n.vm.NewArray(items...)
+	case []byte:
+		return n.vm.ToValue(n.vm.NewArrayBuffer(append([]byte(nil), v...)))
+	case json.Number:
+		if i, ok := engine.ToInt(v); ok {
+			return n.vm.ToValue(i)
+		}
+		f, _ := engine.ToFloat(v)
+		return n.vm.ToValue(f)
+	default:
+		return n.vm.ToValue(v)
+	}
+}
+
+// fromJS converts an exported JavaScript value to a message value
+func fromJS(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = fromJS(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = fromJS(item)
+		}
+		return v
+	case goja.ArrayBuffer:
+		return append([]byte(nil), v.Bytes()...)
+	default:
+		return v
+	}
+}
+
+// toMessage builds a message from a msg object of the script, keeping the
+// delivery of the original message
+func toMessage(original *engine.Message, value interface{}) (*engine.Message, error) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("messages must be objects, not %T", value)
+	}
+
+	msg := original.Clone()
+	msg.Payload = object["payload"]
+	msg.Topic, _ = object["topic"].(string)
+	if id, ok := object["_msgid"].(string); ok && id != "" {
+		msg.MsgID = id
+	} else {
+		msg.MsgID = engine.NewUUID()
+	}
+	msg.Metadata = make(map[string]interface{})
+	if metadata, ok := object["metadata"].(map[string]interface{}); ok {
+		msg.Metadata = metadata
+	}
+	msg.Headers = make(map[string]string)
+	if headers, ok := object["headers"].(map[string]interface{}); ok {
+		for key, value := range headers {
+			msg.Headers[key] = fmt.Sprint(value)
+		}
+	}
+	return msg, nil
+}
+
+// joinArguments formats the arguments of node.log and the like
+func joinArguments(call goja.FunctionCall) string {
+	parts := make([]string, len(call.Arguments))
+	for i, argument := range call.Arguments {
+		if object, ok := argument.(*goja.Object); ok && object.ClassName() != "Error" {
+			if data, err := json.Marshal(object.Export()); err == nil {
+				parts[i] = string(data)
+				continue
+			}
+		}
+		parts[i] = argument.String()
+	}
+	return strings.Join(parts, " ")
+}
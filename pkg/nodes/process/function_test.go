@@ -0,0 +1,107 @@
+package process_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yourusername/go-red/internal/engine"
+	"github.com/yourusername/go-red/internal/registry"
+	"github.com/yourusername/go-red/internal/storage"
+	"github.com/yourusername/go-red/pkg/nodes/process"
+)
+
+// startFunction deploys a function node with config passing what it
+// returns to a collector
+func startFunction(t *testing.T, config string) (*engine.Node, *collectNode) {
+	t.Helper()
+	collector := &collectNode{}
+	reg := registry.New()
+	if err := process.RegisterFunctionNode(reg); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.RegisterNodeType(&engine.NodeType{
+		Name:    "collect",
+		Factory: func() engine.NodeInstance { return collector },
+	}); err != nil {
+		t.Fatal(err)
+	}
+	eng := engine.New(reg, storage.NewMemoryStorage())
+	if err := eng.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { eng.Stop() })
+	flowDef := fmt.Sprintf(`{
+		"id": "script",
+		"nodes": [
+			{"id": "function", "type": "function", "config": %s},
+			{"id": "out", "type": "collect"}
+		],
+		"wires": [{"source": "function", "target": "out", "port": 0}]
+	}`, config)
+	if err := eng.DeployFlow("script", []byte(flowDef)); err != nil {
+		t.Fatal(err)
+	}
+	flow, _ := eng.GetFlow("script")
+	function, _ := flow.GetNode("function")
+	return function, collector
+}
+
+// allocate allocates on the heap until stop is closed
+func allocate(stop <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+	var sink [][]byte
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if sink = append(sink, make([]byte, 1<<20)); len(sink) > 16 {
+			sink = sink[:0]
+		}
+	}
+}
+
+func TestFunctionIgnoresAllocationsElsewhere(t *testing.T) {
+	// A script that allocates little runs while the rest of the process
+	// allocates hundreds of megabytes
+	function, collector := startFunction(t, `{
+		"func": "var end = Date.now() + 300; while (Date.now() < end) {} return msg;",
+		"outputs": 1,
+		"timeout": "5s"
+	}`)
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go allocate(stop, &wg)
+	err := function.Receive(engine.NewMessage("x", ""), 0)
+	close(stop)
+	wg.Wait()
+
+	if err != nil {
+		t.Fatalf("script was interrupted by allocations elsewhere: %v", err)
+	}
+	if got := collector.last(); got != "x" {
+		t.Errorf("sent %v, want x", got)
+	}
+}
+
+func TestFunctionAllocationGuard(t *testing.T) {
+	function, collector := startFunction(t, `{
+		"func": "var a = []; for (var i = 0; i < 1e8; i++) { a.push({i: i}); } return msg;",
+		"outputs": 1,
+		"timeout": "30s",
+		"maxMemory": 1048576
+	}`)
+
+	err := function.Receive(engine.NewMessage("x", ""), 0)
+	if err == nil || !strings.Contains(err.Error(), "allocation limit exceeded") {
+		t.Fatalf("Receive() error = %v, want the allocation limit exceeded", err)
+	}
+	if got := collector.last(); got != nil {
+		t.Errorf("interrupted script sent %v", got)
+	}
+}
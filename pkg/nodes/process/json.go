@@ -0,0 +1,131 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// JSON node modes
+const (
+	JSONModeAuto      = "auto"
+	JSONModeParse     = "parse"
+	JSONModeStringify = "stringify"
+)
+
+// JSONConfig represents the configuration of a json node
+type JSONConfig struct {
+	// Mode is "parse", "stringify", or "auto", which parses text and
+	// stringifies everything else
+	Mode     string `json:"mode" validate:"oneof=auto parse stringify"`
+	Property string `json:"property" validate:"required"`
+	// Indent pretty prints stringified values with this many spaces
+	Indent int `json:"indent" validate:"min=0,max=8"`
+}
+
+// JSONNode converts a message property between JSON text and values. In
+// parse mode values that are not text pass unchanged, in stringify mode
+// text does. Text that fails to parse is sent unchanged on the second
+// output with the error in the "error" metadata.
+type JSONNode struct {
+	engine.BaseNode
+	config JSONConfig
+}
+
+// RegisterJSONNode registers the json node type
+func RegisterJSONNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "json",
+		Description: "Converts between JSON text and values",
+		Category:    "process",
+		Defaults:    json.RawMessage(`{"mode":"auto","property":"payload","indent":0}`),
+		Parallel:    true,
+		Factory: func() engine.NodeInstance {
+			return &JSONNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *JSONNode) Init(config json.RawMessage) error {
+	return n.LoadConfig(config, &n.config)
+}
+
+// Start starts the node
+func (n *JSONNode) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop stops the node
+func (n *JSONNode) Stop() {}
+
+// OnMessage parses or stringifies the configured property
+func (n *JSONNode) OnMessage(msg *engine.Message, port int) error {
+	value, exists := msg.GetProperty(n.config.Property)
+	if !exists {
+		return fmt.Errorf("json: message has no %s", n.config.Property)
+	}
+
+	// Streams are read once, so the text stays available on failure
+	if stream, ok := value.(*engine.Stream); ok {
+		data, err := stream.Bytes()
+		if err != nil {
+			return fmt.Errorf("json: failed to read stream: %w", err)
+		}
+		value = data
+		if err := msg.SetProperty(n.config.Property, data); err != nil {
+			return fmt.Errorf("json: %w", err)
+		}
+	}
+
+	var result interface{}
+	var err error
+	switch value := value.(type) {
+	case string:
+		if n.config.Mode == JSONModeStringify {
+			return n.GetNode().Send(msg, 0)
+		}
+		err = engine.ReadJSON(strings.NewReader(value), &result)
+	case []byte:
+		if n.config.Mode == JSONModeStringify {
+			return n.GetNode().Send(msg, 0)
+		}
+		err = engine.ReadJSON(bytes.NewReader(value), &result)
+	default:
+		if n.config.Mode == JSONModeParse {
+			return n.GetNode().Send(msg, 0)
+		}
+		if result, err = n.stringify(value); err != nil {
+			return fmt.Errorf("json: %w", err)
+		}
+	}
+	if err != nil {
+		msg.SetMetadata("error", fmt.Sprintf("invalid JSON: %v", err))
+		return n.GetNode().Send(msg, 1)
+	}
+
+	if err := msg.SetProperty(n.config.Property, result); err != nil {
+		return fmt.Errorf("json: %w", err)
+	}
+	return n.GetNode().Send(msg, 0)
+}
+
+// stringify encodes value as JSON text, writing it only once
+func (n *JSONNode) stringify(value interface{}) (string, error) {
+	var out strings.Builder
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	if n.config.Indent > 0 {
+		encoder.SetIndent("", strings.Repeat(" ", n.config.Indent))
+	}
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	// Encode ends the text with a newline
+	text := out.String()
+	return text[:len(text)-1], nil
+}
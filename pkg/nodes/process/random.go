@@ -0,0 +1,219 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// Random generator modes
+const (
+	RandomModeInt    = "int"
+	RandomModeFloat  = "float"
+	RandomModeChoice = "choice"
+	RandomModeString = "string"
+	RandomModeUUID   = "uuid"
+)
+
+// defaultRandomAlphabet is used by the string mode when no alphabet is set
+const defaultRandomAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// RandomConfig represents the configuration of a random node
+type RandomConfig struct {
+	// Mode is one of int, float, choice, string or uuid
+	Mode string `json:"mode"`
+	// Property is the property the generated value is written to
+	Property string `json:"property"`
+	// Min and Max bound the int and float modes, both inclusive
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+	// Choices is the list the choice mode selects from
+	Choices []interface{} `json:"choices"`
+	// Length and Alphabet configure the string mode
+	Length   int    `json:"length"`
+	Alphabet string `json:"alphabet"`
+	// Seed makes the node deterministic when set, which rules out running
+	// several instances
+	Seed *int64 `json:"seed"`
+}
+
+// RandomNode sets a message property to a generated random value
+type RandomNode struct {
+	engine.BaseNode
+	config RandomConfig
+	rng    *rand.Rand
+	mu     sync.Mutex
+}
+
+// RegisterRandomNode registers the random node type
+func RegisterRandomNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "random",
+		Description: "Generates random numbers, strings, selections and UUIDs",
+		Category:    "process",
+		Defaults:    json.RawMessage(`{"mode":"int","property":"payload","min":1,"max":10}`),
+		Parallel:    true,
+		Factory: func() engine.NodeInstance {
+			return &RandomNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *RandomNode) Init(config json.RawMessage) error {
+	n.config = RandomConfig{
+		Mode:     RandomModeInt,
+		Property: "payload",
+		Min:      1,
+		Max:      10,
+	}
+	if err := n.LoadConfig(config, &n.config); err != nil {
+		return err
+	}
+
+	switch n.config.Mode {
+	case RandomModeInt, RandomModeFloat:
+		if n.config.Min > n.config.Max {
+			return fmt.Errorf("min must not be greater than max")
+		}
+		if n.config.Mode == RandomModeInt {
+			min, max := math.Ceil(n.config.Min), math.Floor(n.config.Max)
+			if min > max || min >= int64Limit || max < -int64Limit {
+				return fmt.Errorf("no integer between min and max")
+			}
+		}
+	case RandomModeChoice:
+		if len(n.config.Choices) == 0 {
+			return fmt.Errorf("choices are required in %q mode", RandomModeChoice)
+		}
+	case RandomModeString:
+		if n.config.Length <= 0 {
+			return fmt.Errorf("length must be positive in %q mode", RandomModeString)
+		}
+		if n.config.Alphabet == "" {
+			n.config.Alphabet = defaultRandomAlphabet
+		}
+	case RandomModeUUID:
+	default:
+		return fmt.Errorf("invalid random mode: %s", n.config.Mode)
+	}
+
+	seed := time.Now().UnixNano()
+	if n.config.Seed != nil {
+		seed = *n.config.Seed
+	}
+	n.rng = rand.New(rand.NewSource(seed))
+	return nil
+}
+
+// CheckParallel rules out several instances of a seeded node, as each
+// would generate the same sequence
+func (n *RandomNode) CheckParallel() error {
+	if n.config.Seed != nil {
+		return fmt.Errorf("a seeded random node generates one sequence; remove the seed or run one instance")
+	}
+	return nil
+}
+
+// Start starts the node
+func (n *RandomNode) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop stops the node
+func (n *RandomNode) Stop() {}
+
+// OnMessage processes a message
+func (n *RandomNode) OnMessage(msg *engine.Message, port int) error {
+	value, err := n.generate()
+	if err != nil {
+		return fmt.Errorf("random: %w", err)
+	}
+
+	if err := msg.SetProperty(n.config.Property, value); err != nil {
+		return fmt.Errorf("random: %w", err)
+	}
+	return n.GetNode().Send(msg, 0)
+}
+
+// generate produces the next random value
+func (n *RandomNode) generate() (interface{}, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	switch n.config.Mode {
+	case RandomModeInt:
+		lo, hi := intBounds(n.config.Min, n.config.Max)
+		return randomInt(n.rng, lo, hi), nil
+	case RandomModeFloat:
+		return randomFloat(n.rng, n.config.Min, n.config.Max), nil
+	case RandomModeChoice:
+		return n.config.Choices[n.rng.Intn(len(n.config.Choices))], nil
+	case RandomModeString:
+		alphabet := []rune(n.config.Alphabet)
+		result := make([]rune, n.config.Length)
+		for i := range result {
+			result[i] = alphabet[n.rng.Intn(len(alphabet))]
+		}
+		return string(result), nil
+	default:
+		if n.config.Seed == nil {
+			return engine.NewUUID(), nil
+		}
+		return engine.NewUUIDFromReader(n.rng)
+	}
+}
+
+// int64Limit is 2^63, the first float64 beyond the range of int64
+const int64Limit = 1 << 63
+
+// intBounds returns the integers the int mode generates between: both
+// bounds are inclusive, fractional ones round inwards and those beyond the
+// range of int64 are limited to it
+func intBounds(min, max float64) (int64, int64) {
+	return toInt64(math.Ceil(min)), toInt64(math.Floor(max))
+}
+
+// toInt64 converts a whole float64, limiting it to the range of int64
+func toInt64(f float64) int64 {
+	switch {
+	case f >= int64Limit:
+		return math.MaxInt64
+	case f <= -int64Limit:
+		return math.MinInt64
+	}
+	return int64(f)
+}
+
+// randomInt returns a uniformly distributed integer in [lo, hi]
+func randomInt(rng *rand.Rand, lo, hi int64) int64 {
+	// span wraps to 0 for the whole range of int64
+	span := uint64(hi) - uint64(lo) + 1
+	switch {
+	case span == 0:
+		return int64(rng.Uint64())
+	case span <= math.MaxInt64:
+		return lo + rng.Int63n(int64(span))
+	}
+	// More than half of all values are in range, so few draws are rejected
+	for {
+		if v := rng.Uint64(); v < span {
+			return int64(uint64(lo) + v)
+		}
+	}
+}
+
+// randomFloat returns a uniformly distributed float64 in [min, max]
+func randomFloat(rng *rand.Rand, min, max float64) float64 {
+	// u is in [0, 1], both inclusive, in steps of 2^-53
+	u := float64(rng.Int63n(1<<53+1)) / (1 << 53)
+	// Weighting the bounds cannot overflow as max-min can
+	value := min*(1-u) + max*u
+	return math.Max(min, math.Min(max, value))
+}
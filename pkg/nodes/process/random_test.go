@@ -0,0 +1,153 @@
+package process
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// fixedSource is a rand.Source that always returns the same value
+type fixedSource int64
+
+func (s fixedSource) Int63() int64 { return int64(s) }
+func (s fixedSource) Seed(int64)   {}
+
+func TestIntBounds(t *testing.T) {
+	tests := []struct {
+		name     string
+		min, max float64
+		lo, hi   int64
+	}{
+		{"whole", 1, 10, 1, 10},
+		{"fractional", 1.5, 9.5, 2, 9},
+		{"negative", -9.5, -1.5, -9, -2},
+		{"beyond int64", -1e30, 1e30, math.MinInt64, math.MaxInt64},
+		{"int64 limits", -int64Limit, int64Limit, math.MinInt64, math.MaxInt64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lo, hi := intBounds(tt.min, tt.max)
+			if lo != tt.lo || hi != tt.hi {
+				t.Errorf("intBounds(%g, %g) = %d, %d, want %d, %d", tt.min, tt.max, lo, hi, tt.lo, tt.hi)
+			}
+		})
+	}
+}
+
+func TestRandomInt(t *testing.T) {
+	tests := []struct {
+		name   string
+		lo, hi int64
+	}{
+		{"single value", 7, 7},
+		{"small", 1, 3},
+		{"top of int64", math.MaxInt64 - 2, math.MaxInt64},
+		{"bottom of int64", math.MinInt64, math.MinInt64 + 2},
+		{"over half of int64", -1, math.MaxInt64},
+		{"all of int64", math.MinInt64, math.MaxInt64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			seen := make(map[int64]bool)
+			for i := 0; i < 1000; i++ {
+				v := randomInt(rng, tt.lo, tt.hi)
+				if v < tt.lo || v > tt.hi {
+					t.Fatalf("randomInt(%d, %d) = %d", tt.lo, tt.hi, v)
+				}
+				seen[v] = true
+			}
+			// Small ranges must reach both bounds
+			if uint64(tt.hi)-uint64(tt.lo) < 3 && (!seen[tt.lo] || !seen[tt.hi]) {
+				t.Errorf("randomInt(%d, %d) never returned a bound", tt.lo, tt.hi)
+			}
+		})
+	}
+}
+
+func TestRandomFloat(t *testing.T) {
+	tests := []struct {
+		name     string
+		min, max float64
+	}{
+		{"unit", 0, 1},
+		{"negative", -10, -5},
+		{"single value", 2.5, 2.5},
+		{"all of float64", -math.MaxFloat64, math.MaxFloat64},
+		{"tiny", math.SmallestNonzeroFloat64, 2 * math.SmallestNonzeroFloat64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The lowest and highest draws return the bounds themselves
+			if v := randomFloat(rand.New(fixedSource(0)), tt.min, tt.max); v != tt.min {
+				t.Errorf("lowest draw = %g, want %g", v, tt.min)
+			}
+			if v := randomFloat(rand.New(fixedSource(1<<53)), tt.min, tt.max); v != tt.max {
+				t.Errorf("highest draw = %g, want %g", v, tt.max)
+			}
+			rng := rand.New(rand.NewSource(1))
+			for i := 0; i < 1000; i++ {
+				if v := randomFloat(rng, tt.min, tt.max); v < tt.min || v > tt.max || math.IsNaN(v) {
+					t.Fatalf("randomFloat(%g, %g) = %g", tt.min, tt.max, v)
+				}
+			}
+		})
+	}
+}
+
+func TestRandomInit(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{"defaults", `{}`, false},
+		{"int beyond int64", `{"mode": "int", "min": -1e30, "max": 1e30}`, false},
+		{"int min above max", `{"mode": "int", "min": 5, "max": 1}`, true},
+		{"int without integer", `{"mode": "int", "min": 0.2, "max": 0.8}`, true},
+		{"int above int64", `{"mode": "int", "min": 1e19, "max": 2e19}`, true},
+		{"int below int64", `{"mode": "int", "min": -2e19, "max": -1e19}`, true},
+		{"float single value", `{"mode": "float", "min": 1.5, "max": 1.5}`, false},
+		{"choice without choices", `{"mode": "choice"}`, true},
+		{"string without length", `{"mode": "string"}`, true},
+		{"unknown mode", `{"mode": "dice"}`, true},
+		{"wrong type", `{"min": "one"}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &RandomNode{}
+			err := n.Init(json.RawMessage(tt.config))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Init(%s) error = %v, want error %v", tt.config, err, tt.wantErr)
+			}
+			if err == nil {
+				if _, err := n.generate(); err != nil {
+					t.Errorf("generate: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestRandomCheckParallel(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{"unseeded", `{}`, false},
+		{"seeded", `{"seed": 42}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &RandomNode{}
+			if err := n.Init(json.RawMessage(tt.config)); err != nil {
+				t.Fatal(err)
+			}
+			if err := n.CheckParallel(); (err != nil) != tt.wantErr {
+				t.Errorf("CheckParallel() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
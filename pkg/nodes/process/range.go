@@ -0,0 +1,141 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// Range modes
+const (
+	// RangeModeScale scales without limits
+	RangeModeScale = "scale"
+	// RangeModeClamp limits the result to the target range
+	RangeModeClamp = "clamp"
+	// RangeModeWrap wraps the result around within the target range
+	RangeModeWrap = "wrap"
+	// RangeModeConstrain drops values outside the source range
+	RangeModeConstrain = "constrain"
+)
+
+// RangeConfig represents the configuration of a range node
+type RangeConfig struct {
+	// Property is the path of the numeric value
+	Property string `json:"property" validate:"required"`
+	// Target is the property the result is written to; defaults to Property
+	Target string `json:"target"`
+	// FromMin and FromMax are the source range, ToMin and ToMax the target
+	// range; either may be inverted
+	FromMin float64 `json:"fromMin"`
+	FromMax float64 `json:"fromMax"`
+	ToMin   float64 `json:"toMin"`
+	ToMax   float64 `json:"toMax"`
+	// Mode is "scale", "clamp", "wrap" or "constrain"
+	Mode string `json:"mode" validate:"oneof=scale clamp wrap constrain"`
+	// Round is "none", "nearest", "floor" or "ceil", to Decimals decimals
+	Round    string `json:"round" validate:"oneof=none nearest floor ceil"`
+	Decimals int    `json:"decimals" validate:"min=0"`
+}
+
+// RangeNode linearly maps a number from one range to another. Messages
+// whose value is missing or not a number are sent on the second output
+// with the "error" metadata.
+type RangeNode struct {
+	engine.BaseNode
+	config RangeConfig
+}
+
+// RegisterRangeNode registers the range node type
+func RegisterRangeNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "range",
+		Description: "Scales a number from one range to another",
+		Category:    "process",
+		Defaults:    json.RawMessage(`{"property":"payload","fromMin":0,"fromMax":1023,"toMin":0,"toMax":100,"mode":"scale","round":"none"}`),
+		Parallel:    true,
+		Factory: func() engine.NodeInstance {
+			return &RangeNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *RangeNode) Init(config json.RawMessage) error {
+	if err := n.LoadConfig(config, &n.config); err != nil {
+		return err
+	}
+	if n.config.FromMin == n.config.FromMax {
+		return fmt.Errorf("the source range must not be empty")
+	}
+	if n.config.Mode == RangeModeWrap && n.config.ToMin == n.config.ToMax {
+		return fmt.Errorf("the target range must not be empty in %q mode", RangeModeWrap)
+	}
+	if n.config.Target == "" {
+		n.config.Target = n.config.Property
+	}
+	return nil
+}
+
+// Start starts the node
+func (n *RangeNode) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop stops the node
+func (n *RangeNode) Stop() {}
+
+// OnMessage scales the value
+func (n *RangeNode) OnMessage(msg *engine.Message, port int) error {
+	raw, exists := msg.GetProperty(n.config.Property)
+	value, ok := engine.ToFloat(raw)
+	if !exists || !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+		msg.SetMetadata("error", fmt.Sprintf("range: %s is not a number", n.config.Property))
+		return n.GetNode().Send(msg, 1)
+	}
+
+	result, keep := n.scale(value)
+	if !keep {
+		return nil
+	}
+	if err := msg.SetProperty(n.config.Target, result); err != nil {
+		return fmt.Errorf("range: %w", err)
+	}
+	return n.GetNode().Send(msg, 0)
+}
+
+// scale maps a value to the target range; keep is false for values the
+// constrain mode drops
+func (n *RangeNode) scale(value float64) (result float64, keep bool) {
+	c := n.config
+	if c.Mode == RangeModeConstrain {
+		lo, hi := math.Min(c.FromMin, c.FromMax), math.Max(c.FromMin, c.FromMax)
+		if value < lo || value > hi {
+			return 0, false
+		}
+	}
+
+	result = c.ToMin + (value-c.FromMin)*(c.ToMax-c.ToMin)/(c.FromMax-c.FromMin)
+
+	lo, hi := math.Min(c.ToMin, c.ToMax), math.Max(c.ToMin, c.ToMax)
+	switch c.Mode {
+	case RangeModeClamp:
+		result = math.Max(lo, math.Min(hi, result))
+	case RangeModeWrap:
+		// The target range is half-open, so that hi wraps to lo
+		result = lo + math.Mod(math.Mod(result-lo, hi-lo)+(hi-lo), hi-lo)
+	}
+
+	scale := math.Pow(10, float64(c.Decimals))
+	switch c.Round {
+	case "nearest":
+		result = math.Round(result*scale) / scale
+	case "floor":
+		result = math.Floor(result*scale) / scale
+	case "ceil":
+		result = math.Ceil(result*scale) / scale
+	}
+	return result, true
+}
@@ -0,0 +1,56 @@
+package process
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRangeScale(t *testing.T) {
+	base := RangeConfig{FromMin: 0, FromMax: 10, ToMin: 0, ToMax: 100, Mode: RangeModeScale, Round: "none"}
+	with := func(change func(*RangeConfig)) RangeConfig {
+		c := base
+		change(&c)
+		return c
+	}
+	tests := []struct {
+		name   string
+		config RangeConfig
+		value  float64
+		want   float64
+		keep   bool
+	}{
+		{"scale lower bound", base, 0, 0, true},
+		{"scale upper bound", base, 10, 100, true},
+		{"scale below", base, -1, -10, true},
+		{"scale above", base, 11, 110, true},
+		{"inverted target", with(func(c *RangeConfig) { c.ToMin, c.ToMax = 100, 0 }), 10, 0, true},
+		{"inverted source", with(func(c *RangeConfig) { c.FromMin, c.FromMax = 10, 0 }), 10, 0, true},
+		{"clamp below", with(func(c *RangeConfig) { c.Mode = RangeModeClamp }), -1, 0, true},
+		{"clamp above", with(func(c *RangeConfig) { c.Mode = RangeModeClamp }), 11, 100, true},
+		{"clamp inverted target", with(func(c *RangeConfig) { c.Mode, c.ToMin, c.ToMax = RangeModeClamp, 100, 0 }), 11, 0, true},
+		{"wrap upper bound", with(func(c *RangeConfig) { c.Mode = RangeModeWrap }), 10, 0, true},
+		{"wrap lower bound", with(func(c *RangeConfig) { c.Mode = RangeModeWrap }), 0, 0, true},
+		{"wrap below", with(func(c *RangeConfig) { c.Mode = RangeModeWrap }), -1, 90, true},
+		{"wrap above", with(func(c *RangeConfig) { c.Mode = RangeModeWrap }), 12, 20, true},
+		{"constrain lower bound", with(func(c *RangeConfig) { c.Mode = RangeModeConstrain }), 0, 0, true},
+		{"constrain upper bound", with(func(c *RangeConfig) { c.Mode = RangeModeConstrain }), 10, 100, true},
+		{"constrain below", with(func(c *RangeConfig) { c.Mode = RangeModeConstrain }), -0.001, 0, false},
+		{"constrain above", with(func(c *RangeConfig) { c.Mode = RangeModeConstrain }), 10.001, 0, false},
+		{"round nearest", with(func(c *RangeConfig) { c.Round, c.Decimals = "nearest", 1 }), 0.0125, 0.1, true},
+		{"round floor", with(func(c *RangeConfig) { c.Round = "floor" }), 0.19, 1, true},
+		{"round ceil", with(func(c *RangeConfig) { c.Round = "ceil" }), 0.11, 2, true},
+		{"round negative", with(func(c *RangeConfig) { c.Round = "floor" }), -0.11, -2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &RangeNode{config: tt.config}
+			got, keep := n.scale(tt.value)
+			if keep != tt.keep {
+				t.Fatalf("scale(%g) keep = %v, want %v", tt.value, keep, tt.keep)
+			}
+			if keep && math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("scale(%g) = %g, want %g", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// Smoothing methods
+const (
+	SmoothMethodMean    = "mean"
+	SmoothMethodEMA     = "ema"
+	SmoothMethodLowPass = "lowpass"
+	SmoothMethodMin     = "min"
+	SmoothMethodMax     = "max"
+	SmoothMethodStdDev  = "stddev"
+)
+
+// Handling of values that are not numbers
+const (
+	SmoothNonNumericError = "error"
+	SmoothNonNumericPass  = "pass"
+	SmoothNonNumericRoute = "route"
+)
+
+// defaultSmoothMaxSamples bounds the window state of a single topic
+const defaultSmoothMaxSamples = 1000
+
+// SmoothConfig represents the configuration of a smooth node
+type SmoothConfig struct {
+	// Method is one of mean, ema, lowpass, min, max or stddev
+	Method string `json:"method"`
+	// Property is the path of the numeric value, e.g. "payload.temperature"
+	Property string `json:"property"`
+	// Target is the property the result is written to; defaults to Property
+	Target string `json:"target"`
+	// Count is the number of most recent values in the window
+	Count int `json:"count"`
+	// Window is a time window such as "30s", used instead of or together
+	// with Count
+	Window string `json:"window"`
+	// Alpha is the smoothing factor of the ema method; lowpass uses
+	// 1/Count
+	Alpha float64 `json:"alpha"`
+	// MaxSamples bounds the number of values kept per topic
+	MaxSamples int `json:"maxSamples"`
+	// Round is the number of decimals the result is rounded to; negative
+	// leaves it unrounded
+	Round int `json:"round"`
+	// NonNumeric is what happens to messages whose value is missing or not
+	// a number: "error", "pass" to send them on unchanged, or "route" to
+	// send them on the second output
+	NonNumeric string `json:"nonNumeric"`
+}
+
+// SmoothNode computes rolling statistics over a numeric message property,
+// tracked per topic
+type SmoothNode struct {
+	engine.BaseNode
+	config SmoothConfig
+	window time.Duration
+	topics map[string]*smoothState
+	mu     sync.Mutex
+}
+
+// smoothState holds the window of one topic
+type smoothState struct {
+	samples []smoothSample
+	ema     float64
+	hasEMA  bool
+}
+
+// smoothSample is a single value in the window
+type smoothSample struct {
+	value float64
+	at    time.Time
+}
+
+// RegisterSmoothNode registers the smooth node type
+func RegisterSmoothNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "smooth",
+		Description: "Computes rolling statistics over numeric values",
+		Category:    "process",
+		Defaults:    json.RawMessage(`{"method":"mean","property":"payload","count":10,"alpha":0.5,"round":-1,"nonNumeric":"error"}`),
+		Factory: func() engine.NodeInstance {
+			return &SmoothNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *SmoothNode) Init(config json.RawMessage) error {
+	n.config = SmoothConfig{
+		Method:     SmoothMethodMean,
+		Property:   "payload",
+		Alpha:      0.5,
+		Round:      -1,
+		NonNumeric: SmoothNonNumericError,
+	}
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &n.config); err != nil {
+			return fmt.Errorf("invalid smooth config: %w", err)
+		}
+	}
+
+	switch n.config.Method {
+	case SmoothMethodMean, SmoothMethodEMA, SmoothMethodLowPass, SmoothMethodMin, SmoothMethodMax, SmoothMethodStdDev:
+	default:
+		return fmt.Errorf("invalid smooth method: %s", n.config.Method)
+	}
+	switch n.config.NonNumeric {
+	case SmoothNonNumericError, SmoothNonNumericPass, SmoothNonNumericRoute:
+	default:
+		return fmt.Errorf("invalid nonNumeric handling: %s", n.config.NonNumeric)
+	}
+
+	if n.config.Target == "" {
+		n.config.Target = n.config.Property
+	}
+	if n.config.Count < 0 {
+		return fmt.Errorf("count must not be negative")
+	}
+	if n.config.Method == SmoothMethodEMA && (n.config.Alpha <= 0 || n.config.Alpha > 1) {
+		return fmt.Errorf("alpha must be in the range (0, 1]")
+	}
+	if n.config.MaxSamples <= 0 {
+		n.config.MaxSamples = defaultSmoothMaxSamples
+	}
+
+	window, err := parseDuration("window", n.config.Window)
+	if err != nil {
+		return err
+	}
+	if n.config.Count == 0 && window == 0 {
+		n.config.Count = 10
+	}
+	if n.config.Method == SmoothMethodLowPass && n.config.Count == 0 {
+		return fmt.Errorf("the lowpass method requires a count")
+	}
+
+	n.window = window
+	n.topics = make(map[string]*smoothState)
+	return nil
+}
+
+// Start starts the node
+func (n *SmoothNode) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop stops the node
+func (n *SmoothNode) Stop() {}
+
+// OnMessage processes a message
+func (n *SmoothNode) OnMessage(msg *engine.Message, port int) error {
+	if isResetMessage(msg) {
+		n.mu.Lock()
+		if msg.Topic == "" {
+			n.topics = make(map[string]*smoothState)
+		} else {
+			delete(n.topics, msg.Topic)
+		}
+		n.mu.Unlock()
+		return nil
+	}
+
+	raw, exists := msg.GetProperty(n.config.Property)
+	value, ok := engine.ToFloat(raw)
+	if !exists || !ok {
+		switch n.config.NonNumeric {
+		case SmoothNonNumericPass:
+			return n.GetNode().Send(msg, 0)
+		case SmoothNonNumericRoute:
+			return n.GetNode().Send(msg, 1)
+		}
+		if !exists {
+			return fmt.Errorf("smooth: property %s not found", n.config.Property)
+		}
+		return fmt.Errorf("smooth: property %s is not numeric (got %T)", n.config.Property, raw)
+	}
+
+	n.mu.Lock()
+	state, exists := n.topics[msg.Topic]
+	if !exists {
+		state = &smoothState{}
+		n.topics[msg.Topic] = state
+	}
+	result := n.update(state, value, time.Now())
+	n.mu.Unlock()
+
+	if n.config.Round >= 0 {
+		scale := math.Pow(10, float64(n.config.Round))
+		result = math.Round(result*scale) / scale
+	}
+
+	if err := msg.SetProperty(n.config.Target, result); err != nil {
+		return fmt.Errorf("smooth: %w", err)
+	}
+	return n.GetNode().Send(msg, 0)
+}
+
+// update adds a value to the topic window and returns the new result
+func (n *SmoothNode) update(state *smoothState, value float64, now time.Time) float64 {
+	state.samples = append(state.samples, smoothSample{value: value, at: now})
+
+	// Trim the window by count, age and the hard sample limit
+	start := 0
+	if n.config.Count > 0 && len(state.samples)-start > n.config.Count {
+		start = len(state.samples) - n.config.Count
+	}
+	if n.window > 0 {
+		cutoff := now.Add(-n.window)
+		for start < len(state.samples)-1 && state.samples[start].at.Before(cutoff) {
+			start++
+		}
+	}
+	if len(state.samples)-start > n.config.MaxSamples {
+		start = len(state.samples) - n.config.MaxSamples
+	}
+	if start > 0 {
+		state.samples = append(state.samples[:0], state.samples[start:]...)
+	}
+
+	switch n.config.Method {
+	case SmoothMethodEMA, SmoothMethodLowPass:
+		alpha := n.config.Alpha
+		if n.config.Method == SmoothMethodLowPass {
+			alpha = 1 / float64(n.config.Count)
+		}
+		if !state.hasEMA {
+			state.ema = value
+			state.hasEMA = true
+		} else {
+			state.ema = alpha*value + (1-alpha)*state.ema
+		}
+		return state.ema
+	case SmoothMethodMin:
+		result := math.Inf(1)
+		for _, s := range state.samples {
+			result = math.Min(result, s.value)
+		}
+		return result
+	case SmoothMethodMax:
+		result := math.Inf(-1)
+		for _, s := range state.samples {
+			result = math.Max(result, s.value)
+		}
+		return result
+	case SmoothMethodStdDev:
+		mean := sampleMean(state.samples)
+		var variance float64
+		for _, s := range state.samples {
+			variance += (s.value - mean) * (s.value - mean)
+		}
+		return math.Sqrt(variance / float64(len(state.samples)))
+	default:
+		return sampleMean(state.samples)
+	}
+}
+
+// sampleMean returns the mean of the sample values
+func sampleMean(samples []smoothSample) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, s := range samples {
+		sum += s.value
+	}
+	return sum / float64(len(samples))
+}
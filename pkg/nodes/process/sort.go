@@ -0,0 +1,352 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// Sort targets
+const (
+	// SortTargetPayload sorts an array in the message
+	SortTargetPayload = "payload"
+	// SortTargetSequence sorts the messages of a sequence
+	SortTargetSequence = "sequence"
+)
+
+// SortConfig represents the configuration of a sort node
+type SortConfig struct {
+	// Target is "payload" or "sequence"
+	Target string `json:"target" validate:"oneof=payload sequence"`
+	// Property is the path of the array sorted in payload mode
+	Property string `json:"property" validate:"required"`
+	// Key is the path of the sort key: within every element in payload
+	// mode, empty for the element itself, or within every message in
+	// sequence mode, e.g. "payload.time" or "topic"
+	Key string `json:"key"`
+	// Order is "ascending" or "descending"
+	Order string `json:"order" validate:"oneof=ascending descending"`
+	// Compare is "number", which orders values that are not numbers after
+	// the numbers, or "string"
+	Compare string `json:"compare" validate:"oneof=number string"`
+	// Timeout is how long a sequence may take to complete before its
+	// messages are sent sorted as they are
+	Timeout string `json:"timeout" validate:"duration"`
+}
+
+// SortNode sorts an array in the message, or the messages of a sequence
+// delimited by "parts" metadata ({"id", "index", "count"}). A sequence is
+// sent once complete, with the indexes renumbered in the new order.
+// Messages without parts pass through in sequence mode.
+type SortNode struct {
+	engine.BaseNode
+	config  SortConfig
+	timeout time.Duration
+
+	sequences map[string]*sortSequence
+	mu        sync.Mutex
+	wake      chan struct{}
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// sortSequence holds the messages of an incomplete sequence
+type sortSequence struct {
+	msgs     []*engine.Message
+	releases []func(error)
+	count    int
+	deadline time.Time
+}
+
+// RegisterSortNode registers the sort node type
+func RegisterSortNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "sort",
+		Description: "Sorts an array payload or a sequence of messages",
+		Category:    "process",
+		Defaults:    json.RawMessage(`{"target":"payload","property":"payload","order":"ascending","compare":"number","timeout":"1m"}`),
+		Factory: func() engine.NodeInstance {
+			return &SortNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *SortNode) Init(config json.RawMessage) error {
+	if err := n.LoadConfig(config, &n.config); err != nil {
+		return err
+	}
+	timeout, err := time.ParseDuration(n.config.Timeout)
+	if err != nil || timeout <= 0 {
+		return fmt.Errorf("invalid timeout: %s", n.config.Timeout)
+	}
+	n.timeout = timeout
+	if n.config.Target == SortTargetSequence && n.config.Key == "" {
+		n.config.Key = "payload"
+	}
+	n.wake = make(chan struct{}, 1)
+	return nil
+}
+
+// Start starts the sequence timeouts
+func (n *SortNode) Start(ctx context.Context) error {
+	n.mu.Lock()
+	n.sequences = make(map[string]*sortSequence)
+	n.mu.Unlock()
+
+	if n.config.Target != SortTargetSequence {
+		return nil
+	}
+	ctx, n.cancel = context.WithCancel(ctx)
+	n.wg.Add(1)
+	go n.run(ctx)
+	return nil
+}
+
+// Stop stops the timeouts and discards the incomplete sequences
+func (n *SortNode) Stop() {
+	if n.cancel != nil {
+		n.cancel()
+		n.cancel = nil
+	}
+	n.wg.Wait()
+
+	n.mu.Lock()
+	sequences := n.sequences
+	n.sequences = nil
+	n.mu.Unlock()
+
+	for _, sequence := range sequences {
+		sequence.settle(fmt.Errorf("sort: sequence discarded on stop"))
+	}
+}
+
+// OnMessage sorts the payload array or adds the message to its sequence
+func (n *SortNode) OnMessage(msg *engine.Message, port int) error {
+	if n.config.Target == SortTargetPayload {
+		value, exists := msg.GetProperty(n.config.Property)
+		if !exists {
+			return fmt.Errorf("sort: message has no %s", n.config.Property)
+		}
+		array, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("sort: %s is not an array (got %T)", n.config.Property, value)
+		}
+		keys := make([]interface{}, len(array))
+		for i, element := range array {
+			keys[i] = elementValue(element, n.config.Key)
+		}
+		n.sort(keys, func(i, j int) {
+			array[i], array[j] = array[j], array[i]
+		})
+		return n.GetNode().Send(msg, 0)
+	}
+
+	id, count, ok := messageParts(msg)
+	if !ok {
+		return n.GetNode().Send(msg, 0)
+	}
+
+	n.mu.Lock()
+	if n.sequences == nil {
+		n.mu.Unlock()
+		return nil // stopped
+	}
+	sequence, exists := n.sequences[id]
+	if !exists {
+		sequence = &sortSequence{deadline: n.GetNode().Clock().Now().Add(n.timeout)}
+		n.sequences[id] = sequence
+	}
+	sequence.msgs = append(sequence.msgs, msg)
+	sequence.releases = append(sequence.releases, msg.Retain())
+	if count > 0 {
+		sequence.count = count
+	}
+	complete := sequence.count > 0 && len(sequence.msgs) >= sequence.count
+	if complete {
+		delete(n.sequences, id)
+	}
+	n.mu.Unlock()
+
+	if !exists {
+		select {
+		case n.wake <- struct{}{}:
+		default:
+		}
+	}
+	if complete {
+		n.emit(sequence)
+	}
+	return nil
+}
+
+// run sends the sequences that timed out
+func (n *SortNode) run(ctx context.Context) {
+	defer n.wg.Done()
+
+	clock := n.GetNode().Clock()
+	for {
+		var timer engine.Timer
+		var fire <-chan time.Time
+		if next, ok := n.nextDeadline(); ok {
+			timer = clock.NewTimer(next.Sub(clock.Now()))
+			fire = timer.C()
+		}
+
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-n.wake:
+		case <-fire:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+
+		for _, sequence := range n.expired(clock.Now()) {
+			n.GetNode().Logger().Warn("sequence incomplete, sending it", "timeout", n.timeout, "messages", len(sequence.msgs))
+			n.emit(sequence)
+		}
+	}
+}
+
+// nextDeadline returns the earliest sequence timeout
+func (n *SortNode) nextDeadline() (time.Time, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var next time.Time
+	for _, sequence := range n.sequences {
+		if next.IsZero() || sequence.deadline.Before(next) {
+			next = sequence.deadline
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// expired removes and returns the sequences past their timeout
+func (n *SortNode) expired(now time.Time) []*sortSequence {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var out []*sortSequence
+	for id, sequence := range n.sequences {
+		if now.Before(sequence.deadline) {
+			continue
+		}
+		delete(n.sequences, id)
+		out = append(out, sequence)
+	}
+	return out
+}
+
+// emit sorts and sends a sequence, renumbering the parts
+func (n *SortNode) emit(sequence *sortSequence) {
+	msgs := sequence.msgs
+	keys := make([]interface{}, len(msgs))
+	for i, msg := range msgs {
+		keys[i], _ = msg.GetProperty(n.config.Key)
+	}
+	n.sort(keys, func(i, j int) {
+		msgs[i], msgs[j] = msgs[j], msgs[i]
+	})
+
+	for i, msg := range msgs {
+		parts, _ := msg.Metadata["parts"].(map[string]interface{})
+		renumbered := make(map[string]interface{}, len(parts))
+		for key, value := range parts {
+			renumbered[key] = value
+		}
+		renumbered["index"] = i
+		renumbered["count"] = len(msgs)
+		msg.SetMetadata("parts", renumbered)
+	}
+	sequence.settle(n.GetNode().SendAll([][]*engine.Message{msgs}))
+}
+
+// settle reports the outcome to the deliveries of the sequence's messages
+func (s *sortSequence) settle(err error) {
+	for _, release := range s.releases {
+		release(err)
+	}
+	s.releases = nil
+}
+
+// sort stably orders keys, applying every swap to the sorted items too
+func (n *SortNode) sort(keys []interface{}, swap func(i, j int)) {
+	sort.Stable(sortKeys{
+		keys:       keys,
+		swap:       swap,
+		numeric:    n.config.Compare == "number",
+		descending: n.config.Order == "descending",
+	})
+}
+
+// sortKeys implements sort.Interface over keys, mirroring swaps to items
+type sortKeys struct {
+	keys       []interface{}
+	swap       func(i, j int)
+	numeric    bool
+	descending bool
+}
+
+func (s sortKeys) Len() int { return len(s.keys) }
+
+func (s sortKeys) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.swap(i, j)
+}
+
+func (s sortKeys) Less(i, j int) bool {
+	if s.descending {
+		i, j = j, i
+	}
+	a, b := s.keys[i], s.keys[j]
+	if s.numeric {
+		x, xok := engine.ToFloat(a)
+		y, yok := engine.ToFloat(b)
+		switch {
+		case xok && yok:
+			return x < y
+		case xok != yok:
+			// Numbers before other values, whatever the order
+			return xok != s.descending
+		}
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+// elementValue returns the value at a path within an array element, or the
+// element itself for an empty path
+func elementValue(element interface{}, path string) interface{} {
+	if path == "" {
+		return element
+	}
+	value, _ := (&engine.Message{Payload: element}).GetProperty("payload." + path)
+	return value
+}
+
+// messageParts returns the sequence id and count of a message's "parts"
+// metadata; count is 0 if not known yet. Parts without an index, such as
+// those of a batch, describe an array rather than a sequence.
+func messageParts(msg *engine.Message) (string, int, bool) {
+	parts, ok := msg.Metadata["parts"].(map[string]interface{})
+	if !ok {
+		return "", 0, false
+	}
+	id, ok := parts["id"].(string)
+	if _, indexed := parts["index"]; !ok || id == "" || !indexed {
+		return "", 0, false
+	}
+	count, _ := engine.ToInt(parts["count"])
+	return id, int(count), true
+}
@@ -0,0 +1,391 @@
+package process
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql" // registers the "mysql" database/sql driver
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the "pgx" database/sql driver
+	_ "github.com/mattn/go-sqlite3"    // registers the "sqlite3" database/sql driver
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// SQL statement modes
+const (
+	SQLModeAuto  = "auto"
+	SQLModeQuery = "query"
+	SQLModeExec  = "exec"
+)
+
+// SQL result outputs
+const (
+	SQLOutputRows     = "rows"
+	SQLOutputMessages = "messages"
+)
+
+// sqlDrivers maps each database to its database/sql driver. SQLite needs a
+// binary built with cgo.
+var sqlDrivers = map[string]string{
+	"postgres": "pgx",
+	"mysql":    "mysql",
+	"sqlite":   "sqlite3",
+}
+
+// SQLConfig represents the configuration of a sql node
+type SQLConfig struct {
+	// Database is "postgres", "mysql" or "sqlite"
+	Database string `json:"database" validate:"oneof=postgres mysql sqlite"`
+	DSN      string `json:"dsn" validate:"required"`
+	// Query uses the placeholders of the database, $1 for PostgreSQL and
+	// ? for the others
+	Query string `json:"query" validate:"required"`
+	// Params are the property paths of the query's parameters, e.g.
+	// "payload.id" or "metadata.user". Without them an array payload
+	// holds the parameters.
+	Params []string `json:"params"`
+	// Mode is "query" for statements returning rows, "exec" for the
+	// others, or "auto" to tell them apart by the statement
+	Mode string `json:"mode" validate:"oneof=auto query exec"`
+	// Output sends the rows as a single array, or one message each
+	Output  string `json:"output" validate:"oneof=rows messages"`
+	Timeout string `json:"timeout" validate:"duration"`
+	// MaxConnections limits the open connections of the pool; nodes
+	// sharing a DSN share the limit of the first one started
+	MaxConnections int `json:"maxConnections" validate:"min=0"`
+}
+
+// SQLNode runs a query for every message. Rows replace the payload, as
+// an array of objects or one message each; statements without rows set
+// it to {"rowsAffected": n, "lastInsertId": id}. Failures are sent on
+// the second output with the "error" metadata.
+type SQLNode struct {
+	engine.BaseNode
+	config  SQLConfig
+	driver  string
+	query   bool
+	timeout time.Duration
+
+	pool   *sqlPool
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// RegisterSQLNode registers the sql node type
+func RegisterSQLNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "sql",
+		Description: "Runs a query on a PostgreSQL, MySQL or SQLite database",
+		Category:    "process",
+		Defaults:    json.RawMessage(`{"database":"postgres","mode":"auto","output":"rows","timeout":"30s"}`),
+		Credentials: []string{"dsn"},
+		Parallel:    true,
+		Factory: func() engine.NodeInstance {
+			return &SQLNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *SQLNode) Init(config json.RawMessage) error {
+	if err := n.LoadConfig(config, &n.config); err != nil {
+		return err
+	}
+
+	if n.config.Database == "sqlite" && !sqliteAvailable {
+		return fmt.Errorf("sqlite needs a go-red binary built with cgo (CGO_ENABLED=1)")
+	}
+	n.driver = sqlDrivers[n.config.Database]
+
+	timeout, err := time.ParseDuration(n.config.Timeout)
+	if err != nil || timeout <= 0 {
+		return fmt.Errorf("invalid timeout: %s", n.config.Timeout)
+	}
+	n.timeout = timeout
+
+	switch n.config.Mode {
+	case SQLModeQuery:
+		n.query = true
+	case SQLModeExec:
+		n.query = false
+	default:
+		n.query = returnsRows(n.config.Query)
+	}
+	return nil
+}
+
+// Start opens the pool of the DSN, or joins it
+func (n *SQLNode) Start(ctx context.Context) error {
+	pool, err := acquireSQLPool(n.driver, n.config.DSN, n.config.MaxConnections)
+	if err != nil {
+		return err
+	}
+	n.pool = pool
+	n.ctx, n.cancel = context.WithCancel(ctx)
+	return nil
+}
+
+// Stop cancels running queries and leaves the pool
+func (n *SQLNode) Stop() {
+	if n.cancel != nil {
+		n.cancel()
+	}
+	if n.pool != nil {
+		n.pool.release()
+		n.pool = nil
+	}
+}
+
+// OnMessage runs the query with the message's parameters
+func (n *SQLNode) OnMessage(msg *engine.Message, port int) error {
+	args, err := n.args(msg)
+	if err != nil {
+		return n.fail(msg, err)
+	}
+
+	ctx, cancel := context.WithTimeout(n.ctx, n.timeout)
+	defer cancel()
+	stmt, err := n.pool.prepare(ctx, n.config.Query)
+	if err != nil {
+		return n.fail(msg, err)
+	}
+
+	if !n.query {
+		result, err := stmt.ExecContext(ctx, args...)
+		if err != nil {
+			return n.fail(msg, err)
+		}
+		payload := make(map[string]interface{})
+		if affected, err := result.RowsAffected(); err == nil {
+			payload["rowsAffected"] = affected
+		}
+		if id, err := result.LastInsertId(); err == nil {
+			payload["lastInsertId"] = id
+		}
+		msg.Payload = payload
+		return n.GetNode().Send(msg, 0)
+	}
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return n.fail(msg, err)
+	}
+	objects, err := scanRows(rows)
+	if err != nil {
+		return n.fail(msg, err)
+	}
+
+	if n.config.Output == SQLOutputMessages {
+		out := make([]*engine.Message, len(objects))
+		for i, object := range objects {
+			out[i] = msg.Clone()
+			out[i].Payload = object
+			out[i].SetMetadata("index", i)
+			out[i].SetMetadata("count", len(objects))
+		}
+		return n.GetNode().SendAll([][]*engine.Message{out})
+	}
+	msg.Payload = objects
+	return n.GetNode().Send(msg, 0)
+}
+
+// fail sends the message on the second output with the error
+func (n *SQLNode) fail(msg *engine.Message, err error) error {
+	msg.SetMetadata("error", err.Error())
+	return n.GetNode().Send(msg, 1)
+}
+
+// args returns the query's parameters from the message
+func (n *SQLNode) args(msg *engine.Message) ([]interface{}, error) {
+	if len(n.config.Params) == 0 {
+		values, _ := msg.Payload.([]interface{})
+		args := make([]interface{}, len(values))
+		for i, value := range values {
+			args[i] = sqlArg(value)
+		}
+		return args, nil
+	}
+
+	args := make([]interface{}, len(n.config.Params))
+	for i, path := range n.config.Params {
+		value, exists := msg.GetProperty(path)
+		if !exists {
+			return nil, fmt.Errorf("message has no %s", path)
+		}
+		args[i] = sqlArg(value)
+	}
+	return args, nil
+}
+
+// sqlArg converts a message value to a query parameter. Objects and arrays
+// become JSON text, e.g. for JSON columns.
+func sqlArg(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, ok := engine.ToInt(v); ok {
+			return i
+		}
+		f, _ := engine.ToFloat(v)
+		return f
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(data)
+	default:
+		return value
+	}
+}
+
+// returnsRows reports whether a statement returns rows, judging by its
+// first keyword and RETURNING clauses
+func returnsRows(query string) bool {
+	fields := strings.Fields(strings.ToUpper(query))
+	if len(fields) == 0 {
+		return false
+	}
+	switch fields[0] {
+	case "SELECT", "WITH", "SHOW", "VALUES", "EXPLAIN", "DESCRIBE", "PRAGMA", "TABLE":
+		return true
+	}
+	for _, field := range fields {
+		if field == "RETURNING" {
+			return true
+		}
+	}
+	return false
+}
+
+// scanRows reads rows into objects keyed by column name. Text columns
+// become strings, binary ones stay bytes and times use RFC 3339.
+func scanRows(rows *sql.Rows) ([]interface{}, error) {
+	defer rows.Close()
+
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	binary := make([]bool, len(columns))
+	for i, column := range columns {
+		name := strings.ToUpper(column.DatabaseTypeName())
+		binary[i] = strings.Contains(name, "BLOB") || strings.Contains(name, "BYTEA") || strings.Contains(name, "BINARY")
+	}
+
+	objects := []interface{}{}
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		object := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			switch value := values[i].(type) {
+			case []byte:
+				if binary[i] {
+					object[column.Name()] = append([]byte(nil), value...)
+				} else {
+					object[column.Name()] = string(value)
+				}
+			case time.Time:
+				object[column.Name()] = value.Format(time.RFC3339Nano)
+			default:
+				object[column.Name()] = value
+			}
+		}
+		objects = append(objects, object)
+	}
+	return objects, rows.Err()
+}
+
+// sqlPoolKey identifies a pool by driver and DSN
+type sqlPoolKey struct {
+	driver string
+	dsn    string
+}
+
+// sqlPools holds the pools in use
+var sqlPools = struct {
+	sync.Mutex
+	pools map[sqlPoolKey]*sqlPool
+}{pools: make(map[sqlPoolKey]*sqlPool)}
+
+// sqlPool is a connection pool shared by the sql nodes using a DSN, with
+// their prepared statements
+type sqlPool struct {
+	key  sqlPoolKey
+	db   *sql.DB
+	refs int
+
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+// acquireSQLPool returns the pool of a DSN, opening it for its first user.
+// Users release it when done.
+func acquireSQLPool(driver, dsn string, maxConnections int) (*sqlPool, error) {
+	sqlPools.Lock()
+	defer sqlPools.Unlock()
+
+	key := sqlPoolKey{driver, dsn}
+	if p := sqlPools.pools[key]; p != nil {
+		p.refs++
+		return p, nil
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	db.SetMaxOpenConns(maxConnections)
+	p := &sqlPool{key: key, db: db, refs: 1, stmts: make(map[string]*sql.Stmt)}
+	sqlPools.pools[key] = p
+	return p, nil
+}
+
+// prepare returns the prepared statement of a query, preparing it on
+// first use
+func (p *sqlPool) prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if stmt := p.stmts[query]; stmt != nil {
+		return stmt, nil
+	}
+	stmt, err := p.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	p.stmts[query] = stmt
+	return stmt, nil
+}
+
+// release gives up a use of the pool; the last one closes it
+func (p *sqlPool) release() {
+	sqlPools.Lock()
+	p.refs--
+	last := p.refs == 0
+	if last {
+		delete(sqlPools.pools, p.key)
+	}
+	sqlPools.Unlock()
+	if !last {
+		return
+	}
+
+	p.mu.Lock()
+	for _, stmt := range p.stmts {
+		stmt.Close()
+	}
+	p.mu.Unlock()
+	p.db.Close()
+}
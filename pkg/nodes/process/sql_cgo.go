@@ -0,0 +1,7 @@
+//go:build cgo
+
+package process
+
+// sqliteAvailable reports whether the sqlite3 driver works, which needs a
+// binary built with cgo
+const sqliteAvailable = true
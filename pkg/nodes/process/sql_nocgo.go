@@ -0,0 +1,7 @@
+//go:build !cgo
+
+package process
+
+// sqliteAvailable reports whether the sqlite3 driver works; without cgo
+// it only reports that it needs cgo
+const sqliteAvailable = false
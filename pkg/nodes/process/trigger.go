@@ -0,0 +1,288 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// Trigger send modes
+const (
+	// TriggerSendPayload sends the message with the configured payload
+	TriggerSendPayload = "payload"
+	// TriggerSendOriginal sends the message that started the wait
+	TriggerSendOriginal = "original"
+	// TriggerSendLatest sends the last message received while waiting
+	TriggerSendLatest = "latest"
+	// TriggerSendNothing sends nothing
+	TriggerSendNothing = "nothing"
+)
+
+// TriggerConfig represents the configuration of a trigger node
+type TriggerConfig struct {
+	// FirstMode is what is sent when a message starts the wait, "payload"
+	// sending First, and SecondMode what is sent when the delay ends,
+	// "payload" sending Second
+	FirstMode  string          `json:"firstMode" validate:"oneof=payload original nothing"`
+	First      json.RawMessage `json:"first"`
+	SecondMode string          `json:"secondMode" validate:"oneof=payload original latest nothing"`
+	Second     json.RawMessage `json:"second"`
+	// Delay is the wait before the second message; "0s" waits until reset
+	Delay string `json:"delay" validate:"required,duration"`
+	// Extend restarts the delay on every message while waiting; otherwise
+	// those messages are ignored
+	Extend bool `json:"extend"`
+	// Reset is a payload that ends the wait without sending the second
+	// message, as does the "reset" metadata
+	Reset json.RawMessage `json:"reset"`
+	// PerTopic keeps an independent wait for every topic
+	PerTopic bool `json:"perTopic"`
+	// SecondOutput sends the second message on the second output
+	SecondOutput bool `json:"secondOutput"`
+}
+
+// TriggerNode sends a message as soon as one arrives, then a second one
+// after a delay unless it is reset first. Extending the delay on every
+// message makes it a watchdog: only the second message is sent, once
+// messages stop.
+type TriggerNode struct {
+	engine.BaseNode
+	config TriggerConfig
+	delay  time.Duration
+	reset  interface{}
+
+	waits map[string]*triggerWait
+	mu    sync.Mutex
+	wake  chan struct{}
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// triggerWait is a running wait
+type triggerWait struct {
+	deadline time.Time
+	original *engine.Message
+	latest   *engine.Message
+}
+
+// RegisterTriggerNode registers the trigger node type
+func RegisterTriggerNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "trigger",
+		Description: "Sends a message, then another after a delay unless reset",
+		Category:    "process",
+		Defaults:    json.RawMessage(`{"firstMode":"payload","first":1,"secondMode":"payload","second":0,"delay":"250ms"}`),
+		Factory: func() engine.NodeInstance {
+			return &TriggerNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *TriggerNode) Init(config json.RawMessage) error {
+	if err := n.LoadConfig(config, &n.config); err != nil {
+		return err
+	}
+	for name, raw := range map[string]json.RawMessage{"first": n.config.First, "second": n.config.Second, "reset": n.config.Reset} {
+		if len(raw) > 0 && !json.Valid(raw) {
+			return fmt.Errorf("invalid %s payload", name)
+		}
+	}
+
+	n.delay, _ = time.ParseDuration(n.config.Delay)
+	n.reset = nil
+	if len(n.config.Reset) > 0 {
+		if err := engine.DecodeJSON(n.config.Reset, &n.reset); err != nil {
+			return fmt.Errorf("invalid reset payload: %w", err)
+		}
+	}
+	n.wake = make(chan struct{}, 1)
+	return nil
+}
+
+// Start starts the timers
+func (n *TriggerNode) Start(ctx context.Context) error {
+	n.mu.Lock()
+	n.waits = make(map[string]*triggerWait)
+	n.mu.Unlock()
+
+	ctx, n.cancel = context.WithCancel(ctx)
+	n.wg.Add(1)
+	go n.run(ctx)
+	return nil
+}
+
+// Stop stops the timers and drops the running waits
+func (n *TriggerNode) Stop() {
+	if n.cancel != nil {
+		n.cancel()
+		n.cancel = nil
+	}
+	n.wg.Wait()
+
+	n.mu.Lock()
+	n.waits = nil
+	n.mu.Unlock()
+}
+
+// OnMessage starts, extends or resets the wait of the message's topic
+func (n *TriggerNode) OnMessage(msg *engine.Message, port int) error {
+	key := ""
+	if n.config.PerTopic {
+		key = msg.Topic
+	}
+	now := n.GetNode().Clock().Now()
+
+	n.mu.Lock()
+	if n.waits == nil {
+		n.mu.Unlock()
+		return nil // stopped
+	}
+	if n.isReset(msg) {
+		delete(n.waits, key)
+		n.mu.Unlock()
+		n.signal()
+		return nil
+	}
+	if wait, exists := n.waits[key]; exists {
+		wait.latest = msg
+		if n.config.Extend && n.delay > 0 {
+			wait.deadline = now.Add(n.delay)
+		}
+		n.mu.Unlock()
+		n.signal()
+		return nil
+	}
+	n.waits[key] = &triggerWait{deadline: now.Add(n.delay), original: msg, latest: msg}
+	n.mu.Unlock()
+	n.signal()
+
+	first := n.message(n.config.FirstMode, n.config.First, msg, msg)
+	if first == nil {
+		return nil
+	}
+	return n.GetNode().Send(first, 0)
+}
+
+// isReset reports whether a message ends the wait of its topic
+func (n *TriggerNode) isReset(msg *engine.Message) bool {
+	if isResetMessage(msg) {
+		return true
+	}
+	if n.reset == nil {
+		return false
+	}
+	payload, err := jsonValue(msg.Payload)
+	return err == nil && reflect.DeepEqual(payload, n.reset)
+}
+
+// signal wakes the timer goroutine to look at the deadlines again
+func (n *TriggerNode) signal() {
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+}
+
+// message builds a message to send in a mode, nil for "nothing"
+func (n *TriggerNode) message(mode string, payload json.RawMessage, original, latest *engine.Message) *engine.Message {
+	switch mode {
+	case TriggerSendOriginal:
+		return original.Clone()
+	case TriggerSendLatest:
+		return latest.Clone()
+	case TriggerSendPayload:
+		msg := original.Clone()
+		msg.Payload = nil
+		if len(payload) > 0 {
+			// Decoded for every message, so that receivers may modify it
+			engine.DecodeJSON(payload, &msg.Payload)
+		}
+		return msg
+	default:
+		return nil
+	}
+}
+
+// run sends the second messages when deadlines pass
+func (n *TriggerNode) run(ctx context.Context) {
+	defer n.wg.Done()
+
+	clock := n.GetNode().Clock()
+	for {
+		var timer engine.Timer
+		var fire <-chan time.Time
+		if next, ok := n.nextDeadline(); ok {
+			timer = clock.NewTimer(next.Sub(clock.Now()))
+			fire = timer.C()
+		}
+
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-n.wake:
+		case <-fire:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+
+		port := 0
+		if n.config.SecondOutput {
+			port = 1
+		}
+		for _, msg := range n.expired(clock.Now()) {
+			n.GetNode().Send(msg, port)
+		}
+	}
+}
+
+// nextDeadline returns the earliest deadline of the waits with a delay
+func (n *TriggerNode) nextDeadline() (time.Time, bool) {
+	if n.delay == 0 {
+		return time.Time{}, false
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var next time.Time
+	for _, wait := range n.waits {
+		if next.IsZero() || wait.deadline.Before(next) {
+			next = wait.deadline
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// expired ends the waits past their deadline and returns the second
+// messages
+func (n *TriggerNode) expired(now time.Time) []*engine.Message {
+	if n.delay == 0 {
+		return nil
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var out []*engine.Message
+	for key, wait := range n.waits {
+		if now.Before(wait.deadline) {
+			continue
+		}
+		delete(n.waits, key)
+		if msg := n.message(n.config.SecondMode, n.config.Second, wait.original, wait.latest); msg != nil {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
@@ -0,0 +1,59 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// isResetMessage reports whether a message carries the "reset" control flag
+// in its metadata
+func isResetMessage(msg *engine.Message) bool {
+	value, exists := msg.GetMetadata("reset")
+	if !exists {
+		return false
+	}
+
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v != "" && v != "false"
+	default:
+		return value != nil
+	}
+}
+
+// parseDuration parses an optional duration string such as "5s" or "1m",
+// returning 0 for an empty string
+func parseDuration(name, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", name, value, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must not be negative", name, value)
+	}
+
+	return d, nil
+}
+
+// jsonValue returns a value as decoded from its JSON encoding, so that values
+// compare equal with reflect.DeepEqual whatever Go types they were built with
+func jsonValue(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := engine.DecodeJSON(data, &decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
@@ -0,0 +1,246 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+)
+
+// Watchdog events, set in the "watchdog" metadata of emitted messages
+const (
+	WatchdogAlert    = "alert"
+	WatchdogRecovery = "recovery"
+)
+
+// WatchdogConfig represents the configuration of a watchdog node
+type WatchdogConfig struct {
+	// Interval is the longest silence tolerated, e.g. "5m"
+	Interval string `json:"interval"`
+	// PerTopic watches every topic separately
+	PerTopic bool `json:"perTopic"`
+	// Topics are watched from startup in per-topic mode; other topics are
+	// watched once they have been seen
+	Topics []string `json:"topics"`
+	// Grace is the silence tolerated after startup; defaults to Interval
+	Grace string `json:"grace"`
+}
+
+// WatchdogNode passes messages through on its first output and emits an
+// alert on its second output when no message arrived within the interval,
+// followed by a recovery message once messages resume
+type WatchdogNode struct {
+	engine.BaseNode
+	config   WatchdogConfig
+	interval time.Duration
+	grace    time.Duration
+
+	watches map[string]*watchdogState
+	mu      sync.Mutex
+	wake    chan struct{}
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// watchdogState tracks a watched topic
+type watchdogState struct {
+	lastSeen time.Time
+	deadline time.Time
+	alerted  bool
+}
+
+// RegisterWatchdogNode registers the watchdog node type
+func RegisterWatchdogNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "watchdog",
+		Description: "Alerts when messages stop arriving",
+		Category:    "process",
+		Defaults:    json.RawMessage(`{"interval":"5m","perTopic":false}`),
+		Factory: func() engine.NodeInstance {
+			return &WatchdogNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *WatchdogNode) Init(config json.RawMessage) error {
+	n.config = WatchdogConfig{Interval: "5m"}
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &n.config); err != nil {
+			return fmt.Errorf("invalid watchdog config: %w", err)
+		}
+	}
+
+	interval, err := parseDuration("interval", n.config.Interval)
+	if err != nil {
+		return err
+	}
+	if interval == 0 {
+		return fmt.Errorf("interval is required")
+	}
+
+	grace, err := parseDuration("grace", n.config.Grace)
+	if err != nil {
+		return err
+	}
+	if grace == 0 {
+		grace = interval
+	}
+
+	n.interval = interval
+	n.grace = grace
+	n.wake = make(chan struct{}, 1)
+	return nil
+}
+
+// Start arms the startup timers
+func (n *WatchdogNode) Start(ctx context.Context) error {
+	now := n.GetNode().Clock().Now()
+
+	n.mu.Lock()
+	n.watches = make(map[string]*watchdogState)
+	if n.config.PerTopic {
+		for _, topic := range n.config.Topics {
+			n.watches[topic] = &watchdogState{lastSeen: now, deadline: now.Add(n.grace)}
+		}
+	} else {
+		n.watches[""] = &watchdogState{lastSeen: now, deadline: now.Add(n.grace)}
+	}
+	n.mu.Unlock()
+
+	ctx, n.cancel = context.WithCancel(ctx)
+	n.wg.Add(1)
+	go n.run(ctx)
+	return nil
+}
+
+// Stop stops the timers
+func (n *WatchdogNode) Stop() {
+	if n.cancel != nil {
+		n.cancel()
+		n.cancel = nil
+	}
+	n.wg.Wait()
+
+	n.mu.Lock()
+	n.watches = nil
+	n.mu.Unlock()
+}
+
+// OnMessage resets the timer and passes the message through
+func (n *WatchdogNode) OnMessage(msg *engine.Message, port int) error {
+	key := ""
+	if n.config.PerTopic {
+		key = msg.Topic
+	}
+	now := n.GetNode().Clock().Now()
+
+	n.mu.Lock()
+	if n.watches == nil {
+		n.mu.Unlock()
+		return n.GetNode().Send(msg, 0) // stopped
+	}
+	state, exists := n.watches[key]
+	if !exists {
+		state = &watchdogState{}
+		n.watches[key] = state
+	}
+	recovered := state.alerted
+	silence := now.Sub(state.lastSeen)
+	state.lastSeen = now
+	state.deadline = now.Add(n.interval)
+	state.alerted = false
+	n.mu.Unlock()
+
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+
+	if !recovered {
+		return n.GetNode().Send(msg, 0)
+	}
+	return n.GetNode().SendAll([][]*engine.Message{
+		{msg},
+		{n.event(WatchdogRecovery, key, silence)},
+	})
+}
+
+// run emits alerts when deadlines pass
+func (n *WatchdogNode) run(ctx context.Context) {
+	defer n.wg.Done()
+
+	clock := n.GetNode().Clock()
+	for {
+		var timer engine.Timer
+		var fire <-chan time.Time
+		if next, ok := n.nextDeadline(); ok {
+			timer = clock.NewTimer(next.Sub(clock.Now()))
+			fire = timer.C()
+		}
+
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-n.wake:
+		case <-fire:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+
+		for _, msg := range n.expired(clock.Now()) {
+			n.GetNode().Send(msg, 1)
+		}
+	}
+}
+
+// nextDeadline returns the earliest deadline of a topic not yet alerted
+func (n *WatchdogNode) nextDeadline() (time.Time, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var next time.Time
+	for _, state := range n.watches {
+		if !state.alerted && (next.IsZero() || state.deadline.Before(next)) {
+			next = state.deadline
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// expired marks topics past their deadline as alerted and returns the
+// alert messages
+func (n *WatchdogNode) expired(now time.Time) []*engine.Message {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var alerts []*engine.Message
+	for key, state := range n.watches {
+		if state.alerted || now.Before(state.deadline) {
+			continue
+		}
+		state.alerted = true
+		alerts = append(alerts, n.event(WatchdogAlert, key, now.Sub(state.lastSeen)))
+	}
+	return alerts
+}
+
+// event builds an alert or recovery message
+func (n *WatchdogNode) event(kind, topic string, silence time.Duration) *engine.Message {
+	msg := engine.NewMessage(map[string]interface{}{
+		"event":   kind,
+		"topic":   topic,
+		"silence": silence.Seconds(),
+	}, topic)
+	msg.SourceID = n.GetNode().ID
+	msg.SetMetadata("watchdog", kind)
+	return msg
+}
@@ -0,0 +1,223 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/yourusername/go-red/internal/engine"
+	"gopkg.in/yaml.v3"
+)
+
+// YAML document modes
+const (
+	YAMLDocumentsSingle   = "single"
+	YAMLDocumentsArray    = "array"
+	YAMLDocumentsMessages = "messages"
+)
+
+// YAMLConfig represents the configuration of a yaml node
+type YAMLConfig struct {
+	// Mode is "parse", "stringify", or "auto", which parses text and
+	// stringifies everything else
+	Mode     string `json:"mode" validate:"oneof=auto parse stringify"`
+	Property string `json:"property" validate:"required"`
+	// Documents is "single" for text holding one document, "array" to
+	// parse a multi-document stream into an array and stringify arrays
+	// as one document per item, or "messages" to send a message per
+	// document
+	Documents string `json:"documents" validate:"oneof=single array messages"`
+	Indent    int    `json:"indent" validate:"min=1,max=8"`
+}
+
+// YAMLNode converts a message property between YAML text and values. In
+// parse mode values that are not text pass unchanged, in stringify mode
+// text does. Text that fails to parse is sent unchanged on the second
+// output with the error in the "error" metadata. Parsed mappings are Go
+// maps, which have no order, so their keys are stringified in sorted
+// order; mappings with non-string keys become objects keyed by the keys'
+// text.
+type YAMLNode struct {
+	engine.BaseNode
+	config YAMLConfig
+}
+
+// RegisterYAMLNode registers the yaml node type
+func RegisterYAMLNode(r engine.NodeTypeRegistry) error {
+	return r.RegisterNodeType(&engine.NodeType{
+		Name:        "yaml",
+		Description: "Converts between YAML text and values",
+		Category:    "process",
+		Defaults:    json.RawMessage(`{"mode":"auto","property":"payload","documents":"single","indent":2}`),
+		Parallel:    true,
+		Factory: func() engine.NodeInstance {
+			return &YAMLNode{}
+		},
+	})
+}
+
+// Init initializes the node with its configuration
+func (n *YAMLNode) Init(config json.RawMessage) error {
+	return n.LoadConfig(config, &n.config)
+}
+
+// Start starts the node
+func (n *YAMLNode) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop stops the node
+func (n *YAMLNode) Stop() {}
+
+// OnMessage parses or stringifies the configured property
+func (n *YAMLNode) OnMessage(msg *engine.Message, port int) error {
+	value, exists := msg.GetProperty(n.config.Property)
+	if !exists {
+		return fmt.Errorf("yaml: message has no %s", n.config.Property)
+	}
+
+	// Streams are read once, so the text stays available on failure
+	if stream, ok := value.(*engine.Stream); ok {
+		data, err := stream.Bytes()
+		if err != nil {
+			return fmt.Errorf("yaml: failed to read stream: %w", err)
+		}
+		value = data
+		if err := msg.SetProperty(n.config.Property, data); err != nil {
+			return fmt.Errorf("yaml: %w", err)
+		}
+	}
+
+	var docs []interface{}
+	var err error
+	switch value := value.(type) {
+	case string:
+		if n.config.Mode == JSONModeStringify {
+			return n.GetNode().Send(msg, 0)
+		}
+		docs, err = n.parse(strings.NewReader(value))
+	case []byte:
+		if n.config.Mode == JSONModeStringify {
+			return n.GetNode().Send(msg, 0)
+		}
+		docs, err = n.parse(bytes.NewReader(value))
+	default:
+		if n.config.Mode == JSONModeParse {
+			return n.GetNode().Send(msg, 0)
+		}
+		text, err := n.stringify(value)
+		if err != nil {
+			return fmt.Errorf("yaml: %w", err)
+		}
+		if err := msg.SetProperty(n.config.Property, text); err != nil {
+			return fmt.Errorf("yaml: %w", err)
+		}
+		return n.GetNode().Send(msg, 0)
+	}
+	if err == nil && n.config.Documents == YAMLDocumentsSingle && len(docs) != 1 {
+		err = fmt.Errorf("expected one document, got %d", len(docs))
+	}
+	if err != nil {
+		msg.SetMetadata("error", fmt.Sprintf("invalid YAML: %v", err))
+		return n.GetNode().Send(msg, 1)
+	}
+
+	switch n.config.Documents {
+	case YAMLDocumentsMessages:
+		msgs := make([]*engine.Message, len(docs))
+		for i, doc := range docs {
+			msgs[i] = msg.Clone()
+			if err := msgs[i].SetProperty(n.config.Property, doc); err != nil {
+				return fmt.Errorf("yaml: %w", err)
+			}
+			msgs[i].SetMetadata("index", i)
+			msgs[i].SetMetadata("count", len(docs))
+		}
+		return n.GetNode().SendAll([][]*engine.Message{msgs})
+	case YAMLDocumentsArray:
+		err = msg.SetProperty(n.config.Property, docs)
+	default:
+		err = msg.SetProperty(n.config.Property, docs[0])
+	}
+	if err != nil {
+		return fmt.Errorf("yaml: %w", err)
+	}
+	return n.GetNode().Send(msg, 0)
+}
+
+// parse decodes the documents of a YAML stream
+func (n *YAMLNode) parse(r io.Reader) ([]interface{}, error) {
+	docs := []interface{}{}
+	decoder := yaml.NewDecoder(r)
+	for {
+		var doc interface{}
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			if len(docs) > 0 {
+				return nil, fmt.Errorf("document %d: %w", len(docs)+1, err)
+			}
+			return nil, err
+		}
+		docs = append(docs, yamlValue(doc))
+	}
+}
+
+// stringify encodes value as YAML text; in array mode the items of an
+// array become separate documents
+func (n *YAMLNode) stringify(value interface{}) (string, error) {
+	docs := []interface{}{value}
+	if items, ok := value.([]interface{}); ok && n.config.Documents == YAMLDocumentsArray {
+		docs = items
+	}
+
+	var out strings.Builder
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(n.config.Indent)
+	for _, doc := range docs {
+		if err := encoder.Encode(doc); err != nil {
+			return "", err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// yamlValue converts a decoded YAML value to the values decoded JSON has:
+// integers become int64, timestamps RFC 3339 strings and mapping keys
+// strings
+func yamlValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = yamlValue(item)
+		}
+		return v
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted[fmt.Sprint(key)] = yamlValue(item)
+		}
+		return converted
+	case []interface{}:
+		for i, item := range v {
+			v[i] = yamlValue(item)
+		}
+		return v
+	default:
+		return value
+	}
+}